@@ -102,6 +102,8 @@ func main() {
 	cr.LoadCachesFromConfig()
 	th.RegisterPingHandler()
 	th.RegisterConfigHandler()
+	th.RegisterCacheHealthHandler()
+	th.StartCacheHealthChecks()
 	err = rr.RegisterProxyRoutes()
 	if err != nil {
 		log.Fatal(1, "route registration failed", log.Pairs{"detail": err.Error()})
@@ -194,6 +196,21 @@ Trickster Usage:
  Simple ClickHouse Accelerator listening on 8123:
    trickster -origin-url http://clickhouse.example.com:8123/ -origin-type clickhouse -proxy-port 8123
 
+ Simple Graphite Accelerator listening on 8080:
+   trickster -origin-url http://graphite.example.com/ -origin-type graphite -proxy-port 8080
+
+ Simple Loki Accelerator listening on 3100:
+   trickster -origin-url http://loki.example.com:3100/ -origin-type loki -proxy-port 3100
+
+ Simple Elasticsearch Accelerator listening on 9200:
+   trickster -origin-url http://elasticsearch.example.com:9200/ -origin-type elasticsearch -proxy-port 9200
+
+ Simple OpenTSDB Accelerator listening on 4242:
+   trickster -origin-url http://opentsdb.example.com:4242/ -origin-type opentsdb -proxy-port 4242
+
+ Simple Druid Accelerator listening on 8082:
+   trickster -origin-url http://druid-broker.example.com:8082/ -origin-type druid -proxy-port 8082
+
 ------
 
 Trickster currently listens on port 9090 by default; Set in a config file,
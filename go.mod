@@ -2,6 +2,22 @@ module github.com/Comcast/trickster
 
 replace gotest.tools => github.com/gotestyourself/gotest.tools v2.2.0+incompatible
 
+// github.com/prometheus/prometheus is only used for its PromQL parser. It requires newer
+// versions of these modules than Trickster does, so they are pinned to their current versions.
+replace (
+	github.com/go-kit/kit => github.com/go-kit/kit v0.9.0
+	github.com/golang/protobuf => github.com/golang/protobuf v1.3.2
+	github.com/influxdata/influxdb => github.com/influxdata/influxdb v1.7.9
+	github.com/prometheus/client_golang => github.com/prometheus/client_golang v1.3.0
+	github.com/prometheus/client_model => github.com/prometheus/client_model v0.1.0
+	github.com/prometheus/common => github.com/prometheus/common v0.8.0
+	github.com/prometheus/procfs => github.com/prometheus/procfs v0.0.8
+	github.com/stretchr/testify => github.com/stretchr/testify v1.4.0
+	golang.org/x/net => golang.org/x/net v0.0.0-20200114155413-6afb5195e5aa
+	golang.org/x/sys => golang.org/x/sys v0.0.0-20200116001909-b77594299b42
+	google.golang.org/grpc => google.golang.org/grpc v1.24.0
+)
+
 require (
 	github.com/AndreasBriese/bbloom v0.0.0-20190825152654-46b345b51c96 // indirect
 	github.com/BurntSushi/toml v0.3.1
@@ -10,7 +26,7 @@ require (
 	github.com/coreos/bbolt v1.3.3
 	github.com/dgraph-io/badger v1.6.0
 	github.com/dgryski/go-farm v0.0.0-20191112170834-c2139c5d712b // indirect
-	github.com/go-kit/kit v0.9.0
+	github.com/go-kit/kit v0.10.0
 	github.com/go-logfmt/logfmt v0.5.0 // indirect
 	github.com/go-redis/redis v6.15.6+incompatible
 	github.com/go-stack/stack v1.8.0
@@ -18,19 +34,20 @@ require (
 	github.com/gomodule/redigo v2.0.0+incompatible // indirect
 	github.com/gorilla/handlers v1.4.2
 	github.com/gorilla/mux v1.7.3
-	github.com/influxdata/influxdb v1.7.9
+	github.com/influxdata/influxdb v1.8.0
 	github.com/philhofer/fwd v1.0.0 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
-	github.com/prometheus/client_golang v1.3.0
-	github.com/prometheus/common v0.8.0
-	github.com/stretchr/testify v1.4.0
+	github.com/prometheus/client_golang v1.5.1
+	github.com/prometheus/common v0.9.1
+	github.com/prometheus/prometheus v1.8.2-0.20200507164740-ecee9c8abfd1
+	github.com/stretchr/testify v1.5.1
 	github.com/tinylib/msgp v1.1.1
 	github.com/yuin/gopher-lua v0.0.0-20190514113301-1cd887cd7036 // indirect
 	go.opentelemetry.io/otel v0.2.0
 	go.opentelemetry.io/otel/exporter/trace/jaeger v0.2.0
-	golang.org/x/net v0.0.0-20200114155413-6afb5195e5aa
-	golang.org/x/sys v0.0.0-20200116001909-b77594299b42
-	google.golang.org/grpc v1.24.0
+	golang.org/x/net v0.0.0-20200421231249-e086a090c8fd
+	golang.org/x/sys v0.0.0-20200420163511-1957bb5e6d1f
+	google.golang.org/grpc v1.29.0
 	gopkg.in/natefinch/lumberjack.v2 v2.0.0
 )
 
@@ -0,0 +1,206 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+// Package breaker provides a circuit breaker that allows Trickster to bypass
+// an unhealthy cache and proxy requests directly to the origin
+package breaker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Comcast/trickster/internal/config"
+	"github.com/Comcast/trickster/internal/util/log"
+	"github.com/Comcast/trickster/internal/util/metrics"
+)
+
+// State enumerates the states of a circuit breaker
+type State int
+
+const (
+	// StateClosed indicates the cache is healthy and requests use it normally
+	StateClosed = State(iota)
+	// StateHalfOpen indicates the cache is being probed to determine if it has recovered
+	StateHalfOpen
+	// StateOpen indicates the cache is unhealthy and requests bypass it
+	StateOpen
+)
+
+var stateNames = map[State]string{
+	StateClosed:   "closed",
+	StateHalfOpen: "half_open",
+	StateOpen:     "open",
+}
+
+func (s State) String() string {
+	return stateNames[s]
+}
+
+// probeInterval is the minimum time between probe requests allowed through a half-open breaker
+const probeInterval = time.Second
+
+// Breakers maintains the circuit breakers of the active caches, keyed by cache name
+var Breakers = make(map[string]*Breaker)
+
+// Get returns the Breaker for the named cache, or nil if the cache has no breaker.
+// A nil Breaker always allows requests, so callers need not check the result.
+func Get(cacheName string) *Breaker {
+	return Breakers[cacheName]
+}
+
+// Register creates and registers a Breaker for the provided cache, if it is enabled in the cache's config
+func Register(cacheName string, cfg *config.CachingConfig) *Breaker {
+	if cfg == nil || !cfg.CircuitBreaker.Enabled {
+		delete(Breakers, cacheName)
+		return nil
+	}
+	b := NewBreaker(cacheName, cfg.CacheType, cfg.CircuitBreaker)
+	Breakers[cacheName] = b
+	return b
+}
+
+// Breaker is a circuit breaker that tracks the error rate and latency of cache operations.
+// When the breaker is open, callers should bypass the cache entirely.
+type Breaker struct {
+	name      string
+	cacheType string
+	config    config.CircuitBreakerConfig
+
+	mtx         sync.Mutex
+	state       State
+	windowStart time.Time
+	operations  int
+	failures    int
+	openedAt    time.Time
+	lastProbe   time.Time
+	successes   int
+
+	now func() time.Time
+}
+
+// NewBreaker returns a new, closed Breaker for the provided cache
+func NewBreaker(cacheName, cacheType string, cfg config.CircuitBreakerConfig) *Breaker {
+	b := &Breaker{name: cacheName, cacheType: cacheType, config: cfg, now: time.Now}
+	b.windowStart = b.now()
+	metrics.CacheCircuitBreakerState.WithLabelValues(cacheName, cacheType).Set(float64(StateClosed))
+	return b
+}
+
+// State returns the current state of the Breaker
+func (b *Breaker) State() State {
+	if b == nil {
+		return StateClosed
+	}
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.state
+}
+
+// Allow returns true if the caller may use the cache. When the breaker is open, Allow returns false
+// until the open period has elapsed, after which the breaker becomes half-open and periodically
+// allows a probe request through to test the cache.
+func (b *Breaker) Allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	now := b.now()
+	switch b.state {
+	case StateOpen:
+		if now.Sub(b.openedAt) < b.config.OpenDuration {
+			return false
+		}
+		b.transition(StateHalfOpen, now)
+		b.lastProbe = now
+		return true
+	case StateHalfOpen:
+		if now.Sub(b.lastProbe) < probeInterval {
+			return false
+		}
+		b.lastProbe = now
+		return true
+	}
+	return true
+}
+
+// Record registers the outcome of a cache operation. Operations that return an error, or that
+// take longer than the configured latency threshold, are counted as failures.
+func (b *Breaker) Record(err error, latency time.Duration) {
+	if b == nil {
+		return
+	}
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	failed := err != nil || (b.config.LatencyThreshold > 0 && latency > b.config.LatencyThreshold)
+	now := b.now()
+
+	switch b.state {
+	case StateOpen:
+		// operations that began before the breaker opened are ignored
+		return
+	case StateHalfOpen:
+		if failed {
+			b.transition(StateOpen, now)
+			return
+		}
+		b.successes++
+		if b.successes >= b.config.HalfOpenSuccesses {
+			b.transition(StateClosed, now)
+		}
+		return
+	}
+
+	if now.Sub(b.windowStart) >= b.config.Window {
+		b.windowStart = now
+		b.operations = 0
+		b.failures = 0
+	}
+
+	b.operations++
+	if failed {
+		b.failures++
+	}
+
+	if b.operations >= b.config.MinOperations &&
+		float64(b.failures)/float64(b.operations) >= b.config.ErrorRateThreshold {
+		b.transition(StateOpen, now)
+	}
+}
+
+// transition moves the breaker into the provided state. The caller must hold the lock.
+func (b *Breaker) transition(s State, now time.Time) {
+
+	pairs := log.Pairs{"cacheName": b.name, "cacheType": b.cacheType, "from": b.state.String(), "to": s.String()}
+	if b.state == StateClosed {
+		pairs["operations"] = b.operations
+		pairs["failures"] = b.failures
+	}
+
+	b.state = s
+	b.successes = 0
+	b.operations = 0
+	b.failures = 0
+	b.windowStart = now
+	if s == StateOpen {
+		b.openedAt = now
+		log.Warn("cache circuit breaker opened", pairs)
+	} else {
+		log.Info("cache circuit breaker state change", pairs)
+	}
+
+	metrics.CacheCircuitBreakerState.WithLabelValues(b.name, b.cacheType).Set(float64(s))
+	metrics.CacheCircuitBreakerTransitions.WithLabelValues(b.name, b.cacheType, s.String()).Inc()
+}
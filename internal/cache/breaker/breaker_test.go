@@ -0,0 +1,169 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Comcast/trickster/internal/config"
+	"github.com/Comcast/trickster/internal/util/metrics"
+)
+
+func init() {
+	metrics.Init()
+}
+
+var errTest = errors.New("test error")
+
+func testBreaker() (*Breaker, *time.Time) {
+	cfg := config.CircuitBreakerConfig{
+		Enabled:            true,
+		ErrorRateThreshold: 0.5,
+		LatencyThreshold:   100 * time.Millisecond,
+		MinOperations:      4,
+		Window:             10 * time.Second,
+		OpenDuration:       15 * time.Second,
+		HalfOpenSuccesses:  2,
+	}
+	now := time.Unix(1577836800, 0)
+	b := NewBreaker("test", "redis", cfg)
+	b.now = func() time.Time { return now }
+	b.windowStart = now
+	return b, &now
+}
+
+func TestNilBreaker(t *testing.T) {
+	var b *Breaker
+	if !b.Allow() {
+		t.Error("expected nil breaker to allow")
+	}
+	b.Record(errTest, 0)
+	if b.State() != StateClosed {
+		t.Errorf("expected %s got %s", StateClosed, b.State())
+	}
+}
+
+func TestRegister(t *testing.T) {
+	cfg := config.NewCacheConfig()
+	if Register("test", cfg) != nil || Get("test") != nil {
+		t.Error("expected nil breaker for disabled config")
+	}
+	cfg.CircuitBreaker.Enabled = true
+	b := Register("test", cfg)
+	if b == nil || Get("test") != b {
+		t.Error("expected registered breaker")
+	}
+	cfg.CircuitBreaker.Enabled = false
+	Register("test", cfg)
+	if Get("test") != nil {
+		t.Error("expected breaker to be unregistered")
+	}
+}
+
+func TestBreakerOpensOnErrorRate(t *testing.T) {
+	b, _ := testBreaker()
+
+	b.Record(nil, 0)
+	b.Record(errTest, 0)
+	b.Record(errTest, 0)
+	// MinOperations has not been reached
+	if b.State() != StateClosed {
+		t.Errorf("expected %s got %s", StateClosed, b.State())
+	}
+
+	b.Record(nil, 0)
+	if b.State() != StateOpen {
+		t.Errorf("expected %s got %s", StateOpen, b.State())
+	}
+	if b.Allow() {
+		t.Error("expected open breaker to disallow")
+	}
+}
+
+func TestBreakerOpensOnLatency(t *testing.T) {
+	b, _ := testBreaker()
+	for i := 0; i < 4; i++ {
+		b.Record(nil, time.Second)
+	}
+	if b.State() != StateOpen {
+		t.Errorf("expected %s got %s", StateOpen, b.State())
+	}
+}
+
+func TestBreakerWindowReset(t *testing.T) {
+	b, now := testBreaker()
+	b.Record(errTest, 0)
+	b.Record(errTest, 0)
+	b.Record(errTest, 0)
+	*now = now.Add(11 * time.Second)
+	// the prior failures have aged out of the window
+	b.Record(nil, 0)
+	b.Record(nil, 0)
+	b.Record(nil, 0)
+	b.Record(errTest, 0)
+	if b.State() != StateClosed {
+		t.Errorf("expected %s got %s", StateClosed, b.State())
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	b, now := testBreaker()
+	for i := 0; i < 4; i++ {
+		b.Record(errTest, 0)
+	}
+
+	*now = now.Add(16 * time.Second)
+	if !b.Allow() {
+		t.Error("expected probe to be allowed")
+	}
+	if b.State() != StateHalfOpen {
+		t.Errorf("expected %s got %s", StateHalfOpen, b.State())
+	}
+	// only one probe per interval
+	if b.Allow() {
+		t.Error("expected second probe to be disallowed")
+	}
+
+	// a failed probe reopens the breaker
+	b.Record(errTest, 0)
+	if b.State() != StateOpen {
+		t.Errorf("expected %s got %s", StateOpen, b.State())
+	}
+
+	*now = now.Add(16 * time.Second)
+	b.Allow()
+	b.Record(nil, 0)
+	if b.State() != StateHalfOpen {
+		t.Errorf("expected %s got %s", StateHalfOpen, b.State())
+	}
+	*now = now.Add(time.Second)
+	if !b.Allow() {
+		t.Error("expected probe to be allowed")
+	}
+	b.Record(nil, 0)
+	if b.State() != StateClosed {
+		t.Errorf("expected %s got %s", StateClosed, b.State())
+	}
+	if !b.Allow() {
+		t.Error("expected closed breaker to allow")
+	}
+}
+
+func TestStateString(t *testing.T) {
+	if StateHalfOpen.String() != "half_open" {
+		t.Errorf("expected %s got %s", "half_open", StateHalfOpen.String())
+	}
+}
@@ -97,3 +97,9 @@ func ObserveCacheSizeChange(cache, cacheType string, byteCount, objectCount int6
 	metrics.CacheObjects.WithLabelValues(cache, cacheType).Set(float64(objectCount))
 	metrics.CacheBytes.WithLabelValues(cache, cacheType).Set(float64(byteCount))
 }
+
+// ObserveOriginCacheSizeChange adjusts the gauges for an origin's share of a cache as the size changes due to object operations
+func ObserveOriginCacheSizeChange(cache, cacheType, originName string, byteCount, objectCount int64) {
+	metrics.CacheOriginObjects.WithLabelValues(cache, cacheType, originName).Set(float64(objectCount))
+	metrics.CacheOriginBytes.WithLabelValues(cache, cacheType, originName).Set(float64(byteCount))
+}
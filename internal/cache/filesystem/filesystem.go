@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -28,11 +29,17 @@ import (
 	"github.com/Comcast/trickster/internal/cache/status"
 	"github.com/Comcast/trickster/internal/config"
 	"github.com/Comcast/trickster/internal/util/log"
+	"github.com/Comcast/trickster/internal/util/md5"
 	"github.com/Comcast/trickster/pkg/locks"
 )
 
 var lockPrefix string
 
+const (
+	dataFileSuffix = ".data"
+	tempFilePrefix = ".tmp."
+)
+
 // Cache describes a Filesystem Cache
 type Cache struct {
 	Name   string
@@ -54,11 +61,94 @@ func (c *Cache) Connect() error {
 	lockPrefix = c.Name + ".file."
 
 	// Load Index here and pass bytes as param2
-	indexData, _, _ := c.retrieve(index.IndexKey, false, false)
+	indexData, _, err := c.retrieve(index.IndexKey, false, false)
+	if err == nil {
+		_, err = (&index.Index{}).UnmarshalMsg(indexData)
+	}
+	if err != nil {
+		// the index is missing or corrupt, so start with an empty one and
+		// repopulate it from the objects present on disk
+		c.Index = index.NewIndex(c.Name, c.Config.CacheType, nil, c.Config.Index, c.BulkRemove, c.storeNoIndex)
+		c.rebuildIndex()
+		return nil
+	}
 	c.Index = index.NewIndex(c.Name, c.Config.CacheType, indexData, c.Config.Index, c.BulkRemove, c.storeNoIndex)
 	return nil
 }
 
+// rebuildIndex walks the cache path and adds each valid, unexpired object to the Index.
+// Corrupt objects, expired objects and orphaned temp files from interrupted writes are removed.
+// Objects written under a different ShardDepth (e.g., a flat cache from before sharding) are
+// moved to their current location, since they could otherwise never be retrieved or reaped.
+func (c *Cache) rebuildIndex() {
+
+	var recovered, removed, moved int
+	now := time.Now()
+	misplaced := make(map[string]*index.Object)
+
+	filepath.Walk(c.Config.Filesystem.CachePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		name := info.Name()
+		if strings.HasPrefix(name, tempFilePrefix) {
+			os.Remove(path)
+			removed++
+			return nil
+		}
+		if !strings.HasSuffix(name, dataFileSuffix) {
+			return nil
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		o, err := index.ObjectFromBytes(data)
+		if err == nil && o.Key == index.IndexKey && path == c.getFileName(o.Key) {
+			return nil
+		}
+		if err != nil || o.Key == "" || o.Key == index.IndexKey ||
+			(!o.Expiration.IsZero() && o.Expiration.Before(now)) {
+			os.Remove(path)
+			removed++
+			return nil
+		}
+		if path != c.getFileName(o.Key) {
+			// moved once the walk completes, so that objects are not visited twice
+			misplaced[path] = o
+			return nil
+		}
+		c.Index.UpdateObject(o)
+		recovered++
+		return nil
+	})
+
+	for path, o := range misplaced {
+		if c.moveFile(path, c.getFileName(o.Key)) {
+			c.Index.UpdateObject(o)
+			moved++
+			continue
+		}
+		os.Remove(path)
+		removed++
+	}
+
+	log.Info("filesystem cache index rebuilt", log.Pairs{"name": c.Name, "cachePath": c.Config.Filesystem.CachePath,
+		"recoveredObjects": recovered, "movedObjects": moved, "removedFiles": removed})
+}
+
+// moveFile renames the data file at path to dataFile, and returns true if it was moved. A
+// dataFile that already exists is kept, as it was written under the current ShardDepth.
+func (c *Cache) moveFile(path, dataFile string) bool {
+	if _, err := os.Stat(dataFile); err == nil {
+		return false
+	}
+	if err := c.makeShardDirectories(filepath.Dir(dataFile)); err != nil {
+		return false
+	}
+	return os.Rename(path, dataFile) == nil
+}
+
 // Store places an object in the cache using the specified key and ttl
 func (c *Cache) Store(cacheKey string, data []byte, ttl time.Duration) error {
 	return c.store(cacheKey, data, ttl, true)
@@ -88,7 +178,7 @@ func (c *Cache) store(cacheKey string, data []byte, ttl time.Duration, updateInd
 	locks.Acquire(lockPrefix + cacheKey)
 
 	o := &index.Object{Key: cacheKey, Value: data, Expiration: time.Now().Add(ttl)}
-	err := ioutil.WriteFile(dataFile, o.ToBytes(), os.FileMode(0777))
+	err := c.writeFile(dataFile, o.ToBytes())
 	if err != nil {
 		locks.Release(lockPrefix + cacheKey)
 		return err
@@ -185,9 +275,72 @@ func (c *Cache) Close() error {
 	return nil
 }
 
+// writeFile atomically writes data to the provided filename by writing to a temp file
+// in the destination directory and renaming it into place once fully written, so that
+// a crash mid-write never leaves a partial object behind
+func (c *Cache) writeFile(filename string, data []byte) error {
+
+	dir := filepath.Dir(filename)
+	if err := c.makeShardDirectories(dir); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(dir, tempFilePrefix)
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err == nil {
+		err = os.Chmod(tmp, c.Config.Filesystem.FileMode)
+	}
+	if err == nil {
+		err = os.Rename(tmp, filename)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// makeShardDirectories creates any missing shard directories between the cache path and dir.
+// The cache path itself is not created, so a missing or removed cache path fails the write.
+func (c *Cache) makeShardDirectories(dir string) error {
+	root := filepath.Clean(c.Config.Filesystem.CachePath)
+	parent := filepath.Dir(dir)
+	if dir == root || parent == dir {
+		return nil
+	}
+	if err := c.makeShardDirectories(parent); err != nil {
+		return err
+	}
+	if err := os.Mkdir(dir, c.Config.Filesystem.DirectoryMode); err != nil && !os.IsExist(err) {
+		return err
+	}
+	return nil
+}
+
+// getFileName returns the path to the data file for the provided cacheKey. When sharding is
+// enabled, the file is placed in nested subdirectories named from the leading bytes of the
+// key's md5 hash (e.g., /tmp/trickster/3f/a2/cacheKey.data for a ShardDepth of 2)
 func (c *Cache) getFileName(cacheKey string) string {
-	prefix := strings.Replace(c.Config.Filesystem.CachePath+"/"+cacheKey+".", "//", "/", 1)
-	return prefix + "data"
+	parts := make([]string, 1, c.Config.Filesystem.ShardDepth+2)
+	parts[0] = c.Config.Filesystem.CachePath
+	if c.Config.Filesystem.ShardDepth > 0 {
+		h := md5.Checksum(cacheKey)
+		for i := 0; i < c.Config.Filesystem.ShardDepth && i < len(h)/2; i++ {
+			parts = append(parts, h[i*2:i*2+2])
+		}
+	}
+	parts = append(parts, cacheKey+dataFileSuffix)
+	return filepath.Join(parts...)
 }
 
 // writeable returns true if the path is writeable by the calling process.
@@ -16,13 +16,16 @@ package filesystem
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/Comcast/trickster/internal/cache/index"
 	"github.com/Comcast/trickster/internal/cache/status"
 	"github.com/Comcast/trickster/internal/util/log"
+	"github.com/Comcast/trickster/internal/util/md5"
 
 	"github.com/Comcast/trickster/internal/config"
 	"github.com/Comcast/trickster/internal/util/metrics"
@@ -38,7 +41,8 @@ const cacheKey = "cacheKey"
 func storeBenchmark(b *testing.B) Cache {
 	log.Logger = log.ConsoleLogger("none")
 	dir, _ := ioutil.TempDir("/tmp", cacheType)
-	cacheConfig := config.CachingConfig{CacheType: cacheType, Filesystem: config.FilesystemCacheConfig{CachePath: dir}, Index: config.CacheIndexConfig{ReapInterval: time.Second}}
+	cacheConfig := config.CachingConfig{CacheType: cacheType, Filesystem: config.FilesystemCacheConfig{CachePath: dir,
+		ShardDepth: 2, FileMode: 0644, DirectoryMode: 0755}, Index: config.CacheIndexConfig{ReapInterval: time.Second}}
 	fc := Cache{Config: &cacheConfig}
 	defer os.RemoveAll(cacheConfig.BBolt.Filename)
 
@@ -62,7 +66,8 @@ func newCacheConfig(t *testing.T) config.CachingConfig {
 	if err != nil {
 		t.Fatalf("could not create temp directory (%s): %s", dir, err)
 	}
-	return config.CachingConfig{CacheType: cacheType, Filesystem: config.FilesystemCacheConfig{CachePath: dir}, Index: config.CacheIndexConfig{ReapInterval: time.Second}}
+	return config.CachingConfig{CacheType: cacheType, Filesystem: config.FilesystemCacheConfig{CachePath: dir,
+		ShardDepth: 2, FileMode: 0644, DirectoryMode: 0755}, Index: config.CacheIndexConfig{ReapInterval: time.Second}}
 }
 
 func TestConfiguration(t *testing.T) {
@@ -107,7 +112,7 @@ func TestFilesystemCache_ConnectFailed(t *testing.T) {
 func TestFilesystemCache_Store(t *testing.T) {
 
 	const expected1 = "invalid ttl: -1"
-	const expected2 = "open /root/noaccess.trickster.filesystem.cache/cacheKey.data:"
+	const expected2 = "mkdir /root/noaccess.trickster.filesystem.cache/"
 
 	cacheConfig := newCacheConfig(t)
 	defer os.RemoveAll(cacheConfig.Filesystem.CachePath)
@@ -145,6 +150,196 @@ func TestFilesystemCache_Store(t *testing.T) {
 
 }
 
+func TestFilesystemCache_GetFileName(t *testing.T) {
+
+	cacheConfig := newCacheConfig(t)
+	defer os.RemoveAll(cacheConfig.Filesystem.CachePath)
+	fc := Cache{Config: &cacheConfig}
+
+	expected := cacheConfig.Filesystem.CachePath + "/" + md5.Checksum(cacheKey)[0:2] + "/" +
+		md5.Checksum(cacheKey)[2:4] + "/cacheKey.data"
+	if s := fc.getFileName(cacheKey); s != expected {
+		t.Errorf("expected %s got %s", expected, s)
+	}
+
+	cacheConfig.Filesystem.ShardDepth = 0
+	expected = cacheConfig.Filesystem.CachePath + "/cacheKey.data"
+	if s := fc.getFileName(cacheKey); s != expected {
+		t.Errorf("expected %s got %s", expected, s)
+	}
+}
+
+func TestFilesystemCache_StoreAtomic(t *testing.T) {
+
+	cacheConfig := newCacheConfig(t)
+	cacheConfig.Filesystem.FileMode = 0600
+	defer os.RemoveAll(cacheConfig.Filesystem.CachePath)
+	fc := Cache{Config: &cacheConfig}
+
+	err := fc.Connect()
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = fc.Store(cacheKey, []byte("data"), time.Duration(60)*time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+
+	filename := fc.getFileName(cacheKey)
+	fi, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Errorf("expected %o got %o", 0600, fi.Mode().Perm())
+	}
+
+	// no temp files should remain in the shard directory
+	files, err := ioutil.ReadDir(filepath.Dir(filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("expected %d got %d", 1, len(files))
+	}
+}
+
+func TestFilesystemCache_RebuildIndex(t *testing.T) {
+
+	cacheConfig := newCacheConfig(t)
+	defer os.RemoveAll(cacheConfig.Filesystem.CachePath)
+	fc := Cache{Config: &cacheConfig}
+
+	err := fc.Connect()
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = fc.Store(cacheKey, []byte("data"), time.Duration(60)*time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+	err = fc.Store(cacheKey+"2", []byte("data"), time.Duration(60)*time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// simulate an expired object, a corrupt object and an interrupted write
+	expired := &index.Object{Key: cacheKey + "3", Value: []byte("data"), Expiration: time.Now().Add(-time.Hour)}
+	err = fc.writeFile(fc.getFileName(cacheKey+"3"), expired.ToBytes())
+	if err != nil {
+		t.Error(err)
+	}
+	err = fc.writeFile(fc.getFileName(cacheKey+"4"), []byte("junk"))
+	if err != nil {
+		t.Error(err)
+	}
+	tmp := filepath.Join(filepath.Dir(fc.getFileName(cacheKey)), tempFilePrefix+"12345")
+	err = ioutil.WriteFile(tmp, []byte("partial"), 0644)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// write a corrupt index and reconnect
+	err = fc.writeFile(fc.getFileName(index.IndexKey), []byte("junk"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	fc2 := Cache{Config: &cacheConfig}
+	err = fc2.Connect()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if fc2.Index.ObjectCount != 2 {
+		t.Errorf("expected %d got %d", 2, fc2.Index.ObjectCount)
+	}
+
+	data, ls, err := fc2.Retrieve(cacheKey, false)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(data) != "data" {
+		t.Errorf("wanted \"%s\". got \"%s\".", "data", data)
+	}
+	if ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+
+	for _, f := range []string{tmp, fc.getFileName(cacheKey + "3"), fc.getFileName(cacheKey + "4")} {
+		if _, err := os.Stat(f); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed", f)
+		}
+	}
+}
+
+func TestFilesystemCache_RebuildIndexShardDepth(t *testing.T) {
+
+	cacheConfig := newCacheConfig(t)
+	defer os.RemoveAll(cacheConfig.Filesystem.CachePath)
+
+	// populate a flat cache, as written before sharding
+	cacheConfig.Filesystem.ShardDepth = 0
+	fc := Cache{Config: &cacheConfig}
+	err := fc.Connect()
+	if err != nil {
+		t.Error(err)
+	}
+	for _, k := range []string{cacheKey, cacheKey + "2"} {
+		err = fc.Store(k, []byte("data"), time.Duration(60)*time.Second)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	fc.storeNoIndex(index.IndexKey, []byte("stale index"))
+	flat := []string{fc.getFileName(cacheKey), fc.getFileName(cacheKey + "2"), fc.getFileName(index.IndexKey)}
+
+	// the flat cache also has a stale copy of an object that was rewritten since the upgrade
+	cacheConfig.Filesystem.ShardDepth = 2
+	fc2 := Cache{Config: &cacheConfig}
+	err = fc2.writeFile(fc2.getFileName(cacheKey+"2"), (&index.Object{Key: cacheKey + "2", Value: []byte("newer"),
+		Expiration: time.Now().Add(time.Minute)}).ToBytes())
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = fc2.Connect()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if fc2.Index.ObjectCount != 2 {
+		t.Errorf("expected %d got %d", 2, fc2.Index.ObjectCount)
+	}
+
+	for k, v := range map[string]string{cacheKey: "data", cacheKey + "2": "newer"} {
+		data, ls, err := fc2.Retrieve(k, false)
+		if err != nil {
+			t.Error(err)
+		}
+		if string(data) != v {
+			t.Errorf("wanted \"%s\". got \"%s\".", v, data)
+		}
+		if ls != status.LookupStatusHit {
+			t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+		}
+	}
+
+	for _, f := range flat {
+		if _, err := os.Stat(f); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed", f)
+		}
+	}
+
+	// the moved objects are removed from their new location
+	fc2.Remove(cacheKey)
+	if _, err := os.Stat(fc2.getFileName(cacheKey)); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed", fc2.getFileName(cacheKey))
+	}
+}
+
 func BenchmarkCache_Store(b *testing.B) {
 	fc := storeBenchmark(b)
 	defer fc.Close()
@@ -0,0 +1,125 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package cache
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// HealthCheckKeyPrefix is the prefix of the keys written to a cache during a health check
+const HealthCheckKeyPrefix = "trickster.healthcheck."
+
+const healthCheckTTL = 60 * time.Second
+
+// ErrHealthCheckTimeout is returned when a cache health check does not complete within the allotted timeout
+var ErrHealthCheckTimeout = errors.New("health check timed out")
+
+// ErrHealthCheckInProgress is returned when an earlier health check of the cache has timed out
+// and has still not completed
+var ErrHealthCheckInProgress = errors.New("previous health check has not completed")
+
+// ErrHealthCheckMismatch is returned when a cache health check retrieves a value that differs from the one stored
+var ErrHealthCheckMismatch = errors.New("health check retrieved an unexpected value")
+
+// HealthStatus describes the outcome of a Store/Retrieve/Remove health check against a cache
+type HealthStatus struct {
+	// Name is the name of the cache that was checked
+	Name string `json:"name"`
+	// CacheType is the type of the cache that was checked
+	CacheType string `json:"cache_type"`
+	// Healthy is true when the round-trip completed successfully
+	Healthy bool `json:"healthy"`
+	// LatencyMS is the elapsed round-trip time in milliseconds
+	LatencyMS float64 `json:"latency_ms"`
+	// Error describes the failure, if any
+	Error string `json:"error,omitempty"`
+}
+
+// CheckHealth performs a store/retrieve/remove round-trip against the provided cache, and returns
+// the result. If the round-trip does not complete within the timeout, the cache is reported as unhealthy.
+func CheckHealth(cacheName string, c Cache, timeout time.Duration) *HealthStatus {
+
+	hs := &HealthStatus{Name: cacheName, CacheType: c.Configuration().CacheType}
+
+	start := time.Now()
+
+	var err error
+	if ch := startRoundTrip(cacheName, c); ch == nil {
+		err = ErrHealthCheckInProgress
+	} else {
+		select {
+		case err = <-ch:
+		case <-time.After(timeout):
+			err = ErrHealthCheckTimeout
+		}
+	}
+
+	hs.LatencyMS = float64(time.Since(start)) / float64(time.Millisecond)
+	if err != nil {
+		hs.Error = err.Error()
+		ObserveCacheEvent(hs.Name, hs.CacheType, "error", "health_check")
+	} else {
+		hs.Healthy = true
+	}
+
+	return hs
+}
+
+// inFlight holds the names of the caches with a health check round-trip in progress. Cache
+// operations can't be cancelled, so a round-trip that outlives its timeout keeps running until
+// the cache responds; in the meantime, further checks of that cache fail without starting
+// another round-trip, so that an unresponsive cache holds at most one goroutine.
+var inFlight = make(map[string]bool)
+var inFlightMtx sync.Mutex
+
+// startRoundTrip runs a round-trip against the cache in the background and returns the channel
+// that receives its result, or nil if a round-trip for the cache is already in progress
+func startRoundTrip(cacheName string, c Cache) chan error {
+	inFlightMtx.Lock()
+	defer inFlightMtx.Unlock()
+	if inFlight[cacheName] {
+		return nil
+	}
+	inFlight[cacheName] = true
+	ch := make(chan error, 1)
+	go func() {
+		err := roundTrip(c)
+		inFlightMtx.Lock()
+		delete(inFlight, cacheName)
+		inFlightMtx.Unlock()
+		ch <- err
+	}()
+	return ch
+}
+
+func roundTrip(c Cache) error {
+	key := HealthCheckKeyPrefix + strconv.FormatInt(time.Now().UnixNano(), 10)
+	value := []byte(key)
+	if err := c.Store(key, value, healthCheckTTL); err != nil {
+		return err
+	}
+	defer c.Remove(key)
+	b, _, err := c.Retrieve(key, false)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(b, value) {
+		return ErrHealthCheckMismatch
+	}
+	return nil
+}
@@ -16,6 +16,7 @@ package index
 
 import (
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -50,6 +51,14 @@ type Index struct {
 	flushInterval  time.Duration                      `msg:"-"`
 	flushFunc      func(cacheKey string, data []byte) `msg:"-"`
 	lastWrite      time.Time                          `msg:"-"`
+	originUsage    map[string]*originUsage            `msg:"-"`
+	tenantUsage    map[string]*originUsage            `msg:"-"`
+}
+
+// originUsage tracks the portion of the cache used by an origin, or by one of its tenants
+type originUsage struct {
+	size  int64
+	count int64
 }
 
 // ToBytes returns a serialized byte slice representing the Index
@@ -109,6 +118,19 @@ func NewIndex(cacheName, cacheType string, indexData []byte, cfg config.CacheInd
 	i.bulkRemoveFunc = bulkRemoveFunc
 	i.config = cfg
 
+	// per-origin usage is derived from the objects rather than persisted with the index
+	i.originUsage = make(map[string]*originUsage)
+	i.tenantUsage = make(map[string]*originUsage)
+	for prefix := range cfg.OriginQuotas {
+		i.originUsage[prefix] = &originUsage{}
+	}
+	for key, o := range i.Objects {
+		i.updateOriginUsage(key, o.Size, 1)
+	}
+	for prefix := range i.originUsage {
+		i.observeOriginUsage(prefix)
+	}
+
 	if flushFunc != nil {
 		if i.flushInterval > 0 {
 			go i.flusher()
@@ -170,13 +192,18 @@ func (idx *Index) UpdateObject(obj *Object) {
 	obj.LastWrite = obj.LastAccess
 
 	if o, ok := idx.Objects[key]; ok {
-		idx.CacheSize += o.Size - idx.Objects[key].Size
+		idx.CacheSize += obj.Size - o.Size
+		idx.updateOriginUsage(key, obj.Size-o.Size, 0)
 	} else {
 		idx.CacheSize += obj.Size
 		idx.ObjectCount++
+		idx.updateOriginUsage(key, obj.Size, 1)
 	}
 
 	cache.ObserveCacheSizeChange(idx.name, idx.cacheType, idx.CacheSize, idx.ObjectCount)
+	if prefix := idx.originPrefix(key); prefix != "" {
+		idx.observeOriginUsage(prefix)
+	}
 
 	idx.Objects[key] = obj
 	indexLock.Unlock()
@@ -197,6 +224,9 @@ func (idx *Index) RemoveObject(key string, noLock bool) {
 
 		delete(idx.Objects, key)
 		cache.ObserveCacheSizeChange(idx.name, idx.cacheType, idx.CacheSize, idx.ObjectCount)
+		if prefix := idx.updateOriginUsage(key, -o.Size, -1); prefix != "" {
+			idx.observeOriginUsage(prefix)
+		}
 	}
 	if !noLock {
 		indexLock.Unlock()
@@ -215,6 +245,73 @@ func (idx *Index) GetExpiration(cacheKey string) time.Time {
 	return time.Time{}
 }
 
+// originPrefix returns the CacheKeyPrefix of the origin that owns the provided key,
+// or an empty string if the key does not belong to a known origin
+func (idx *Index) originPrefix(key string) string {
+	var prefix string
+	for p := range idx.config.OriginQuotas {
+		// the longest matching prefix wins, in case one origin's prefix is a prefix of another's
+		if len(p) > len(prefix) && strings.HasPrefix(key, p+".") {
+			prefix = p
+		}
+	}
+	return prefix
+}
+
+// updateOriginUsage adjusts the usage of the origin that owns the provided key,
+// and returns that origin's CacheKeyPrefix
+func (idx *Index) updateOriginUsage(key string, sizeDelta, countDelta int64) string {
+	prefix := idx.originPrefix(key)
+	if prefix == "" {
+		return ""
+	}
+	u, ok := idx.originUsage[prefix]
+	if !ok {
+		u = &originUsage{}
+		idx.originUsage[prefix] = u
+	}
+	u.size += sizeDelta
+	u.count += countDelta
+
+	if g := idx.tenantGroup(key, prefix); g != "" {
+		tu, ok := idx.tenantUsage[g]
+		if !ok {
+			tu = &originUsage{}
+			idx.tenantUsage[g] = tu
+		}
+		tu.size += sizeDelta
+		tu.count += countDelta
+		if tu.count <= 0 {
+			delete(idx.tenantUsage, g)
+		}
+	}
+
+	return prefix
+}
+
+// tenantGroup returns the leading portion of the provided key that identifies both its origin
+// and tenant, when the quotas of the origin with the provided prefix apply to each tenant.
+// Otherwise, it returns an empty string.
+func (idx *Index) tenantGroup(key, prefix string) string {
+	if q, ok := idx.config.OriginQuotas[prefix]; !ok || !q.PerTenant {
+		return ""
+	}
+	i := strings.IndexByte(key[len(prefix)+1:], '.')
+	if i < 1 {
+		return ""
+	}
+	return key[:len(prefix)+1+i]
+}
+
+func (idx *Index) observeOriginUsage(prefix string) {
+	q, ok := idx.config.OriginQuotas[prefix]
+	if !ok {
+		return
+	}
+	u := idx.originUsage[prefix]
+	cache.ObserveOriginCacheSizeChange(idx.name, idx.cacheType, q.OriginName, u.size, u.count)
+}
+
 // flusher periodically calls the cache's index flush func that writes the cache index to disk
 func (idx *Index) flusher() {
 	var lastFlush time.Time
@@ -280,6 +377,18 @@ func (idx *Index) reap() {
 		cacheChanged = true
 	}
 
+	if idx.reapOrigins(remainders) {
+		cacheChanged = true
+		// drop anything evicted for exceeding its origin's quota from the remainders
+		present := make(objectsAtime, 0, len(remainders))
+		for _, o := range remainders {
+			if _, ok := idx.Objects[o.Key]; ok {
+				present = append(present, o)
+			}
+		}
+		remainders = present
+	}
+
 	if ((idx.config.MaxSizeBytes > 0 && idx.CacheSize > idx.config.MaxSizeBytes) || (idx.config.MaxSizeObjects > 0 && idx.ObjectCount > idx.config.MaxSizeObjects)) && len(remainders) > 0 {
 
 		var evictionType string
@@ -299,35 +408,12 @@ func (idx *Index) reap() {
 			},
 		)
 
-		removals = make([]string, 0)
-
 		sort.Sort(remainders)
 
-		i := 0
-		j := len(remainders)
-
 		if evictionType == "size_bytes" {
-			bytesNeeded := (idx.CacheSize - idx.config.MaxSizeBytes)
-			if idx.config.MaxSizeBytes > idx.config.MaxSizeBackoffBytes {
-				bytesNeeded += idx.config.MaxSizeBackoffBytes
-			}
-			bytesSelected := int64(0)
-			for bytesSelected < bytesNeeded && i < j {
-				removals = append(removals, remainders[i].Key)
-				bytesSelected += remainders[i].Size
-				i++
-			}
+			removals = evictionCandidates(remainders, true, idx.CacheSize, idx.config.MaxSizeBytes, idx.config.MaxSizeBackoffBytes)
 		} else {
-			objectsNeeded := (idx.ObjectCount - idx.config.MaxSizeObjects)
-			if idx.config.MaxSizeObjects > idx.config.MaxSizeBackoffObjects {
-				objectsNeeded += idx.config.MaxSizeBackoffObjects
-			}
-			objectsSelected := int64(0)
-			for objectsSelected < objectsNeeded && i < j {
-				removals = append(removals, remainders[i].Key)
-				objectsSelected++
-				i++
-			}
+			removals = evictionCandidates(remainders, false, idx.ObjectCount, idx.config.MaxSizeObjects, idx.config.MaxSizeBackoffObjects)
 		}
 
 		if len(removals) > 0 {
@@ -349,6 +435,112 @@ func (idx *Index) reap() {
 	}
 }
 
+// reapOrigins evicts the least-recently-accessed elements of each origin that exceeds its quota,
+// leaving other origins sharing the cache untouched. For origins whose quotas apply to each tenant,
+// only the elements of the tenants exceeding the quota are evicted. It returns true if anything was evicted
+func (idx *Index) reapOrigins(remainders objectsAtime) bool {
+
+	var evicted bool
+
+	type quotaGroup struct {
+		q       *config.OriginCacheQuota
+		u       *originUsage
+		tenant  bool
+		objects objectsAtime
+	}
+
+	exceeds := func(q *config.OriginCacheQuota, u *originUsage) bool {
+		return (q.MaxSizeBytes > 0 && u.size > q.MaxSizeBytes) || (q.MaxSizeObjects > 0 && u.count > q.MaxSizeObjects)
+	}
+
+	groups := make(map[string]*quotaGroup)
+	for prefix, q := range idx.config.OriginQuotas {
+		if q.PerTenant {
+			continue
+		}
+		if u, ok := idx.originUsage[prefix]; ok && exceeds(q, u) {
+			groups[prefix] = &quotaGroup{q: q, u: u, objects: make(objectsAtime, 0, u.count)}
+		}
+	}
+	for g, u := range idx.tenantUsage {
+		if q, ok := idx.config.OriginQuotas[idx.originPrefix(g+".")]; ok && exceeds(q, u) {
+			groups[g] = &quotaGroup{q: q, u: u, tenant: true, objects: make(objectsAtime, 0, u.count)}
+		}
+	}
+	if len(groups) == 0 {
+		return false
+	}
+
+	for _, o := range remainders {
+		g := idx.originPrefix(o.Key)
+		if tg := idx.tenantGroup(o.Key, g); tg != "" {
+			g = tg
+		}
+		if group, ok := groups[g]; ok {
+			group.objects = append(group.objects, o)
+		}
+	}
+
+	for g, group := range groups {
+
+		q, u := group.q, group.u
+
+		var evictionType string
+		var removals []string
+
+		scope := "origin"
+		if group.tenant {
+			scope = "tenant"
+		}
+
+		sort.Sort(group.objects)
+
+		if q.MaxSizeBytes > 0 && u.size > q.MaxSizeBytes {
+			evictionType = scope + "_size_bytes"
+			removals = evictionCandidates(group.objects, true, u.size, q.MaxSizeBytes, idx.config.MaxSizeBackoffBytes)
+		} else {
+			evictionType = scope + "_size_objects"
+			removals = evictionCandidates(group.objects, false, u.count, q.MaxSizeObjects, idx.config.MaxSizeBackoffObjects)
+		}
+
+		log.Debug("max origin cache size reached. evicting least-recently-accessed records",
+			log.Pairs{
+				"reason": evictionType, "originName": q.OriginName, "group": g,
+				"originSizeBytes": u.size, "maxSizeBytes": q.MaxSizeBytes,
+				"originSizeObjects": u.count, "maxSizeObjects": q.MaxSizeObjects,
+			},
+		)
+
+		if len(removals) > 0 {
+			cache.ObserveCacheEvent(idx.name, idx.cacheType, "eviction", evictionType)
+			idx.bulkRemoveFunc(removals, true)
+			evicted = true
+		}
+	}
+
+	return evicted
+}
+
+// evictionCandidates returns the keys of the least-recently-accessed objects in o, which must be sorted,
+// that must be removed to bring the usage (in bytes or objects) to below max, less the backoff
+func evictionCandidates(o objectsAtime, bytes bool, usage, max, backoff int64) []string {
+	removals := make([]string, 0)
+	needed := usage - max
+	if max > backoff {
+		needed += backoff
+	}
+	var selected int64
+	for i := 0; selected < needed && i < len(o); i++ {
+		removals = append(removals, o[i].Key)
+		if bytes {
+			selected += o[i].Size
+		} else {
+			selected++
+		}
+	}
+	return removals
+}
+
 // Len returns the length of an array of Prometheus model.Times
 func (o objectsAtime) Len() int {
 	return len(o)
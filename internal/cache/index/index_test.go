@@ -153,6 +153,101 @@ func TestReap(t *testing.T) {
 
 }
 
+func TestReapOriginQuotas(t *testing.T) {
+
+	cacheConfig := &config.CachingConfig{CacheType: "test", Index: config.CacheIndexConfig{ReapInterval: time.Second * time.Duration(10), FlushInterval: time.Second * time.Duration(10)}}
+	cacheConfig.Index.MaxSizeBackoffObjects = 1
+	cacheConfig.Index.OriginQuotas = map[string]*config.OriginCacheQuota{
+		"noisy":       {OriginName: "noisy", MaxSizeObjects: 2},
+		"noisy.bytes": {OriginName: "noisy-bytes", MaxSizeBytes: 15},
+		"quiet":       {OriginName: "quiet"},
+	}
+
+	idx := NewIndex("test", "test", nil, cacheConfig.Index, testBulkRemoveFunc, fakeFlusherFunc)
+	testBulkIndex = idx
+
+	for _, key := range []string{"noisy.1", "noisy.2", "noisy.3", "noisy.4", "quiet.1", "quiet.2", "quiet.3", "noisy.bytes.1", "noisy.bytes.2"} {
+		idx.UpdateObject(&Object{Key: key, Value: []byte("test_value")})
+	}
+
+	if u := idx.originUsage["noisy"]; u.count != 4 || u.size != 40 {
+		t.Errorf("expected 4 objects and 40 bytes, got %d and %d", u.count, u.size)
+	}
+
+	if u := idx.originUsage["noisy.bytes"]; u.count != 2 || u.size != 20 {
+		t.Errorf("expected 2 objects and 20 bytes, got %d and %d", u.count, u.size)
+	}
+
+	idx.reap()
+
+	// noisy should be evicted down to its quota, less the backoff
+	if u := idx.originUsage["noisy"]; u.count != 1 {
+		t.Errorf("expected 1 object, got %d", u.count)
+	}
+
+	if _, ok := idx.Objects["noisy.4"]; !ok {
+		t.Errorf("expected key %s to be present", "noisy.4")
+	}
+
+	// noisy.bytes should be evicted down to its byte quota
+	if u := idx.originUsage["noisy.bytes"]; u.count != 1 || u.size != 10 {
+		t.Errorf("expected 1 object and 10 bytes, got %d and %d", u.count, u.size)
+	}
+
+	// quiet has no quota and should be untouched
+	if u := idx.originUsage["quiet"]; u.count != 3 {
+		t.Errorf("expected 3 objects, got %d", u.count)
+	}
+
+	// usage should be rebuilt when the index is loaded
+	idx2 := NewIndex("test", "test", idx.ToBytes(), cacheConfig.Index, testBulkRemoveFunc, fakeFlusherFunc)
+	if u := idx2.originUsage["quiet"]; u.count != 3 || u.size != 30 {
+		t.Errorf("expected 3 objects and 30 bytes, got %d and %d", u.count, u.size)
+	}
+
+}
+
+func TestReapTenantQuotas(t *testing.T) {
+
+	cacheConfig := &config.CachingConfig{CacheType: "test", Index: config.CacheIndexConfig{ReapInterval: time.Second * time.Duration(10), FlushInterval: time.Second * time.Duration(10)}}
+	cacheConfig.Index.MaxSizeBackoffObjects = 1
+	cacheConfig.Index.OriginQuotas = map[string]*config.OriginCacheQuota{
+		"shared": {OriginName: "shared", MaxSizeObjects: 2, PerTenant: true},
+	}
+
+	idx := NewIndex("test", "test", nil, cacheConfig.Index, testBulkRemoveFunc, fakeFlusherFunc)
+	testBulkIndex = idx
+
+	for _, key := range []string{"shared.a.1", "shared.a.2", "shared.a.3", "shared.a.4", "shared.b.1", "shared.b.2"} {
+		idx.UpdateObject(&Object{Key: key, Value: []byte("test_value")})
+	}
+
+	if u := idx.originUsage["shared"]; u.count != 6 {
+		t.Errorf("expected 6 objects, got %d", u.count)
+	}
+
+	if u := idx.tenantUsage["shared.a"]; u.count != 4 || u.size != 40 {
+		t.Errorf("expected 4 objects and 40 bytes, got %d and %d", u.count, u.size)
+	}
+
+	idx.reap()
+
+	// tenant a should be evicted down to the quota, less the backoff
+	if u := idx.tenantUsage["shared.a"]; u.count != 1 {
+		t.Errorf("expected 1 object, got %d", u.count)
+	}
+
+	// tenant b is within the quota and should be untouched
+	if u := idx.tenantUsage["shared.b"]; u.count != 2 {
+		t.Errorf("expected 2 objects, got %d", u.count)
+	}
+
+	if u := idx.originUsage["shared"]; u.count != 3 {
+		t.Errorf("expected 3 objects, got %d", u.count)
+	}
+
+}
+
 func TestObjectFromBytes(t *testing.T) {
 
 	obj := &Object{}
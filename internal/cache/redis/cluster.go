@@ -29,7 +29,9 @@ func (c *Cache) clusterOpts() (*redis.ClusterOptions, error) {
 		Addrs: c.Config.Redis.Endpoints,
 	}
 
-	if c.Config.Redis.Password != "" {
+	if c.Config.Redis.Username != "" {
+		o.OnConnect = c.aclOnConnect()
+	} else if c.Config.Redis.Password != "" {
 		o.Password = c.Config.Redis.Password
 	}
 
@@ -81,5 +83,11 @@ func (c *Cache) clusterOpts() (*redis.ClusterOptions, error) {
 		o.IdleCheckFrequency = durationFromMS(c.Config.Redis.IdleCheckFrequencyMS)
 	}
 
+	tc, err := c.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	o.TLSConfig = tc
+
 	return o, nil
 }
@@ -16,6 +16,9 @@
 package redis
 
 import (
+	"crypto/tls"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-redis/redis"
@@ -29,6 +32,9 @@ import (
 // Redis is the string "redis"
 const Redis = "redis"
 
+// bulkRemoveBatchSize is the maximum number of keys removed in a single pipeline
+const bulkRemoveBatchSize = 1000
+
 // Cache represents a redis cache object that conforms to the Cache interface
 type Cache struct {
 	Name   string
@@ -36,6 +42,10 @@ type Cache struct {
 
 	client redis.Cmdable
 	closer func() error
+
+	// noUnlink is set to 1 once the server has rejected UNLINK (Redis < 4.0),
+	// after which bulk removals fall back to DEL
+	noUnlink int32
 }
 
 // Configuration returns the Configuration for the Cache object
@@ -80,16 +90,34 @@ func (c *Cache) Connect() error {
 func (c *Cache) Store(cacheKey string, data []byte, ttl time.Duration) error {
 	cache.ObserveCacheOperation(c.Name, c.Config.CacheType, "set", "none", float64(len(data)))
 	log.Debug("redis cache store", log.Pairs{"key": cacheKey})
-	return c.client.Set(cacheKey, data, ttl).Err()
+	return c.client.Set(cacheKey, data, c.storageTTL(ttl)).Err()
 }
 
-// Retrieve gets data from the Redis Cache using the provided Key
-// because Redis manages Object Expiration internally, allowExpired is not used.
+// Retrieve gets data from the Redis Cache using the provided Key. The object's remaining
+// TTL is fetched in the same pipeline, so that objects retained beyond their TTL by
+// stale_ttl_ms are only returned when allowExpired is true.
 func (c *Cache) Retrieve(cacheKey string, allowExpired bool) ([]byte, status.LookupStatus, error) {
-	res, err := c.client.Get(cacheKey).Result()
+
+	pipe := c.client.Pipeline()
+	getCmd := pipe.Get(cacheKey)
+	ttlCmd := pipe.PTTL(cacheKey)
+
+	// Exec returns redis.Nil when the key is not found, which is handled as a miss below
+	if _, err := pipe.Exec(); err != nil && err != redis.Nil {
+		log.Debug("redis cache retrieve failed", log.Pairs{"key": cacheKey, "reason": err.Error()})
+		cache.ObserveCacheMiss(cacheKey, c.Name, c.Config.CacheType)
+		return nil, status.LookupStatusError, err
+	}
+
+	res, err := getCmd.Result()
 
 	if err == nil {
 		data := []byte(res)
+		if !allowExpired && c.isStale(ttlCmd.Val()) {
+			log.Debug("redis cache object expired", log.Pairs{"key": cacheKey})
+			cache.ObserveCacheMiss(cacheKey, c.Name, c.Config.CacheType)
+			return nil, status.LookupStatusKeyMiss, cache.ErrKNF
+		}
 		log.Debug("redis cache retrieve", log.Pairs{"key": cacheKey})
 		cache.ObserveCacheOperation(c.Name, c.Config.CacheType, "get", "hit", float64(len(data)))
 		return data, status.LookupStatusHit, nil
@@ -115,16 +143,107 @@ func (c *Cache) Remove(cacheKey string) {
 
 // SetTTL updates the TTL for the provided cache object
 func (c *Cache) SetTTL(cacheKey string, ttl time.Duration) {
-	c.client.Expire(cacheKey, ttl)
+	c.client.Expire(cacheKey, c.storageTTL(ttl))
 }
 
-// BulkRemove removes a list of objects from the cache. noLock is not used for Redis
+// BulkRemove removes a list of objects from the cache using pipelined UNLINK commands,
+// or DEL commands when the server does not support UNLINK. Each key is removed by its own
+// command so that keys hashing to different Redis Cluster slots can share a pipeline.
+// noLock is not used for Redis
 func (c *Cache) BulkRemove(cacheKeys []string, noLock bool) {
-	log.Debug("redis cache bulk remove", log.Pairs{})
-	c.client.Del(cacheKeys...)
+	log.Debug("redis cache bulk remove", log.Pairs{"count": len(cacheKeys)})
+	for i := 0; i < len(cacheKeys); i += bulkRemoveBatchSize {
+		j := i + bulkRemoveBatchSize
+		if j > len(cacheKeys) {
+			j = len(cacheKeys)
+		}
+		if err := c.removeBatch(cacheKeys[i:j]); err != nil {
+			log.Error("redis cache bulk remove failed", log.Pairs{"reason": err.Error()})
+		}
+	}
 	cache.ObserveCacheDel(c.Name, c.Config.CacheType, float64(len(cacheKeys)))
 }
 
+func (c *Cache) removeBatch(cacheKeys []string) error {
+	if atomic.LoadInt32(&c.noUnlink) == 0 {
+		err := c.pipelinedRemove(cacheKeys, true)
+		if err == nil || !isUnknownCommand(err) {
+			return err
+		}
+		log.Debug("redis server does not support UNLINK, falling back to DEL", log.Pairs{})
+		atomic.StoreInt32(&c.noUnlink, 1)
+	}
+	return c.pipelinedRemove(cacheKeys, false)
+}
+
+func (c *Cache) pipelinedRemove(cacheKeys []string, unlink bool) error {
+	pipe := c.client.Pipeline()
+	for _, key := range cacheKeys {
+		if unlink {
+			pipe.Unlink(key)
+		} else {
+			pipe.Del(key)
+		}
+	}
+	_, err := pipe.Exec()
+	return err
+}
+
+func isUnknownCommand(err error) bool {
+	return strings.Contains(strings.ToLower(err.Error()), "unknown command")
+}
+
+// storageTTL returns the TTL to set in Redis for an object with the provided TTL,
+// which is extended by stale_ttl_ms so the object can be served stale
+func (c *Cache) storageTTL(ttl time.Duration) time.Duration {
+	if ttl <= 0 || c.Config.Redis.StaleTTLMS <= 0 {
+		return ttl
+	}
+	return ttl + durationFromMS(c.Config.Redis.StaleTTLMS)
+}
+
+// isStale returns true if an object with the provided remaining storage TTL has
+// outlived its TTL and is only being retained for stale_ttl_ms
+func (c *Cache) isStale(remaining time.Duration) bool {
+	if c.Config.Redis.StaleTTLMS <= 0 || remaining < 0 {
+		return false
+	}
+	return remaining <= durationFromMS(c.Config.Redis.StaleTTLMS)
+}
+
+// tlsConfig returns the client TLS configuration for connecting to Redis, or nil if TLS is not enabled
+func (c *Cache) tlsConfig() (*tls.Config, error) {
+	if !c.Config.Redis.UseTLS {
+		return nil, nil
+	}
+	if c.Config.Redis.TLS == nil {
+		return &tls.Config{}, nil
+	}
+	return c.Config.Redis.TLS.ClientTLSConfig()
+}
+
+// aclOnConnect returns a connection hook that authenticates with a Redis 6 ACL username and
+// password, and then selects the configured DB. It returns nil when no username is configured.
+// The go-redis client only supports password-based AUTH, so when a username is configured,
+// the Password and DB client options are left unset and handled here instead.
+func (c *Cache) aclOnConnect() func(*redis.Conn) error {
+	if c.Config.Redis.Username == "" {
+		return nil
+	}
+	username := c.Config.Redis.Username
+	password := c.Config.Redis.Password
+	db := c.Config.Redis.DB
+	return func(cn *redis.Conn) error {
+		if err := cn.Do("auth", username, password).Err(); err != nil {
+			return err
+		}
+		if db > 0 {
+			return cn.Select(db).Err()
+		}
+		return nil
+	}
+}
+
 // Close disconnects from the Redis Cache
 func (c *Cache) Close() error {
 	log.Info("closing redis connection", log.Pairs{})
@@ -18,6 +18,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/Comcast/trickster/internal/cache"
 	"github.com/Comcast/trickster/internal/cache/status"
 	"github.com/Comcast/trickster/internal/util/log"
 
@@ -348,6 +349,25 @@ func TestRedisCache_Retrieve(t *testing.T) {
 	if string(data) != "data" {
 		t.Errorf("wanted \"%s\". got \"%s\"", "data", data)
 	}
+
+	// a missing key is a miss
+	_, ls, err = rc.Retrieve(cacheKey+"-missing", false)
+	if err != cache.ErrKNF {
+		t.Errorf("expected %v got %v", cache.ErrKNF, err)
+	}
+	if ls != status.LookupStatusKeyMiss {
+		t.Errorf("expected %s got %s", status.LookupStatusKeyMiss, ls)
+	}
+
+	// an unreachable server is an error, not a miss
+	close()
+	_, ls, err = rc.Retrieve(cacheKey, false)
+	if err == nil || err == cache.ErrKNF {
+		t.Errorf("expected connection error got %v", err)
+	}
+	if ls != status.LookupStatusError {
+		t.Errorf("expected %s got %s", status.LookupStatusError, ls)
+	}
 }
 
 func BenchmarkCache_Retrieve(b *testing.B) {
@@ -495,6 +515,146 @@ func TestCache_BulkRemove(t *testing.T) {
 	}
 }
 
+func TestCache_BulkRemoveBatches(t *testing.T) {
+
+	rc, close := setupRedisCache(clientTypeStandard)
+	defer close()
+
+	err := rc.Connect()
+	if err != nil {
+		t.Error(err)
+	}
+	defer rc.Close()
+
+	keys := make([]string, bulkRemoveBatchSize+10)
+	for i := range keys {
+		keys[i] = cacheKey + strconv.Itoa(i)
+		err = rc.Store(keys[i], []byte("data"), time.Duration(60)*time.Second)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	rc.BulkRemove(keys, true)
+
+	// miniredis does not support UNLINK, so the cache should have fallen back to DEL
+	if rc.noUnlink != 1 {
+		t.Errorf("expected %d got %d", 1, rc.noUnlink)
+	}
+
+	for _, key := range []string{keys[0], keys[bulkRemoveBatchSize], keys[len(keys)-1]} {
+		_, ls, _ := rc.Retrieve(key, false)
+		if ls != status.LookupStatusKeyMiss {
+			t.Errorf("expected %s got %s", status.LookupStatusKeyMiss, ls)
+		}
+	}
+}
+
+func TestRedisCache_RetrieveStale(t *testing.T) {
+	rc, close := setupRedisCache(clientTypeStandard)
+	defer close()
+
+	rc.Config.Redis.StaleTTLMS = 60000
+
+	err := rc.Connect()
+	if err != nil {
+		t.Error(err)
+	}
+	defer rc.Close()
+
+	err = rc.Store(cacheKey, []byte("data"), time.Duration(60)*time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// the stored TTL should be extended by the stale ttl
+	ttl := rc.client.PTTL(cacheKey).Val()
+	if ttl != 120*time.Second {
+		t.Errorf("expected %s got %s", 120*time.Second, ttl)
+	}
+
+	// a fresh object should be a hit
+	_, ls, err := rc.Retrieve(cacheKey, false)
+	if err != nil {
+		t.Error(err)
+	}
+	if ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+
+	// simulate the object's TTL passing, leaving it within the stale window
+	rc.client.Expire(cacheKey, 30*time.Second)
+
+	_, ls, err = rc.Retrieve(cacheKey, false)
+	if err == nil {
+		t.Errorf("expected key not found error for %s", cacheKey)
+	}
+	if ls != status.LookupStatusKeyMiss {
+		t.Errorf("expected %s got %s", status.LookupStatusKeyMiss, ls)
+	}
+
+	// it should be served when expired objects are allowed
+	data, ls, err := rc.Retrieve(cacheKey, true)
+	if err != nil {
+		t.Error(err)
+	}
+	if ls != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, ls)
+	}
+	if string(data) != "data" {
+		t.Errorf("wanted \"%s\". got \"%s\"", "data", data)
+	}
+}
+
+func TestClientOptsTLSAndUsername(t *testing.T) {
+
+	const expected1 = `open bad-ca-path: no such file or directory`
+
+	rc, close := setupRedisCache(clientTypeStandard)
+	defer close()
+
+	rc.Config.Redis.Username = "test_user"
+	rc.Config.Redis.Password = "test_password"
+	rc.Config.Redis.DB = 3
+	rc.Config.Redis.UseTLS = true
+	rc.Config.Redis.Endpoints = []string{rc.Config.Redis.Endpoint}
+
+	o, err := rc.clientOpts()
+	if err != nil {
+		t.Error(err)
+	}
+	if o.TLSConfig == nil {
+		t.Errorf("expected non-nil TLS config")
+	}
+	// with a username, AUTH and SELECT are handled by the OnConnect hook
+	if o.Password != "" || o.DB != 0 || o.OnConnect == nil {
+		t.Errorf("expected username auth via OnConnect, got password %s db %d", o.Password, o.DB)
+	}
+
+	co, err := rc.clusterOpts()
+	if err != nil {
+		t.Error(err)
+	}
+	if co.TLSConfig == nil || co.OnConnect == nil {
+		t.Errorf("expected TLS config and OnConnect hook for cluster options")
+	}
+
+	rc.Config.Redis.SentinelMaster = "test_master"
+	so, err := rc.sentinelOpts()
+	if err != nil {
+		t.Error(err)
+	}
+	if so.TLSConfig == nil || so.OnConnect == nil {
+		t.Errorf("expected TLS config and OnConnect hook for sentinel options")
+	}
+
+	rc.Config.Redis.TLS = &config.TLSConfig{CertificateAuthorityPaths: []string{"bad-ca-path"}}
+	_, err = rc.clientOpts()
+	if err == nil || err.Error() != expected1 {
+		t.Errorf("expected error for %s", expected1)
+	}
+}
+
 func BenchmarkCache_BulkRemove(b *testing.B) {
 	rc, close := storeBenchmark(b)
 	defer close()
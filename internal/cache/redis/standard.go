@@ -33,11 +33,13 @@ func (c *Cache) clientOpts() (*redis.Options, error) {
 		o.Network = c.Config.Redis.Protocol
 	}
 
-	if c.Config.Redis.Password != "" {
+	if c.Config.Redis.Username != "" {
+		o.OnConnect = c.aclOnConnect()
+	} else if c.Config.Redis.Password != "" {
 		o.Password = c.Config.Redis.Password
 	}
 
-	if c.Config.Redis.DB != 0 {
+	if c.Config.Redis.DB != 0 && c.Config.Redis.Username == "" {
 		o.DB = c.Config.Redis.DB
 	}
 
@@ -89,5 +91,11 @@ func (c *Cache) clientOpts() (*redis.Options, error) {
 		o.IdleCheckFrequency = durationFromMS(c.Config.Redis.IdleCheckFrequencyMS)
 	}
 
+	tc, err := c.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	o.TLSConfig = tc
+
 	return o, nil
 }
@@ -21,6 +21,7 @@ import (
 	"github.com/Comcast/trickster/internal/cache"
 	"github.com/Comcast/trickster/internal/cache/badger"
 	"github.com/Comcast/trickster/internal/cache/bbolt"
+	"github.com/Comcast/trickster/internal/cache/breaker"
 	"github.com/Comcast/trickster/internal/cache/filesystem"
 	"github.com/Comcast/trickster/internal/cache/memory"
 	"github.com/Comcast/trickster/internal/cache/redis"
@@ -74,5 +75,6 @@ func NewCache(cacheName string, cfg *config.CachingConfig) cache.Cache {
 	}
 
 	c.Connect()
+	breaker.Register(cacheName, cfg)
 	return c
 }
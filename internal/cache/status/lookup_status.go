@@ -44,6 +44,9 @@ const (
 	LookupStatusNegativeCacheHit
 	// LookupStatusError indicates that there was an error looking up the object in the cache
 	LookupStatusError
+	// LookupStatusStale indicates that the origin failed, so an expired object that was retained
+	// by the cache was served in place of the origin's error
+	LookupStatusStale
 )
 
 var cacheLookupStatusNames = map[string]LookupStatus{
@@ -57,6 +60,7 @@ var cacheLookupStatusNames = map[string]LookupStatus{
 	"proxy-only":  LookupStatusProxyOnly,
 	"nchit":       LookupStatusNegativeCacheHit,
 	"error":       LookupStatusError,
+	"stale":       LookupStatusStale,
 }
 
 var cacheLookupStatusValues = map[LookupStatus]string{
@@ -70,6 +74,7 @@ var cacheLookupStatusValues = map[LookupStatus]string{
 	LookupStatusProxyOnly:        "proxy-only",
 	LookupStatusNegativeCacheHit: "nchit",
 	LookupStatusError:            "error",
+	LookupStatusStale:            "stale",
 }
 
 func (s LookupStatus) String() string {
@@ -20,7 +20,8 @@ func TestLookupStatusString(t *testing.T) {
 	t1 := LookupStatusHit
 	t2 := LookupStatusKeyMiss
 
-	var t3 LookupStatus = 10
+	t4 := LookupStatusStale
+	var t3 LookupStatus = 11
 
 	if t1.String() != "hit" {
 		t.Errorf("expected %s got %s", "hit", t1.String())
@@ -30,7 +31,11 @@ func TestLookupStatusString(t *testing.T) {
 		t.Errorf("expected %s got %s", "kmiss", t2.String())
 	}
 
-	if t3.String() != "10" {
-		t.Errorf("expected %s got %s", "9", t3.String())
+	if t4.String() != "stale" {
+		t.Errorf("expected %s got %s", "stale", t4.String())
+	}
+
+	if t3.String() != "11" {
+		t.Errorf("expected %s got %s", "11", t3.String())
 	}
 }
@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"fmt"
 	"net/http"
+	"os"
 	"strings"
 	"time"
 
@@ -93,6 +94,12 @@ type MainConfig struct {
 	ConfigHandlerPath string `toml:"config_handler_path"`
 	// PingHandlerPath provides the path to register the Ping Handler for checking that Trickster is running
 	PingHandlerPath string `toml:"ping_handler_path"`
+	// CacheHealthHandlerPath provides the path to register the Cache Health Handler for checking the configured caches
+	CacheHealthHandlerPath string `toml:"cache_health_handler_path"`
+	// CacheHealthTimeoutMS is how long the Cache Health Handler waits for each cache's health check to complete
+	CacheHealthTimeoutMS int `toml:"cache_health_timeout_ms"`
+	// CacheHealthIntervalMS is how often each cache's health check runs in the background, 0 disables
+	CacheHealthIntervalMS int `toml:"cache_health_interval_ms"`
 }
 
 // OriginConfig is a collection of configurations for prometheus origins proxied by Trickster
@@ -119,6 +126,24 @@ type OriginConfig struct {
 	CacheName string `toml:"cache_name"`
 	// CacheKeyPrefix defines the cache key prefix the origin will use when writing objects to the cache
 	CacheKeyPrefix string `toml:"cache_key_prefix"`
+	// CacheMaxSizeBytes indicates how many bytes of the cache the origin can use before the
+	// Cache Index evicts the origin's least-recently-accessed items. 0 is unlimited
+	CacheMaxSizeBytes int64 `toml:"cache_max_size_bytes"`
+	// CacheMaxSizeObjects indicates how many objects the origin can have in the cache before the
+	// Cache Index evicts the origin's least-recently-accessed items. 0 is unlimited
+	CacheMaxSizeObjects int64 `toml:"cache_max_size_objects"`
+	// TenantHeader is the name of the HTTP request header that identifies the tenant, such as
+	// X-Scope-OrgID. When set, cache keys and cache quotas are split by tenant
+	TenantHeader string `toml:"tenant_header"`
+	// TenantHeaderRequired indicates whether requests without the TenantHeader are rejected
+	TenantHeaderRequired bool `toml:"tenant_header_required"`
+	// TenantMetricsAllowList lists the tenants whose requests are counted under their own tenant
+	// label value in the tenant request metrics. Requests of all other tenants are counted as "other"
+	TenantMetricsAllowList []string `toml:"tenant_metrics_allowlist"`
+	// LabelRules enforces label matchers in the queries made to Prometheus origins, keyed by label name
+	LabelRules map[string]*LabelRuleConfig `toml:"label_rules"`
+	// SQLHTTP configures how the statements and results of 'sqlhttp' origins are interpreted
+	SQLHTTP *SQLHTTPConfig `toml:"sqlhttp"`
 	// HealthCheckUpstreamPath provides the URL path for the upstream health check
 	HealthCheckUpstreamPath string `toml:"health_check_upstream_path"`
 	// HealthCheckVerb provides the HTTP verb to use when making an upstream health check
@@ -137,6 +162,9 @@ type OriginConfig struct {
 	// BackfillToleranceSecs prevents values with timestamps newer than the provided number of seconds from being cached
 	// this allows propagation of upstream backfill operations that modify recently-served data
 	BackfillToleranceSecs int64 `toml:"backfill_tolerance_secs"`
+	// ValueRetentionSecs limits how far back raw sample timeseries, which have no step to apply the
+	// TimeseriesRetentionFactor to, are cached when the eviction method is 'oldest'
+	ValueRetentionSecs int64 `toml:"value_retention_secs"`
 	// PathList is a list of PathConfigs that control the behavior of the given paths when requested
 	Paths map[string]*PathConfig `toml:"paths"`
 	// NegativeCacheName provides the name of the Negative Cache Config to be used by this Origin
@@ -204,10 +232,23 @@ type OriginConfig struct {
 	HTTPClient *http.Client `toml:"-"`
 	// CompressableTypes is the map version of CompressableTypeList for fast lookup
 	CompressableTypes map[string]bool `toml:"-"`
+	// TenantMetricsAllowed is the map version of TenantMetricsAllowList for fast lookup
+	TenantMetricsAllowed map[string]bool `toml:"-"`
 	// TracingConfig is the reference to the Tracing Config as indicated by TracingConfigName
 	TracingConfig *TracingConfig `toml:"-"`
 }
 
+// LabelRuleConfig is a rule that restricts the values of a label that queries can select.
+// The allowed values are provided by each request, in a header or a claim of its bearer token.
+type LabelRuleConfig struct {
+	// Label is the name of the enforced label, which is the rule's key in the origin's label_rules
+	Label string `toml:"-"`
+	// Header is the name of the request header providing the comma-separated allowed values
+	Header string `toml:"header"`
+	// JWTClaim is the name of the claim in the request's bearer token providing the allowed values
+	JWTClaim string `toml:"jwt_claim"`
+}
+
 // CachingConfig is a collection of defining the Trickster Caching Behavior
 type CachingConfig struct {
 	// Name is the Name of the cache, taken from the Key in the Caches map[string]*CacheConfig
@@ -224,6 +265,8 @@ type CachingConfig struct {
 	BBolt BBoltCacheConfig `toml:"bbolt"`
 	// Badger provides options for BadgerDB caching
 	Badger BadgerCacheConfig `toml:"badger"`
+	// CircuitBreaker provides options for bypassing the cache when it is unhealthy
+	CircuitBreaker CircuitBreakerConfig `toml:"circuit_breaker"`
 
 	//  Synthetic Values
 
@@ -253,6 +296,73 @@ type CacheIndexConfig struct {
 
 	ReapInterval  time.Duration `toml:"-"`
 	FlushInterval time.Duration `toml:"-"`
+	// OriginQuotas maps the CacheKeyPrefix of each origin using the cache to its quota,
+	// and is automatically populated at startup
+	OriginQuotas map[string]*OriginCacheQuota `toml:"-"`
+}
+
+// OriginCacheQuota defines how much of a cache an origin may use
+type OriginCacheQuota struct {
+	// OriginName is the name of the origin
+	OriginName string
+	// MaxSizeBytes is the origin's cache_max_size_bytes. 0 is unlimited
+	MaxSizeBytes int64
+	// MaxSizeObjects is the origin's cache_max_size_objects. 0 is unlimited
+	MaxSizeObjects int64
+	// PerTenant indicates the quota applies to each of the origin's tenants separately,
+	// which is the case when the origin has a tenant_header
+	PerTenant bool
+}
+
+func (q *OriginCacheQuota) hasLimits() bool {
+	return q.MaxSizeBytes > 0 || q.MaxSizeObjects > 0 || q.PerTenant
+}
+
+// CircuitBreakerConfig defines when a cache's circuit breaker opens, causing requests to bypass the cache
+type CircuitBreakerConfig struct {
+	// Enabled indicates whether the circuit breaker is active for the cache
+	Enabled bool `toml:"enabled"`
+	// ErrorRateThreshold is the fraction (0.0 - 1.0) of failed cache operations within a window that opens the breaker
+	ErrorRateThreshold float64 `toml:"error_rate_threshold"`
+	// LatencyThresholdMS is the duration after which a cache operation is counted as failed, even if it succeeds.
+	// 0 disables latency-based failures
+	LatencyThresholdMS int `toml:"latency_threshold_ms"`
+	// MinOperations is the minimum number of cache operations in a window before the error rate is evaluated
+	MinOperations int `toml:"min_operations"`
+	// WindowSecs is the length of the window over which the error rate is evaluated
+	WindowSecs int `toml:"window_secs"`
+	// OpenSecs is how long the breaker stays open before probing the cache
+	OpenSecs int `toml:"open_secs"`
+	// HalfOpenSuccesses is the number of consecutive successful probe operations required to close the breaker
+	HalfOpenSuccesses int `toml:"half_open_successes"`
+
+	LatencyThreshold time.Duration `toml:"-"`
+	Window           time.Duration `toml:"-"`
+	OpenDuration     time.Duration `toml:"-"`
+}
+
+// validate returns an error if the circuit breaker settings can't be used to evaluate a cache
+func (cb *CircuitBreakerConfig) validate() error {
+	if cb.ErrorRateThreshold <= 0 || cb.ErrorRateThreshold > 1 {
+		return fmt.Errorf("error_rate_threshold must be greater than 0 and at most 1, got %v", cb.ErrorRateThreshold)
+	}
+	if cb.LatencyThresholdMS < 0 {
+		return fmt.Errorf("latency_threshold_ms must not be negative, got %d", cb.LatencyThresholdMS)
+	}
+	for _, v := range []struct {
+		name  string
+		value int
+	}{
+		{"min_operations", cb.MinOperations},
+		{"window_secs", cb.WindowSecs},
+		{"open_secs", cb.OpenSecs},
+		{"half_open_successes", cb.HalfOpenSuccesses},
+	} {
+		if v.value <= 0 {
+			return fmt.Errorf("%s must be greater than 0, got %d", v.name, v.value)
+		}
+	}
+	return nil
 }
 
 // RedisCacheConfig is a collection of Configurations for Connecting to Redis
@@ -265,8 +375,17 @@ type RedisCacheConfig struct {
 	Endpoint string `toml:"endpoint"`
 	// Endpoints represents FQDN:port or IPAddress:Port collection of a Redis Cluster or Sentinel Nodes
 	Endpoints []string `toml:"endpoints"`
+	// Username can be set when using a redis instance protected by a Redis 6 ACL user.
+	Username string `toml:"username"`
 	// Password can be set when using password protected redis instance.
 	Password string `toml:"password"`
+	// UseTLS indicates that connections to the redis endpoint(s) should be made over TLS
+	UseTLS bool `toml:"use_tls"`
+	// TLS provides the client TLS options used when UseTLS is true
+	TLS *TLSConfig `toml:"tls"`
+	// StaleTTLMS is how long objects are retained past their TTL so they can be served stale
+	// (e.g., when the origin is unavailable). 0 disables stale retention.
+	StaleTTLMS int `toml:"stale_ttl_ms"`
 	// SentinelMaster should be set when using Redis Sentinel to indicate the Master Node
 	SentinelMaster string `toml:"sentinel_master"`
 	// DB is the Database to be selected after connecting to the server.
@@ -317,6 +436,18 @@ type BBoltCacheConfig struct {
 type FilesystemCacheConfig struct {
 	// CachePath represents the path on disk where our cache will live
 	CachePath string `toml:"cache_path"`
+	// ShardDepth is the number of hashed subdirectory levels under CachePath across which
+	// cache objects are distributed. 0 stores all objects directly in CachePath
+	ShardDepth int `toml:"shard_depth"`
+	// FileModeName is the octal permission mode (e.g., '0644') applied to cache object files
+	FileModeName string `toml:"file_mode"`
+	// DirectoryModeName is the octal permission mode (e.g., '0755') applied to shard directories
+	DirectoryModeName string `toml:"directory_mode"`
+
+	// FileMode is the parsed value of FileModeName
+	FileMode os.FileMode `toml:"-"`
+	// DirectoryMode is the parsed value of DirectoryModeName
+	DirectoryMode os.FileMode `toml:"-"`
 }
 
 // FrontendConfig is a collection of configurations for the main http frontend for the application
@@ -376,8 +507,11 @@ func NewConfig() *TricksterConfig {
 			LogLevel: defaultLogLevel,
 		},
 		Main: &MainConfig{
-			ConfigHandlerPath: defaultConfigHandlerPath,
-			PingHandlerPath:   defaultPingHandlerPath,
+			ConfigHandlerPath:      defaultConfigHandlerPath,
+			PingHandlerPath:        defaultPingHandlerPath,
+			CacheHealthHandlerPath: defaultCacheHealthHandlerPath,
+			CacheHealthTimeoutMS:   defaultCacheHealthTimeoutMS,
+			CacheHealthIntervalMS:  defaultCacheHealthIntervalMS,
 		},
 		Metrics: &MetricsConfig{
 			ListenPort: defaultMetricsListenPort,
@@ -409,9 +543,24 @@ func NewCacheConfig() *CachingConfig {
 		CacheType:   defaultCacheType,
 		CacheTypeID: defaultCacheTypeID,
 		Redis:       RedisCacheConfig{ClientType: defaultRedisClientType, Protocol: defaultRedisProtocol, Endpoint: defaultRedisEndpoint, Endpoints: []string{defaultRedisEndpoint}},
-		Filesystem:  FilesystemCacheConfig{CachePath: defaultCachePath},
-		BBolt:       BBoltCacheConfig{Filename: defaultBBoltFile, Bucket: defaultBBoltBucket},
-		Badger:      BadgerCacheConfig{Directory: defaultCachePath, ValueDirectory: defaultCachePath},
+		Filesystem: FilesystemCacheConfig{
+			CachePath:         defaultCachePath,
+			ShardDepth:        defaultFilesystemShardDepth,
+			FileModeName:      defaultFilesystemFileMode,
+			DirectoryModeName: defaultFilesystemDirectoryMode,
+			FileMode:          defaultFilesystemFileModeValue,
+			DirectoryMode:     defaultFilesystemDirectoryModeValue,
+		},
+		BBolt:  BBoltCacheConfig{Filename: defaultBBoltFile, Bucket: defaultBBoltBucket},
+		Badger: BadgerCacheConfig{Directory: defaultCachePath, ValueDirectory: defaultCachePath},
+		CircuitBreaker: CircuitBreakerConfig{
+			ErrorRateThreshold: defaultBreakerErrorRate,
+			LatencyThresholdMS: defaultBreakerLatencyMS,
+			MinOperations:      defaultBreakerMinOperations,
+			WindowSecs:         defaultBreakerWindowSecs,
+			OpenSecs:           defaultBreakerOpenSecs,
+			HalfOpenSuccesses:  defaultBreakerHalfOpenSuccesses,
+		},
 		Index: CacheIndexConfig{
 			ReapIntervalSecs:      defaultCacheIndexReap,
 			FlushIntervalSecs:     defaultCacheIndexFlush,
@@ -428,6 +577,8 @@ func NewOriginConfig() *OriginConfig {
 	return &OriginConfig{
 		BackfillTolerance:            defaultBackfillToleranceSecs,
 		BackfillToleranceSecs:        defaultBackfillToleranceSecs,
+		ValueRetention:               defaultValueRetentionSecs * time.Second,
+		ValueRetentionSecs:           defaultValueRetentionSecs,
 		CacheKeyPrefix:               "",
 		CacheName:                    defaultOriginCacheName,
 		CompressableTypeList:         defaultCompressableTypes(),
@@ -446,6 +597,7 @@ func NewOriginConfig() *OriginConfig {
 		NegativeCacheName:            defaultOriginNegativeCacheName,
 		Paths:                        make(map[string]*PathConfig),
 		RevalidationFactor:           defaultRevalidationFactor,
+		SQLHTTP:                      NewSQLHTTPConfig(),
 		TLS:                          &TLSConfig{},
 		Timeout:                      time.Second * defaultOriginTimeoutSecs,
 		TimeoutSecs:                  defaultOriginTimeoutSecs,
@@ -487,7 +639,8 @@ func (c *TricksterConfig) setDefaults(metadata *toml.MetaData) error {
 }
 
 var pathMembers = []string{"path", "match_type", "handler", "methods", "cache_key_params", "cache_key_headers", "default_ttl_secs",
-	"request_headers", "response_headers", "response_headers", "response_code", "response_body", "no_metrics", "progressive_collapsed_forwarding"}
+	"request_headers", "response_headers", "response_headers", "response_code", "response_body", "no_metrics", "progressive_collapsed_forwarding",
+	"time_quantum_secs"}
 
 func (c *TricksterConfig) validateConfigMappings() error {
 	for k, oc := range c.Origins {
@@ -546,6 +699,41 @@ func (c *TricksterConfig) processOriginConfigs(metadata *toml.MetaData) {
 			oc.CacheKeyPrefix = v.CacheKeyPrefix
 		}
 
+		if metadata.IsDefined("origins", k, "cache_max_size_bytes") {
+			oc.CacheMaxSizeBytes = v.CacheMaxSizeBytes
+		}
+
+		if metadata.IsDefined("origins", k, "cache_max_size_objects") {
+			oc.CacheMaxSizeObjects = v.CacheMaxSizeObjects
+		}
+
+		if metadata.IsDefined("origins", k, "tenant_header") {
+			oc.TenantHeader = http.CanonicalHeaderKey(v.TenantHeader)
+		}
+
+		if metadata.IsDefined("origins", k, "tenant_header_required") {
+			oc.TenantHeaderRequired = v.TenantHeaderRequired
+		}
+
+		if metadata.IsDefined("origins", k, "tenant_metrics_allowlist") {
+			oc.TenantMetricsAllowList = v.TenantMetricsAllowList
+		}
+
+		if metadata.IsDefined("origins", k, "label_rules") {
+			oc.LabelRules = make(map[string]*LabelRuleConfig, len(v.LabelRules))
+			for l, lr := range v.LabelRules {
+				oc.LabelRules[l] = &LabelRuleConfig{
+					Label:    l,
+					Header:   http.CanonicalHeaderKey(lr.Header),
+					JWTClaim: lr.JWTClaim,
+				}
+			}
+		}
+
+		if metadata.IsDefined("origins", k, "sqlhttp") {
+			oc.SQLHTTP.processSQLHTTPConfig(metadata, k, v.SQLHTTP)
+		}
+
 		if metadata.IsDefined("origins", k, "origin_url") {
 			oc.OriginURL = v.OriginURL
 		}
@@ -597,6 +785,10 @@ func (c *TricksterConfig) processOriginConfigs(metadata *toml.MetaData) {
 			oc.BackfillToleranceSecs = v.BackfillToleranceSecs
 		}
 
+		if metadata.IsDefined("origins", k, "value_retention_secs") {
+			oc.ValueRetentionSecs = v.ValueRetentionSecs
+		}
+
 		if metadata.IsDefined("origins", k, "paths") {
 			var j = 0
 			for l, p := range v.Paths {
@@ -613,6 +805,7 @@ func (c *TricksterConfig) processOriginConfigs(metadata *toml.MetaData) {
 					p.ResponseBodyBytes = []byte(p.ResponseBody)
 					p.HasCustomResponseBody = true
 				}
+				p.TimeQuantum = time.Duration(p.TimeQuantumSecs) * time.Second
 
 				if mt, ok := pathMatchTypeNames[strings.ToLower(p.MatchTypeName)]; ok {
 					p.MatchType = mt
@@ -727,6 +920,34 @@ func (c *TricksterConfig) processCachingConfigs(metadata *toml.MetaData) {
 			cc.Index.MaxSizeBackoffObjects = v.Index.MaxSizeBackoffObjects
 		}
 
+		if metadata.IsDefined("caches", k, "circuit_breaker", "enabled") {
+			cc.CircuitBreaker.Enabled = v.CircuitBreaker.Enabled
+		}
+
+		if metadata.IsDefined("caches", k, "circuit_breaker", "error_rate_threshold") {
+			cc.CircuitBreaker.ErrorRateThreshold = v.CircuitBreaker.ErrorRateThreshold
+		}
+
+		if metadata.IsDefined("caches", k, "circuit_breaker", "latency_threshold_ms") {
+			cc.CircuitBreaker.LatencyThresholdMS = v.CircuitBreaker.LatencyThresholdMS
+		}
+
+		if metadata.IsDefined("caches", k, "circuit_breaker", "min_operations") {
+			cc.CircuitBreaker.MinOperations = v.CircuitBreaker.MinOperations
+		}
+
+		if metadata.IsDefined("caches", k, "circuit_breaker", "window_secs") {
+			cc.CircuitBreaker.WindowSecs = v.CircuitBreaker.WindowSecs
+		}
+
+		if metadata.IsDefined("caches", k, "circuit_breaker", "open_secs") {
+			cc.CircuitBreaker.OpenSecs = v.CircuitBreaker.OpenSecs
+		}
+
+		if metadata.IsDefined("caches", k, "circuit_breaker", "half_open_successes") {
+			cc.CircuitBreaker.HalfOpenSuccesses = v.CircuitBreaker.HalfOpenSuccesses
+		}
+
 		if cc.CacheTypeID == CacheTypeRedis {
 
 			var hasEndpoint, hasEndpoints bool
@@ -764,10 +985,31 @@ func (c *TricksterConfig) processCachingConfigs(metadata *toml.MetaData) {
 				cc.Redis.SentinelMaster = v.Redis.SentinelMaster
 			}
 
+			if metadata.IsDefined("caches", k, "redis", "username") {
+				cc.Redis.Username = v.Redis.Username
+			}
+
 			if metadata.IsDefined("caches", k, "redis", "password") {
 				cc.Redis.Password = v.Redis.Password
 			}
 
+			if metadata.IsDefined("caches", k, "redis", "use_tls") {
+				cc.Redis.UseTLS = v.Redis.UseTLS
+			}
+
+			if metadata.IsDefined("caches", k, "redis", "tls") {
+				cc.Redis.TLS = &TLSConfig{
+					InsecureSkipVerify:        v.Redis.TLS.InsecureSkipVerify,
+					CertificateAuthorityPaths: v.Redis.TLS.CertificateAuthorityPaths,
+					ClientCertPath:            v.Redis.TLS.ClientCertPath,
+					ClientKeyPath:             v.Redis.TLS.ClientKeyPath,
+				}
+			}
+
+			if metadata.IsDefined("caches", k, "redis", "stale_ttl_ms") {
+				cc.Redis.StaleTTLMS = v.Redis.StaleTTLMS
+			}
+
 			if metadata.IsDefined("caches", k, "redis", "db") {
 				cc.Redis.DB = v.Redis.DB
 			}
@@ -825,6 +1067,18 @@ func (c *TricksterConfig) processCachingConfigs(metadata *toml.MetaData) {
 			cc.Filesystem.CachePath = v.Filesystem.CachePath
 		}
 
+		if metadata.IsDefined("caches", k, "filesystem", "shard_depth") {
+			cc.Filesystem.ShardDepth = v.Filesystem.ShardDepth
+		}
+
+		if metadata.IsDefined("caches", k, "filesystem", "file_mode") {
+			cc.Filesystem.FileModeName = v.Filesystem.FileModeName
+		}
+
+		if metadata.IsDefined("caches", k, "filesystem", "directory_mode") {
+			cc.Filesystem.DirectoryModeName = v.Filesystem.DirectoryModeName
+		}
+
 		if metadata.IsDefined("caches", k, "bbolt", "filename") {
 			cc.BBolt.Filename = v.BBolt.Filename
 		}
@@ -854,6 +1108,9 @@ func (c *TricksterConfig) copy() *TricksterConfig {
 	nc.Main.ConfigHandlerPath = c.Main.ConfigHandlerPath
 	nc.Main.InstanceID = c.Main.InstanceID
 	nc.Main.PingHandlerPath = c.Main.PingHandlerPath
+	nc.Main.CacheHealthHandlerPath = c.Main.CacheHealthHandlerPath
+	nc.Main.CacheHealthTimeoutMS = c.Main.CacheHealthTimeoutMS
+	nc.Main.CacheHealthIntervalMS = c.Main.CacheHealthIntervalMS
 
 	nc.Logging.LogFile = c.Logging.LogFile
 	nc.Logging.LogLevel = c.Logging.LogLevel
@@ -944,8 +1201,29 @@ func (oc *OriginConfig) Clone() *OriginConfig {
 	o.DearticulateUpstreamRanges = oc.DearticulateUpstreamRanges
 	o.BackfillTolerance = oc.BackfillTolerance
 	o.BackfillToleranceSecs = oc.BackfillToleranceSecs
+	o.ValueRetentionSecs = oc.ValueRetentionSecs
 	o.CacheName = oc.CacheName
 	o.CacheKeyPrefix = oc.CacheKeyPrefix
+	o.CacheMaxSizeBytes = oc.CacheMaxSizeBytes
+	o.CacheMaxSizeObjects = oc.CacheMaxSizeObjects
+	o.TenantHeader = oc.TenantHeader
+	o.TenantHeaderRequired = oc.TenantHeaderRequired
+	if oc.TenantMetricsAllowList != nil {
+		o.TenantMetricsAllowList = make([]string, len(oc.TenantMetricsAllowList))
+		copy(o.TenantMetricsAllowList, oc.TenantMetricsAllowList)
+	}
+	if oc.TenantMetricsAllowed != nil {
+		o.TenantMetricsAllowed = make(map[string]bool, len(oc.TenantMetricsAllowed))
+		for k := range oc.TenantMetricsAllowed {
+			o.TenantMetricsAllowed[k] = true
+		}
+	}
+	if oc.LabelRules != nil {
+		o.LabelRules = make(map[string]*LabelRuleConfig, len(oc.LabelRules))
+		for l, lr := range oc.LabelRules {
+			o.LabelRules[l] = &LabelRuleConfig{Label: lr.Label, Header: lr.Header, JWTClaim: lr.JWTClaim}
+		}
+	}
 	o.FastForwardDisable = oc.FastForwardDisable
 	o.FastForwardTTL = oc.FastForwardTTL
 	o.FastForwardTTLSecs = oc.FastForwardTTLSecs
@@ -1022,6 +1300,10 @@ func (oc *OriginConfig) Clone() *OriginConfig {
 		o.NegativeCache = m
 	}
 
+	if oc.SQLHTTP != nil {
+		o.SQLHTTP = oc.SQLHTTP.Clone()
+	}
+
 	if oc.TLS != nil {
 		o.TLS = oc.TLS.Clone()
 	}
@@ -1051,11 +1333,25 @@ func (cc *CachingConfig) Clone() *CachingConfig {
 	c.Index.MaxSizeObjects = cc.Index.MaxSizeObjects
 	c.Index.ReapInterval = cc.Index.ReapInterval
 	c.Index.ReapIntervalSecs = cc.Index.ReapIntervalSecs
+	if cc.Index.OriginQuotas != nil {
+		c.Index.OriginQuotas = make(map[string]*OriginCacheQuota)
+		for k, v := range cc.Index.OriginQuotas {
+			q := *v
+			c.Index.OriginQuotas[k] = &q
+		}
+	}
 
 	c.Badger.Directory = cc.Badger.Directory
 	c.Badger.ValueDirectory = cc.Badger.ValueDirectory
 
+	c.CircuitBreaker = cc.CircuitBreaker
+
 	c.Filesystem.CachePath = cc.Filesystem.CachePath
+	c.Filesystem.ShardDepth = cc.Filesystem.ShardDepth
+	c.Filesystem.FileModeName = cc.Filesystem.FileModeName
+	c.Filesystem.DirectoryModeName = cc.Filesystem.DirectoryModeName
+	c.Filesystem.FileMode = cc.Filesystem.FileMode
+	c.Filesystem.DirectoryMode = cc.Filesystem.DirectoryMode
 
 	c.BBolt.Bucket = cc.BBolt.Bucket
 	c.BBolt.Filename = cc.BBolt.Filename
@@ -1079,6 +1375,12 @@ func (cc *CachingConfig) Clone() *CachingConfig {
 	c.Redis.ReadTimeoutMS = cc.Redis.ReadTimeoutMS
 	c.Redis.SentinelMaster = cc.Redis.SentinelMaster
 	c.Redis.WriteTimeoutMS = cc.Redis.WriteTimeoutMS
+	c.Redis.Username = cc.Redis.Username
+	c.Redis.UseTLS = cc.Redis.UseTLS
+	c.Redis.StaleTTLMS = cc.Redis.StaleTTLMS
+	if cc.Redis.TLS != nil {
+		c.Redis.TLS = cc.Redis.TLS.Clone()
+	}
 
 	return c
 
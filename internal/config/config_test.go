@@ -16,6 +16,7 @@ package config
 import (
 	"errors"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -36,6 +37,8 @@ func TestCopy(t *testing.T) {
 	oc.FastForwardPath = NewPathConfig()
 	oc.TLS = &TLSConfig{CertificateAuthorityPaths: []string{"foo"}}
 	oc.HealthCheckHeaders = map[string]string{headers.NameAuthorization: "Basic SomeHash"}
+	oc.TenantMetricsAllowList = []string{"tenant-a"}
+	oc.TenantMetricsAllowed = map[string]bool{"tenant-a": true}
 
 	c2 := c1.copy()
 	if !reflect.DeepEqual(c1, c2) {
@@ -90,3 +93,41 @@ func TestCloneOriginConfig(t *testing.T) {
 	}
 
 }
+
+func TestCircuitBreakerConfigValidate(t *testing.T) {
+
+	tests := []struct {
+		mod      func(*CircuitBreakerConfig)
+		expected string
+	}{
+		{func(cb *CircuitBreakerConfig) {}, ""},
+		{func(cb *CircuitBreakerConfig) { cb.ErrorRateThreshold = 1 }, ""},
+		{func(cb *CircuitBreakerConfig) { cb.LatencyThresholdMS = 0 }, ""},
+		{func(cb *CircuitBreakerConfig) { cb.ErrorRateThreshold = 0 },
+			"error_rate_threshold must be greater than 0 and at most 1, got 0"},
+		{func(cb *CircuitBreakerConfig) { cb.ErrorRateThreshold = -0.5 },
+			"error_rate_threshold must be greater than 0 and at most 1, got -0.5"},
+		{func(cb *CircuitBreakerConfig) { cb.ErrorRateThreshold = 1.5 },
+			"error_rate_threshold must be greater than 0 and at most 1, got 1.5"},
+		{func(cb *CircuitBreakerConfig) { cb.LatencyThresholdMS = -1 },
+			"latency_threshold_ms must not be negative, got -1"},
+		{func(cb *CircuitBreakerConfig) { cb.MinOperations = 0 }, "min_operations must be greater than 0, got 0"},
+		{func(cb *CircuitBreakerConfig) { cb.WindowSecs = -1 }, "window_secs must be greater than 0, got -1"},
+		{func(cb *CircuitBreakerConfig) { cb.OpenSecs = 0 }, "open_secs must be greater than 0, got 0"},
+		{func(cb *CircuitBreakerConfig) { cb.HalfOpenSuccesses = 0 }, "half_open_successes must be greater than 0, got 0"},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			cb := NewCacheConfig().CircuitBreaker
+			test.mod(&cb)
+			err := cb.validate()
+			if test.expected == "" && err != nil {
+				t.Error(err)
+			} else if test.expected != "" && (err == nil || err.Error() != test.expected) {
+				t.Errorf("expected error `%s` got `%v`", test.expected, err)
+			}
+		})
+	}
+
+}
@@ -36,6 +36,12 @@ const (
 
 	defaultCachePath = "/tmp/trickster"
 
+	defaultFilesystemShardDepth         = 2
+	defaultFilesystemFileMode           = "0644"
+	defaultFilesystemDirectoryMode      = "0755"
+	defaultFilesystemFileModeValue      = 0644
+	defaultFilesystemDirectoryModeValue = 0755
+
 	defaultRedisClientType = "standard"
 	defaultRedisProtocol   = "tcp"
 	defaultRedisEndpoint   = "redis:6379"
@@ -51,6 +57,13 @@ const (
 	defaultMaxSizeBackoffObjects = 100
 	defaultMaxObjectSizeBytes    = 524288
 
+	defaultBreakerErrorRate         = 0.5
+	defaultBreakerLatencyMS         = 500
+	defaultBreakerMinOperations     = 20
+	defaultBreakerWindowSecs        = 10
+	defaultBreakerOpenSecs          = 15
+	defaultBreakerHalfOpenSuccesses = 3
+
 	defaultOriginTRF               = 1024
 	defaultOriginTEM               = EvictionMethodOldest
 	defaultOriginTEMName           = "oldest"
@@ -59,15 +72,26 @@ const (
 	defaultOriginNegativeCacheName = "default"
 	defaultTracingConfigName       = "default"
 	defaultBackfillToleranceSecs   = 0
+	defaultValueRetentionSecs      = 86400
 	defaultKeepAliveTimeoutSecs    = 300
 	defaultMaxIdleConns            = 20
 
+	defaultSQLHTTPQueryParam     = "query"
+	defaultSQLHTTPTimeFormat     = "rfc3339"
+	defaultSQLHTTPResponseFormat = "json"
+	defaultSQLHTTPRowsField      = "rows"
+	defaultSQLHTTPColumnsField   = "columns"
+
 	defaultHealthCheckPath  = "-"
 	defaultHealthCheckQuery = "-"
 	defaultHealthCheckVerb  = "-"
 
 	defaultConfigHandlerPath = "/trickster/config"
 	defaultPingHandlerPath   = "/trickster/ping"
+
+	defaultCacheHealthHandlerPath = "/trickster/health/caches"
+	defaultCacheHealthTimeoutMS   = 1000
+	defaultCacheHealthIntervalMS  = 30000
 )
 
 func defaultCompressableTypes() []string {
@@ -16,6 +16,8 @@ package config
 import (
 	"fmt"
 	"net/url"
+	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -109,6 +111,22 @@ func Load(applicationName string, applicationVersion string, arguments []string)
 			return fmt.Errorf(`missing origin-type for origin "%s"`, k)
 		}
 
+		if o.TenantHeaderRequired && o.TenantHeader == "" {
+			return fmt.Errorf(`tenant_header_required is set without a tenant_header for origin "%s"`, k)
+		}
+
+		for l, lr := range o.LabelRules {
+			if (lr.Header == "") == (lr.JWTClaim == "") {
+				return fmt.Errorf(`label rule "%s" for origin "%s" must set exactly one of header or jwt_claim`, l, k)
+			}
+		}
+
+		if o.OriginType == "sqlhttp" {
+			if err := o.SQLHTTP.compile(); err != nil {
+				return fmt.Errorf(`invalid sqlhttp config for origin "%s": %s`, k, err.Error())
+			}
+		}
+
 		if strings.HasSuffix(url.Path, "/") {
 			url.Path = url.Path[0 : len(url.Path)-1]
 		}
@@ -119,6 +137,7 @@ func Load(applicationName string, applicationVersion string, arguments []string)
 		o.PathPrefix = url.Path
 		o.Timeout = time.Duration(o.TimeoutSecs) * time.Second
 		o.BackfillTolerance = time.Duration(o.BackfillToleranceSecs) * time.Second
+		o.ValueRetention = time.Duration(o.ValueRetentionSecs) * time.Second
 		o.TimeseriesRetention = time.Duration(o.TimeseriesRetentionFactor)
 		o.TimeseriesTTL = time.Duration(o.TimeseriesTTLSecs) * time.Second
 		o.FastForwardTTL = time.Duration(o.FastForwardTTLSecs) * time.Second
@@ -131,6 +150,13 @@ func Load(applicationName string, applicationVersion string, arguments []string)
 			}
 		}
 
+		if o.TenantMetricsAllowList != nil {
+			o.TenantMetricsAllowed = make(map[string]bool)
+			for _, v := range o.TenantMetricsAllowList {
+				o.TenantMetricsAllowed[v] = true
+			}
+		}
+
 		if o.CacheKeyPrefix == "" {
 			o.CacheKeyPrefix = o.Host
 		}
@@ -168,9 +194,61 @@ func Load(applicationName string, applicationVersion string, arguments []string)
 		Origins[k] = o
 	}
 
-	for _, c := range Caches {
+	for k, c := range Caches {
 		c.Index.FlushInterval = time.Duration(c.Index.FlushIntervalSecs) * time.Second
 		c.Index.ReapInterval = time.Duration(c.Index.ReapIntervalSecs) * time.Second
+		c.CircuitBreaker.LatencyThreshold = time.Duration(c.CircuitBreaker.LatencyThresholdMS) * time.Millisecond
+		c.CircuitBreaker.Window = time.Duration(c.CircuitBreaker.WindowSecs) * time.Second
+		c.CircuitBreaker.OpenDuration = time.Duration(c.CircuitBreaker.OpenSecs) * time.Second
+
+		if err := c.CircuitBreaker.validate(); err != nil {
+			return fmt.Errorf(`invalid circuit_breaker config for cache "%s": %s`, k, err)
+		}
+
+		if c.Filesystem.ShardDepth < 0 {
+			return fmt.Errorf(`invalid shard_depth %d for cache "%s"`, c.Filesystem.ShardDepth, k)
+		}
+		m, err := strconv.ParseUint(c.Filesystem.FileModeName, 8, 32)
+		if err != nil {
+			return fmt.Errorf(`invalid file_mode "%s" for cache "%s"`, c.Filesystem.FileModeName, k)
+		}
+		c.Filesystem.FileMode = os.FileMode(m)
+		m, err = strconv.ParseUint(c.Filesystem.DirectoryModeName, 8, 32)
+		if err != nil {
+			return fmt.Errorf(`invalid directory_mode "%s" for cache "%s"`, c.Filesystem.DirectoryModeName, k)
+		}
+		c.Filesystem.DirectoryMode = os.FileMode(m)
+		c.Index.OriginQuotas = make(map[string]*OriginCacheQuota)
+	}
+
+	// iterate the origins in name order, so the quota of origins sharing a prefix is deterministic
+	names := make([]string, 0, len(Origins))
+	for k := range Origins {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		o := Origins[k]
+		c, ok := Caches[o.CacheName]
+		if !ok {
+			continue
+		}
+		q := &OriginCacheQuota{
+			OriginName:     k,
+			MaxSizeBytes:   o.CacheMaxSizeBytes,
+			MaxSizeObjects: o.CacheMaxSizeObjects,
+			PerTenant:      o.TenantHeader != "",
+		}
+		// usage is tracked by cache key prefix, so origins sharing a prefix in the same cache
+		// would share (and overwrite) each other's quota
+		if eq, ok := c.Index.OriginQuotas[o.CacheKeyPrefix]; ok {
+			if eq.hasLimits() || q.hasLimits() {
+				return fmt.Errorf(`origins "%s" and "%s" share cache_key_prefix "%s" in cache "%s", which is not supported with cache quotas or a tenant_header`,
+					eq.OriginName, k, o.CacheKeyPrefix, o.CacheName)
+			}
+			continue
+		}
+		c.Index.OriginQuotas[o.CacheKeyPrefix] = q
 	}
 
 	return nil
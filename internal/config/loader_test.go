@@ -76,6 +76,26 @@ func TestLoadConfigurationFileFailures(t *testing.T) {
 			"../../testdata/test.invalid-negative-cache-3.conf",
 			`invalid negative cache name: foo`,
 		},
+		{ // Case 7
+			"../../testdata/test.tenant-header-required.conf",
+			`tenant_header_required is set without a tenant_header for origin "test"`,
+		},
+		{ // Case 8
+			"../../testdata/test.invalid-label-rule.conf",
+			`label rule "namespace" for origin "test" must set exactly one of header or jwt_claim`,
+		},
+		{ // Case 9
+			"../../testdata/test.invalid-sqlhttp.conf",
+			`invalid sqlhttp config for origin "test": step_pattern is missing the named group 'step'`,
+		},
+		{ // Case 10
+			"../../testdata/test.invalid-circuit-breaker.conf",
+			`invalid circuit_breaker config for cache "default": error_rate_threshold must be greater than 0 and at most 1, got 0`,
+		},
+		{ // Case 11
+			"../../testdata/test.duplicate-quota-prefix.conf",
+			`origins "test1" and "test2" share cache_key_prefix "prometheus:9090" in cache "default", which is not supported with cache quotas or a tenant_header`,
+		},
 	}
 
 	for i, test := range tests {
@@ -201,6 +221,10 @@ func TestFullLoadConfiguration(t *testing.T) {
 		t.Errorf("expected 301, got %d", o.BackfillToleranceSecs)
 	}
 
+	if o.ValueRetention != time.Hour {
+		t.Errorf("expected %s, got %s", time.Hour, o.ValueRetention)
+	}
+
 	if o.TimeoutSecs != 37 {
 		t.Errorf("expected 37, got %d", o.TimeoutSecs)
 	}
@@ -217,6 +241,43 @@ func TestFullLoadConfiguration(t *testing.T) {
 		t.Errorf("expected %d got %d", 7, o.KeepAliveTimeoutSecs)
 	}
 
+	if o.CacheMaxSizeBytes != 1048576 {
+		t.Errorf("expected %d got %d", 1048576, o.CacheMaxSizeBytes)
+	}
+
+	if o.CacheMaxSizeObjects != 50 {
+		t.Errorf("expected %d got %d", 50, o.CacheMaxSizeObjects)
+	}
+
+	// the tenant header name is canonicalized
+	if o.TenantHeader != "X-Scope-Orgid" {
+		t.Errorf("expected %s got %s", "X-Scope-Orgid", o.TenantHeader)
+	}
+
+	if !o.TenantHeaderRequired {
+		t.Errorf("expected %t got %t", true, o.TenantHeaderRequired)
+	}
+
+	if len(o.TenantMetricsAllowList) != 2 || !o.TenantMetricsAllowed["tenant-a"] || !o.TenantMetricsAllowed["tenant-b"] {
+		t.Errorf("unexpected tenant metrics allowlist %v", o.TenantMetricsAllowed)
+	}
+
+	if lr, ok := o.LabelRules["namespace"]; !ok || lr.Label != "namespace" || lr.Header != "X-Namespace" {
+		t.Errorf("unexpected label rule %v", lr)
+	}
+
+	if lr, ok := o.LabelRules["team"]; !ok || lr.JWTClaim != "team" || lr.Header != "" {
+		t.Errorf("unexpected label rule %v", lr)
+	}
+
+	// sqlhttp settings that are not defined retain their defaults
+	if o.SQLHTTP.QueryParam != "query" || o.SQLHTTP.QueryBodyField != "stmt" ||
+		o.SQLHTTP.TimeFilterFormat != "epoch_ms" || !o.SQLHTTP.TimeFilterEndExclusive ||
+		o.SQLHTTP.TimestampColumn != "ts" || o.SQLHTTP.TimestampFormat != "rfc3339" ||
+		o.SQLHTTP.ResponseFormat != "csv" || o.SQLHTTP.RowsField != "rows" {
+		t.Errorf("unexpected sqlhttp config %v", o.SQLHTTP)
+	}
+
 	// MaxTTLSecs is 300, thus should override TimeseriesTTLSecs = 8666
 	if o.TimeseriesTTLSecs != 300 {
 		t.Errorf("expected 300, got %d", o.TimeseriesTTLSecs)
@@ -227,6 +288,12 @@ func TestFullLoadConfiguration(t *testing.T) {
 		t.Errorf("expected 300, got %d", o.FastForwardTTLSecs)
 	}
 
+	if p, ok := o.Paths["/series-GET-HEAD"]; !ok {
+		t.Errorf("expected path config for %s", "/series")
+	} else if p.TimeQuantum != 30*time.Second {
+		t.Errorf("expected %s got %s", 30*time.Second, p.TimeQuantum)
+	}
+
 	if o.TLS == nil {
 		t.Errorf("expected tls config for origin %s, got nil", "test")
 	}
@@ -267,6 +334,13 @@ func TestFullLoadConfiguration(t *testing.T) {
 		t.Errorf("expected 4, got %d", c.Index.ReapIntervalSecs)
 	}
 
+	q, ok := c.Index.OriginQuotas["test-prefix"]
+	if !ok {
+		t.Errorf("unable to find origin quota: %s", "test-prefix")
+	} else if q.OriginName != "test" || q.MaxSizeBytes != 1048576 || q.MaxSizeObjects != 50 || !q.PerTenant {
+		t.Errorf("unexpected origin quota: %v", q)
+	}
+
 	if c.Index.FlushIntervalSecs != 6 {
 		t.Errorf("expected 6, got %d", c.Index.FlushIntervalSecs)
 	}
@@ -315,6 +389,22 @@ func TestFullLoadConfiguration(t *testing.T) {
 		t.Errorf("expected 42, got %d", c.Redis.DB)
 	}
 
+	if c.Redis.Username != "test_username" {
+		t.Errorf("expected test_username, got %s", c.Redis.Username)
+	}
+
+	if !c.Redis.UseTLS {
+		t.Errorf("expected true got %t", c.Redis.UseTLS)
+	}
+
+	if c.Redis.TLS == nil || !c.Redis.TLS.InsecureSkipVerify {
+		t.Errorf("expected redis tls insecure_skip_verify to be true")
+	}
+
+	if c.Redis.StaleTTLMS != 30000 {
+		t.Errorf("expected 30000, got %d", c.Redis.StaleTTLMS)
+	}
+
 	if c.Redis.MaxRetries != 6 {
 		t.Errorf("expected 6, got %d", c.Redis.MaxRetries)
 	}
@@ -367,6 +457,46 @@ func TestFullLoadConfiguration(t *testing.T) {
 		t.Errorf("expected test_cache_path, got %s", c.Filesystem.CachePath)
 	}
 
+	if !c.CircuitBreaker.Enabled {
+		t.Errorf("expected true got %t", c.CircuitBreaker.Enabled)
+	}
+
+	if c.CircuitBreaker.ErrorRateThreshold != 0.25 {
+		t.Errorf("expected 0.25, got %f", c.CircuitBreaker.ErrorRateThreshold)
+	}
+
+	if c.CircuitBreaker.LatencyThreshold != 250*time.Millisecond {
+		t.Errorf("expected 250ms, got %s", c.CircuitBreaker.LatencyThreshold)
+	}
+
+	if c.CircuitBreaker.MinOperations != 10 {
+		t.Errorf("expected 10, got %d", c.CircuitBreaker.MinOperations)
+	}
+
+	if c.CircuitBreaker.Window != 30*time.Second {
+		t.Errorf("expected 30s, got %s", c.CircuitBreaker.Window)
+	}
+
+	if c.CircuitBreaker.OpenDuration != 60*time.Second {
+		t.Errorf("expected 60s, got %s", c.CircuitBreaker.OpenDuration)
+	}
+
+	if c.CircuitBreaker.HalfOpenSuccesses != 5 {
+		t.Errorf("expected 5, got %d", c.CircuitBreaker.HalfOpenSuccesses)
+	}
+
+	if c.Filesystem.ShardDepth != 3 {
+		t.Errorf("expected 3, got %d", c.Filesystem.ShardDepth)
+	}
+
+	if c.Filesystem.FileMode != 0600 {
+		t.Errorf("expected 0600, got %o", c.Filesystem.FileMode)
+	}
+
+	if c.Filesystem.DirectoryMode != 0700 {
+		t.Errorf("expected 0700, got %o", c.Filesystem.DirectoryMode)
+	}
+
 	if c.BBolt.Filename != "test_filename" {
 		t.Errorf("expected test_filename, got %s", c.BBolt.Filename)
 	}
@@ -591,6 +721,14 @@ func TestEmptyLoadConfiguration(t *testing.T) {
 		t.Errorf("expected /tmp/trickster, got %s", c.Filesystem.CachePath)
 	}
 
+	if c.Filesystem.ShardDepth != 2 {
+		t.Errorf("expected 2, got %d", c.Filesystem.ShardDepth)
+	}
+
+	if c.Filesystem.FileMode != 0644 {
+		t.Errorf("expected 0644, got %o", c.Filesystem.FileMode)
+	}
+
 	if c.BBolt.Filename != "trickster.db" {
 		t.Errorf("expected trickster.db, got %s", c.BBolt.Filename)
 	}
@@ -29,23 +29,48 @@ const (
 	OriginTypeIronDB
 	// OriginTypeClickHouse represents the ClickHouse origin type
 	OriginTypeClickHouse
+	// OriginTypeGraphite represents the Graphite origin type
+	OriginTypeGraphite
+	// OriginTypeLoki represents the Loki origin type
+	OriginTypeLoki
+	// OriginTypeElasticsearch represents the Elasticsearch origin type
+	OriginTypeElasticsearch
+	// OriginTypeOpenTSDB represents the OpenTSDB origin type
+	OriginTypeOpenTSDB
+	// OriginTypeDruid represents the Apache Druid origin type
+	OriginTypeDruid
+	// OriginTypeSQLHTTP represents the generic SQL-over-HTTP origin type
+	OriginTypeSQLHTTP
 )
 
 var originTypeNames = map[string]OriginType{
 	"rpc":               OriginTypeRPC,
 	"reverseproxycache": OriginTypeRPC,
 	"prometheus":        OriginTypePrometheus,
+	"victoriametrics":   OriginTypePrometheus,
 	"influxdb":          OriginTypeInfluxDB,
 	"irondb":            OriginTypeIronDB,
 	"clickhouse":        OriginTypeClickHouse,
+	"graphite":          OriginTypeGraphite,
+	"loki":              OriginTypeLoki,
+	"elasticsearch":     OriginTypeElasticsearch,
+	"opentsdb":          OriginTypeOpenTSDB,
+	"druid":             OriginTypeDruid,
+	"sqlhttp":           OriginTypeSQLHTTP,
 }
 
 var originTypeValues = map[OriginType]string{
-	OriginTypeRPC:        "rpc",
-	OriginTypePrometheus: "prometheus",
-	OriginTypeInfluxDB:   "influxdb",
-	OriginTypeIronDB:     "irondb",
-	OriginTypeClickHouse: "clickhouse",
+	OriginTypeRPC:           "rpc",
+	OriginTypePrometheus:    "prometheus",
+	OriginTypeInfluxDB:      "influxdb",
+	OriginTypeIronDB:        "irondb",
+	OriginTypeClickHouse:    "clickhouse",
+	OriginTypeGraphite:      "graphite",
+	OriginTypeLoki:          "loki",
+	OriginTypeElasticsearch: "elasticsearch",
+	OriginTypeOpenTSDB:      "opentsdb",
+	OriginTypeDruid:         "druid",
+	OriginTypeSQLHTTP:       "sqlhttp",
 }
 
 func (t OriginType) String() string {
@@ -46,10 +46,17 @@ func TestIsValidOriginType(t *testing.T) {
 	}{
 		{"rpc", true},
 		{"prometheus", true},
+		{"victoriametrics", true},
 		{"", false},
 		{"invalid", false},
 		{"influxdb", true},
 		{"irondb", true},
+		{"graphite", true},
+		{"loki", true},
+		{"elasticsearch", true},
+		{"opentsdb", true},
+		{"druid", true},
+		{"sqlhttp", true},
 	}
 
 	for i, test := range tests {
@@ -18,6 +18,7 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/Comcast/trickster/internal/proxy/methods"
 	ts "github.com/Comcast/trickster/internal/util/strings"
@@ -83,6 +84,10 @@ type PathConfig struct {
 	NoMetrics bool `toml:"no_metrics"`
 	// CollapsedForwardingName indicates 'basic' or 'progressive' Collapsed Forwarding to be used by this path.
 	CollapsedForwardingName string `toml:"collapsed_forwarding"`
+	// TimeQuantumSecs, when > 0, rounds the time parameters of instantaneous queries and metadata
+	// requests down to a multiple of this many seconds. Instantaneous query results are cached
+	// for the same duration
+	TimeQuantumSecs int `toml:"time_quantum_secs"`
 
 	// Synthesized PathConfig Values
 	//
@@ -97,6 +102,8 @@ type PathConfig struct {
 	MatchType PathMatchType `toml:"-"`
 	// CollapsedForwardingType is the typed representation of CollapsedForwardingName
 	CollapsedForwardingType CollapsedForwardingType `toml:"-"`
+	// TimeQuantum is the time.Duration representation of TimeQuantumSecs
+	TimeQuantum time.Duration `toml:"-"`
 	// OriginConfig is the reference to the PathConfig's parent Origin Config
 	OriginConfig *OriginConfig `toml:"-"`
 	// KeyHasher points to an optional function that hashes the cacheKey with a custom algorithm
@@ -145,6 +152,8 @@ func (p *PathConfig) Clone() *PathConfig {
 		CollapsedForwardingName: p.CollapsedForwardingName,
 		CollapsedForwardingType: p.CollapsedForwardingType,
 		NoMetrics:               p.NoMetrics,
+		TimeQuantumSecs:         p.TimeQuantumSecs,
+		TimeQuantum:             p.TimeQuantum,
 		HasCustomResponseBody:   p.HasCustomResponseBody,
 		Methods:                 make([]string, len(p.Methods)),
 		CacheKeyParams:          make([]string, len(p.CacheKeyParams)),
@@ -204,6 +213,9 @@ func (p *PathConfig) Merge(p2 *PathConfig) {
 		case "collapsed_forwarding":
 			p.CollapsedForwardingName = p2.CollapsedForwardingName
 			p.CollapsedForwardingType = p2.CollapsedForwardingType
+		case "time_quantum_secs":
+			p.TimeQuantumSecs = p2.TimeQuantumSecs
+			p.TimeQuantum = p2.TimeQuantum
 		}
 	}
 }
@@ -16,6 +16,7 @@ package config
 import (
 	"net/http"
 	"testing"
+	"time"
 )
 
 func TestPMTString(t *testing.T) {
@@ -76,7 +77,8 @@ func TestPathMerge(t *testing.T) {
 	pc2.OriginConfig = NewOriginConfig()
 
 	pc2.custom = []string{"path", "match_type", "handler", "methods", "cache_key_params", "cache_key_headers", "cache_key_form_fields",
-		"request_headers", "request_params", "response_headers", "response_code", "response_body", "no_metrics", "collapsed_forwarding"}
+		"request_headers", "request_params", "response_headers", "response_code", "response_body", "no_metrics", "collapsed_forwarding",
+		"time_quantum_secs"}
 
 	expectedPath := "testPath"
 	expectedHandlerName := "testHandler"
@@ -96,6 +98,8 @@ func TestPathMerge(t *testing.T) {
 	pc2.NoMetrics = true
 	pc2.CollapsedForwardingName = "progressive"
 	pc2.CollapsedForwardingType = CFTypeProgressive
+	pc2.TimeQuantumSecs = 30
+	pc2.TimeQuantum = 30 * time.Second
 
 	pc.Merge(pc2)
 
@@ -159,4 +163,8 @@ func TestPathMerge(t *testing.T) {
 		t.Errorf("expected %s got %s", "progressive", pc.CollapsedForwardingName)
 	}
 
+	if pc.TimeQuantumSecs != 30 || pc.TimeQuantum != 30*time.Second {
+		t.Errorf("expected %d got %d", 30, pc.TimeQuantumSecs)
+	}
+
 }
@@ -0,0 +1,181 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/BurntSushi/toml"
+)
+
+// SQLHTTPConfig describes how the statements and results of a 'sqlhttp' origin are interpreted,
+// so that the time series queries it serves can be accelerated by the Delta Proxy Cache
+type SQLHTTPConfig struct {
+	// QueryParam is the URL query parameter or form field that carries the SQL statement
+	QueryParam string `toml:"query_param"`
+	// QueryBodyField is the field of a JSON request body that carries the SQL statement,
+	// for origins that accept JSON-encoded POST requests
+	QueryBodyField string `toml:"query_body_field"`
+	// TimeFilterPattern is a regular expression matching the time range filter of a statement.
+	// Its 'start' and 'end' named groups capture the bounds of the time range
+	TimeFilterPattern string `toml:"time_filter_pattern"`
+	// TimeFilterFormat is the format of the time range bounds: 'rfc3339', 'epoch_s', 'epoch_ms',
+	// 'epoch_us', 'epoch_ns' or a Go time layout
+	TimeFilterFormat string `toml:"time_filter_format"`
+	// TimeFilterEndExclusive indicates the end of the time range filter is exclusive, as with ts < 'end'
+	TimeFilterEndExclusive bool `toml:"time_filter_end_exclusive"`
+	// StepPattern is a regular expression matching the bucket size of a statement. Its 'step'
+	// named group captures a duration, such as 30s or 5m, or a number of seconds
+	StepPattern string `toml:"step_pattern"`
+	// TimestampColumn is the name of the result column that holds the timestamp of each row
+	TimestampColumn string `toml:"timestamp_column"`
+	// TimestampFormat is the format of the values in the TimestampColumn, with the same options as TimeFilterFormat
+	TimestampFormat string `toml:"timestamp_format"`
+	// ResponseFormat is the format of the origin's results: 'json' or 'csv' (with a header row)
+	ResponseFormat string `toml:"response_format"`
+	// RowsField is the field of a JSON result that holds the rows, which may be arrays of values or
+	// objects keyed by column name. When empty, the result is itself the array of rows
+	RowsField string `toml:"rows_field"`
+	// ColumnsField is the field of a JSON result that names the columns of rows that are arrays of values
+	ColumnsField string `toml:"columns_field"`
+
+	// TimeFilter is the compiled TimeFilterPattern
+	TimeFilter *regexp.Regexp `toml:"-"`
+	// Step is the compiled StepPattern
+	Step *regexp.Regexp `toml:"-"`
+}
+
+// NewSQLHTTPConfig returns a *SQLHTTPConfig with the default settings
+func NewSQLHTTPConfig() *SQLHTTPConfig {
+	return &SQLHTTPConfig{
+		QueryParam:       defaultSQLHTTPQueryParam,
+		TimeFilterFormat: defaultSQLHTTPTimeFormat,
+		TimestampFormat:  defaultSQLHTTPTimeFormat,
+		ResponseFormat:   defaultSQLHTTPResponseFormat,
+		RowsField:        defaultSQLHTTPRowsField,
+		ColumnsField:     defaultSQLHTTPColumnsField,
+	}
+}
+
+// Clone returns an exact copy of the subject *SQLHTTPConfig
+func (sc *SQLHTTPConfig) Clone() *SQLHTTPConfig {
+	return &SQLHTTPConfig{
+		QueryParam:             sc.QueryParam,
+		QueryBodyField:         sc.QueryBodyField,
+		TimeFilterPattern:      sc.TimeFilterPattern,
+		TimeFilterFormat:       sc.TimeFilterFormat,
+		TimeFilterEndExclusive: sc.TimeFilterEndExclusive,
+		StepPattern:            sc.StepPattern,
+		TimestampColumn:        sc.TimestampColumn,
+		TimestampFormat:        sc.TimestampFormat,
+		ResponseFormat:         sc.ResponseFormat,
+		RowsField:              sc.RowsField,
+		ColumnsField:           sc.ColumnsField,
+		TimeFilter:             sc.TimeFilter,
+		Step:                   sc.Step,
+	}
+}
+
+// processSQLHTTPConfig overlays the settings that are defined in the config file for the named origin
+func (sc *SQLHTTPConfig) processSQLHTTPConfig(metadata *toml.MetaData, name string, v *SQLHTTPConfig) {
+
+	isDefined := func(key string) bool {
+		return metadata.IsDefined("origins", name, "sqlhttp", key)
+	}
+
+	if isDefined("query_param") {
+		sc.QueryParam = v.QueryParam
+	}
+	if isDefined("query_body_field") {
+		sc.QueryBodyField = v.QueryBodyField
+	}
+	if isDefined("time_filter_pattern") {
+		sc.TimeFilterPattern = v.TimeFilterPattern
+	}
+	if isDefined("time_filter_format") {
+		sc.TimeFilterFormat = v.TimeFilterFormat
+	}
+	if isDefined("time_filter_end_exclusive") {
+		sc.TimeFilterEndExclusive = v.TimeFilterEndExclusive
+	}
+	if isDefined("step_pattern") {
+		sc.StepPattern = v.StepPattern
+	}
+	if isDefined("timestamp_column") {
+		sc.TimestampColumn = v.TimestampColumn
+	}
+	if isDefined("timestamp_format") {
+		sc.TimestampFormat = v.TimestampFormat
+	}
+	if isDefined("response_format") {
+		sc.ResponseFormat = v.ResponseFormat
+	}
+	if isDefined("rows_field") {
+		sc.RowsField = v.RowsField
+	}
+	if isDefined("columns_field") {
+		sc.ColumnsField = v.ColumnsField
+	}
+}
+
+// compile validates the subject *SQLHTTPConfig and compiles its patterns
+func (sc *SQLHTTPConfig) compile() error {
+
+	if sc.TimestampColumn == "" {
+		return fmt.Errorf("missing timestamp_column")
+	}
+
+	if sc.ResponseFormat != "json" && sc.ResponseFormat != "csv" {
+		return fmt.Errorf("invalid response_format: %s", sc.ResponseFormat)
+	}
+
+	if sc.TimeFilterFormat == "" || sc.TimestampFormat == "" {
+		return fmt.Errorf("missing time_filter_format or timestamp_format")
+	}
+
+	var err error
+	sc.TimeFilter, err = compileNamedPattern("time_filter_pattern", sc.TimeFilterPattern, "start", "end")
+	if err != nil {
+		return err
+	}
+
+	sc.Step, err = compileNamedPattern("step_pattern", sc.StepPattern, "step")
+	return err
+}
+
+// compileNamedPattern compiles a regular expression, which must include the provided named groups
+func compileNamedPattern(field, pattern string, groups ...string) (*regexp.Regexp, error) {
+
+	if pattern == "" {
+		return nil, fmt.Errorf("missing %s", field)
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %s", field, err.Error())
+	}
+
+	names := make(map[string]bool)
+	for _, n := range re.SubexpNames() {
+		names[n] = true
+	}
+	for _, g := range groups {
+		if !names[g] {
+			return nil, fmt.Errorf("%s is missing the named group '%s'", field, g)
+		}
+	}
+
+	return re, nil
+}
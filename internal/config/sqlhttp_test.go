@@ -0,0 +1,75 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package config
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestSQLHTTPConfigClone(t *testing.T) {
+
+	sc := NewSQLHTTPConfig()
+	sc.TimeFilterPattern = `ts >= '(?P<start>[^']+)' AND ts < '(?P<end>[^']+)'`
+	sc.StepPattern = `SAMPLE BY (?P<step>\w+)`
+	sc.TimestampColumn = "ts"
+	if err := sc.compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	sc2 := sc.Clone()
+	if *sc2 != *sc {
+		t.Errorf("expected %v got %v", sc, sc2)
+	}
+
+}
+
+func TestSQLHTTPConfigCompile(t *testing.T) {
+
+	tests := []struct {
+		timeFilter, step, column, format string
+		expected                         string
+	}{
+		{`ts >= (?P<start>\d+) AND ts < (?P<end>\d+)`, `(?P<step>\d+)`, "ts", "json", ""},
+		{`ts >= (?P<start>\d+) AND ts < (?P<end>\d+)`, `(?P<step>\d+)`, "ts", "csv", ""},
+		{`ts >= (?P<start>\d+) AND ts < (?P<end>\d+)`, `(?P<step>\d+)`, "", "json", "missing timestamp_column"},
+		{`ts >= (?P<start>\d+) AND ts < (?P<end>\d+)`, `(?P<step>\d+)`, "ts", "xml", "invalid response_format: xml"},
+		{"", `(?P<step>\d+)`, "ts", "json", "missing time_filter_pattern"},
+		{`ts >= (?P<start>\d+`, `(?P<step>\d+)`, "ts", "json",
+			"invalid time_filter_pattern: error parsing regexp: missing closing ): `ts >= (?P<start>\\d+`"},
+		{`ts >= (?P<start>\d+)`, `(?P<step>\d+)`, "ts", "json", "time_filter_pattern is missing the named group 'end'"},
+		{`ts >= (?P<start>\d+) AND ts < (?P<end>\d+)`, `(\d+)`, "ts", "json", "step_pattern is missing the named group 'step'"},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			sc := NewSQLHTTPConfig()
+			sc.TimeFilterPattern = test.timeFilter
+			sc.StepPattern = test.step
+			sc.TimestampColumn = test.column
+			sc.ResponseFormat = test.format
+			err := sc.compile()
+			if test.expected == "" {
+				if err != nil {
+					t.Error(err)
+				} else if sc.TimeFilter == nil || sc.Step == nil {
+					t.Errorf("expected compiled patterns")
+				}
+			} else if err == nil || err.Error() != test.expected {
+				t.Errorf("expected error `%s` got `%v`", test.expected, err)
+			}
+		})
+	}
+
+}
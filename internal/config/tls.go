@@ -15,6 +15,8 @@ package config
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"fmt"
 	"io/ioutil"
 )
 
@@ -68,6 +70,49 @@ func (tc *TLSConfig) Clone() *TLSConfig {
 	}
 }
 
+// ClientTLSConfig returns a crypto/tls client configuration built from the subject *TLSConfig's
+// InsecureSkipVerify, Client Certificate and Certificate Authority settings
+func (tc *TLSConfig) ClientTLSConfig() (*tls.Config, error) {
+
+	TLSConfig := &tls.Config{InsecureSkipVerify: tc.InsecureSkipVerify}
+
+	if tc.ClientCertPath != "" && tc.ClientKeyPath != "" {
+		// load client cert
+		cert, err := tls.LoadX509KeyPair(tc.ClientCertPath, tc.ClientKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		TLSConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if tc.CertificateAuthorityPaths != nil && len(tc.CertificateAuthorityPaths) > 0 {
+
+		// credit snippet to https://forfuncsake.github.io/post/2017/08/trust-extra-ca-cert-in-go-app/
+		// Get the SystemCertPool, continue with an empty pool on error
+		rootCAs, _ := x509.SystemCertPool()
+		if rootCAs == nil {
+			rootCAs = x509.NewCertPool()
+		}
+
+		for _, path := range tc.CertificateAuthorityPaths {
+			// Read in the cert file
+			certs, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			// Append our cert to the system pool
+			if ok := rootCAs.AppendCertsFromPEM(certs); !ok {
+				return nil, fmt.Errorf("unable to append to CA Certs from file %s", path)
+			}
+		}
+
+		// Trust the augmented cert pool in our client
+		TLSConfig.RootCAs = rootCAs
+	}
+
+	return TLSConfig, nil
+}
+
 func (c *TricksterConfig) verifyTLSConfigs() error {
 
 	for _, oc := range c.Origins {
@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"github.com/Comcast/trickster/internal/cache"
+	"github.com/Comcast/trickster/internal/cache/breaker"
 	"github.com/Comcast/trickster/internal/cache/status"
 	tc "github.com/Comcast/trickster/internal/proxy/context"
 	"github.com/Comcast/trickster/internal/proxy/headers"
@@ -33,8 +34,17 @@ import (
 	kv "go.opentelemetry.io/otel/api/key"
 )
 
-// QueryCache queries the cache for an HTTPDocument and returns it
-func QueryCache(ctx context.Context, c cache.Cache, key string, ranges byterange.Ranges) (*HTTPDocument, status.LookupStatus, byterange.Ranges, error) {
+// allowExpired returns true if expired objects that are still in the cache should be returned on
+// normal lookups. This is true of all cache types except Redis, which only retains expired objects
+// for its stale_ttl_ms, so that they are served only when the origin fails.
+func allowExpired(c cache.Cache) bool {
+	return c.Configuration().CacheType != "redis"
+}
+
+// QueryCache queries the cache for an HTTPDocument and returns it. When allowExpired is true, an
+// expired document that is still retained by the cache (e.g., for a Redis cache's stale_ttl_ms)
+// is returned as a hit, so that it can be served stale when the origin fails.
+func QueryCache(ctx context.Context, c cache.Cache, key string, ranges byterange.Ranges, allowExpired bool) (*HTTPDocument, status.LookupStatus, byterange.Ranges, error) {
 
 	rsc := tc.Resources(ctx).(*request.Resources)
 	oc := rsc.OriginConfig
@@ -50,7 +60,7 @@ func QueryCache(ctx context.Context, c cache.Cache, key string, ranges byterange
 	if c.Configuration().CacheType == "memory" {
 		mc := c.(cache.MemoryCache)
 		var ifc interface{}
-		ifc, lookupStatus, err = mc.RetrieveReference(key, true)
+		ifc, lookupStatus, err = mc.RetrieveReference(key, allowExpired)
 		// normalize any cache miss errors to cache.ErrKNF. We'll get all of them updated so we can remove this code
 		if err != nil && err != cache.ErrKNF && strings.HasSuffix(err.Error(), "not in cache") {
 			err = cache.ErrKNF
@@ -72,11 +82,18 @@ func QueryCache(ctx context.Context, c cache.Cache, key string, ranges byterange
 
 	} else {
 
-		bytes, lookupStatus, err = c.Retrieve(key, true)
+		start := time.Now()
+		bytes, lookupStatus, err = c.Retrieve(key, allowExpired)
 		// normalize any cache miss errors to cache.ErrKNF. We'll get all of them updated so we can remove this code
 		if err != nil && err != cache.ErrKNF && strings.HasSuffix(err.Error(), "not in cache") {
 			err = cache.ErrKNF
 		}
+		// a cache miss is still a successful cache operation as far as the circuit breaker is concerned
+		opErr := err
+		if opErr == cache.ErrKNF {
+			opErr = nil
+		}
+		breaker.Get(c.Configuration().Name).Record(opErr, time.Since(start))
 
 		if err != nil || (lookupStatus != status.LookupStatusHit) {
 			var nr byterange.Ranges
@@ -203,7 +220,9 @@ func WriteCache(ctx context.Context, c cache.Cache, key string, d *HTTPDocument,
 		bytes = append([]byte{0}, bytes...)
 	}
 
+	start := time.Now()
 	err := c.Store(key, bytes, ttl)
+	breaker.Get(c.Configuration().Name).Record(err, time.Since(start))
 	if err != nil {
 		span.AddEvent(
 			ctx,
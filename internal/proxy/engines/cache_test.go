@@ -27,6 +27,7 @@ import (
 
 	"github.com/Comcast/trickster/internal/proxy/request"
 
+	"github.com/Comcast/trickster/internal/cache"
 	cr "github.com/Comcast/trickster/internal/cache/registration"
 	"github.com/Comcast/trickster/internal/cache/status"
 	"github.com/Comcast/trickster/internal/config"
@@ -111,7 +112,7 @@ func TestCacheHitRangeRequest(t *testing.T) {
 	}
 
 	ranges := byterange.Ranges{byterange.Range{Start: 5, End: 10}}
-	d2, _, deltas, err := QueryCache(ctx, cache, "testKey", ranges)
+	d2, _, deltas, err := QueryCache(ctx, cache, "testKey", ranges, false)
 	if err != nil {
 		t.Error(err)
 	}
@@ -155,7 +156,7 @@ func TestCacheHitRangeRequest2(t *testing.T) {
 	}
 
 	ranges := byterange.Ranges{byterange.Range{Start: 5, End: 10}}
-	d2, _, deltas, err := QueryCache(ctx, cache, "testKey", ranges)
+	d2, _, deltas, err := QueryCache(ctx, cache, "testKey", ranges, false)
 	if err != nil {
 		t.Error(err)
 	}
@@ -199,7 +200,7 @@ func TestCacheHitRangeRequest3(t *testing.T) {
 	}
 
 	qrange := byterange.Ranges{byterange.Range{Start: 5, End: 10}}
-	_, _, deltas, err := QueryCache(ctx, cache, "testKey", qrange)
+	_, _, deltas, err := QueryCache(ctx, cache, "testKey", qrange, false)
 	if err != nil {
 		t.Error(err)
 	}
@@ -240,7 +241,7 @@ func TestPartialCacheMissRangeRequest(t *testing.T) {
 	}
 
 	ranges := byterange.Ranges{byterange.Range{Start: 5, End: 20}}
-	_, _, deltas, err := QueryCache(ctx, cache, "testKey", ranges)
+	_, _, deltas, err := QueryCache(ctx, cache, "testKey", ranges, false)
 	if err != nil {
 		t.Error(err)
 	}
@@ -283,7 +284,7 @@ func TestFullCacheMissRangeRequest(t *testing.T) {
 	}
 
 	ranges := byterange.Ranges{byterange.Range{Start: 15, End: 20}}
-	_, _, deltas, err := QueryCache(ctx, cache, "testKey", ranges)
+	_, _, deltas, err := QueryCache(ctx, cache, "testKey", ranges, false)
 	if err != nil {
 		t.Error(err)
 	}
@@ -334,7 +335,7 @@ func TestRangeRequestFromClient(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
-	_, _, deltas, err := QueryCache(ctx, cache, "testKey2", want)
+	_, _, deltas, err := QueryCache(ctx, cache, "testKey2", want, false)
 	if err != nil {
 		t.Error(err)
 	}
@@ -343,7 +344,7 @@ func TestRangeRequestFromClient(t *testing.T) {
 	}
 	want[0].Start = 20
 	want[0].End = 35
-	_, _, deltas, err = QueryCache(ctx, cache, "testKey2", want)
+	_, _, deltas, err = QueryCache(ctx, cache, "testKey2", want, false)
 	if err != nil {
 		t.Error(err)
 	}
@@ -385,7 +386,7 @@ func TestQueryCache(t *testing.T) {
 		t.Error(err)
 	}
 
-	d2, _, _, err := QueryCache(ctx, cache, "testKey", nil)
+	d2, _, _, err := QueryCache(ctx, cache, "testKey", nil, false)
 	if err != nil {
 		t.Error(err)
 	}
@@ -398,7 +399,7 @@ func TestQueryCache(t *testing.T) {
 		t.Errorf("expected %d got %d", 200, d2.StatusCode)
 	}
 
-	_, _, _, err = QueryCache(ctx, cache, "testKey2", nil)
+	_, _, _, err = QueryCache(ctx, cache, "testKey2", nil, false)
 	if err == nil {
 		t.Errorf("expected error")
 	}
@@ -407,7 +408,7 @@ func TestQueryCache(t *testing.T) {
 	cache.Remove("testKey")
 	cache.Configuration().CacheType = "test"
 
-	_, _, _, err = QueryCache(ctx, cache, "testKey", byterange.Ranges{{Start: 0, End: 1}})
+	_, _, _, err = QueryCache(ctx, cache, "testKey", byterange.Ranges{{Start: 0, End: 1}}, false)
 	if err == nil {
 		t.Errorf("expected error")
 	}
@@ -417,7 +418,7 @@ func TestQueryCache(t *testing.T) {
 		t.Error(err)
 	}
 
-	d2, _, _, err = QueryCache(ctx, cache, "testKey", nil)
+	d2, _, _, err = QueryCache(ctx, cache, "testKey", nil, false)
 	if err != nil {
 		t.Error(err)
 	}
@@ -456,3 +457,58 @@ func (tc *testCache) Remove(cacheKey string)                     {}
 func (tc *testCache) BulkRemove(cacheKeys []string, noLock bool) {}
 func (tc *testCache) Close() error                               { return errTest }
 func (tc *testCache) Configuration() *config.CachingConfig       { return tc.configuration }
+
+// staleCache wraps a cache to simulate one that retains expired objects (e.g., Redis with
+// stale_ttl_ms), by treating every object as expired unless allowExpired is set
+type staleCache struct {
+	cache.Cache
+}
+
+func (sc *staleCache) Retrieve(cacheKey string, allowExpired bool) ([]byte, status.LookupStatus, error) {
+	if !allowExpired {
+		return nil, status.LookupStatusKeyMiss, cache.ErrKNF
+	}
+	return sc.Cache.Retrieve(cacheKey, allowExpired)
+}
+
+func TestAllowExpired(t *testing.T) {
+
+	err := config.Load("trickster", "test", []string{"-origin-url", "http://1", "-origin-type", "test"})
+	if err != nil {
+		t.Errorf("Could not load configuration: %s", err.Error())
+	}
+
+	cr.LoadCachesFromConfig()
+	cache, err := cr.GetCache("default")
+	if err != nil {
+		t.Error(err)
+	}
+
+	resp := &http.Response{Header: make(http.Header), StatusCode: 200}
+	d := DocumentFromHTTPResponse(resp, []byte("1234"), nil)
+	d.ContentType = "text/plain"
+
+	ctx := context.Background()
+	ctx = tc.WithResources(ctx, &request.Resources{OriginConfig: config.Origins["default"]})
+
+	err = WriteCache(ctx, cache, "testKey", d, time.Millisecond, map[string]bool{"text/plain": true})
+	if err != nil {
+		t.Error(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	// expired objects that have not been reaped are still hits for caches other than redis
+	if !allowExpired(cache) {
+		t.Errorf("expected expired objects to be allowed for %s", cache.Configuration().CacheType)
+	}
+	_, cacheStatus, _, err := QueryCache(ctx, cache, "testKey", nil, allowExpired(cache))
+	if err != nil || cacheStatus != status.LookupStatusHit {
+		t.Errorf("expected %s got %s", status.LookupStatusHit, cacheStatus)
+	}
+
+	cache.Configuration().CacheType = "redis"
+	if allowExpired(cache) {
+		t.Error("expected expired objects to be disallowed for redis")
+	}
+	cache.Configuration().CacheType = "memory"
+}
@@ -23,6 +23,7 @@ import (
 	"time"
 
 	tc "github.com/Comcast/trickster/internal/cache"
+	"github.com/Comcast/trickster/internal/cache/breaker"
 	"github.com/Comcast/trickster/internal/cache/status"
 	"github.com/Comcast/trickster/internal/config"
 	tctx "github.com/Comcast/trickster/internal/proxy/context"
@@ -61,6 +62,12 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !breaker.Get(cc.Name).Allow() {
+		// the cache's circuit breaker is open, so bypass the cache
+		DoProxy(w, r)
+		return
+	}
+
 	var cacheStatus status.LookupStatus
 
 	pr := newProxyRequest(r, w)
@@ -78,7 +85,12 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 
 	OldestRetainedTimestamp := time.Time{}
 	if oc.TimeseriesEvictionMethod == config.EvictionMethodOldest {
-		OldestRetainedTimestamp = now.Truncate(trq.Step).Add(-(trq.Step * oc.TimeseriesRetention))
+		if trq.Step > 0 {
+			OldestRetainedTimestamp = now.Truncate(trq.Step).Add(-(trq.Step * oc.TimeseriesRetention))
+		} else {
+			// raw sample queries have no step to apply the retention factor to, so use the value retention
+			OldestRetainedTimestamp = now.Add(-oc.ValueRetention)
+		}
 		if trq.Extent.End.Before(OldestRetainedTimestamp) {
 			log.Debug("timerange end is too early to consider caching", log.Pairs{"oldestRetainedTimestamp": OldestRetainedTimestamp, "step": trq.Step, "retention": oc.TimeseriesRetention})
 			DoProxy(w, r)
@@ -92,7 +104,7 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 	}
 
 	client.SetExtent(r, trq, &trq.Extent)
-	key := oc.CacheKeyPrefix + "." + pr.DeriveCacheKey(trq.TemplateURL, "")
+	key := cacheKeyPrefix(r, oc) + pr.DeriveCacheKey(trq.TemplateURL, "")
 
 	locks.Acquire(key)
 
@@ -123,9 +135,14 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 			return // fetchTimeseries logs the error
 		}
 	} else {
-		doc, cacheStatus, _, err = QueryCache(ctx, cache, key, nil)
+		doc, cacheStatus, _, err = QueryCache(ctx, cache, key, nil, allowExpired(cache))
 		if cacheStatus == status.LookupStatusKeyMiss && err == tc.ErrKNF {
 			cts, doc, elapsed, err = fetchTimeseries(pr, trq, client)
+			if err != nil && doc.StatusCode >= http.StatusInternalServerError &&
+				respondStale(ctx, w, r, cache, key, trq, client) {
+				locks.Release(key)
+				return
+			}
 			if err != nil {
 				recordDPCResult(r, status.LookupStatusProxyError, doc.StatusCode, r.URL.Path, "", elapsed.Seconds(), nil, doc.Headers)
 
@@ -215,6 +232,9 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 	if len(missRanges) > 0 {
 		dpStatus["extentsFetched"] = timeseries.ExtentList(missRanges).String()
 	}
+	if trq.Lookback > 0 {
+		dpStatus["lookback"] = trq.Lookback.String()
+	}
 
 	// maintain a list of timeseries to merge into the main timeseries
 	mts := make([]timeseries.Timeseries, 0, len(missRanges))
@@ -362,6 +382,43 @@ func DeltaProxyCacheRequest(w http.ResponseWriter, r *http.Request) {
 	locks.Release(key)
 }
 
+// respondStale responds with the expired timeseries that the cache still retains for the key
+// (e.g., for a Redis cache's stale_ttl_ms) in place of an origin error, and returns false if
+// there is none to serve
+func respondStale(ctx context.Context, w http.ResponseWriter, r *http.Request, c tc.Cache, key string,
+	trq *timeseries.TimeRangeQuery, client origins.TimeseriesClient) bool {
+
+	doc, cacheStatus, _, err := QueryCache(ctx, c, key, nil, true)
+	if err != nil || cacheStatus != status.LookupStatusHit || doc == nil {
+		return false
+	}
+
+	var cts timeseries.Timeseries
+	if c.Configuration().CacheType == "memory" {
+		cts = doc.timeseries
+	} else {
+		cts, err = client.UnmarshalTimeseries(doc.Body)
+	}
+	if err != nil || cts == nil {
+		return false
+	}
+
+	rts := cts.Clone()
+	rts.CropToRange(trq.Extent)
+	rts.SetExtents(nil)
+	rts.SetStep(0)
+	rdata, err := client.MarshalTimeseries(rts)
+	if err != nil {
+		return false
+	}
+
+	rh := http.Header(doc.Headers).Clone()
+	log.Warn("serving stale timeseries after origin failure", log.Pairs{"cacheKey": key})
+	recordDPCResult(r, status.LookupStatusStale, doc.StatusCode, r.URL.Path, "", 0, nil, rh)
+	Respond(w, doc.StatusCode, rh, rdata)
+	return true
+}
+
 func logDeltaRoutine(p log.Pairs) { log.Debug("delta routine completed", p) }
 
 func fetchTimeseries(pr *proxyRequest, trq *timeseries.TimeRangeQuery, client origins.TimeseriesClient) (timeseries.Timeseries, *HTTPDocument, time.Duration, error) {
@@ -68,7 +68,7 @@ func DoProxy(w io.Writer, r *http.Request) *http.Response {
 		}
 	} else {
 		pr := newProxyRequest(r, w)
-		key := oc.CacheKeyPrefix + "." + pr.DeriveCacheKey(nil, "")
+		key := cacheKeyPrefix(r, oc) + pr.DeriveCacheKey(nil, "")
 		result, ok := Reqs.Load(key)
 		if !ok {
 			var contentLength int64
@@ -232,6 +232,16 @@ func setStatusHeader(httpStatus int, header http.Header) status.LookupStatus {
 	return st
 }
 
+// tenantLabel returns the tenant label value for the request's tenant request metrics. Tenants are
+// only labeled by name when they are in the origin's tenant_metrics_allowlist, so that clients can't
+// create an unbounded number of series by sending arbitrary tenant header values
+func tenantLabel(r *http.Request, oc *config.OriginConfig) string {
+	if t := r.Header.Get(oc.TenantHeader); oc.TenantMetricsAllowed[t] {
+		return t
+	}
+	return "other"
+}
+
 func recordResults(r *http.Request, engine string, cacheStatus status.LookupStatus, statusCode int, path, ffStatus string, elapsed float64, extents timeseries.ExtentList, header http.Header) {
 
 	rsc := request.GetResources(r)
@@ -246,6 +256,9 @@ func recordResults(r *http.Request, engine string, cacheStatus status.LookupStat
 		if elapsed > 0 {
 			metrics.ProxyRequestDuration.WithLabelValues(oc.Name, oc.OriginType, r.Method, status, httpStatus, path).Observe(elapsed)
 		}
+		if oc.TenantHeader != "" {
+			metrics.ProxyTenantRequestStatus.WithLabelValues(oc.Name, oc.OriginType, tenantLabel(r, oc), status, httpStatus).Inc()
+		}
 	}
 	headers.SetResultsHeader(header, engine, status, ffStatus, extents)
 }
@@ -296,3 +296,27 @@ func TestPrepareFetchReaderErr(t *testing.T) {
 		t.Errorf("expected 0 got %d", i)
 	}
 }
+
+func TestTenantLabel(t *testing.T) {
+
+	oc := config.NewOriginConfig()
+	oc.TenantHeader = "X-Scope-OrgID"
+
+	r := httptest.NewRequest(http.MethodGet, "http://0/", nil)
+	r.Header.Set(oc.TenantHeader, "tenant-a")
+
+	// without an allowlist, all tenants are counted as other
+	if l := tenantLabel(r, oc); l != "other" {
+		t.Errorf("expected %s got %s", "other", l)
+	}
+
+	oc.TenantMetricsAllowed = map[string]bool{"tenant-a": true}
+	if l := tenantLabel(r, oc); l != "tenant-a" {
+		t.Errorf("expected %s got %s", "tenant-a", l)
+	}
+
+	r.Header.Set(oc.TenantHeader, "tenant-b")
+	if l := tenantLabel(r, oc); l != "other" {
+		t.Errorf("expected %s got %s", "other", l)
+	}
+}
@@ -24,6 +24,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/Comcast/trickster/internal/config"
 	"github.com/Comcast/trickster/internal/proxy/headers"
 	"github.com/Comcast/trickster/internal/proxy/request"
 	"github.com/Comcast/trickster/internal/util/md5"
@@ -31,6 +32,16 @@ import (
 
 var methodsWithBody = map[string]bool{http.MethodPut: true, http.MethodPost: true, http.MethodPatch: true}
 
+// cacheKeyPrefix returns the prefix of the cache keys for the request. When the origin has a
+// tenant header, the prefix includes a hash of the request's tenant, so that tenants never share
+// cached objects, and so that the Cache Index can apply the origin's quotas to each tenant.
+func cacheKeyPrefix(r *http.Request, oc *config.OriginConfig) string {
+	if oc.TenantHeader == "" {
+		return oc.CacheKeyPrefix + "."
+	}
+	return oc.CacheKeyPrefix + "." + md5.Checksum(r.Header.Get(oc.TenantHeader)) + "."
+}
+
 // DeriveCacheKey calculates a query-specific keyname based on the prometheus query in the user request
 func (pr *proxyRequest) DeriveCacheKey(templateURL *url.URL, extra string) string {
 
@@ -73,11 +84,12 @@ func (pr *proxyRequest) DeriveCacheKey(templateURL *url.URL, extra string) strin
 
 	if len(pc.CacheKeyParams) == 1 && pc.CacheKeyParams[0] == "*" {
 		for p := range params {
-			vals = append(vals, fmt.Sprintf("%s.%s.", p, params.Get(p)))
+			vals = append(vals, fmt.Sprintf("%s.%s.", p, strings.Join(params[p], ",")))
 		}
 	} else {
+		// parameters like match[] can be provided multiple times, so all values are included
 		for _, p := range pc.CacheKeyParams {
-			if v := params.Get(p); v != "" {
+			if v := strings.Join(params[p], ","); v != "" {
 				vals = append(vals, fmt.Sprintf("%s.%s.", p, v))
 			}
 		}
@@ -91,10 +103,11 @@ func (pr *proxyRequest) DeriveCacheKey(templateURL *url.URL, extra string) strin
 
 	if _, ok := methodsWithBody[pr.Method]; ok && pc.CacheKeyFormFields != nil && len(pc.CacheKeyFormFields) > 0 {
 		ct := pr.Header.Get(headers.NameContentType)
-		if ct == headers.ValueXFormURLEncoded || strings.HasPrefix(ct, headers.ValueMultipartFormData) || ct == headers.ValueApplicationJSON {
+		isForm := strings.HasPrefix(ct, headers.ValueXFormURLEncoded)
+		if isForm || strings.HasPrefix(ct, headers.ValueMultipartFormData) || ct == headers.ValueApplicationJSON {
 			b, _ := ioutil.ReadAll(pr.Body)
 			pr.Body = ioutil.NopCloser(bytes.NewReader(b))
-			if ct == headers.ValueXFormURLEncoded {
+			if isForm {
 				pr.ParseForm()
 			} else if strings.HasPrefix(ct, headers.ValueMultipartFormData) {
 				pr.ParseMultipartForm(1024 * 1024)
@@ -114,11 +127,15 @@ func (pr *proxyRequest) DeriveCacheKey(templateURL *url.URL, extra string) strin
 				}
 			}
 			pr.Body = ioutil.NopCloser(bytes.NewReader(b))
+			// the upstream request was cloned with the same body, which has now been read
+			if pr.upstreamRequest != nil {
+				pr.upstreamRequest.Body = ioutil.NopCloser(bytes.NewReader(b))
+			}
 		}
 
 		for _, f := range pc.CacheKeyFormFields {
-			if _, ok := pr.Form[f]; ok {
-				if v := pr.FormValue(f); v != "" {
+			if fv, ok := pr.Form[f]; ok {
+				if v := strings.Join(fv, ","); v != "" {
 					vals = append(vals, fmt.Sprintf("%s.%s.", f, v))
 				}
 			}
@@ -22,6 +22,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/Comcast/trickster/internal/config"
@@ -169,6 +170,23 @@ func TestDeriveCacheKey(t *testing.T) {
 		t.Errorf("expected %s got %s", expected, key)
 	}
 
+	// all values of repeated parameters and form fields are part of the key
+	cfg.Paths["root"].CacheKeyParams = []string{"match[]"}
+	cfg.Paths["root"].CacheKeyFormFields = []string{"match[]"}
+	keys := make(map[string]bool)
+	for _, body := range []string{"match[]=a&match[]=b", "match[]=a&match[]=c", "match[]=a"} {
+		tr = httptest.NewRequest(http.MethodPost, "http://127.0.0.1/", bytes.NewReader([]byte(body)))
+		tr = tr.WithContext(ct.WithResources(context.Background(), request.NewResources(cfg, cfg.Paths["root"], nil, nil, nil)))
+		tr.Header.Set(headers.NameContentType, headers.ValueXFormURLEncoded+"; charset=UTF-8")
+		keys[newProxyRequest(tr, nil).DeriveCacheKey(nil, "")] = true
+		tr = httptest.NewRequest(http.MethodGet, "http://127.0.0.1/?"+body, nil)
+		tr = tr.WithContext(ct.WithResources(context.Background(), request.NewResources(cfg, cfg.Paths["root"], nil, nil, nil)))
+		keys[newProxyRequest(tr, nil).DeriveCacheKey(nil, "")] = true
+	}
+	if len(keys) != 6 {
+		t.Errorf("expected %d got %d", 6, len(keys))
+	}
+
 	// Test Custom KeyHasher Integration
 	rpath.KeyHasher = []config.KeyHasherFunc{exampleKeyHasher}
 	key = pr.DeriveCacheKey(nil, "extra")
@@ -242,3 +260,27 @@ func TestDeriveCacheKeyNoPathConfig(t *testing.T) {
 	}
 
 }
+
+func TestCacheKeyPrefix(t *testing.T) {
+
+	oc := &config.OriginConfig{CacheKeyPrefix: "test"}
+
+	r := httptest.NewRequest("GET", "http://127.0.0.1/", nil)
+	r.Header.Set("X-Scope-OrgID", "tenant1")
+
+	if p := cacheKeyPrefix(r, oc); p != "test." {
+		t.Errorf("expected %s got %s", "test.", p)
+	}
+
+	oc.TenantHeader = "X-Scope-OrgID"
+	p1 := cacheKeyPrefix(r, oc)
+	if p1 == "test." || !strings.HasPrefix(p1, "test.") || !strings.HasSuffix(p1, ".") {
+		t.Errorf("unexpected prefix %s", p1)
+	}
+
+	r.Header.Set("X-Scope-OrgID", "tenant2")
+	if p2 := cacheKeyPrefix(r, oc); p2 == p1 {
+		t.Errorf("expected tenants to have different prefixes, got %s", p2)
+	}
+
+}
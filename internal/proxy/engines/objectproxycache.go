@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/Comcast/trickster/internal/cache"
+	"github.com/Comcast/trickster/internal/cache/breaker"
 	"github.com/Comcast/trickster/internal/cache/status"
 	"github.com/Comcast/trickster/internal/proxy/headers"
 	"github.com/Comcast/trickster/internal/proxy/request"
@@ -223,9 +224,39 @@ func handleTrueCacheHit(pr *proxyRequest) error {
 func handleCacheKeyMiss(pr *proxyRequest) error {
 	pr.prepareUpstreamRequests()
 	handleUpstreamTransactions(pr)
+	if pr.loadStaleDocument() {
+		return handleTrueCacheHit(pr)
+	}
 	return handleAllWrites(pr)
 }
 
+// loadStaleDocument loads the expired document that the cache still retains for the key (e.g., for
+// a Redis cache's stale_ttl_ms) when the origin has failed, so that it can be served in place of the
+// origin's error. It returns false if the origin did not fail, or there is no document to serve.
+func (pr *proxyRequest) loadStaleDocument() bool {
+
+	if pr.upstreamResponse == nil || pr.upstreamResponse.StatusCode < http.StatusInternalServerError {
+		return false
+	}
+
+	rsc := request.GetResources(pr.Request)
+	d, cacheStatus, _, err := QueryCache(pr.Context(), rsc.CacheClient, pr.key, nil, true)
+	if err != nil || cacheStatus != status.LookupStatusHit || d == nil || len(d.Ranges) > 0 {
+		return false
+	}
+
+	if rc, ok := pr.upstreamReader.(io.Closer); ok {
+		rc.Close()
+	}
+
+	log.Warn("serving stale object after origin failure", log.Pairs{"cacheKey": pr.key})
+	pr.cacheDocument = d
+	pr.cacheStatus = status.LookupStatusStale
+	pr.cachingPolicy.IsNegativeCache = false
+	pr.writeToCache = false
+	return true
+}
+
 func handleUpstreamTransactions(pr *proxyRequest) error {
 	pr.makeUpstreamRequests()
 	pr.reconstituteResponses()
@@ -277,7 +308,7 @@ func fetchViaObjectProxyCache(w io.Writer, r *http.Request) (*http.Response, sta
 
 	pr.cachingPolicy = GetRequestCachingPolicy(pr.Header)
 
-	pr.key = oc.CacheKeyPrefix + "." + pr.DeriveCacheKey(nil, "")
+	pr.key = cacheKeyPrefix(r, oc) + pr.DeriveCacheKey(nil, "")
 	pcfResult, pcfExists := Reqs.Load(pr.key)
 	if (!pr.wantsRanges && pcfExists) || pr.cachingPolicy.NoCache {
 		if pr.cachingPolicy.NoCache {
@@ -288,6 +319,11 @@ func fetchViaObjectProxyCache(w io.Writer, r *http.Request) (*http.Response, sta
 		return nil, status.LookupStatusProxyOnly
 	}
 
+	if !breaker.Get(cc.Configuration().Name).Allow() {
+		// the cache's circuit breaker is open, so bypass the cache
+		return nil, status.LookupStatusProxyOnly
+	}
+
 	if pcfExists {
 		pr.collapsedForwarder = pcfResult.(ProgressiveCollapseForwarder)
 	}
@@ -299,7 +335,7 @@ func fetchViaObjectProxyCache(w io.Writer, r *http.Request) (*http.Response, sta
 	}
 
 	var err error
-	pr.cacheDocument, pr.cacheStatus, pr.neededRanges, err = QueryCache(pr.Context(), cc, pr.key, pr.wantedRanges)
+	pr.cacheDocument, pr.cacheStatus, pr.neededRanges, err = QueryCache(pr.Context(), cc, pr.key, pr.wantedRanges, allowExpired(cc))
 	if err == nil || err == cache.ErrKNF {
 		if f, ok := cacheResponseHandlers[pr.cacheStatus]; ok {
 			f(pr)
@@ -144,6 +144,30 @@ func TestObjectProxyCacheRequest(t *testing.T) {
 
 }
 
+func TestObjectProxyCacheRequestStaleOnError(t *testing.T) {
+
+	hdrs := map[string]string{"Cache-Control": "max-age=60"}
+	ts, _, r, rsc, err := setupTestHarnessOPC("", "test", http.StatusOK, hdrs)
+	if err != nil {
+		t.Error(err)
+	}
+
+	rsc.CacheConfig.CacheType = "redis"
+	rsc.CacheClient = &staleCache{Cache: rsc.CacheClient}
+
+	_, e := testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "kmiss"})
+	for _, err = range e {
+		t.Error(err)
+	}
+
+	// the cached object is expired, so the origin is requested again, but is unavailable
+	ts.Close()
+	_, e = testFetchOPC(r, http.StatusOK, "test", map[string]string{"status": "stale"})
+	for _, err = range e {
+		t.Error(err)
+	}
+}
+
 func TestObjectProxyCachePartialHit(t *testing.T) {
 	ts, _, r, rsc, err := setupTestHarnessOPCRange(nil)
 	if err != nil {
@@ -389,7 +413,7 @@ func TestObjectProxyCachePartialHitNotFresh(t *testing.T) {
 	cc := rsc.CacheClient
 	pr.cachingPolicy = GetRequestCachingPolicy(pr.Header)
 	pr.key = oc.Host + "." + pr.DeriveCacheKey(nil, "")
-	pr.cacheDocument, pr.cacheStatus, pr.neededRanges, _ = QueryCache(ctx, cc, pr.key, pr.wantedRanges)
+	pr.cacheDocument, pr.cacheStatus, pr.neededRanges, _ = QueryCache(ctx, cc, pr.key, pr.wantedRanges, false)
 	handleCacheKeyMiss(pr)
 
 	pr.cachingPolicy.CanRevalidate = false
@@ -424,7 +448,7 @@ func TestObjectProxyCachePartialHitFullResponse(t *testing.T) {
 	cc := rsc.CacheClient
 	pr.cachingPolicy = GetRequestCachingPolicy(pr.Header)
 	pr.key = oc.Host + "." + pr.DeriveCacheKey(nil, "")
-	pr.cacheDocument, pr.cacheStatus, pr.neededRanges, _ = QueryCache(ctx, cc, pr.key, pr.wantedRanges)
+	pr.cacheDocument, pr.cacheStatus, pr.neededRanges, _ = QueryCache(ctx, cc, pr.key, pr.wantedRanges, false)
 	handleCacheKeyMiss(pr)
 	handleCachePartialHit(pr)
 
@@ -0,0 +1,113 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Comcast/trickster/internal/cache"
+	cr "github.com/Comcast/trickster/internal/cache/registration"
+	"github.com/Comcast/trickster/internal/config"
+	"github.com/Comcast/trickster/internal/proxy/headers"
+	"github.com/Comcast/trickster/internal/routing"
+	"github.com/Comcast/trickster/internal/util/log"
+	"github.com/Comcast/trickster/internal/util/metrics"
+)
+
+// cacheHealthResponse is the JSON document returned by the Cache Health Handler
+type cacheHealthResponse struct {
+	Healthy bool                  `json:"healthy"`
+	Caches  []*cache.HealthStatus `json:"caches"`
+}
+
+// RegisterCacheHealthHandler registers the application's cache health handler
+func RegisterCacheHealthHandler() {
+	routing.Router.HandleFunc(config.Main.CacheHealthHandlerPath, cacheHealthHandler).Methods("GET")
+}
+
+// StartCacheHealthChecks runs the health check against each registered cache in the background,
+// at the configured interval, so that the cache up gauge is current without polling the handler
+func StartCacheHealthChecks() {
+	if config.Main.CacheHealthIntervalMS <= 0 {
+		return
+	}
+	interval := time.Duration(config.Main.CacheHealthIntervalMS) * time.Millisecond
+	go func() {
+		for {
+			checkCaches()
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// cacheHealthHandler runs a health check against each registered cache and responds with
+// the results as JSON. The response code is 200 OK if all caches are healthy, otherwise 503
+func cacheHealthHandler(w http.ResponseWriter, r *http.Request) {
+
+	resp := checkCaches()
+
+	b, _ := json.Marshal(resp)
+
+	w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+	w.Header().Set(headers.NameCacheControl, headers.ValueNoCache)
+	if resp.Healthy {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write(b)
+}
+
+// checkCaches runs a health check against each registered cache, updates the cache up gauge
+// with the results, and returns them sorted by cache name
+func checkCaches() *cacheHealthResponse {
+
+	timeout := time.Duration(config.Main.CacheHealthTimeoutMS) * time.Millisecond
+
+	resp := &cacheHealthResponse{Healthy: true, Caches: make([]*cache.HealthStatus, 0, len(cr.Caches))}
+	mtx := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for k, c := range cr.Caches {
+		wg.Add(1)
+		go func(name string, c cache.Cache) {
+			hs := cache.CheckHealth(name, c, timeout)
+			mtx.Lock()
+			resp.Caches = append(resp.Caches, hs)
+			mtx.Unlock()
+			wg.Done()
+		}(k, c)
+	}
+	wg.Wait()
+
+	sort.Slice(resp.Caches, func(i, j int) bool {
+		return resp.Caches[i].Name < resp.Caches[j].Name
+	})
+
+	for _, hs := range resp.Caches {
+		var up float64
+		if hs.Healthy {
+			up = 1
+		} else {
+			resp.Healthy = false
+			log.Error("cache health check failed", log.Pairs{"cacheName": hs.Name, "cacheType": hs.CacheType, "detail": hs.Error})
+		}
+		metrics.CacheUp.WithLabelValues(hs.Name, hs.CacheType).Set(up)
+	}
+
+	return resp
+}
@@ -0,0 +1,183 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Comcast/trickster/internal/cache"
+	cr "github.com/Comcast/trickster/internal/cache/registration"
+	"github.com/Comcast/trickster/internal/cache/status"
+	"github.com/Comcast/trickster/internal/config"
+	"github.com/Comcast/trickster/internal/util/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	metrics.Init()
+}
+
+// brokenCache is a cache that fails every Store, or hangs when delay is set
+type brokenCache struct {
+	delay time.Duration
+	cfg   *config.CachingConfig
+}
+
+func (c *brokenCache) Connect() error { return nil }
+func (c *brokenCache) Store(cacheKey string, data []byte, ttl time.Duration) error {
+	time.Sleep(c.delay)
+	return errors.New("connection refused")
+}
+func (c *brokenCache) Retrieve(cacheKey string, allowExpired bool) ([]byte, status.LookupStatus, error) {
+	return nil, status.LookupStatusError, cache.ErrKNF
+}
+func (c *brokenCache) SetTTL(cacheKey string, ttl time.Duration)  {}
+func (c *brokenCache) Remove(cacheKey string)                     {}
+func (c *brokenCache) BulkRemove(cacheKeys []string, noLock bool) {}
+func (c *brokenCache) Close() error                               { return nil }
+func (c *brokenCache) Configuration() *config.CachingConfig       { return c.cfg }
+
+func TestCacheHealthHandler(t *testing.T) {
+
+	config.Load("trickster-test", "test", []string{"-origin-url", "http://1.2.3.4", "-origin-type", "prometheus"})
+	config.Main.CacheHealthTimeoutMS = 100
+
+	RegisterCacheHealthHandler()
+
+	cr.Caches = map[string]cache.Cache{"default": cr.NewCache("default", config.Caches["default"])}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "http://0/trickster/health/caches", nil)
+
+	cacheHealthHandler(w, r)
+	resp := w.Result()
+
+	if resp.StatusCode != 200 {
+		t.Errorf("expected 200 got %d.", resp.StatusCode)
+	}
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Error(err)
+	}
+
+	chr := &cacheHealthResponse{}
+	err = json.Unmarshal(bodyBytes, chr)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if !chr.Healthy || len(chr.Caches) != 1 || !chr.Caches[0].Healthy {
+		t.Errorf("expected healthy response got %s", string(bodyBytes))
+	}
+
+	cr.Caches["failing"] = &brokenCache{cfg: &config.CachingConfig{CacheType: "redis"}}
+	cr.Caches["hanging"] = &brokenCache{delay: time.Second, cfg: &config.CachingConfig{CacheType: "redis"}}
+
+	w = httptest.NewRecorder()
+	cacheHealthHandler(w, r)
+	resp = w.Result()
+
+	if resp.StatusCode != 503 {
+		t.Errorf("expected 503 got %d.", resp.StatusCode)
+	}
+
+	bodyBytes, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Error(err)
+	}
+
+	chr = &cacheHealthResponse{}
+	err = json.Unmarshal(bodyBytes, chr)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if chr.Healthy || len(chr.Caches) != 3 {
+		t.Fatalf("expected unhealthy response got %s", string(bodyBytes))
+	}
+
+	// results are sorted by name
+	if chr.Caches[1].Name != "failing" || chr.Caches[1].Error != "connection refused" {
+		t.Errorf("expected failing cache error got %s", string(bodyBytes))
+	}
+
+	if chr.Caches[2].Name != "hanging" || chr.Caches[2].Error != cache.ErrHealthCheckTimeout.Error() {
+		t.Errorf("expected hanging cache timeout got %s", string(bodyBytes))
+	}
+
+	// a hanging cache is not checked again until its earlier round-trip completes
+	w = httptest.NewRecorder()
+	cacheHealthHandler(w, r)
+	bodyBytes, _ = ioutil.ReadAll(w.Result().Body)
+	chr = &cacheHealthResponse{}
+	err = json.Unmarshal(bodyBytes, chr)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(chr.Caches) != 3 || chr.Caches[2].Error != cache.ErrHealthCheckInProgress.Error() {
+		t.Errorf("expected hanging cache in progress got %s", string(bodyBytes))
+	}
+
+	cr.Caches = make(map[string]cache.Cache)
+}
+
+func TestCheckCaches(t *testing.T) {
+
+	config.Load("trickster-test", "test", []string{"-origin-url", "http://1.2.3.4", "-origin-type", "prometheus"})
+	config.Main.CacheHealthTimeoutMS = 100
+
+	cr.Caches = map[string]cache.Cache{
+		"default": cr.NewCache("default", config.Caches["default"]),
+		"failing": &brokenCache{cfg: &config.CachingConfig{CacheType: "redis"}},
+	}
+
+	resp := checkCaches()
+	if resp.Healthy || len(resp.Caches) != 2 {
+		t.Errorf("expected unhealthy response for %d caches got %v", 2, resp)
+	}
+
+	// the gauge is updated for each cache, without a request to the handler
+	expected := map[string]float64{"default": 1, "failing": 0}
+	mfs, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Error(err)
+	}
+	for _, mf := range mfs {
+		if mf.GetName() != "trickster_cache_up" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if v, ok := expected[l.GetValue()]; ok && l.GetName() == "cache_name" {
+					if m.GetGauge().GetValue() != v {
+						t.Errorf("expected %f for %s got %f", v, l.GetValue(), m.GetGauge().GetValue())
+					}
+					delete(expected, l.GetValue())
+				}
+			}
+		}
+	}
+	if len(expected) > 0 {
+		t.Errorf("expected gauges for %v", expected)
+	}
+
+	cr.Caches = make(map[string]cache.Cache)
+}
@@ -29,6 +29,10 @@ const (
 
 	// ValueApplicationJSON represents the HTTP Header Value of "application/json"
 	ValueApplicationJSON = "application/json"
+	// ValueApplicationFlux represents the HTTP Header Value of "application/vnd.flux"
+	ValueApplicationFlux = "application/vnd.flux"
+	// ValueApplicationNDJSON represents the HTTP Header Value of "application/x-ndjson"
+	ValueApplicationNDJSON = "application/x-ndjson"
 	// ValueMaxAge represents the HTTP Header Value of "max-age"
 	ValueMaxAge = "max-age"
 	// ValueMultipartFormData represents the HTTP Header Value of "multipart/form-data"
@@ -49,6 +53,8 @@ const (
 	ValuePublic = "public"
 	// ValueSharedMaxAge represents the HTTP Header Value of "s-maxage"
 	ValueSharedMaxAge = "s-maxage"
+	// ValueTextCSV represents the HTTP Header Value of "text/csv"
+	ValueTextCSV = "text/csv"
 	// ValueTextPlain represents the HTTP Header Value of "text/plain"
 	ValueTextPlain = "text/plain"
 	// ValueXFormURLEncoded represents the HTTP Header Value of "application/x-www-form-urlencoded"
@@ -79,6 +85,8 @@ const (
 	NameVia = "Via"
 	// NameXForwardedFor represents the HTTP Header Name of "X-Forwarded-For"
 	NameXForwardedFor = "X-Forwarded-For"
+	// NameAccept represents the HTTP Header Name of "Accept"
+	NameAccept = "Accept"
 	// NameAcceptEncoding represents the HTTP Header Name of "Accept-Encoding"
 	NameAcceptEncoding = "Accept-Encoding"
 	// NameSetCookie represents the HTTP Header Name of "Set-Cookie"
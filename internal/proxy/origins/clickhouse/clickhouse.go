@@ -17,15 +17,14 @@ package clickhouse
 import (
 	"net/http"
 	"net/url"
+	"strings"
 
 	"github.com/Comcast/trickster/internal/cache"
 	"github.com/Comcast/trickster/internal/config"
 	"github.com/Comcast/trickster/internal/proxy"
 	"github.com/Comcast/trickster/internal/proxy/errors"
-	tt "github.com/Comcast/trickster/internal/proxy/timeconv"
 	"github.com/Comcast/trickster/internal/proxy/urls"
 	"github.com/Comcast/trickster/internal/timeseries"
-	"github.com/Comcast/trickster/internal/util/regexp/matching"
 )
 
 // Client Implements the Proxy Client Interface
@@ -79,35 +78,33 @@ func (c *Client) ParseTimeRangeQuery(r *http.Request) (*timeseries.TimeRangeQuer
 	trq := &timeseries.TimeRangeQuery{Extent: timeseries.Extent{}}
 	trq.TemplateURL = urls.Clone(r.URL)
 	qi := trq.TemplateURL.Query()
-	if p, ok := qi[upQuery]; ok {
-		trq.Statement = p[0]
-	} else {
+
+	var err error
+	trq.Statement, err = requestStatement(r)
+	if err != nil {
+		return nil, err
+	}
+	if trq.Statement == "" {
 		return nil, errors.MissingURLParam(upQuery)
 	}
 
-	mp := []string{"step", "timeField"}
-	found := matching.GetNamedMatches(reTimeFieldAndStep, trq.Statement, mp)
-
-	for _, f := range mp {
-		v, ok := found[f]
-		if !ok || v == "" {
-			return nil, errors.ErrNotTimeRangeQuery
-		}
-		switch f {
-		case "timeField":
-			trq.TimestampFieldName = v
-		case "step":
-			trq.Step, _ = tt.ParseDuration(v + "s")
-		}
+	// only output formats that Trickster can parse and reproduce are accelerated
+	if _, ok := outputFormats[strings.ToLower(parseFormat(trq.Statement))]; !ok {
+		return nil, errors.ErrNotTimeRangeQuery
+	}
+
+	trq.TimestampFieldName, trq.Step, err = parseTimeFieldAndStep(trq.Statement)
+	if err != nil {
+		return nil, err
 	}
 
-	var err error
 	trq.Statement, trq.Extent, _, err = getQueryParts(trq.Statement, trq.TimestampFieldName)
 	if err != nil {
 		return nil, err
 	}
 
-	// Swap in the Tokenzed Query in the Url Params
+	// Swap in the Tokenzed Query in the Url Params, including statements sent in the POST
+	// body, so the cache key is derived from the statement regardless of how it was sent
 	qi.Set(upQuery, trq.Statement)
 	trq.TemplateURL.RawQuery = qi.Encode()
 	return trq, nil
@@ -16,10 +16,13 @@ package clickhouse
 import (
 	"net/http"
 	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	cr "github.com/Comcast/trickster/internal/cache/registration"
 	"github.com/Comcast/trickster/internal/config"
+	"github.com/Comcast/trickster/internal/proxy/errors"
 	"github.com/Comcast/trickster/internal/proxy/origins"
 	"github.com/Comcast/trickster/internal/util/metrics"
 )
@@ -190,4 +193,43 @@ func TestParseTimeRangeQuery(t *testing.T) {
 		t.Errorf("expected error for: %s", "not a time range query")
 	}
 
+	// queries produced by the Grafana ClickHouse plugin's $__timeInterval and $__timeFilter macros
+	req.URL.RawQuery = url.Values(map[string][]string{"query": {
+		`SELECT toStartOfInterval(toDateTime(ts), INTERVAL 300 second) AS time, count() AS cnt FROM tbl ` +
+			`WHERE ts >= toDateTime(1516665600) AND ts <= toDateTime(1516687200) GROUP BY time ORDER BY time FORMAT CSVWithNames`}}).Encode()
+	res, err = client.ParseTimeRangeQuery(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Step != 5*time.Minute || res.TimestampFieldName != "ts" || !res.Extent.End.Equal(time.Unix(1516687200, 0)) {
+		t.Errorf("unexpected time range query %s", res)
+	}
+	const expected = `SELECT toStartOfInterval(toDateTime(ts), INTERVAL 300 second) AS time, count() AS cnt FROM tbl ` +
+		`WHERE ts BETWEEN toDateTime(<$TIMESTAMP1$>) AND toDateTime(<$TIMESTAMP2$>) GROUP BY time ORDER BY time FORMAT CSVWithNames`
+	if res.Statement != expected || res.TemplateURL.Query().Get(upQuery) != expected {
+		t.Errorf("expected %s got %s", expected, res.Statement)
+	}
+
+	// statements sent in the POST body are tokenized into the template url
+	r, _ := http.NewRequest(http.MethodPost, "http://0/?database=db", strings.NewReader(
+		`SELECT toStartOfMinute(ts) AS t, count() AS cnt FROM tbl WHERE ts >= toDateTime(1516665600) FORMAT JSON`))
+	res, err = client.ParseTimeRangeQuery(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expectedBody = `SELECT toStartOfMinute(ts) AS t, count() AS cnt FROM tbl ` +
+		`WHERE ts BETWEEN toDateTime(<$TIMESTAMP1$>) AND toDateTime(<$TIMESTAMP2$>) FORMAT JSON`
+	if v := res.TemplateURL.Query(); v.Get(upQuery) != expectedBody || v.Get(upDatabase) != "db" {
+		t.Errorf("expected %s got %s", expectedBody, v.Get(upQuery))
+	}
+
+	// unsupported output formats are not accelerated
+	for _, format := range []string{" FORMAT Pretty", ""} {
+		req.URL.RawQuery = url.Values(map[string][]string{"query": {
+			`SELECT toStartOfMinute(ts) AS t, count() AS cnt FROM tbl WHERE ts >= toDateTime(1516665600)` + format}}).Encode()
+		if _, err = client.ParseTimeRangeQuery(req); err != errors.ErrNotTimeRangeQuery {
+			t.Errorf("expected %v got %v", errors.ErrNotTimeRangeQuery, err)
+		}
+	}
+
 }
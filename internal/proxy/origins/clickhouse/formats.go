@@ -0,0 +1,280 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package clickhouse
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Output Formats that can be accelerated
+const (
+	formatJSON         = "JSON"
+	formatJSONCompact  = "JSONCompact"
+	formatTSVWithNames = "TSVWithNames"
+	formatCSVWithNames = "CSVWithNames"
+)
+
+// outputFormats maps the lowercase names of the accelerated output formats, including aliases
+var outputFormats = map[string]string{
+	"json":                  formatJSON,
+	"jsoncompact":           formatJSONCompact,
+	"tsvwithnames":          formatTSVWithNames,
+	"tabseparatedwithnames": formatTSVWithNames,
+	"csvwithnames":          formatCSVWithNames,
+}
+
+// layouts of the text representations of ClickHouse Date, DateTime and DateTime64 values
+const (
+	layoutDate     = "2006-01-02"
+	layoutDateTime = "2006-01-02 15:04:05"
+)
+
+// parseTimestamp converts a time field value into a time.Time. Numeric values are epoch milliseconds,
+// while Date, DateTime and DateTime64 strings are interpreted in the provided location.
+func parseTimestamp(v interface{}, loc *time.Location) (time.Time, error) {
+	switch tv := v.(type) {
+	case float64:
+		return time.Unix(0, int64(tv)*int64(time.Millisecond)), nil
+	case string:
+		if t, err := msToTime(tv); err == nil {
+			return t, nil
+		}
+		layout := layoutDateTime
+		if len(tv) == len(layoutDate) {
+			layout = layoutDate
+		}
+		return time.ParseInLocation(layout, tv, loc)
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp value: %v", v)
+}
+
+// formatTimestamp returns the text representation of the time for the ClickHouse data type of the time field
+func formatTimestamp(t time.Time, typ string) string {
+	loc := timeLocation(typ)
+	switch {
+	case strings.HasPrefix(typ, "DateTime64"):
+		layout := layoutDateTime
+		if p := dateTime64Precision(typ); p > 0 {
+			layout += "." + strings.Repeat("0", p)
+		}
+		return t.In(loc).Format(layout)
+	case strings.HasPrefix(typ, "DateTime"):
+		return t.In(loc).Format(layoutDateTime)
+	case strings.HasPrefix(typ, "Date"):
+		return t.In(loc).Format(layoutDate)
+	}
+	return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+}
+
+// timeLocation returns the location named in a DateTime('tz') or DateTime64(p, 'tz') type,
+// or UTC when none is named or it cannot be loaded
+func timeLocation(typ string) *time.Location {
+	i := strings.Index(typ, "'")
+	j := strings.LastIndex(typ, "'")
+	if i >= 0 && j > i {
+		if loc, err := time.LoadLocation(typ[i+1 : j]); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+// dateTime64Precision returns the number of sub-second digits of a DateTime64(p) type
+func dateTime64Precision(typ string) int {
+	i := strings.Index(typ, "(")
+	if i < 0 {
+		return 0
+	}
+	p := strings.TrimSpace(strings.SplitN(strings.Trim(typ[i+1:], ")"), ",", 2)[0])
+	n, _ := strconv.Atoi(p)
+	return n
+}
+
+// inferTimeType returns the ClickHouse data type of a time field value in
+// a format that does not include types, like TSVWithNames
+func inferTimeType(v string) string {
+	if _, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return "UInt64"
+	}
+	if len(v) == len(layoutDate) {
+		return "Date"
+	}
+	if i := strings.Index(v, "."); i > 0 {
+		return fmt.Sprintf("DateTime64(%d)", len(v)-i-1)
+	}
+	return "DateTime"
+}
+
+// marshalFormat returns the ResultsEnvelope in its output format
+func (re *ResultsEnvelope) marshalFormat() ([]byte, error) {
+
+	rsp, err := re.response()
+	if err != nil {
+		return nil, err
+	}
+
+	switch re.Format {
+	case formatJSONCompact:
+		return rsp.marshalCompact()
+	case formatTSVWithNames:
+		return rsp.marshalTSV(), nil
+	case formatCSVWithNames:
+		return rsp.marshalCSV()
+	}
+	return json.Marshal(rsp)
+}
+
+// marshalCompact returns the Response in the JSONCompact format
+func (rsp *Response) marshalCompact() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteString(`{"meta":`)
+	meta, _ := json.Marshal(rsp.Meta)
+	buf.Write(meta)
+	buf.WriteString(`,"data":[`)
+	for i, rd := range rsp.RawData {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		row := make([]interface{}, len(rsp.Order))
+		for j, k := range rsp.Order {
+			row[j] = rd[k]
+		}
+		b, err := json.Marshal(row)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+	}
+	buf.WriteString(fmt.Sprintf(`],"rows":%d}`, rsp.Rows))
+	return buf.Bytes(), nil
+}
+
+// tsvEscaper escapes values for the TabSeparated formats
+var tsvEscaper = strings.NewReplacer("\\", "\\\\", "\t", "\\t", "\n", "\\n")
+
+// tsvUnescaper reverses tsvEscaper
+var tsvUnescaper = strings.NewReplacer("\\\\", "\\", "\\t", "\t", "\\n", "\n")
+
+// marshalTSV returns the Response in the TSVWithNames format
+func (rsp *Response) marshalTSV() []byte {
+	buf := &bytes.Buffer{}
+	for i, k := range rsp.Order {
+		if i > 0 {
+			buf.WriteString("\t")
+		}
+		buf.WriteString(tsvEscaper.Replace(k))
+	}
+	buf.WriteString("\n")
+	for _, rd := range rsp.RawData {
+		for i, k := range rsp.Order {
+			if i > 0 {
+				buf.WriteString("\t")
+			}
+			buf.WriteString(tsvEscaper.Replace(textValue(rd[k])))
+		}
+		buf.WriteString("\n")
+	}
+	return buf.Bytes()
+}
+
+// marshalCSV returns the Response in the CSVWithNames format
+func (rsp *Response) marshalCSV() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	w.Write(rsp.Order)
+	for _, rd := range rsp.RawData {
+		rec := make([]string, len(rsp.Order))
+		for i, k := range rsp.Order {
+			rec[i] = textValue(rd[k])
+		}
+		w.Write(rec)
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// textValue returns the text representation of a row value
+func textValue(v interface{}) string {
+	switch tv := v.(type) {
+	case string:
+		return tv
+	case float64:
+		return strconv.FormatFloat(tv, 'f', -1, 64)
+	case nil:
+		return "\\N"
+	}
+	return fmt.Sprint(v)
+}
+
+// unmarshalDelimited populates the ResultsEnvelope from a TSVWithNames or CSVWithNames document
+func (re *ResultsEnvelope) unmarshalDelimited(b []byte) error {
+
+	header := b
+	if i := bytes.IndexByte(b, '\n'); i >= 0 {
+		header = b[:i]
+	}
+
+	var records [][]string
+	// the header line of names is tab-delimited in TSVWithNames, and comma-delimited in CSVWithNames
+	if bytes.IndexByte(header, '\t') >= 0 {
+		re.Format = formatTSVWithNames
+		for _, line := range strings.Split(string(b), "\n") {
+			fields := strings.Split(line, "\t")
+			for i := range fields {
+				fields[i] = tsvUnescaper.Replace(fields[i])
+			}
+			records = append(records, fields)
+		}
+	} else {
+		re.Format = formatCSVWithNames
+		var err error
+		if records, err = csv.NewReader(bytes.NewReader(b)).ReadAll(); err != nil {
+			return err
+		}
+	}
+
+	if len(records[0]) < 2 {
+		return fmt.Errorf("Must have at least two fields; only have %d", len(records[0]))
+	}
+
+	meta := make([]FieldDefinition, len(records[0]))
+	for i, name := range records[0] {
+		meta[i] = FieldDefinition{Name: name, Type: "String"}
+	}
+	meta[1].Type = "Float64"
+	if len(records) > 1 {
+		meta[0].Type = inferTimeType(records[1][0])
+	}
+
+	rows := make([]ResponseValue, 0, len(records)-1)
+	for _, rec := range records[1:] {
+		if len(rec) != len(meta) {
+			return fmt.Errorf("expected %d fields got %d", len(meta), len(rec))
+		}
+		rv := make(ResponseValue, len(rec))
+		for i, v := range rec {
+			rv[meta[i].Name] = v
+		}
+		rows = append(rows, rv)
+	}
+
+	re.loadRows(meta, rows)
+	return nil
+}
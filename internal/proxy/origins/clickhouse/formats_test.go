@@ -0,0 +1,170 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package clickhouse
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+const testTSV = "t\tcnt\thost\n" +
+	"2018-01-23 00:00:00\t12\ta\\tb\n" +
+	"2018-01-23 00:01:00\t13\tc\\td\n"
+
+const testCSV = "\"t\",\"cnt\",\"host\"\n" +
+	"\"2018-01-23 00:00:00.250\",12.5,\"a,b\"\n" +
+	"\"2018-01-23 00:01:00.250\",13,\"c\"\n"
+
+const testJSONCompact = `{"meta":[{"name":"t","type":"DateTime('UTC')"},{"name":"cnt","type":"UInt64"},{"name":"host","type":"String"}],` +
+	`"data":[["2018-01-23 00:00:00","12","a"],["2018-01-23 00:01:00","13","c"]],"rows":2,"statistics":{"elapsed":0.001}}`
+
+func TestFormatRoundTrip(t *testing.T) {
+
+	client := &Client{}
+
+	tests := []struct {
+		name, input, expected, format string
+		start                         time.Time
+	}{
+		{"tsv", testTSV, testTSV, formatTSVWithNames, time.Date(2018, 1, 23, 0, 0, 0, 0, time.UTC)},
+		{"csv", testCSV, "t,cnt,host\n2018-01-23 00:00:00.250,12.5,\"a,b\"\n2018-01-23 00:01:00.250,13,c\n",
+			formatCSVWithNames, time.Date(2018, 1, 23, 0, 0, 0, 250000000, time.UTC)},
+		{"compact", testJSONCompact, `{"meta":[{"name":"t","type":"DateTime('UTC')"},{"name":"cnt","type":"UInt64"},` +
+			`{"name":"host","type":"String"}],"data":[["2018-01-23 00:00:00","12","a"],["2018-01-23 00:01:00","13","c"]],"rows":2}`,
+			formatJSONCompact, time.Date(2018, 1, 23, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ts, err := client.UnmarshalTimeseries([]byte(test.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			re := ts.(*ResultsEnvelope)
+			if re.Format != test.format {
+				t.Errorf("expected %s got %s", test.format, re.Format)
+			}
+			if re.SeriesCount() != 2 || re.ValueCount() != 2 {
+				t.Errorf("expected 2 series got %d", re.SeriesCount())
+			}
+
+			re.SetExtents(timeseries.ExtentList{{Start: test.start, End: test.start.Add(time.Minute)}})
+			re.SetStep(time.Minute)
+
+			// the cached envelope is JSON, which preserves the format
+			b, err := client.MarshalTimeseries(re)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ts, err = client.UnmarshalTimeseries(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			re = ts.(*ResultsEnvelope)
+			if re.Format != test.format || len(re.ExtentList) != 1 {
+				t.Errorf("expected %s got %s", test.format, re.Format)
+			}
+			if x := re.Extents(); !x[0].Start.Equal(test.start) {
+				t.Errorf("expected %s got %s", test.start, x[0].Start)
+			}
+
+			// client responses are in the query's format
+			re.SetExtents(nil)
+			re.SetStep(0)
+			b, err = client.MarshalTimeseries(re)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != test.expected {
+				t.Errorf("expected\n%s\ngot\n%s", test.expected, string(b))
+			}
+		})
+	}
+
+	if _, err := client.UnmarshalTimeseries([]byte("t\n1")); err == nil {
+		t.Error("expected error for single column")
+	}
+
+	if _, err := client.UnmarshalTimeseries([]byte("t\tcnt\n1\t2\t3")); err == nil {
+		t.Error("expected error for mismatched columns")
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		v        interface{}
+		loc      *time.Location
+		expected time.Time
+	}{
+		{"1516665600000", time.UTC, time.Unix(1516665600, 0)},
+		{float64(1516665600000), time.UTC, time.Unix(1516665600, 0)},
+		{"2018-01-23 00:00:00", time.UTC, time.Unix(1516665600, 0)},
+		{"2018-01-23 01:00:00", berlin, time.Unix(1516665600, 0)},
+		{"2018-01-23 00:00:00.5", time.UTC, time.Unix(1516665600, 500000000)},
+		{"2018-01-23", time.UTC, time.Unix(1516665600, 0)},
+	}
+
+	for i, test := range tests {
+		ts, err := parseTimestamp(test.v, test.loc)
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+		} else if !ts.Equal(test.expected) {
+			t.Errorf("test %d: expected %s got %s", i, test.expected, ts)
+		}
+	}
+
+	if _, err := parseTimestamp(true, time.UTC); err == nil {
+		t.Error("expected error for invalid timestamp")
+	}
+}
+
+func TestFormatTimestamp(t *testing.T) {
+	ts := time.Unix(1516665600, 123000000)
+	tests := map[string]string{
+		"UInt64":                            "1516665600123",
+		"DateTime":                          "2018-01-23 00:00:00",
+		"DateTime('Europe/Berlin')":         "2018-01-23 01:00:00",
+		"DateTime64(3)":                     "2018-01-23 00:00:00.123",
+		"DateTime64(6, 'UTC')":              "2018-01-23 00:00:00.123000",
+		"Date":                              "2018-01-23",
+		"DateTime64(3, 'Invalid/Location')": "2018-01-23 00:00:00.123",
+	}
+	for typ, expected := range tests {
+		if s := formatTimestamp(ts, typ); s != expected {
+			t.Errorf("%s: expected %s got %s", typ, expected, s)
+		}
+	}
+}
+
+func TestInferTimeType(t *testing.T) {
+	tests := map[string]string{
+		"1516665600000":           "UInt64",
+		"2018-01-23":              "Date",
+		"2018-01-23 00:00:00":     "DateTime",
+		"2018-01-23 00:00:00.250": "DateTime64(3)",
+	}
+	for v, expected := range tests {
+		if typ := inferTimeType(v); typ != expected {
+			t.Errorf("expected %s got %s", expected, typ)
+		}
+	}
+}
@@ -23,10 +23,15 @@ import (
 // QueryHandler handles timeseries requests for ClickHouse and processes them through the delta proxy cache
 func (c *Client) QueryHandler(w http.ResponseWriter, r *http.Request) {
 
-	rqlc := strings.Replace(strings.ToLower(r.URL.RawQuery), "%20", "+", -1)
-	// if it's not a select statement, just proxy it instead
-	if (!strings.HasPrefix(rqlc, "query=select+")) && (!(strings.Index(rqlc, "&query=select+") > 0)) &&
-		(!strings.HasSuffix(rqlc, "format+json")) {
+	// if it's not a select statement, just proxy it instead. Select statements that
+	// are not time range queries in a supported format are proxied by the engine.
+	// The body is only inspected when the query parameter doesn't rule out a select,
+	// so that large insert bodies are not buffered.
+	if q := r.URL.Query().Get(upQuery); q != "" && !isSelect(q) {
+		c.ProxyHandler(w, r)
+		return
+	}
+	if q, err := requestStatement(r); err != nil || !isSelect(q) {
 		c.ProxyHandler(w, r)
 		return
 	}
@@ -34,3 +39,8 @@ func (c *Client) QueryHandler(w http.ResponseWriter, r *http.Request) {
 	r.URL = c.BuildUpstreamURL(r)
 	engines.DeltaProxyCacheRequest(w, r)
 }
+
+// isSelect returns true if the statement is a select query
+func isSelect(stmt string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(stmt)), "select")
+}
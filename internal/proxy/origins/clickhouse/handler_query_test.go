@@ -14,11 +14,14 @@
 package clickhouse
 
 import (
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Comcast/trickster/internal/proxy/request"
 	tu "github.com/Comcast/trickster/internal/util/testing"
@@ -100,3 +103,91 @@ func TestQueryHandler(t *testing.T) {
 	}
 
 }
+
+func TestQueryHandlerCSV(t *testing.T) {
+
+	// use recent times so the request falls within the default timeseries retention
+	start := time.Now().UTC().Truncate(time.Minute).Add(-10 * time.Minute)
+	end := start.Add(2 * time.Minute)
+	t1, t2 := start.Format(layoutDateTime), start.Add(time.Minute).Format(layoutDateTime)
+
+	body := fmt.Sprintf("\"t\",\"cnt\"\n\"%s\",12\n\"%s\",13\n", t1, t2)
+	query := url.Values(map[string][]string{"query": {fmt.Sprintf(
+		`SELECT toStartOfMinute(ts) AS t, count() AS cnt FROM tbl `+
+			`WHERE ts >= toDateTime(%d) AND ts < toDateTime(%d) GROUP BY t ORDER BY t FORMAT CSVWithNames`,
+		start.Unix(), end.Unix())}}).Encode()
+
+	client := &Client{name: "test"}
+	ts, _, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, body, nil, "clickhouse", "/?"+query, "debug")
+	ctx := r.Context()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	defer ts.Close()
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := fmt.Sprintf("t,cnt\n%s,12\n%s,13\n", t1, t2)
+	for i, status := range []string{"status=kmiss", "status=hit"} {
+		r, _ = http.NewRequest(http.MethodGet, ts.URL+"/?"+query, nil)
+		w := httptest.NewRecorder()
+		client.QueryHandler(w, r.WithContext(ctx))
+		resp := w.Result()
+		if resp.StatusCode != 200 {
+			t.Errorf("test %d expected 200 got %d.", i, resp.StatusCode)
+		}
+		if h := resp.Header.Get("X-Trickster-Result"); !strings.Contains(h, "engine=DeltaProxyCache") || !strings.Contains(h, status) {
+			t.Errorf("test %d expected %s got %s", i, status, h)
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		if string(b) != expected {
+			t.Errorf("test %d expected %s got %s", i, expected, string(b))
+		}
+	}
+}
+
+func TestQueryHandlerPostBody(t *testing.T) {
+
+	start := time.Now().UTC().Truncate(time.Minute).Add(-10 * time.Minute)
+	end := start.Add(2 * time.Minute)
+	body := fmt.Sprintf("{\"meta\":[{\"name\":\"t\",\"type\":\"DateTime\"},{\"name\":\"cnt\",\"type\":\"UInt64\"}],"+
+		"\"data\":[{\"t\":\"%s\",\"cnt\":\"12\"}],\"rows\":1}", start.Format(layoutDateTime))
+	stmt := fmt.Sprintf(`SELECT toStartOfMinute(ts) AS t, count() AS cnt FROM tbl `+
+		`WHERE ts >= toDateTime(%d) AND ts < toDateTime(%d) GROUP BY t ORDER BY t FORMAT JSON`, start.Unix(), end.Unix())
+
+	client := &Client{name: "test"}
+	ts, _, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, body, nil, "clickhouse", "/?database=db", "debug")
+	ctx := r.Context()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	defer ts.Close()
+	if err != nil {
+		t.Error(err)
+	}
+
+	// the statement is sent in the POST body, and the cache key is derived from it
+	r, _ = http.NewRequest(http.MethodPost, ts.URL+"/?database=db", strings.NewReader(stmt))
+	r2, _ := http.NewRequest(http.MethodPost, ts.URL+"/?database=db", strings.NewReader(stmt))
+	r3, _ := http.NewRequest(http.MethodPost, ts.URL+"/?database=db2&max_execution_time=5", strings.NewReader(stmt))
+
+	for i, test := range []struct {
+		r      *http.Request
+		status string
+	}{{r, "status=kmiss"}, {r2, "status=hit"}, {r3, "status=kmiss"}} {
+		w := httptest.NewRecorder()
+		client.QueryHandler(w, test.r.WithContext(ctx))
+		resp := w.Result()
+		if resp.StatusCode != 200 {
+			t.Errorf("test %d expected 200 got %d.", i, resp.StatusCode)
+		}
+		if h := resp.Header.Get("X-Trickster-Result"); !strings.Contains(h, "engine=DeltaProxyCache") || !strings.Contains(h, test.status) {
+			t.Errorf("test %d expected %s got %s", i, test.status, h)
+		}
+	}
+}
@@ -72,6 +72,7 @@ type Response struct {
 	Order        []string              `json:"-"`
 	StepDuration time.Duration         `json:"step,omitempty"`
 	ExtentList   timeseries.ExtentList `json:"extents,omitempty"`
+	Format       string                `json:"format,omitempty"`
 }
 
 // ResultsEnvelope is the ClickHouse document structure optimized for time series manipulation
@@ -82,6 +83,8 @@ type ResultsEnvelope struct {
 	ExtentList   timeseries.ExtentList        `json:"extents,omitempty"`
 	Serializers  map[string]func(interface{}) `json:"-"`
 	SeriesOrder  []string                     `json:"series_order,omitempty"`
+	// Format is the output format of the query results, when other than JSON
+	Format string `json:"format,omitempty"`
 
 	timestamps map[time.Time]bool // tracks unique timestamps in the matrix data
 	tslist     times.Times
@@ -91,21 +94,37 @@ type ResultsEnvelope struct {
 
 // MarshalTimeseries converts a Timeseries into a JSON blob
 func (c *Client) MarshalTimeseries(ts timeseries.Timeseries) ([]byte, error) {
+	re := ts.(*ResultsEnvelope)
+	// client responses are marshaled in the query's output format, while
+	// cached envelopes are always JSON so they can carry the extents
+	if re.Format != "" && re.Format != formatJSON && len(re.ExtentList) == 0 && re.StepDuration == 0 {
+		return re.marshalFormat()
+	}
 	// Marshal the Envelope back to a json object for Cache Storage
-	return json.Marshal(ts.(*ResultsEnvelope))
+	return json.Marshal(re)
 }
 
 // UnmarshalTimeseries converts a JSON blob into a Timeseries
 func (c *Client) UnmarshalTimeseries(data []byte) (timeseries.Timeseries, error) {
 	re := &ResultsEnvelope{}
+	if b := bytes.TrimSpace(data); len(b) > 0 && b[0] != '{' {
+		err := re.unmarshalDelimited(b)
+		return re, err
+	}
 	err := json.Unmarshal(data, re)
 	return re, err
 }
 
 // Parts ...
 func (rv ResponseValue) Parts(timeKey, valKey string) (string, time.Time, float64, ResponseValue) {
+	return rv.partsIn(timeKey, valKey, time.UTC)
+}
 
-	if len(rv) < 3 {
+// partsIn returns the Parts of the ResponseValue, with any date or time strings
+// in the time field interpreted in the provided location
+func (rv ResponseValue) partsIn(timeKey, valKey string, loc *time.Location) (string, time.Time, float64, ResponseValue) {
+
+	if len(rv) < 2 {
 		return noParts()
 	}
 
@@ -119,7 +138,7 @@ func (rv ResponseValue) Parts(timeKey, valKey string) (string, time.Time, float6
 	for k, v := range rv {
 		switch k {
 		case timeKey:
-			t, err = msToTime(v.(string))
+			t, err = parseTimestamp(v, loc)
 			if err != nil {
 				return noParts()
 			}
@@ -128,7 +147,11 @@ func (rv ResponseValue) Parts(timeKey, valKey string) (string, time.Time, float6
 				val = av
 				continue
 			}
-			val, err = strconv.ParseFloat(v.(string), 64)
+			sv, ok := v.(string)
+			if !ok {
+				return noParts()
+			}
+			val, err = strconv.ParseFloat(sv, 64)
 			if err != nil {
 				return noParts()
 			}
@@ -148,6 +171,22 @@ func noParts() (string, time.Time, float64, ResponseValue) {
 // MarshalJSON ...
 func (re ResultsEnvelope) MarshalJSON() ([]byte, error) {
 
+	rsp, err := re.response()
+	if err != nil {
+		return nil, err
+	}
+
+	bytes, err := json.Marshal(rsp)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes, nil
+}
+
+// response returns the Response document for the ResultsEnvelope, with rows in timestamp order
+func (re *ResultsEnvelope) response() (*Response, error) {
+
 	if len(re.Meta) < 2 {
 		return nil, fmt.Errorf("Must have at least two fields; only have %d", len(re.Meta))
 	}
@@ -167,6 +206,7 @@ func (re ResultsEnvelope) MarshalJSON() ([]byte, error) {
 		Rows:         re.ValueCount(),
 		StepDuration: re.StepDuration,
 		ExtentList:   re.ExtentList,
+		Format:       re.Format,
 	}
 
 	rsp.Order = make([]string, 0, len(re.Meta))
@@ -177,6 +217,7 @@ func (re ResultsEnvelope) MarshalJSON() ([]byte, error) {
 	// Assume the first item in the meta array is the time, and the second is the value
 	timestampFieldName := rsp.Order[0]
 	valueFieldName := rsp.Order[1]
+	timestampType := re.Meta[0].Type
 
 	tm := make(map[time.Time][]ResponseValue)
 	tl := make(times.Times, 0, mpl)
@@ -197,7 +238,7 @@ func (re ResultsEnvelope) MarshalJSON() ([]byte, error) {
 			}
 
 			r := ResponseValue{
-				timestampFieldName: strconv.FormatInt(p.Timestamp.UnixNano()/int64(time.Millisecond), 10),
+				timestampFieldName: formatTimestamp(p.Timestamp, timestampType),
 				valueFieldName:     strconv.FormatFloat(p.Value, 'f', -1, 64),
 			}
 			for k2, v2 := range ds.Metric {
@@ -221,12 +262,7 @@ func (re ResultsEnvelope) MarshalJSON() ([]byte, error) {
 		rsp.RawData = append(rsp.RawData, tm[t]...)
 	}
 
-	bytes, err := json.Marshal(rsp)
-	if err != nil {
-		return nil, err
-	}
-
-	return bytes, nil
+	return rsp, nil
 }
 
 // MarshalJSON ...
@@ -244,6 +280,10 @@ func (rsp *Response) MarshalJSON() ([]byte, error) {
 	buf.WriteString(strings.Join(d, ",") + "]")
 	buf.WriteString(fmt.Sprintf(`,"rows": %d`, rsp.Rows))
 
+	if rsp.Format != "" && rsp.Format != formatJSON {
+		buf.WriteString(fmt.Sprintf(`,"format": %q`, rsp.Format))
+	}
+
 	if rsp.ExtentList != nil && len(rsp.ExtentList) > 0 {
 		el, _ := json.Marshal(rsp.ExtentList)
 		buf.WriteString(fmt.Sprintf(`,"extents": %s`, string(el)))
@@ -279,7 +319,13 @@ func (rv ResponseValue) ToJSON(order []string) []byte {
 // UnmarshalJSON ...
 func (re *ResultsEnvelope) UnmarshalJSON(b []byte) error {
 
-	response := Response{}
+	response := struct {
+		Meta         []FieldDefinition     `json:"meta"`
+		RawData      []json.RawMessage     `json:"data"`
+		StepDuration time.Duration         `json:"step"`
+		ExtentList   timeseries.ExtentList `json:"extents"`
+		Format       string                `json:"format"`
+	}{}
 	err := json.Unmarshal(b, &response)
 	if err != nil {
 		return err
@@ -289,21 +335,54 @@ func (re *ResultsEnvelope) UnmarshalJSON(b []byte) error {
 		return fmt.Errorf("Must have at least two fields; only have %d", len(response.Meta))
 	}
 
-	re.Meta = response.Meta
 	re.ExtentList = response.ExtentList
 	re.StepDuration = response.StepDuration
+	re.Format = response.Format
+
+	// rows are objects in the JSON format, and arrays in the JSONCompact format
+	rows := make([]ResponseValue, 0, len(response.RawData))
+	for _, raw := range response.RawData {
+		rv := make(ResponseValue)
+		if d := bytes.TrimSpace(raw); len(d) > 0 && d[0] == '[' {
+			var values []interface{}
+			if err := json.Unmarshal(d, &values); err != nil {
+				return err
+			}
+			for i, v := range values {
+				if i < len(response.Meta) {
+					rv[response.Meta[i].Name] = v
+				}
+			}
+			if re.Format == "" {
+				re.Format = formatJSONCompact
+			}
+		} else if err := json.Unmarshal(d, &rv); err != nil {
+			return err
+		}
+		rows = append(rows, rv)
+	}
+
+	re.loadRows(response.Meta, rows)
+	return nil
+}
+
+// loadRows populates the ResultsEnvelope's series from the rows of a query result
+func (re *ResultsEnvelope) loadRows(meta []FieldDefinition, rows []ResponseValue) {
+
+	re.Meta = meta
 	re.SeriesOrder = make([]string, 0)
 
 	// Assume the first item in the meta array is the time field, and the second is the value field
-	timestampFieldName := response.Meta[0].Name
-	valueFieldName := response.Meta[1].Name
+	timestampFieldName := meta[0].Name
+	valueFieldName := meta[1].Name
+	loc := timeLocation(meta[0].Type)
 
 	registeredMetrics := make(map[string]bool)
 
 	re.Data = make(map[string]*DataSet)
-	l := len(response.RawData)
-	for _, v := range response.RawData {
-		metric, ts, val, meta := v.Parts(timestampFieldName, valueFieldName)
+	l := len(rows)
+	for _, v := range rows {
+		metric, ts, val, m := v.partsIn(timestampFieldName, valueFieldName, loc)
 		if _, ok := registeredMetrics[metric]; !ok {
 			registeredMetrics[metric] = true
 			re.SeriesOrder = append(re.SeriesOrder, metric)
@@ -311,14 +390,12 @@ func (re *ResultsEnvelope) UnmarshalJSON(b []byte) error {
 		if !ts.IsZero() {
 			a, ok := re.Data[metric]
 			if !ok {
-				a = &DataSet{Metric: meta, Points: make([]Point, 0, l)}
+				a = &DataSet{Metric: m, Points: make([]Point, 0, l)}
 			}
 			a.Points = append(a.Points, Point{Timestamp: ts, Value: val})
 			re.Data[metric] = a
 		}
 	}
-
-	return nil
 }
 
 // Len returns the length of a slice of time series data points
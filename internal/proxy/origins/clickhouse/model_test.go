@@ -55,10 +55,14 @@ func TestParts(t *testing.T) {
 		"cnt": "27",
 	}
 
-	metric, _, _, _ = rv2.Parts("t", "cnt")
+	// a time and value with no other fields is a single, unlabeled series
+	metric, ts, _, _ = rv2.Parts("t", "cnt")
 	if metric != "{}" {
 		t.Errorf("expected '{}' got %s", metric)
 	}
+	if ts != expectedTs {
+		t.Errorf("expected %d got %d", expectedTs.Unix(), ts.Unix())
+	}
 
 	rv3 := ResponseValue{
 		"t":     "A557766080000",
@@ -46,7 +46,7 @@ func (c *Client) DefaultPathConfigs(oc *config.OriginConfig) map[string]*config.
 			Methods:        []string{http.MethodGet, http.MethodPost},
 			MatchType:      config.PathMatchTypePrefix,
 			MatchTypeName:  "prefix",
-			CacheKeyParams: []string{"query", "database"},
+			CacheKeyParams: []string{upQuery, upDatabase, upUser, upSessionTimezone},
 			OriginConfig:   oc,
 		},
 	}
@@ -120,6 +120,7 @@ func (re *ResultsEnvelope) Clone() timeseries.Timeseries {
 		isCounted:    re.isCounted,
 		isSorted:     re.isSorted,
 		StepDuration: re.StepDuration,
+		Format:       re.Format,
 	}
 
 	wg := sync.WaitGroup{}
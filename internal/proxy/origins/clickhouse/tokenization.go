@@ -15,11 +15,14 @@ package clickhouse
 
 import (
 	"fmt"
+	"math"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/Comcast/trickster/internal/proxy/errors"
 	"github.com/Comcast/trickster/internal/timeseries"
 	"github.com/Comcast/trickster/internal/util/regexp/matching"
 )
@@ -31,108 +34,258 @@ import (
 const (
 	tkTimestamp1 = "<$TIMESTAMP1$>"
 	tkTimestamp2 = "<$TIMESTAMP2$>"
+	// tokens for millisecond-precision timestamps, used by DateTime64 literals
+	tkTimestamp1MS      = "<$TIMESTAMP1_MS$>"
+	tkTimestamp2MS      = "<$TIMESTAMP2_MS$>"
+	tkTimestamp1Decimal = "<$TIMESTAMP1_DECIMAL$>"
+	tkTimestamp2Decimal = "<$TIMESTAMP2_DECIMAL$>"
 )
 
-var reTimeFieldAndStep, reTimeClauseAlt *regexp.Regexp
+// timeLiteral is the regular expression for a function call that converts a number to a time,
+// such as toDateTime(1516665600), toDateTime64(1516665600.123, 3) or fromUnixTimestamp64Milli(1516665600123)
+const timeLiteral = `\b(?:todatetime64|todatetime|todate|fromunixtimestamp64milli)\s*\(\s*[0-9]+(?:\.[0-9]+)?\s*(?:,[^()]*)?\)`
+
+// timeClauseRe matches a comparison of the time field against one or two time literals
+const timeClauseRe = `(?i)(?P<conjunction>\b(?:prewhere|where|and))\s+(?P<expression>#TIME_FIELD#\s*` +
+	`(?P<operator>>=|<=|>|<|=|\bbetween\b)\s*(?P<lit1>` + timeLiteral + `)(?:\s+and\s+(?P<lit2>` + timeLiteral + `))?)`
+
+var reTimeFieldAndStep, reTimeLiteral, reFormat *regexp.Regexp
+var reTimeBuckets []*regexp.Regexp
 
 func init() {
 	reTimeFieldAndStep = regexp.MustCompile(`(?i)select\s+\(\s*intdiv\s*\(\s*touint32\s*\(\s*(?P<timeField>[a-zA-Z0-9\._-]+)\s*\)\s*,\s*(?P<step>[0-9]+)\s*\)\s*\*\s*[0-9]+\s*\)`)
-	reTimeClauseAlt = regexp.MustCompile(`(?i)\s+(?P<expression>(?P<operator>>=|>|=|between)\s+(?P<modifier>toDate(Time)?)\((?P<ts1>[0-9]+)\)(?P<timeExpr2>\s+and\s+toDate(Time)?\((?P<ts2>[0-9]+)\))?)`)
+	reTimeBuckets = []*regexp.Regexp{
+		reTimeFieldAndStep,
+		// toStartOfInterval(field, INTERVAL 5 minute), as produced by Grafana's $__timeInterval macro
+		regexp.MustCompile(`(?i)select\s+tostartofinterval\s*\(\s*(?:todatetime(?:64)?\s*\(\s*)?(?P<timeField>[a-zA-Z0-9\._-]+)` +
+			`(?:\s*,\s*[0-9]+)?\s*\)?\s*,\s*interval\s+(?P<step>[0-9]+)\s+(?P<unit>second|minute|hour|day)s?\s*(?:,\s*'[^']*'\s*)?\)`),
+		// toStartOfMinute(field), toStartOfHour(field), etc.
+		regexp.MustCompile(`(?i)select\s+tostartof(?P<unit>minute|fiveminutes?|tenminutes|fifteenminutes|hour|day)\s*\(\s*` +
+			`(?:todatetime(?:64)?\s*\(\s*)?(?P<timeField>[a-zA-Z0-9\._-]+)`),
+	}
+	reTimeLiteral = regexp.MustCompile(`(?i)` + timeLiteral)
+	reFormat = regexp.MustCompile(`(?i)\s+format\s+(?P<format>[a-z]+)\s*;?\s*$`)
 }
 
-func interpolateTimeQuery(template, timeField string, extent *timeseries.Extent) string {
-	return strings.Replace(strings.Replace(template, tkTimestamp1, strconv.Itoa(int(extent.Start.Unix())), -1), tkTimestamp2, strconv.Itoa(int(extent.End.Unix())), -1)
+// bucketUnits maps the time units of ClickHouse bucketing functions to their durations
+var bucketUnits = map[string]time.Duration{
+	"second":         time.Second,
+	"minute":         time.Minute,
+	"fiveminute":     5 * time.Minute,
+	"fiveminutes":    5 * time.Minute,
+	"tenminutes":     10 * time.Minute,
+	"fifteenminutes": 15 * time.Minute,
+	"hour":           time.Hour,
+	"day":            24 * time.Hour,
 }
 
-var compiledRe = make(map[string]*regexp.Regexp)
+// parseTimeFieldAndStep returns the name of the time field and the step of the
+// time bucket in the first column of a SELECT statement
+func parseTimeFieldAndStep(query string) (string, time.Duration, error) {
+	for _, re := range reTimeBuckets {
+		found := matching.GetNamedMatches(re, query, nil)
+		field := found["timeField"]
+		if field == "" {
+			continue
+		}
+		unit := time.Second
+		if u, ok := found["unit"]; ok && u != "" {
+			unit = bucketUnits[strings.ToLower(u)]
+		}
+		n := 1
+		if s, ok := found["step"]; ok && s != "" {
+			n, _ = strconv.Atoi(s)
+		}
+		if n <= 0 {
+			return "", 0, errors.ErrNotTimeRangeQuery
+		}
+		return field, time.Duration(n) * unit, nil
+	}
+	return "", 0, errors.ErrNotTimeRangeQuery
+}
 
-const timeClauseRe = `(?i)(?P<conjunction>where|and)\s+#TIME_FIELD#\s+(?P<timeExpr1>(?P<operator>>=|>|=|between)\s+(?P<modifier>toDate(Time)?)\((?P<ts1>[0-9]+)\))(?P<timeExpr2>\s+and\s+toDate(Time)?\((?P<ts2>[0-9]+)\))?`
+// parseFormat returns the output format named in the FORMAT clause of the query
+func parseFormat(query string) string {
+	f, _ := matching.GetNamedMatch("format", reFormat, query)
+	return f
+}
 
-func getQueryParts(query string, timeField string) (string, timeseries.Extent, bool, error) {
+// timeLiteralParts is a parsed time literal
+type timeLiteralParts struct {
+	t    time.Time
+	fn   string
+	args string
+	// precision is the smallest time difference the literal can represent
+	precision time.Duration
+	// tokens are the start and end tokens that render a time in the literal's representation
+	tokens [2]string
+}
 
-	tcKey := timeField + "-tc"
-	trex, ok := compiledRe[tcKey]
-	if !ok {
-		trex = regexp.MustCompile(strings.Replace(timeClauseRe, "#TIME_FIELD#", timeField, -1))
-		compiledRe[tcKey] = trex
+// template returns the literal with its value replaced by the start (n=1) or end (n=2) token
+func (tl *timeLiteralParts) template(n int) string {
+	return fmt.Sprintf("%s(%s%s)", tl.fn, tl.tokens[n-1], tl.args)
+}
+
+// parseTimeLiteral parses a time literal, such as toDateTime(1516665600)
+func parseTimeLiteral(lit string) (*timeLiteralParts, error) {
+
+	i := strings.Index(lit, "(")
+	j := strings.LastIndex(lit, ")")
+	if i < 0 || j < i {
+		return nil, fmt.Errorf("failed to parse query: invalid time literal %s", lit)
 	}
 
-	m := matching.GetNamedMatches(trex, query, nil)
-	if len(m) == 0 {
-		return "", timeseries.Extent{}, false, fmt.Errorf("unable to parse time from query: %s", query)
+	tl := &timeLiteralParts{fn: strings.TrimSpace(lit[:i])}
+	args := strings.SplitN(lit[i+1:j], ",", 2)
+	if len(args) > 1 {
+		tl.args = "," + args[1]
 	}
 
-	ext, isRelativeTime, err := parseQueryExtents(query, m)
+	f, err := strconv.ParseFloat(strings.TrimSpace(args[0]), 64)
 	if err != nil {
-		return "", timeseries.Extent{}, false, err
+		return nil, fmt.Errorf("failed to parse query: invalid time literal %s", lit)
 	}
 
-	tq := tokenizeQuery(query, m)
-	return tq, ext, isRelativeTime, err
-}
-
-// tokenizeQuery will take a ClickHouse query and replace all time conditionals with a single <$TIME_TOKEN$>
-func tokenizeQuery(query string, timeParts map[string]string) string {
-	// First check the existence of timeExpr1, and if exists, tokenize
-	if expr, ok := timeParts["timeExpr1"]; ok {
-		if modifier, ok := timeParts["modifier"]; ok {
-			query = strings.Replace(query, expr, fmt.Sprintf("BETWEEN %s(%s) AND %s(%s)", modifier, tkTimestamp1, modifier, tkTimestamp2), -1)
-			// Then check the existence of timeExpr2, and if exists, remove from tokenized version
-			if expr, ok := timeParts["timeExpr2"]; ok {
-				query = strings.Replace(query, expr, "", -1)
-			}
-		}
+	switch strings.ToLower(tl.fn) {
+	case "fromunixtimestamp64milli":
+		tl.t = time.Unix(0, int64(f)*int64(time.Millisecond))
+		tl.precision = time.Millisecond
+		tl.tokens = [2]string{tkTimestamp1MS, tkTimestamp2MS}
+	case "todatetime64":
+		s, frac := math.Modf(f)
+		tl.t = time.Unix(int64(s), int64(math.Round(frac*1000))*int64(time.Millisecond))
+		tl.precision = time.Millisecond
+		tl.tokens = [2]string{tkTimestamp1Decimal, tkTimestamp2Decimal}
+	default:
+		tl.t = time.Unix(int64(f), 0)
+		tl.precision = time.Second
+		tl.tokens = [2]string{tkTimestamp1, tkTimestamp2}
 	}
+	return tl, nil
+}
 
-	if ts1, ok := timeParts["ts1"]; ok {
-		if strings.Contains(query, "("+ts1+")") {
-			m := matching.GetNamedMatches(reTimeClauseAlt, query, nil)
-			if len(m) > 0 {
-				if modifier, ok := m["modifier"]; ok {
-					if expression, ok := m["expression"]; ok {
-						query = strings.Replace(query, expression, fmt.Sprintf("BETWEEN %s(%s) AND %s(%s)", modifier, tkTimestamp1, modifier, tkTimestamp2), -1)
-					}
-				}
-			}
-		}
-	}
+func interpolateTimeQuery(template, timeField string, extent *timeseries.Extent) string {
+	return strings.NewReplacer(
+		tkTimestamp1, strconv.FormatInt(extent.Start.Unix(), 10),
+		tkTimestamp2, strconv.FormatInt(extent.End.Unix(), 10),
+		tkTimestamp1MS, strconv.FormatInt(extent.Start.UnixNano()/int64(time.Millisecond), 10),
+		tkTimestamp2MS, strconv.FormatInt(extent.End.UnixNano()/int64(time.Millisecond), 10),
+		tkTimestamp1Decimal, formatDecimalSeconds(extent.Start),
+		tkTimestamp2Decimal, formatDecimalSeconds(extent.End),
+	).Replace(template)
+}
 
-	return query
+// formatDecimalSeconds returns the time as fractional seconds with millisecond precision
+func formatDecimalSeconds(t time.Time) string {
+	ms := t.UnixNano() / int64(time.Millisecond)
+	return fmt.Sprintf("%d.%03d", ms/1000, ms%1000)
 }
 
-func parseQueryExtents(query string, timeParts map[string]string) (timeseries.Extent, bool, error) {
+var compiledRe = make(map[string]*regexp.Regexp)
+var compiledReLock = sync.Mutex{}
 
-	var e timeseries.Extent
+// getQueryParts returns a tokenized version of the query, the time range it requests, and whether
+// the time range is relative to now. The comparisons of the time field are rewritten to a single
+// BETWEEN clause, and any other time literals matching the start or end time are tokenized.
+func getQueryParts(query string, timeField string) (string, timeseries.Extent, bool, error) {
 
-	isRelativeTime := true
+	var e timeseries.Extent
 
-	op, ok := timeParts["operator"]
+	tcKey := timeField + "-tc"
+	compiledReLock.Lock()
+	trex, ok := compiledRe[tcKey]
 	if !ok {
-		return e, false, fmt.Errorf("failed to parse query: %s", "could not find operator")
+		trex = regexp.MustCompile(strings.Replace(timeClauseRe, "#TIME_FIELD#", regexp.QuoteMeta(timeField), -1))
+		compiledRe[tcKey] = trex
 	}
+	compiledReLock.Unlock()
 
-	if t, ok := timeParts["ts1"]; ok {
-		i, err := strconv.ParseInt(t, 10, 64)
-		if err != nil {
-			return e, false, fmt.Errorf("failed to parse query: %s", "could not find start time")
+	matches := trex.FindAllStringSubmatchIndex(query, -1)
+	if len(matches) == 0 {
+		return "", e, false, fmt.Errorf("unable to parse time from query: %s", query)
+	}
+
+	group := func(m []int, name string) string {
+		i := trex.SubexpIndex(name)
+		if m[2*i] < 0 {
+			return ""
 		}
-		e.Start = time.Unix(i, 0)
+		return query[m[2*i]:m[2*i+1]]
 	}
 
-	if strings.ToLower(op) == "between" {
-		isRelativeTime = false
-		if t, ok := timeParts["ts2"]; ok {
-			i, err := strconv.ParseInt(t, 10, 64)
+	var start, end *timeLiteralParts
+	// endValue is the time value of the end literal, before any adjustment for exclusivity
+	var endValue time.Time
+	for _, m := range matches {
+		lit1, err := parseTimeLiteral(group(m, "lit1"))
+		if err != nil {
+			return "", e, false, err
+		}
+		switch op := strings.ToLower(group(m, "operator")); op {
+		case "between":
+			if group(m, "lit2") == "" {
+				return "", e, false, fmt.Errorf("failed to parse query: %s", "could not find end time")
+			}
+			lit2, err := parseTimeLiteral(group(m, "lit2"))
 			if err != nil {
-				return e, false, fmt.Errorf("failed to parse query: %s", "could not determine end time")
+				return "", e, false, err
 			}
-			e.End = time.Unix(i, 0)
-		} else {
-			return e, false, fmt.Errorf("failed to parse query: %s", "could not find end time")
+			start, end = lit1, lit2
+			endValue = end.t
+		case "<=", "<":
+			end = lit1
+			endValue = end.t
+			if op == "<" {
+				// the extent end is inclusive
+				end.t = end.t.Add(-end.precision)
+			}
+		default:
+			start = lit1
 		}
-	} else {
+	}
+
+	if start == nil {
+		return "", e, false, fmt.Errorf("failed to parse query: %s", "could not find start time")
+	}
+
+	isRelativeTime := end == nil
+	e.Start = start.t
+	if isRelativeTime {
 		e.End = time.Now()
+	} else {
+		e.End = end.t
 	}
 
-	return e, isRelativeTime, nil
+	// rewrite the first time field comparison to a BETWEEN clause that uses the
+	// representation of the start time for both tokens, and remove the others
+	var b strings.Builder
+	var last int
+	ei := trex.SubexpIndex("expression")
+	for i, m := range matches {
+		if i == 0 {
+			b.WriteString(query[last:m[2*ei]])
+			b.WriteString(fmt.Sprintf("%s BETWEEN %s AND %s", timeField, start.template(1), start.template(2)))
+		} else {
+			b.WriteString(strings.TrimRight(query[last:m[0]], " \t\r\n"))
+		}
+		last = m[1]
+	}
+	b.WriteString(query[last:])
+
+	// tokenize any other time literals, such as those comparing a date column, that match the range
+	tq := reTimeLiteral.ReplaceAllStringFunc(b.String(), func(lit string) string {
+		tl, err := parseTimeLiteral(lit)
+		if err != nil {
+			return lit
+		}
+		if tl.t.Equal(start.t) {
+			return tl.template(1)
+		}
+		if end != nil && tl.t.Equal(endValue) {
+			return tl.template(2)
+		}
+		return lit
+	})
+
+	return tq, e, isRelativeTime, nil
 }
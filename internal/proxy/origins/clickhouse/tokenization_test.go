@@ -15,6 +15,10 @@ package clickhouse
 
 import (
 	"testing"
+	"time"
+
+	"github.com/Comcast/trickster/internal/proxy/errors"
+	"github.com/Comcast/trickster/internal/timeseries"
 )
 
 func TestGetQueryPartsFailure(t *testing.T) {
@@ -26,31 +30,156 @@ func TestGetQueryPartsFailure(t *testing.T) {
 
 }
 
-func TestParseQueryExtents(t *testing.T) {
+func TestGetQueryParts(t *testing.T) {
 
-	_, _, err := parseQueryExtents("", map[string]string{})
-	if err == nil {
-		t.Errorf("expected error: %s", `failed to parse query: could not find operator`)
+	tests := []struct {
+		query, expected string
+		start, end      time.Time
+		relative        bool
+	}{
+		// the existing Grafana plugin's $timeFilter, with an additional date column
+		{
+			"SELECT t FROM tbl WHERE ts BETWEEN toDateTime(1516665600) AND toDateTime(1516687200) " +
+				"AND date_column >= toDate(1516665600) AND toDate(1516687200) AND x = 1 FORMAT JSON",
+			"SELECT t FROM tbl WHERE ts BETWEEN toDateTime(<$TIMESTAMP1$>) AND toDateTime(<$TIMESTAMP2$>) " +
+				"AND date_column >= toDate(<$TIMESTAMP1$>) AND toDate(<$TIMESTAMP2$>) AND x = 1 FORMAT JSON",
+			time.Unix(1516665600, 0), time.Unix(1516687200, 0), false,
+		},
+		// $__timeFilter-style lower and upper bounds
+		{
+			"SELECT t FROM tbl WHERE ts >= toDateTime(1516665600) AND ts <= toDateTime(1516687200) AND x = 1",
+			"SELECT t FROM tbl WHERE ts BETWEEN toDateTime(<$TIMESTAMP1$>) AND toDateTime(<$TIMESTAMP2$>) AND x = 1",
+			time.Unix(1516665600, 0), time.Unix(1516687200, 0), false,
+		},
+		// an exclusive upper bound
+		{
+			"SELECT t FROM tbl PREWHERE ts >= toDateTime(1516665600) and ts < toDateTime(1516687200)",
+			"SELECT t FROM tbl PREWHERE ts BETWEEN toDateTime(<$TIMESTAMP1$>) AND toDateTime(<$TIMESTAMP2$>)",
+			time.Unix(1516665600, 0), time.Unix(1516687199, 0), false,
+		},
+		// DateTime64 comparisons
+		{
+			"SELECT t FROM tbl WHERE ts >= toDateTime64(1516665600.5, 3) AND ts <= toDateTime64(1516687200.25, 3, 'UTC')",
+			"SELECT t FROM tbl WHERE ts BETWEEN toDateTime64(<$TIMESTAMP1_DECIMAL$>, 3) AND toDateTime64(<$TIMESTAMP2_DECIMAL$>, 3)",
+			time.Unix(1516665600, 500000000), time.Unix(1516687200, 250000000), false,
+		},
+		{
+			"SELECT t FROM tbl WHERE ts >= fromUnixTimestamp64Milli(1516665600123) AND ts < fromUnixTimestamp64Milli(1516687200000)",
+			"SELECT t FROM tbl WHERE ts BETWEEN fromUnixTimestamp64Milli(<$TIMESTAMP1_MS$>) AND fromUnixTimestamp64Milli(<$TIMESTAMP2_MS$>)",
+			time.Unix(1516665600, 123000000), time.Unix(1516687199, 999000000), false,
+		},
+		// a lower bound only is relative to now
+		{
+			"SELECT t FROM tbl WHERE ts > toDateTime(1516665600) AND other > toDateTime(1)",
+			"SELECT t FROM tbl WHERE ts BETWEEN toDateTime(<$TIMESTAMP1$>) AND toDateTime(<$TIMESTAMP2$>) AND other > toDateTime(1)",
+			time.Unix(1516665600, 0), time.Time{}, true,
+		},
 	}
 
-	_, _, err = parseQueryExtents("", map[string]string{"operator": "", "ts1": "a"})
-	if err == nil {
-		t.Errorf("expected error: %s", `failed to parse query: could not find start time`)
+	for i, test := range tests {
+		tq, e, relative, err := getQueryParts(test.query, "ts")
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+			continue
+		}
+		if tq != test.expected {
+			t.Errorf("test %d:\nexpected [%s]\ngot      [%s]", i, test.expected, tq)
+		}
+		if !e.Start.Equal(test.start) || (!relative && !e.End.Equal(test.end)) {
+			t.Errorf("test %d: expected %s-%s got %s", i, test.start, test.end, e)
+		}
+		if relative != test.relative {
+			t.Errorf("test %d: expected %t got %t", i, test.relative, relative)
+		}
 	}
 
-	_, _, err = parseQueryExtents("", map[string]string{"operator": "between", "ts1": "1", "ts2": "a"})
-	if err == nil {
-		t.Errorf("expected error: %s", `failed to parse query: could not determine end time`)
+	failures := []string{
+		"SELECT t FROM tbl WHERE ts <= toDateTime(1516687200)",
+		"SELECT t FROM tbl WHERE ts BETWEEN toDateTime(1516665600)",
+		"SELECT t FROM tbl WHERE tsx >= toDateTime(1516665600)",
+	}
+	for i, query := range failures {
+		if _, _, _, err := getQueryParts(query, "ts"); err == nil {
+			t.Errorf("failure %d: expected error", i)
+		}
 	}
+}
 
-	_, _, err = parseQueryExtents("", map[string]string{"operator": "between", "ts1": "1"})
-	if err == nil {
-		t.Errorf("expected error: %s", `failed to parse query: could not find end time`)
+func TestParseTimeFieldAndStep(t *testing.T) {
+
+	tests := []struct {
+		query, field string
+		step         time.Duration
+	}{
+		{"SELECT (intDiv(toUInt32(ts), 60) * 60) * 1000 AS t", "ts", time.Minute},
+		{"SELECT toStartOfInterval(ts, INTERVAL 5 minute) AS t", "ts", 5 * time.Minute},
+		{"select toStartOfInterval(toDateTime(event_time), INTERVAL 20 SECOND) as time", "event_time", 20 * time.Second},
+		{"SELECT toStartOfInterval(toDateTime64(ts, 3), INTERVAL 2 hours, 'UTC') AS t", "ts", 2 * time.Hour},
+		{"SELECT toStartOfMinute(ts) AS t", "ts", time.Minute},
+		{"SELECT toStartOfFiveMinute(ts) AS t", "ts", 5 * time.Minute},
+		{"SELECT toStartOfFifteenMinutes(ts) AS t", "ts", 15 * time.Minute},
+		{"SELECT toStartOfHour(toDateTime(ts)) AS t", "ts", time.Hour},
+		{"SELECT toStartOfDay(ts) AS t", "ts", 24 * time.Hour},
+	}
+
+	for i, test := range tests {
+		field, step, err := parseTimeFieldAndStep(test.query)
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+			continue
+		}
+		if field != test.field || step != test.step {
+			t.Errorf("test %d: expected %s %s got %s %s", i, test.field, test.step, field, step)
+		}
+	}
+
+	for i, query := range []string{
+		"SELECT count() FROM tbl",
+		"SELECT toStartOfInterval(ts, INTERVAL 1 month) AS t",
+		"SELECT toStartOfInterval(ts, INTERVAL 0 second) AS t",
+		"SELECT x, toStartOfMinute(ts) AS t",
+	} {
+		if _, _, err := parseTimeFieldAndStep(query); err != errors.ErrNotTimeRangeQuery {
+			t.Errorf("failure %d: expected %v got %v", i, errors.ErrNotTimeRangeQuery, err)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := map[string]string{
+		"SELECT 1 FORMAT JSON":            "JSON",
+		"SELECT 1 format CSVWithNames;":   "CSVWithNames",
+		"SELECT 1 FORMAT TSVWithNames \n": "TSVWithNames",
+		"SELECT 1":                        "",
+	}
+	for query, expected := range tests {
+		if f := parseFormat(query); f != expected {
+			t.Errorf("expected %s got %s", expected, f)
+		}
 	}
+}
 
-	_, _, err = parseQueryExtents("", map[string]string{"operator": "x", "ts1": "1"})
+func TestParseTimeLiteral(t *testing.T) {
+	if _, err := parseTimeLiteral("toDateTime"); err == nil {
+		t.Error("expected error for invalid time literal")
+	}
+	if _, err := parseTimeLiteral("toDateTime(abc)"); err == nil {
+		t.Error("expected error for invalid time literal")
+	}
+	tl, err := parseTimeLiteral("toDate(1516665600)")
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
+	}
+	if tl.template(2) != "toDate(<$TIMESTAMP2$>)" || tl.precision != time.Second {
+		t.Errorf("unexpected template %s", tl.template(2))
 	}
+}
 
+func TestInterpolateTimeQuery(t *testing.T) {
+	e := &timeseries.Extent{Start: time.Unix(1516665600, 5000000), End: time.Unix(1516687200, 0)}
+	const template = "<$TIMESTAMP1$> <$TIMESTAMP2$> <$TIMESTAMP1_MS$> <$TIMESTAMP2_MS$> <$TIMESTAMP1_DECIMAL$> <$TIMESTAMP2_DECIMAL$>"
+	const expected = "1516665600 1516687200 1516665600005 1516687200000 1516665600.005 1516687200.000"
+	if q := interpolateTimeQuery(template, "ts", e); q != expected {
+		t.Errorf("expected %s got %s", expected, q)
+	}
 }
@@ -14,16 +14,26 @@
 package clickhouse
 
 import (
+	"bytes"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 
+	"github.com/Comcast/trickster/internal/proxy/errors"
+	"github.com/Comcast/trickster/internal/proxy/headers"
 	"github.com/Comcast/trickster/internal/timeseries"
 )
 
 // Common URL Parameter Names
 const (
 	upQuery = "query"
+	// the settings below are passed through to ClickHouse in the URL and affect query results,
+	// so they are included in the cache key. Other settings, like max_execution_time, are not.
+	upDatabase        = "database"
+	upUser            = "user"
+	upSessionTimezone = "session_timezone"
 )
 
 // BaseURL returns a URL in the form of scheme://host/path based on the proxy configuration
@@ -62,9 +72,67 @@ func (c *Client) SetExtent(r *http.Request, trq *timeseries.TimeRangeQuery, exte
 	t := trq.TemplateURL.Query()
 	q := t.Get(upQuery)
 
-	if q != "" {
-		p.Set(upQuery, interpolateTimeQuery(q, trq.TimestampFieldName, extent))
+	if q == "" {
+		return
 	}
 
+	// statements sent in the POST body are rewritten in the body, and any leading part
+	// of the statement in the query parameter is removed, since it is in the template
+	if hasBodyStatement(r) {
+		p.Del(upQuery)
+		r.URL.RawQuery = p.Encode()
+		setBody(r, []byte(interpolateTimeQuery(q, trq.TimestampFieldName, extent)))
+		return
+	}
+
+	p.Set(upQuery, interpolateTimeQuery(q, trq.TimestampFieldName, extent))
 	r.URL.RawQuery = p.Encode()
 }
+
+// hasBodyStatement returns true if the request is a POST with all or part of its
+// SQL statement in a plain (uncompressed and non-multipart) body
+func hasBodyStatement(r *http.Request) bool {
+	if r.Method != http.MethodPost || r.Body == nil || r.Body == http.NoBody ||
+		r.Header.Get(headers.NameContentEncoding) != "" ||
+		strings.HasPrefix(r.Header.Get(headers.NameContentType), headers.ValueMultipartFormData) {
+		return false
+	}
+	b, err := readBody(r)
+	return err == nil && len(bytes.TrimSpace(b)) > 0
+}
+
+// requestStatement returns the SQL statement of the request. As in ClickHouse, a statement
+// in the POST body is appended to the beginning of the statement in the query parameter,
+// separated by a newline.
+func requestStatement(r *http.Request) (string, error) {
+	stmt := r.URL.Query().Get(upQuery)
+	if !hasBodyStatement(r) {
+		return stmt, nil
+	}
+	b, err := readBody(r)
+	if err != nil {
+		return "", err
+	}
+	if stmt != "" {
+		stmt += "\n"
+	}
+	return stmt + string(b), nil
+}
+
+// readBody returns the body of the request. The body is restored so that it can be read again.
+func readBody(r *http.Request) ([]byte, error) {
+	b, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		return nil, errors.ParseRequestBody(err)
+	}
+	return b, nil
+}
+
+// setBody replaces the body of the request with the provided bytes
+func setBody(r *http.Request, b []byte) {
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	r.ContentLength = int64(len(b))
+	r.Header.Set(headers.NameContentLength, strconv.Itoa(len(b)))
+}
@@ -15,12 +15,15 @@ package clickhouse
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/Comcast/trickster/internal/config"
+	"github.com/Comcast/trickster/internal/proxy/headers"
 	"github.com/Comcast/trickster/internal/timeseries"
 )
 
@@ -50,6 +53,67 @@ func TestSetExtent(t *testing.T) {
 
 }
 
+func TestSetExtentBody(t *testing.T) {
+
+	start := time.Unix(1516665600, 0)
+	end := time.Unix(1516687200, 0)
+	const expected = "SELECT toStartOfMinute(ts) AS t, count() AS cnt FROM tbl " +
+		"WHERE ts BETWEEN toDateTime(1516665600) AND toDateTime(1516687200) GROUP BY t FORMAT JSON"
+
+	client := &Client{}
+	tu := &url.URL{RawQuery: url.Values{upQuery: {"SELECT toStartOfMinute(ts) AS t, count() AS cnt FROM tbl " +
+		"WHERE ts BETWEEN toDateTime(<$TIMESTAMP1$>) AND toDateTime(<$TIMESTAMP2$>) GROUP BY t FORMAT JSON"}}.Encode()}
+	trq := &timeseries.TimeRangeQuery{TimestampFieldName: "ts", TemplateURL: tu}
+
+	r, _ := http.NewRequest(http.MethodPost, "http://0/?database=db&query=SELECT", strings.NewReader("x"))
+	client.SetExtent(r, trq, &timeseries.Extent{Start: start, End: end})
+
+	if r.URL.RawQuery != "database=db" {
+		t.Errorf("expected %s got %s", "database=db", r.URL.RawQuery)
+	}
+	b, _ := ioutil.ReadAll(r.Body)
+	if string(b) != expected {
+		t.Errorf("\nexpected [%s]\ngot      [%s]", expected, string(b))
+	}
+	if r.ContentLength != int64(len(expected)) {
+		t.Errorf("expected %d got %d", len(expected), r.ContentLength)
+	}
+
+}
+
+func TestRequestStatement(t *testing.T) {
+
+	tests := []struct {
+		method, url, contentEncoding, body, expected string
+	}{
+		{http.MethodGet, "http://0/?query=SELECT+1", "", "", "SELECT 1"},
+		{http.MethodPost, "http://0/?query=SELECT+1", "", "", "SELECT 1"},
+		{http.MethodPost, "http://0/", "", "SELECT 1", "SELECT 1"},
+		{http.MethodPost, "http://0/?query=SELECT", "", "1", "SELECT\n1"},
+		{http.MethodPost, "http://0/?query=SELECT+1", "gzip", "abc", "SELECT 1"},
+	}
+
+	for i, test := range tests {
+		r, _ := http.NewRequest(test.method, test.url, strings.NewReader(test.body))
+		if test.contentEncoding != "" {
+			r.Header.Set(headers.NameContentEncoding, test.contentEncoding)
+		}
+		stmt, err := requestStatement(r)
+		if err != nil {
+			t.Error(err)
+		}
+		if stmt != test.expected {
+			t.Errorf("test %d expected %s got %s", i, test.expected, stmt)
+		}
+		// the body must remain readable
+		b, _ := ioutil.ReadAll(r.Body)
+		if string(b) != test.body {
+			t.Errorf("test %d expected body %s got %s", i, test.body, string(b))
+		}
+	}
+
+}
+
 func TestBuildUpstreamURL(t *testing.T) {
 
 	cfg := config.NewConfig()
@@ -0,0 +1,92 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package druid
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/trickster/internal/proxy/request"
+	tu "github.com/Comcast/trickster/internal/util/testing"
+)
+
+func TestQueryHandler(t *testing.T) {
+
+	client := &Client{name: "test"}
+	end := time.Now().Truncate(time.Minute)
+	start := end.Add(-5 * time.Minute)
+	response := testResponse([]string{"a"}, start.Unix(), end.Unix())
+
+	ts, w, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, response, nil, "druid", APIPath, "debug")
+	ctx := r.Context()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	defer ts.Close()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if _, ok := client.config.Paths[APIPath]; !ok {
+		t.Errorf("could not find path config named %s", APIPath)
+	}
+
+	interval := `"` + start.UTC().Format(time.RFC3339) + "/" + end.Add(time.Minute).UTC().Format(time.RFC3339) + `"`
+	r = httptest.NewRequest(http.MethodPost, ts.URL+APIPath,
+		strings.NewReader(testQuery(qtGroupBy, `"minute"`, interval, `,"dimensions":["host"]`)))
+	r = r.WithContext(ctx)
+
+	client.QueryHandler(w, r)
+	resp := w.Result()
+
+	// it should return 200 OK
+	if resp.StatusCode != 200 {
+		t.Errorf("expected 200 got %d.", resp.StatusCode)
+	}
+
+	if h := resp.Header.Get("X-Trickster-Result"); !strings.Contains(h, "engine=DeltaProxyCache") || !strings.Contains(h, "status=kmiss") {
+		t.Errorf("expected delta proxy cache key miss got %s", h)
+	}
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if string(bodyBytes) != response {
+		t.Errorf("\nexpected [%s]\ngot      [%s]", response, bodyBytes)
+	}
+
+	// queries that can't be accelerated are proxied
+	r = httptest.NewRequest(http.MethodPost, ts.URL+APIPath,
+		strings.NewReader(testQuery(qtGroupBy, `"all"`, interval, `,"dimensions":["host"]`)))
+	r = r.WithContext(ctx)
+	w = httptest.NewRecorder()
+
+	client.QueryHandler(w, r)
+	resp = w.Result()
+	if resp.StatusCode != 200 {
+		t.Errorf("expected 200 got %d.", resp.StatusCode)
+	}
+
+	if h := resp.Header.Get("X-Trickster-Result"); !strings.Contains(h, "engine=HTTPProxy") {
+		t.Errorf("expected http proxy engine got %s", h)
+	}
+}
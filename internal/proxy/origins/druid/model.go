@@ -0,0 +1,139 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package druid
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+// envelopePrefix identifies a ResultSet that was marshaled for cache storage
+var envelopePrefix = []byte(`{"rows":`)
+
+// ResultSet represents the results of a timeseries or groupBy query. Each row holds
+// the results for a timestamp (and, for groupBy queries, a set of dimension values).
+type ResultSet struct {
+	Rows         []Row
+	StepDuration time.Duration
+	ExtentList   timeseries.ExtentList
+
+	timestamps map[time.Time]int // tracks the count of rows for each timestamp in the result set
+	isSorted   bool              // tracks if the result set is currently sorted
+	isCounted  bool              // tracks if timestamps map is up-to-date
+}
+
+// Row represents a single result row. The row is carried as-is, since its layout
+// depends upon the query type and the query's dimensions and aggregations.
+type Row struct {
+	Timestamp time.Time
+	Data      json.RawMessage
+}
+
+// resultSetEnvelope is the cached representation of a ResultSet, which carries the step and extents
+type resultSetEnvelope struct {
+	Rows         json.RawMessage       `json:"rows"`
+	StepDuration time.Duration         `json:"step,omitempty"`
+	ExtentList   timeseries.ExtentList `json:"extents,omitempty"`
+}
+
+// MarshalTimeseries converts a Timeseries into a JSON blob
+func (c *Client) MarshalTimeseries(ts timeseries.Timeseries) ([]byte, error) {
+	rs, ok := ts.(*ResultSet)
+	if !ok {
+		return nil, fmt.Errorf("unsupported timeseries type: %T", ts)
+	}
+	return rs.marshal()
+}
+
+// UnmarshalTimeseries converts a JSON blob into a Timeseries
+func (c *Client) UnmarshalTimeseries(data []byte) (timeseries.Timeseries, error) {
+	return unmarshalResultSet(data)
+}
+
+// unmarshalResultSet converts a cached ResultSet or a query response body into a ResultSet
+func unmarshalResultSet(data []byte) (*ResultSet, error) {
+
+	if bytes.HasPrefix(data, envelopePrefix) {
+		re := &resultSetEnvelope{}
+		if err := json.Unmarshal(data, re); err != nil {
+			return nil, err
+		}
+		rs, err := parseRows(re.Rows)
+		if err != nil {
+			return nil, err
+		}
+		rs.StepDuration = re.StepDuration
+		rs.ExtentList = re.ExtentList
+		return rs, nil
+	}
+
+	return parseRows(data)
+}
+
+func parseRows(data []byte) (*ResultSet, error) {
+
+	var list []json.RawMessage
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+
+	rs := &ResultSet{Rows: make([]Row, len(list))}
+	for i, raw := range list {
+		row := struct {
+			Timestamp *string `json:"timestamp"`
+		}{}
+		if err := json.Unmarshal(raw, &row); err != nil {
+			return nil, err
+		}
+		// rows without a timestamp, such as grand totals, can't be merged
+		if row.Timestamp == nil {
+			return nil, fmt.Errorf("missing result timestamp")
+		}
+		t, err := time.Parse(time.RFC3339Nano, *row.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+		rs.Rows[i] = Row{Timestamp: t.UTC(), Data: raw}
+	}
+
+	return rs, nil
+}
+
+// JSON returns the ResultSet as a native query response body
+func (rs *ResultSet) JSON() []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('[')
+	for i, row := range rs.Rows {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(row.Data)
+	}
+	buf.WriteByte(']')
+	return buf.Bytes()
+}
+
+// marshal returns the ResultSet as a native query response body for client responses,
+// or as a JSON envelope that carries the step and extents for cache storage
+func (rs *ResultSet) marshal() ([]byte, error) {
+	if len(rs.ExtentList) == 0 && rs.StepDuration == 0 {
+		return rs.JSON(), nil
+	}
+	return json.Marshal(&resultSetEnvelope{Rows: rs.JSON(),
+		StepDuration: rs.StepDuration, ExtentList: rs.ExtentList})
+}
@@ -0,0 +1,119 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package druid
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+// testResponse returns the results of a one minute granularity query from start to end (inclusive,
+// in epoch seconds). When hosts are provided, the results are those of a groupBy query on the host dimension.
+func testResponse(hosts []string, start, end int64) string {
+	rows := make([]string, 0, 8)
+	for ts := start; ts <= end; ts += 60 {
+		t := time.Unix(ts, 0).UTC().Format("2006-01-02T15:04:05.000Z")
+		if len(hosts) == 0 {
+			rows = append(rows, fmt.Sprintf(`{"timestamp":"%s","result":{"count":%d}}`, t, ts/60))
+			continue
+		}
+		for _, h := range hosts {
+			rows = append(rows, fmt.Sprintf(`{"version":"v1","timestamp":"%s","event":{"count":%d,"host":"%s"}}`, t, ts/60, h))
+		}
+	}
+	return "[" + strings.Join(rows, ",") + "]"
+}
+
+func testResultSet(t *testing.T, hosts []string, start, end int64) *ResultSet {
+	rs, err := unmarshalResultSet([]byte(testResponse(hosts, start, end)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.StepDuration = time.Minute
+	rs.ExtentList = timeseries.ExtentList{{Start: time.Unix(start, 0), End: time.Unix(end, 0)}}
+	return rs
+}
+
+func TestMarshalTimeseries(t *testing.T) {
+
+	client := &Client{}
+	expected := testResponse([]string{"a", "b"}, 600, 720)
+
+	ts, err := client.UnmarshalTimeseries([]byte(expected))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// client responses are marshaled as-is
+	b, err := client.MarshalTimeseries(ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != expected {
+		t.Errorf("\nexpected [%s]\ngot      [%s]", expected, b)
+	}
+
+	// cached result sets carry the step and extents
+	rs := ts.(*ResultSet)
+	rs.SetStep(time.Minute)
+	rs.SetExtents(timeseries.ExtentList{{Start: time.Unix(600, 0), End: time.Unix(720, 0)}})
+	b, err = client.MarshalTimeseries(rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), string(envelopePrefix)) {
+		t.Errorf("expected envelope got %s", b)
+	}
+
+	ts, err = client.UnmarshalTimeseries(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs2 := ts.(*ResultSet)
+	if rs2.Step() != time.Minute || len(rs2.Extents()) != 1 || rs2.ValueCount() != 6 {
+		t.Errorf("unexpected result set %v", rs2)
+	}
+	if string(rs2.JSON()) != expected {
+		t.Errorf("\nexpected [%s]\ngot      [%s]", expected, rs2.JSON())
+	}
+
+	if _, err = client.MarshalTimeseries(nil); err == nil {
+		t.Error("expected error for unsupported timeseries type")
+	}
+}
+
+func TestUnmarshalTimeseriesErrors(t *testing.T) {
+
+	client := &Client{}
+	tests := []string{
+		`{"rows":[`,
+		`{"rows":{},"step":60}`,
+		`{"error":"Unknown exception"}`,
+		`[1]`,
+		`[{"timestamp":null,"result":{"count":1}}]`,
+		`[{"timestamp":"yesterday","result":{"count":1}}]`,
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			if _, err := client.UnmarshalTimeseries([]byte(test)); err == nil {
+				t.Errorf("expected error for %s", test)
+			}
+		})
+	}
+}
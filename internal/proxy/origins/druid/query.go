@@ -0,0 +1,369 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package druid
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Comcast/trickster/internal/proxy/errors"
+	"github.com/Comcast/trickster/internal/proxy/urls"
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+// This file handles parsing and tokenization of the intervals and granularity
+// of Druid native queries for cache key hashing and delta proxy caching.
+
+// tkInterval is the Token for String Interpolation of the query interval. It is a JSON
+// string in the tokenized query, and is replaced by the ISO-8601 interval to fetch.
+const tkInterval = "<$INTERVAL_TOKEN$>"
+
+// Native query field names with special meaning to Trickster
+const (
+	fnQueryType   = "queryType"
+	fnIntervals   = "intervals"
+	fnGranularity = "granularity"
+	fnDescending  = "descending"
+	fnLimit       = "limit"
+	fnLimitSpec   = "limitSpec"
+	fnColumns     = "columns"
+	fnSubtotals   = "subtotalsSpec"
+	fnContext     = "context"
+	fnType        = "type"
+	fnPeriod      = "period"
+	fnDuration    = "duration"
+	fnTimeZone    = "timeZone"
+	fnOrigin      = "origin"
+	fnTimestamp   = "timestamp"
+)
+
+// Native query types that can be accelerated
+const (
+	qtTimeseries = "timeseries"
+	qtGroupBy    = "groupBy"
+)
+
+// simpleGranularities maps the simple granularities that are aligned to the epoch to their durations
+var simpleGranularities = map[string]time.Duration{
+	"second":         time.Second,
+	"minute":         time.Minute,
+	"five_minute":    5 * time.Minute,
+	"ten_minute":     10 * time.Minute,
+	"fifteen_minute": 15 * time.Minute,
+	"thirty_minute":  30 * time.Minute,
+	"hour":           time.Hour,
+	"six_hour":       6 * time.Hour,
+	"eight_hour":     8 * time.Hour,
+	"day":            24 * time.Hour,
+}
+
+// unsupportedContext lists the query context flags that change the layout of the results,
+// such that results for different intervals can't be merged
+var unsupportedContext = []string{"grandTotal", "sortByDimsFirst", "resultAsArray"}
+
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04", "2006-01-02T15", "2006-01-02"}
+
+var rePeriod *regexp.Regexp
+
+func init() {
+	// Regexp for parsing an ISO-8601 period of fixed length, such as PT1M or P1DT12H
+	rePeriod = regexp.MustCompile(`^P(?:([0-9]+)W)?(?:([0-9]+)D)?(?:T(?:([0-9]+)H)?(?:([0-9]+)M)?(?:([0-9]+(?:\.[0-9]+)?)S)?)?$`)
+}
+
+// parseQueryRequest parses the key parts of a TimeRangeQuery from an inbound native query request
+func parseQueryRequest(r *http.Request) (*timeseries.TimeRangeQuery, error) {
+
+	if r.Method != http.MethodPost {
+		return nil, errors.ErrNotTimeRangeQuery
+	}
+
+	if r.Body == nil {
+		return nil, errors.MissingRequestParam(upQueryBody)
+	}
+
+	b, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		return nil, errors.ParseRequestBody(err)
+	}
+
+	template, extent, step, err := parseQuery(b, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	trq := &timeseries.TimeRangeQuery{Statement: template, Extent: extent, Step: step, FastForwardDisable: true}
+
+	// Swap in the Tokenized Query in the Url Params
+	trq.TemplateURL = urls.Clone(r.URL)
+	qi := trq.TemplateURL.Query()
+	qi.Set(upQueryBody, template)
+	trq.TemplateURL.RawQuery = qi.Encode()
+
+	return trq, nil
+}
+
+// parseQuery tokenizes the intervals of a timeseries or groupBy query, and returns the tokenized
+// query with the time range and step it specifies
+func parseQuery(b []byte, now time.Time) (string, timeseries.Extent, time.Duration, error) {
+
+	var e timeseries.Extent
+
+	doc := map[string]interface{}{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&doc); err != nil {
+		return "", e, 0, errors.ParseRequestBody(err)
+	}
+
+	// only results that are bucketed by time, in chronological order, can be merged
+	switch doc[fnQueryType] {
+	case qtTimeseries:
+		if isTrue(doc[fnDescending]) || doc[fnLimit] != nil {
+			return "", e, 0, errors.ErrNotTimeRangeQuery
+		}
+	case qtGroupBy:
+		if doc[fnSubtotals] != nil || !isUnorderedLimitSpec(doc[fnLimitSpec]) {
+			return "", e, 0, errors.ErrNotTimeRangeQuery
+		}
+	default:
+		return "", e, 0, errors.ErrNotTimeRangeQuery
+	}
+
+	if ctx, ok := doc[fnContext].(map[string]interface{}); ok {
+		for _, k := range unsupportedContext {
+			if isTrue(ctx[k]) {
+				return "", e, 0, errors.ErrNotTimeRangeQuery
+			}
+		}
+	}
+
+	step, err := parseGranularity(doc[fnGranularity])
+	if err != nil {
+		return "", e, 0, err
+	}
+
+	e, err = parseIntervals(doc[fnIntervals], now)
+	if err != nil {
+		return "", e, 0, err
+	}
+
+	doc[fnIntervals] = []interface{}{tkInterval}
+	template, err := marshalJSON(doc)
+	if err != nil {
+		return "", e, 0, err
+	}
+
+	return string(template), e, step, nil
+}
+
+// isTrue returns true if the value is a JSON true, or the string "true" (as is accepted by Druid)
+func isTrue(v interface{}) bool {
+	switch t := v.(type) {
+	case bool:
+		return t
+	case string:
+		return strings.ToLower(t) == "true"
+	}
+	return false
+}
+
+// isUnorderedLimitSpec returns true if the groupBy limitSpec neither limits nor
+// reorders the results, which are otherwise ordered by timestamp
+func isUnorderedLimitSpec(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	ls, ok := v.(map[string]interface{})
+	if !ok || ls[fnLimit] != nil {
+		return false
+	}
+	cols, _ := ls[fnColumns].([]interface{})
+	return len(cols) == 0
+}
+
+// parseGranularity returns the step of a granularity that is a fixed duration aligned to the epoch
+func parseGranularity(v interface{}) (time.Duration, error) {
+
+	switch t := v.(type) {
+	case string:
+		if d, ok := simpleGranularities[strings.ToLower(t)]; ok {
+			return d, nil
+		}
+		// all, none and the calendar granularities (week, month, quarter and year)
+		return 0, errors.ErrNotTimeRangeQuery
+
+	case map[string]interface{}:
+		typ, _ := t[fnType].(string)
+		if typ != fnPeriod && typ != fnDuration {
+			return parseGranularity(typ)
+		}
+		if t[fnOrigin] != nil {
+			return 0, errors.ErrNotTimeRangeQuery
+		}
+		if typ == fnDuration {
+			n, ok := t[fnDuration].(json.Number)
+			if !ok {
+				return 0, errors.ErrStepParse
+			}
+			ms, err := n.Int64()
+			if err != nil || ms <= 0 {
+				return 0, errors.ErrStepParse
+			}
+			return time.Duration(ms) * time.Millisecond, nil
+		}
+		if tz, ok := t[fnTimeZone].(string); ok && !isUTC(tz) {
+			return 0, errors.ErrNotTimeRangeQuery
+		}
+		p, _ := t[fnPeriod].(string)
+		d, err := parsePeriod(p)
+		if err != nil {
+			// months and years have no fixed length
+			return 0, errors.ErrNotTimeRangeQuery
+		}
+		// periods are aligned to the calendar, which is only aligned to the epoch when they evenly divide a day
+		if (24*time.Hour)%d != 0 {
+			return 0, errors.ErrNotTimeRangeQuery
+		}
+		return d, nil
+	}
+
+	return 0, errors.ErrStepParse
+}
+
+func isUTC(tz string) bool {
+	switch strings.ToUpper(tz) {
+	case "UTC", "Z", "GMT", "ETC/UTC", "ETC/GMT", "+00:00":
+		return true
+	}
+	return false
+}
+
+// parsePeriod parses an ISO-8601 period of fixed length, such as PT5M
+func parsePeriod(s string) (time.Duration, error) {
+	m := rePeriod.FindStringSubmatch(s)
+	if m == nil {
+		return errors.ParseDuration(s)
+	}
+	var d time.Duration
+	for i, unit := range []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute} {
+		if m[i+1] != "" {
+			n, _ := strconv.ParseInt(m[i+1], 10, 64)
+			d += time.Duration(n) * unit
+		}
+	}
+	if m[5] != "" {
+		f, _ := strconv.ParseFloat(m[5], 64)
+		d += time.Duration(f * float64(time.Second))
+	}
+	if d <= 0 {
+		return errors.ParseDuration(s)
+	}
+	return d, nil
+}
+
+// parseIntervals returns the extent of the query's intervals, which must be a single interval
+func parseIntervals(v interface{}, now time.Time) (timeseries.Extent, error) {
+
+	var e timeseries.Extent
+
+	// intervals may be an interval string, a list of them, or an object holding a list of them
+	if m, ok := v.(map[string]interface{}); ok {
+		v = m[fnIntervals]
+	}
+	if l, ok := v.([]interface{}); ok {
+		if len(l) != 1 {
+			return e, errors.ErrNotTimeRangeQuery
+		}
+		v = l[0]
+	}
+	s, ok := v.(string)
+	if !ok {
+		return e, errors.MissingRequestParam(fnIntervals)
+	}
+
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 {
+		return e, errors.ParseRequestBody(fmt.Errorf("invalid interval: %s", s))
+	}
+
+	start, startErr := parseTime(parts[0])
+	end, endErr := parseTime(parts[1])
+	switch {
+	case startErr == nil && endErr == nil:
+	case startErr == nil:
+		d, err := parsePeriod(parts[1])
+		if err != nil {
+			return e, errors.ParseRequestBody(fmt.Errorf("invalid interval: %s", s))
+		}
+		end = start.Add(d)
+	case endErr == nil:
+		d, err := parsePeriod(parts[0])
+		if err != nil {
+			return e, errors.ParseRequestBody(fmt.Errorf("invalid interval: %s", s))
+		}
+		start = end.Add(-d)
+	default:
+		return e, errors.ParseRequestBody(fmt.Errorf("invalid interval: %s", s))
+	}
+
+	if !end.After(start) {
+		return e, errors.ErrNotTimeRangeQuery
+	}
+
+	// the end of an interval is exclusive, so the last bucket is the one before it
+	e.Start, e.End = start, end.Add(-time.Millisecond)
+	return e, nil
+}
+
+// parseTime parses an ISO-8601 time. Times without a zone are in UTC, as they are in Druid.
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unable to parse time: %s", s)
+}
+
+// formatInterval returns the ISO-8601 interval that includes every bucket of the extent
+func formatInterval(extent *timeseries.Extent, step time.Duration) string {
+	const layout = "2006-01-02T15:04:05.000Z"
+	return extent.Start.UTC().Format(layout) + "/" + extent.End.Add(step).UTC().Format(layout)
+}
+
+// interpolateQuery replaces the interval token in a tokenized query with the provided extent
+func interpolateQuery(template string, extent *timeseries.Extent, step time.Duration) string {
+	return strings.Replace(template, `"`+tkInterval+`"`, `"`+formatInterval(extent, step)+`"`, -1)
+}
+
+// marshalJSON marshals the value without escaping HTML characters, so that tokens remain intact
+func marshalJSON(v interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
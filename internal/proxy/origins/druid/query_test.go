@@ -0,0 +1,195 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package druid
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/trickster/internal/proxy/errors"
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+const testInterval = `"1970-01-01T00:10:00Z/1970-01-01T00:13:00Z"`
+
+func testQuery(queryType, granularity, intervals, extra string) string {
+	return fmt.Sprintf(`{"queryType":"%s","dataSource":"metrics","granularity":%s,"intervals":%s,`+
+		`"aggregations":[{"type":"count","name":"count"}]%s}`, queryType, granularity, intervals, extra)
+}
+
+func TestParseQueryRequest(t *testing.T) {
+
+	r := httptest.NewRequest(http.MethodPost, "http://0/druid/v2?pretty",
+		strings.NewReader(testQuery(qtGroupBy, `"minute"`, "["+testInterval+"]", `,"dimensions":["host"]`)))
+	trq, err := parseQueryRequest(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if trq.Extent.Start.Unix() != 600 || trq.Extent.End.Unix() != 779 || trq.Step != time.Minute {
+		t.Errorf("unexpected extent %v or step %s", trq.Extent, trq.Step)
+	}
+
+	if !trq.FastForwardDisable {
+		t.Error("expected fast forward to be disabled")
+	}
+
+	const expected = `{"aggregations":[{"name":"count","type":"count"}],"dataSource":"metrics","dimensions":["host"],` +
+		`"granularity":"minute","intervals":["<$INTERVAL_TOKEN$>"],"queryType":"groupBy"}`
+	if trq.Statement != expected || trq.TemplateURL.Query().Get(upQueryBody) != expected {
+		t.Errorf("\nexpected [%s]\ngot      [%s]", expected, trq.Statement)
+	}
+
+	// the body is restored for proxying
+	if b, _ := ioutil.ReadAll(r.Body); !strings.Contains(string(b), testInterval) {
+		t.Errorf("expected restored body got %s", b)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "http://0/druid/v2", nil)
+	if _, err = parseQueryRequest(r); err != errors.ErrNotTimeRangeQuery {
+		t.Errorf("expected %v got %v", errors.ErrNotTimeRangeQuery, err)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "http://0/druid/v2", nil)
+	r.Body = nil
+	if _, err = parseQueryRequest(r); err == nil {
+		t.Error("expected error for missing body")
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "http://0/druid/v2", strings.NewReader(testQuery(qtTimeseries, `"all"`, testInterval, "")))
+	if _, err = parseQueryRequest(r); err != errors.ErrNotTimeRangeQuery {
+		t.Errorf("expected %v got %v", errors.ErrNotTimeRangeQuery, err)
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+
+	now := time.Now()
+	tests := []struct {
+		query string
+		start int64
+		end   int64
+		step  time.Duration
+		err   error
+	}{
+		{testQuery(qtTimeseries, `"MINUTE"`, testInterval, ""), 600, 779, time.Minute, nil},
+		{testQuery(qtTimeseries, `"five_minute"`, `["1970-01-01T00:10/PT1H"]`, ""), 600, 4199, 5 * time.Minute, nil},
+		{testQuery(qtTimeseries, `{"type":"period","period":"PT15M","timeZone":"UTC"}`, `"P1D/1970-01-02"`, ""), 0, 86399, 15 * time.Minute, nil},
+		{testQuery(qtTimeseries, `{"type":"duration","duration":90000}`, `{"type":"intervals","intervals":[`+testInterval+`]}`, ""), 600, 779, 90 * time.Second, nil},
+		{testQuery(qtTimeseries, `{"type":"hour"}`, `["1970-01-01T01:00:00.000+01:00/1970-01-01T03"]`, `,"context":{"grandTotal":false}`), 0, 10799, time.Hour, nil},
+		{testQuery(qtGroupBy, `"minute"`, testInterval, `,"limitSpec":{"type":"default"}`), 600, 779, time.Minute, nil},
+		// results that aren't in chronological order
+		{testQuery(qtTimeseries, `"minute"`, testInterval, `,"descending":"true"`), 0, 0, 0, errors.ErrNotTimeRangeQuery},
+		{testQuery(qtTimeseries, `"minute"`, testInterval, `,"limit":10`), 0, 0, 0, errors.ErrNotTimeRangeQuery},
+		{testQuery(qtTimeseries, `"minute"`, testInterval, `,"context":{"grandTotal":true}`), 0, 0, 0, errors.ErrNotTimeRangeQuery},
+		{testQuery(qtGroupBy, `"minute"`, testInterval, `,"limitSpec":{"type":"default","limit":10}`), 0, 0, 0, errors.ErrNotTimeRangeQuery},
+		{testQuery(qtGroupBy, `"minute"`, testInterval, `,"limitSpec":{"type":"default","columns":["count"]}`), 0, 0, 0, errors.ErrNotTimeRangeQuery},
+		{testQuery(qtGroupBy, `"minute"`, testInterval, `,"limitSpec":"none"`), 0, 0, 0, errors.ErrNotTimeRangeQuery},
+		{testQuery(qtGroupBy, `"minute"`, testInterval, `,"subtotalsSpec":[["host"]]`), 0, 0, 0, errors.ErrNotTimeRangeQuery},
+		{testQuery("topN", `"minute"`, testInterval, ""), 0, 0, 0, errors.ErrNotTimeRangeQuery},
+		// granularities that aren't fixed or aren't aligned to the epoch
+		{testQuery(qtTimeseries, `"week"`, testInterval, ""), 0, 0, 0, errors.ErrNotTimeRangeQuery},
+		{testQuery(qtTimeseries, `{"type":"all"}`, testInterval, ""), 0, 0, 0, errors.ErrNotTimeRangeQuery},
+		{testQuery(qtTimeseries, `{"type":"period","period":"P1M"}`, testInterval, ""), 0, 0, 0, errors.ErrNotTimeRangeQuery},
+		{testQuery(qtTimeseries, `{"type":"period","period":"PT7M"}`, testInterval, ""), 0, 0, 0, errors.ErrNotTimeRangeQuery},
+		{testQuery(qtTimeseries, `{"type":"period","period":"PT1H","timeZone":"America/New_York"}`, testInterval, ""), 0, 0, 0, errors.ErrNotTimeRangeQuery},
+		{testQuery(qtTimeseries, `{"type":"period","period":"PT1H","origin":"1970-01-01T00:30:00Z"}`, testInterval, ""), 0, 0, 0, errors.ErrNotTimeRangeQuery},
+		{testQuery(qtTimeseries, `{"type":"duration","duration":"60000"}`, testInterval, ""), 0, 0, 0, errors.ErrStepParse},
+		{testQuery(qtTimeseries, `{"type":"duration","duration":0}`, testInterval, ""), 0, 0, 0, errors.ErrStepParse},
+		{testQuery(qtTimeseries, `null`, testInterval, ""), 0, 0, 0, errors.ErrStepParse},
+		// unsupported intervals
+		{testQuery(qtTimeseries, `"minute"`, `[`+testInterval+`,`+testInterval+`]`, ""), 0, 0, 0, errors.ErrNotTimeRangeQuery},
+		{testQuery(qtTimeseries, `"minute"`, `"1970-01-01T00:10:00Z/1970-01-01T00:10:00Z"`, ""), 0, 0, 0, errors.ErrNotTimeRangeQuery},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			_, e, step, err := parseQuery([]byte(test.query), now)
+			if err != test.err {
+				t.Fatalf("expected %v got %v", test.err, err)
+			}
+			if err != nil {
+				return
+			}
+			if step != test.step {
+				t.Errorf("expected step %s got %s", test.step, step)
+			}
+			if !e.Start.Equal(time.Unix(test.start, 0)) || e.End.Unix() != test.end {
+				t.Errorf("expected %d-%d got %v", test.start, test.end, e)
+			}
+		})
+	}
+
+	// malformed queries and intervals
+	for _, q := range []string{
+		`{"queryType":`,
+		testQuery(qtTimeseries, `"minute"`, `null`, ""),
+		testQuery(qtTimeseries, `"minute"`, `"1970-01-01T00:10:00Z"`, ""),
+		testQuery(qtTimeseries, `"minute"`, `"1970-01-01T00:10:00Z/P1Y"`, ""),
+		testQuery(qtTimeseries, `"minute"`, `"P1Y/1970-01-01T00:10:00Z"`, ""),
+		testQuery(qtTimeseries, `"minute"`, `"PT1H/PT1H"`, ""),
+	} {
+		if _, _, _, err := parseQuery([]byte(q), now); err == nil || err == errors.ErrNotTimeRangeQuery {
+			t.Errorf("expected parse error for %s got %v", q, err)
+		}
+	}
+}
+
+func TestParsePeriod(t *testing.T) {
+
+	tests := []struct {
+		period   string
+		expected time.Duration
+	}{
+		{"PT1M", time.Minute},
+		{"PT1.5S", 1500 * time.Millisecond},
+		{"P1DT12H", 36 * time.Hour},
+		{"P1W", 7 * 24 * time.Hour},
+		{"P", 0},
+		{"PT0S", 0},
+		{"P1M", 0},
+	}
+
+	for _, test := range tests {
+		d, err := parsePeriod(test.period)
+		if test.expected == 0 {
+			if err == nil {
+				t.Errorf("expected error for %s", test.period)
+			}
+			continue
+		}
+		if err != nil || d != test.expected {
+			t.Errorf("expected %s got %s (%v)", test.expected, d, err)
+		}
+	}
+}
+
+func TestInterpolateQuery(t *testing.T) {
+	e := &timeseries.Extent{Start: time.Unix(600, 0), End: time.Unix(720, 0)}
+	const expected = `{"intervals":["1970-01-01T00:10:00.000Z/1970-01-01T00:13:00.000Z"]}`
+	if q := interpolateQuery(`{"intervals":["`+tkInterval+`"]}`, e, time.Minute); q != expected {
+		t.Errorf("\nexpected [%s]\ngot      [%s]", expected, q)
+	}
+}
+
+func TestIsTrue(t *testing.T) {
+	if !isTrue(true) || !isTrue("TRUE") || isTrue("false") || isTrue(1) || isTrue(nil) {
+		t.Error("unexpected result")
+	}
+}
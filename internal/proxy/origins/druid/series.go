@@ -0,0 +1,236 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package druid
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Comcast/trickster/internal/timeseries"
+	"github.com/Comcast/trickster/pkg/sort/times"
+)
+
+// SetExtents overwrites a Timeseries's known extents with the provided extent list
+func (rs *ResultSet) SetExtents(extents timeseries.ExtentList) {
+	rs.ExtentList = make(timeseries.ExtentList, len(extents))
+	copy(rs.ExtentList, extents)
+	rs.isCounted = false
+}
+
+// Extents returns the Timeseries's ExentList
+func (rs *ResultSet) Extents() timeseries.ExtentList {
+	return rs.ExtentList
+}
+
+// Step returns the step for the Timeseries
+func (rs *ResultSet) Step() time.Duration {
+	return rs.StepDuration
+}
+
+// SetStep sets the step for the Timeseries
+func (rs *ResultSet) SetStep(step time.Duration) {
+	rs.StepDuration = step
+}
+
+// SeriesCount returns the count of all Series in the Timeseries, which is
+// the greatest number of rows (or dimension sets) for any timestamp
+func (rs *ResultSet) SeriesCount() int {
+	rs.updateTimestamps()
+	c := 0
+	for _, n := range rs.timestamps {
+		if n > c {
+			c = n
+		}
+	}
+	return c
+}
+
+// ValueCount returns the count of all rows in the Timeseries
+func (rs *ResultSet) ValueCount() int {
+	return len(rs.Rows)
+}
+
+// TimestampCount returns the count of unique timestamps in the Timeseries
+func (rs *ResultSet) TimestampCount() int {
+	rs.updateTimestamps()
+	return len(rs.timestamps)
+}
+
+func (rs *ResultSet) updateTimestamps() {
+	if rs.isCounted {
+		return
+	}
+	m := make(map[time.Time]int)
+	for _, row := range rs.Rows {
+		m[row.Timestamp]++
+	}
+	rs.timestamps = m
+	rs.isCounted = true
+}
+
+// Merge merges the provided Timeseries list into the base Timeseries (in the order provided) and optionally sorts the merged Timeseries.
+// The rows for each timestamp are always fetched together, so the rows of a merged Timeseries replace any rows already present for the
+// same timestamps, including those for dimension sets that are no longer present.
+func (rs *ResultSet) Merge(sort bool, collection ...timeseries.Timeseries) {
+
+	for _, ts := range collection {
+		if ts == nil {
+			continue
+		}
+		rs2 := ts.(*ResultSet)
+		merged := make(map[time.Time]bool)
+		for _, row := range rs2.Rows {
+			merged[row.Timestamp] = true
+		}
+		rs.filterRows(func(row Row) bool { return !merged[row.Timestamp] })
+		rs.Rows = append(rs.Rows, rs2.Rows...)
+		rs.ExtentList = append(rs.ExtentList, rs2.ExtentList...)
+	}
+
+	rs.ExtentList = rs.ExtentList.Compress(rs.StepDuration)
+	rs.isSorted = false
+	rs.isCounted = false
+	if sort {
+		rs.Sort()
+	}
+}
+
+// Clone returns a perfect copy of the base Timeseries. The raw row data is never modified, so it is shared.
+func (rs *ResultSet) Clone() timeseries.Timeseries {
+	rs2 := &ResultSet{
+		Rows:         make([]Row, len(rs.Rows)),
+		StepDuration: rs.StepDuration,
+		ExtentList:   make(timeseries.ExtentList, len(rs.ExtentList)),
+		isSorted:     rs.isSorted,
+	}
+	copy(rs2.ExtentList, rs.ExtentList)
+	copy(rs2.Rows, rs.Rows)
+	return rs2
+}
+
+// CropToSize reduces the number of elements in the Timeseries to the provided count, by evicting elements
+// using a least-recently-used methodology. The time parameter limits the upper extent to the provided time,
+// in order to support backfill tolerance
+func (rs *ResultSet) CropToSize(sz int, t time.Time, lur timeseries.Extent) {
+
+	rs.isCounted = false
+	rs.isSorted = false
+	x := len(rs.ExtentList)
+	// The Series has no extents, so no need to do anything
+	if x < 1 {
+		rs.Rows = []Row{}
+		rs.ExtentList = timeseries.ExtentList{}
+		return
+	}
+
+	// Crop to the Backfill Tolerance Value if needed
+	if rs.ExtentList[x-1].End.After(t) {
+		rs.CropToRange(timeseries.Extent{Start: rs.ExtentList[0].Start, End: t})
+	}
+
+	tc := rs.TimestampCount()
+	if len(rs.Rows) == 0 || tc <= sz {
+		return
+	}
+
+	el := timeseries.ExtentListLRU(rs.ExtentList).UpdateLastUsed(lur, rs.StepDuration)
+	sort.Sort(el)
+
+	rc := tc - sz // # of required timestamps we must delete to meet the rentention policy
+	removals := make(map[time.Time]bool)
+	done := false
+
+	for _, x := range el {
+		for ts := x.Start; !x.End.Before(ts) && !done; ts = ts.Add(rs.StepDuration) {
+			// row timestamps are in UTC, while extents may be in any location
+			if _, ok := rs.timestamps[ts.UTC()]; ok {
+				removals[ts.UTC()] = true
+				done = len(removals) >= rc
+			}
+		}
+		if done {
+			break
+		}
+	}
+
+	rs.filterRows(func(row Row) bool { return !removals[row.Timestamp] })
+
+	tl := times.FromMap(removals)
+	sort.Sort(tl)
+	for _, t := range tl {
+		for i, e := range el {
+			if e.StartsAt(t) {
+				el[i].Start = e.Start.Add(rs.StepDuration)
+			}
+		}
+	}
+
+	rs.ExtentList = timeseries.ExtentList(el).Compress(rs.StepDuration)
+	rs.Sort()
+}
+
+// CropToRange reduces the Timeseries down to timestamps contained within the provided Extents (inclusive).
+func (rs *ResultSet) CropToRange(e timeseries.Extent) {
+
+	rs.isCounted = false
+	x := len(rs.ExtentList)
+	// The Series has no extents, or is entirely outside of the crop range, so return an empty set
+	if x < 1 || rs.ExtentList.OutsideOf(e) {
+		rs.Rows = []Row{}
+		rs.ExtentList = timeseries.ExtentList{}
+		return
+	}
+
+	rs.filterRows(func(row Row) bool {
+		return !row.Timestamp.Before(e.Start) && !row.Timestamp.After(e.End)
+	})
+	rs.ExtentList = rs.ExtentList.Crop(e)
+}
+
+// filterRows retains only the rows for which keep returns true
+func (rs *ResultSet) filterRows(keep func(Row) bool) {
+	rows := rs.Rows[:0]
+	for _, row := range rs.Rows {
+		if keep(row) {
+			rows = append(rows, row)
+		}
+	}
+	rs.Rows = rows
+}
+
+// Sort sorts the rows chronologically by their timestamp, retaining the order of the rows for each timestamp
+func (rs *ResultSet) Sort() {
+
+	if rs.isSorted {
+		return
+	}
+
+	sort.SliceStable(rs.Rows, func(i, j int) bool { return rs.Rows[i].Timestamp.Before(rs.Rows[j].Timestamp) })
+	sort.Sort(rs.ExtentList)
+
+	rs.isCounted = false
+	rs.isSorted = true
+}
+
+// Size returns the approximate memory utilization in bytes of the timeseries
+func (rs *ResultSet) Size() int {
+	size := 0
+	for _, row := range rs.Rows {
+		// Timestamp
+		size += 24 + len(row.Data)
+	}
+	// ExtentList + StepDuration + Timestamps + isCounted + isSorted
+	size += (len(rs.ExtentList) * 24) + 8 + (len(rs.timestamps) * 16) + 2
+	return size
+}
@@ -0,0 +1,172 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package druid
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+func TestSetStep(t *testing.T) {
+	rs := &ResultSet{}
+	const step = time.Duration(300) * time.Minute
+	rs.SetStep(step)
+	if rs.Step() != step {
+		t.Errorf(`expected "%s". got "%s"`, step, rs.Step())
+	}
+}
+
+func TestSetExtents(t *testing.T) {
+	rs := &ResultSet{}
+	ex := timeseries.ExtentList{{Start: time.Unix(0, 0), End: time.Unix(60, 0)}}
+	rs.SetExtents(ex)
+	if len(rs.Extents()) != 1 || !rs.Extents()[0].End.Equal(ex[0].End) {
+		t.Errorf("expected %v got %v", ex, rs.Extents())
+	}
+}
+
+func TestMerge(t *testing.T) {
+
+	rs := testResultSet(t, []string{"a"}, 600, 720)
+	rs2 := testResultSet(t, []string{"a", "b"}, 780, 900)
+
+	rs.Merge(true, rs2, nil)
+
+	if rs.SeriesCount() != 2 {
+		t.Errorf("expected %d got %d", 2, rs.SeriesCount())
+	}
+
+	if rs.ValueCount() != 9 {
+		t.Errorf("expected %d got %d", 9, rs.ValueCount())
+	}
+
+	if rs.TimestampCount() != 6 {
+		t.Errorf("expected %d got %d", 6, rs.TimestampCount())
+	}
+
+	if len(rs.ExtentList) != 1 || !rs.ExtentList[0].Start.Equal(time.Unix(600, 0)) ||
+		!rs.ExtentList[0].End.Equal(time.Unix(900, 0)) {
+		t.Errorf("unexpected extents %v", rs.ExtentList)
+	}
+
+	// the rows for a timestamp are replaced by those of the merged result set, in its order
+	rs = testResultSet(t, []string{"a", "b"}, 600, 720)
+	rs2 = testResultSet(t, []string{"c", "a"}, 660, 660)
+	rs.Merge(true, rs2)
+
+	if rs.ValueCount() != 6 {
+		t.Errorf("expected %d got %d", 6, rs.ValueCount())
+	}
+	if string(rs.Rows[2].Data) != string(rs2.Rows[0].Data) || string(rs.Rows[3].Data) != string(rs2.Rows[1].Data) {
+		t.Errorf("unexpected rows %s %s", rs.Rows[2].Data, rs.Rows[3].Data)
+	}
+}
+
+func TestSort(t *testing.T) {
+
+	rs := testResultSet(t, nil, 600, 720)
+	rs2 := testResultSet(t, nil, 480, 540)
+	rs.Merge(false, rs2)
+
+	rs.Sort()
+	for i := 1; i < len(rs.Rows); i++ {
+		if rs.Rows[i].Timestamp.Before(rs.Rows[i-1].Timestamp) {
+			t.Errorf("rows are not sorted at %d", i)
+		}
+	}
+
+	// sorting is a no-op once sorted
+	rs.Sort()
+	if rs.ValueCount() != 5 || rs.TimestampCount() != 5 {
+		t.Errorf("expected %d got %d", 5, rs.ValueCount())
+	}
+}
+
+func TestClone(t *testing.T) {
+
+	rs := testResultSet(t, []string{"a", "b"}, 600, 720)
+
+	rs2 := rs.Clone().(*ResultSet)
+	if rs2.ValueCount() != rs.ValueCount() || rs2.Step() != rs.Step() || len(rs2.ExtentList) != 1 {
+		t.Errorf("clone mismatch")
+	}
+
+	// the clone is independent of the original
+	rs2.CropToRange(timeseries.Extent{Start: time.Unix(600, 0), End: time.Unix(600, 0)})
+	if rs.ValueCount() != 6 || rs2.ValueCount() != 2 {
+		t.Errorf("expected 6 and 2 rows got %d and %d", rs.ValueCount(), rs2.ValueCount())
+	}
+}
+
+func TestCropToRange(t *testing.T) {
+
+	rs := testResultSet(t, []string{"a", "b"}, 600, 720)
+	rs.CropToRange(timeseries.Extent{Start: time.Unix(660, 0), End: time.Unix(900, 0)})
+	if rs.ValueCount() != 4 {
+		t.Errorf("expected %d got %d", 4, rs.ValueCount())
+	}
+	if len(rs.ExtentList) != 1 || !rs.ExtentList[0].Start.Equal(time.Unix(660, 0)) {
+		t.Errorf("unexpected extents %v", rs.ExtentList)
+	}
+
+	// outside of the extents
+	rs.CropToRange(timeseries.Extent{Start: time.Unix(0, 0), End: time.Unix(60, 0)})
+	if rs.ValueCount() != 0 || len(rs.ExtentList) != 0 {
+		t.Errorf("expected empty result set got %d rows", rs.ValueCount())
+	}
+}
+
+func TestCropToSize(t *testing.T) {
+
+	now := time.Now().Truncate(time.Minute)
+	start := now.Add(-10 * time.Minute)
+
+	rs := testResultSet(t, []string{"a", "b"}, start.Unix(), now.Unix())
+	rs.CropToSize(5, now, timeseries.Extent{Start: start, End: now})
+
+	if rs.TimestampCount() != 5 {
+		t.Errorf("expected %d got %d", 5, rs.TimestampCount())
+	}
+	if rs.ValueCount() != 10 {
+		t.Errorf("expected %d got %d", 10, rs.ValueCount())
+	}
+	if len(rs.ExtentList) != 1 || !rs.ExtentList[0].Start.Equal(now.Add(-4*time.Minute)) {
+		t.Errorf("unexpected extents %v", rs.ExtentList)
+	}
+
+	// backfill tolerance
+	rs = testResultSet(t, nil, start.Unix(), now.Unix())
+	rs.CropToSize(100, now.Add(-time.Minute), timeseries.Extent{Start: start, End: now})
+	if rs.TimestampCount() != 10 {
+		t.Errorf("expected %d got %d", 10, rs.TimestampCount())
+	}
+
+	// no extents
+	rs = testResultSet(t, nil, start.Unix(), now.Unix())
+	rs.ExtentList = nil
+	rs.CropToSize(5, now, timeseries.Extent{})
+	if rs.ValueCount() != 0 {
+		t.Errorf("expected %d got %d", 0, rs.ValueCount())
+	}
+}
+
+func TestSize(t *testing.T) {
+	rs := testResultSet(t, nil, 600, 600)
+	const expected = 120
+	if rs.Size() != expected {
+		t.Errorf("expected %d got %d", expected, rs.Size())
+	}
+}
@@ -0,0 +1,88 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package druid
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/trickster/internal/config"
+	"github.com/Comcast/trickster/internal/proxy/headers"
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+func TestSetExtent(t *testing.T) {
+
+	client := &Client{}
+	e := &timeseries.Extent{Start: time.Unix(600, 0), End: time.Unix(720, 0)}
+
+	r := httptest.NewRequest(http.MethodPost, "http://0/druid/v2",
+		strings.NewReader(`{"queryType":"timeseries","granularity":"minute","intervals":"1970-01-01/P1D"}`))
+	trq, err := parseQueryRequest(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client.SetExtent(r, trq, e)
+	b, _ := ioutil.ReadAll(r.Body)
+	const expected = `{"granularity":"minute","intervals":["1970-01-01T00:10:00.000Z/1970-01-01T00:13:00.000Z"],"queryType":"timeseries"}`
+	if string(b) != expected {
+		t.Errorf("\nexpected [%s]\ngot      [%s]", expected, b)
+	}
+
+	if r.ContentLength != int64(len(b)) {
+		t.Errorf("expected %d got %d", len(b), r.ContentLength)
+	}
+
+	if ct := r.Header.Get(headers.NameContentType); ct != headers.ValueApplicationJSON {
+		t.Errorf("expected %s got %s", headers.ValueApplicationJSON, ct)
+	}
+
+	// a nil extent leaves the request unchanged
+	r.Body = ioutil.NopCloser(strings.NewReader("test"))
+	client.SetExtent(r, trq, nil)
+	if b, _ = ioutil.ReadAll(r.Body); string(b) != "test" {
+		t.Errorf("expected %s got %s", "test", b)
+	}
+}
+
+func TestBuildUpstreamURL(t *testing.T) {
+
+	cfg := config.NewConfig()
+	oc := cfg.Origins["default"]
+	oc.Scheme = "http"
+	oc.Host = "0"
+	oc.PathPrefix = ""
+
+	client := &Client{name: "default", config: oc}
+	r, err := http.NewRequest(http.MethodPost, "http://0/default/druid/v2?pretty", nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	u := client.BuildUpstreamURL(r)
+	if u.Path != "/druid/v2" || u.RawQuery != "pretty" {
+		t.Errorf("expected %s got %s", "/druid/v2?pretty", u)
+	}
+
+	r, _ = http.NewRequest(http.MethodPost, "http://0/druid/v2", nil)
+	u = client.BuildUpstreamURL(r)
+	if u.Path != "/druid/v2" {
+		t.Errorf("expected %s got %s", "/druid/v2", u.Path)
+	}
+}
@@ -0,0 +1,31 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package elasticsearch
+
+import (
+	"net/http"
+
+	"github.com/Comcast/trickster/internal/proxy/engines"
+)
+
+// SearchHandler handles _search and _msearch requests for Elasticsearch and processes them through
+// the delta proxy cache. All other requests are proxied.
+func (c *Client) SearchHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := searchType(r.URL.Path); !ok {
+		c.ProxyHandler(w, r)
+		return
+	}
+	r.URL = c.BuildUpstreamURL(r)
+	engines.DeltaProxyCacheRequest(w, r)
+}
@@ -0,0 +1,90 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package elasticsearch
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/trickster/internal/proxy/request"
+	tu "github.com/Comcast/trickster/internal/util/testing"
+)
+
+func TestSearchHandler(t *testing.T) {
+
+	client := &Client{name: "test"}
+	end := time.Now().Truncate(time.Minute)
+	start := end.Add(-5 * time.Minute)
+	response := testResponse([]string{"a"}, start.Unix(), end.Unix())
+
+	ts, w, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, response, nil, "elasticsearch", "/logs-*/_search", "debug")
+	ctx := r.Context()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	defer ts.Close()
+	if err != nil {
+		t.Error(err)
+	}
+
+	ms := func(t time.Time) string { return strconv.FormatInt(t.Unix()*1000, 10) }
+	r = httptest.NewRequest(http.MethodPost, ts.URL+"/logs-*/_search",
+		strings.NewReader(testSearchBody(ms(start), ms(end))))
+	r = r.WithContext(ctx)
+
+	// searches for date histograms go through the delta proxy cache
+	client.SearchHandler(w, r)
+	resp := w.Result()
+
+	// it should return 200 OK
+	if resp.StatusCode != 200 {
+		t.Errorf("expected 200 got %d.", resp.StatusCode)
+	}
+
+	if h := resp.Header.Get("X-Trickster-Result"); !strings.Contains(h, "engine=DeltaProxyCache") {
+		t.Errorf("expected delta proxy cache engine got %s", h)
+	}
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// the marker is not returned to the client
+	if strings.Contains(string(bodyBytes), metaMarker) || !strings.Contains(string(bodyBytes), `"key":"a"`) {
+		t.Errorf("unexpected response body %s", bodyBytes)
+	}
+
+	// all other paths are proxied
+	r = httptest.NewRequest(http.MethodGet, ts.URL+"/_cat/indices", nil)
+	r = r.WithContext(ctx)
+	w = httptest.NewRecorder()
+
+	client.SearchHandler(w, r)
+	resp = w.Result()
+	if resp.StatusCode != 200 {
+		t.Errorf("expected 200 got %d.", resp.StatusCode)
+	}
+
+	if h := resp.Header.Get("X-Trickster-Result"); !strings.Contains(h, "engine=HTTPProxy") {
+		t.Errorf("expected http proxy engine got %s", h)
+	}
+}
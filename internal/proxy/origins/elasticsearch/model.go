@@ -0,0 +1,408 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+// Search response field names with special meaning to Trickster
+const (
+	fnResponses = "responses"
+	fnBuckets   = "buckets"
+	fnKey       = "key"
+	fnError     = "error"
+)
+
+// envelopePrefix identifies a Document that was marshaled for cache storage
+var envelopePrefix = []byte(`{"multi":`)
+
+// markerBytes identifies raw response fields that contain a marked date histogram
+var markerBytes = []byte(`"` + metaMarker + `"`)
+
+// Document represents a _search or _msearch response. Only the date histogram
+// aggregations within each response are treated as time series; all other
+// fields are carried as-is.
+type Document struct {
+	Fields       map[string]json.RawMessage
+	Responses    []*Response
+	Multi        bool
+	StepDuration time.Duration
+	ExtentList   timeseries.ExtentList
+
+	timestamps map[time.Time]bool // tracks unique timestamps in the document
+	isSorted   bool               // tracks if the document is currently sorted
+	isCounted  bool               // tracks if timestamps map is up-to-date
+}
+
+// Response represents a single search response
+type Response struct {
+	Fields       map[string]json.RawMessage
+	Aggregations map[string]*Aggregation
+}
+
+// Aggregation represents an aggregation result that contains a date histogram. The buckets
+// of a date histogram are its datapoints, while the buckets of any other aggregation are
+// descended to reach the date histograms within them.
+type Aggregation struct {
+	Fields       map[string]json.RawMessage
+	IsHistogram  bool
+	Buckets      []*Bucket
+	Aggregations map[string]*Aggregation
+
+	keyed bool
+}
+
+// Bucket represents a single bucket of an Aggregation
+type Bucket struct {
+	Key          string
+	Timestamp    time.Time
+	Fields       map[string]json.RawMessage
+	Aggregations map[string]*Aggregation
+}
+
+// documentEnvelope is the cached representation of a Document, which carries the step and extents
+type documentEnvelope struct {
+	Multi        bool                       `json:"multi"`
+	Fields       map[string]json.RawMessage `json:"fields,omitempty"`
+	Responses    []json.RawMessage          `json:"responses"`
+	StepDuration time.Duration              `json:"step,omitempty"`
+	ExtentList   timeseries.ExtentList      `json:"extents,omitempty"`
+}
+
+// MarshalTimeseries converts a Timeseries into a JSON blob
+func (c *Client) MarshalTimeseries(ts timeseries.Timeseries) ([]byte, error) {
+	d, ok := ts.(*Document)
+	if !ok {
+		return nil, fmt.Errorf("unsupported timeseries type: %T", ts)
+	}
+	return d.marshal()
+}
+
+// UnmarshalTimeseries converts a JSON blob into a Timeseries
+func (c *Client) UnmarshalTimeseries(data []byte) (timeseries.Timeseries, error) {
+	return unmarshalDocument(data)
+}
+
+// unmarshalDocument converts a cached Document or a search response body into a Document
+func unmarshalDocument(data []byte) (*Document, error) {
+
+	if bytes.HasPrefix(data, envelopePrefix) {
+		de := &documentEnvelope{}
+		if err := json.Unmarshal(data, de); err != nil {
+			return nil, err
+		}
+		d := &Document{Fields: de.Fields, Multi: de.Multi, StepDuration: de.StepDuration, ExtentList: de.ExtentList}
+		return d, d.parseResponses(de.Responses)
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	raw, ok := fields[fnResponses]
+	if !ok {
+		r, err := newResponse(fields)
+		if err != nil {
+			return nil, err
+		}
+		return &Document{Responses: []*Response{r}}, nil
+	}
+
+	delete(fields, fnResponses)
+	d := &Document{Fields: fields, Multi: true}
+	var rs []json.RawMessage
+	if err := json.Unmarshal(raw, &rs); err != nil {
+		return nil, err
+	}
+	return d, d.parseResponses(rs)
+}
+
+func (d *Document) parseResponses(rs []json.RawMessage) error {
+	d.Responses = make([]*Response, len(rs))
+	for i, raw := range rs {
+		fields := make(map[string]json.RawMessage)
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return err
+		}
+		r, err := newResponse(fields)
+		if err != nil {
+			return err
+		}
+		d.Responses[i] = r
+	}
+	return nil
+}
+
+func newResponse(fields map[string]json.RawMessage) (*Response, error) {
+	if e, ok := fields[fnError]; ok {
+		return nil, fmt.Errorf("search response contains an error: %s", e)
+	}
+	r := &Response{Fields: fields}
+	if raw, ok := fields[fnAggregations]; ok {
+		delete(fields, fnAggregations)
+		aggs := make(map[string]json.RawMessage)
+		if err := json.Unmarshal(raw, &aggs); err != nil {
+			return nil, err
+		}
+		r.Aggregations = make(map[string]*Aggregation, len(aggs))
+		for k, v := range aggs {
+			a, err := parseAggregation(v)
+			if err != nil {
+				return nil, err
+			}
+			r.Aggregations[k] = a
+		}
+	}
+	return r, nil
+}
+
+func parseAggregation(raw json.RawMessage) (*Aggregation, error) {
+
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	a := &Aggregation{Fields: fields}
+
+	// the marker is removed from the meta, and is restored when marshaling for cache storage
+	if m, ok := fields[fnMeta]; ok {
+		meta := make(map[string]json.RawMessage)
+		if err := json.Unmarshal(m, &meta); err == nil {
+			if _, ok := meta[metaMarker]; ok {
+				a.IsHistogram = true
+				delete(meta, metaMarker)
+				if len(meta) == 0 {
+					delete(fields, fnMeta)
+				} else {
+					fields[fnMeta], _ = json.Marshal(meta)
+				}
+			}
+		}
+	}
+
+	raw, ok := fields[fnBuckets]
+	if a.IsHistogram {
+		delete(fields, fnBuckets)
+		var bs []map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &bs); err != nil {
+			return nil, err
+		}
+		a.Buckets = make([]*Bucket, len(bs))
+		for i, b := range bs {
+			ms, err := strconv.ParseInt(string(b[fnKey]), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid date histogram bucket key: %s", b[fnKey])
+			}
+			a.Buckets[i] = &Bucket{Key: string(b[fnKey]), Timestamp: time.Unix(0, ms*int64(time.Millisecond)).UTC(), Fields: b}
+		}
+		return a, nil
+	}
+
+	if ok && bytes.Contains(raw, markerBytes) {
+		delete(fields, fnBuckets)
+		if err := a.parseBuckets(raw); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	a.Aggregations, err = parseSubAggregations(fields)
+	return a, err
+}
+
+// parseBuckets parses the buckets of a non-histogram aggregation, which are either
+// a list or, for keyed aggregations like filters, an object
+func (a *Aggregation) parseBuckets(raw json.RawMessage) error {
+
+	if t := bytes.TrimSpace(raw); len(t) > 0 && t[0] == '{' {
+		km := make(map[string]json.RawMessage)
+		if err := json.Unmarshal(raw, &km); err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(km))
+		for k := range km {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		a.keyed = true
+		a.Buckets = make([]*Bucket, len(keys))
+		for i, k := range keys {
+			b, err := parseBucket(km[k])
+			if err != nil {
+				return err
+			}
+			b.Key = k
+			a.Buckets[i] = b
+		}
+		return nil
+	}
+
+	var bs []json.RawMessage
+	if err := json.Unmarshal(raw, &bs); err != nil {
+		return err
+	}
+	a.Buckets = make([]*Bucket, len(bs))
+	for i, v := range bs {
+		b, err := parseBucket(v)
+		if err != nil {
+			return err
+		}
+		a.Buckets[i] = b
+	}
+	return nil
+}
+
+func parseBucket(raw json.RawMessage) (*Bucket, error) {
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	b := &Bucket{Key: string(fields[fnKey]), Fields: fields}
+	var err error
+	b.Aggregations, err = parseSubAggregations(fields)
+	return b, err
+}
+
+// parseSubAggregations moves the fields that contain a marked date histogram out of
+// the provided fields, and returns them as Aggregations
+func parseSubAggregations(fields map[string]json.RawMessage) (map[string]*Aggregation, error) {
+	var aggs map[string]*Aggregation
+	for k, v := range fields {
+		if t := bytes.TrimSpace(v); len(t) == 0 || t[0] != '{' || !bytes.Contains(v, markerBytes) {
+			continue
+		}
+		a, err := parseAggregation(v)
+		if err != nil {
+			return nil, err
+		}
+		if aggs == nil {
+			aggs = make(map[string]*Aggregation)
+		}
+		aggs[k] = a
+		delete(fields, k)
+	}
+	return aggs, nil
+}
+
+// marshal returns the Document as a search response for client responses, or as a
+// JSON envelope that carries the step and extents for cache storage
+func (d *Document) marshal() ([]byte, error) {
+
+	if len(d.ExtentList) == 0 && d.StepDuration == 0 {
+		if !d.Multi {
+			if len(d.Responses) == 0 {
+				return []byte("{}"), nil
+			}
+			return marshalJSON(d.Responses[0].export(false))
+		}
+		m := make(map[string]interface{}, len(d.Fields)+1)
+		for k, v := range d.Fields {
+			m[k] = v
+		}
+		rs := make([]interface{}, len(d.Responses))
+		for i, r := range d.Responses {
+			rs[i] = r.export(false)
+		}
+		m[fnResponses] = rs
+		return marshalJSON(m)
+	}
+
+	de := &documentEnvelope{Multi: d.Multi, Fields: d.Fields, StepDuration: d.StepDuration,
+		ExtentList: d.ExtentList, Responses: make([]json.RawMessage, len(d.Responses))}
+	for i, r := range d.Responses {
+		b, err := marshalJSON(r.export(true))
+		if err != nil {
+			return nil, err
+		}
+		de.Responses[i] = b
+	}
+	return marshalJSON(de)
+}
+
+// export returns the Response as a map that can be marshaled, optionally marking its date histograms
+func (r *Response) export(marker bool) map[string]interface{} {
+	m := make(map[string]interface{}, len(r.Fields)+1)
+	for k, v := range r.Fields {
+		m[k] = v
+	}
+	if r.Aggregations != nil {
+		m[fnAggregations] = exportAggregations(r.Aggregations, marker)
+	}
+	return m
+}
+
+func exportAggregations(aggs map[string]*Aggregation, marker bool) map[string]interface{} {
+	m := make(map[string]interface{}, len(aggs))
+	for k, a := range aggs {
+		m[k] = a.export(marker)
+	}
+	return m
+}
+
+func (a *Aggregation) export(marker bool) map[string]interface{} {
+
+	m := make(map[string]interface{}, len(a.Fields)+len(a.Aggregations)+2)
+	for k, v := range a.Fields {
+		m[k] = v
+	}
+	for k, v := range exportAggregations(a.Aggregations, marker) {
+		m[k] = v
+	}
+
+	if a.IsHistogram && marker {
+		meta := make(map[string]interface{})
+		if raw, ok := a.Fields[fnMeta]; ok {
+			json.Unmarshal(raw, &meta)
+		}
+		meta[metaMarker] = true
+		m[fnMeta] = meta
+	}
+
+	switch {
+	case a.keyed:
+		km := make(map[string]interface{}, len(a.Buckets))
+		for _, b := range a.Buckets {
+			km[b.Key] = b.export(marker)
+		}
+		m[fnBuckets] = km
+	case a.IsHistogram || a.Buckets != nil:
+		bs := make([]interface{}, len(a.Buckets))
+		for i, b := range a.Buckets {
+			bs[i] = b.export(marker)
+		}
+		m[fnBuckets] = bs
+	}
+
+	return m
+}
+
+func (b *Bucket) export(marker bool) map[string]interface{} {
+	m := make(map[string]interface{}, len(b.Fields)+len(b.Aggregations))
+	for k, v := range b.Fields {
+		m[k] = v
+	}
+	for k, v := range exportAggregations(b.Aggregations, marker) {
+		m[k] = v
+	}
+	return m
+}
@@ -0,0 +1,193 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package elasticsearch
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+// testResponse returns a search response with a terms aggregation of the provided hosts,
+// each with a marked date histogram with one-minute buckets from start to end (in seconds)
+func testResponse(hosts []string, start, end int64) string {
+	hb := make([]string, len(hosts))
+	for i, h := range hosts {
+		bs := make([]string, 0, (end-start)/60+1)
+		for ts := start; ts <= end; ts += 60 {
+			bs = append(bs, fmt.Sprintf(`{"1":{"value":%d},"doc_count":1,"key":%d,"key_as_string":"%d"}`, ts, ts*1000, ts*1000))
+		}
+		hb[i] = fmt.Sprintf(`{"2":{"buckets":[%s],"meta":{"%s":true}},"doc_count":%d,"key":"%s"}`,
+			strings.Join(bs, ","), metaMarker, len(bs), h)
+	}
+	return fmt.Sprintf(`{"aggregations":{"3":{"buckets":[%s],"sum_other_doc_count":0}},"hits":{"hits":[],"total":{"relation":"eq","value":0}},"took":1}`,
+		strings.Join(hb, ","))
+}
+
+func testDocument(t *testing.T, hosts []string, start, end int64) *Document {
+	d, err := unmarshalDocument([]byte(testResponse(hosts, start, end)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.SetStep(time.Minute)
+	d.SetExtents(timeseries.ExtentList{{Start: time.Unix(start, 0), End: time.Unix(end, 0)}})
+	return d
+}
+
+func TestUnmarshalTimeseries(t *testing.T) {
+
+	client := &Client{}
+	ts, err := client.UnmarshalTimeseries([]byte(testResponse([]string{"a", "b"}, 600, 720)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := ts.(*Document)
+	if d.Multi || len(d.Responses) != 1 {
+		t.Errorf("expected a single response got %d", len(d.Responses))
+	}
+
+	if d.SeriesCount() != 2 || d.ValueCount() != 6 {
+		t.Errorf("expected 2 series with 6 values got %d with %d", d.SeriesCount(), d.ValueCount())
+	}
+
+	// client responses have the marker removed from the meta
+	b, err := client.MarshalTimeseries(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.Replace(testResponse([]string{"a", "b"}, 600, 720), `,"meta":{"`+metaMarker+`":true}`, "", -1)
+	if string(b) != expected {
+		t.Errorf("\nexpected [%s]\ngot      [%s]", expected, b)
+	}
+
+	// cached documents retain the marker, step and extents
+	d.SetStep(time.Minute)
+	d.SetExtents(timeseries.ExtentList{{Start: time.Unix(600, 0), End: time.Unix(720, 0)}})
+	b, err = client.MarshalTimeseries(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts, err = client.UnmarshalTimeseries(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ts.Step() != time.Minute || len(ts.Extents()) != 1 || ts.ValueCount() != 6 {
+		t.Errorf("unexpected cached document %s", b)
+	}
+
+	_, err = client.MarshalTimeseries(nil)
+	if err == nil {
+		t.Error("expected error for unsupported timeseries")
+	}
+}
+
+func TestUnmarshalMultiSearch(t *testing.T) {
+
+	r := testResponse([]string{"a"}, 600, 660)
+	body := `{"responses":[` + r + `,` + r + `],"took":2}`
+
+	d, err := unmarshalDocument([]byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !d.Multi || len(d.Responses) != 2 || d.ValueCount() != 4 {
+		t.Errorf("expected 2 responses with 4 values got %d with %d", len(d.Responses), d.ValueCount())
+	}
+
+	b, err := d.marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := strings.Replace(body, `,"meta":{"`+metaMarker+`":true}`, "", -1)
+	if string(b) != expected {
+		t.Errorf("\nexpected [%s]\ngot      [%s]", expected, b)
+	}
+
+	d.SetStep(time.Minute)
+	b, err = d.marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err = unmarshalDocument(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Multi || d.Fields["took"] == nil || d.ValueCount() != 4 {
+		t.Errorf("unexpected cached document %s", b)
+	}
+}
+
+func TestUnmarshalKeyedBuckets(t *testing.T) {
+
+	// filters aggregations return their buckets as an object, and meta that isn't
+	// the marker is retained
+	const body = `{"aggregations":{"f":{"buckets":{"x":{"doc_count":1,"h":{"buckets":[{"doc_count":1,"key":60000}],"meta":{"a":1,"` +
+		metaMarker + `":true}}},"y":{"doc_count":0}}}}}`
+
+	d, err := unmarshalDocument([]byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := d.Responses[0].Aggregations["f"]
+	if !a.keyed || len(a.Buckets) != 2 || a.Fields["buckets"] != nil {
+		t.Errorf("unexpected keyed aggregation %v", a)
+	}
+
+	b, _ := d.marshal()
+	const expected = `{"aggregations":{"f":{"buckets":{"x":{"doc_count":1,"h":{"buckets":[{"doc_count":1,"key":60000}],"meta":{"a":1}}},"y":{"doc_count":0}}}}}`
+	if string(b) != expected {
+		t.Errorf("\nexpected [%s]\ngot      [%s]", expected, b)
+	}
+}
+
+func TestUnmarshalDocumentErrors(t *testing.T) {
+
+	tests := []string{
+		`[`,
+		`{"multi":true,"responses":[`,
+		`{"multi":true,"responses":[[]]}`,
+		`{"responses":{}}`,
+		`{"responses":[{"error":{"type":"x"},"status":400}]}`,
+		`{"aggregations":[]}`,
+		`{"aggregations":{"a":[]}}`,
+		`{"aggregations":{"a":{"meta":{"` + metaMarker + `":true},"buckets":{}}}}`,
+		`{"aggregations":{"a":{"meta":{"` + metaMarker + `":true},"buckets":[{"key":"x"}]}}}`,
+		`{"aggregations":{"a":{"buckets":{"x":[]},"meta":{"` + metaMarker + `":true}}}}`,
+		`{"aggregations":{"a":{"buckets":[["` + metaMarker + `"]]}}}`,
+		`{"aggregations":{"a":{"buckets":{"x":["` + metaMarker + `"]}}}}`,
+		`{"aggregations":{"a":{"buckets":{"x":` + metaMarker + `}}}}`,
+		`{"aggregations":{"a":{"h":{"buckets":{},"meta":{"` + metaMarker + `":true}}}}}`,
+	}
+
+	for _, test := range tests {
+		if _, err := unmarshalDocument([]byte(test)); err == nil {
+			t.Errorf("expected error for %s", test)
+		}
+	}
+}
+
+func TestMarshalEmptyDocument(t *testing.T) {
+	b, _ := (&Document{}).marshal()
+	if string(b) != "{}" {
+		t.Errorf("expected %s got %s", "{}", b)
+	}
+}
@@ -0,0 +1,493 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Comcast/trickster/internal/proxy/errors"
+	"github.com/Comcast/trickster/internal/proxy/urls"
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+// This file handles parsing and tokenization of the time range within
+// Elasticsearch search requests for cache key hashing and delta proxy caching.
+
+// Tokens for String Interpolation. Each is a JSON string in the tokenized request,
+// and is replaced along with its quotes by an epoch milliseconds value.
+const (
+	tkStart     = "<$START_TOKEN$>"
+	tkEnd       = "<$END_TOKEN$>"
+	tkBoundsMin = "<$BOUNDS_MIN_TOKEN$>"
+	tkBoundsMax = "<$BOUNDS_MAX_TOKEN$>"
+)
+
+// Search request field names with special meaning to Trickster
+const (
+	fnSize             = "size"
+	fnQuery            = "query"
+	fnAggs             = "aggs"
+	fnAggregations     = "aggregations"
+	fnMeta             = "meta"
+	fnRange            = "range"
+	fnDateHistogram    = "date_histogram"
+	fnField            = "field"
+	fnFixedInterval    = "fixed_interval"
+	fnOffset           = "offset"
+	fnKeyed            = "keyed"
+	fnTimeZone         = "time_zone"
+	fnExtendedBounds   = "extended_bounds"
+	fnFormat           = "format"
+	formatEpochMillis  = "epoch_millis"
+	formatEpochSeconds = "epoch_second"
+)
+
+// upSearchBody is the name of the TemplateURL parameter holding the tokenized
+// request body, so that it is factored into the cache key
+const upSearchBody = "search_body"
+
+// metaMarker is added to the meta of each date histogram aggregation in upstream
+// requests, so the histograms can be identified in the responses
+const metaMarker = "trickster_date_histogram"
+
+var reFixedInterval, reDateMath *regexp.Regexp
+
+var utcZones = map[string]bool{"utc": true, "z": true, "gmt": true, "etc/utc": true,
+	"etc/gmt": true, "+00:00": true, "-00:00": true}
+
+func init() {
+	// Regexp for parsing a fixed_interval, such as 30s or 1h
+	reFixedInterval = regexp.MustCompile(`^([0-9]+)(ms|s|m|h|d)$`)
+	// Regexp for parsing each operation of a date math expression, such as -1h in now-1h
+	reDateMath = regexp.MustCompile(`^([+-])([0-9]+)([wdhHms])`)
+}
+
+// searchType returns whether the path is for a multi-search, and whether it is for a search at all
+func searchType(path string) (bool, bool) {
+	if strings.HasSuffix(path, "/"+mnMultiSearch) {
+		return true, true
+	}
+	return false, strings.HasSuffix(path, "/"+mnSearch)
+}
+
+// parseSearchRequest parses the key parts of a TimeRangeQuery from an inbound _search or _msearch request
+func parseSearchRequest(r *http.Request) (*timeseries.TimeRangeQuery, error) {
+
+	multi, ok := searchType(r.URL.Path)
+	if !ok {
+		return nil, errors.ErrNotTimeRangeQuery
+	}
+
+	if r.Body == nil {
+		return nil, errors.MissingRequestParam(upSearchBody)
+	}
+
+	b, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		return nil, errors.ParseRequestBody(err)
+	}
+
+	// an _msearch body is newline-delimited pairs of header and search body lines
+	lines := []string{string(b)}
+	if multi {
+		lines = splitLines(b)
+		if len(lines) == 0 || len(lines)%2 != 0 {
+			return nil, errors.ParseRequestBody(fmt.Errorf("expected pairs of header and body lines"))
+		}
+	}
+
+	trq := &timeseries.TimeRangeQuery{FastForwardDisable: true}
+	now := time.Now()
+	for i := range lines {
+		if multi && i%2 == 0 {
+			continue
+		}
+		template, extent, step, err := parseSearchBody([]byte(lines[i]), now)
+		if err != nil {
+			return nil, err
+		}
+		// every search in the request must be for the same time range and step
+		if trq.Step == 0 {
+			trq.Extent, trq.Step = extent, step
+		} else if step != trq.Step || !extent.Start.Equal(trq.Extent.Start) || !extent.End.Equal(trq.Extent.End) {
+			return nil, errors.ErrNotTimeRangeQuery
+		}
+		lines[i] = template
+	}
+
+	trq.Statement = strings.Join(lines, "\n")
+	if multi {
+		trq.Statement += "\n"
+	}
+
+	// Swap in the Tokenized Request Body in the Url Params
+	trq.TemplateURL = urls.Clone(r.URL)
+	qi := trq.TemplateURL.Query()
+	qi.Set(upSearchBody, trq.Statement)
+	trq.TemplateURL.RawQuery = qi.Encode()
+
+	return trq, nil
+}
+
+func splitLines(b []byte) []string {
+	lines := make([]string, 0, 2)
+	for _, l := range strings.Split(string(b), "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+// parseSearchBody tokenizes the time range of a search body that returns only date histogram
+// aggregations, and returns the tokenized body with the time range and step it specifies
+func parseSearchBody(b []byte, now time.Time) (string, timeseries.Extent, time.Duration, error) {
+
+	var e timeseries.Extent
+
+	doc := map[string]interface{}{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&doc); err != nil {
+		return "", e, 0, errors.ParseRequestBody(err)
+	}
+
+	// search hits can't be merged, so only requests for aggregations are accelerated
+	if size, ok := doc[fnSize].(json.Number); !ok || size.String() != "0" {
+		return "", e, 0, errors.ErrNotTimeRangeQuery
+	}
+
+	hs := make([]map[string]interface{}, 0, 1)
+	if !findHistograms(subAggregations(doc), &hs) {
+		return "", e, 0, errors.ErrNotTimeRangeQuery
+	}
+
+	var step time.Duration
+	var field string
+	for i, h := range hs {
+		s, f, err := prepareHistogram(h)
+		if err != nil {
+			return "", e, 0, err
+		}
+		if i > 0 && (s != step || f != field) {
+			return "", e, 0, errors.ErrNotTimeRangeQuery
+		}
+		step, field = s, f
+	}
+
+	ranges := findRanges(doc[fnQuery], field, nil)
+	if len(ranges) == 0 {
+		return "", e, 0, errors.ErrNotTimeRangeQuery
+	}
+	r0, _ := json.Marshal(ranges[0])
+	for _, rg := range ranges[1:] {
+		if r, _ := json.Marshal(rg); !bytes.Equal(r, r0) {
+			return "", e, 0, errors.ErrNotTimeRangeQuery
+		}
+	}
+
+	e, err := parseRange(ranges[0], now)
+	if err != nil {
+		return "", e, 0, err
+	}
+
+	for _, rg := range ranges {
+		tokenizeRange(rg)
+	}
+
+	template, err := marshalJSON(doc)
+	if err != nil {
+		return "", e, 0, err
+	}
+
+	return string(template), e, step, nil
+}
+
+// subAggregations returns the sub-aggregations of a search body or aggregation
+func subAggregations(m map[string]interface{}) map[string]interface{} {
+	if aggs, ok := m[fnAggs].(map[string]interface{}); ok {
+		return aggs
+	}
+	aggs, _ := m[fnAggregations].(map[string]interface{})
+	return aggs
+}
+
+// findHistograms collects the date_histogram aggregations from the provided aggregations,
+// and returns false if any aggregation does not lead to a date histogram. Such aggregations
+// are computed across the entire time range, so their results could not be merged.
+func findHistograms(aggs map[string]interface{}, hs *[]map[string]interface{}) bool {
+	if len(aggs) == 0 {
+		return false
+	}
+	for _, v := range aggs {
+		agg, ok := v.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if _, ok := agg[fnDateHistogram]; ok {
+			*hs = append(*hs, agg)
+			continue
+		}
+		if !findHistograms(subAggregations(agg), hs) {
+			return false
+		}
+	}
+	return true
+}
+
+// prepareHistogram returns the step and field of a date_histogram aggregation, tokenizes its
+// extended bounds, and marks it so it can be identified in the response
+func prepareHistogram(agg map[string]interface{}) (time.Duration, string, error) {
+
+	dh, ok := agg[fnDateHistogram].(map[string]interface{})
+	if !ok {
+		return 0, "", errors.ErrNotTimeRangeQuery
+	}
+
+	// calendar intervals have no fixed length, and keyed or offset buckets aren't supported
+	fi, _ := dh[fnFixedInterval].(string)
+	step, err := parseInterval(fi)
+	if err != nil {
+		return 0, "", errors.ErrStepParse
+	}
+
+	field, _ := dh[fnField].(string)
+	if field == "" {
+		return 0, "", errors.ErrNotTimeRangeQuery
+	}
+
+	if k, _ := dh[fnKeyed].(bool); k {
+		return 0, "", errors.ErrNotTimeRangeQuery
+	}
+
+	if _, ok := dh[fnOffset]; ok {
+		return 0, "", errors.ErrNotTimeRangeQuery
+	}
+
+	if tz, ok := dh[fnTimeZone].(string); ok && !utcZones[strings.ToLower(tz)] {
+		return 0, "", errors.ErrNotTimeRangeQuery
+	}
+
+	if eb, ok := dh[fnExtendedBounds].(map[string]interface{}); ok {
+		eb["min"] = tkBoundsMin
+		eb["max"] = tkBoundsMax
+	}
+
+	meta, _ := agg[fnMeta].(map[string]interface{})
+	if meta == nil {
+		meta = make(map[string]interface{})
+	}
+	meta[metaMarker] = true
+	agg[fnMeta] = meta
+
+	return step, field, nil
+}
+
+// findRanges returns the range clauses on the provided field found anywhere in the query
+func findRanges(v interface{}, field string, ranges []map[string]interface{}) []map[string]interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if rg, ok := t[fnRange].(map[string]interface{}); ok {
+			if f, ok := rg[field].(map[string]interface{}); ok {
+				ranges = append(ranges, f)
+			}
+		}
+		for _, v2 := range t {
+			ranges = findRanges(v2, field, ranges)
+		}
+	case []interface{}:
+		for _, v2 := range t {
+			ranges = findRanges(v2, field, ranges)
+		}
+	}
+	return ranges
+}
+
+// parseRange returns the extent of a range clause
+func parseRange(rg map[string]interface{}, now time.Time) (timeseries.Extent, error) {
+
+	var e timeseries.Extent
+	format, _ := rg[fnFormat].(string)
+
+	start := firstValue(rg, "gte", "gt", "from")
+	if start == nil {
+		return e, errors.ErrNotTimeRangeQuery
+	}
+	var err error
+	if e.Start, err = parseTime(start, format, now); err != nil {
+		return e, err
+	}
+
+	e.End = now
+	if end := firstValue(rg, "lte", "lt", "to"); end != nil {
+		if e.End, err = parseTime(end, format, now); err != nil {
+			return e, err
+		}
+	}
+
+	if e.End.Before(e.Start) {
+		return e, errors.ErrNotTimeRangeQuery
+	}
+
+	return e, nil
+}
+
+func firstValue(m map[string]interface{}, keys ...string) interface{} {
+	for _, k := range keys {
+		if v, ok := m[k]; ok && v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+// tokenizeRange replaces the bounds of a range clause with tokens
+func tokenizeRange(rg map[string]interface{}) {
+	for _, k := range []string{"gte", "gt", "from", "lte", "lt", "to", "include_lower", "include_upper"} {
+		delete(rg, k)
+	}
+	rg["gte"] = tkStart
+	rg["lte"] = tkEnd
+	rg[fnFormat] = formatEpochMillis
+}
+
+// interpolateSearchBody replaces the tokens in a tokenized request body with the provided
+// extent. The range is extended to the end of the last bucket, so that it is complete.
+func interpolateSearchBody(template string, extent *timeseries.Extent, step time.Duration) string {
+	start := strconv.FormatInt(epochMillis(extent.Start), 10)
+	end := strconv.FormatInt(epochMillis(extent.End), 10)
+	return strings.NewReplacer(
+		`"`+tkStart+`"`, start,
+		`"`+tkEnd+`"`, strconv.FormatInt(epochMillis(extent.End.Add(step))-1, 10),
+		`"`+tkBoundsMin+`"`, start,
+		`"`+tkBoundsMax+`"`, end,
+	).Replace(template)
+}
+
+func epochMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// parseInterval parses a fixed_interval value
+func parseInterval(v string) (time.Duration, error) {
+	m := reFixedInterval.FindStringSubmatch(v)
+	if m == nil {
+		return errors.ParseDuration(v)
+	}
+	n, _ := strconv.ParseInt(m[1], 10, 64)
+	var unit time.Duration
+	switch m[2] {
+	case "ms":
+		unit = time.Millisecond
+	case "s":
+		unit = time.Second
+	case "m":
+		unit = time.Minute
+	case "h":
+		unit = time.Hour
+	case "d":
+		unit = 24 * time.Hour
+	}
+	if n <= 0 {
+		return errors.ParseDuration(v)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// parseTime converts a range bound into a time. Supported values are epoch
+// milliseconds (or seconds, per the format), RFC3339 times and dates, and
+// date math relative to now without rounding (e.g., now-1h).
+func parseTime(v interface{}, format string, now time.Time) (time.Time, error) {
+
+	var s string
+	switch t := v.(type) {
+	case json.Number:
+		s = t.String()
+	case string:
+		s = t
+	default:
+		return time.Time{}, fmt.Errorf("unsupported time value: %v", v)
+	}
+
+	if strings.HasPrefix(s, "now") {
+		return parseDateMath(s[3:], now)
+	}
+
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if strings.Contains(format, formatEpochSeconds) {
+			return time.Unix(i, 0), nil
+		}
+		return time.Unix(0, i*int64(time.Millisecond)), nil
+	}
+
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unable to parse time: %s", s)
+}
+
+func parseDateMath(s string, now time.Time) (time.Time, error) {
+	t := now
+	for s != "" {
+		m := reDateMath.FindStringSubmatch(s)
+		if m == nil {
+			return time.Time{}, fmt.Errorf("unsupported date math: %s", s)
+		}
+		n, _ := strconv.ParseInt(m[2], 10, 64)
+		var unit time.Duration
+		switch m[3] {
+		case "w":
+			unit = 7 * 24 * time.Hour
+		case "d":
+			unit = 24 * time.Hour
+		case "h", "H":
+			unit = time.Hour
+		case "m":
+			unit = time.Minute
+		case "s":
+			unit = time.Second
+		}
+		d := time.Duration(n) * unit
+		if m[1] == "-" {
+			d = -d
+		}
+		t = t.Add(d)
+		s = s[len(m[0]):]
+	}
+	return t, nil
+}
+
+// marshalJSON marshals the value without escaping HTML characters, so that tokens remain intact
+func marshalJSON(v interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
@@ -0,0 +1,275 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+// testSearchBody returns a search body like those sent by Grafana, with a terms
+// aggregation of date histograms over the provided range
+func testSearchBody(gte, lte string) string {
+	return `{"size":0,"query":{"bool":{"filter":[{"range":{"@timestamp":{"gte":` + gte + `,"lte":` + lte +
+		`,"format":"epoch_millis"}}},{"query_string":{"analyze_wildcard":true,"query":"*"}}]}},` +
+		`"aggs":{"3":{"terms":{"field":"host","size":10},"aggs":{"2":{"date_histogram":{"field":"@timestamp",` +
+		`"fixed_interval":"1m","min_doc_count":0,"extended_bounds":{"min":` + gte + `,"max":` + lte + `},` +
+		`"format":"epoch_millis"},"aggs":{"1":{"avg":{"field":"value"}}}}}}}}`
+}
+
+func TestParseSearchRequest(t *testing.T) {
+
+	body := testSearchBody("600000", "720000")
+	r := httptest.NewRequest("POST", "http://0/logs-*/_search", strings.NewReader(body))
+
+	trq, err := parseSearchRequest(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if trq.Step != time.Minute {
+		t.Errorf("expected %s got %s", time.Minute, trq.Step)
+	}
+
+	if !trq.Extent.Start.Equal(time.Unix(600, 0)) || !trq.Extent.End.Equal(time.Unix(720, 0)) {
+		t.Errorf("unexpected extent %v", trq.Extent)
+	}
+
+	if !trq.FastForwardDisable {
+		t.Error("expected fast forward to be disabled")
+	}
+
+	for _, tk := range []string{tkStart, tkEnd, tkBoundsMin, tkBoundsMax, metaMarker} {
+		if !strings.Contains(trq.Statement, tk) {
+			t.Errorf("expected %s in statement %s", tk, trq.Statement)
+		}
+	}
+
+	if trq.TemplateURL.Query().Get(upSearchBody) != trq.Statement {
+		t.Errorf("expected template url to contain the statement")
+	}
+
+	// the body remains readable for proxied requests
+	b, _ := ioutil.ReadAll(r.Body)
+	if string(b) != body {
+		t.Errorf("expected %s got %s", body, b)
+	}
+}
+
+func TestParseMultiSearchRequest(t *testing.T) {
+
+	const header = `{"index":"logs-*","ignore_unavailable":true}`
+	body := header + "\n" + testSearchBody("600000", "720000") + "\n" +
+		header + "\n" + testSearchBody(`"1970-01-01T00:10:00Z"`, `"1970-01-01T00:12:00Z"`) + "\n"
+
+	r := httptest.NewRequest("POST", "http://0/_msearch", strings.NewReader(body))
+	trq, err := parseSearchRequest(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(trq.Statement, "\n")
+	if len(lines) != 5 || lines[0] != header || lines[4] != "" || lines[1] != lines[3] {
+		t.Errorf("unexpected statement %s", trq.Statement)
+	}
+
+	// searches for differing time ranges can't be accelerated
+	body = header + "\n" + testSearchBody("600000", "720000") + "\n" +
+		header + "\n" + testSearchBody("660000", "720000") + "\n"
+	r = httptest.NewRequest("POST", "http://0/_msearch", strings.NewReader(body))
+	if _, err = parseSearchRequest(r); err == nil {
+		t.Error("expected error for differing time ranges")
+	}
+
+	r = httptest.NewRequest("POST", "http://0/_msearch", strings.NewReader(header+"\n"))
+	if _, err = parseSearchRequest(r); err == nil {
+		t.Error("expected error for unpaired lines")
+	}
+}
+
+func TestParseSearchRequestErrors(t *testing.T) {
+
+	r := httptest.NewRequest("GET", "http://0/_cat/indices", nil)
+	if _, err := parseSearchRequest(r); err == nil {
+		t.Error("expected error for non-search path")
+	}
+
+	r = httptest.NewRequest("GET", "http://0/_search", nil)
+	r.Body = nil
+	if _, err := parseSearchRequest(r); err == nil {
+		t.Error("expected error for missing body")
+	}
+
+	body := testSearchBody("600000", "720000")
+	tests := []string{
+		`{`,
+		strings.Replace(body, `"size":0`, `"size":10`, 1),
+		strings.Replace(body, `"size":0,`, ``, 1),
+		strings.Replace(body, `"fixed_interval":"1m"`, `"calendar_interval":"1M"`, 1),
+		strings.Replace(body, `"field":"@timestamp",`, ``, 1),
+		strings.Replace(body, `"min_doc_count":0`, `"min_doc_count":0,"keyed":true`, 1),
+		strings.Replace(body, `"min_doc_count":0`, `"min_doc_count":0,"offset":"+30s"`, 1),
+		strings.Replace(body, `"min_doc_count":0`, `"min_doc_count":0,"time_zone":"America/New_York"`, 1),
+		strings.Replace(body, `"@timestamp":{"gte"`, `"other":{"gte"`, 1),
+		strings.Replace(body, `"gte":600000,`, ``, 1),
+		strings.Replace(body, `"gte":600000`, `"gte":"x"`, 1),
+		strings.Replace(body, `"lte":720000`, `"lte":"x"`, 1),
+		strings.Replace(body, `"lte":720000`, `"lte":500000`, 1),
+		// a sibling metric aggregation is computed across the entire range
+		strings.Replace(body, `"aggs":{"3"`, `"aggs":{"4":{"max":{"field":"value"}},"3"`, 1),
+		strings.Replace(body, `"aggs":{"3"`, `"aggs":{"4":[],"3"`, 1),
+		strings.Replace(body, `"aggs":{"3"`, `"aggs":{"4":{"date_histogram":{"field":"@timestamp",`+
+			`"fixed_interval":"1h"}},"3"`, 1),
+		strings.Replace(body, `,"aggs":{"3"`, `,"x":{"3"`, 1),
+		strings.Replace(body, `{"query_string"`, `{"range":{"@timestamp":{"gte":0}}},{"query_string"`, 1),
+	}
+
+	for i, test := range tests {
+		r = httptest.NewRequest("POST", "http://0/_search", strings.NewReader(test))
+		if _, err := parseSearchRequest(r); err == nil {
+			t.Errorf("expected error for test %d: %s", i, test)
+		}
+	}
+}
+
+func TestParseSearchBodyDefaults(t *testing.T) {
+
+	now := time.Unix(3600, 0)
+	body := `{"size":0,"query":{"range":{"ts":{"gt":"now-1h","time_zone":"UTC"}}},` +
+		`"aggregations":{"h":{"date_histogram":{"field":"ts","fixed_interval":"30s","time_zone":"Z"}}}}`
+
+	template, e, step, err := parseSearchBody([]byte(body), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if step != 30*time.Second || !e.Start.Equal(time.Unix(0, 0)) || !e.End.Equal(now) {
+		t.Errorf("unexpected step %s or extent %v", step, e)
+	}
+
+	const expected = `{"aggregations":{"h":{"date_histogram":{"field":"ts","fixed_interval":"30s","time_zone":"Z"},` +
+		`"meta":{"trickster_date_histogram":true}}},"query":{"range":{"ts":{"format":"epoch_millis",` +
+		`"gte":"<$START_TOKEN$>","lte":"<$END_TOKEN$>","time_zone":"UTC"}}},"size":0}`
+	if template != expected {
+		t.Errorf("\nexpected [%s]\ngot      [%s]", expected, template)
+	}
+}
+
+func TestInterpolateSearchBody(t *testing.T) {
+
+	template := `{"a":"` + tkStart + `","b":"` + tkEnd + `","c":"` + tkBoundsMin + `","d":"` + tkBoundsMax + `"}`
+	e := &timeseries.Extent{Start: time.Unix(600, 0), End: time.Unix(720, 0)}
+
+	const expected = `{"a":600000,"b":779999,"c":600000,"d":720000}`
+	if s := interpolateSearchBody(template, e, time.Minute); s != expected {
+		t.Errorf("expected %s got %s", expected, s)
+	}
+
+	// the tokenized body of a parsed request is valid json once interpolated
+	_, _, _, err := parseSearchBody([]byte(testSearchBody("600000", "720000")), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest("POST", "http://0/_search", strings.NewReader(testSearchBody("600000", "720000")))
+	trq, _ := parseSearchRequest(r)
+	m := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(interpolateSearchBody(trq.Statement, e, trq.Step)), &m); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseInterval(t *testing.T) {
+
+	tests := []struct {
+		v        string
+		expected time.Duration
+		err      bool
+	}{
+		{"500ms", 500 * time.Millisecond, false},
+		{"30s", 30 * time.Second, false},
+		{"5m", 5 * time.Minute, false},
+		{"2h", 2 * time.Hour, false},
+		{"1d", 24 * time.Hour, false},
+		{"0s", 0, true},
+		{"1w", 0, true},
+		{"", 0, true},
+	}
+
+	for _, test := range tests {
+		d, err := parseInterval(test.v)
+		if test.err != (err != nil) {
+			t.Errorf("unexpected error state for %s: %v", test.v, err)
+		}
+		if d != test.expected {
+			t.Errorf("expected %s got %s", test.expected, d)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+
+	now := time.Unix(1000000, 0)
+
+	tests := []struct {
+		v        interface{}
+		format   string
+		expected time.Time
+		err      bool
+	}{
+		{json.Number("600000"), "", time.Unix(600, 0), false},
+		{json.Number("600"), "epoch_second", time.Unix(600, 0), false},
+		{"600000", "strict_date_optional_time||epoch_millis", time.Unix(600, 0), false},
+		{"1970-01-01T00:10:00Z", "", time.Unix(600, 0), false},
+		{"1970-01-01T00:10:00", "", time.Unix(600, 0), false},
+		{"1970-01-02", "", time.Unix(86400, 0), false},
+		{"now", "", now, false},
+		{"now-1h", "", now.Add(-time.Hour), false},
+		{"now-1d+30m", "", now.Add(-24 * time.Hour).Add(30 * time.Minute), false},
+		{"now-1w-10s", "", now.Add(-7 * 24 * time.Hour).Add(-10 * time.Second), false},
+		{"now-2H", "", now.Add(-2 * time.Hour), false},
+		{"now-1d/d", "", time.Time{}, true},
+		{"yesterday", "", time.Time{}, true},
+		{true, "", time.Time{}, true},
+	}
+
+	for _, test := range tests {
+		v, err := parseTime(test.v, test.format, now)
+		if test.err != (err != nil) {
+			t.Errorf("unexpected error state for %v: %v", test.v, err)
+		}
+		if !v.Equal(test.expected) {
+			t.Errorf("expected %s got %s for %v", test.expected, v, test.v)
+		}
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	b, err := marshalJSON(map[string]string{"a": "<$>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, []byte(`{"a":"<$>"}`)) {
+		t.Errorf("expected %s got %s", `{"a":"<$>"}`, b)
+	}
+	if _, err = marshalJSON(make(chan int)); err == nil {
+		t.Error("expected error for unsupported value")
+	}
+}
@@ -0,0 +1,426 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package elasticsearch
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/Comcast/trickster/internal/timeseries"
+	"github.com/Comcast/trickster/pkg/sort/times"
+)
+
+// SetExtents overwrites a Timeseries's known extents with the provided extent list
+func (d *Document) SetExtents(extents timeseries.ExtentList) {
+	d.ExtentList = make(timeseries.ExtentList, len(extents))
+	copy(d.ExtentList, extents)
+	d.isCounted = false
+}
+
+// Extents returns the Timeseries's ExentList
+func (d *Document) Extents() timeseries.ExtentList {
+	return d.ExtentList
+}
+
+// Step returns the step for the Timeseries
+func (d *Document) Step() time.Duration {
+	return d.StepDuration
+}
+
+// SetStep sets the step for the Timeseries
+func (d *Document) SetStep(step time.Duration) {
+	d.StepDuration = step
+}
+
+// histograms calls f for each date histogram in the Document
+func (d *Document) histograms(f func(*Aggregation)) {
+	for _, r := range d.Responses {
+		walkHistograms(r.Aggregations, f)
+	}
+}
+
+func walkHistograms(aggs map[string]*Aggregation, f func(*Aggregation)) {
+	for _, a := range aggs {
+		if a.IsHistogram {
+			f(a)
+			continue
+		}
+		walkHistograms(a.Aggregations, f)
+		for _, b := range a.Buckets {
+			walkHistograms(b.Aggregations, f)
+		}
+	}
+}
+
+// SeriesCount returns the count of all date histograms in the Timeseries
+func (d *Document) SeriesCount() int {
+	c := 0
+	d.histograms(func(a *Aggregation) { c++ })
+	return c
+}
+
+// ValueCount returns the count of all buckets across all date histograms in the Timeseries
+func (d *Document) ValueCount() int {
+	c := 0
+	d.histograms(func(a *Aggregation) { c += len(a.Buckets) })
+	return c
+}
+
+// TimestampCount returns the count of unique timestamps across all date histograms in the Timeseries
+func (d *Document) TimestampCount() int {
+	d.updateTimestamps()
+	return len(d.timestamps)
+}
+
+func (d *Document) updateTimestamps() {
+	if d.isCounted {
+		return
+	}
+	m := make(map[time.Time]bool)
+	d.histograms(func(a *Aggregation) {
+		for _, b := range a.Buckets {
+			m[b.Timestamp] = true
+		}
+	})
+	d.timestamps = m
+	d.isCounted = true
+}
+
+// Merge merges the provided Timeseries list into the base Timeseries (in the order provided) and optionally sorts the merged Timeseries
+func (d *Document) Merge(sort bool, collection ...timeseries.Timeseries) {
+
+	for _, ts := range collection {
+		if ts == nil {
+			continue
+		}
+		d2 := ts.(*Document)
+		for i, r2 := range d2.Responses {
+			if i >= len(d.Responses) {
+				d.Responses = append(d.Responses, r2.clone())
+				continue
+			}
+			d.Responses[i].Aggregations = mergeAggregations(d.Responses[i].Aggregations, r2.Aggregations)
+		}
+		d.ExtentList = append(d.ExtentList, d2.ExtentList...)
+	}
+
+	d.ExtentList = d.ExtentList.Compress(d.StepDuration)
+	d.isSorted = false
+	d.isCounted = false
+	if sort {
+		d.Sort()
+	}
+}
+
+// mergeAggregations merges the src aggregations into dst, matching them by name
+func mergeAggregations(dst, src map[string]*Aggregation) map[string]*Aggregation {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]*Aggregation, len(src))
+	}
+	for k, a2 := range src {
+		if a, ok := dst[k]; ok {
+			a.merge(a2)
+			continue
+		}
+		dst[k] = a2.clone()
+	}
+	return dst
+}
+
+// merge merges the buckets of a2 into the Aggregation. Date histogram buckets are
+// appended and de-duplicated when sorted, while other buckets are matched by key.
+func (a *Aggregation) merge(a2 *Aggregation) {
+
+	a.Aggregations = mergeAggregations(a.Aggregations, a2.Aggregations)
+
+	if a.IsHistogram {
+		for _, b2 := range a2.Buckets {
+			a.Buckets = append(a.Buckets, b2.clone())
+		}
+		return
+	}
+
+	buckets := make(map[string]*Bucket, len(a.Buckets))
+	for _, b := range a.Buckets {
+		buckets[b.Key] = b
+	}
+	for _, b2 := range a2.Buckets {
+		if b, ok := buckets[b2.Key]; ok {
+			b.Aggregations = mergeAggregations(b.Aggregations, b2.Aggregations)
+			continue
+		}
+		b := b2.clone()
+		buckets[b.Key] = b
+		a.Buckets = append(a.Buckets, b)
+	}
+}
+
+// Clone returns a perfect copy of the base Timeseries
+func (d *Document) Clone() timeseries.Timeseries {
+	d2 := &Document{
+		Fields:       cloneFields(d.Fields),
+		Responses:    make([]*Response, len(d.Responses)),
+		Multi:        d.Multi,
+		StepDuration: d.StepDuration,
+		ExtentList:   make(timeseries.ExtentList, len(d.ExtentList)),
+		isSorted:     d.isSorted,
+	}
+	copy(d2.ExtentList, d.ExtentList)
+	for i, r := range d.Responses {
+		d2.Responses[i] = r.clone()
+	}
+	return d2
+}
+
+func (r *Response) clone() *Response {
+	return &Response{Fields: cloneFields(r.Fields), Aggregations: cloneAggregations(r.Aggregations)}
+}
+
+func (a *Aggregation) clone() *Aggregation {
+	a2 := &Aggregation{
+		Fields:       cloneFields(a.Fields),
+		IsHistogram:  a.IsHistogram,
+		Aggregations: cloneAggregations(a.Aggregations),
+		keyed:        a.keyed,
+	}
+	if a.Buckets != nil {
+		a2.Buckets = make([]*Bucket, len(a.Buckets))
+		for i, b := range a.Buckets {
+			a2.Buckets[i] = b.clone()
+		}
+	}
+	return a2
+}
+
+func (b *Bucket) clone() *Bucket {
+	return &Bucket{Key: b.Key, Timestamp: b.Timestamp, Fields: cloneFields(b.Fields),
+		Aggregations: cloneAggregations(b.Aggregations)}
+}
+
+func cloneAggregations(aggs map[string]*Aggregation) map[string]*Aggregation {
+	if aggs == nil {
+		return nil
+	}
+	aggs2 := make(map[string]*Aggregation, len(aggs))
+	for k, a := range aggs {
+		aggs2[k] = a.clone()
+	}
+	return aggs2
+}
+
+// cloneFields copies a map of raw fields. The raw values are never modified, so they are shared.
+func cloneFields(fields map[string]json.RawMessage) map[string]json.RawMessage {
+	if fields == nil {
+		return nil
+	}
+	f2 := make(map[string]json.RawMessage, len(fields))
+	for k, v := range fields {
+		f2[k] = v
+	}
+	return f2
+}
+
+// CropToSize reduces the number of elements in the Timeseries to the provided count, by evicting elements
+// using a least-recently-used methodology. The time parameter limits the upper extent to the provided time,
+// in order to support backfill tolerance
+func (d *Document) CropToSize(sz int, t time.Time, lur timeseries.Extent) {
+
+	d.isCounted = false
+	d.isSorted = false
+	x := len(d.ExtentList)
+	// The Series has no extents, so no need to do anything
+	if x < 1 {
+		d.filterBuckets(func(time.Time) bool { return false })
+		d.ExtentList = timeseries.ExtentList{}
+		return
+	}
+
+	// Crop to the Backfill Tolerance Value if needed
+	if d.ExtentList[x-1].End.After(t) {
+		d.CropToRange(timeseries.Extent{Start: d.ExtentList[0].Start, End: t})
+	}
+
+	tc := d.TimestampCount()
+	if tc <= sz {
+		return
+	}
+
+	el := timeseries.ExtentListLRU(d.ExtentList).UpdateLastUsed(lur, d.StepDuration)
+	sort.Sort(el)
+
+	rc := tc - sz // # of required timestamps we must delete to meet the rentention policy
+	removals := make(map[time.Time]bool)
+	done := false
+
+	for _, x := range el {
+		for ts := x.Start; !x.End.Before(ts) && !done; ts = ts.Add(d.StepDuration) {
+			// bucket timestamps are in UTC, while extents may be in any location
+			if _, ok := d.timestamps[ts.UTC()]; ok {
+				removals[ts.UTC()] = true
+				done = len(removals) >= rc
+			}
+		}
+		if done {
+			break
+		}
+	}
+
+	d.filterBuckets(func(ts time.Time) bool { return !removals[ts] })
+
+	tl := times.FromMap(removals)
+	sort.Sort(tl)
+	for _, t := range tl {
+		for i, e := range el {
+			if e.StartsAt(t) {
+				el[i].Start = e.Start.Add(d.StepDuration)
+			}
+		}
+	}
+
+	d.ExtentList = timeseries.ExtentList(el).Compress(d.StepDuration)
+	d.Sort()
+}
+
+// CropToRange reduces the Timeseries down to timestamps contained within the provided Extents (inclusive).
+func (d *Document) CropToRange(e timeseries.Extent) {
+
+	d.isCounted = false
+	x := len(d.ExtentList)
+	// The Series has no extents, or is entirely outside of the crop range, so return an empty set
+	if x < 1 || d.ExtentList.OutsideOf(e) {
+		d.filterBuckets(func(time.Time) bool { return false })
+		d.ExtentList = timeseries.ExtentList{}
+		return
+	}
+
+	d.filterBuckets(func(ts time.Time) bool {
+		return !ts.Before(e.Start) && !ts.After(e.End)
+	})
+	d.ExtentList = d.ExtentList.Crop(e)
+}
+
+// filterBuckets retains only the date histogram buckets for which keep returns true, and
+// removes any other buckets that are left without date histogram buckets
+func (d *Document) filterBuckets(keep func(time.Time) bool) {
+	d.histograms(func(a *Aggregation) {
+		bs := a.Buckets[:0]
+		for _, b := range a.Buckets {
+			if keep(b.Timestamp) {
+				bs = append(bs, b)
+			}
+		}
+		a.Buckets = bs
+	})
+	for _, r := range d.Responses {
+		for _, a := range r.Aggregations {
+			a.prune()
+		}
+	}
+}
+
+// prune removes the non-histogram buckets that contain no date histogram buckets,
+// and returns true if the Aggregation contains any date histogram buckets
+func (a *Aggregation) prune() bool {
+	if a.IsHistogram {
+		return len(a.Buckets) > 0
+	}
+	has := false
+	for _, sa := range a.Aggregations {
+		if sa.prune() {
+			has = true
+		}
+	}
+	if a.Buckets != nil {
+		bs := a.Buckets[:0]
+		for _, b := range a.Buckets {
+			keep := false
+			for _, sa := range b.Aggregations {
+				if sa.prune() {
+					keep = true
+				}
+			}
+			if keep {
+				bs = append(bs, b)
+				has = true
+			}
+		}
+		a.Buckets = bs
+	}
+	return has
+}
+
+// Sort sorts all date histogram buckets chronologically by their timestamp, keeping the
+// most recently merged bucket when a timestamp is duplicated
+func (d *Document) Sort() {
+
+	if d.isSorted {
+		return
+	}
+
+	tsm := make(map[time.Time]bool)
+	d.histograms(func(a *Aggregation) {
+		sort.SliceStable(a.Buckets, func(i, j int) bool { return a.Buckets[i].Timestamp.Before(a.Buckets[j].Timestamp) })
+		bs := a.Buckets[:0]
+		for _, b := range a.Buckets {
+			if n := len(bs); n > 0 && bs[n-1].Timestamp.Equal(b.Timestamp) {
+				bs[n-1] = b
+				continue
+			}
+			bs = append(bs, b)
+			tsm[b.Timestamp] = true
+		}
+		a.Buckets = bs
+	})
+
+	sort.Sort(d.ExtentList)
+
+	d.timestamps = tsm
+	d.isCounted = true
+	d.isSorted = true
+}
+
+// Size returns the approximate memory utilization in bytes of the timeseries
+func (d *Document) Size() int {
+	size := fieldsSize(d.Fields)
+	for _, r := range d.Responses {
+		size += fieldsSize(r.Fields) + aggregationsSize(r.Aggregations)
+	}
+	// ExtentList + StepDuration + Timestamps + Multi + isCounted + isSorted
+	size += (len(d.ExtentList) * 24) + 8 + (len(d.timestamps) * 9) + 3
+	return size
+}
+
+func aggregationsSize(aggs map[string]*Aggregation) int {
+	size := 0
+	for k, a := range aggs {
+		size += len(k) + fieldsSize(a.Fields) + aggregationsSize(a.Aggregations)
+		for _, b := range a.Buckets {
+			// Timestamp
+			size += len(b.Key) + 24 + fieldsSize(b.Fields) + aggregationsSize(b.Aggregations)
+		}
+	}
+	return size
+}
+
+func fieldsSize(fields map[string]json.RawMessage) int {
+	size := 0
+	for k, v := range fields {
+		size += len(k) + len(v)
+	}
+	return size
+}
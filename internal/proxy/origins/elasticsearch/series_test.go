@@ -0,0 +1,194 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package elasticsearch
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+func TestSetStep(t *testing.T) {
+	d := &Document{}
+	const step = time.Duration(300) * time.Minute
+	d.SetStep(step)
+	if d.Step() != step {
+		t.Errorf(`expected "%s". got "%s"`, step, d.Step())
+	}
+}
+
+func TestSetExtents(t *testing.T) {
+	d := &Document{}
+	ex := timeseries.ExtentList{{Start: time.Unix(0, 0), End: time.Unix(60, 0)}}
+	d.SetExtents(ex)
+	if len(d.Extents()) != 1 || !d.Extents()[0].End.Equal(ex[0].End) {
+		t.Errorf("expected %v got %v", ex, d.Extents())
+	}
+}
+
+func TestMerge(t *testing.T) {
+
+	d := testDocument(t, []string{"a"}, 600, 720)
+	d2 := testDocument(t, []string{"a", "b"}, 780, 900)
+
+	d.Merge(true, d2, nil)
+
+	if d.SeriesCount() != 2 {
+		t.Errorf("expected %d got %d", 2, d.SeriesCount())
+	}
+
+	if d.ValueCount() != 9 {
+		t.Errorf("expected %d got %d", 9, d.ValueCount())
+	}
+
+	if d.TimestampCount() != 6 {
+		t.Errorf("expected %d got %d", 6, d.TimestampCount())
+	}
+
+	if len(d.ExtentList) != 1 || !d.ExtentList[0].Start.Equal(time.Unix(600, 0)) ||
+		!d.ExtentList[0].End.Equal(time.Unix(900, 0)) {
+		t.Errorf("unexpected extents %v", d.ExtentList)
+	}
+
+	// buckets of the merged histogram are in order
+	h := d.Responses[0].Aggregations["3"].Buckets[0].Aggregations["2"]
+	for i := 1; i < len(h.Buckets); i++ {
+		if !h.Buckets[i-1].Timestamp.Before(h.Buckets[i].Timestamp) {
+			t.Errorf("unsorted buckets at index %d", i)
+		}
+	}
+
+	// an _msearch document with more responses gets the extra responses
+	d3 := &Document{Responses: []*Response{}}
+	d3.Merge(false, d2)
+	if len(d3.Responses) != 1 || d3.ValueCount() != 6 {
+		t.Errorf("expected 1 response with 6 values got %d with %d", len(d3.Responses), d3.ValueCount())
+	}
+}
+
+func TestSort(t *testing.T) {
+
+	d := testDocument(t, []string{"a"}, 600, 720)
+	d2 := testDocument(t, []string{"a"}, 660, 660)
+
+	// the later bucket for a duplicate timestamp is kept
+	d2.Responses[0].Aggregations["3"].Buckets[0].Aggregations["2"].Buckets[0].Key = "new"
+	d.Merge(true, d2)
+
+	h := d.Responses[0].Aggregations["3"].Buckets[0].Aggregations["2"]
+	if len(h.Buckets) != 3 {
+		t.Errorf("expected %d got %d", 3, len(h.Buckets))
+	}
+	if h.Buckets[1].Key != "new" {
+		t.Errorf("expected %s got %s", "new", h.Buckets[1].Key)
+	}
+
+	// sorting is a no-op once sorted
+	d.Sort()
+	if len(h.Buckets) != 3 {
+		t.Errorf("expected %d got %d", 3, len(h.Buckets))
+	}
+}
+
+func TestClone(t *testing.T) {
+
+	d := testDocument(t, []string{"a", "b"}, 600, 720)
+	d.Multi = true
+	d.Fields = map[string]json.RawMessage{"took": json.RawMessage("1")}
+	d.Responses[0].Aggregations["3"].keyed = true
+
+	d2 := d.Clone().(*Document)
+	if d2.ValueCount() != d.ValueCount() || d2.Step() != d.Step() || !d2.Multi ||
+		len(d2.ExtentList) != 1 || string(d2.Fields["took"]) != "1" || !d2.Responses[0].Aggregations["3"].keyed {
+		t.Errorf("clone mismatch")
+	}
+
+	// the clone is independent of the original
+	d2.CropToRange(timeseries.Extent{Start: time.Unix(600, 0), End: time.Unix(600, 0)})
+	if d.ValueCount() != 6 || d2.ValueCount() != 2 {
+		t.Errorf("expected 6 and 2 values got %d and %d", d.ValueCount(), d2.ValueCount())
+	}
+}
+
+func TestCropToRange(t *testing.T) {
+
+	d := testDocument(t, []string{"a", "b"}, 600, 720)
+	d.CropToRange(timeseries.Extent{Start: time.Unix(660, 0), End: time.Unix(900, 0)})
+	if d.ValueCount() != 4 {
+		t.Errorf("expected %d got %d", 4, d.ValueCount())
+	}
+	if len(d.ExtentList) != 1 || !d.ExtentList[0].Start.Equal(time.Unix(660, 0)) {
+		t.Errorf("unexpected extents %v", d.ExtentList)
+	}
+
+	// terms buckets left without any histogram buckets are removed
+	d = testDocument(t, []string{"a"}, 600, 720)
+	d2 := testDocument(t, []string{"b"}, 780, 840)
+	d.Merge(true, d2)
+	d.CropToRange(timeseries.Extent{Start: time.Unix(780, 0), End: time.Unix(840, 0)})
+	bs := d.Responses[0].Aggregations["3"].Buckets
+	if len(bs) != 1 || bs[0].Key != `"b"` {
+		t.Errorf("expected only bucket b got %d buckets", len(bs))
+	}
+
+	// outside of the extents
+	d.CropToRange(timeseries.Extent{Start: time.Unix(0, 0), End: time.Unix(60, 0)})
+	if d.ValueCount() != 0 || len(d.ExtentList) != 0 {
+		t.Errorf("expected empty document got %d values", d.ValueCount())
+	}
+}
+
+func TestCropToSize(t *testing.T) {
+
+	now := time.Now().Truncate(time.Minute)
+	start := now.Add(-10 * time.Minute)
+
+	d := testDocument(t, []string{"a", "b"}, start.Unix(), now.Unix())
+	d.CropToSize(5, now, timeseries.Extent{Start: start, End: now})
+
+	if d.TimestampCount() != 5 {
+		t.Errorf("expected %d got %d", 5, d.TimestampCount())
+	}
+	if d.ValueCount() != 10 {
+		t.Errorf("expected %d got %d", 10, d.ValueCount())
+	}
+	if len(d.ExtentList) != 1 || !d.ExtentList[0].Start.Equal(now.Add(-4*time.Minute)) {
+		t.Errorf("unexpected extents %v", d.ExtentList)
+	}
+
+	// backfill tolerance
+	d = testDocument(t, []string{"a"}, start.Unix(), now.Unix())
+	d.CropToSize(100, now.Add(-time.Minute), timeseries.Extent{Start: start, End: now})
+	if d.TimestampCount() != 10 {
+		t.Errorf("expected %d got %d", 10, d.TimestampCount())
+	}
+
+	// no extents
+	d = testDocument(t, []string{"a"}, start.Unix(), now.Unix())
+	d.ExtentList = nil
+	d.CropToSize(5, now, timeseries.Extent{})
+	if d.ValueCount() != 0 {
+		t.Errorf("expected %d got %d", 0, d.ValueCount())
+	}
+}
+
+func TestSize(t *testing.T) {
+	d := testDocument(t, []string{"a"}, 600, 600)
+	const expected = 240
+	if d.Size() != expected {
+		t.Errorf("expected %d got %d", expected, d.Size())
+	}
+}
@@ -0,0 +1,96 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package elasticsearch
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/trickster/internal/config"
+	"github.com/Comcast/trickster/internal/proxy/headers"
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+func TestSetExtent(t *testing.T) {
+
+	client := &Client{}
+	e := &timeseries.Extent{Start: time.Unix(600, 0), End: time.Unix(720, 0)}
+
+	r := httptest.NewRequest(http.MethodPost, "http://0/_search", strings.NewReader(testSearchBody("0", "60000")))
+	trq, err := parseSearchRequest(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client.SetExtent(r, trq, e)
+	b, _ := ioutil.ReadAll(r.Body)
+	for _, v := range []string{`"gte":600000`, `"lte":779999`, `"min":600000`, `"max":720000`} {
+		if !strings.Contains(string(b), v) {
+			t.Errorf("expected %s in %s", v, b)
+		}
+	}
+
+	if r.ContentLength != int64(len(b)) {
+		t.Errorf("expected %d got %d", len(b), r.ContentLength)
+	}
+
+	if ct := r.Header.Get(headers.NameContentType); ct != headers.ValueApplicationJSON {
+		t.Errorf("expected %s got %s", headers.ValueApplicationJSON, ct)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "http://0/_msearch", strings.NewReader("{}\n"+testSearchBody("0", "60000")))
+	trq, err = parseSearchRequest(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetExtent(r, trq, e)
+	if ct := r.Header.Get(headers.NameContentType); ct != headers.ValueApplicationNDJSON {
+		t.Errorf("expected %s got %s", headers.ValueApplicationNDJSON, ct)
+	}
+
+	// a nil extent leaves the request unchanged
+	client.SetExtent(r, trq, nil)
+	b, _ = ioutil.ReadAll(r.Body)
+	if !strings.HasSuffix(string(b), "\n") {
+		t.Errorf("expected multi-search body to end with a newline")
+	}
+}
+
+func TestBuildUpstreamURL(t *testing.T) {
+
+	cfg := config.NewConfig()
+	oc := cfg.Origins["default"]
+	oc.Scheme = "http"
+	oc.Host = "0"
+	oc.PathPrefix = ""
+
+	client := &Client{name: "default", config: oc}
+	r, err := http.NewRequest(http.MethodPost, "http://0/default/logs-*/_search?ignore_unavailable=true", nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	u := client.BuildUpstreamURL(r)
+	if u.Path != "/logs-*/_search" {
+		t.Errorf("expected %s got %s", "/logs-*/_search", u.Path)
+	}
+
+	if u.RawQuery != "ignore_unavailable=true" {
+		t.Errorf("expected %s got %s", "ignore_unavailable=true", u.RawQuery)
+	}
+}
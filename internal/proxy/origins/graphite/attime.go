@@ -0,0 +1,185 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package graphite
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	day = 24 * time.Hour
+
+	// layouts of the absolute time references accepted by the render API
+	layoutHourMinuteDate = "15:04_20060102"
+	layoutDate           = "20060102"
+)
+
+// parseTime converts a render API from/until value into a time. It supports
+// the subset of Graphite's at-style times that are in common use: "now",
+// epoch seconds, relative offsets such as "-24h" or "now-1h30min", and the
+// "HH:MM_YYYYMMDD", "YYYYMMDD", "today", "yesterday", "tomorrow", "midnight"
+// and "noon" references, each optionally followed by an offset.
+func parseTime(s string, now time.Time, loc *time.Location) (time.Time, error) {
+
+	s = strings.Replace(strings.ToLower(strings.TrimSpace(s)), " ", "", -1)
+	if s == "" {
+		return time.Time{}, timeParseError(s)
+	}
+
+	if isDigits(s) && !isDate(s) {
+		secs, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return time.Time{}, timeParseError(s)
+		}
+		return time.Unix(secs, 0), nil
+	}
+
+	ref, offset := s, ""
+	if i := strings.IndexAny(s, "+-"); i >= 0 {
+		ref, offset = s[:i], s[i:]
+	}
+
+	t := now
+	if ref != "" && ref != "now" {
+		var err error
+		if t, err = parseReference(ref, now, loc); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	if offset != "" {
+		d, err := parseOffset(offset)
+		if err != nil {
+			return time.Time{}, err
+		}
+		t = t.Add(d)
+	}
+
+	return t, nil
+}
+
+// parseReference converts a named or absolute time reference into a time
+func parseReference(ref string, now time.Time, loc *time.Location) (time.Time, error) {
+	n := now.In(loc)
+	midnight := time.Date(n.Year(), n.Month(), n.Day(), 0, 0, 0, 0, loc)
+	switch ref {
+	case "today", "midnight":
+		return midnight, nil
+	case "yesterday":
+		return midnight.AddDate(0, 0, -1), nil
+	case "tomorrow":
+		return midnight.AddDate(0, 0, 1), nil
+	case "noon":
+		return midnight.Add(12 * time.Hour), nil
+	}
+	for _, layout := range []string{layoutHourMinuteDate, layoutDate} {
+		if t, err := time.ParseInLocation(layout, ref, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, timeParseError(ref)
+}
+
+// parseOffset converts a signed offset such as "-1h30min" into a duration
+func parseOffset(s string) (time.Duration, error) {
+
+	sign := time.Duration(1)
+	switch s[0] {
+	case '-':
+		sign = -1
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	if s == "" {
+		return 0, timeParseError(s)
+	}
+
+	var d time.Duration
+	for s != "" {
+		i := 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		j := i
+		for j < len(s) && s[j] >= 'a' && s[j] <= 'z' {
+			j++
+		}
+		if i == 0 || j == i {
+			return 0, timeParseError(s)
+		}
+		n, err := strconv.ParseInt(s[:i], 10, 64)
+		if err != nil {
+			return 0, timeParseError(s)
+		}
+		unit, ok := unitDuration(s[i:j])
+		if !ok {
+			return 0, timeParseError(s)
+		}
+		d += time.Duration(n) * unit
+		s = s[j:]
+	}
+
+	return sign * d, nil
+}
+
+// unitDuration maps a unit name to its duration using Graphite's prefix
+// rules, where "m" means minutes and "mon" means a 30-day month
+func unitDuration(u string) (time.Duration, bool) {
+	switch {
+	case strings.HasPrefix(u, "s"):
+		return time.Second, true
+	case strings.HasPrefix(u, "min"):
+		return time.Minute, true
+	case strings.HasPrefix(u, "h"):
+		return time.Hour, true
+	case strings.HasPrefix(u, "d"):
+		return day, true
+	case strings.HasPrefix(u, "w"):
+		return 7 * day, true
+	case strings.HasPrefix(u, "mon"):
+		return 30 * day, true
+	case strings.HasPrefix(u, "m"):
+		return time.Minute, true
+	case strings.HasPrefix(u, "y"):
+		return 365 * day, true
+	}
+	return 0, false
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isDate reports whether an all-digit value is a YYYYMMDD date rather than epoch seconds
+func isDate(s string) bool {
+	if len(s) != 8 || s[:4] <= "1900" {
+		return false
+	}
+	_, err := time.Parse(layoutDate, s)
+	return err == nil
+}
+
+func timeParseError(s string) error {
+	return fmt.Errorf("unable to parse graphite time: %s", s)
+}
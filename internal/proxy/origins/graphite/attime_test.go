@@ -0,0 +1,72 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package graphite
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+
+	now := time.Date(2020, 1, 15, 10, 30, 0, 0, time.UTC)
+	ny, _ := time.LoadLocation("America/New_York")
+
+	tests := []struct {
+		input    string
+		loc      *time.Location
+		expected time.Time
+		err      bool
+	}{
+		{"now", time.UTC, now, false},
+		{"", time.UTC, time.Time{}, true},
+		{"1577836800", time.UTC, time.Unix(1577836800, 0), false},
+		{"-24h", time.UTC, now.Add(-24 * time.Hour), false},
+		{"now-1h30min", time.UTC, now.Add(-90 * time.Minute), false},
+		{"-5m", time.UTC, now.Add(-5 * time.Minute), false},
+		{"-2mon", time.UTC, now.Add(-60 * day), false},
+		{"-1w", time.UTC, now.Add(-7 * day), false},
+		{"-1y", time.UTC, now.Add(-365 * day), false},
+		{"-30s", time.UTC, now.Add(-30 * time.Second), false},
+		{"+1d", time.UTC, now.Add(day), false},
+		{"-1days", time.UTC, now.Add(-day), false},
+		{"20200101", time.UTC, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), false},
+		{"12:30_20200101", time.UTC, time.Date(2020, 1, 1, 12, 30, 0, 0, time.UTC), false},
+		{"12:30_20200101", ny, time.Date(2020, 1, 1, 12, 30, 0, 0, ny), false},
+		{"today", time.UTC, time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC), false},
+		{"midnight", time.UTC, time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC), false},
+		{"yesterday", time.UTC, time.Date(2020, 1, 14, 0, 0, 0, 0, time.UTC), false},
+		{"tomorrow", time.UTC, time.Date(2020, 1, 16, 0, 0, 0, 0, time.UTC), false},
+		{"noon", time.UTC, time.Date(2020, 1, 15, 12, 0, 0, 0, time.UTC), false},
+		{"today-1h", time.UTC, time.Date(2020, 1, 14, 23, 0, 0, 0, time.UTC), false},
+		{"-", time.UTC, time.Time{}, true},
+		{"-1", time.UTC, time.Time{}, true},
+		{"-h", time.UTC, time.Time{}, true},
+		{"-1x", time.UTC, time.Time{}, true},
+		{"-99999999999999999999h", time.UTC, time.Time{}, true},
+		{"99999999999999999999", time.UTC, time.Time{}, true},
+		{"invalid", time.UTC, time.Time{}, true},
+	}
+
+	for _, test := range tests {
+		out, err := parseTime(test.input, now, test.loc)
+		if (err != nil) != test.err {
+			t.Errorf("input %s: expected error %t got %v", test.input, test.err, err)
+			continue
+		}
+		if !out.Equal(test.expected) {
+			t.Errorf("input %s: expected %s got %s", test.input, test.expected, out)
+		}
+	}
+}
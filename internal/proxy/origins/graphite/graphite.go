@@ -0,0 +1,207 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+// Package graphite provides the Graphite origin type
+package graphite
+
+import (
+	"container/list"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Comcast/trickster/internal/cache"
+	"github.com/Comcast/trickster/internal/config"
+	"github.com/Comcast/trickster/internal/proxy"
+	"github.com/Comcast/trickster/internal/proxy/errors"
+	"github.com/Comcast/trickster/internal/proxy/urls"
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+// maxLearnedSteps caps the number of distinct render statements whose step is remembered
+const maxLearnedSteps = 10000
+
+// Client Implements the Proxy Client Interface
+type Client struct {
+	name               string
+	config             *config.OriginConfig
+	cache              cache.Cache
+	webClient          *http.Client
+	handlers           map[string]http.Handler
+	handlersRegistered bool
+
+	healthURL     *url.URL
+	healthMethod  string
+	healthHeaders http.Header
+
+	// Graphite's render API has no step parameter, so the step for each
+	// statement is learned from the datapoints of its first response.
+	// stepsLRU orders the learned steps from most to least recently used
+	steps    map[string]*list.Element
+	stepsLRU *list.List
+	stepsMtx sync.Mutex
+}
+
+// learnedStepEntry is the value of each element in a Client's stepsLRU
+type learnedStepEntry struct {
+	statement string
+	step      time.Duration
+}
+
+// NewClient returns a new Client Instance
+func NewClient(name string, oc *config.OriginConfig, cache cache.Cache) (*Client, error) {
+	c, err := proxy.NewHTTPClient(oc)
+	return &Client{name: name, config: oc, cache: cache, webClient: c}, err
+}
+
+// Configuration returns the upstream Configuration for this Client
+func (c *Client) Configuration() *config.OriginConfig {
+	return c.config
+}
+
+// HTTPClient returns the HTTP Transport the client is using
+func (c *Client) HTTPClient() *http.Client {
+	return c.webClient
+}
+
+// Cache returns and handle to the Cache instance used by the Client
+func (c *Client) Cache() cache.Cache {
+	return c.cache
+}
+
+// Name returns the name of the upstream Configuration proxied by the Client
+func (c *Client) Name() string {
+	return c.name
+}
+
+// SetCache sets the Cache object the client will use for caching origin content
+func (c *Client) SetCache(cc cache.Cache) {
+	c.cache = cc
+}
+
+// ParseTimeRangeQuery parses the key parts of a TimeRangeQuery from the inbound HTTP Request
+func (c *Client) ParseTimeRangeQuery(r *http.Request) (*timeseries.TimeRangeQuery, error) {
+	trq, err := parseRenderQuery(r.URL)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := c.learnedStep(trq.Statement)
+	if !ok {
+		return nil, errors.ErrStepParse
+	}
+	trq.Step = step
+	return trq, nil
+}
+
+// parseRenderQuery parses the targets and time range of a render API request
+func parseRenderQuery(u *url.URL) (*timeseries.TimeRangeQuery, error) {
+
+	qi := u.Query()
+	targets, ok := qi[upTarget]
+	if !ok || len(targets) == 0 {
+		return nil, errors.MissingURLParam(upTarget)
+	}
+
+	if f := qi.Get(upFormat); f != "json" || qi.Get(upJSONP) != "" {
+		return nil, errors.ErrNotTimeRangeQuery
+	}
+
+	loc := time.UTC
+	if tz := qi.Get(upTimeZone); tz != "" {
+		l, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, err
+		}
+		loc = l
+	}
+
+	now := time.Now()
+	from := qi.Get(upFrom)
+	if from == "" {
+		from = "-24h"
+	}
+	until := qi.Get(upUntil)
+	if until == "" {
+		until = "now"
+	}
+
+	start, err := parseTime(from, now, loc)
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseTime(until, now, loc)
+	if err != nil {
+		return nil, err
+	}
+	if end.Before(start) {
+		return nil, errors.ErrNotTimeRangeQuery
+	}
+
+	trq := &timeseries.TimeRangeQuery{
+		Statement: strings.Join(targets, "\n"),
+		Extent:    timeseries.Extent{Start: start, End: end},
+		// the render API has no notion of an open-ended "latest" datapoint
+		FastForwardDisable: true,
+	}
+
+	// the cache key ignores the time range and sees every target, since
+	// only the first value of a multi-valued parameter is considered
+	trq.TemplateURL = urls.Clone(u)
+	qt := trq.TemplateURL.Query()
+	qt.Del(upFrom)
+	qt.Del(upUntil)
+	qt.Del(upMaxDataPoints)
+	qt.Set(upTarget, trq.Statement)
+	trq.TemplateURL.RawQuery = qt.Encode()
+
+	return trq, nil
+}
+
+// learnedStep returns the step previously observed for the provided statement
+func (c *Client) learnedStep(statement string) (time.Duration, bool) {
+	c.stepsMtx.Lock()
+	defer c.stepsMtx.Unlock()
+	e, ok := c.steps[statement]
+	if !ok {
+		return 0, false
+	}
+	c.stepsLRU.MoveToFront(e)
+	return e.Value.(*learnedStepEntry).step, true
+}
+
+// learnStep records the step observed for the provided statement, evicting
+// the least-recently-used statement once maxLearnedSteps are remembered
+func (c *Client) learnStep(statement string, step time.Duration) {
+	if step <= 0 {
+		return
+	}
+	c.stepsMtx.Lock()
+	defer c.stepsMtx.Unlock()
+	if c.steps == nil {
+		c.steps = make(map[string]*list.Element)
+		c.stepsLRU = list.New()
+	}
+	if e, ok := c.steps[statement]; ok {
+		e.Value.(*learnedStepEntry).step = step
+		c.stepsLRU.MoveToFront(e)
+		return
+	}
+	if c.stepsLRU.Len() >= maxLearnedSteps {
+		e := c.stepsLRU.Back()
+		c.stepsLRU.Remove(e)
+		delete(c.steps, e.Value.(*learnedStepEntry).statement)
+	}
+	c.steps[statement] = c.stepsLRU.PushFront(&learnedStepEntry{statement: statement, step: step})
+}
@@ -0,0 +1,201 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package graphite
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	cr "github.com/Comcast/trickster/internal/cache/registration"
+	"github.com/Comcast/trickster/internal/config"
+	"github.com/Comcast/trickster/internal/proxy/errors"
+	"github.com/Comcast/trickster/internal/proxy/origins"
+	"github.com/Comcast/trickster/internal/util/metrics"
+)
+
+func init() {
+	metrics.Init()
+}
+
+func TestGraphiteClientInterfacing(t *testing.T) {
+
+	// this test ensures the client will properly conform to the
+	// Client and TimeseriesClient interfaces
+
+	c := &Client{name: "test"}
+	var oc origins.Client = c
+	var tc origins.TimeseriesClient = c
+
+	if oc.Name() != "test" {
+		t.Errorf("expected %s got %s", "test", oc.Name())
+	}
+
+	if tc.Name() != "test" {
+		t.Errorf("expected %s got %s", "test", tc.Name())
+	}
+}
+
+func TestNewClient(t *testing.T) {
+
+	err := config.Load("trickster", "test", []string{"-origin-type", "graphite", "-origin-url", "http://1"})
+	if err != nil {
+		t.Errorf("Could not load configuration: %s", err.Error())
+	}
+
+	cr.LoadCachesFromConfig()
+	cache, err := cr.GetCache("default")
+	if err != nil {
+		t.Error(err)
+	}
+
+	oc := &config.OriginConfig{OriginType: "TEST_CLIENT"}
+	c, err := NewClient("default", oc, cache)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if c.Name() != "default" {
+		t.Errorf("expected %s got %s", "default", c.Name())
+	}
+
+	if c.Cache().Configuration().CacheType != "memory" {
+		t.Errorf("expected %s got %s", "memory", c.Cache().Configuration().CacheType)
+	}
+
+	if c.Configuration().OriginType != "TEST_CLIENT" {
+		t.Errorf("expected %s got %s", "TEST_CLIENT", c.Configuration().OriginType)
+	}
+
+	if c.HTTPClient() == nil {
+		t.Error("expected non-nil http client")
+	}
+
+	c.SetCache(nil)
+	if c.Cache() != nil {
+		t.Error("expected nil cache")
+	}
+}
+
+func TestParseTimeRangeQuery(t *testing.T) {
+
+	client := &Client{name: "test"}
+	u := &url.URL{Path: "/render", RawQuery: url.Values{
+		"target":        {"a.b.c", "sumSeries(d.*)"},
+		"from":          {"1577836800"},
+		"until":         {"1577840400"},
+		"format":        {"json"},
+		"maxDataPoints": {"500"},
+	}.Encode()}
+
+	r := &http.Request{URL: u}
+	_, err := client.ParseTimeRangeQuery(r)
+	if err != errors.ErrStepParse {
+		t.Errorf("expected %v got %v", errors.ErrStepParse, err)
+	}
+
+	client.learnStep("a.b.c\nsumSeries(d.*)", time.Minute)
+	trq, err := client.ParseTimeRangeQuery(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if trq.Step != time.Minute {
+		t.Errorf("expected %s got %s", time.Minute, trq.Step)
+	}
+
+	if trq.Extent.Start.Unix() != 1577836800 || trq.Extent.End.Unix() != 1577840400 {
+		t.Errorf("unexpected extent %s", trq.Extent)
+	}
+
+	if !trq.FastForwardDisable {
+		t.Error("expected fast forward to be disabled")
+	}
+
+	const expected = "format=json&target=a.b.c%0AsumSeries%28d.%2A%29"
+	if trq.TemplateURL.RawQuery != expected {
+		t.Errorf("expected %s got %s", expected, trq.TemplateURL.RawQuery)
+	}
+}
+
+func TestParseRenderQuery(t *testing.T) {
+
+	tests := []struct {
+		query string
+		err   bool
+	}{
+		{"target=a&format=json", false},
+		{"target=a&format=json&from=-1h&until=now", false},
+		{"target=a&format=json&from=00:00_20200101&tz=America/New_York", false},
+		{"format=json", true},
+		{"target=a", true},
+		{"target=a&format=png", true},
+		{"target=a&format=json&jsonp=cb", true},
+		{"target=a&format=json&tz=Invalid/Zone", true},
+		{"target=a&format=json&from=invalid", true},
+		{"target=a&format=json&until=invalid", true},
+		{"target=a&format=json&from=-1h&until=-2h", true},
+	}
+
+	for _, test := range tests {
+		_, err := parseRenderQuery(&url.URL{RawQuery: test.query})
+		if (err != nil) != test.err {
+			t.Errorf("query %s: expected error %t got %v", test.query, test.err, err)
+		}
+	}
+
+	trq, _ := parseRenderQuery(&url.URL{RawQuery: "target=a&format=json"})
+	if d := trq.Extent.End.Sub(trq.Extent.Start); d != 24*time.Hour {
+		t.Errorf("expected default range of %s got %s", 24*time.Hour, d)
+	}
+}
+
+func TestLearnStep(t *testing.T) {
+
+	client := &Client{}
+	client.learnStep("a", 0)
+	if _, ok := client.learnedStep("a"); ok {
+		t.Error("expected non-positive step to be ignored")
+	}
+
+	for i := 0; i < maxLearnedSteps; i++ {
+		client.learnStep(strconv.Itoa(i), time.Second)
+	}
+	// using "0" makes "1" the least-recently-used statement
+	if _, ok := client.learnedStep("0"); !ok {
+		t.Errorf("expected learned step for %s", "0")
+	}
+	client.learnStep("a", time.Minute)
+	if len(client.steps) != maxLearnedSteps || client.stepsLRU.Len() != maxLearnedSteps {
+		t.Errorf("expected %d got %d", maxLearnedSteps, len(client.steps))
+	}
+	if s, _ := client.learnedStep("a"); s != time.Minute {
+		t.Errorf("expected %s got %s", time.Minute, s)
+	}
+	if _, ok := client.learnedStep("1"); ok {
+		t.Errorf("expected %s to be evicted", "1")
+	}
+	for _, k := range []string{"0", "2"} {
+		if _, ok := client.learnedStep(k); !ok {
+			t.Errorf("expected learned step for %s", k)
+		}
+	}
+
+	client.learnStep("a", time.Hour)
+	if s, _ := client.learnedStep("a"); s != time.Hour {
+		t.Errorf("expected %s got %s", time.Hour, s)
+	}
+}
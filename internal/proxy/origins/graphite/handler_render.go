@@ -0,0 +1,66 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package graphite
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/Comcast/trickster/internal/proxy/engines"
+)
+
+// RenderHandler handles render API requests for Graphite and processes json-formatted
+// results through the delta proxy cache
+func (c *Client) RenderHandler(w http.ResponseWriter, r *http.Request) {
+
+	// render parameters may be form-encoded in a POST body; move them into the
+	// query string so they are parsed and cache-keyed like a GET
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err == nil {
+			r.URL.RawQuery = r.Form.Encode()
+			r.Method = http.MethodGet
+			r.Body = nil
+			r.ContentLength = 0
+			r.Header.Del("Content-Type")
+			r.Header.Del("Content-Length")
+		}
+	}
+
+	r.URL = c.BuildUpstreamURL(r)
+
+	trq, err := parseRenderQuery(r.URL)
+	if err != nil {
+		// not a json time range query (e.g., png or pickle), so just proxy it
+		engines.DoProxy(w, r)
+		return
+	}
+
+	if _, ok := c.learnedStep(trq.Statement); ok {
+		engines.DeltaProxyCacheRequest(w, r)
+		return
+	}
+
+	// the step for this statement isn't known yet, so proxy this request and
+	// learn the step from the response so subsequent requests can be cached
+	buf := &bytes.Buffer{}
+	resp := engines.DoProxy(buf, r)
+	if resp.StatusCode == http.StatusOK {
+		var series []*Series
+		if json.Unmarshal(buf.Bytes(), &series) == nil {
+			c.learnStep(trq.Statement, stepFromSeries(series))
+		}
+	}
+	engines.Respond(w, resp.StatusCode, resp.Header, buf.Bytes())
+}
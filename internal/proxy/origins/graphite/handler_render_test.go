@@ -0,0 +1,109 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package graphite
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/trickster/internal/proxy/request"
+	tu "github.com/Comcast/trickster/internal/util/testing"
+)
+
+func testRenderQuery(format string) string {
+	return url.Values{"target": {"a.b.c"}, "from": {"-1h"}, "until": {"now"}, "format": {format}}.Encode()
+}
+
+func TestRenderHandler(t *testing.T) {
+
+	client := &Client{name: "test"}
+	ts, w, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, testRenderBody, nil, "graphite", "/render?"+testRenderQuery("json"), "debug")
+	ctx := r.Context()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	defer ts.Close()
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, ok := client.config.Paths["/render"]
+	if !ok {
+		t.Errorf("could not find path config named %s", "/render")
+	}
+
+	// the first request is proxied, and the step is learned from its response
+	client.RenderHandler(w, r)
+	resp := w.Result()
+
+	// it should return 200 OK
+	if resp.StatusCode != 200 {
+		t.Errorf("expected 200 got %d.", resp.StatusCode)
+	}
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if string(bodyBytes) != testRenderBody {
+		t.Errorf("expected %s got %s.", testRenderBody, bodyBytes)
+	}
+
+	if s, ok := client.learnedStep("a.b.c"); !ok || s != time.Minute {
+		t.Errorf("expected learned step %s got %s", time.Minute, s)
+	}
+
+	// subsequent requests are form-posted and go through the delta proxy cache
+	r = httptest.NewRequest(http.MethodPost, ts.URL+"/render", strings.NewReader(testRenderQuery("json")))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r = r.WithContext(ctx)
+	w = httptest.NewRecorder()
+
+	client.RenderHandler(w, r)
+	resp = w.Result()
+	if resp.StatusCode != 200 {
+		t.Errorf("expected 200 got %d.", resp.StatusCode)
+	}
+
+	if r.Method != http.MethodGet || r.URL.Query().Get("target") != "a.b.c" {
+		t.Errorf("expected form body to be moved to the query string, got %s %s", r.Method, r.URL.RawQuery)
+	}
+
+	if h := resp.Header.Get("X-Trickster-Result"); !strings.Contains(h, "engine=DeltaProxyCache") {
+		t.Errorf("expected delta proxy cache engine got %s", h)
+	}
+
+	// non-json formats are only proxied
+	r = httptest.NewRequest(http.MethodGet, ts.URL+"/render?"+testRenderQuery("png"), nil)
+	r = r.WithContext(ctx)
+	w = httptest.NewRecorder()
+
+	client.RenderHandler(w, r)
+	resp = w.Result()
+	if resp.StatusCode != 200 {
+		t.Errorf("expected 200 got %d.", resp.StatusCode)
+	}
+
+	if _, ok := client.learnedStep("a.b.c\npng"); ok {
+		t.Error("expected no learned step for a non-json request")
+	}
+}
@@ -0,0 +1,157 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package graphite
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+// Datapoint is a single [value, timestamp] pair in a render API response.
+// A nil Value represents a null datapoint.
+type Datapoint struct {
+	Value     *float64
+	Timestamp time.Time
+}
+
+// Datapoints is a list of Datapoint
+type Datapoints []Datapoint
+
+// Series is a single target's results in a render API response
+type Series struct {
+	Target     string            `json:"target"`
+	Tags       map[string]string `json:"tags,omitempty"`
+	Datapoints Datapoints        `json:"datapoints"`
+}
+
+// SeriesEnvelope is the render API response document structure optimized for time series manipulation
+type SeriesEnvelope struct {
+	Series       []*Series
+	StepDuration time.Duration
+	ExtentList   timeseries.ExtentList
+
+	timestamps map[time.Time]bool
+	isSorted   bool
+	isCounted  bool
+}
+
+// envelope is the cached representation of a SeriesEnvelope, which unlike the
+// plain list returned by Graphite, carries the step and extents
+type envelope struct {
+	Series       []*Series             `json:"series"`
+	StepDuration time.Duration         `json:"step,omitempty"`
+	ExtentList   timeseries.ExtentList `json:"extents,omitempty"`
+}
+
+// MarshalTimeseries converts a Timeseries into a JSON blob
+func (c *Client) MarshalTimeseries(ts timeseries.Timeseries) ([]byte, error) {
+	// Marshal the Envelope back to a json object for Cache Storage
+	return json.Marshal(ts)
+}
+
+// UnmarshalTimeseries converts a JSON blob into a Timeseries
+func (c *Client) UnmarshalTimeseries(data []byte) (timeseries.Timeseries, error) {
+	se := &SeriesEnvelope{}
+	err := json.Unmarshal(data, se)
+	return se, err
+}
+
+// MarshalJSON marshals the SeriesEnvelope as the plain series list Graphite
+// returns when it has no step or extents, and as an envelope otherwise
+func (se *SeriesEnvelope) MarshalJSON() ([]byte, error) {
+	series := se.Series
+	if series == nil {
+		series = []*Series{}
+	}
+	if len(se.ExtentList) == 0 && se.StepDuration == 0 {
+		return json.Marshal(series)
+	}
+	return json.Marshal(&envelope{Series: series, StepDuration: se.StepDuration, ExtentList: se.ExtentList})
+}
+
+// UnmarshalJSON unmarshals either a plain series list or an envelope into the SeriesEnvelope
+func (se *SeriesEnvelope) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if len(b) > 0 && b[0] == '[' {
+		se.Series = nil
+		return json.Unmarshal(b, &se.Series)
+	}
+	e := &envelope{}
+	if err := json.Unmarshal(b, e); err != nil {
+		return err
+	}
+	se.Series = e.Series
+	se.StepDuration = e.StepDuration
+	se.ExtentList = e.ExtentList
+	return nil
+}
+
+// MarshalJSON marshals the Datapoint as a [value, timestamp] pair
+func (d Datapoint) MarshalJSON() ([]byte, error) {
+	v := "null"
+	if d.Value != nil && !math.IsNaN(*d.Value) && !math.IsInf(*d.Value, 0) {
+		v = strconv.FormatFloat(*d.Value, 'f', -1, 64)
+	}
+	return []byte("[" + v + "," + strconv.FormatInt(d.Timestamp.Unix(), 10) + "]"), nil
+}
+
+// UnmarshalJSON unmarshals a [value, timestamp] pair into the Datapoint
+func (d *Datapoint) UnmarshalJSON(b []byte) error {
+	var pair []*float64
+	if err := json.Unmarshal(b, &pair); err != nil {
+		return err
+	}
+	if len(pair) != 2 || pair[1] == nil {
+		return fmt.Errorf("invalid graphite datapoint: %s", b)
+	}
+	d.Value = pair[0]
+	d.Timestamp = time.Unix(int64(*pair[1]), 0)
+	return nil
+}
+
+// Len returns the length of a slice of Datapoints
+func (d Datapoints) Len() int {
+	return len(d)
+}
+
+// Less returns true if i comes before j
+func (d Datapoints) Less(i, j int) bool {
+	return d[i].Timestamp.Before(d[j].Timestamp)
+}
+
+// Swap modifies a slice of Datapoints by swapping the values in indexes i and j
+func (d Datapoints) Swap(i, j int) {
+	d[i], d[j] = d[j], d[i]
+}
+
+// stepFromSeries returns the smallest positive interval between consecutive
+// datapoints across all series, which is the resolution Graphite rendered at
+func stepFromSeries(series []*Series) time.Duration {
+	var step time.Duration
+	for _, s := range series {
+		for i := 1; i < len(s.Datapoints); i++ {
+			d := s.Datapoints[i].Timestamp.Sub(s.Datapoints[i-1].Timestamp)
+			if d > 0 && (step == 0 || d < step) {
+				step = d
+			}
+		}
+	}
+	return step
+}
@@ -0,0 +1,108 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package graphite
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+const testRenderBody = `[{"target":"a.b.c","tags":{"name":"a.b.c"},"datapoints":[[1.5,1577836800],[null,1577836860],[3,1577836920]]}]`
+
+func TestMarshalTimeseries(t *testing.T) {
+
+	client := &Client{}
+	ts, err := client.UnmarshalTimeseries([]byte(testRenderBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	se := ts.(*SeriesEnvelope)
+	if len(se.Series) != 1 || len(se.Series[0].Datapoints) != 3 {
+		t.Fatalf("unexpected series %v", se.Series)
+	}
+
+	if se.Series[0].Datapoints[1].Value != nil {
+		t.Error("expected null value")
+	}
+
+	b, err := client.MarshalTimeseries(se)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(b) != testRenderBody {
+		t.Errorf("expected %s got %s", testRenderBody, b)
+	}
+
+	se.SetStep(time.Minute)
+	se.SetExtents(timeseries.ExtentList{{Start: time.Unix(1577836800, 0), End: time.Unix(1577836920, 0)}})
+	b, err = client.MarshalTimeseries(se)
+	if err != nil {
+		t.Error(err)
+	}
+
+	ts, err = client.UnmarshalTimeseries(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	se2 := ts.(*SeriesEnvelope)
+	if se2.StepDuration != time.Minute || len(se2.ExtentList) != 1 || se2.ValueCount() != 3 {
+		t.Errorf("envelope did not round trip: %s", b)
+	}
+
+	b, _ = client.MarshalTimeseries(&SeriesEnvelope{})
+	if string(b) != "[]" {
+		t.Errorf("expected %s got %s", "[]", b)
+	}
+
+	if _, err = client.UnmarshalTimeseries([]byte("{")); err == nil {
+		t.Error("expected error for invalid json")
+	}
+}
+
+func TestDatapointJSON(t *testing.T) {
+
+	nan := math.NaN()
+	d := Datapoint{Value: &nan, Timestamp: time.Unix(60, 0)}
+	b, _ := d.MarshalJSON()
+	if string(b) != "[null,60]" {
+		t.Errorf("expected %s got %s", "[null,60]", b)
+	}
+
+	for _, in := range []string{"[1]", "[1,null]", "{}"} {
+		if err := d.UnmarshalJSON([]byte(in)); err == nil {
+			t.Errorf("expected error for %s", in)
+		}
+	}
+}
+
+func TestStepFromSeries(t *testing.T) {
+
+	series := []*Series{
+		{Datapoints: Datapoints{{Timestamp: time.Unix(0, 0)}, {Timestamp: time.Unix(60, 0)}}},
+		{Datapoints: Datapoints{{Timestamp: time.Unix(0, 0)}, {Timestamp: time.Unix(10, 0)}, {Timestamp: time.Unix(20, 0)}}},
+		{Datapoints: Datapoints{{Timestamp: time.Unix(0, 0)}}},
+	}
+
+	if s := stepFromSeries(series); s != 10*time.Second {
+		t.Errorf("expected %s got %s", 10*time.Second, s)
+	}
+
+	if s := stepFromSeries(nil); s != 0 {
+		t.Errorf("expected %d got %s", 0, s)
+	}
+}
@@ -0,0 +1,300 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package graphite
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Comcast/trickster/internal/timeseries"
+	"github.com/Comcast/trickster/pkg/sort/times"
+)
+
+// SetExtents overwrites a Timeseries's known extents with the provided extent list
+func (se *SeriesEnvelope) SetExtents(extents timeseries.ExtentList) {
+	se.isCounted = false
+	se.ExtentList = extents
+}
+
+// Extents returns the Timeseries's ExentList
+func (se *SeriesEnvelope) Extents() timeseries.ExtentList {
+	return se.ExtentList
+}
+
+// Step returns the step for the Timeseries
+func (se *SeriesEnvelope) Step() time.Duration {
+	return se.StepDuration
+}
+
+// SetStep sets the step for the Timeseries
+func (se *SeriesEnvelope) SetStep(step time.Duration) {
+	se.StepDuration = step
+}
+
+// Merge merges the provided Timeseries list into the base Timeseries (in the order provided) and optionally sorts the merged Timeseries
+func (se *SeriesEnvelope) Merge(sort bool, collection ...timeseries.Timeseries) {
+
+	index := make(map[string]*Series, len(se.Series))
+	for _, s := range se.Series {
+		index[s.Target] = s
+	}
+
+	for _, ts := range collection {
+		if ts == nil {
+			continue
+		}
+		se2 := ts.(*SeriesEnvelope)
+		for _, s := range se2.Series {
+			if s1, ok := index[s.Target]; ok {
+				s1.Datapoints = append(s1.Datapoints, s.Datapoints...)
+				continue
+			}
+			s1 := s.clone()
+			index[s.Target] = s1
+			se.Series = append(se.Series, s1)
+		}
+		se.ExtentList = append(se.ExtentList, se2.ExtentList...)
+	}
+
+	se.ExtentList = se.ExtentList.Compress(se.StepDuration)
+	se.isSorted = false
+	se.isCounted = false
+	if sort {
+		se.Sort()
+	}
+}
+
+// Clone returns a perfect copy of the base Timeseries
+func (se *SeriesEnvelope) Clone() timeseries.Timeseries {
+	se2 := &SeriesEnvelope{
+		StepDuration: se.StepDuration,
+		isSorted:     se.isSorted,
+		isCounted:    se.isCounted,
+	}
+
+	if se.Series != nil {
+		se2.Series = make([]*Series, len(se.Series))
+		for i, s := range se.Series {
+			se2.Series[i] = s.clone()
+		}
+	}
+
+	if se.ExtentList != nil {
+		se2.ExtentList = make(timeseries.ExtentList, len(se.ExtentList))
+		copy(se2.ExtentList, se.ExtentList)
+	}
+
+	if se.timestamps != nil {
+		se2.timestamps = make(map[time.Time]bool, len(se.timestamps))
+		for k, v := range se.timestamps {
+			se2.timestamps[k] = v
+		}
+	}
+
+	return se2
+}
+
+func (s *Series) clone() *Series {
+	s2 := &Series{Target: s.Target}
+	if s.Tags != nil {
+		s2.Tags = make(map[string]string, len(s.Tags))
+		for k, v := range s.Tags {
+			s2.Tags[k] = v
+		}
+	}
+	if s.Datapoints != nil {
+		s2.Datapoints = make(Datapoints, len(s.Datapoints))
+		copy(s2.Datapoints, s.Datapoints)
+	}
+	return s2
+}
+
+// CropToSize reduces the number of elements in the Timeseries to the provided count, by evicting elements
+// using a least-recently-used methodology. Any timestamps newer than the provided time are removed before
+// sizing, in order to support backfill tolerance. The provided extent will be marked as used during crop.
+func (se *SeriesEnvelope) CropToSize(sz int, t time.Time, lur timeseries.Extent) {
+	se.isCounted = false
+	se.isSorted = false
+	x := len(se.ExtentList)
+	// The Series has no extents, so no need to do anything
+	if x < 1 {
+		se.Series = []*Series{}
+		se.ExtentList = timeseries.ExtentList{}
+		return
+	}
+
+	// Crop to the Backfill Tolerance Value if needed
+	if se.ExtentList[x-1].End.After(t) {
+		se.CropToRange(timeseries.Extent{Start: se.ExtentList[0].Start, End: t})
+	}
+
+	tc := se.TimestampCount()
+	el := timeseries.ExtentListLRU(se.ExtentList).UpdateLastUsed(lur, se.StepDuration)
+	sort.Sort(el)
+	if len(se.Series) == 0 || tc <= sz {
+		return
+	}
+
+	rc := tc - sz // # of required timestamps we must delete to meet the retention policy
+	removals := make(map[time.Time]bool)
+	done := false
+
+	for _, x := range el {
+		for ts := x.Start; !x.End.Before(ts) && !done; ts = ts.Add(se.StepDuration) {
+			if _, ok := se.timestamps[ts]; ok {
+				removals[ts] = true
+				done = len(removals) >= rc
+			}
+		}
+		if done {
+			break
+		}
+	}
+
+	for _, s := range se.Series {
+		tmp := s.Datapoints[:0]
+		for _, d := range s.Datapoints {
+			if _, ok := removals[d.Timestamp]; !ok {
+				tmp = append(tmp, d)
+			}
+		}
+		s.Datapoints = tmp
+	}
+
+	tl := times.FromMap(removals)
+	sort.Sort(tl)
+
+	for _, t := range tl {
+		for i, e := range el {
+			if e.StartsAt(t) {
+				el[i].Start = e.Start.Add(se.StepDuration)
+			}
+		}
+	}
+
+	se.ExtentList = timeseries.ExtentList(el).Compress(se.StepDuration)
+	se.Sort()
+}
+
+// CropToRange reduces the Timeseries down to timestamps contained within the provided Extents (inclusive).
+// Series left without any datapoints in the range are removed.
+func (se *SeriesEnvelope) CropToRange(e timeseries.Extent) {
+	se.isCounted = false
+	x := len(se.ExtentList)
+	// The Series has no extents, or is entirely outside of the crop range,
+	// so return an empty set
+	if x < 1 || se.ExtentList.OutsideOf(e) {
+		se.Series = []*Series{}
+		se.ExtentList = timeseries.ExtentList{}
+		return
+	}
+
+	series := se.Series[:0]
+	for _, s := range se.Series {
+		tmp := s.Datapoints[:0]
+		for _, d := range s.Datapoints {
+			if !d.Timestamp.Before(e.Start) && !d.Timestamp.After(e.End) {
+				tmp = append(tmp, d)
+			}
+		}
+		s.Datapoints = tmp
+		if len(tmp) > 0 {
+			series = append(series, s)
+		}
+	}
+	se.Series = series
+	se.ExtentList = se.ExtentList.Crop(e)
+}
+
+// Sort sorts all Datapoints in each Series chronologically by their timestamp. When a timestamp
+// is duplicated, the most recently merged value is kept unless it is null and an earlier one is not.
+func (se *SeriesEnvelope) Sort() {
+
+	if se.isSorted || len(se.Series) == 0 {
+		return
+	}
+
+	tsm := make(map[time.Time]bool)
+	for _, s := range se.Series {
+		sort.Stable(s.Datapoints)
+		tmp := s.Datapoints[:0]
+		for _, d := range s.Datapoints {
+			if n := len(tmp); n > 0 && tmp[n-1].Timestamp.Equal(d.Timestamp) {
+				if d.Value != nil || tmp[n-1].Value == nil {
+					tmp[n-1] = d
+				}
+				continue
+			}
+			tmp = append(tmp, d)
+			tsm[d.Timestamp] = true
+		}
+		s.Datapoints = tmp
+	}
+
+	sort.Sort(se.ExtentList)
+
+	se.timestamps = tsm
+	se.isCounted = true
+	se.isSorted = true
+}
+
+func (se *SeriesEnvelope) updateTimestamps() {
+	if se.isCounted {
+		return
+	}
+	m := make(map[time.Time]bool)
+	for _, s := range se.Series {
+		for _, d := range s.Datapoints {
+			m[d.Timestamp] = true
+		}
+	}
+	se.timestamps = m
+	se.isCounted = true
+}
+
+// TimestampCount returns the number of unique timestamps across the timeseries
+func (se *SeriesEnvelope) TimestampCount() int {
+	se.updateTimestamps()
+	return len(se.timestamps)
+}
+
+// SeriesCount returns the number of individual Series in the Timeseries object
+func (se *SeriesEnvelope) SeriesCount() int {
+	return len(se.Series)
+}
+
+// ValueCount returns the count of all values across all Series in the Timeseries object
+func (se *SeriesEnvelope) ValueCount() int {
+	c := 0
+	for _, s := range se.Series {
+		c += len(s.Datapoints)
+	}
+	return c
+}
+
+// Size returns the approximate memory utilization in bytes of the timeseries
+func (se *SeriesEnvelope) Size() int {
+	size := 0
+	for _, s := range se.Series {
+		size += len(s.Target)
+		for k, v := range s.Tags {
+			size += len(k) + len(v)
+		}
+		// Value pointer + value + Timestamp
+		size += len(s.Datapoints) * 40
+	}
+	// ExtentList + StepDuration + Timestamps + isCounted + isSorted
+	size += (len(se.ExtentList) * 24) + 8 + (len(se.timestamps) * 9) + 2
+	return size
+}
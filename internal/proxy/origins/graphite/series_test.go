@@ -0,0 +1,172 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package graphite
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+func fp(v float64) *float64 {
+	return &v
+}
+
+func tm(s int64) time.Time {
+	return time.Unix(s, 0)
+}
+
+func testEnvelope(target string, start, end int64) *SeriesEnvelope {
+	s := &Series{Target: target, Tags: map[string]string{"name": target}}
+	for i := start; i <= end; i += 10 {
+		s.Datapoints = append(s.Datapoints, Datapoint{Value: fp(float64(i)), Timestamp: tm(i)})
+	}
+	return &SeriesEnvelope{
+		Series:       []*Series{s},
+		StepDuration: 10 * time.Second,
+		ExtentList:   timeseries.ExtentList{{Start: tm(start), End: tm(end)}},
+	}
+}
+
+func TestSetExtents(t *testing.T) {
+	se := &SeriesEnvelope{}
+	el := timeseries.ExtentList{{Start: tm(0), End: tm(10)}}
+	se.SetExtents(el)
+	if len(se.Extents()) != 1 {
+		t.Errorf("expected %d got %d", 1, len(se.Extents()))
+	}
+}
+
+func TestStep(t *testing.T) {
+	se := &SeriesEnvelope{}
+	se.SetStep(time.Minute)
+	if se.Step() != time.Minute {
+		t.Errorf("expected %s got %s", time.Minute, se.Step())
+	}
+}
+
+func TestMerge(t *testing.T) {
+
+	se := testEnvelope("a", 0, 40)
+	se2 := testEnvelope("a", 50, 90)
+	se3 := testEnvelope("b", 0, 40)
+
+	// a later null must not replace an existing value, but a later value replaces an earlier one
+	se2.Series[0].Datapoints = append(se2.Series[0].Datapoints,
+		Datapoint{Value: nil, Timestamp: tm(0)}, Datapoint{Value: fp(99), Timestamp: tm(10)})
+
+	se.Merge(true, se2, se3, nil)
+
+	if se.SeriesCount() != 2 {
+		t.Errorf("expected %d got %d", 2, se.SeriesCount())
+	}
+
+	if se.ValueCount() != 15 {
+		t.Errorf("expected %d got %d", 15, se.ValueCount())
+	}
+
+	if se.TimestampCount() != 10 {
+		t.Errorf("expected %d got %d", 10, se.TimestampCount())
+	}
+
+	dp := se.Series[0].Datapoints
+	if dp[0].Value == nil || *dp[0].Value != 0 {
+		t.Errorf("expected %d got %v", 0, dp[0].Value)
+	}
+	if *dp[1].Value != 99 {
+		t.Errorf("expected %d got %f", 99, *dp[1].Value)
+	}
+
+	if len(se.ExtentList) != 1 || !se.ExtentList[0].End.Equal(tm(90)) {
+		t.Errorf("unexpected extents %s", se.ExtentList)
+	}
+}
+
+func TestClone(t *testing.T) {
+
+	se := testEnvelope("a", 0, 40)
+	se.Sort()
+	se2 := se.Clone().(*SeriesEnvelope)
+
+	se2.Series[0].Datapoints[0].Timestamp = tm(5)
+	se2.Series[0].Tags["name"] = "b"
+	if se.Series[0].Datapoints[0].Timestamp.Equal(tm(5)) || se.Series[0].Tags["name"] != "a" {
+		t.Error("clone is not a deep copy")
+	}
+
+	if se2.TimestampCount() != 5 || len(se2.ExtentList) != 1 || se2.StepDuration != se.StepDuration {
+		t.Error("clone is not a perfect copy")
+	}
+}
+
+func TestCropToRange(t *testing.T) {
+
+	se := testEnvelope("a", 0, 90)
+	se.Merge(false, testEnvelope("b", 0, 20))
+
+	se.CropToRange(timeseries.Extent{Start: tm(30), End: tm(50)})
+	if se.SeriesCount() != 1 || se.ValueCount() != 3 {
+		t.Errorf("expected 1 series with 3 values got %d with %d", se.SeriesCount(), se.ValueCount())
+	}
+	if !se.ExtentList[0].Start.Equal(tm(30)) || !se.ExtentList[0].End.Equal(tm(50)) {
+		t.Errorf("unexpected extents %s", se.ExtentList)
+	}
+
+	se.CropToRange(timeseries.Extent{Start: tm(100), End: tm(200)})
+	if se.SeriesCount() != 0 || len(se.ExtentList) != 0 {
+		t.Errorf("expected empty timeseries got %d series", se.SeriesCount())
+	}
+
+	se = &SeriesEnvelope{}
+	se.CropToRange(timeseries.Extent{Start: tm(0), End: tm(10)})
+	if se.Series == nil {
+		t.Error("expected empty series list")
+	}
+}
+
+func TestCropToSize(t *testing.T) {
+
+	now := time.Now().Truncate(10 * time.Second)
+	se := testEnvelope("a", now.Add(-90*time.Second).Unix(), now.Unix())
+
+	se.CropToSize(5, now.Add(-20*time.Second), timeseries.Extent{Start: now.Add(-30 * time.Second), End: now})
+	if se.TimestampCount() != 5 {
+		t.Errorf("expected %d got %d", 5, se.TimestampCount())
+	}
+
+	dp := se.Series[0].Datapoints
+	if !dp[len(dp)-1].Timestamp.Equal(now.Add(-20 * time.Second)) {
+		t.Errorf("expected backfill crop to %s got %s", now.Add(-20*time.Second), dp[len(dp)-1].Timestamp)
+	}
+
+	if !se.ExtentList[0].Start.Equal(dp[0].Timestamp) {
+		t.Errorf("expected extent start %s got %s", dp[0].Timestamp, se.ExtentList[0].Start)
+	}
+
+	se = &SeriesEnvelope{}
+	se.CropToSize(5, now, timeseries.Extent{})
+	if se.Series == nil || se.ExtentList == nil {
+		t.Error("expected empty series and extent lists")
+	}
+}
+
+func TestSize(t *testing.T) {
+	se := testEnvelope("a", 0, 40)
+	se.Sort()
+	const expected = 1 + 5 + 5*40 + 24 + 8 + 5*9 + 2
+	if se.Size() != expected {
+		t.Errorf("expected %d got %d", expected, se.Size())
+	}
+}
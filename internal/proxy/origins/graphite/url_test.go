@@ -0,0 +1,70 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package graphite
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Comcast/trickster/internal/config"
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+func TestSetExtent(t *testing.T) {
+
+	start := time.Unix(1577836800, 0)
+	end := time.Unix(1577840400, 0)
+	const expected = "format=json&from=1577836799&target=a.b.c&until=1577840400"
+
+	client := &Client{}
+	u := &url.URL{RawQuery: "target=a.b.c&format=json&from=-1h&maxDataPoints=100"}
+	e := &timeseries.Extent{Start: start, End: end}
+
+	r, _ := http.NewRequest(http.MethodGet, u.String(), nil)
+	trq := &timeseries.TimeRangeQuery{TemplateURL: u}
+
+	client.SetExtent(r, trq, e)
+	if expected != r.URL.RawQuery {
+		t.Errorf("\nexpected [%s]\ngot      [%s]", expected, r.URL.RawQuery)
+	}
+
+	client.SetExtent(r, trq, nil)
+	if expected != r.URL.RawQuery {
+		t.Errorf("\nexpected [%s]\ngot      [%s]", expected, r.URL.RawQuery)
+	}
+
+}
+
+func TestBuildUpstreamURL(t *testing.T) {
+
+	cfg := config.NewConfig()
+	oc := cfg.Origins["default"]
+	oc.Scheme = "http"
+	oc.Host = "0"
+	oc.PathPrefix = ""
+
+	client := &Client{name: "default", config: oc}
+	r, err := http.NewRequest(http.MethodGet, "http://0/default/render?target=a.b.c&format=json", nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	u := client.BuildUpstreamURL(r)
+	if u.Path != "/render" {
+		t.Errorf("expected %s got %s", "/render", u.Path)
+	}
+
+}
@@ -0,0 +1,250 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package influxdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Comcast/trickster/internal/proxy/errors"
+	"github.com/Comcast/trickster/internal/proxy/headers"
+	"github.com/Comcast/trickster/internal/proxy/timeconv"
+	"github.com/Comcast/trickster/internal/proxy/urls"
+	"github.com/Comcast/trickster/internal/timeseries"
+	"github.com/Comcast/trickster/internal/util/regexp/matching"
+)
+
+// This file handles parsing and tokenization of the time range within
+// InfluxDB 2.x Flux queries for cache key hashing and delta proxy caching.
+
+// Tokens for String Interpolation
+const (
+	tkRange = "<$RANGE_TOKEN$>"
+)
+
+var reFluxRange, reFluxRangeCall, reFluxStep, reFluxTimeSrcStart *regexp.Regexp
+
+func init() {
+
+	// Regexp for extracting the time range from a Flux query. searches for something like: range(start: -1h, stop: now())
+	reFluxRange = regexp.MustCompile(`\brange\(\s*start\s*:\s*(?P<start>now\(\)|[^,\)\s]+)\s*(,\s*stop\s*:\s*(?P<stop>now\(\)|[^,\)\s]+)\s*)?\)`)
+
+	// Regexp for counting the range() calls in a Flux query, to ensure each of them is matched by reFluxRange
+	reFluxRangeCall = regexp.MustCompile(`\brange\(`)
+
+	// Regexp for extracting the step from a Flux query. searches for something like: aggregateWindow(every: 1m, fn: mean)
+	reFluxStep = regexp.MustCompile(`\baggregateWindow\([^)]*?\bevery\s*:\s*(?P<step>[0-9][0-9a-zµμ]*)`)
+
+	// Regexp for detecting aggregate windows that are timestamped at their start rather than their stop
+	reFluxTimeSrcStart = regexp.MustCompile(`\btimeSrc\s*:\s*"_start"`)
+}
+
+// fluxRequest is the JSON request body format of the /api/v2/query endpoint
+type fluxRequest map[string]json.RawMessage
+
+// isFluxRequest returns true if the request is for the InfluxDB 2.x Flux query API
+func isFluxRequest(r *http.Request) bool {
+	return r != nil && r.URL != nil && strings.HasSuffix(r.URL.Path, "/"+mnQueryV2)
+}
+
+// parseFluxQuery parses the key parts of a TimeRangeQuery from an inbound /api/v2/query request
+func parseFluxQuery(r *http.Request) (*timeseries.TimeRangeQuery, error) {
+
+	if r.Body == nil {
+		return nil, errors.MissingRequestParam(upFluxQuery)
+	}
+
+	b, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		return nil, errors.ParseRequestBody(err)
+	}
+
+	var query, envelope string
+	if strings.HasPrefix(r.Header.Get(headers.NameContentType), headers.ValueApplicationJSON) {
+		fr := fluxRequest{}
+		if err := json.Unmarshal(b, &fr); err != nil {
+			return nil, errors.ParseRequestBody(err)
+		}
+		if err := json.Unmarshal(fr[upFluxQuery], &query); err != nil {
+			return nil, errors.MissingRequestParam(upFluxQuery)
+		}
+		delete(fr, upFluxQuery)
+		// the remainder of the request (e.g., dialect) is kept so it can be
+		// factored into the cache key and reassembled for upstream requests
+		eb, _ := json.Marshal(fr)
+		envelope = string(eb)
+	} else {
+		query = string(b)
+	}
+
+	step, found := matching.GetNamedMatch("step", reFluxStep, query)
+	if !found {
+		return nil, errors.ErrStepParse
+	}
+	stepDuration, err := parseFluxDuration(step)
+	if err != nil || stepDuration <= 0 {
+		return nil, errors.ErrStepParse
+	}
+
+	statement, extent, err := getFluxQueryParts(query, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	trq := &timeseries.TimeRangeQuery{Statement: statement, Extent: extent, Step: stepDuration}
+
+	// Swap in the Tokenized Query and Request Envelope in the Url Params
+	trq.TemplateURL = urls.Clone(r.URL)
+	qi := trq.TemplateURL.Query()
+	qi.Set(upFluxQuery, statement)
+	if envelope != "" {
+		qi.Set(upFluxRequest, envelope)
+	}
+	trq.TemplateURL.RawQuery = qi.Encode()
+
+	return trq, nil
+}
+
+// getFluxQueryParts tokenizes the range() calls in a Flux query and returns the
+// tokenized query with the time range they specify. All range() calls must be
+// identical, or the query is not considered a time range query.
+func getFluxQueryParts(query string, now time.Time) (string, timeseries.Extent, error) {
+
+	var e timeseries.Extent
+
+	matches := reFluxRange.FindAllString(query, -1)
+	if len(matches) == 0 || len(matches) != len(reFluxRangeCall.FindAllString(query, -1)) {
+		return "", e, errors.ErrNotTimeRangeQuery
+	}
+	for _, m := range matches[1:] {
+		if m != matches[0] {
+			return "", e, errors.ErrNotTimeRangeQuery
+		}
+	}
+
+	parts := matching.GetNamedMatches(reFluxRange, matches[0], nil)
+	var err error
+	if e.Start, err = parseFluxTime(parts["start"], now); err != nil {
+		return "", e, err
+	}
+	e.End = now
+	if stop, ok := parts["stop"]; ok && stop != "" {
+		if e.End, err = parseFluxTime(stop, now); err != nil {
+			return "", e, err
+		}
+	}
+	if e.End.Before(e.Start) {
+		return "", e, errors.ErrNotTimeRangeQuery
+	}
+
+	return strings.Replace(query, matches[0], "range("+tkRange+")", -1), e, nil
+}
+
+// interpolateFluxQuery replaces the range token in a tokenized Flux query with the
+// provided extent. Flux's stop is exclusive, and aggregateWindow timestamps each
+// window at its stop by default, so the range is shifted by one step as needed to
+// return the datapoints at both the start and end of the extent.
+func interpolateFluxQuery(template string, extent *timeseries.Extent, step time.Duration) string {
+	start, stop := extent.Start.Add(-step), extent.End
+	if reFluxTimeSrcStart.MatchString(template) {
+		start, stop = extent.Start, extent.End.Add(step)
+	}
+	return strings.Replace(template, tkRange,
+		fmt.Sprintf("start: %s, stop: %s", start.UTC().Format(time.RFC3339Nano), stop.UTC().Format(time.RFC3339Nano)), -1)
+}
+
+// setFluxExtent rewrites the body of a Flux request to query the provided extent
+func setFluxExtent(r *http.Request, trq *timeseries.TimeRangeQuery, extent *timeseries.Extent) {
+
+	t := trq.TemplateURL.Query()
+	query := interpolateFluxQuery(t.Get(upFluxQuery), extent, trq.Step)
+
+	var b []byte
+	if envelope := t.Get(upFluxRequest); envelope != "" {
+		fr := fluxRequest{}
+		json.Unmarshal([]byte(envelope), &fr)
+		fr[upFluxQuery], _ = json.Marshal(query)
+		b, _ = json.Marshal(fr)
+		r.Header.Set(headers.NameContentType, headers.ValueApplicationJSON)
+	} else {
+		b = []byte(query)
+		r.Header.Set(headers.NameContentType, headers.ValueApplicationFlux)
+	}
+
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	r.ContentLength = int64(len(b))
+	r.Header.Set(headers.NameContentLength, strconv.Itoa(len(b)))
+}
+
+// parseFluxTime converts a range() start or stop value into a time. Supported
+// values are now(), relative durations (e.g., -1h), RFC3339 times and integer
+// Unix timestamps in seconds.
+func parseFluxTime(v string, now time.Time) (time.Time, error) {
+	if v == "now()" {
+		return now, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+		return t, nil
+	}
+	if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(i, 0), nil
+	}
+	neg := strings.HasPrefix(v, "-")
+	d, err := parseFluxDuration(strings.TrimPrefix(v, "-"))
+	if err != nil {
+		return time.Time{}, err
+	}
+	if neg {
+		d = -d
+	}
+	return now.Add(d), nil
+}
+
+// parseFluxDuration parses a Flux duration literal such as 1h30m. Calendar
+// months (mo) have no fixed length and are not supported.
+func parseFluxDuration(v string) (time.Duration, error) {
+	if v == "" {
+		return errors.ParseDuration(v)
+	}
+	var d time.Duration
+	for s := v; s != ""; {
+		i := 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		j := i
+		for j < len(s) && (s[j] < '0' || s[j] > '9') {
+			j++
+		}
+		if i == 0 {
+			return errors.ParseDuration(v)
+		}
+		n, err := timeconv.ParseDuration(s[:j])
+		if err != nil {
+			return errors.ParseDuration(v)
+		}
+		d += n
+		s = s[j:]
+	}
+	return d, nil
+}
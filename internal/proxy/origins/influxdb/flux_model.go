@@ -0,0 +1,285 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package influxdb
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Comcast/trickster/internal/timeseries"
+	str "github.com/Comcast/trickster/internal/util/strings"
+)
+
+// Flux annotated CSV column names with special meaning to Trickster
+const (
+	fcResult = "result"
+	fcTable  = "table"
+	fcStart  = "_start"
+	fcStop   = "_stop"
+	fcTime   = "_time"
+)
+
+// fluxEnvelopePrefix identifies a FluxResult that was marshaled for cache storage
+var fluxEnvelopePrefix = []byte(`{"csv":`)
+
+// FluxResult represents an annotated CSV response from the InfluxDB 2.x Flux query API
+type FluxResult struct {
+	Tables       []*FluxTable
+	StepDuration time.Duration
+	ExtentList   timeseries.ExtentList
+
+	timestamps map[time.Time]bool // tracks unique timestamps in the result
+	isSorted   bool               // tracks if the result is currently sorted
+	isCounted  bool               // tracks if timestamps map is up-to-date
+}
+
+// FluxTable is a block of annotated CSV rows that share a single schema
+type FluxTable struct {
+	Annotations [][]string
+	Header      []string
+	Series      []*FluxSeries
+
+	timeIndex  int
+	keyIndexes []int
+}
+
+// FluxSeries is the list of rows in a FluxTable that share a group key
+type FluxSeries struct {
+	Key  string
+	Rows []FluxRow
+}
+
+// FluxRow is a single data row of a FluxTable
+type FluxRow struct {
+	Timestamp time.Time
+	Values    []string
+}
+
+// fluxEnvelope is the cached representation of a FluxResult, which carries the step and extents
+type fluxEnvelope struct {
+	CSV          string                `json:"csv"`
+	StepDuration time.Duration         `json:"step,omitempty"`
+	ExtentList   timeseries.ExtentList `json:"extents,omitempty"`
+}
+
+// isFluxResponse returns true if the response body is not an InfluxQL JSON document
+func isFluxResponse(data []byte) bool {
+	if bytes.HasPrefix(data, fluxEnvelopePrefix) {
+		return true
+	}
+	b := bytes.TrimSpace(data)
+	return len(data) > 0 && (len(b) == 0 || b[0] != '{')
+}
+
+// unmarshalFluxResult converts a cached FluxResult or an annotated CSV response body into a FluxResult
+func unmarshalFluxResult(data []byte) (*FluxResult, error) {
+	if bytes.HasPrefix(data, fluxEnvelopePrefix) {
+		fe := &fluxEnvelope{}
+		if err := json.Unmarshal(data, fe); err != nil {
+			return nil, err
+		}
+		fr, err := parseFluxCSV([]byte(fe.CSV))
+		if err != nil {
+			return nil, err
+		}
+		fr.StepDuration = fe.StepDuration
+		fr.ExtentList = fe.ExtentList
+		return fr, nil
+	}
+	return parseFluxCSV(data)
+}
+
+// parseFluxCSV converts an annotated CSV document into a FluxResult. Tables
+// with differing schemas are separated by an empty line.
+func parseFluxCSV(data []byte) (*FluxResult, error) {
+	fr := &FluxResult{Tables: []*FluxTable{}}
+	for _, block := range splitFluxBlocks(data) {
+		t, err := parseFluxTable(block)
+		if err != nil {
+			return nil, err
+		}
+		if t != nil {
+			fr.Tables = append(fr.Tables, t)
+		}
+	}
+	return fr, nil
+}
+
+func splitFluxBlocks(data []byte) []string {
+	blocks := make([]string, 0, 1)
+	var sb strings.Builder
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if line == "" {
+			if sb.Len() > 0 {
+				blocks = append(blocks, sb.String())
+				sb.Reset()
+			}
+			continue
+		}
+		sb.WriteString(line)
+		sb.WriteString("\n")
+	}
+	if sb.Len() > 0 {
+		blocks = append(blocks, sb.String())
+	}
+	return blocks
+}
+
+func parseFluxTable(block string) (*FluxTable, error) {
+
+	cr := csv.NewReader(strings.NewReader(block))
+	cr.FieldsPerRecord = -1
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	t := &FluxTable{Series: []*FluxSeries{}}
+	i := 0
+	for ; i < len(records) && strings.HasPrefix(records[i][0], "#"); i++ {
+		t.Annotations = append(t.Annotations, records[i])
+	}
+	if i == len(records) {
+		return nil, nil
+	}
+	t.Header = records[i]
+	if err := t.index(); err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]*FluxSeries)
+	for _, rec := range records[i+1:] {
+		if len(rec) != len(t.Header) {
+			return nil, fmt.Errorf("flux table row has %d columns, expected %d", len(rec), len(t.Header))
+		}
+		ts, err := time.Parse(time.RFC3339Nano, rec[t.timeIndex])
+		if err != nil {
+			return nil, err
+		}
+		key := t.seriesKey(rec)
+		s, ok := index[key]
+		if !ok {
+			s = &FluxSeries{Key: key}
+			index[key] = s
+			t.Series = append(t.Series, s)
+		}
+		s.Rows = append(s.Rows, FluxRow{Timestamp: ts, Values: rec})
+	}
+
+	return t, nil
+}
+
+// index locates the time column and the columns that make up the group key of each series.
+// The _start and _stop columns are excluded from the group key since they vary with the
+// time range of each upstream request.
+func (t *FluxTable) index() error {
+
+	t.timeIndex = str.IndexOfString(t.Header, fcTime)
+	if t.timeIndex < 0 {
+		return fmt.Errorf("flux table has no %s column", fcTime)
+	}
+
+	var group []string
+	for _, a := range t.Annotations {
+		if a[0] == "#group" && len(a) == len(t.Header) {
+			group = a
+		}
+	}
+
+	t.keyIndexes = make([]int, 0, len(t.Header))
+	for i, h := range t.Header {
+		switch {
+		case h == fcStart || h == fcStop:
+		case h == fcResult:
+			t.keyIndexes = append(t.keyIndexes, i)
+		case group == nil && h == fcTable:
+			t.keyIndexes = append(t.keyIndexes, i)
+		case group != nil && group[i] == "true" && h != fcTable:
+			t.keyIndexes = append(t.keyIndexes, i)
+		}
+	}
+	return nil
+}
+
+func (t *FluxTable) seriesKey(rec []string) string {
+	parts := make([]string, len(t.keyIndexes))
+	for i, j := range t.keyIndexes {
+		parts[i] = rec[j]
+	}
+	return strings.Join(parts, "\x00")
+}
+
+// schema returns a string uniquely identifying the table's annotations and header
+func (t *FluxTable) schema() string {
+	var sb strings.Builder
+	for _, a := range t.Annotations {
+		sb.WriteString(strings.Join(a, ","))
+		sb.WriteString("\n")
+	}
+	sb.WriteString(strings.Join(t.Header, ","))
+	return sb.String()
+}
+
+// CSV returns the FluxResult as an annotated CSV document, with table ids renumbered
+// sequentially within each result
+func (fr *FluxResult) CSV() []byte {
+
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	w.UseCRLF = true
+	ids := make(map[string]int)
+
+	for _, t := range fr.Tables {
+		w.WriteAll(t.Annotations)
+		w.Write(t.Header)
+		ri := str.IndexOfString(t.Header, fcResult)
+		ti := str.IndexOfString(t.Header, fcTable)
+		for _, s := range t.Series {
+			var result string
+			if ri >= 0 && len(s.Rows) > 0 {
+				result = s.Rows[0].Values[ri]
+			}
+			id := ids[result]
+			ids[result] = id + 1
+			for _, r := range s.Rows {
+				rec := r.Values
+				if ti >= 0 {
+					rec = make([]string, len(r.Values))
+					copy(rec, r.Values)
+					rec[ti] = strconv.Itoa(id)
+				}
+				w.Write(rec)
+			}
+		}
+		w.Flush()
+		buf.WriteString("\r\n")
+	}
+	w.Flush()
+	return buf.Bytes()
+}
+
+// marshal returns the FluxResult as annotated CSV for client responses, or as a
+// JSON envelope that carries the step and extents for cache storage
+func (fr *FluxResult) marshal() ([]byte, error) {
+	if len(fr.ExtentList) == 0 && fr.StepDuration == 0 {
+		return fr.CSV(), nil
+	}
+	return json.Marshal(&fluxEnvelope{CSV: string(fr.CSV()), StepDuration: fr.StepDuration, ExtentList: fr.ExtentList})
+}
@@ -0,0 +1,137 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package influxdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+const testFluxCSV = "#datatype,string,long,dateTime:RFC3339,dateTime:RFC3339,dateTime:RFC3339,double,string,string\r\n" +
+	"#group,false,false,true,true,false,false,true,true\r\n" +
+	"#default,_result,,,,,,,\r\n" +
+	",result,table,_start,_stop,_time,_value,_field,host\r\n" +
+	",,0,2020-01-01T00:00:00Z,2020-01-01T00:03:00Z,2020-01-01T00:01:00Z,1.5,usage,a\r\n" +
+	",,0,2020-01-01T00:00:00Z,2020-01-01T00:03:00Z,2020-01-01T00:02:00Z,2.5,usage,a\r\n" +
+	",,1,2020-01-01T00:00:00Z,2020-01-01T00:03:00Z,2020-01-01T00:01:00Z,3.5,usage,b\r\n" +
+	"\r\n" +
+	"#datatype,string,long,dateTime:RFC3339,dateTime:RFC3339,dateTime:RFC3339,long,string\r\n" +
+	"#group,false,false,true,true,false,false,true\r\n" +
+	"#default,_result,,,,,,\r\n" +
+	",result,table,_start,_stop,_time,_value,_field\r\n" +
+	",,2,2020-01-01T00:00:00Z,2020-01-01T00:03:00Z,2020-01-01T00:01:00Z,7,count\r\n" +
+	"\r\n"
+
+func TestFluxResultMarshaling(t *testing.T) {
+
+	client := &Client{}
+	ts, err := client.UnmarshalTimeseries([]byte(testFluxCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fr, ok := ts.(*FluxResult)
+	if !ok {
+		t.Fatalf("expected *FluxResult got %T", ts)
+	}
+
+	if len(fr.Tables) != 2 || fr.SeriesCount() != 3 || fr.ValueCount() != 4 {
+		t.Errorf("expected 2 tables, 3 series and 4 values got %d, %d and %d", len(fr.Tables), fr.SeriesCount(), fr.ValueCount())
+	}
+
+	b, err := client.MarshalTimeseries(fr)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(b) != testFluxCSV {
+		t.Errorf("expected\n%s\ngot\n%s", testFluxCSV, b)
+	}
+
+	// with a step and extents, the result is enveloped for cache storage
+	fr.SetStep(time.Minute)
+	fr.SetExtents(timeseries.ExtentList{{Start: time.Unix(1577836860, 0), End: time.Unix(1577836920, 0)}})
+	b, err = client.MarshalTimeseries(fr)
+	if err != nil {
+		t.Error(err)
+	}
+	if !isFluxResponse(b) {
+		t.Errorf("expected a flux envelope got %s", b)
+	}
+
+	ts, err = client.UnmarshalTimeseries(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fr2 := ts.(*FluxResult)
+	if fr2.StepDuration != time.Minute || len(fr2.ExtentList) != 1 || fr2.ValueCount() != 4 {
+		t.Errorf("envelope did not round trip: %s", b)
+	}
+
+	// an empty response is an empty flux result
+	ts, err = client.UnmarshalTimeseries([]byte("\r\n"))
+	if err != nil {
+		t.Error(err)
+	}
+	if ts.(*FluxResult).SeriesCount() != 0 {
+		t.Errorf("expected %d got %d", 0, ts.SeriesCount())
+	}
+
+	// influxql responses are unaffected
+	ts, err = client.UnmarshalTimeseries([]byte(`{"results":[]}`))
+	if err != nil {
+		t.Error(err)
+	}
+	if _, ok := ts.(*SeriesEnvelope); !ok {
+		t.Errorf("expected *SeriesEnvelope got %T", ts)
+	}
+}
+
+func TestParseFluxCSVErrors(t *testing.T) {
+
+	tests := []string{
+		",result,table,_value\r\n,,0,1\r\n",
+		",result,table,_time,_value\r\n,,0,2020-01-01T00:01:00Z\r\n",
+		",result,table,_time,_value\r\n,,0,invalid,1\r\n",
+		",result,table,_time\r\n,,0,\"unterminated\r\n",
+		`{"csv":1}`,
+		`{"csv":",result,_value\r\n"}`,
+	}
+
+	for _, test := range tests {
+		if _, err := unmarshalFluxResult([]byte(test)); err == nil {
+			t.Errorf("expected error for %s", test)
+		}
+	}
+
+	// a table without annotations is keyed by its table column
+	fr, err := parseFluxCSV([]byte(",result,table,_time,_value\r\n,_result,0,2020-01-01T00:01:00Z,1\r\n,_result,1,2020-01-01T00:01:00Z,2\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fr.SeriesCount() != 2 {
+		t.Errorf("expected %d got %d", 2, fr.SeriesCount())
+	}
+
+	// annotations without a header are ignored
+	fr, err = parseFluxCSV([]byte("#datatype,string\r\n"))
+	if err != nil || len(fr.Tables) != 0 {
+		t.Errorf("expected no tables got %d (%v)", len(fr.Tables), err)
+	}
+
+	if len(fr.CSV()) != 0 {
+		t.Errorf("expected empty csv got %s", fr.CSV())
+	}
+}
@@ -0,0 +1,388 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package influxdb
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Comcast/trickster/internal/timeseries"
+	str "github.com/Comcast/trickster/internal/util/strings"
+	"github.com/Comcast/trickster/pkg/sort/times"
+)
+
+// SetExtents overwrites a Timeseries's known extents with the provided extent list
+func (fr *FluxResult) SetExtents(extents timeseries.ExtentList) {
+	fr.ExtentList = make(timeseries.ExtentList, len(extents))
+	copy(fr.ExtentList, extents)
+	fr.isCounted = false
+}
+
+// Extents returns the Timeseries's ExentList
+func (fr *FluxResult) Extents() timeseries.ExtentList {
+	return fr.ExtentList
+}
+
+// Step returns the step for the Timeseries
+func (fr *FluxResult) Step() time.Duration {
+	return fr.StepDuration
+}
+
+// SetStep sets the step for the Timeseries
+func (fr *FluxResult) SetStep(step time.Duration) {
+	fr.StepDuration = step
+}
+
+// SeriesCount returns the count of all Series across all Tables in the Timeseries
+func (fr *FluxResult) SeriesCount() int {
+	c := 0
+	for _, t := range fr.Tables {
+		c += len(t.Series)
+	}
+	return c
+}
+
+// ValueCount returns the count of all rows across all Series in the Timeseries
+func (fr *FluxResult) ValueCount() int {
+	c := 0
+	for _, t := range fr.Tables {
+		for _, s := range t.Series {
+			c += len(s.Rows)
+		}
+	}
+	return c
+}
+
+// TimestampCount returns the count of unique timestamps across all Series in the Timeseries
+func (fr *FluxResult) TimestampCount() int {
+	fr.updateTimestamps()
+	return len(fr.timestamps)
+}
+
+func (fr *FluxResult) updateTimestamps() {
+	if fr.isCounted {
+		return
+	}
+	m := make(map[time.Time]bool)
+	for _, t := range fr.Tables {
+		for _, s := range t.Series {
+			for _, r := range s.Rows {
+				m[r.Timestamp] = true
+			}
+		}
+	}
+	fr.timestamps = m
+	fr.isCounted = true
+}
+
+// Merge merges the provided Timeseries list into the base Timeseries (in the order provided) and optionally sorts the merged Timeseries
+func (fr *FluxResult) Merge(sort bool, collection ...timeseries.Timeseries) {
+
+	tables := make(map[string]*FluxTable, len(fr.Tables))
+	for _, t := range fr.Tables {
+		tables[t.schema()] = t
+	}
+
+	for _, ts := range collection {
+		if ts == nil {
+			continue
+		}
+		fr2 := ts.(*FluxResult)
+		for _, t2 := range fr2.Tables {
+			t, ok := tables[t2.schema()]
+			if !ok {
+				t = t2.clone()
+				tables[t.schema()] = t
+				fr.Tables = append(fr.Tables, t)
+				continue
+			}
+			series := make(map[string]*FluxSeries, len(t.Series))
+			for _, s := range t.Series {
+				series[s.Key] = s
+			}
+			for _, s2 := range t2.Series {
+				if s, ok := series[s2.Key]; ok {
+					s.Rows = append(s.Rows, s2.clone().Rows...)
+					continue
+				}
+				t.Series = append(t.Series, s2.clone())
+			}
+		}
+		fr.ExtentList = append(fr.ExtentList, fr2.ExtentList...)
+	}
+
+	fr.ExtentList = fr.ExtentList.Compress(fr.StepDuration)
+	fr.isSorted = false
+	fr.isCounted = false
+	if sort {
+		fr.Sort()
+	}
+}
+
+// Clone returns a perfect copy of the base Timeseries
+func (fr *FluxResult) Clone() timeseries.Timeseries {
+	fr2 := &FluxResult{
+		Tables:       make([]*FluxTable, len(fr.Tables)),
+		StepDuration: fr.StepDuration,
+		ExtentList:   make(timeseries.ExtentList, len(fr.ExtentList)),
+		isSorted:     fr.isSorted,
+	}
+	copy(fr2.ExtentList, fr.ExtentList)
+	for i, t := range fr.Tables {
+		fr2.Tables[i] = t.clone()
+	}
+	return fr2
+}
+
+func (t *FluxTable) clone() *FluxTable {
+	t2 := &FluxTable{
+		Annotations: make([][]string, len(t.Annotations)),
+		Header:      make([]string, len(t.Header)),
+		Series:      make([]*FluxSeries, len(t.Series)),
+		timeIndex:   t.timeIndex,
+		keyIndexes:  make([]int, len(t.keyIndexes)),
+	}
+	for i, a := range t.Annotations {
+		t2.Annotations[i] = make([]string, len(a))
+		copy(t2.Annotations[i], a)
+	}
+	copy(t2.Header, t.Header)
+	copy(t2.keyIndexes, t.keyIndexes)
+	for i, s := range t.Series {
+		t2.Series[i] = s.clone()
+	}
+	return t2
+}
+
+func (s *FluxSeries) clone() *FluxSeries {
+	s2 := &FluxSeries{Key: s.Key, Rows: make([]FluxRow, len(s.Rows))}
+	for i, r := range s.Rows {
+		s2.Rows[i] = FluxRow{Timestamp: r.Timestamp, Values: make([]string, len(r.Values))}
+		copy(s2.Rows[i].Values, r.Values)
+	}
+	return s2
+}
+
+// CropToSize reduces the number of elements in the Timeseries to the provided count, by evicting elements
+// using a least-recently-used methodology. The time parameter limits the upper extent to the provided time,
+// in order to support backfill tolerance
+func (fr *FluxResult) CropToSize(sz int, t time.Time, lur timeseries.Extent) {
+
+	fr.isCounted = false
+	fr.isSorted = false
+	x := len(fr.ExtentList)
+	// The Series has no extents, so no need to do anything
+	if x < 1 {
+		fr.Tables = []*FluxTable{}
+		fr.ExtentList = timeseries.ExtentList{}
+		return
+	}
+
+	// Crop to the Backfill Tolerance Value if needed
+	if fr.ExtentList[x-1].End.After(t) {
+		fr.CropToRange(timeseries.Extent{Start: fr.ExtentList[0].Start, End: t})
+	}
+
+	tc := fr.TimestampCount()
+	if len(fr.Tables) == 0 || tc <= sz {
+		return
+	}
+
+	el := timeseries.ExtentListLRU(fr.ExtentList).UpdateLastUsed(lur, fr.StepDuration)
+	sort.Sort(el)
+
+	rc := tc - sz // # of required timestamps we must delete to meet the rentention policy
+	removals := make(map[time.Time]bool)
+	done := false
+
+	for _, x := range el {
+		for ts := x.Start; !x.End.Before(ts) && !done; ts = ts.Add(fr.StepDuration) {
+			// row timestamps are parsed in UTC, while extents may be in any location
+			if _, ok := fr.timestamps[ts.UTC()]; ok {
+				removals[ts.UTC()] = true
+				done = len(removals) >= rc
+			}
+		}
+		if done {
+			break
+		}
+	}
+
+	fr.filterRows(func(r FluxRow) bool { return !removals[r.Timestamp] })
+
+	tl := times.FromMap(removals)
+	sort.Sort(tl)
+	for _, t := range tl {
+		for i, e := range el {
+			if e.StartsAt(t) {
+				el[i].Start = e.Start.Add(fr.StepDuration)
+			}
+		}
+	}
+
+	fr.ExtentList = timeseries.ExtentList(el).Compress(fr.StepDuration)
+	fr.Sort()
+}
+
+// CropToRange reduces the Timeseries down to timestamps contained within the provided Extents (inclusive).
+// The _start and _stop columns of the remaining rows are narrowed to the provided Extent.
+func (fr *FluxResult) CropToRange(e timeseries.Extent) {
+
+	fr.isCounted = false
+	x := len(fr.ExtentList)
+	// The Series has no extents, or is entirely outside of the crop range, so return an empty set
+	if x < 1 || fr.ExtentList.OutsideOf(e) {
+		fr.Tables = []*FluxTable{}
+		fr.ExtentList = timeseries.ExtentList{}
+		return
+	}
+
+	fr.filterRows(func(r FluxRow) bool {
+		return !r.Timestamp.Before(e.Start) && !r.Timestamp.After(e.End)
+	})
+	for _, t := range fr.Tables {
+		for _, s := range t.Series {
+			t.normalizeBounds(s, e)
+		}
+	}
+	fr.ExtentList = fr.ExtentList.Crop(e)
+}
+
+// filterRows retains only the rows for which keep returns true, and removes
+// any Series and Tables that are left empty
+func (fr *FluxResult) filterRows(keep func(FluxRow) bool) {
+	tables := fr.Tables[:0]
+	for _, t := range fr.Tables {
+		series := t.Series[:0]
+		for _, s := range t.Series {
+			rows := s.Rows[:0]
+			for _, r := range s.Rows {
+				if keep(r) {
+					rows = append(rows, r)
+				}
+			}
+			s.Rows = rows
+			if len(rows) > 0 {
+				series = append(series, s)
+			}
+		}
+		t.Series = series
+		if len(series) > 0 {
+			tables = append(tables, t)
+		}
+	}
+	fr.Tables = tables
+}
+
+// normalizeBounds sets the _start and _stop columns of each row in the Series to the
+// widest bounds across its rows, limited to the provided Extent when it is not zero.
+// Rows merged from separate upstream requests otherwise carry the bounds of their own request.
+func (t *FluxTable) normalizeBounds(s *FluxSeries, e timeseries.Extent) {
+
+	si := str.IndexOfString(t.Header, fcStart)
+	pi := str.IndexOfString(t.Header, fcStop)
+	if (si < 0 && pi < 0) || len(s.Rows) == 0 {
+		return
+	}
+
+	var start, stop time.Time
+	for _, r := range s.Rows {
+		if si >= 0 {
+			if v, err := time.Parse(time.RFC3339Nano, r.Values[si]); err == nil && (start.IsZero() || v.Before(start)) {
+				start = v
+			}
+		}
+		if pi >= 0 {
+			if v, err := time.Parse(time.RFC3339Nano, r.Values[pi]); err == nil && v.After(stop) {
+				stop = v
+			}
+		}
+	}
+	if !e.Start.IsZero() && e.Start.After(start) {
+		start = e.Start
+	}
+	if !e.End.IsZero() && (stop.IsZero() || e.End.Before(stop)) {
+		stop = e.End
+	}
+
+	for _, r := range s.Rows {
+		if si >= 0 && !start.IsZero() {
+			r.Values[si] = start.UTC().Format(time.RFC3339Nano)
+		}
+		if pi >= 0 && !stop.IsZero() {
+			r.Values[pi] = stop.UTC().Format(time.RFC3339Nano)
+		}
+	}
+}
+
+// Sort sorts all rows in each Series chronologically by their timestamp, keeping the most
+// recently merged row when a timestamp is duplicated
+func (fr *FluxResult) Sort() {
+
+	if fr.isSorted || len(fr.Tables) == 0 {
+		return
+	}
+
+	tsm := make(map[time.Time]bool)
+	for _, t := range fr.Tables {
+		for _, s := range t.Series {
+			sort.SliceStable(s.Rows, func(i, j int) bool { return s.Rows[i].Timestamp.Before(s.Rows[j].Timestamp) })
+			rows := s.Rows[:0]
+			for _, r := range s.Rows {
+				if n := len(rows); n > 0 && rows[n-1].Timestamp.Equal(r.Timestamp) {
+					rows[n-1] = r
+					continue
+				}
+				rows = append(rows, r)
+				tsm[r.Timestamp] = true
+			}
+			s.Rows = rows
+			t.normalizeBounds(s, timeseries.Extent{})
+		}
+	}
+
+	sort.Sort(fr.ExtentList)
+
+	fr.timestamps = tsm
+	fr.isCounted = true
+	fr.isSorted = true
+}
+
+// Size returns the approximate memory utilization in bytes of the timeseries
+func (fr *FluxResult) Size() int {
+	size := 0
+	for _, t := range fr.Tables {
+		for _, a := range t.Annotations {
+			for _, v := range a {
+				size += len(v)
+			}
+		}
+		for _, h := range t.Header {
+			size += len(h)
+		}
+		for _, s := range t.Series {
+			size += len(s.Key)
+			for _, r := range s.Rows {
+				// Timestamp
+				size += 24
+				for _, v := range r.Values {
+					size += len(v)
+				}
+			}
+		}
+	}
+	// ExtentList + StepDuration + Timestamps + isCounted + isSorted
+	size += (len(fr.ExtentList) * 24) + 8 + (len(fr.timestamps) * 9) + 2
+	return size
+}
@@ -0,0 +1,168 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package influxdb
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+func tfr(t *testing.T, hosts []string, start, end int64) *FluxResult {
+	csv := "#group,false,false,true,true,false,false,true\r\n,result,table,_start,_stop,_time,_value,host\r\n"
+	for i, h := range hosts {
+		for ts := start; ts <= end; ts += 60 {
+			csv += fmt.Sprintf(",,%d,%s,%s,%s,%d,%s\r\n", i, tfmt(start-60), tfmt(end), tfmt(ts), ts, h)
+		}
+	}
+	fr, err := parseFluxCSV([]byte(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fr.SetStep(time.Minute)
+	fr.SetExtents(timeseries.ExtentList{{Start: time.Unix(start, 0), End: time.Unix(end, 0)}})
+	return fr
+}
+
+func tfmt(ts int64) string {
+	return time.Unix(ts, 0).UTC().Format(time.RFC3339Nano)
+}
+
+func TestFluxResultStep(t *testing.T) {
+	fr := &FluxResult{}
+	fr.SetStep(time.Minute)
+	if fr.Step() != time.Minute {
+		t.Errorf("expected %s got %s", time.Minute, fr.Step())
+	}
+	fr.SetExtents(timeseries.ExtentList{{}})
+	if len(fr.Extents()) != 1 {
+		t.Errorf("expected %d got %d", 1, len(fr.Extents()))
+	}
+}
+
+func TestFluxResultMerge(t *testing.T) {
+
+	fr := tfr(t, []string{"a"}, 600, 840)
+	fr2 := tfr(t, []string{"a", "b"}, 900, 1200)
+	fr3 := tfr(t, []string{"a"}, 840, 900)
+	fr3.Tables[0].Header[6] = "_other" // a different schema is kept as its own table
+
+	fr.Merge(true, fr2, fr3, nil)
+
+	if len(fr.Tables) != 2 {
+		t.Errorf("expected %d got %d", 2, len(fr.Tables))
+	}
+
+	if fr.SeriesCount() != 3 {
+		t.Errorf("expected %d got %d", 3, fr.SeriesCount())
+	}
+
+	if fr.TimestampCount() != 11 {
+		t.Errorf("expected %d got %d", 11, fr.TimestampCount())
+	}
+
+	s := fr.Tables[0].Series[0]
+	if len(s.Rows) != 11 {
+		t.Errorf("expected %d got %d", 11, len(s.Rows))
+	}
+
+	// rows merged from separate requests share the widest bounds
+	for _, r := range s.Rows {
+		if r.Values[3] != tfmt(540) || r.Values[4] != tfmt(1200) {
+			t.Errorf("unexpected bounds %s %s", r.Values[3], r.Values[4])
+		}
+	}
+
+	if len(fr.ExtentList) != 1 || fr.ExtentList[0].End.Unix() != 1200 {
+		t.Errorf("unexpected extents %s", fr.ExtentList)
+	}
+}
+
+func TestFluxResultClone(t *testing.T) {
+
+	fr := tfr(t, []string{"a", "b"}, 600, 840)
+	fr2 := fr.Clone().(*FluxResult)
+
+	fr2.Tables[0].Series[0].Rows[0].Values[6] = "changed"
+	fr2.Tables[0].Header[0] = "changed"
+	if fr.Tables[0].Series[0].Rows[0].Values[6] == "changed" || fr.Tables[0].Header[0] == "changed" {
+		t.Error("clone is not a deep copy")
+	}
+
+	if fr2.ValueCount() != fr.ValueCount() || fr2.StepDuration != fr.StepDuration || len(fr2.ExtentList) != 1 {
+		t.Error("clone is not a perfect copy")
+	}
+}
+
+func TestFluxResultCropToRange(t *testing.T) {
+
+	fr := tfr(t, []string{"a"}, 600, 1200)
+	fr.Merge(false, tfr(t, []string{"b"}, 600, 720))
+
+	fr.CropToRange(timeseries.Extent{Start: time.Unix(900, 0), End: time.Unix(1020, 0)})
+	if fr.SeriesCount() != 1 || fr.ValueCount() != 3 {
+		t.Errorf("expected 1 series with 3 values got %d with %d", fr.SeriesCount(), fr.ValueCount())
+	}
+
+	r := fr.Tables[0].Series[0].Rows[0]
+	if r.Values[3] != tfmt(900) || r.Values[4] != tfmt(1020) {
+		t.Errorf("unexpected bounds %s %s", r.Values[3], r.Values[4])
+	}
+
+	fr.CropToRange(timeseries.Extent{Start: time.Unix(2000, 0), End: time.Unix(3000, 0)})
+	if len(fr.Tables) != 0 || len(fr.ExtentList) != 0 {
+		t.Errorf("expected empty timeseries got %d tables", len(fr.Tables))
+	}
+
+	fr.CropToRange(timeseries.Extent{Start: time.Unix(0, 0), End: time.Unix(10, 0)})
+	if fr.Tables == nil {
+		t.Error("expected empty table list")
+	}
+}
+
+func TestFluxResultCropToSize(t *testing.T) {
+
+	now := time.Now().Truncate(time.Minute)
+	fr := tfr(t, []string{"a"}, now.Add(-9*time.Minute).Unix(), now.Unix())
+
+	fr.CropToSize(5, now.Add(-2*time.Minute), timeseries.Extent{Start: now.Add(-3 * time.Minute), End: now})
+	if fr.TimestampCount() != 5 {
+		t.Errorf("expected %d got %d", 5, fr.TimestampCount())
+	}
+
+	rows := fr.Tables[0].Series[0].Rows
+	if !rows[len(rows)-1].Timestamp.Equal(now.Add(-2 * time.Minute)) {
+		t.Errorf("expected backfill crop to %s got %s", now.Add(-2*time.Minute), rows[len(rows)-1].Timestamp)
+	}
+
+	if !fr.ExtentList[0].Start.Equal(rows[0].Timestamp) {
+		t.Errorf("expected extent start %s got %s", rows[0].Timestamp, fr.ExtentList[0].Start)
+	}
+
+	fr = &FluxResult{}
+	fr.CropToSize(5, now, timeseries.Extent{})
+	if fr.Tables == nil || fr.ExtentList == nil {
+		t.Error("expected empty table and extent lists")
+	}
+}
+
+func TestFluxResultSize(t *testing.T) {
+	fr := tfr(t, []string{"a"}, 600, 600)
+	fr.Sort()
+	if fr.Size() != 209 {
+		t.Errorf("expected %d got %d", 209, fr.Size())
+	}
+}
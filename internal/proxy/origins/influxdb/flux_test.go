@@ -0,0 +1,242 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package influxdb
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/trickster/internal/proxy/errors"
+	"github.com/Comcast/trickster/internal/proxy/headers"
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+const testFluxQuery = `from(bucket: "telegraf")
+  |> range(start: 2020-01-01T00:00:00Z, stop: 2020-01-01T01:00:00Z)
+  |> filter(fn: (r) => r._measurement == "cpu")
+  |> aggregateWindow(every: 1m, fn: mean)`
+
+func testFluxRequest(t *testing.T, contentType, body string) *http.Request {
+	r, err := http.NewRequest(http.MethodPost, "http://0/api/v2/query?org=test", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set(headers.NameContentType, contentType)
+	return r
+}
+
+func TestIsFluxRequest(t *testing.T) {
+	if isFluxRequest(nil) {
+		t.Error("expected false for nil request")
+	}
+	r, _ := http.NewRequest(http.MethodPost, "http://0/test/api/v2/query", nil)
+	if !isFluxRequest(r) {
+		t.Error("expected true for flux request")
+	}
+	r, _ = http.NewRequest(http.MethodGet, "http://0/query", nil)
+	if isFluxRequest(r) {
+		t.Error("expected false for influxql request")
+	}
+}
+
+func TestParseFluxQuery(t *testing.T) {
+
+	b, _ := json.Marshal(map[string]interface{}{"query": testFluxQuery, "type": "flux",
+		"dialect": map[string]interface{}{"annotations": []string{"datatype", "group", "default"}}})
+	r := testFluxRequest(t, headers.ValueApplicationJSON, string(b))
+
+	client := &Client{}
+	trq, err := client.ParseTimeRangeQuery(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if trq.Step != time.Minute {
+		t.Errorf("expected %s got %s", time.Minute, trq.Step)
+	}
+
+	if trq.Extent.Start.Unix() != 1577836800 || trq.Extent.End.Unix() != 1577840400 {
+		t.Errorf("unexpected extent %s", trq.Extent)
+	}
+
+	if !strings.Contains(trq.Statement, "range("+tkRange+")") {
+		t.Errorf("expected tokenized statement got %s", trq.Statement)
+	}
+
+	qi := trq.TemplateURL.Query()
+	if qi.Get(upFluxQuery) != trq.Statement || qi.Get("org") != "test" {
+		t.Errorf("unexpected template url %s", trq.TemplateURL)
+	}
+	if strings.Contains(qi.Get(upFluxRequest), "range") || !strings.Contains(qi.Get(upFluxRequest), "dialect") {
+		t.Errorf("unexpected request envelope %s", qi.Get(upFluxRequest))
+	}
+
+	// the body must remain readable for proxying
+	rb, _ := ioutil.ReadAll(r.Body)
+	if string(rb) != string(b) {
+		t.Errorf("expected %s got %s", b, rb)
+	}
+
+	r = testFluxRequest(t, headers.ValueApplicationFlux, testFluxQuery)
+	trq, err = parseFluxQuery(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trq.TemplateURL.Query().Get(upFluxRequest) != "" {
+		t.Error("expected no request envelope for a raw flux body")
+	}
+
+	tests := []struct {
+		contentType string
+		body        string
+		err         error
+	}{
+		{headers.ValueApplicationJSON, "{", nil},
+		{headers.ValueApplicationJSON, "{}", errors.MissingRequestParam(upFluxQuery)},
+		{headers.ValueApplicationFlux, strings.Replace(testFluxQuery, "aggregateWindow", "window", 1), errors.ErrStepParse},
+		{headers.ValueApplicationFlux, strings.Replace(testFluxQuery, "every: 1m", "every: 1mo", 1), errors.ErrStepParse},
+		{headers.ValueApplicationFlux, strings.Replace(testFluxQuery, "stop: 2020-01-01T01:00:00Z", "stop: v.timeRangeStop", 1), nil},
+	}
+
+	for _, test := range tests {
+		_, err := parseFluxQuery(testFluxRequest(t, test.contentType, test.body))
+		if err == nil {
+			t.Errorf("expected error for %s", test.body)
+		} else if test.err != nil && err.Error() != test.err.Error() {
+			t.Errorf("expected %v got %v", test.err, err)
+		}
+	}
+
+	if _, err := parseFluxQuery(&http.Request{URL: &url.URL{}}); err == nil {
+		t.Error("expected error for nil body")
+	}
+}
+
+func TestGetFluxQueryParts(t *testing.T) {
+
+	now := time.Unix(1577840400, 0)
+
+	tests := []struct {
+		query string
+		start int64
+		end   int64
+		err   bool
+	}{
+		{"range(start: -1h)", 1577836800, 1577840400, false},
+		{"range(start: -1h, stop: now())", 1577836800, 1577840400, false},
+		{"range(start: 1577836800, stop: -30m)", 1577836800, 1577838600, false},
+		{"range(start: -1h) |> join(range(start: -1h))", 1577836800, 1577840400, false},
+		{"range(start: -1h) |> join(range(start: -2h))", 0, 0, true},
+		{"range(stop: now(), start: -1h)", 0, 0, true},
+		{"range(start: -1h, stop: -2h)", 0, 0, true},
+		{"range(start: -1x)", 0, 0, true},
+		{"range(start: -1h, stop: bad)", 0, 0, true},
+		{"filter(fn: (r) => true)", 0, 0, true},
+	}
+
+	for _, test := range tests {
+		q, e, err := getFluxQueryParts(test.query, now)
+		if (err != nil) != test.err {
+			t.Errorf("query %s: expected error %t got %v", test.query, test.err, err)
+			continue
+		}
+		if test.err {
+			continue
+		}
+		if e.Start.Unix() != test.start || e.End.Unix() != test.end {
+			t.Errorf("query %s: unexpected extent %s", test.query, e)
+		}
+		if strings.Contains(q, "start:") {
+			t.Errorf("query %s: expected tokenized query got %s", test.query, q)
+		}
+	}
+}
+
+func TestSetFluxExtent(t *testing.T) {
+
+	e := &timeseries.Extent{Start: time.Unix(1577836800, 0), End: time.Unix(1577840400, 0)}
+	client := &Client{}
+
+	for _, ct := range []string{headers.ValueApplicationJSON, headers.ValueApplicationFlux} {
+		body := testFluxQuery
+		if ct == headers.ValueApplicationJSON {
+			b, _ := json.Marshal(map[string]string{"query": testFluxQuery, "type": "flux"})
+			body = string(b)
+		}
+		r := testFluxRequest(t, ct, body)
+		trq, err := parseFluxQuery(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		client.SetExtent(r, trq, e)
+		b, _ := ioutil.ReadAll(r.Body)
+		q := string(b)
+		if ct == headers.ValueApplicationJSON {
+			fr := map[string]string{}
+			json.Unmarshal(b, &fr)
+			if fr["type"] != "flux" {
+				t.Errorf("expected request envelope to be preserved got %s", b)
+			}
+			q = fr["query"]
+		}
+
+		const expected = "range(start: 2019-12-31T23:59:00Z, stop: 2020-01-01T01:00:00Z)"
+		if !strings.Contains(q, expected) {
+			t.Errorf("expected %s in %s", expected, q)
+		}
+		if r.ContentLength != int64(len(b)) || r.Header.Get(headers.NameContentType) != ct {
+			t.Errorf("unexpected headers %v", r.Header)
+		}
+	}
+
+	q := interpolateFluxQuery(`range(`+tkRange+`) |> aggregateWindow(every: 1m, fn: mean, timeSrc: "_start")`, e, time.Minute)
+	const expected = "range(start: 2020-01-01T00:00:00Z, stop: 2020-01-01T01:01:00Z)"
+	if !strings.Contains(q, expected) {
+		t.Errorf("expected %s in %s", expected, q)
+	}
+}
+
+func TestParseFluxDuration(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected time.Duration
+		err      bool
+	}{
+		{"1m", time.Minute, false},
+		{"1h30m", 90 * time.Minute, false},
+		{"500ms", 500 * time.Millisecond, false},
+		{"2d", 48 * time.Hour, false},
+		{"", 0, true},
+		{"m", 0, true},
+		{"1mo", 0, true},
+		{"1", 0, true},
+	}
+
+	for _, test := range tests {
+		d, err := parseFluxDuration(test.input)
+		if (err != nil) != test.err {
+			t.Errorf("input %s: expected error %t got %v", test.input, test.err, err)
+		}
+		if d != test.expected {
+			t.Errorf("input %s: expected %s got %s", test.input, test.expected, d)
+		}
+	}
+}
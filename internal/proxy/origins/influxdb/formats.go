@@ -0,0 +1,241 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package influxdb
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Comcast/trickster/internal/proxy/headers"
+	str "github.com/Comcast/trickster/internal/util/strings"
+)
+
+// cacheEpoch is the precision of the epoch timestamps that InfluxQL query results are
+// requested from InfluxDB and cached in, regardless of the format requested by the client
+const cacheEpoch = "ms"
+
+// epochUnits maps the values of the epoch parameter to the duration of one unit of
+// the epoch timestamps. As in InfluxDB, any other value is treated as nanoseconds.
+var epochUnits = map[string]time.Duration{
+	"h":  time.Hour,
+	"m":  time.Minute,
+	"s":  time.Second,
+	"ms": time.Millisecond,
+	"u":  time.Microsecond,
+}
+
+const (
+	mediaTypeApplicationCSV = "application/csv"
+	mediaTypeMsgpack        = "application/x-msgpack"
+)
+
+// outputFormat describes the format in which the client requested InfluxQL query results
+type outputFormat struct {
+	// epoch is the value of the epoch parameter. When empty, JSON timestamps are RFC3339 strings.
+	epoch string
+	csv   bool
+}
+
+// requestedFormat returns the outputFormat of the InfluxQL query request
+func requestedFormat(r *http.Request) outputFormat {
+	mt := acceptedMediaType(r)
+	return outputFormat{
+		epoch: r.URL.Query().Get(upEpoch),
+		csv:   mt == mediaTypeApplicationCSV || mt == headers.ValueTextCSV,
+	}
+}
+
+// acceptedMediaType returns the first media type of the request's Accept header, without parameters
+func acceptedMediaType(r *http.Request) string {
+	mt := strings.SplitN(r.Header.Get(headers.NameAccept), ",", 2)[0]
+	mt = strings.SplitN(mt, ";", 2)[0]
+	return strings.ToLower(strings.TrimSpace(mt))
+}
+
+// isCached returns true if the outputFormat is the one that query results are cached in
+func (f outputFormat) isCached() bool {
+	return !f.csv && f.epoch == cacheEpoch
+}
+
+// unit returns the duration of one unit of the outputFormat's epoch timestamps
+func (f outputFormat) unit() time.Duration {
+	if u, ok := epochUnits[f.epoch]; ok {
+		return u
+	}
+	return time.Nanosecond
+}
+
+// timestamp returns the outputFormat's representation of the cached millisecond epoch
+func (f outputFormat) timestamp(ms float64) interface{} {
+	t := time.Unix(0, int64(ms)*int64(time.Millisecond)).UTC()
+	if f.epoch == "" && !f.csv {
+		return t.Format(time.RFC3339Nano)
+	}
+	return t.UnixNano() / int64(f.unit())
+}
+
+// setCacheFormat changes the request to retrieve query results in the format they are cached in
+func setCacheFormat(r *http.Request) {
+	p := r.URL.Query()
+	p.Set(upEpoch, cacheEpoch)
+	r.URL.RawQuery = p.Encode()
+	r.Header.Del(headers.NameAccept)
+}
+
+// formatWriter buffers a query response in the cached format, so that it can
+// be converted to the client's requested format before it is written
+type formatWriter struct {
+	w      http.ResponseWriter
+	format outputFormat
+	status int
+	buf    bytes.Buffer
+}
+
+// Header returns the header map of the underlying ResponseWriter
+func (fw *formatWriter) Header() http.Header {
+	return fw.w.Header()
+}
+
+// WriteHeader records the status code to write when the response is flushed
+func (fw *formatWriter) WriteHeader(code int) {
+	fw.status = code
+}
+
+// Write buffers the response body until it is flushed
+func (fw *formatWriter) Write(b []byte) (int, error) {
+	return fw.buf.Write(b)
+}
+
+// flush writes the buffered response to the client in the requested format. Responses
+// that are not successful InfluxQL query results are written unmodified.
+func (fw *formatWriter) flush() {
+
+	if fw.status == 0 {
+		fw.status = http.StatusOK
+	}
+	b := fw.buf.Bytes()
+	h := fw.w.Header()
+
+	se := &SeriesEnvelope{}
+	if fw.status == http.StatusOK && json.Unmarshal(b, se) == nil && len(se.Results) > 0 {
+		ct := headers.ValueApplicationJSON
+		var err error
+		var b2 []byte
+		if fw.format.csv {
+			ct = headers.ValueTextCSV
+			b2, err = se.marshalCSV(fw.format)
+		} else {
+			se.convertTimes(fw.format)
+			b2, err = json.Marshal(se)
+		}
+		if err == nil {
+			b = b2
+			h.Set(headers.NameContentType, ct)
+		}
+	}
+
+	h.Set(headers.NameContentLength, strconv.Itoa(len(b)))
+	fw.w.WriteHeader(fw.status)
+	fw.w.Write(b)
+}
+
+// convertTimes converts the cached timestamps of each series' time column to the outputFormat
+func (se *SeriesEnvelope) convertTimes(f outputFormat) {
+	for i := range se.Results {
+		for j := range se.Results[i].Series {
+			s := &se.Results[i].Series[j]
+			ti := str.IndexOfString(s.Columns, "time")
+			if ti < 0 {
+				continue
+			}
+			for _, v := range s.Values {
+				if ti >= len(v) {
+					continue
+				}
+				if ms, ok := v[ti].(float64); ok {
+					v[ti] = f.timestamp(ms)
+				}
+			}
+		}
+	}
+}
+
+// marshalCSV returns the query results in the CSV format of the InfluxDB HTTP API.
+// A header row is written whenever the columns change, preceded by an empty line
+// when it is not the first.
+func (se *SeriesEnvelope) marshalCSV(f outputFormat) ([]byte, error) {
+
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+
+	var columns []string
+	for _, r := range se.Results {
+		for _, s := range r.Series {
+			if columns == nil || strings.Join(columns[2:], ",") != strings.Join(s.Columns, ",") {
+				if columns != nil {
+					w.Write([]string{})
+				}
+				columns = append([]string{"name", "tags"}, s.Columns...)
+				w.Write(columns)
+			}
+
+			tags := make([]string, 0, len(s.Tags))
+			for k, v := range s.Tags {
+				tags = append(tags, k+"="+v)
+			}
+			sort.Strings(tags)
+
+			ti := str.IndexOfString(s.Columns, "time")
+			for _, v := range s.Values {
+				record := make([]string, 2, len(v)+2)
+				record[0] = s.Name
+				record[1] = strings.Join(tags, ",")
+				for i, fv := range v {
+					if ms, ok := fv.(float64); ok && i == ti {
+						fv = f.timestamp(ms)
+					}
+					record = append(record, csvValue(fv))
+				}
+				w.Write(record)
+			}
+		}
+	}
+
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// csvValue returns the CSV representation of a field value
+func csvValue(v interface{}) string {
+	switch tv := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return tv
+	case float64:
+		return strconv.FormatFloat(tv, 'f', -1, 64)
+	case int64:
+		return strconv.FormatInt(tv, 10)
+	case bool:
+		return strconv.FormatBool(tv)
+	}
+	return fmt.Sprint(v)
+}
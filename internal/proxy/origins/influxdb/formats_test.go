@@ -0,0 +1,176 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package influxdb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Comcast/trickster/internal/proxy/headers"
+
+	"github.com/influxdata/influxdb/models"
+)
+
+func TestRequestedFormat(t *testing.T) {
+
+	tests := []struct {
+		url, accept string
+		expected    outputFormat
+		cached      bool
+	}{
+		{"http://0/query?epoch=ms", "", outputFormat{epoch: "ms"}, true},
+		{"http://0/query?epoch=ms", "application/json", outputFormat{epoch: "ms"}, true},
+		{"http://0/query", "", outputFormat{}, false},
+		{"http://0/query?epoch=s", "", outputFormat{epoch: "s"}, false},
+		{"http://0/query?epoch=ms", "application/csv", outputFormat{epoch: "ms", csv: true}, false},
+		{"http://0/query", "text/csv; charset=utf-8, */*", outputFormat{csv: true}, false},
+	}
+
+	for i, test := range tests {
+		r, _ := http.NewRequest(http.MethodGet, test.url, nil)
+		r.Header.Set(headers.NameAccept, test.accept)
+		f := requestedFormat(r)
+		if f != test.expected {
+			t.Errorf("test %d expected %v got %v", i, test.expected, f)
+		}
+		if f.isCached() != test.cached {
+			t.Errorf("test %d expected %t got %t", i, test.cached, f.isCached())
+		}
+	}
+
+}
+
+func TestOutputFormatTimestamp(t *testing.T) {
+
+	const ms = float64(1577836800500)
+
+	tests := []struct {
+		f        outputFormat
+		expected interface{}
+	}{
+		{outputFormat{}, "2020-01-01T00:00:00.5Z"},
+		{outputFormat{csv: true}, int64(1577836800500000000)},
+		{outputFormat{epoch: "ns"}, int64(1577836800500000000)},
+		{outputFormat{epoch: "u"}, int64(1577836800500000)},
+		{outputFormat{epoch: "ms"}, int64(1577836800500)},
+		{outputFormat{epoch: "s"}, int64(1577836800)},
+		{outputFormat{epoch: "m"}, int64(26297280)},
+		{outputFormat{epoch: "h"}, int64(438288)},
+	}
+
+	for i, test := range tests {
+		if v := test.f.timestamp(ms); v != test.expected {
+			t.Errorf("test %d expected %v got %v", i, test.expected, v)
+		}
+	}
+
+}
+
+func TestSetCacheFormat(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodGet, "http://0/query?epoch=s&q=select", nil)
+	r.Header.Set(headers.NameAccept, "application/csv")
+	setCacheFormat(r)
+	if v := r.URL.Query().Get(upEpoch); v != cacheEpoch {
+		t.Errorf("expected %s got %s", cacheEpoch, v)
+	}
+	if v := r.Header.Get(headers.NameAccept); v != "" {
+		t.Errorf("expected empty Accept header got %s", v)
+	}
+}
+
+func testFormatEnvelope() *SeriesEnvelope {
+	return &SeriesEnvelope{
+		Results: []Result{
+			{StatementID: 0, Series: []models.Row{
+				{Name: "cpu", Tags: map[string]string{"region": "west", "host": "a"}, Columns: []string{"time", "mean"},
+					Values: [][]interface{}{{float64(60000), 1.5}, {float64(120000), nil}}},
+			}},
+			{StatementID: 1, Series: []models.Row{
+				{Name: "mem", Columns: []string{"time", "max", "unit"},
+					Values: [][]interface{}{{float64(60000), float64(10), "a,b"}}},
+			}},
+		},
+	}
+}
+
+func TestMarshalCSV(t *testing.T) {
+
+	b, err := testFormatEnvelope().marshalCSV(outputFormat{epoch: "s", csv: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const expected = "name,tags,time,mean\n" +
+		"cpu,\"host=a,region=west\",60,1.5\n" +
+		"cpu,\"host=a,region=west\",120,\n" +
+		"\n" +
+		"name,tags,time,max,unit\n" +
+		"mem,,60,10,\"a,b\"\n"
+	if string(b) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, string(b))
+	}
+
+}
+
+func TestFormatWriter(t *testing.T) {
+
+	tests := []struct {
+		format      outputFormat
+		status      int
+		body        string
+		expected    string
+		contentType string
+	}{
+		{
+			outputFormat{epoch: "s"}, http.StatusOK,
+			`{"results":[{"statement_id":0,"series":[{"name":"cpu","columns":["time","v"],"values":[[60000,1]]}]}]}`,
+			`{"results":[{"statement_id":0,"series":[{"name":"cpu","columns":["time","v"],"values":[[60,1]]}]}]}`,
+			headers.ValueApplicationJSON,
+		},
+		{
+			outputFormat{}, http.StatusOK,
+			`{"results":[{"statement_id":0,"series":[{"name":"cpu","columns":["time","v"],"values":[[60000,1]]}]}]}`,
+			`{"results":[{"statement_id":0,"series":[{"name":"cpu","columns":["time","v"],"values":[["1970-01-01T00:01:00Z",1]]}]}]}`,
+			headers.ValueApplicationJSON,
+		},
+		{
+			outputFormat{csv: true}, http.StatusOK,
+			`{"results":[{"statement_id":0,"series":[{"name":"cpu","columns":["time","v"],"values":[[60000,1]]}]}]}`,
+			"name,tags,time,v\ncpu,,60000000000,1\n",
+			headers.ValueTextCSV,
+		},
+		// unsuccessful or unparseable responses are written unmodified
+		{outputFormat{csv: true}, http.StatusBadRequest, `{"error":"bad query"}`, `{"error":"bad query"}`, ""},
+		{outputFormat{epoch: "s"}, http.StatusOK, `{}`, `{}`, ""},
+	}
+
+	for i, test := range tests {
+		w := httptest.NewRecorder()
+		fw := &formatWriter{w: w, format: test.format}
+		fw.WriteHeader(test.status)
+		fw.Write([]byte(test.body))
+		fw.flush()
+		resp := w.Result()
+		if resp.StatusCode != test.status {
+			t.Errorf("test %d expected %d got %d", i, test.status, resp.StatusCode)
+		}
+		if w.Body.String() != test.expected {
+			t.Errorf("test %d expected %s got %s", i, test.expected, w.Body.String())
+		}
+		if ct := resp.Header.Get(headers.NameContentType); ct != test.contentType {
+			t.Errorf("test %d expected %s got %s", i, test.contentType, ct)
+		}
+	}
+}
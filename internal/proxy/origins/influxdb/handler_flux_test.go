@@ -0,0 +1,82 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package influxdb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Comcast/trickster/internal/proxy/request"
+	tu "github.com/Comcast/trickster/internal/util/testing"
+)
+
+const testFluxBody = `{"query":"from(bucket: \"b\") |> range(start: -1h) |> aggregateWindow(every: 1m, fn: mean)","dialect":{"annotations":["group","datatype","default"]}}`
+
+func TestFluxHandler(t *testing.T) {
+
+	client := &Client{name: "test"}
+	ts, w, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, testFluxCSV, nil, "influxdb", "/api/v2/query?org=test", "debug")
+	ctx := r.Context()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	defer ts.Close()
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, ok := client.config.Paths["/api/v2/query"]
+	if !ok {
+		t.Errorf("could not find path config named %s", "/api/v2/query")
+	}
+
+	// non-POST requests are proxied
+	client.FluxHandler(w, r)
+	resp := w.Result()
+	if resp.StatusCode != 200 {
+		t.Errorf("expected 200 got %d.", resp.StatusCode)
+	}
+	if h := resp.Header.Get("X-Trickster-Result"); !strings.Contains(h, "engine=HTTPProxy") {
+		t.Errorf("expected proxy engine got %s", h)
+	}
+
+	newRequest := func(token string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, ts.URL+"/api/v2/query?org=test", strings.NewReader(testFluxBody))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("Authorization", "Token "+token)
+		return r.WithContext(ctx)
+	}
+
+	w = httptest.NewRecorder()
+	client.FluxHandler(w, newRequest("a"))
+	resp = w.Result()
+	if resp.StatusCode != 200 {
+		t.Errorf("expected 200 got %d.", resp.StatusCode)
+	}
+	if h := resp.Header.Get("X-Trickster-Result"); !strings.Contains(h, "engine=DeltaProxyCache") {
+		t.Errorf("expected delta proxy cache engine got %s", h)
+	}
+
+	// the same query with another token is cached separately
+	w = httptest.NewRecorder()
+	client.FluxHandler(w, newRequest("b"))
+	resp = w.Result()
+	if h := resp.Header.Get("X-Trickster-Result"); !strings.Contains(h, "status=kmiss") {
+		t.Errorf("expected key miss got %s", h)
+	}
+}
@@ -16,6 +16,7 @@ package influxdb
 import (
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/Comcast/trickster/internal/proxy/engines"
 	"github.com/Comcast/trickster/internal/proxy/errors"
@@ -35,13 +36,34 @@ func (c *Client) QueryHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// results in formats that Trickster can't reproduce, like msgpack, are not cached
+	if acceptedMediaType(r) == mediaTypeMsgpack {
+		c.ProxyHandler(w, r)
+		return
+	}
+
 	r.URL = c.BuildUpstreamURL(r)
-	engines.DeltaProxyCacheRequest(w, r)
+
+	// results are cached with millisecond epoch timestamps, and converted to the
+	// requested epoch precision or CSV format when they are written to the client
+	f := requestedFormat(r)
+	if f.isCached() {
+		engines.DeltaProxyCacheRequest(w, r)
+		return
+	}
+	setCacheFormat(r)
+	fw := &formatWriter{w: w, format: f}
+	engines.DeltaProxyCacheRequest(fw, r)
+	fw.flush()
 }
 
 // ParseTimeRangeQuery parses the key parts of a TimeRangeQuery from the inbound HTTP Request
 func (c *Client) ParseTimeRangeQuery(r *http.Request) (*timeseries.TimeRangeQuery, error) {
 
+	if isFluxRequest(r) {
+		return parseFluxQuery(r)
+	}
+
 	trq := &timeseries.TimeRangeQuery{Extent: timeseries.Extent{}}
 	trq.TemplateURL = urls.Clone(r.URL)
 
@@ -52,19 +74,48 @@ func (c *Client) ParseTimeRangeQuery(r *http.Request) (*timeseries.TimeRangeQuer
 		return nil, errors.MissingURLParam(upQuery)
 	}
 
-	// if the Step wasn't found in the query (e.g., "group by time(1m)"), just proxy it instead
-	step, found := matching.GetNamedMatch("step", reStep, trq.Statement)
-	if !found {
+	// semicolon-separated statements are accelerated together when they share a step
+	// and time range, as they do when a dashboard panel has several queries. The
+	// results of each statement are merged into the Result having its statement_id.
+	statements := splitStatements(trq.Statement)
+	if len(statements) == 0 {
 		return nil, errors.ErrStepParse
 	}
+	for i, stmt := range statements {
 
-	stepDuration, err := timeconv.ParseDuration(step)
-	if err != nil {
-		return nil, errors.ErrStepParse
-	}
-	trq.Step = stepDuration
+		// statements without a step (e.g., "group by time(1m)") are cached as raw values when
+		// they select them, and are otherwise just proxied instead
+		var stepDuration time.Duration
+		if step, found := matching.GetNamedMatch("step", reStep, stmt); found {
+			var err error
+			if stepDuration, err = timeconv.ParseDuration(step); err != nil {
+				return nil, errors.ErrStepParse
+			}
+		} else if !isRawQuery(stmt) {
+			return nil, errors.ErrStepParse
+		}
+
+		var extent timeseries.Extent
+		statements[i], extent = getQueryParts(stmt)
 
-	trq.Statement, trq.Extent = getQueryParts(trq.Statement)
+		// raw values are only cached for statements with a time range
+		if stepDuration == 0 && !strings.Contains(statements[i], tkTime) {
+			return nil, errors.ErrNotTimeRangeQuery
+		}
+
+		if i == 0 {
+			trq.Step = stepDuration
+			trq.Extent = extent
+			continue
+		}
+
+		if stepDuration != trq.Step ||
+			!extent.Start.Truncate(trq.Step).Equal(trq.Extent.Start.Truncate(trq.Step)) ||
+			!extent.End.Truncate(trq.Step).Equal(trq.Extent.End.Truncate(trq.Step)) {
+			return nil, errors.ErrNotTimeRangeQuery
+		}
+	}
+	trq.Statement = strings.Join(statements, "; ")
 
 	// Swap in the Tokenzed Query in the Url Params
 	qi.Set(upQuery, trq.Statement)
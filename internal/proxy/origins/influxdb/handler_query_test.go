@@ -14,12 +14,17 @@
 package influxdb
 
 import (
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Comcast/trickster/internal/proxy/errors"
+	"github.com/Comcast/trickster/internal/proxy/headers"
 	"github.com/Comcast/trickster/internal/proxy/request"
 	"github.com/Comcast/trickster/internal/util/metrics"
 	tu "github.com/Comcast/trickster/internal/util/testing"
@@ -48,6 +53,70 @@ func TestParseTimeRangeQuery(t *testing.T) {
 	}
 }
 
+func TestParseTimeRangeQueryRaw(t *testing.T) {
+
+	tests := []struct {
+		query string
+		err   error
+	}{
+		{`SELECT "value", "host" FROM "cpu" WHERE "host" = 'a' AND time >= now() - 1h`, nil},
+		{`SELECT * FROM "cpu" WHERE time >= 1000s AND time <= 2000s GROUP BY "host"`, nil},
+		{`SELECT mean("value") FROM "cpu" WHERE time >= now() - 1h`, errors.ErrStepParse},
+		{`SELECT "value" FROM "cpu" WHERE time >= now() - 1h LIMIT 10`, errors.ErrStepParse},
+		{`SELECT "value" FROM "cpu" WHERE time >= now() - 1h ORDER BY time DESC`, errors.ErrStepParse},
+		{`SELECT "value" INTO "cpu2" FROM "cpu" WHERE time >= now() - 1h`, errors.ErrStepParse},
+		{`SELECT "value" FROM "cpu"`, errors.ErrNotTimeRangeQuery},
+		// statements with and without a step can't be accelerated together
+		{`SELECT "value" FROM "cpu" WHERE time >= now() - 1h; SELECT mean("value") FROM "cpu" WHERE time >= now() - 1h GROUP BY time(1m)`,
+			errors.ErrNotTimeRangeQuery},
+	}
+
+	client := &Client{}
+	for i, test := range tests {
+		req := &http.Request{URL: &url.URL{Path: "/query", RawQuery: url.Values{"q": {test.query}}.Encode()}}
+		trq, err := client.ParseTimeRangeQuery(req)
+		if err != test.err {
+			t.Errorf("test %d: expected %v got %v", i, test.err, err)
+			continue
+		}
+		if err == nil && (trq.Step != 0 || !strings.Contains(trq.Statement, tkTime)) {
+			t.Errorf("test %d: unexpected time range query %s", i, trq)
+		}
+	}
+
+}
+
+func TestParseTimeRangeQueryMultiStatement(t *testing.T) {
+
+	const q1 = `SELECT mean("value") FROM "cpu" WHERE time >= now() - 6h GROUP BY time(15s)`
+	const q2 = `SELECT max("value") FROM "mem" WHERE time >= now() - 6h GROUP BY time(15s), "host"`
+
+	client := &Client{}
+	req := &http.Request{URL: &url.URL{Path: "/query", RawQuery: url.Values{"q": {q1 + ";" + q2 + ";"}}.Encode()}}
+	res, err := client.ParseTimeRangeQuery(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expected = `SELECT mean("value") FROM "cpu" WHERE <$TIME_TOKEN$> GROUP BY time(15s); ` +
+		`SELECT max("value") FROM "mem" WHERE <$TIME_TOKEN$> GROUP BY time(15s), "host"`
+	if res.Statement != expected || res.TemplateURL.Query().Get(upQuery) != expected {
+		t.Errorf("expected %s got %s", expected, res.Statement)
+	}
+	assert.Equal(t, int(res.Step.Seconds()), 15)
+	assert.Equal(t, int(res.Extent.End.Sub(res.Extent.Start).Hours()), 6)
+
+	// statements with different steps or time ranges are not accelerated together
+	for _, q2 := range []string{
+		`SELECT max("value") FROM "mem" WHERE time >= now() - 6h GROUP BY time(1m)`,
+		`SELECT max("value") FROM "mem" WHERE time >= now() - 1h GROUP BY time(15s)`,
+	} {
+		req.URL.RawQuery = url.Values{"q": {q1 + ";" + q2}}.Encode()
+		if _, err = client.ParseTimeRangeQuery(req); err != errors.ErrNotTimeRangeQuery {
+			t.Errorf("expected %v got %v", errors.ErrNotTimeRangeQuery, err)
+		}
+	}
+}
+
 func TestQueryHandlerWithSelect(t *testing.T) {
 
 	client := &Client{name: "test"}
@@ -81,6 +150,64 @@ func TestQueryHandlerWithSelect(t *testing.T) {
 	}
 }
 
+func TestQueryHandlerFormats(t *testing.T) {
+
+	t1 := time.Now().Truncate(time.Minute).Add(-2 * time.Minute)
+	body := fmt.Sprintf(`{"results":[`+
+		`{"statement_id":0,"series":[{"name":"cpu","columns":["time","mean"],"values":[[%d,1]]}]},`+
+		`{"statement_id":1,"series":[{"name":"mem","columns":["time","max"],"values":[[%d,2]]}]}]}`,
+		t1.Unix()*1000, t1.Unix()*1000)
+	q := `SELECT mean("value") FROM "cpu" WHERE time >= now() - 5m GROUP BY time(1m); ` +
+		`SELECT max("value") FROM "mem" WHERE time >= now() - 5m GROUP BY time(1m)`
+
+	client := &Client{name: "test"}
+	ts, _, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, body, nil, "influxdb", "/query", "debug")
+	ctx := r.Context()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	defer ts.Close()
+	if err != nil {
+		t.Error(err)
+	}
+
+	tests := []struct {
+		epoch, accept, status, expected string
+	}{
+		{"s", "", "status=kmiss", fmt.Sprintf(`{"results":[`+
+			`{"statement_id":0,"series":[{"name":"cpu","columns":["time","mean"],"values":[[%d,1]]}]},`+
+			`{"statement_id":1,"series":[{"name":"mem","columns":["time","max"],"values":[[%d,2]]}]}]}`,
+			t1.Unix(), t1.Unix())},
+		// the cached millisecond results are reused for other formats
+		{"", "application/csv", "status=hit", fmt.Sprintf("name,tags,time,mean\ncpu,,%d,1\n\nname,tags,time,max\nmem,,%d,2\n",
+			t1.UnixNano(), t1.UnixNano())},
+	}
+
+	for i, test := range tests {
+		v := url.Values{"q": {q}}
+		if test.epoch != "" {
+			v.Set(upEpoch, test.epoch)
+		}
+		r, _ = http.NewRequest(http.MethodGet, ts.URL+"/query?"+v.Encode(), nil)
+		r.Header.Set(headers.NameAccept, test.accept)
+		w := httptest.NewRecorder()
+		client.QueryHandler(w, r.WithContext(ctx))
+		resp := w.Result()
+		if resp.StatusCode != 200 {
+			t.Errorf("test %d expected 200 got %d.", i, resp.StatusCode)
+		}
+		if h := resp.Header.Get(headers.NameTricksterResult); !strings.Contains(h, "engine=DeltaProxyCache") || !strings.Contains(h, test.status) {
+			t.Errorf("test %d expected %s got %s", i, test.status, h)
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		if string(b) != test.expected {
+			t.Errorf("test %d expected %s got %s", i, test.expected, string(b))
+		}
+	}
+}
+
 func TestQueryHandlerNotSelect(t *testing.T) {
 
 	client := &Client{name: "test"}
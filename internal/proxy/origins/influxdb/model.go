@@ -44,12 +44,18 @@ type Result struct {
 
 // MarshalTimeseries converts a Timeseries into a JSON blob
 func (c Client) MarshalTimeseries(ts timeseries.Timeseries) ([]byte, error) {
+	if fr, ok := ts.(*FluxResult); ok {
+		return fr.marshal()
+	}
 	// Marshal the Envelope back to a json object for Cache Storage
 	return json.Marshal(ts)
 }
 
 // UnmarshalTimeseries converts a JSON blob into a Timeseries
 func (c Client) UnmarshalTimeseries(data []byte) (timeseries.Timeseries, error) {
+	if isFluxResponse(data) {
+		return unmarshalFluxResult(data)
+	}
 	se := &SeriesEnvelope{}
 	err := json.Unmarshal(data, se)
 	return se, err
@@ -26,6 +26,7 @@ func (c *Client) registerHandlers() {
 	// and are able to be referenced by name (map key) in Config Files
 	c.handlers["health"] = http.HandlerFunc(c.HealthHandler)
 	c.handlers["query"] = http.HandlerFunc(c.QueryHandler)
+	c.handlers["flux"] = http.HandlerFunc(c.FluxHandler)
 	c.handlers["proxy"] = http.HandlerFunc(c.ProxyHandler)
 }
 
@@ -64,6 +65,15 @@ func (c *Client) DefaultPathConfigs(oc *config.OriginConfig) map[string]*config.
 			MatchTypeName:   "exact",
 			MatchType:       config.PathMatchTypeExact,
 		},
+		"/" + mnQueryV2: {
+			Path:            "/" + mnQueryV2,
+			HandlerName:     "flux",
+			Methods:         []string{http.MethodPost},
+			CacheKeyParams:  []string{"org", "orgID", upFluxQuery, upFluxRequest},
+			CacheKeyHeaders: []string{},
+			MatchTypeName:   "exact",
+			MatchType:       config.PathMatchTypeExact,
+		},
 		"/": {
 			Path:          "/",
 			HandlerName:   "proxy",
@@ -52,7 +52,11 @@ func TestDefaultPathConfigs(t *testing.T) {
 		t.Errorf("expected to find path named: %s", "/")
 	}
 
-	const expectedLen = 2
+	if _, ok := client.config.Paths["/"+mnQueryV2]; !ok {
+		t.Errorf("expected to find path named: %s", "/"+mnQueryV2)
+	}
+
+	const expectedLen = 3
 	if len(client.config.Paths) != expectedLen {
 		t.Errorf("expected ordered length to be: %d", expectedLen)
 	}
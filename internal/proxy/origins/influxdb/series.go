@@ -72,7 +72,7 @@ func (se *SeriesEnvelope) updateTimestamps() {
 				continue
 			}
 			for k := range se.Results[i].Series[j].Values {
-				m[time.Unix(int64(se.Results[i].Series[j].Values[k][ti].(float64)/1000), 0)] = true
+				m[msTime(se.Results[i].Series[j].Values[k][ti].(float64))] = true
 			}
 		}
 	}
@@ -122,16 +122,18 @@ func (se *SeriesEnvelope) Merge(sort bool, collection ...timeseries.Timeseries)
 	mtx := sync.Mutex{}
 	wg := sync.WaitGroup{}
 
-	series := make(map[seriesKey]*models.Row)
+	// series maps each series to its index in the Series of its Result, since
+	// appending to the Series may move them
+	series := make(map[seriesKey]int)
 	for i, r := range se.Results {
 		for j := range se.Results[i].Series {
 			wg.Add(1)
-			go func(s *models.Row) {
+			go func(s *models.Row, resultID, statementID, seriesID int) {
 				mtx.Lock()
-				series[seriesKey{ResultID: i, StatementID: r.StatementID, Name: s.Name, Tags: tags(s.Tags).String(), Columns: strings.Join(s.Columns, ",")}] = s
+				series[seriesKey{ResultID: resultID, StatementID: statementID, Name: s.Name, Tags: tags(s.Tags).String(), Columns: strings.Join(s.Columns, ",")}] = seriesID
 				mtx.Unlock()
 				wg.Done()
-			}(&se.Results[i].Series[j])
+			}(&se.Results[i].Series[j], i, r.StatementID, j)
 		}
 	}
 	wg.Wait()
@@ -141,29 +143,31 @@ func (se *SeriesEnvelope) Merge(sort bool, collection ...timeseries.Timeseries)
 			se2 := ts.(*SeriesEnvelope)
 			for g, r := range se2.Results {
 
+				// the Results of multi-statement queries are merged by their position,
+				// and any not yet in the base Timeseries are added with their series in order
 				if g >= len(se.Results) {
-					mtx.Lock()
-					se.Results = append(se.Results, se2.Results[g:]...)
-					mtx.Unlock()
-					break
+					se.Results = append(se.Results, Result{StatementID: r.StatementID, Err: r.Err,
+						Series: append([]models.Row{}, r.Series...)})
+					for j, s := range r.Series {
+						series[seriesKey{ResultID: g, StatementID: r.StatementID, Name: s.Name, Tags: tags(s.Tags).String(), Columns: strings.Join(s.Columns, ",")}] = j
+					}
+					continue
 				}
 
 				for i := range r.Series {
 					wg.Add(1)
-					go func(s *models.Row, resultID int) {
+					go func(s *models.Row, resultID, statementID int) {
 						mtx.Lock()
-						sk := seriesKey{ResultID: g, StatementID: r.StatementID, Name: s.Name, Tags: tags(s.Tags).String(), Columns: strings.Join(s.Columns, ",")}
-						if _, ok := series[sk]; !ok {
-							series[sk] = s
+						sk := seriesKey{ResultID: resultID, StatementID: statementID, Name: s.Name, Tags: tags(s.Tags).String(), Columns: strings.Join(s.Columns, ",")}
+						if j, ok := series[sk]; ok {
+							se.Results[resultID].Series[j].Values = append(se.Results[resultID].Series[j].Values, s.Values...)
+						} else {
+							series[sk] = len(se.Results[resultID].Series)
 							se.Results[resultID].Series = append(se.Results[resultID].Series, *s)
-							mtx.Unlock()
-							wg.Done()
-							return
 						}
-						series[sk].Values = append(series[sk].Values, s.Values...)
 						mtx.Unlock()
 						wg.Done()
-					}(&r.Series[i], g)
+					}(&r.Series[i], g, r.StatementID)
 				}
 			}
 			wg.Wait()
@@ -257,30 +261,37 @@ func (se *SeriesEnvelope) CropToSize(sz int, t time.Time, lur timeseries.Extent)
 	done := false
 	var ok bool
 
-	for _, x := range el {
-		for ts := x.Start; !x.End.Before(ts) && !done; ts = ts.Add(se.StepDuration) {
-			if _, ok = se.timestamps[ts]; ok {
-				removals[ts] = true
-				done = len(removals) >= rc
+	if se.StepDuration <= 0 {
+		// raw samples don't fall on step boundaries, so the oldest timestamps are removed
+		// from the extents directly
+		var el2 timeseries.ExtentList
+		removals, el2 = el.RemoveOldest(se.tslist, rc)
+		el = timeseries.ExtentListLRU(el2)
+	} else {
+		for _, x := range el {
+			for ts := x.Start; !x.End.Before(ts) && !done; ts = ts.Add(se.StepDuration) {
+				if _, ok = se.timestamps[ts]; ok {
+					removals[ts] = true
+					done = len(removals) >= rc
+				}
+			}
+			if done {
+				break
 			}
-		}
-		if done {
-			break
 		}
 	}
 
 	ti := str.IndexOfString(se.Results[0].Series[0].Columns, "time")
 
 	for i, r := range se.Results {
-		for j, s := range r.Series {
+		for j := range r.Series {
 			tmp := se.Results[i].Series[j].Values[:0]
 			for _, v := range se.Results[i].Series[j].Values {
-				t = time.Unix(int64(v[ti].(float64)/1000), 0)
-				if _, ok := removals[t]; !ok {
+				if _, ok := removals[msTime(v[ti].(float64))]; !ok {
 					tmp = append(tmp, v)
 				}
 			}
-			s.Values = tmp
+			se.Results[i].Series[j].Values = tmp
 		}
 	}
 
@@ -288,7 +299,7 @@ func (se *SeriesEnvelope) CropToSize(sz int, t time.Time, lur timeseries.Extent)
 	sort.Sort(tl)
 	for _, t := range tl {
 		for i, e := range el {
-			if e.StartsAt(t) {
+			if se.StepDuration > 0 && e.StartsAt(t) {
 				el[i].Start = e.Start.Add(se.StepDuration)
 			}
 		}
@@ -332,8 +343,9 @@ func (se *SeriesEnvelope) CropToRange(e timeseries.Extent) {
 		return
 	}
 
-	startSecs := e.Start.Unix()
-	endSecs := e.End.Unix()
+	// values are compared at the millisecond precision of the cached timestamps
+	startMs := (e.Start.UnixNano() + int64(time.Millisecond) - 1) / int64(time.Millisecond)
+	endMs := e.End.UnixNano() / int64(time.Millisecond)
 
 	for i, r := range se.Results {
 
@@ -351,22 +363,22 @@ func (se *SeriesEnvelope) CropToRange(e timeseries.Extent) {
 				start := -1
 				end := -1
 				for vi, v := range se.Results[i].Series[j].Values {
-					t := int64(v[ti].(float64) / 1000)
-					if t == endSecs {
-						if vi == 0 || t == startSecs || start == -1 {
+					t := int64(v[ti].(float64))
+					if t == endMs {
+						if vi == 0 || t == startMs || start == -1 {
 							start = vi
 						}
 						end = vi + 1
 						break
 					}
-					if t > endSecs {
+					if t > endMs {
 						end = vi
 						break
 					}
-					if t < startSecs {
+					if t < startMs {
 						continue
 					}
-					if start == -1 && (t == startSecs || (endSecs > t && t > startSecs)) {
+					if start == -1 && (t == startMs || (endMs > t && t > startMs)) {
 						start = vi
 					}
 				}
@@ -404,11 +416,12 @@ func (se *SeriesEnvelope) Sort() {
 	}
 
 	tsm := map[time.Time]bool{}
-	m := make(map[int64][]interface{})
 	if ti := str.IndexOfString(se.Results[0].Series[0].Columns, "time"); ti != -1 {
 		for ri := range se.Results {
 			for si := range se.Results[ri].Series {
-				keys := make([]int64, 0, len(m))
+				// values are deduplicated by timestamp within each series
+				m := make(map[int64][]interface{})
+				keys := make([]int64, 0, len(se.Results[ri].Series[si].Values))
 				for _, v := range se.Results[ri].Series[si].Values {
 					wg.Add(1)
 					go func(s []interface{}) {
@@ -418,7 +431,7 @@ func (se *SeriesEnvelope) Sort() {
 							keys = append(keys, t)
 							m[t] = s
 						}
-						tsm[time.Unix(t/1000, 0)] = true
+						tsm[msTime(s[ti].(float64))] = true
 						mtx.Unlock()
 						wg.Done()
 					}(v)
@@ -442,6 +455,11 @@ func (se *SeriesEnvelope) Sort() {
 	se.isSorted = true
 }
 
+// msTime returns the time of a cached millisecond epoch timestamp
+func msTime(ms float64) time.Time {
+	return time.Unix(0, int64(ms)*int64(time.Millisecond))
+}
+
 // Size returns the approximate memory utilization in bytes of the timeseries
 func (se *SeriesEnvelope) Size() int {
 	c := 8 + len(se.Err)
@@ -500,6 +500,54 @@ func TestMerge(t *testing.T) {
 	}
 }
 
+func TestMergeMultiStatement(t *testing.T) {
+
+	row := func(name string, ts ...float64) models.Row {
+		r := models.Row{Name: name, Columns: []string{"time", "units"}, Tags: map[string]string{"t": "v"}}
+		for _, v := range ts {
+			r.Values = append(r.Values, []interface{}{v, 1.5})
+		}
+		return r
+	}
+
+	// the second statement has no cached results, so its series are added
+	// from the first delta and then extended by the second
+	se := &SeriesEnvelope{
+		Results:      []Result{{StatementID: 0, Series: []models.Row{row("a", 10000), row("b", 10000)}}},
+		ExtentList:   timeseries.ExtentList{{Start: time.Unix(10, 0), End: time.Unix(10, 0)}},
+		StepDuration: testStep,
+	}
+	d1 := &SeriesEnvelope{
+		Results: []Result{
+			{StatementID: 0, Series: []models.Row{row("a", 20000), row("b", 20000)}},
+			{StatementID: 1, Series: []models.Row{row("c", 20000)}},
+		},
+		ExtentList:   timeseries.ExtentList{{Start: time.Unix(20, 0), End: time.Unix(20, 0)}},
+		StepDuration: testStep,
+	}
+	d2 := &SeriesEnvelope{
+		Results: []Result{
+			{StatementID: 0, Series: []models.Row{row("a", 30000), row("b", 30000)}},
+			{StatementID: 1, Series: []models.Row{row("c", 30000)}},
+		},
+		ExtentList:   timeseries.ExtentList{{Start: time.Unix(30, 0), End: time.Unix(30, 0)}},
+		StepDuration: testStep,
+	}
+
+	se.Merge(true, d1, d2)
+
+	expected := []Result{
+		{StatementID: 0, Series: []models.Row{row("a", 10000, 20000, 30000), row("b", 10000, 20000, 30000)}},
+		{StatementID: 1, Series: []models.Row{row("c", 20000, 30000)}},
+	}
+	if !reflect.DeepEqual(se.Results, expected) {
+		t.Errorf("mismatch\nactual=%v\nexpect=%v", se.Results, expected)
+	}
+	if len(se.ExtentList) != 1 || !se.ExtentList[0].End.Equal(time.Unix(30, 0)) {
+		t.Errorf("unexpected extents %s", se.ExtentList)
+	}
+}
+
 func TestCropToSize(t *testing.T) {
 
 	now := time.Now().Truncate(testStep)
@@ -778,6 +826,49 @@ func TestCropToSize(t *testing.T) {
 	}
 }
 
+func TestCropToSizeRaw(t *testing.T) {
+
+	// raw values are not aligned to a step or to whole seconds
+	se := &SeriesEnvelope{
+		Results: []Result{
+			{
+				Series: []models.Row{
+					{
+						Name:    "a",
+						Columns: []string{"time", "units"},
+						Values: [][]interface{}{
+							{float64(1444004600100), 1.5},
+							{float64(1444004600200), 2.5},
+							{float64(1444004601300), 3.5},
+						},
+					},
+				},
+			},
+		},
+		ExtentList: timeseries.ExtentList{
+			timeseries.Extent{Start: time.Unix(1444004600, 0), End: time.Unix(1444004605, 0)},
+		},
+	}
+
+	se.CropToSize(1, time.Now(), timeseries.Extent{})
+
+	v := se.Results[0].Series[0].Values
+	if len(v) != 1 || v[0][0].(float64) != 1444004601300 {
+		t.Errorf("unexpected values %v", v)
+	}
+	start := time.Unix(1444004600, 200000000).Add(time.Nanosecond)
+	if len(se.ExtentList) != 1 || !se.ExtentList[0].Start.Equal(start) {
+		t.Errorf("unexpected extents %s", se.ExtentList)
+	}
+
+	// values are cropped at millisecond precision
+	se.CropToRange(timeseries.Extent{Start: start, End: time.Unix(1444004601, 299000000)})
+	if len(se.Results[0].Series) != 0 {
+		t.Errorf("unexpected series %v", se.Results[0].Series)
+	}
+
+}
+
 func TestCropToRange(t *testing.T) {
 	tests := []struct {
 		before, after *SeriesEnvelope
@@ -32,13 +32,18 @@ const (
 	tkTime = "<$TIME_TOKEN$>"
 )
 
-var reTime1, reTime2, reStep *regexp.Regexp
+var reTime1, reTime2, reStep, reRawFields, reRawModifiers *regexp.Regexp
 
 func init() {
 
 	// Regexp for extracting the step from an InfluxDB Timeseries Query. searches for something like: group by time(1d)
 	reStep = regexp.MustCompile(`(?i)\s+group\s+by\s+.*time\((?P<step>[0-9]+(ns|µ|u|ms|s|m|h|d|w|y))\).*;??`)
 
+	// Regexps for determining whether a query without a step selects raw values that can be merged across time ranges.
+	// The field list must not call any functions, and clauses that limit, reorder or write the results are not allowed
+	reRawFields = regexp.MustCompile(`(?is)^\s*select\s+(?P<fields>.+?)\s+from\s`)
+	reRawModifiers = regexp.MustCompile(`(?i)\s(s?limit|s?offset)\s+[0-9]+|\sorder\s+by\s+time\s+desc\b|\sinto\s`)
+
 	// Regexp for extracting the time elements from an InfluxDB Timeseries Query with equality operators: >=, >, =
 	// If it's a relative time range (e.g.,  where time >= now() - 24h  ), this expression is all that is required
 	reTime1 = regexp.MustCompile(`(?i)(?P<preOp1>where|and)\s+(?P<timeExpr1>time\s+(?P<relationalOp1>>=|>|=)\s+(?P<value1>((?P<ts1>[0-9]+)(?P<tsUnit1>ns|µ|u|ms|s|m|h|d|w|y)|(?P<now1>now\(\))\s+(?P<operand1>[+-])\s+(?P<offset1>[0-9]+[mhsdwy]))))(\s+(?P<postOp1>and|or|group|order|limit)|$)`)
@@ -48,8 +53,54 @@ func init() {
 	reTime2 = regexp.MustCompile(`(?i)(?P<preOp2>where|and)\s+(?P<timeExpr2>time\s+(?P<relationalOp2><=|<)\s+(?P<value2>((?P<ts2>[0-9]+)(?P<tsUnit2>ns|µ|u|ms|s|m|h|d|w|y)|(?P<now2>now\(\))\s+(?P<operand2>[+-])\s+(?P<offset2>[0-9]+[mhsdwy]))))(\s+(?P<postOp2>and|or|group|order|limit)|$)`)
 }
 
-func interpolateTimeQuery(template string, extent *timeseries.Extent) string {
-	return strings.Replace(template, tkTime, fmt.Sprintf("time >= %dms AND time <= %dms", extent.Start.Unix()*1000, extent.End.Unix()*1000), -1)
+// splitStatements returns the semicolon-separated statements of an InfluxQL query,
+// ignoring any semicolons within quoted strings and identifiers
+func splitStatements(query string) []string {
+	statements := make([]string, 0, 1)
+	var quote rune
+	var escaped bool
+	start := 0
+	add := func(end int) {
+		if stmt := strings.TrimSpace(query[start:end]); stmt != "" {
+			statements = append(statements, stmt)
+		}
+	}
+	for i, c := range query {
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ';':
+			add(i)
+			start = i + 1
+		}
+	}
+	add(len(query))
+	return statements
+}
+
+// isRawQuery returns true if the statement selects raw values, whose results for different
+// time ranges can be merged
+func isRawQuery(stmt string) bool {
+	fields, ok := matching.GetNamedMatch("fields", reRawFields, stmt)
+	return ok && !strings.Contains(fields, "(") && !reRawModifiers.MatchString(stmt)
+}
+
+// interpolateTimeQuery replaces the time token in the template with the extent, in whole seconds for
+// statements with a step, and in milliseconds for raw values
+func interpolateTimeQuery(template string, extent *timeseries.Extent, step time.Duration) string {
+	start, end := extent.Start.Unix()*1000, extent.End.Unix()*1000
+	if step <= 0 {
+		start, end = extent.Start.UnixNano()/int64(time.Millisecond), extent.End.UnixNano()/int64(time.Millisecond)
+	}
+	return strings.Replace(template, tkTime, fmt.Sprintf("time >= %dms AND time <= %dms", start, end), -1)
 }
 
 func getQueryParts(query string) (string, timeseries.Extent) {
@@ -14,6 +14,7 @@
 package influxdb
 
 import (
+	"strings"
 	"testing"
 	"time"
 )
@@ -63,3 +64,24 @@ func TestTimeFromParts(t *testing.T) {
 	}
 
 }
+
+func TestSplitStatements(t *testing.T) {
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"", []string{}},
+		{"SELECT a FROM b", []string{"SELECT a FROM b"}},
+		{"SELECT a FROM b; SELECT c FROM d;", []string{"SELECT a FROM b", "SELECT c FROM d"}},
+		{`SELECT a FROM "x;y" WHERE c = 'd;\'e'; SELECT f FROM g`, []string{`SELECT a FROM "x;y" WHERE c = 'd;\'e'`, "SELECT f FROM g"}},
+	}
+
+	for i, test := range tests {
+		out := splitStatements(test.query)
+		if strings.Join(out, "|") != strings.Join(test.expected, "|") || len(out) != len(test.expected) {
+			t.Errorf("test %d expected %v got %v", i, test.expected, out)
+		}
+	}
+
+}
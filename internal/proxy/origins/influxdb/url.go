@@ -23,13 +23,20 @@ import (
 
 // Upstream Endpoints
 const (
-	mnQuery = "query"
+	mnQuery   = "query"
+	mnQueryV2 = "api/v2/query"
 )
 
 // Common URL Parameter Names
 const (
 	upQuery = "q"
 	upDB    = "db"
+	upEpoch = "epoch"
+
+	// Flux requests carry the query in the request body, so these are only
+	// present in the TemplateURL, in order to factor them into the cache key
+	upFluxQuery   = "query"
+	upFluxRequest = "request"
 )
 
 // BaseURL returns a URL in the form of scheme://host/path based on the proxy configuration
@@ -60,12 +67,17 @@ func (c Client) BuildUpstreamURL(r *http.Request) *url.URL {
 // SetExtent will change the upstream request query to use the provided Extent
 func (c Client) SetExtent(r *http.Request, trq *timeseries.TimeRangeQuery, extent *timeseries.Extent) {
 
+	if isFluxRequest(r) {
+		setFluxExtent(r, trq, extent)
+		return
+	}
+
 	p := r.URL.Query()
 	t := trq.TemplateURL.Query()
 
 	q := t.Get(upQuery)
 	if q != "" {
-		p.Set(upQuery, interpolateTimeQuery(q, extent))
+		p.Set(upQuery, interpolateTimeQuery(q, extent, trq.Step))
 	}
 
 	r.URL.RawQuery = p.Encode()
@@ -41,13 +41,25 @@ func TestSetExtent(t *testing.T) {
 	tu := &url.URL{RawQuery: "q=select * where <$TIME_TOKEN$> group by time(1m)"}
 
 	r, _ := http.NewRequest(http.MethodGet, tu.String(), nil)
-	trq := &timeseries.TimeRangeQuery{TemplateURL: tu}
+	trq := &timeseries.TimeRangeQuery{TemplateURL: tu, Step: time.Minute}
 	e := &timeseries.Extent{Start: start, End: end}
 	client.SetExtent(r, trq, e)
 
 	if expected != r.URL.RawQuery {
 		t.Errorf("\nexpected [%s]\ngot    [%s]", expected, r.URL.RawQuery)
 	}
+
+	// raw values are queried with millisecond precision
+	tu = &url.URL{RawQuery: "q=select * where <$TIME_TOKEN$>"}
+	r, _ = http.NewRequest(http.MethodGet, tu.String(), nil)
+	trq = &timeseries.TimeRangeQuery{TemplateURL: tu}
+	e = &timeseries.Extent{Start: time.Unix(100, 250000000), End: time.Unix(200, 500000001)}
+	client.SetExtent(r, trq, e)
+
+	expected = "q=select+%2A+where+time+%3E%3D+100250ms+AND+time+%3C%3D+200500ms"
+	if expected != r.URL.RawQuery {
+		t.Errorf("\nexpected [%s]\ngot    [%s]", expected, r.URL.RawQuery)
+	}
 }
 
 func TestBuildUpstreamURL(t *testing.T) {
@@ -14,8 +14,11 @@
 package irondb
 
 import (
+	"fmt"
 	"net/http"
 	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -97,8 +100,16 @@ func (c *Client) caqlHandlerParseTimeRangeQuery(
 		return nil, err
 	}
 
+	minPeriod, err := caqlMinPeriod(trq.Statement)
+	if err != nil {
+		return nil, err
+	}
+
 	if p = qp.Get(upCAQLPeriod); p == "" {
-		return nil, errors.MissingURLParam(upCAQLPeriod)
+		if minPeriod == 0 {
+			return nil, errors.MissingURLParam(upCAQLPeriod)
+		}
+		p = "0"
 	}
 
 	if !strings.HasSuffix(p, "s") {
@@ -109,9 +120,44 @@ func (c *Client) caqlHandlerParseTimeRangeQuery(
 		return nil, err
 	}
 
+	// IRONdb uses the smallest multiple of the #min_period that is no smaller than the period
+	if minPeriod > 0 && (trq.Step == 0 || trq.Step%minPeriod != 0) {
+		trq.Step = (trq.Step/minPeriod + 1) * minPeriod
+	}
+
 	return trq, nil
 }
 
+// caqlMinPeriodDirective matches the #min_period directive of a CAQL query, which sets
+// the minimum period of its output, as a number of seconds or a duration like 5m
+var caqlMinPeriodDirective = regexp.MustCompile(`(?:^|\s)#min_period\s*=\s*([^\s]+)`)
+
+// caqlDurationUnits are the units of CAQL durations
+var caqlDurationUnits = map[string]time.Duration{
+	"":  time.Second,
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+// caqlMinPeriod returns the minimum period set by the query's #min_period directive,
+// or 0 when the query has none
+func caqlMinPeriod(q string) (time.Duration, error) {
+	m := caqlMinPeriodDirective.FindStringSubmatch(q)
+	if m == nil {
+		return 0, nil
+	}
+	v := strings.TrimRight(m[1], "smhdw")
+	unit, ok := caqlDurationUnits[m[1][len(v):]]
+	n, err := strconv.ParseInt(v, 10, 64)
+	if !ok || err != nil || n <= 0 {
+		return 0, fmt.Errorf("unable to parse #min_period %s", m[1])
+	}
+	return time.Duration(n) * unit, nil
+}
+
 // caqlHandlerFastForwardURL returns the url to fetch the Fast Forward value
 // based on a timerange URL.
 func (c *Client) caqlHandlerFastForwardURL(
@@ -16,6 +16,7 @@ package irondb
 import (
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"testing"
 	"time"
 
@@ -172,6 +173,58 @@ func TestCaqlHandlerParseTimeRangeQuery(t *testing.T) {
 		t.Errorf("expected error for parameter missing")
 	}
 
+	// period is raised to a multiple of the #min_period
+	r.URL.RawQuery = "q=" + url.QueryEscape("#min_period=60 metric:average(\"1234\",\"test\")") + "&start=9012&end=3456&period=90"
+	trq, err = client.caqlHandlerParseTimeRangeQuery(r)
+	if err != nil {
+		t.Error(err)
+	} else if trq.Step != 120*time.Second {
+		t.Errorf("expected %s got %s", 120*time.Second, trq.Step)
+	}
+
+	// missing period param with a #min_period
+	r.URL.RawQuery = "q=" + url.QueryEscape("#min_period=5m find(\"test\")") + "&start=9012&end=3456"
+	trq, err = client.caqlHandlerParseTimeRangeQuery(r)
+	if err != nil {
+		t.Error(err)
+	} else if trq.Step != 5*time.Minute {
+		t.Errorf("expected %s got %s", 5*time.Minute, trq.Step)
+	}
+
+	// unparsable #min_period
+	r.URL.RawQuery = "q=" + url.QueryEscape("#min_period=5x find(\"test\")") + "&start=9012&end=3456&period=60"
+	_, err = client.caqlHandlerParseTimeRangeQuery(r)
+	if err == nil {
+		t.Errorf("expected error for unparsable #min_period")
+	}
+
+}
+
+func TestCaqlMinPeriod(t *testing.T) {
+
+	tests := []struct {
+		query    string
+		expected time.Duration
+		err      bool
+	}{
+		{`find("test")`, 0, false},
+		{`#min_period=60 find("test")`, time.Minute, false},
+		{"#min_period = 1h\nfind(\"test\")", time.Hour, false},
+		{`#min_period=2d find("test")`, 48 * time.Hour, false},
+		{`#min_period=0 find("test")`, 0, true},
+		{`#min_period=1ms find("test")`, 0, true},
+	}
+
+	for i, test := range tests {
+		d, err := caqlMinPeriod(test.query)
+		if (err != nil) != test.err {
+			t.Errorf("test %d: unexpected error %v", i, err)
+		}
+		if d != test.expected {
+			t.Errorf("test %d: expected %s got %s", i, test.expected, d)
+		}
+	}
+
 }
 
 func TestCaqlHandlerFastForwardURLError(t *testing.T) {
@@ -15,13 +15,33 @@ package irondb
 
 import (
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/Comcast/trickster/internal/proxy/engines"
+	"github.com/Comcast/trickster/internal/proxy/request"
 )
 
 // FindHandler handles requests to find metirc information and processes them
-// through the object proxy cache.
+// through the object proxy cache. The activity window of the request is rounded
+// to the Path's time quantum, so that similar requests share cached responses.
 func (c *Client) FindHandler(w http.ResponseWriter, r *http.Request) {
-	r.URL = c.BuildUpstreamURL(r)
+	u := c.BuildUpstreamURL(r)
+
+	if rsc := request.GetResources(r); rsc != nil && rsc.PathConfig != nil &&
+		rsc.PathConfig.TimeQuantum >= time.Second {
+		q := u.Query()
+		quantum := int64(rsc.PathConfig.TimeQuantum.Seconds())
+		for _, k := range []string{upActivityStart, upActivityEnd} {
+			if p := q.Get(k); p != "" {
+				if t, err := strconv.ParseInt(p, 10, 64); err == nil {
+					q.Set(k, strconv.FormatInt(t-(t%quantum), 10))
+				}
+			}
+		}
+		u.RawQuery = q.Encode()
+	}
+
+	r.URL = u
 	engines.ObjectProxyCacheRequest(w, r)
 }
@@ -58,3 +58,31 @@ func TestFindHandler(t *testing.T) {
 		t.Errorf("expected '{}' got %s.", bodyBytes)
 	}
 }
+
+func TestFindHandlerTimeQuantum(t *testing.T) {
+
+	client := &Client{name: "test"}
+	ts, w, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, "{}", nil, "irondb", "/find/1/tags?query=metric"+
+		"&activity_start_secs=119&activity_end_secs=901", "debug")
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	defer ts.Close()
+	if err != nil {
+		t.Error(err)
+	}
+
+	rsc.PathConfig = client.config.Paths["/"+mnFind+"/"]
+
+	client.FindHandler(w, r)
+
+	q := r.URL.Query()
+	if v := q.Get(upActivityStart); v != "60" {
+		t.Errorf("expected %s got %s", "60", v)
+	}
+	if v := q.Get(upActivityEnd); v != "900" {
+		t.Errorf("expected %s got %s", "900", v)
+	}
+}
@@ -49,6 +49,12 @@ const (
 	upCAQLStart  = "start"
 	upCAQLEnd    = "end"
 	upCAQLPeriod = "period"
+	upCAQLFormat = "format"
+
+	upActivity      = "activity"
+	upActivityStart = "activity_start_secs"
+	upActivityEnd   = "activity_end_secs"
+	upLatest        = "latest"
 )
 
 // IRONdb request body field names.
@@ -96,6 +102,7 @@ func (c *Client) makeTrqParsers() {
 		"TextHandler":      c.textHandlerParseTimeRangeQuery,
 		"HistogramHandler": c.histogramHandlerParseTimeRangeQuery,
 		"CAQLHandler":      c.caqlHandlerParseTimeRangeQuery,
+		"CAQLPubHandler":   c.caqlHandlerParseTimeRangeQuery,
 	}
 }
 
@@ -107,6 +114,7 @@ func (c *Client) makeExtentSetters() {
 		"TextHandler":      c.textHandlerSetExtent,
 		"HistogramHandler": c.histogramHandlerSetExtent,
 		"CAQLHandler":      c.caqlHandlerSetExtent,
+		"CAQLPubHandler":   c.caqlHandlerSetExtent,
 	}
 }
 
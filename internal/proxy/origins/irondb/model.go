@@ -72,6 +72,10 @@ func (se *SeriesEnvelope) UnmarshalJSON(b []byte) error {
 
 		se.Data = se2.Data
 		se.ExtentList = se2.ExtentList
+		// raw data has no step
+		if se2.StepDuration == "" {
+			return nil
+		}
 		d, err := time.ParseDuration(se2.StepDuration)
 		if err != nil {
 			return err
@@ -198,7 +202,7 @@ func (se *SeriesEnvelope) ValueCount() int {
 func (se *SeriesEnvelope) TimestampCount() int {
 	ts := map[int64]struct{}{}
 	for _, dp := range se.Data {
-		ts[dp.Time.Unix()] = struct{}{}
+		ts[dp.Time.UnixNano()] = struct{}{}
 	}
 
 	return len(ts)
@@ -277,7 +281,7 @@ func (se *SeriesEnvelope) CropToSize(sz int, t time.Time,
 
 	ts := map[int64]struct{}{}
 	for _, dp := range se.Data {
-		ts[dp.Time.Unix()] = struct{}{}
+		ts[dp.Time.UnixNano()] = struct{}{}
 	}
 
 	if len(se.Data) == 0 || len(ts) <= sz {
@@ -297,10 +301,10 @@ func (se *SeriesEnvelope) CropToSize(sz int, t time.Time,
 		tsm[int64(t)] = struct{}{}
 	}
 
-	min, max := time.Now().Unix(), int64(0)
+	min, max := time.Now().UnixNano(), int64(0)
 	newData := DataPoints{}
 	for _, dp := range se.Data {
-		t := dp.Time.Unix()
+		t := dp.Time.UnixNano()
 		if _, ok := tsm[t]; ok {
 			newData = append(newData, dp)
 			if t < min {
@@ -315,16 +319,30 @@ func (se *SeriesEnvelope) CropToSize(sz int, t time.Time,
 
 	se.Data = newData
 	se.ExtentList = timeseries.ExtentList{timeseries.Extent{
-		Start: time.Unix(min, 0),
-		End:   time.Unix(max, 0),
+		Start: time.Unix(0, min),
+		End:   time.Unix(0, max),
 	}}
 
 	se.Sort()
 }
 
 // Sort sorts all data in the Timeseries chronologically by their timestamp.
+// Data points with the same timestamp, such as the raw samples on the
+// boundaries of merged extents, are deduplicated, keeping the last merged.
 func (se *SeriesEnvelope) Sort() {
-	sort.Sort(se.Data)
+	sort.Stable(se.Data)
+	if len(se.Data) < 2 {
+		return
+	}
+	data := se.Data[:1]
+	for _, dp := range se.Data[1:] {
+		if dp.Time.Equal(data[len(data)-1].Time) {
+			data[len(data)-1] = dp
+			continue
+		}
+		data = append(data, dp)
+	}
+	se.Data = data
 }
 
 // MarshalTimeseries converts a Timeseries into a JSON blob for cache storage.
@@ -14,6 +14,8 @@
 package irondb
 
 import (
+	"encoding/json"
+	"strconv"
 	"sync"
 	"time"
 
@@ -81,12 +83,97 @@ func (se *DF4SeriesEnvelope) TimestampCount() int {
 	return int(se.Head.Count)
 }
 
+// metricData values hold the data of a single series of a DF4 Timeseries, keyed by timestamp
 type metricData struct {
-	name string
 	meta map[string]interface{}
 	data map[int64]interface{}
 }
 
+// df4Metrics values hold the series of a DF4 Timeseries, in their original order, so
+// that the outputs of multi-output CAQL queries are returned in the order requested
+type df4Metrics struct {
+	keys    []string
+	metrics map[string]*metricData
+}
+
+// seriesKeys returns a key for each series in the Timeseries, derived from its metadata.
+// The label alone can't identify a series, since the outputs of a CAQL query can share a
+// label, or have none, so repeated metadata is told apart by its occurrence.
+func (se *DF4SeriesEnvelope) seriesKeys() []string {
+	keys := make([]string, len(se.Data))
+	seen := make(map[string]int)
+	for i := range se.Data {
+		var k string
+		if i < len(se.Meta) {
+			// maps are marshaled with sorted keys, so equal metadata yields equal keys
+			b, _ := json.Marshal(se.Meta[i])
+			k = string(b)
+		}
+		seen[k]++
+		keys[i] = k + "#" + strconv.Itoa(seen[k])
+	}
+	return keys
+}
+
+// addSeries adds the data of each series of the Timeseries, located by its own head start
+// and period, that falls within the provided time range. Existing values are replaced,
+// unless the new value is null.
+func (m *df4Metrics) addSeries(se *DF4SeriesEnvelope, start, end int64) {
+	if m.metrics == nil {
+		m.metrics = make(map[string]*metricData)
+	}
+	for i, k := range se.seriesKeys() {
+		md, ok := m.metrics[k]
+		if !ok {
+			md = &metricData{data: make(map[int64]interface{})}
+			if i < len(se.Meta) {
+				md.meta = se.Meta[i]
+			}
+			m.metrics[k] = md
+			m.keys = append(m.keys, k)
+		}
+		for j, dv := range se.Data[i] {
+			ts := se.Head.Start + (int64(j) * se.Head.Period)
+			if ts < start || ts > end || (dv == nil && md.data[ts] != nil) {
+				continue
+			}
+			md.data[ts] = dv
+		}
+	}
+}
+
+// apply replaces the data and metadata of the Timeseries with the series, populated
+// with the data at each timestamp described by the provided head
+func (m *df4Metrics) apply(se *DF4SeriesEnvelope, head DF4Info) {
+	newData := make([][]interface{}, 0, len(m.keys))
+	newMeta := make([]map[string]interface{}, 0, len(m.keys))
+	var hasMeta bool
+	for _, k := range m.keys {
+		md := m.metrics[k]
+		newMeta = append(newMeta, md.meta)
+		hasMeta = hasMeta || md.meta != nil
+		d := make([]interface{}, head.Count)
+		for i := range d {
+			d[i] = md.data[head.Start+(int64(i)*head.Period)]
+		}
+		newData = append(newData, d)
+	}
+	if !hasMeta {
+		newMeta = nil
+	}
+	se.Data = newData
+	se.Meta = newMeta
+	se.Head = head
+}
+
+// period returns the period of the Timeseries' data points, in seconds
+func (se *DF4SeriesEnvelope) period() int64 {
+	if se.Head.Period > 0 {
+		return se.Head.Period
+	}
+	return int64(se.StepDuration.Seconds())
+}
+
 // Merge merges the provided Timeseries list into the base Timeseries (in the
 // order provided) and optionally sorts the merged Timeseries.
 func (se *DF4SeriesEnvelope) Merge(sort bool,
@@ -94,80 +181,43 @@ func (se *DF4SeriesEnvelope) Merge(sort bool,
 	for _, ts := range collection {
 		if ts != nil && ts.Step() == se.Step() {
 			if se2, ok := ts.(*DF4SeriesEnvelope); ok {
-				// Build new data series for each metric.
-				metrics := map[string]*metricData{}
-				for i, mv := range se.Meta {
-					if name, ok := mv["label"].(string); ok {
-						metrics[name] = &metricData{
-							name: name,
-							meta: mv,
-							data: map[int64]interface{}{},
-						}
-
-						for j, dv := range se.Data[i] {
-							ts := se.Head.Start + (int64(j) * se.Head.Period)
-							metrics[name].data[ts] = dv
-						}
-					}
-				}
-
-				// Merge in the data from the merging series.
-				for i, mv := range se2.Meta {
-					if name, ok := mv["label"].(string); ok {
-						md, ok := metrics[name]
-						if !ok {
-							metrics[name] = &metricData{
-								name: name,
-								meta: mv,
-								data: map[int64]interface{}{},
-							}
-
-							md = metrics[name]
-						}
-
-						for j, dv := range se2.Data[i] {
-							ts := se2.Head.Start +
-								(int64(j) * se2.Head.Period)
-							md.data[ts] = dv
-						}
-					}
-				}
 
-				// Calculate the new range of data points.
-				min := se.Head.Start
-				if se2.Head.Start < se.Head.Start {
-					min = se2.Head.Start
+				period := se.period()
+				if period <= 0 {
+					period = se2.period()
 				}
-
-				max := se.Head.Start + ((se.Head.Count - 1) * se.Head.Period)
-				max2 := se2.Head.Start + ((se2.Head.Count - 1) * se2.Head.Period)
-				if max2 > max {
-					max = max2
+				if period <= 0 {
+					continue
 				}
 
-				// Merge the new data series.
-				newData := [][]interface{}{}
-				newMeta := []map[string]interface{}{}
-				newHead := DF4Info{
-					Count:  (max-min)/se.Head.Period + 1,
-					Start:  min,
-					Period: se.Head.Period,
+				// Calculate the new range of data points, from the envelopes that have any.
+				var min, max int64
+				var found bool
+				for _, e := range []*DF4SeriesEnvelope{se, se2} {
+					if e.Head.Count <= 0 || e.Head.Period <= 0 {
+						continue
+					}
+					emin := e.Head.Start
+					emax := e.Head.Start + ((e.Head.Count - 1) * e.Head.Period)
+					if !found || emin < min {
+						min = emin
+					}
+					if !found || emax > max {
+						max = emax
+					}
+					found = true
 				}
 
-				for _, m := range metrics {
-					newMeta = append(newMeta, m.meta)
-					d := []interface{}{}
-					for i := int64(0); i < newHead.Count; i++ {
-						ts := newHead.Start + (i * newHead.Period)
-						d = append(d, m.data[ts])
-					}
+				m := &df4Metrics{}
+				m.addSeries(se, min, max)
+				m.addSeries(se2, min, max)
 
-					newData = append(newData, d)
+				newHead := DF4Info{Start: min, Period: period}
+				if found {
+					newHead.Count = (max-min)/period + 1
 				}
+				m.apply(se, newHead)
 
-				se.Data = newData
-				se.Meta = newMeta
-				se.Head = newHead
 				se.ExtentList = append(se.ExtentList, se2.ExtentList...)
 			}
 		}
@@ -179,11 +229,30 @@ func (se *DF4SeriesEnvelope) Merge(sort bool,
 	}
 }
 
+// cloneValue returns a copy of a DF4 data value. Histogram values are maps of buckets to
+// counts, which must not be shared between clones.
+func cloneValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(t))
+		for k, bv := range t {
+			c[k] = bv
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(t))
+		for i, bv := range t {
+			c[i] = cloneValue(bv)
+		}
+		return c
+	}
+	return v
+}
+
 // Clone returns a perfect copy of the base Timeseries.
 func (se *DF4SeriesEnvelope) Clone() timeseries.Timeseries {
 	b := &DF4SeriesEnvelope{
 		Data: make([][]interface{}, len(se.Data)),
-		Meta: make([]map[string]interface{}, len(se.Meta)),
 		Ver:  se.Ver,
 		Head: DF4Info{
 			Count:  se.Head.Count,
@@ -196,9 +265,14 @@ func (se *DF4SeriesEnvelope) Clone() timeseries.Timeseries {
 
 	for i, v := range se.Data {
 		b.Data[i] = make([]interface{}, len(v))
-		copy(b.Data[i], v)
+		for j, dv := range v {
+			b.Data[i][j] = cloneValue(dv)
+		}
 	}
 
+	if se.Meta != nil {
+		b.Meta = make([]map[string]interface{}, len(se.Meta))
+	}
 	for i, v := range se.Meta {
 		b.Meta[i] = make(map[string]interface{}, len(se.Meta[i]))
 		for k, mv := range v {
@@ -213,9 +287,22 @@ func (se *DF4SeriesEnvelope) Clone() timeseries.Timeseries {
 // Crop assumes the base Timeseries is already sorted, and will corrupt an
 // unsorted Timeseries.
 func (se *DF4SeriesEnvelope) CropToRange(e timeseries.Extent) {
-	// Align crop extents with step period.
-	e.Start = time.Unix(e.Start.Unix()-(e.Start.Unix()%se.Head.Period), 0)
-	e.End = time.Unix(e.End.Unix()-(e.End.Unix()%se.Head.Period), 0)
+
+	// Align crop extents with the data points, as located by the head start and period.
+	period := se.period()
+	if period <= 0 {
+		period = 1
+	}
+	align := func(t time.Time) time.Time {
+		d := t.Unix() - se.Head.Start
+		o := d % period
+		if o < 0 {
+			o += period
+		}
+		return time.Unix(t.Unix()-o, 0)
+	}
+	e.Start = align(e.Start)
+	e.End = align(e.End)
 
 	// If the Timeseries has no extents, or the extent of the series is entirely
 	// outside the extent of the crop range, return empty set and bail.
@@ -228,48 +315,14 @@ func (se *DF4SeriesEnvelope) CropToRange(e timeseries.Extent) {
 		return
 	}
 
-	// Create a map of the time series data.
-	metrics := map[string]metricData{}
-	for i, mv := range se.Meta {
-		if name, ok := mv["label"].(string); ok {
-			metrics[name] = metricData{
-				name: name,
-				meta: mv,
-				data: map[int64]interface{}{},
-			}
-
-			for j, dv := range se.Data[i] {
-				ts := se.Head.Start + (int64(j) * se.Head.Period)
-				if ts >= e.Start.Unix() && ts <= e.End.Unix() {
-					metrics[name].data[ts] = dv
-				}
-			}
-		}
-	}
-
 	// Replace with the cropped data series.
-	newData := [][]interface{}{}
-	newMeta := []map[string]interface{}{}
-	newHead := DF4Info{
-		Count:  (e.End.Unix() - e.Start.Unix()) / se.Head.Period,
+	m := &df4Metrics{}
+	m.addSeries(se, e.Start.Unix(), e.End.Unix())
+	m.apply(se, DF4Info{
+		Count:  (e.End.Unix() - e.Start.Unix()) / period,
 		Start:  e.Start.Unix(),
-		Period: se.Head.Period,
-	}
-
-	for _, m := range metrics {
-		newMeta = append(newMeta, m.meta)
-		d := []interface{}{}
-		for i := int64(0); i < newHead.Count; i++ {
-			ts := newHead.Start + (i * newHead.Period)
-			d = append(d, m.data[ts])
-		}
-
-		newData = append(newData, d)
-	}
-
-	se.Data = newData
-	se.Meta = newMeta
-	se.Head = newHead
+		Period: period,
+	})
 	se.ExtentList = se.ExtentList.Crop(e)
 }
 
@@ -332,8 +385,17 @@ func (se *DF4SeriesEnvelope) Size() int {
 	for i := range se.Data {
 		wg.Add(1)
 		go func(s []interface{}) {
+			n := len(s) * 16
+			for _, v := range s {
+				// histogram values hold a count for each bucket
+				if h, ok := v.(map[string]interface{}); ok {
+					for k := range h {
+						n += len(k) + 16
+					}
+				}
+			}
 			mtx.Lock()
-			c += (len(s) * 16)
+			c += n
 			mtx.Unlock()
 			wg.Done()
 		}(se.Data[i])
@@ -178,15 +178,114 @@ func TestDF4SeriesEnvelopeMerge(t *testing.T) {
 		t.Errorf("Expected count: 8, got: %v", se1.ValueCount())
 	}
 
-	// disabled until Merge functionality can be made deterministic
+	if se1.Data[0][0] != 1.0 {
+		t.Errorf("Expected first value: 1, got: %v", se1.Data[0][0])
+	}
+
+	if se1.Data[0][3] != 6.0 {
+		t.Errorf("Expected last value: 6, got: %v", se1.Data[0][3])
+	}
+
+	if se1.Meta[1]["label"] != "test1" {
+		t.Errorf("Expected label: test1, got: %v", se1.Meta[1]["label"])
+	}
+}
+
+const testDF4HistogramResponse = `{
+  "data": [
+    [{"+10e-001": 2}, {"+10e-001": 1, "+20e-001": 3}],
+    [1, 2]
+  ],
+  "meta": [
+    {"kind": "histogram", "label": "latency"},
+    {"kind": "numeric", "label": "latency"}
+  ],
+  "version": "DF4",
+  "head": {"count": 2, "start": 0, "period": 60}
+}`
+
+const testDF4HistogramResponse2 = `{
+  "data": [
+    [{"+10e-001": 4}, null],
+    [3, null]
+  ],
+  "meta": [
+    {"kind": "histogram", "label": "latency"},
+    {"kind": "numeric", "label": "latency"}
+  ],
+  "version": "DF4",
+  "head": {"count": 2, "start": 60, "period": 60}
+}`
+
+func TestDF4SeriesEnvelopeMergeHistogram(t *testing.T) {
+	client := &Client{}
+	ts1, err := client.UnmarshalTimeseries([]byte(testDF4HistogramResponse))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts2, err := client.UnmarshalTimeseries([]byte(testDF4HistogramResponse2))
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	// if se1.Data[0][0] != 1.0 {
-	// 	t.Errorf("Expected first value: 1, got: %v", se1.Data[0][0])
-	// }
+	se1 := ts1.(*DF4SeriesEnvelope)
+	se1.Merge(true, ts2)
 
-	// if se1.Data[0][3] != 6.0 {
-	// 	t.Errorf("Expected last value: 6, got: %v", se1.Data[0][3])
-	// }
+	// outputs that share a label are kept apart, in their original order
+	b, err := client.MarshalTimeseries(se1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := `{"data":[[{"+10e-001":2},{"+10e-001":4},null],[1,3,null]],` +
+		`"meta":[{"kind":"histogram","label":"latency"},{"kind":"numeric","label":"latency"}],` +
+		`"version":"DF4","head":{"count":3,"start":0,"period":60}}`
+	if string(b) != exp {
+		t.Errorf("Expected JSON: %s, got: %s", exp, string(b))
+	}
+
+	// null values don't replace cached values
+	ts3, _ := client.UnmarshalTimeseries([]byte(testDF4HistogramResponse2))
+	se3 := ts3.(*DF4SeriesEnvelope)
+	se3.Data[0][0] = nil
+	se1.Merge(true, se3)
+	if h, ok := se1.Data[0][1].(map[string]interface{}); !ok || h["+10e-001"] != 4.0 {
+		t.Errorf("Expected histogram value, got: %v", se1.Data[0][1])
+	}
+}
+
+func TestDF4SeriesEnvelopeMergeEmpty(t *testing.T) {
+	client := &Client{}
+	ts1, err := client.UnmarshalTimeseries([]byte(testDF4Response))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	se := &DF4SeriesEnvelope{}
+	se.Merge(true, ts1)
+
+	if se.Head.Start != 0 || se.Head.Count != 3 || se.Head.Period != 300 {
+		t.Errorf("Unexpected head: %v", se.Head)
+	}
+
+	if se.ValueCount() != 3 {
+		t.Errorf("Expected count: 3, got: %v", se.ValueCount())
+	}
+}
+
+func TestDF4SeriesEnvelopeCloneHistogram(t *testing.T) {
+	client := &Client{}
+	ts1, err := client.UnmarshalTimeseries([]byte(testDF4HistogramResponse))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	se := ts1.(*DF4SeriesEnvelope)
+	se2 := se.Clone().(*DF4SeriesEnvelope)
+	se2.Data[0][0].(map[string]interface{})["+10e-001"] = 10.0
+
+	if h := se.Data[0][0].(map[string]interface{}); h["+10e-001"] != 2.0 {
+		t.Errorf("Expected original value: 2, got: %v", h["+10e-001"])
+	}
 }
 
 func TestDF4SeriesEnvelopeClone(t *testing.T) {
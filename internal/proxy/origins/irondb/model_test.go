@@ -196,16 +196,56 @@ func TestSeriesEnvelopeMerge(t *testing.T) {
 
 	se2 := ts2.(*SeriesEnvelope)
 	se1.Merge(true, se2)
-	if se1.ValueCount() != 7 {
-		t.Errorf("Expected count: 7, got: %v", se1.ValueCount())
+	// values with the same timestamp are deduplicated, keeping the merged value
+	if se1.ValueCount() != 5 {
+		t.Fatalf("Expected count: 5, got: %v", se1.ValueCount())
 	}
 
 	if se1.Data[0].Value != 1.0 {
 		t.Errorf("Expected first value: 1, got: %v", se1.Data[0].Value)
 	}
 
-	if se1.Data[6].Value != 3.0 {
-		t.Errorf("Expected last value: 3, got: %v", se1.Data[6].Value)
+	if se1.Data[1].Value != 2.0 {
+		t.Errorf("Expected merged value: 2, got: %v", se1.Data[1].Value)
+	}
+
+	if se1.Data[4].Value != 3.0 {
+		t.Errorf("Expected last value: 3, got: %v", se1.Data[4].Value)
+	}
+}
+
+func TestSeriesEnvelopeRaw(t *testing.T) {
+	client := &Client{}
+
+	// raw data has no step, so it is cached with only its extents
+	se := &SeriesEnvelope{
+		Data: DataPoints{
+			{Time: time.Unix(300, 250000000), Value: 1.5},
+			{Time: time.Unix(300, 500000000), Value: 2.5},
+		},
+		ExtentList: timeseries.ExtentList{
+			timeseries.Extent{Start: time.Unix(300, 0), End: time.Unix(301, 0)},
+		},
+	}
+
+	b, err := client.MarshalTimeseries(se)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts, err := client.UnmarshalTimeseries(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	se2 := ts.(*SeriesEnvelope)
+	if se2.StepDuration != 0 || len(se2.ExtentList) != 1 {
+		t.Errorf("unexpected step %s or extents %s", se2.StepDuration, se2.ExtentList)
+	}
+
+	// timestamps are counted at their full precision
+	if se2.TimestampCount() != 2 {
+		t.Errorf("Expected count: 2, got %d", se2.TimestampCount())
 	}
 }
 
@@ -298,7 +338,7 @@ func TestSeriesEnvelopeCropToRange(t *testing.T) {
 		return
 	}
 
-	exp := `{"data":[[0,1],[300,1.5],[300,2]],` +
+	exp := `{"data":[[0,1],[300,2]],` +
 		`"extents":[{"start":"` + time.Unix(0, 0).Format(time.RFC3339) + `",` +
 		`"end":"` + time.Unix(300, 0).Format(time.RFC3339) + `"}]}`
 	if string(s1) != exp {
@@ -344,7 +384,7 @@ func TestSeriesEnvelopeCropToSize(t *testing.T) {
 		return
 	}
 
-	exp := `{"data":[[600,1.75],[600,2.5],[900,2.75]],` +
+	exp := `{"data":[[600,2.5],[900,2.75]],` +
 		`"extents":[{"start":"` + time.Unix(600, 0).Format(time.RFC3339) +
 		`","end":"` + time.Unix(900, 0).Format(time.RFC3339) + `"}]}`
 	if string(s1) != exp {
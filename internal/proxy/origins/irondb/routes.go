@@ -15,6 +15,7 @@ package irondb
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/Comcast/trickster/internal/config"
 )
@@ -34,6 +35,18 @@ func (c *Client) registerHandlers() {
 	c.handlers[mnState] = http.HandlerFunc(c.StateHandler)
 	c.handlers[mnCAQL] = http.HandlerFunc(c.CAQLHandler)
 	c.handlers["proxy"] = http.HandlerFunc(c.ProxyHandler)
+	// the default path configs reference the handlers by these names, which
+	// also select each path's time range query parser and extent setter
+	c.handlers["RawHandler"] = c.handlers[mnRaw]
+	c.handlers["RollupHandler"] = c.handlers[mnRollup]
+	c.handlers["FetchHandler"] = c.handlers[mnFetch]
+	c.handlers["TextHandler"] = c.handlers[mnRead]
+	c.handlers["HistogramHandler"] = c.handlers[mnHistogram]
+	c.handlers["FindHandler"] = c.handlers[mnFind]
+	c.handlers["StateHandler"] = c.handlers[mnState]
+	c.handlers["CAQLHandler"] = c.handlers[mnCAQL]
+	c.handlers["CAQLPubHandler"] = c.handlers[mnCAQL]
+	c.handlers["ProxyHandler"] = c.handlers["proxy"]
 }
 
 // Handlers returns a map of the HTTP Handlers the client has registered
@@ -56,6 +69,10 @@ func populateHeathCheckRequestValues(oc *config.OriginConfig) {
 	}
 }
 
+// defaultFindTimeQuantumSecs is the default granularity to which the activity window
+// of find requests is rounded
+const defaultFindTimeQuantumSecs = 60
+
 // DefaultPathConfigs returns the default PathConfigs for the given OriginType
 func (c *Client) DefaultPathConfigs(oc *config.OriginConfig) map[string]*config.PathConfig {
 
@@ -120,10 +137,12 @@ func (c *Client) DefaultPathConfigs(oc *config.OriginConfig) map[string]*config.
 			Path:            "/" + mnFind + "/",
 			HandlerName:     "FindHandler",
 			Methods:         []string{http.MethodGet},
-			CacheKeyParams:  []string{upQuery},
+			CacheKeyParams:  []string{upQuery, upActivity, upActivityStart, upActivityEnd, upLatest},
 			CacheKeyHeaders: []string{},
 			MatchType:       config.PathMatchTypePrefix,
 			MatchTypeName:   "prefix",
+			TimeQuantumSecs: defaultFindTimeQuantumSecs,
+			TimeQuantum:     defaultFindTimeQuantumSecs * time.Second,
 		},
 
 		"/" + mnState + "/": {
@@ -140,7 +159,7 @@ func (c *Client) DefaultPathConfigs(oc *config.OriginConfig) map[string]*config.
 			Path:            "/" + mnCAQL,
 			HandlerName:     "CAQLHandler",
 			Methods:         []string{http.MethodGet},
-			CacheKeyParams:  []string{upQuery, upCAQLQuery, upCAQLPeriod},
+			CacheKeyParams:  []string{upQuery, upCAQLQuery, upCAQLPeriod, upCAQLFormat},
 			CacheKeyHeaders: []string{},
 			MatchType:       config.PathMatchTypePrefix,
 			MatchTypeName:   "prefix",
@@ -150,7 +169,7 @@ func (c *Client) DefaultPathConfigs(oc *config.OriginConfig) map[string]*config.
 			Path:            "/" + mnCAQLPub + "/",
 			HandlerName:     "CAQLPubHandler",
 			Methods:         []string{http.MethodGet},
-			CacheKeyParams:  []string{upQuery, upCAQLQuery, upCAQLPeriod},
+			CacheKeyParams:  []string{upQuery, upCAQLQuery, upCAQLPeriod, upCAQLFormat},
 			CacheKeyHeaders: []string{},
 			MatchType:       config.PathMatchTypePrefix,
 			MatchTypeName:   "prefix",
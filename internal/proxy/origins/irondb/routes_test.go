@@ -16,6 +16,7 @@ package irondb
 import (
 	"testing"
 
+	"github.com/Comcast/trickster/internal/config"
 	"github.com/Comcast/trickster/internal/proxy/request"
 	tu "github.com/Comcast/trickster/internal/util/testing"
 )
@@ -28,6 +29,16 @@ func TestRegisterHandlers(t *testing.T) {
 	}
 }
 
+func TestRegisterHandlersPathNames(t *testing.T) {
+	c := &Client{name: "test"}
+	c.registerHandlers()
+	for _, p := range c.DefaultPathConfigs(config.NewOriginConfig()) {
+		if h, ok := c.handlers[p.HandlerName]; !ok || h == nil {
+			t.Errorf("expected to find handler named: %s", p.HandlerName)
+		}
+	}
+}
+
 func TestHandlers(t *testing.T) {
 	c := &Client{}
 	m := c.Handlers()
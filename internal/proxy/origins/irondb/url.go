@@ -84,7 +84,7 @@ func (c *Client) FastForwardURL(r *http.Request) (*url.URL, error) {
 		return c.rollupHandlerFastForwardURL(r)
 	case "HistogramHandler":
 		return c.histogramHandlerFastForwardURL(r)
-	case "CAQLHandler":
+	case "CAQLHandler", "CAQLPubHandler":
 		return c.caqlHandlerFastForwardURL(r)
 	}
 
@@ -0,0 +1,78 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package loki
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Comcast/trickster/internal/proxy/engines"
+	"github.com/Comcast/trickster/internal/proxy/headers"
+	"github.com/Comcast/trickster/internal/proxy/urls"
+)
+
+// defaultLimit is the number of log lines Loki returns when a query has no limit parameter
+const defaultLimit = 100
+
+// QueryRangeHandler handles range requests for Loki. Metric queries are processed through the
+// delta proxy cache, while log queries are processed through the object proxy cache
+func (c *Client) QueryRangeHandler(w http.ResponseWriter, r *http.Request) {
+	r.URL = c.BuildUpstreamURL(r)
+	qp := r.URL.Query()
+	if isMetricQuery(qp.Get(upQuery)) {
+		engines.DeltaProxyCacheRequest(w, r)
+		return
+	}
+
+	// log queries are only bucketed when they have an explicit time range and limit
+	// that can be applied to the bucketed response; others are cached as requested
+	start, err := parseTime(qp.Get(upStart))
+	if err != nil {
+		engines.ObjectProxyCacheRequest(w, r)
+		return
+	}
+	end, err := parseTime(qp.Get(upEnd))
+	if err != nil {
+		engines.ObjectProxyCacheRequest(w, r)
+		return
+	}
+	limit := defaultLimit
+	if p := qp.Get(upLimit); p != "" {
+		if limit, err = strconv.Atoi(p); err != nil || limit <= 0 {
+			engines.ObjectProxyCacheRequest(w, r)
+			return
+		}
+	}
+
+	br := r.Clone(r.Context())
+	br.URL = urls.Clone(r.URL)
+	bucketTimeRange(br.URL)
+	body, resp, _ := engines.FetchViaObjectProxyCache(br)
+	if resp.StatusCode == http.StatusOK {
+		b, n, err := filterStreams(body, start, end)
+		if err == nil && n >= limit {
+			// Loki applies the limit to the bucketed range, so the bucketed response
+			// may be missing lines that are in the requested range
+			engines.ObjectProxyCacheRequest(w, r)
+			return
+		}
+		if err == nil {
+			body = b
+		}
+	}
+
+	h := resp.Header.Clone()
+	h.Del(headers.NameContentLength)
+	engines.Respond(w, resp.StatusCode, h, body)
+}
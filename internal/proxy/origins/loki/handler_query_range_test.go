@@ -0,0 +1,172 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package loki
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/trickster/internal/proxy/request"
+	tu "github.com/Comcast/trickster/internal/util/testing"
+)
+
+func TestQueryRangeHandler(t *testing.T) {
+
+	client := &Client{name: "test"}
+	now := time.Now().Truncate(time.Minute)
+	start := strconv.FormatInt(now.Add(-time.Hour).Unix(), 10)
+	q := url.Values{"query": {`rate({app="a"}[1m])`}, "start": {start}, "end": {strconv.FormatInt(now.Unix(), 10)}, "step": {"60"}}
+	ts, w, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, testMatrix, nil, "loki", "/loki/api/v1/query_range?"+q.Encode(), "debug")
+	ctx := r.Context()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	defer ts.Close()
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, ok := client.config.Paths[APIPath+mnQueryRange]
+	if !ok {
+		t.Errorf("could not find path config named %s", APIPath+mnQueryRange)
+	}
+
+	// metric queries go through the delta proxy cache
+	client.QueryRangeHandler(w, r)
+	resp := w.Result()
+
+	// it should return 200 OK
+	if resp.StatusCode != 200 {
+		t.Errorf("expected 200 got %d.", resp.StatusCode)
+	}
+
+	if h := resp.Header.Get("X-Trickster-Result"); !strings.Contains(h, "engine=DeltaProxyCache") {
+		t.Errorf("expected delta proxy cache engine got %s", h)
+	}
+
+	// log queries go through the object proxy cache
+	q.Set("query", `{app="a"} |= "error"`)
+	q.Set("start", start+".5")
+	r = httptest.NewRequest(http.MethodGet, ts.URL+"/loki/api/v1/query_range?"+q.Encode(), nil)
+	r = r.WithContext(ctx)
+	w = httptest.NewRecorder()
+
+	client.QueryRangeHandler(w, r)
+	resp = w.Result()
+	if resp.StatusCode != 200 {
+		t.Errorf("expected 200 got %d.", resp.StatusCode)
+	}
+
+	if h := resp.Header.Get("X-Trickster-Result"); !strings.Contains(h, "engine=ObjectProxyCache") {
+		t.Errorf("expected object proxy cache engine got %s", h)
+	}
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if string(bodyBytes) != testMatrix {
+		t.Errorf("expected %s got %s.", testMatrix, bodyBytes)
+	}
+}
+
+func TestQueryRangeHandlerLogQuery(t *testing.T) {
+
+	const body = `{"status":"success","data":{"resultType":"streams","result":[` +
+		`{"stream":{"app":"a"},"values":[["1577836830000000000","c"],["1577836810000000000","b"],["1577836790000000000","a"]]}]}}`
+	const expected = `{"data":{"result":[{"stream":{"app":"a"},"values":[["1577836810000000000","b"]]}],"resultType":"streams"},"status":"success"}`
+
+	client := &Client{name: "test"}
+	q := url.Values{"query": {`{app="a"}`}, "start": {"1577836800"}, "end": {"1577836820"}}
+	ts, w, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, body, nil, "loki", APIPath+mnQueryRange, "debug")
+	ctx := r.Context()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	defer ts.Close()
+	if err != nil {
+		t.Error(err)
+	}
+
+	// the bucketed response is filtered back to the requested time range
+	r = httptest.NewRequest(http.MethodGet, ts.URL+"/loki/api/v1/query_range?"+q.Encode(), nil)
+	r = r.WithContext(ctx)
+	client.QueryRangeHandler(w, r)
+	resp := w.Result()
+	if resp.StatusCode != 200 {
+		t.Errorf("expected 200 got %d.", resp.StatusCode)
+	}
+	if h := resp.Header.Get("X-Trickster-Result"); !strings.Contains(h, "status=kmiss") {
+		t.Errorf("expected kmiss got %s", h)
+	}
+	bodyBytes, _ := ioutil.ReadAll(resp.Body)
+	if string(bodyBytes) != expected {
+		t.Errorf("expected %s got %s.", expected, bodyBytes)
+	}
+
+	// a request in the same bucket is served from the cache
+	q.Set("end", "1577836815")
+	r = httptest.NewRequest(http.MethodGet, ts.URL+"/loki/api/v1/query_range?"+q.Encode(), nil)
+	r = r.WithContext(ctx)
+	w = httptest.NewRecorder()
+	client.QueryRangeHandler(w, r)
+	resp = w.Result()
+	if h := resp.Header.Get("X-Trickster-Result"); !strings.Contains(h, "status=hit") {
+		t.Errorf("expected hit got %s", h)
+	}
+	bodyBytes, _ = ioutil.ReadAll(resp.Body)
+	if string(bodyBytes) != expected {
+		t.Errorf("expected %s got %s.", expected, bodyBytes)
+	}
+
+	// when the bucketed response reaches the limit, the request is fetched as requested
+	q.Set("limit", "3")
+	r = httptest.NewRequest(http.MethodGet, ts.URL+"/loki/api/v1/query_range?"+q.Encode(), nil)
+	r = r.WithContext(ctx)
+	w = httptest.NewRecorder()
+	client.QueryRangeHandler(w, r)
+	resp = w.Result()
+	bodyBytes, _ = ioutil.ReadAll(resp.Body)
+	if string(bodyBytes) != body {
+		t.Errorf("expected %s got %s.", body, bodyBytes)
+	}
+
+	// requests without a valid time range or limit are cached as requested
+	for _, v := range []url.Values{
+		{"query": {`{app="a"}`}, "end": {"1577836820"}},
+		{"query": {`{app="a"}`}, "start": {"1577836800"}},
+		{"query": {`{app="a"}`}, "start": {"1577836800"}, "end": {"1577836820"}, "limit": {"x"}},
+	} {
+		r = httptest.NewRequest(http.MethodGet, ts.URL+"/loki/api/v1/query_range?"+v.Encode(), nil)
+		r = r.WithContext(ctx)
+		w = httptest.NewRecorder()
+		client.QueryRangeHandler(w, r)
+		resp = w.Result()
+		bodyBytes, _ = ioutil.ReadAll(resp.Body)
+		if string(bodyBytes) != body {
+			t.Errorf("expected %s got %s.", body, bodyBytes)
+		}
+	}
+}
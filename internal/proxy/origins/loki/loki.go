@@ -0,0 +1,228 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+// Package loki provides the Loki origin type
+package loki
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Comcast/trickster/internal/cache"
+	"github.com/Comcast/trickster/internal/config"
+	"github.com/Comcast/trickster/internal/proxy"
+	"github.com/Comcast/trickster/internal/proxy/errors"
+	tt "github.com/Comcast/trickster/internal/proxy/timeconv"
+	"github.com/Comcast/trickster/internal/proxy/urls"
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+// Loki API
+const (
+	APIPath      = "/loki/api/v1/"
+	mnQueryRange = "query_range"
+	mnQuery      = "query"
+	mnLabels     = "labels"
+	mnLabel      = "label"
+	mnSeries     = "series"
+)
+
+// Common URL Parameter Names
+const (
+	upQuery     = "query"
+	upStart     = "start"
+	upEnd       = "end"
+	upStep      = "step"
+	upTime      = "time"
+	upLimit     = "limit"
+	upDirection = "direction"
+	upMatch     = "match[]"
+)
+
+// Loki's defaults for omitted query_range parameters
+const (
+	defaultRange      = time.Hour
+	defaultStepPoints = 250
+)
+
+// Client Implements Proxy Client Interface
+type Client struct {
+	name               string
+	config             *config.OriginConfig
+	cache              cache.Cache
+	webClient          *http.Client
+	handlers           map[string]http.Handler
+	handlersRegistered bool
+
+	healthURL     *url.URL
+	healthHeaders http.Header
+	healthMethod  string
+}
+
+// NewClient returns a new Client Instance
+func NewClient(name string, oc *config.OriginConfig, cache cache.Cache) (*Client, error) {
+	c, err := proxy.NewHTTPClient(oc)
+	return &Client{name: name, config: oc, cache: cache, webClient: c}, err
+}
+
+// SetCache sets the Cache object the client will use for caching origin content
+func (c *Client) SetCache(cc cache.Cache) {
+	c.cache = cc
+}
+
+// Configuration returns the upstream Configuration for this Client
+func (c *Client) Configuration() *config.OriginConfig {
+	return c.config
+}
+
+// HTTPClient returns the HTTP Client for this origin
+func (c *Client) HTTPClient() *http.Client {
+	return c.webClient
+}
+
+// Name returns the name of the upstream Configuration proxied by the Client
+func (c *Client) Name() string {
+	return c.name
+}
+
+// Cache returns and handle to the Cache instance used by the Client
+func (c *Client) Cache() cache.Cache {
+	return c.cache
+}
+
+// parseTime converts a Loki time URL parameter to time.Time. Loki accepts
+// nanosecond epochs, second epochs (with up to 10 digits or a fractional
+// part) and RFC3339 times.
+func parseTime(s string) (time.Time, error) {
+	if strings.Contains(s, ".") {
+		if t, err := strconv.ParseFloat(s, 64); err == nil {
+			s, ns := math.Modf(t)
+			ns = math.Round(ns*1000) / 1000
+			return time.Unix(int64(s), int64(ns*float64(time.Second))), nil
+		}
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if len(s) <= 10 {
+			return time.Unix(i, 0), nil
+		}
+		return time.Unix(0, i), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q to a valid timestamp", s)
+}
+
+// parseDuration parses Loki step parameters, which can be float64 seconds or durations like 1d, 5m, etc
+func parseDuration(input string) (time.Duration, error) {
+	v, err := strconv.ParseFloat(input, 64)
+	if err != nil {
+		return tt.ParseDuration(input)
+	}
+	return time.Duration(v * float64(time.Second)), nil
+}
+
+// isMetricQuery returns true if the LogQL query returns samples rather than log
+// lines. Log queries always begin with a stream selector, while metric queries
+// begin with a range or vector aggregation.
+func isMetricQuery(query string) bool {
+	query = strings.TrimSpace(query)
+	return query != "" && query[0] != '{'
+}
+
+// ParseTimeRangeQuery parses the key parts of a TimeRangeQuery from the inbound HTTP Request
+func (c *Client) ParseTimeRangeQuery(r *http.Request) (*timeseries.TimeRangeQuery, error) {
+
+	trq := &timeseries.TimeRangeQuery{Extent: timeseries.Extent{}}
+	qp := r.URL.Query()
+
+	trq.Statement = qp.Get(upQuery)
+	if trq.Statement == "" {
+		return nil, errors.MissingURLParam(upQuery)
+	}
+
+	// log queries return streams of log lines, which are not time series
+	if !isMetricQuery(trq.Statement) {
+		return nil, errors.ErrNotTimeRangeQuery
+	}
+
+	trq.Extent.End = time.Now()
+	if p := qp.Get(upEnd); p != "" {
+		t, err := parseTime(p)
+		if err != nil {
+			return nil, err
+		}
+		trq.Extent.End = t
+	}
+
+	trq.Extent.Start = trq.Extent.End.Add(-defaultRange)
+	if p := qp.Get(upStart); p != "" {
+		t, err := parseTime(p)
+		if err != nil {
+			return nil, err
+		}
+		trq.Extent.Start = t
+	}
+
+	if trq.Extent.End.Before(trq.Extent.Start) {
+		return nil, errors.ErrNotTimeRangeQuery
+	}
+
+	if p := qp.Get(upStep); p != "" {
+		step, err := parseDuration(p)
+		if err != nil {
+			return nil, err
+		}
+		trq.Step = step
+	} else {
+		trq.Step = defaultStep(trq.Extent)
+	}
+	if trq.Step < time.Second {
+		return nil, errors.ErrStepParse
+	}
+
+	if strings.Contains(trq.Statement, " offset ") {
+		trq.IsOffset = true
+		trq.FastForwardDisable = true
+	}
+
+	// the cache key ignores the time range, and always sees the step, since
+	// Loki would otherwise derive a different default step for each range
+	trq.TemplateURL = urls.Clone(r.URL)
+	qt := trq.TemplateURL.Query()
+	qt.Del(upStart)
+	qt.Del(upEnd)
+	qt.Set(upStep, formatStep(trq.Step))
+	trq.TemplateURL.RawQuery = qt.Encode()
+
+	return trq, nil
+}
+
+// defaultStep returns the step Loki uses for a query_range request with no step parameter
+func defaultStep(e timeseries.Extent) time.Duration {
+	s := int64(e.End.Sub(e.Start).Seconds()) / defaultStepPoints
+	if s < 1 {
+		s = 1
+	}
+	return time.Duration(s) * time.Second
+}
+
+// formatStep returns the step as a number of seconds, which Loki accepts in the step parameter
+func formatStep(step time.Duration) string {
+	return strconv.FormatFloat(step.Seconds(), 'f', -1, 64)
+}
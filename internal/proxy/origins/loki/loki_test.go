@@ -0,0 +1,223 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package loki
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	cr "github.com/Comcast/trickster/internal/cache/registration"
+	"github.com/Comcast/trickster/internal/config"
+	"github.com/Comcast/trickster/internal/proxy/errors"
+	"github.com/Comcast/trickster/internal/proxy/origins"
+	"github.com/Comcast/trickster/internal/util/metrics"
+)
+
+func init() {
+	metrics.Init()
+}
+
+func TestLokiClientInterfacing(t *testing.T) {
+
+	// this test ensures the client will properly conform to the
+	// Client and TimeseriesClient interfaces
+
+	c := &Client{name: "test"}
+	var oc origins.Client = c
+	var tc origins.TimeseriesClient = c
+
+	if oc.Name() != "test" {
+		t.Errorf("expected %s got %s", "test", oc.Name())
+	}
+
+	if tc.Name() != "test" {
+		t.Errorf("expected %s got %s", "test", tc.Name())
+	}
+}
+
+func TestNewClient(t *testing.T) {
+
+	err := config.Load("trickster", "test", []string{"-origin-type", "loki", "-origin-url", "http://1"})
+	if err != nil {
+		t.Errorf("Could not load configuration: %s", err.Error())
+	}
+
+	cr.LoadCachesFromConfig()
+	cache, err := cr.GetCache("default")
+	if err != nil {
+		t.Error(err)
+	}
+
+	oc := &config.OriginConfig{OriginType: "TEST_CLIENT"}
+	c, err := NewClient("default", oc, cache)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if c.Name() != "default" {
+		t.Errorf("expected %s got %s", "default", c.Name())
+	}
+
+	if c.Cache().Configuration().CacheType != "memory" {
+		t.Errorf("expected %s got %s", "memory", c.Cache().Configuration().CacheType)
+	}
+
+	if c.Configuration().OriginType != "TEST_CLIENT" {
+		t.Errorf("expected %s got %s", "TEST_CLIENT", c.Configuration().OriginType)
+	}
+
+	if c.HTTPClient() == nil {
+		t.Error("expected non-nil http client")
+	}
+
+	c.SetCache(nil)
+	if c.Cache() != nil {
+		t.Error("expected nil cache")
+	}
+}
+
+func TestParseTime(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected time.Time
+		err      bool
+	}{
+		{"1577836800", time.Unix(1577836800, 0), false},
+		{"1577836800.5", time.Unix(1577836800, 500000000), false},
+		{"1577836800000000000", time.Unix(1577836800, 0), false},
+		{"2020-01-01T00:00:00Z", time.Unix(1577836800, 0), false},
+		{"invalid", time.Time{}, true},
+	}
+
+	for _, test := range tests {
+		tm, err := parseTime(test.input)
+		if (err != nil) != test.err {
+			t.Errorf("input %s: expected error %t got %v", test.input, test.err, err)
+		}
+		if !tm.Equal(test.expected) {
+			t.Errorf("input %s: expected %s got %s", test.input, test.expected, tm)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected time.Duration
+		err      bool
+	}{
+		{"15", 15 * time.Second, false},
+		{"1.5", 1500 * time.Millisecond, false},
+		{"1m", time.Minute, false},
+		{"invalid", 0, true},
+	}
+
+	for _, test := range tests {
+		d, err := parseDuration(test.input)
+		if (err != nil) != test.err {
+			t.Errorf("input %s: expected error %t got %v", test.input, test.err, err)
+		}
+		if d != test.expected {
+			t.Errorf("input %s: expected %s got %s", test.input, test.expected, d)
+		}
+	}
+}
+
+func TestIsMetricQuery(t *testing.T) {
+	if isMetricQuery(` {app="a"} |= "error"`) {
+		t.Error("expected log query")
+	}
+	if !isMetricQuery(`sum(rate({app="a"}[5m]))`) {
+		t.Error("expected metric query")
+	}
+	if isMetricQuery("") {
+		t.Error("expected empty query to not be a metric query")
+	}
+}
+
+func TestParseTimeRangeQuery(t *testing.T) {
+
+	client := &Client{name: "test"}
+	u := &url.URL{Path: "/loki/api/v1/query_range", RawQuery: url.Values{
+		"query": {`sum(rate({app="a"}[5m]))`},
+		"start": {"1577836800000000000"},
+		"end":   {"1577840400000000000"},
+		"step":  {"60"},
+		"limit": {"100"},
+	}.Encode()}
+
+	trq, err := client.ParseTimeRangeQuery(&http.Request{URL: u})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if trq.Step != time.Minute {
+		t.Errorf("expected %s got %s", time.Minute, trq.Step)
+	}
+
+	if trq.Extent.Start.Unix() != 1577836800 || trq.Extent.End.Unix() != 1577840400 {
+		t.Errorf("unexpected extent %s", trq.Extent)
+	}
+
+	const expected = "limit=100&query=sum%28rate%28%7Bapp%3D%22a%22%7D%5B5m%5D%29%29&step=60"
+	if trq.TemplateURL.RawQuery != expected {
+		t.Errorf("expected %s got %s", expected, trq.TemplateURL.RawQuery)
+	}
+
+	// with no step or time range, Loki's defaults are used
+	u.RawQuery = url.Values{"query": {`count_over_time({app="a"}[1m]) offset 1h`}}.Encode()
+	trq, err = client.ParseTimeRangeQuery(&http.Request{URL: u})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := trq.Extent.End.Sub(trq.Extent.Start); d != defaultRange {
+		t.Errorf("expected %s got %s", defaultRange, d)
+	}
+
+	if trq.Step != 14*time.Second {
+		t.Errorf("expected %s got %s", 14*time.Second, trq.Step)
+	}
+
+	if !trq.IsOffset || !trq.FastForwardDisable {
+		t.Error("expected offset query to disable fast forward")
+	}
+
+	tests := []struct {
+		query string
+		err   error
+	}{
+		{"", errors.MissingURLParam(upQuery)},
+		{`query={app="a"}`, errors.ErrNotTimeRangeQuery},
+		{"query=rate(a[1m])&start=invalid", nil},
+		{"query=rate(a[1m])&end=invalid", nil},
+		{"query=rate(a[1m])&start=1577840400&end=1577836800", errors.ErrNotTimeRangeQuery},
+		{"query=rate(a[1m])&step=invalid", nil},
+		{"query=rate(a[1m])&step=0.5", errors.ErrStepParse},
+	}
+
+	for _, test := range tests {
+		u.RawQuery = test.query
+		_, err := client.ParseTimeRangeQuery(&http.Request{URL: u})
+		if err == nil {
+			t.Errorf("query %s: expected error", test.query)
+		} else if test.err != nil && err.Error() != test.err.Error() {
+			t.Errorf("query %s: expected %v got %v", test.query, test.err, err)
+		}
+	}
+}
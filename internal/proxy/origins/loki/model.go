@@ -0,0 +1,110 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package loki
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/Comcast/trickster/internal/proxy/origins/prometheus"
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+// Loki returns the results of metric queries in the same matrix and vector
+// formats as the Prometheus HTTP API, so the Prometheus models are used here.
+
+// MarshalTimeseries converts a Timeseries into a JSON blob
+func (c *Client) MarshalTimeseries(ts timeseries.Timeseries) ([]byte, error) {
+	// Marshal the Envelope back to a json object for Cache Storage
+	return json.Marshal(ts)
+}
+
+// UnmarshalTimeseries converts a JSON blob into a Timeseries
+func (c *Client) UnmarshalTimeseries(data []byte) (timeseries.Timeseries, error) {
+	me := &prometheus.MatrixEnvelope{}
+	err := json.Unmarshal(data, &me)
+	return me, err
+}
+
+// UnmarshalInstantaneous converts a JSON blob into an Instantaneous Data Point
+func (c *Client) UnmarshalInstantaneous(data []byte) (timeseries.Timeseries, error) {
+	ve := &prometheus.VectorEnvelope{}
+	err := json.Unmarshal(data, &ve)
+	if err != nil {
+		return nil, err
+	}
+	return ve.ToMatrix(), nil
+}
+
+// stream is a log stream in a Loki streams result
+type stream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// filterStreams removes the log lines of a Loki streams response that are outside of
+// [start, end), and returns the filtered response along with the number of log lines in
+// the original response. Fields other than the result are passed through unmodified.
+func filterStreams(body []byte, start, end time.Time) ([]byte, int, error) {
+	var envelope, data map[string]json.RawMessage
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, 0, err
+	}
+	if err := json.Unmarshal(envelope["data"], &data); err != nil {
+		return nil, 0, err
+	}
+	var resultType string
+	if err := json.Unmarshal(data["resultType"], &resultType); err != nil {
+		return nil, 0, err
+	}
+	if resultType != "streams" {
+		return body, 0, nil
+	}
+	var streams []stream
+	if err := json.Unmarshal(data["result"], &streams); err != nil {
+		return nil, 0, err
+	}
+
+	s, e := start.UnixNano(), end.UnixNano()
+	n := 0
+	filtered := make([]stream, 0, len(streams))
+	for _, st := range streams {
+		values := make([][2]string, 0, len(st.Values))
+		for _, v := range st.Values {
+			n++
+			ts, err := strconv.ParseInt(v[0], 10, 64)
+			if err != nil {
+				return nil, 0, err
+			}
+			if ts >= s && ts < e {
+				values = append(values, v)
+			}
+		}
+		if len(values) > 0 {
+			st.Values = values
+			filtered = append(filtered, st)
+		}
+	}
+
+	var err error
+	if data["result"], err = json.Marshal(filtered); err != nil {
+		return nil, 0, err
+	}
+	if envelope["data"], err = json.Marshal(data); err != nil {
+		return nil, 0, err
+	}
+	b, err := json.Marshal(envelope)
+	return b, n, err
+}
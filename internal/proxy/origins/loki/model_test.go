@@ -0,0 +1,106 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package loki
+
+import (
+	"testing"
+	"time"
+)
+
+const testMatrix = `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"app":"a"},"values":[[1577836800,"1"],[1577836860,"2"]]}],"stats":{}}}`
+
+func TestUnmarshalTimeseries(t *testing.T) {
+
+	client := &Client{}
+	ts, err := client.UnmarshalTimeseries([]byte(testMatrix))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ts.SeriesCount() != 1 || ts.ValueCount() != 2 {
+		t.Errorf("expected 1 series with 2 values got %d with %d", ts.SeriesCount(), ts.ValueCount())
+	}
+
+	ts.SetStep(time.Minute)
+	b, err := client.MarshalTimeseries(ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts, err = client.UnmarshalTimeseries(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ts.Step() != time.Minute {
+		t.Errorf("expected %s got %s", time.Minute, ts.Step())
+	}
+
+	_, err = client.UnmarshalTimeseries([]byte("["))
+	if err == nil {
+		t.Error("expected error for invalid json")
+	}
+}
+
+func TestUnmarshalInstantaneous(t *testing.T) {
+
+	client := &Client{}
+	ts, err := client.UnmarshalInstantaneous([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{"app":"a"},"value":[1577836800,"1"]}]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if x := ts.Extents(); len(x) != 1 || x[0].End.Unix() != 1577836800 {
+		t.Errorf("unexpected extents %s", x)
+	}
+
+	_, err = client.UnmarshalInstantaneous([]byte("["))
+	if err == nil {
+		t.Error("expected error for invalid json")
+	}
+}
+
+func TestFilterStreams(t *testing.T) {
+
+	const body = `{"status":"success","data":{"resultType":"streams","result":[` +
+		`{"stream":{"app":"a"},"values":[["1577836830000000000","c"],["1577836810000000000","b"],["1577836790000000000","a"]]},` +
+		`{"stream":{"app":"b"},"values":[["1577836850000000000","d"]]}],"stats":{"summary":{}}}}`
+	const expected = `{"data":{"result":[{"stream":{"app":"a"},"values":[["1577836830000000000","c"],["1577836810000000000","b"]]}],` +
+		`"resultType":"streams","stats":{"summary":{}}},"status":"success"}`
+
+	b, n, err := filterStreams([]byte(body), time.Unix(1577836800, 0), time.Unix(1577836850, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 4 {
+		t.Errorf("expected %d got %d", 4, n)
+	}
+	if string(b) != expected {
+		t.Errorf("expected %s got %s", expected, b)
+	}
+
+	// non-stream results are returned as-is
+	b, _, err = filterStreams([]byte(testMatrix), time.Unix(0, 0), time.Unix(0, 0))
+	if err != nil || string(b) != testMatrix {
+		t.Errorf("expected %s got %s", testMatrix, b)
+	}
+
+	for _, s := range []string{"[", `{"data":[]}`, `{"data":{"resultType":1}}`,
+		`{"data":{"resultType":"streams","result":{}}}`,
+		`{"data":{"resultType":"streams","result":[{"values":[["x","a"]]}]}}`} {
+		if _, _, err = filterStreams([]byte(s), time.Unix(0, 0), time.Unix(0, 0)); err == nil {
+			t.Errorf("expected error for %s", s)
+		}
+	}
+}
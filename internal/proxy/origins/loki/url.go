@@ -0,0 +1,102 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package loki
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Comcast/trickster/internal/proxy/urls"
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+// bucketSize is the interval to which the time range of log stream, label
+// and series requests are expanded, so that similar requests share a cache key
+const bucketSize = time.Minute
+
+// BaseURL returns a URL in the form of scheme://host/path based on the proxy configuration
+func (c *Client) BaseURL() *url.URL {
+	u := &url.URL{}
+	u.Scheme = c.config.Scheme
+	u.Host = c.config.Host
+	u.Path = c.config.PathPrefix
+	return u
+}
+
+// BuildUpstreamURL will merge the downstream request with the BaseURL to construct the full upstream URL
+func (c *Client) BuildUpstreamURL(r *http.Request) *url.URL {
+	u := c.BaseURL()
+
+	if strings.HasPrefix(r.URL.Path, "/"+c.name+"/") {
+		u.Path += strings.Replace(r.URL.Path, "/"+c.name+"/", "/", 1)
+	} else {
+		u.Path += r.URL.Path
+	}
+
+	u.RawQuery = r.URL.RawQuery
+	u.Fragment = r.URL.Fragment
+	u.User = r.URL.User
+	return u
+}
+
+// SetExtent will change the upstream request query to use the provided Extent
+func (c *Client) SetExtent(r *http.Request, trq *timeseries.TimeRangeQuery, extent *timeseries.Extent) {
+	params := r.URL.Query()
+	params.Set(upStart, strconv.FormatInt(extent.Start.UnixNano(), 10))
+	params.Set(upEnd, strconv.FormatInt(extent.End.UnixNano(), 10))
+	params.Set(upStep, formatStep(trq.Step))
+	r.URL.RawQuery = params.Encode()
+}
+
+// FastForwardURL returns the url to fetch the Fast Forward value based on a timerange url
+func (c *Client) FastForwardURL(r *http.Request) (*url.URL, error) {
+
+	u := urls.Clone(r.URL)
+
+	if strings.HasSuffix(u.Path, "/"+mnQueryRange) {
+		u.Path = u.Path[0:len(u.Path)-len(mnQueryRange)] + mnQuery
+	}
+
+	p := u.Query()
+	p.Del(upStart)
+	p.Del(upEnd)
+	p.Del(upStep)
+	u.RawQuery = p.Encode()
+
+	return u, nil
+}
+
+// bucketTimeRange expands the start and end parameters of the provided URL
+// outward to the nearest bucket boundaries, so the time range covers at least
+// what was requested. Unparsable values are left as-is.
+func bucketTimeRange(u *url.URL) {
+	params := u.Query()
+	if p := params.Get(upStart); p != "" {
+		if t, err := parseTime(p); err == nil {
+			params.Set(upStart, strconv.FormatInt(t.Truncate(bucketSize).UnixNano(), 10))
+		}
+	}
+	if p := params.Get(upEnd); p != "" {
+		if t, err := parseTime(p); err == nil {
+			if tt := t.Truncate(bucketSize); tt.Before(t) {
+				t = tt.Add(bucketSize)
+			}
+			params.Set(upEnd, strconv.FormatInt(t.UnixNano(), 10))
+		}
+	}
+	u.RawQuery = params.Encode()
+}
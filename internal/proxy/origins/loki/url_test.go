@@ -0,0 +1,105 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package loki
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Comcast/trickster/internal/config"
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+func TestSetExtent(t *testing.T) {
+
+	start := time.Unix(1577836800, 0)
+	end := time.Unix(1577840400, 0)
+	const expected = "end=1577840400000000000&query=rate%28a%5B1m%5D%29&start=1577836800000000000&step=15"
+
+	client := &Client{}
+	u := &url.URL{RawQuery: "query=rate%28a%5B1m%5D%29"}
+	e := &timeseries.Extent{Start: start, End: end}
+
+	r, _ := http.NewRequest(http.MethodGet, u.String(), nil)
+	trq := &timeseries.TimeRangeQuery{TemplateURL: u, Step: 15 * time.Second}
+
+	client.SetExtent(r, trq, e)
+	if expected != r.URL.RawQuery {
+		t.Errorf("\nexpected [%s]\ngot      [%s]", expected, r.URL.RawQuery)
+	}
+}
+
+func TestFastForwardURL(t *testing.T) {
+
+	const expected = "/loki/api/v1/query?query=rate%28a%5B1m%5D%29"
+
+	client := &Client{}
+	u := &url.URL{Path: "/loki/api/v1/query_range", RawQuery: "query=rate%28a%5B1m%5D%29&start=1&end=2&step=15"}
+	r, _ := http.NewRequest(http.MethodGet, u.String(), nil)
+
+	ffu, err := client.FastForwardURL(r)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if ffu.String() != expected {
+		t.Errorf("\nexpected [%s]\ngot      [%s]", expected, ffu.String())
+	}
+}
+
+func TestBucketTimeRange(t *testing.T) {
+
+	u := &url.URL{RawQuery: "start=1577836830&end=1577840430"}
+	bucketTimeRange(u)
+
+	const expected = "end=1577840460000000000&start=1577836800000000000"
+	if u.RawQuery != expected {
+		t.Errorf("\nexpected [%s]\ngot      [%s]", expected, u.RawQuery)
+	}
+
+	// times already on a bucket boundary are unchanged
+	bucketTimeRange(u)
+	if u.RawQuery != expected {
+		t.Errorf("\nexpected [%s]\ngot      [%s]", expected, u.RawQuery)
+	}
+
+	u.RawQuery = "start=invalid&end=invalid"
+	bucketTimeRange(u)
+	if u.RawQuery != "end=invalid&start=invalid" {
+		t.Errorf("expected unparsable times to be unchanged got %s", u.RawQuery)
+	}
+}
+
+func TestBuildUpstreamURL(t *testing.T) {
+
+	cfg := config.NewConfig()
+	oc := cfg.Origins["default"]
+	oc.Scheme = "http"
+	oc.Host = "0"
+	oc.PathPrefix = ""
+
+	client := &Client{name: "default", config: oc}
+	r, err := http.NewRequest(http.MethodGet, "http://0/default/loki/api/v1/query_range?query=rate%28a%5B1m%5D%29", nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	u := client.BuildUpstreamURL(r)
+	if u.Path != "/loki/api/v1/query_range" {
+		t.Errorf("expected %s got %s", "/loki/api/v1/query_range", u.Path)
+	}
+
+}
@@ -0,0 +1,111 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package opentsdb
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/trickster/internal/proxy/request"
+	tu "github.com/Comcast/trickster/internal/util/testing"
+	"github.com/Comcast/trickster/pkg/opentsdbsim"
+)
+
+func TestQueryHandler(t *testing.T) {
+
+	client := &Client{name: "test"}
+	end := time.Now().Truncate(time.Minute)
+	start := end.Add(-time.Hour)
+	q := url.Values{"m": {"sum:1m-avg:sys.cpu.user{series_count=2}"}, "start": {strconv.FormatInt(start.Unix(), 10)},
+		"end": {strconv.FormatInt(end.Unix(), 10)}}
+
+	ts, w, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, "", nil, "opentsdbsim", "/api/query?"+q.Encode(), "debug")
+	ctx := r.Context()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	defer ts.Close()
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, ok := client.config.Paths[APIPath+mnQuery]
+	if !ok {
+		t.Errorf("could not find path config named %s", APIPath+mnQuery)
+	}
+
+	client.QueryHandler(w, r)
+	resp := w.Result()
+
+	// it should return 200 OK
+	if resp.StatusCode != 200 {
+		t.Errorf("expected 200 got %d.", resp.StatusCode)
+	}
+
+	if h := resp.Header.Get("X-Trickster-Result"); !strings.Contains(h, "engine=DeltaProxyCache") || !strings.Contains(h, "status=kmiss") {
+		t.Errorf("expected delta proxy cache key miss got %s", h)
+	}
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// the response matches that of the simulator for the same time range
+	sq := opentsdbsim.ParseSubQuery(q.Get("m"))
+	expected, _, _ := opentsdbsim.GetQueryData([]opentsdbsim.SubQuery{sq}, start, end, false)
+	rs, _ := unmarshalResultSet([]byte(expected))
+	rs2, err := unmarshalResultSet(bodyBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(rs2.JSON()) != string(rs.JSON()) {
+		t.Errorf("\nexpected [%s]\ngot      [%s]", rs.JSON(), rs2.JSON())
+	}
+
+	// a later time range is a partial hit, served the same way via a POST body
+	body := `{"start":` + strconv.FormatInt(start.Add(30*time.Minute).Unix(), 10) +
+		`,"end":` + strconv.FormatInt(end.Add(10*time.Minute).Unix(), 10) +
+		`,"queries":[{"aggregator":"sum","metric":"sys.cpu.user","downsample":"1m-avg","tags":{"series_count":"2"}}]}`
+	r = httptest.NewRequest(http.MethodPost, ts.URL+"/api/query", strings.NewReader(body))
+	r = r.WithContext(ctx)
+	w = httptest.NewRecorder()
+
+	client.QueryHandler(w, r)
+	resp = w.Result()
+	if resp.StatusCode != 200 {
+		t.Errorf("expected 200 got %d.", resp.StatusCode)
+	}
+
+	if h := resp.Header.Get("X-Trickster-Result"); !strings.Contains(h, "engine=DeltaProxyCache") {
+		t.Errorf("expected delta proxy cache engine got %s", h)
+	}
+
+	bodyBytes, _ = ioutil.ReadAll(resp.Body)
+	rs2, err = unmarshalResultSet(bodyBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs2.SeriesCount() != 2 {
+		t.Errorf("expected %d got %d", 2, rs2.SeriesCount())
+	}
+}
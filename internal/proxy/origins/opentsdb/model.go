@@ -0,0 +1,223 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package opentsdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+// Query response field names with special meaning to Trickster
+const (
+	fnMetric        = "metric"
+	fnTags          = "tags"
+	fnAggregateTags = "aggregateTags"
+	fnDataPoints    = "dps"
+)
+
+// envelopePrefix identifies a ResultSet that was marshaled for cache storage
+var envelopePrefix = []byte(`{"series":`)
+
+// ResultSet represents a response from the OpenTSDB /api/query endpoint
+type ResultSet struct {
+	Series       []*Series
+	StepDuration time.Duration
+	ExtentList   timeseries.ExtentList
+	// Millis is true when datapoint timestamps are keyed in milliseconds rather than seconds
+	Millis bool
+
+	timestamps map[time.Time]bool // tracks unique timestamps in the result set
+	isSorted   bool               // tracks if the result set is currently sorted
+	isCounted  bool               // tracks if timestamps map is up-to-date
+}
+
+// Series represents a single time series of a ResultSet. All fields other than
+// the datapoints, such as the metric name and tags, are carried as-is.
+type Series struct {
+	Fields map[string]json.RawMessage
+	Points []Point
+
+	key string
+}
+
+// Point represents a single datapoint of a Series
+type Point struct {
+	Timestamp time.Time
+	Value     json.RawMessage
+}
+
+// resultSetEnvelope is the cached representation of a ResultSet, which carries the step and extents
+type resultSetEnvelope struct {
+	Series       json.RawMessage       `json:"series"`
+	Millis       bool                  `json:"ms,omitempty"`
+	StepDuration time.Duration         `json:"step,omitempty"`
+	ExtentList   timeseries.ExtentList `json:"extents,omitempty"`
+}
+
+// MarshalTimeseries converts a Timeseries into a JSON blob
+func (c *Client) MarshalTimeseries(ts timeseries.Timeseries) ([]byte, error) {
+	rs, ok := ts.(*ResultSet)
+	if !ok {
+		return nil, fmt.Errorf("unsupported timeseries type: %T", ts)
+	}
+	return rs.marshal()
+}
+
+// UnmarshalTimeseries converts a JSON blob into a Timeseries
+func (c *Client) UnmarshalTimeseries(data []byte) (timeseries.Timeseries, error) {
+	return unmarshalResultSet(data)
+}
+
+// unmarshalResultSet converts a cached ResultSet or a query response body into a ResultSet
+func unmarshalResultSet(data []byte) (*ResultSet, error) {
+
+	if bytes.HasPrefix(data, envelopePrefix) {
+		re := &resultSetEnvelope{}
+		if err := json.Unmarshal(data, re); err != nil {
+			return nil, err
+		}
+		rs, err := parseSeriesList(re.Series)
+		if err != nil {
+			return nil, err
+		}
+		rs.Millis = rs.Millis || re.Millis
+		rs.StepDuration = re.StepDuration
+		rs.ExtentList = re.ExtentList
+		return rs, nil
+	}
+
+	return parseSeriesList(data)
+}
+
+func parseSeriesList(data []byte) (*ResultSet, error) {
+
+	var list []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+
+	rs := &ResultSet{Series: make([]*Series, len(list))}
+	occurrences := make(map[string]int)
+
+	for i, fields := range list {
+		dps := make(map[string]json.RawMessage)
+		if raw, ok := fields[fnDataPoints]; ok {
+			if err := json.Unmarshal(raw, &dps); err != nil {
+				return nil, err
+			}
+			delete(fields, fnDataPoints)
+		}
+		s := &Series{Fields: fields, Points: make([]Point, 0, len(dps))}
+		for k, v := range dps {
+			n, err := strconv.ParseInt(k, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid datapoint timestamp: %s", k)
+			}
+			var t time.Time
+			if len(k) > 10 {
+				t = time.Unix(0, n*int64(time.Millisecond))
+				rs.Millis = true
+			} else {
+				t = time.Unix(n, 0)
+			}
+			s.Points = append(s.Points, Point{Timestamp: t.UTC(), Value: v})
+		}
+		sort.Slice(s.Points, func(i, j int) bool { return s.Points[i].Timestamp.Before(s.Points[j].Timestamp) })
+
+		// sub queries may return series with the same identity, which are told apart by their order
+		id := s.identity()
+		s.key = id + "\x00" + strconv.Itoa(occurrences[id])
+		occurrences[id]++
+		rs.Series[i] = s
+	}
+
+	return rs, nil
+}
+
+// identity returns a string identifying the Series by its metric name, tags and aggregated tags
+func (s *Series) identity() string {
+	var metric string
+	json.Unmarshal(s.Fields[fnMetric], &metric)
+	tags := make(map[string]string)
+	json.Unmarshal(s.Fields[fnTags], &tags)
+	var aggTags []string
+	json.Unmarshal(s.Fields[fnAggregateTags], &aggTags)
+
+	pairs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	sort.Strings(aggTags)
+
+	return metric + "{" + strings.Join(pairs, ",") + "}" + strings.Join(aggTags, ",")
+}
+
+// JSON returns the ResultSet as an /api/query response body
+func (rs *ResultSet) JSON() []byte {
+
+	buf := &bytes.Buffer{}
+	buf.WriteByte('[')
+	for i, s := range rs.Series {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteByte('{')
+		keys := make([]string, 0, len(s.Fields))
+		for k := range s.Fields {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			kb, _ := json.Marshal(k)
+			buf.Write(kb)
+			buf.WriteByte(':')
+			buf.Write(s.Fields[k])
+			buf.WriteByte(',')
+		}
+		// datapoints are written in chronological order, as they are by OpenTSDB
+		buf.WriteString(`"` + fnDataPoints + `":{`)
+		for j, p := range s.Points {
+			if j > 0 {
+				buf.WriteByte(',')
+			}
+			ts := p.Timestamp.Unix()
+			if rs.Millis {
+				ts = p.Timestamp.UnixNano() / int64(time.Millisecond)
+			}
+			buf.WriteString(`"` + strconv.FormatInt(ts, 10) + `":`)
+			buf.Write(p.Value)
+		}
+		buf.WriteString("}}")
+	}
+	buf.WriteByte(']')
+	return buf.Bytes()
+}
+
+// marshal returns the ResultSet as an /api/query response body for client responses,
+// or as a JSON envelope that carries the step and extents for cache storage
+func (rs *ResultSet) marshal() ([]byte, error) {
+	if len(rs.ExtentList) == 0 && rs.StepDuration == 0 {
+		return rs.JSON(), nil
+	}
+	return json.Marshal(&resultSetEnvelope{Series: rs.JSON(), Millis: rs.Millis,
+		StepDuration: rs.StepDuration, ExtentList: rs.ExtentList})
+}
@@ -0,0 +1,159 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package opentsdb
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+// testResponse returns a query response with a series for each of the provided hosts,
+// with datapoints each minute from start to end (in seconds)
+func testResponse(hosts []string, start, end int64) string {
+	series := make([]string, len(hosts))
+	for i, h := range hosts {
+		dps := make([]string, 0, (end-start)/60+1)
+		for ts := start; ts <= end; ts += 60 {
+			dps = append(dps, fmt.Sprintf(`"%d":%d`, ts, ts/60))
+		}
+		series[i] = fmt.Sprintf(`{"aggregateTags":["cpu"],"metric":"sys.cpu.user","tags":{"host":"%s"},"dps":{%s}}`,
+			h, strings.Join(dps, ","))
+	}
+	return "[" + strings.Join(series, ",") + "]"
+}
+
+func testResultSet(t *testing.T, hosts []string, start, end int64) *ResultSet {
+	rs, err := unmarshalResultSet([]byte(testResponse(hosts, start, end)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.SetStep(time.Minute)
+	rs.SetExtents(timeseries.ExtentList{{Start: time.Unix(start, 0), End: time.Unix(end, 0)}})
+	return rs
+}
+
+func TestUnmarshalTimeseries(t *testing.T) {
+
+	client := &Client{}
+	body := testResponse([]string{"a", "b"}, 600, 720)
+	ts, err := client.UnmarshalTimeseries([]byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rs := ts.(*ResultSet)
+	if rs.SeriesCount() != 2 || rs.ValueCount() != 6 || rs.Millis {
+		t.Errorf("expected 2 series with 6 values got %d with %d", rs.SeriesCount(), rs.ValueCount())
+	}
+
+	b, err := client.MarshalTimeseries(rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != body {
+		t.Errorf("\nexpected [%s]\ngot      [%s]", body, b)
+	}
+
+	// cached result sets retain the step and extents
+	rs.SetStep(time.Minute)
+	rs.SetExtents(timeseries.ExtentList{{Start: time.Unix(600, 0), End: time.Unix(720, 0)}})
+	b, err = client.MarshalTimeseries(rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ts, err = client.UnmarshalTimeseries(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ts.Step() != time.Minute || len(ts.Extents()) != 1 || ts.ValueCount() != 6 {
+		t.Errorf("unexpected cached result set %s", b)
+	}
+
+	_, err = client.MarshalTimeseries(nil)
+	if err == nil {
+		t.Error("expected error for unsupported timeseries")
+	}
+}
+
+func TestUnmarshalMillis(t *testing.T) {
+
+	const body = `[{"metric":"a","dps":{"1577836800500":1,"1577836800000":0.5}}]`
+	rs, err := unmarshalResultSet([]byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !rs.Millis {
+		t.Error("expected millisecond resolution")
+	}
+
+	// datapoints are sorted
+	const expected = `[{"metric":"a","dps":{"1577836800000":0.5,"1577836800500":1}}]`
+	if b := rs.JSON(); string(b) != expected {
+		t.Errorf("expected %s got %s", expected, b)
+	}
+
+	// the resolution is retained in the cache when there are no datapoints
+	rs.Series[0].Points = nil
+	rs.SetStep(time.Second)
+	b, _ := rs.marshal()
+	rs, err = unmarshalResultSet(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rs.Millis {
+		t.Error("expected millisecond resolution")
+	}
+}
+
+func TestSeriesKeys(t *testing.T) {
+
+	// tag order doesn't matter, and series with the same identity are keyed by their order
+	const body = `[{"metric":"a","tags":{"x":"1","y":"2"}},{"metric":"a","tags":{"y":"2","x":"1"}},{"metric":"a"}]`
+	rs, err := unmarshalResultSet([]byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rs.Series[0].key == rs.Series[1].key || rs.Series[0].key[:len(rs.Series[0].key)-1] != rs.Series[1].key[:len(rs.Series[1].key)-1] {
+		t.Errorf("unexpected keys %q and %q", rs.Series[0].key, rs.Series[1].key)
+	}
+
+	if rs.Series[0].key == rs.Series[2].key {
+		t.Errorf("unexpected key %q", rs.Series[2].key)
+	}
+}
+
+func TestUnmarshalResultSetErrors(t *testing.T) {
+
+	tests := []string{
+		`{"error":{"code":400,"message":"No such name for 'metrics'"}}`,
+		`{"series":[`,
+		`{"series":{}}`,
+		`[{"metric":"a","dps":[]}]`,
+		`[{"metric":"a","dps":{"x":1}}]`,
+	}
+
+	for _, test := range tests {
+		if _, err := unmarshalResultSet([]byte(test)); err == nil {
+			t.Errorf("expected error for %s", test)
+		}
+	}
+}
@@ -0,0 +1,381 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package opentsdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Comcast/trickster/internal/proxy/errors"
+	"github.com/Comcast/trickster/internal/proxy/urls"
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+// This file handles parsing of the time range and downsample interval of
+// OpenTSDB /api/query requests for cache key hashing and delta proxy caching.
+
+// Query request body field names with special meaning to Trickster
+const (
+	fnStart        = "start"
+	fnEnd          = "end"
+	fnTimeZone     = "timezone"
+	fnQueries      = "queries"
+	fnDownsample   = "downsample"
+	fnMSResolution = "msResolution"
+	fnShowSummary  = "showSummary"
+	fnShowStats    = "showStats"
+	fnDelete       = "delete"
+	fnUseCalendar  = "useCalendar"
+)
+
+// OpenTSDB's fixed lengths for month and year units in relative times
+const (
+	month = 30 * 24 * time.Hour
+	year  = 365 * 24 * time.Hour
+)
+
+var reDuration *regexp.Regexp
+
+func init() {
+	// Regexp for parsing an OpenTSDB duration, such as 5m in 5m-avg or 1h-ago
+	reDuration = regexp.MustCompile(`^([0-9]+)(ms|s|m|h|d|w|n|y)$`)
+}
+
+// absoluteLayouts are the formatted absolute times accepted by OpenTSDB
+var absoluteLayouts = []string{
+	"2006/01/02-15:04:05",
+	"2006/01/02 15:04:05",
+	"2006/01/02-15:04",
+	"2006/01/02 15:04",
+	"2006/01/02",
+}
+
+// parseQueryURL parses the key parts of a TimeRangeQuery from a GET /api/query request
+func parseQueryURL(u *url.URL) (*timeseries.TimeRangeQuery, error) {
+
+	qi := u.Query()
+
+	// summaries, stats and array-formatted datapoints can't be merged
+	for _, p := range []string{upArrays, upShowSummary, upShowStats} {
+		if _, ok := qi[p]; ok {
+			return nil, errors.ErrNotTimeRangeQuery
+		}
+	}
+
+	metrics, tsuids := qi[upMetric], qi[upTSUID]
+	if len(metrics) == 0 && len(tsuids) == 0 {
+		return nil, errors.MissingURLParam(upMetric)
+	}
+
+	downsamples := make([]string, 0, len(metrics)+len(tsuids))
+	for _, q := range append(append([]string{}, metrics...), tsuids...) {
+		downsamples = append(downsamples, subQueryDownsample(q))
+	}
+	_, ms := qi[upMS]
+	step, err := parseStep(downsamples, ms)
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := loadLocation(qi.Get(upTimeZone))
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := parseExtent(qi.Get(upStart), qi.Get(upEnd), loc)
+	if err != nil {
+		return nil, err
+	}
+
+	trq := &timeseries.TimeRangeQuery{
+		Statement: strings.Join(append(append([]string{}, metrics...), tsuids...), "\n"),
+		Extent:    e,
+		Step:      step,
+		// OpenTSDB has no instantaneous query for the latest datapoint
+		FastForwardDisable: true,
+	}
+
+	// the cache key ignores the time range and sees every sub query, since
+	// only the first value of a multi-valued parameter is considered
+	trq.TemplateURL = urls.Clone(u)
+	qt := trq.TemplateURL.Query()
+	qt.Del(upStart)
+	qt.Del(upEnd)
+	if len(metrics) > 0 {
+		qt.Set(upMetric, strings.Join(metrics, "\n"))
+	}
+	if len(tsuids) > 0 {
+		qt.Set(upTSUID, strings.Join(tsuids, "\n"))
+	}
+	trq.TemplateURL.RawQuery = qt.Encode()
+
+	return trq, nil
+}
+
+// parseQueryBody parses the key parts of a TimeRangeQuery from a POST /api/query request
+func parseQueryBody(r *http.Request) (*timeseries.TimeRangeQuery, error) {
+
+	if r.Body == nil {
+		return nil, errors.MissingRequestParam(upQueryBody)
+	}
+
+	b, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		return nil, errors.ParseRequestBody(err)
+	}
+
+	body := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(b, &body); err != nil {
+		return nil, errors.ParseRequestBody(err)
+	}
+
+	for _, f := range []string{fnShowSummary, fnShowStats, fnDelete, fnUseCalendar} {
+		var v bool
+		if raw, ok := body[f]; ok && json.Unmarshal(raw, &v) == nil && v {
+			return nil, errors.ErrNotTimeRangeQuery
+		}
+	}
+
+	var queries []map[string]json.RawMessage
+	if err := json.Unmarshal(body[fnQueries], &queries); err != nil || len(queries) == 0 {
+		return nil, errors.MissingRequestParam(fnQueries)
+	}
+
+	downsamples := make([]string, len(queries))
+	for i, q := range queries {
+		json.Unmarshal(q[fnDownsample], &downsamples[i])
+	}
+	var ms bool
+	json.Unmarshal(body[fnMSResolution], &ms)
+	step, err := parseStep(downsamples, ms)
+	if err != nil {
+		return nil, err
+	}
+
+	var tz string
+	json.Unmarshal(body[fnTimeZone], &tz)
+	loc, err := loadLocation(tz)
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := parseExtent(bodyTime(body[fnStart]), bodyTime(body[fnEnd]), loc)
+	if err != nil {
+		return nil, err
+	}
+
+	// the remainder of the request is the statement, so it is factored into
+	// the cache key, and reassembled with each extent for upstream requests
+	delete(body, fnStart)
+	delete(body, fnEnd)
+	statement, _ := json.Marshal(body)
+
+	trq := &timeseries.TimeRangeQuery{
+		Statement:          string(statement),
+		Extent:             e,
+		Step:               step,
+		FastForwardDisable: true,
+	}
+
+	trq.TemplateURL = urls.Clone(r.URL)
+	qt := trq.TemplateURL.Query()
+	qt.Set(upQueryBody, trq.Statement)
+	trq.TemplateURL.RawQuery = qt.Encode()
+
+	return trq, nil
+}
+
+// bodyTime returns the string form of a start or end value of a request body,
+// which may be a number or a string
+func bodyTime(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var n json.Number
+	if json.Unmarshal(raw, &n) == nil {
+		return n.String()
+	}
+	return ""
+}
+
+// subQueryDownsample returns the downsample specifier of an m or tsuid sub query, such as
+// 1m-avg in sum:1m-avg:rate:sys.cpu.user{host=a}, or an empty string if there is none
+func subQueryDownsample(q string) string {
+	if i := strings.Index(q, "{"); i >= 0 {
+		q = q[:i]
+	}
+	parts := strings.Split(q, ":")
+	for i := 1; i < len(parts)-1; i++ {
+		if p := parts[i]; p != "" && p[0] >= '0' && p[0] <= '9' && strings.Contains(p, "-") {
+			return p
+		}
+	}
+	return ""
+}
+
+// parseStep returns the downsample interval shared by all sub queries
+func parseStep(downsamples []string, ms bool) (time.Duration, error) {
+
+	var step time.Duration
+	for i, ds := range downsamples {
+		if ds == "" {
+			return 0, errors.ErrStepParse
+		}
+		d, err := parseDownsample(ds)
+		if err != nil {
+			return 0, err
+		}
+		if i > 0 && d != step {
+			return 0, errors.ErrNotTimeRangeQuery
+		}
+		step = d
+	}
+
+	// without millisecond resolution, datapoints are keyed by the second
+	if step <= 0 || (!ms && step%time.Second != 0) {
+		return 0, errors.ErrStepParse
+	}
+
+	return step, nil
+}
+
+// parseDownsample returns the interval of a downsample specifier like 1m-avg or 1h-sum-zero.
+// Calendar-aligned intervals (e.g., 1dc-sum), and those with no fixed length, aren't supported.
+func parseDownsample(ds string) (time.Duration, error) {
+	parts := strings.Split(ds, "-")
+	if len(parts) < 2 {
+		return 0, errors.ErrStepParse
+	}
+	m := reDuration.FindStringSubmatch(parts[0])
+	if m == nil || m[2] == "n" || m[2] == "y" {
+		return 0, errors.ErrNotTimeRangeQuery
+	}
+	return parseDuration(parts[0])
+}
+
+// parseDuration parses an OpenTSDB duration, such as 30s or 1h
+func parseDuration(s string) (time.Duration, error) {
+	m := reDuration.FindStringSubmatch(s)
+	if m == nil {
+		return errors.ParseDuration(s)
+	}
+	n, _ := strconv.ParseInt(m[1], 10, 64)
+	var unit time.Duration
+	switch m[2] {
+	case "ms":
+		unit = time.Millisecond
+	case "s":
+		unit = time.Second
+	case "m":
+		unit = time.Minute
+	case "h":
+		unit = time.Hour
+	case "d":
+		unit = 24 * time.Hour
+	case "w":
+		unit = 7 * 24 * time.Hour
+	case "n":
+		unit = month
+	case "y":
+		unit = year
+	}
+	return time.Duration(n) * unit, nil
+}
+
+func loadLocation(tz string) (*time.Location, error) {
+	if tz == "" {
+		return time.UTC, nil
+	}
+	return time.LoadLocation(tz)
+}
+
+// parseExtent returns the extent of a query from its start and end values. The start
+// is required, while the end defaults to now, as in OpenTSDB.
+func parseExtent(start, end string, loc *time.Location) (timeseries.Extent, error) {
+
+	var e timeseries.Extent
+	if start == "" {
+		return e, errors.MissingURLParam(upStart)
+	}
+
+	now := time.Now()
+	var err error
+	if e.Start, err = parseTime(start, now, loc); err != nil {
+		return e, err
+	}
+
+	e.End = now
+	if end != "" {
+		if e.End, err = parseTime(end, now, loc); err != nil {
+			return e, err
+		}
+	}
+
+	if e.End.Before(e.Start) {
+		return e, errors.ErrNotTimeRangeQuery
+	}
+
+	return e, nil
+}
+
+// parseTime converts an OpenTSDB start or end value into a time. Supported values
+// are relative times (e.g., 1h-ago), Unix epochs in seconds (with up to 10 digits or
+// a fractional part) or milliseconds, and formatted absolute times in the provided location.
+func parseTime(s string, now time.Time, loc *time.Location) (time.Time, error) {
+
+	if s == "now" {
+		return now, nil
+	}
+
+	if strings.HasSuffix(s, "-ago") {
+		d, err := parseDuration(strings.TrimSuffix(s, "-ago"))
+		if err != nil {
+			return time.Time{}, err
+		}
+		return now.Add(-d), nil
+	}
+
+	if strings.Contains(s, ".") {
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return time.Unix(0, int64(math.Round(f*1000))*int64(time.Millisecond)), nil
+		}
+	}
+
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if len(s) <= 10 {
+			return time.Unix(i, 0), nil
+		}
+		return time.Unix(0, i*int64(time.Millisecond)), nil
+	}
+
+	for _, layout := range absoluteLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("cannot parse %q to a valid timestamp", s)
+}
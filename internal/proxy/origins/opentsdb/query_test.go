@@ -0,0 +1,249 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package opentsdb
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseQueryURL(t *testing.T) {
+
+	u, _ := url.Parse("http://0/api/query?start=1h-ago&m=sum:1m-avg:sys.cpu.user{host=a}" +
+		"&m=max:1m-max-zero:rate:sys.cpu.system&tsuid=sum:1m-avg:000001000001000001&ms")
+
+	now := time.Now()
+	trq, err := parseQueryURL(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if trq.Step != time.Minute {
+		t.Errorf("expected %s got %s", time.Minute, trq.Step)
+	}
+
+	if d := now.Sub(trq.Extent.Start); d < time.Hour-time.Minute || d > time.Hour+time.Minute {
+		t.Errorf("unexpected extent start %s", trq.Extent.Start)
+	}
+
+	if !trq.FastForwardDisable {
+		t.Error("expected fast forward to be disabled")
+	}
+
+	const expected = "sum:1m-avg:sys.cpu.user{host=a}\nmax:1m-max-zero:rate:sys.cpu.system\nsum:1m-avg:000001000001000001"
+	if trq.Statement != expected {
+		t.Errorf("expected %s got %s", expected, trq.Statement)
+	}
+
+	qt := trq.TemplateURL.Query()
+	if qt.Get(upStart) != "" || len(qt[upMetric]) != 1 || qt.Get(upTSUID) != "sum:1m-avg:000001000001000001" {
+		t.Errorf("unexpected template url %s", trq.TemplateURL)
+	}
+}
+
+func TestParseQueryURLErrors(t *testing.T) {
+
+	tests := []string{
+		"start=0",
+		"start=0&m=sum:sys.cpu.user",
+		"start=0&m=sum:1m-avg:a&m=sum:5m-avg:b",
+		"start=0&m=sum:1m-avg:a&m=sum:b",
+		"start=0&m=sum:1dc-avg:a",
+		"start=0&m=sum:0all-sum:a",
+		"start=0&m=sum:1n-avg:a",
+		"start=0&m=sum:500ms-avg:a",
+		"start=0&m=sum:0s-avg:a",
+		"start=0&m=sum:1m:a",
+		"start=0&m=sum:1m-avg:a&arrays=true",
+		"start=0&m=sum:1m-avg:a&show_summary",
+		"start=0&m=sum:1m-avg:a&show_stats",
+		"start=0&m=sum:1m-avg:a&tz=Invalid/Zone",
+		"m=sum:1m-avg:a",
+		"start=x&m=sum:1m-avg:a",
+		"start=0&end=x&m=sum:1m-avg:a",
+		"start=60&end=0&m=sum:1m-avg:a",
+	}
+
+	for _, test := range tests {
+		u := &url.URL{Path: "/api/query", RawQuery: test}
+		if _, err := parseQueryURL(u); err == nil {
+			t.Errorf("expected error for %s", test)
+		}
+	}
+
+	// sub-second downsampling is supported with millisecond resolution
+	u := &url.URL{Path: "/api/query", RawQuery: "start=0&m=sum:500ms-avg:a&ms=true"}
+	if trq, err := parseQueryURL(u); err != nil || trq.Step != 500*time.Millisecond {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestParseQueryBody(t *testing.T) {
+
+	const body = `{"start":"2020/01/01-00:00","end":1577858400000,"timezone":"America/New_York","showQuery":true,` +
+		`"queries":[{"aggregator":"sum","metric":"sys.cpu.user","downsample":"5m-avg","tags":{"host":"a"}}]}`
+
+	r := httptest.NewRequest("POST", "http://0/api/query", strings.NewReader(body))
+	trq, err := parseQueryBody(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if trq.Step != 5*time.Minute {
+		t.Errorf("expected %s got %s", 5*time.Minute, trq.Step)
+	}
+
+	// the start is in New York time, five hours after midnight UTC
+	if trq.Extent.Start.Unix() != 1577854800 || trq.Extent.End.Unix() != 1577858400 {
+		t.Errorf("unexpected extent %v", trq.Extent)
+	}
+
+	const expected = `{"queries":[{"aggregator":"sum","metric":"sys.cpu.user","downsample":"5m-avg","tags":{"host":"a"}}],` +
+		`"showQuery":true,"timezone":"America/New_York"}`
+	if trq.Statement != expected {
+		t.Errorf("\nexpected [%s]\ngot      [%s]", expected, trq.Statement)
+	}
+
+	if trq.TemplateURL.Query().Get(upQueryBody) != expected {
+		t.Errorf("expected template url to contain the statement")
+	}
+
+	// the body remains readable for proxied requests
+	b, _ := ioutil.ReadAll(r.Body)
+	if string(b) != body {
+		t.Errorf("expected %s got %s", body, b)
+	}
+}
+
+func TestParseQueryBodyErrors(t *testing.T) {
+
+	r := httptest.NewRequest("POST", "http://0/api/query", nil)
+	r.Body = nil
+	if _, err := parseQueryBody(r); err == nil {
+		t.Error("expected error for missing body")
+	}
+
+	const q = `"queries":[{"aggregator":"sum","metric":"a","downsample":"1m-avg"}]`
+	tests := []string{
+		`{`,
+		`{"start":0}`,
+		`{"start":0,"queries":[]}`,
+		`{"start":0,"queries":[{"aggregator":"sum","metric":"a"}]}`,
+		`{"start":0,"showSummary":true,` + q + `}`,
+		`{"start":0,"showStats":true,` + q + `}`,
+		`{"start":0,"delete":true,` + q + `}`,
+		`{"start":0,"useCalendar":true,` + q + `}`,
+		`{"start":0,"timezone":"Invalid/Zone",` + q + `}`,
+		`{"start":true,` + q + `}`,
+		`{"start":0,"end":"x",` + q + `}`,
+	}
+
+	for _, test := range tests {
+		r = httptest.NewRequest("POST", "http://0/api/query", strings.NewReader(test))
+		if _, err := parseQueryBody(r); err == nil {
+			t.Errorf("expected error for %s", test)
+		}
+	}
+}
+
+func TestSubQueryDownsample(t *testing.T) {
+
+	tests := []struct {
+		q, expected string
+	}{
+		{"sum:1m-avg:sys.cpu.user", "1m-avg"},
+		{"sum:rate{counter,,1000}:1h-sum-zero:sys.cpu.user{host=a-b}", ""},
+		{"sum:rate:1h-sum-zero:sys.cpu.user{host=a:1m-b}", "1h-sum-zero"},
+		{"sum:sys.cpu.user{host=1m-avg}", ""},
+		{"sum:10m-a", ""},
+	}
+
+	for _, test := range tests {
+		if v := subQueryDownsample(test.q); v != test.expected {
+			t.Errorf("expected %s got %s for %s", test.expected, v, test.q)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+
+	tests := []struct {
+		v        string
+		expected time.Duration
+		err      bool
+	}{
+		{"500ms", 500 * time.Millisecond, false},
+		{"30s", 30 * time.Second, false},
+		{"5m", 5 * time.Minute, false},
+		{"2h", 2 * time.Hour, false},
+		{"1d", 24 * time.Hour, false},
+		{"1w", 7 * 24 * time.Hour, false},
+		{"1n", month, false},
+		{"1y", year, false},
+		{"1x", 0, true},
+		{"", 0, true},
+	}
+
+	for _, test := range tests {
+		d, err := parseDuration(test.v)
+		if test.err != (err != nil) {
+			t.Errorf("unexpected error state for %s: %v", test.v, err)
+		}
+		if d != test.expected {
+			t.Errorf("expected %s got %s", test.expected, d)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+
+	now := time.Unix(1000000, 0)
+	ny, _ := time.LoadLocation("America/New_York")
+
+	tests := []struct {
+		v        string
+		loc      *time.Location
+		expected time.Time
+		err      bool
+	}{
+		{"now", time.UTC, now, false},
+		{"1h-ago", time.UTC, now.Add(-time.Hour), false},
+		{"2d-ago", time.UTC, now.Add(-48 * time.Hour), false},
+		{"1x-ago", time.UTC, time.Time{}, true},
+		{"1577836800", time.UTC, time.Unix(1577836800, 0), false},
+		{"1577836800500", time.UTC, time.Unix(1577836800, 500000000), false},
+		{"1577836800.123", time.UTC, time.Unix(1577836800, 123000000), false},
+		{"2020/01/01-00:00:00", time.UTC, time.Unix(1577836800, 0), false},
+		{"2020/01/01 00:00:00", time.UTC, time.Unix(1577836800, 0), false},
+		{"2020/01/01-00:00", time.UTC, time.Unix(1577836800, 0), false},
+		{"2020/01/01 00:00", time.UTC, time.Unix(1577836800, 0), false},
+		{"2020/01/01", time.UTC, time.Unix(1577836800, 0), false},
+		{"2020/01/01", ny, time.Unix(1577854800, 0), false},
+		{"yesterday", time.UTC, time.Time{}, true},
+	}
+
+	for _, test := range tests {
+		v, err := parseTime(test.v, now, test.loc)
+		if test.err != (err != nil) {
+			t.Errorf("unexpected error state for %s: %v", test.v, err)
+		}
+		if !v.Equal(test.expected) {
+			t.Errorf("expected %s got %s for %s", test.expected, v, test.v)
+		}
+	}
+}
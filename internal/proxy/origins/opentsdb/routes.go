@@ -0,0 +1,85 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package opentsdb
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Comcast/trickster/internal/config"
+	"github.com/Comcast/trickster/internal/proxy/headers"
+)
+
+func (c *Client) registerHandlers() {
+	c.handlersRegistered = true
+	c.handlers = make(map[string]http.Handler)
+	// This is the registry of handlers that Trickster supports for OpenTSDB,
+	// and are able to be referenced by name (map key) in Config Files
+	c.handlers["health"] = http.HandlerFunc(c.HealthHandler)
+	c.handlers[mnQuery] = http.HandlerFunc(c.QueryHandler)
+	c.handlers["proxycache"] = http.HandlerFunc(c.ObjectProxyCacheHandler)
+	c.handlers["proxy"] = http.HandlerFunc(c.ProxyHandler)
+}
+
+// Handlers returns a map of the HTTP Handlers the client has registered
+func (c *Client) Handlers() map[string]http.Handler {
+	if !c.handlersRegistered {
+		c.registerHandlers()
+	}
+	return c.handlers
+}
+
+// DefaultPathConfigs returns the default PathConfigs for the given OriginType
+func (c *Client) DefaultPathConfigs(oc *config.OriginConfig) map[string]*config.PathConfig {
+
+	rhinst := map[string]string{headers.NameCacheControl: fmt.Sprintf("%s=%d", headers.ValueSharedMaxAge, 30)}
+
+	paths := map[string]*config.PathConfig{
+
+		APIPath + mnQuery: {
+			Path:        APIPath + mnQuery,
+			HandlerName: mnQuery,
+			Methods:     []string{http.MethodGet, http.MethodPost},
+			CacheKeyParams: []string{upMetric, upTSUID, upMS, upTimeZone, upShowTSUIDs, upShowQuery,
+				upNoAnnotation, upGlobalAnnots, upQueryBody},
+			CacheKeyHeaders: []string{},
+			OriginConfig:    oc,
+			MatchTypeName:   "exact",
+			MatchType:       config.PathMatchTypeExact,
+		},
+
+		APIPath + mnSuggest: {
+			Path:            APIPath + mnSuggest,
+			HandlerName:     "proxycache",
+			Methods:         []string{http.MethodGet},
+			CacheKeyParams:  []string{upSuggestType, upSuggestQuery, upSuggestMax},
+			CacheKeyHeaders: []string{},
+			ResponseHeaders: rhinst,
+			OriginConfig:    oc,
+			MatchTypeName:   "exact",
+			MatchType:       config.PathMatchTypeExact,
+		},
+
+		"/": {
+			Path:          "/",
+			HandlerName:   "proxy",
+			Methods:       []string{http.MethodGet, http.MethodPost},
+			OriginConfig:  oc,
+			MatchType:     config.PathMatchTypePrefix,
+			MatchTypeName: "prefix",
+		},
+	}
+
+	return paths
+}
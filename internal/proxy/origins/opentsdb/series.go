@@ -0,0 +1,286 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package opentsdb
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/Comcast/trickster/internal/timeseries"
+	"github.com/Comcast/trickster/pkg/sort/times"
+)
+
+// SetExtents overwrites a Timeseries's known extents with the provided extent list
+func (rs *ResultSet) SetExtents(extents timeseries.ExtentList) {
+	rs.ExtentList = make(timeseries.ExtentList, len(extents))
+	copy(rs.ExtentList, extents)
+	rs.isCounted = false
+}
+
+// Extents returns the Timeseries's ExentList
+func (rs *ResultSet) Extents() timeseries.ExtentList {
+	return rs.ExtentList
+}
+
+// Step returns the step for the Timeseries
+func (rs *ResultSet) Step() time.Duration {
+	return rs.StepDuration
+}
+
+// SetStep sets the step for the Timeseries
+func (rs *ResultSet) SetStep(step time.Duration) {
+	rs.StepDuration = step
+}
+
+// SeriesCount returns the count of all Series in the Timeseries
+func (rs *ResultSet) SeriesCount() int {
+	return len(rs.Series)
+}
+
+// ValueCount returns the count of all datapoints across all Series in the Timeseries
+func (rs *ResultSet) ValueCount() int {
+	c := 0
+	for _, s := range rs.Series {
+		c += len(s.Points)
+	}
+	return c
+}
+
+// TimestampCount returns the count of unique timestamps across all Series in the Timeseries
+func (rs *ResultSet) TimestampCount() int {
+	rs.updateTimestamps()
+	return len(rs.timestamps)
+}
+
+func (rs *ResultSet) updateTimestamps() {
+	if rs.isCounted {
+		return
+	}
+	m := make(map[time.Time]bool)
+	for _, s := range rs.Series {
+		for _, p := range s.Points {
+			m[p.Timestamp] = true
+		}
+	}
+	rs.timestamps = m
+	rs.isCounted = true
+}
+
+// Merge merges the provided Timeseries list into the base Timeseries (in the order provided) and optionally sorts the merged Timeseries
+func (rs *ResultSet) Merge(sort bool, collection ...timeseries.Timeseries) {
+
+	series := make(map[string]*Series, len(rs.Series))
+	for _, s := range rs.Series {
+		series[s.key] = s
+	}
+
+	for _, ts := range collection {
+		if ts == nil {
+			continue
+		}
+		rs2 := ts.(*ResultSet)
+		for _, s2 := range rs2.Series {
+			if s, ok := series[s2.key]; ok {
+				s.Points = append(s.Points, s2.clone().Points...)
+				continue
+			}
+			s := s2.clone()
+			series[s.key] = s
+			rs.Series = append(rs.Series, s)
+		}
+		rs.Millis = rs.Millis || rs2.Millis
+		rs.ExtentList = append(rs.ExtentList, rs2.ExtentList...)
+	}
+
+	rs.ExtentList = rs.ExtentList.Compress(rs.StepDuration)
+	rs.isSorted = false
+	rs.isCounted = false
+	if sort {
+		rs.Sort()
+	}
+}
+
+// Clone returns a perfect copy of the base Timeseries
+func (rs *ResultSet) Clone() timeseries.Timeseries {
+	rs2 := &ResultSet{
+		Series:       make([]*Series, len(rs.Series)),
+		StepDuration: rs.StepDuration,
+		ExtentList:   make(timeseries.ExtentList, len(rs.ExtentList)),
+		Millis:       rs.Millis,
+		isSorted:     rs.isSorted,
+	}
+	copy(rs2.ExtentList, rs.ExtentList)
+	for i, s := range rs.Series {
+		rs2.Series[i] = s.clone()
+	}
+	return rs2
+}
+
+// clone returns a copy of the Series. The raw field and datapoint values are never modified, so they are shared.
+func (s *Series) clone() *Series {
+	s2 := &Series{Fields: make(map[string]json.RawMessage, len(s.Fields)),
+		Points: make([]Point, len(s.Points)), key: s.key}
+	for k, v := range s.Fields {
+		s2.Fields[k] = v
+	}
+	copy(s2.Points, s.Points)
+	return s2
+}
+
+// CropToSize reduces the number of elements in the Timeseries to the provided count, by evicting elements
+// using a least-recently-used methodology. The time parameter limits the upper extent to the provided time,
+// in order to support backfill tolerance
+func (rs *ResultSet) CropToSize(sz int, t time.Time, lur timeseries.Extent) {
+
+	rs.isCounted = false
+	rs.isSorted = false
+	x := len(rs.ExtentList)
+	// The Series has no extents, so no need to do anything
+	if x < 1 {
+		rs.Series = []*Series{}
+		rs.ExtentList = timeseries.ExtentList{}
+		return
+	}
+
+	// Crop to the Backfill Tolerance Value if needed
+	if rs.ExtentList[x-1].End.After(t) {
+		rs.CropToRange(timeseries.Extent{Start: rs.ExtentList[0].Start, End: t})
+	}
+
+	tc := rs.TimestampCount()
+	if len(rs.Series) == 0 || tc <= sz {
+		return
+	}
+
+	el := timeseries.ExtentListLRU(rs.ExtentList).UpdateLastUsed(lur, rs.StepDuration)
+	sort.Sort(el)
+
+	rc := tc - sz // # of required timestamps we must delete to meet the rentention policy
+	removals := make(map[time.Time]bool)
+	done := false
+
+	for _, x := range el {
+		for ts := x.Start; !x.End.Before(ts) && !done; ts = ts.Add(rs.StepDuration) {
+			// datapoint timestamps are in UTC, while extents may be in any location
+			if _, ok := rs.timestamps[ts.UTC()]; ok {
+				removals[ts.UTC()] = true
+				done = len(removals) >= rc
+			}
+		}
+		if done {
+			break
+		}
+	}
+
+	rs.filterPoints(func(p Point) bool { return !removals[p.Timestamp] })
+
+	tl := times.FromMap(removals)
+	sort.Sort(tl)
+	for _, t := range tl {
+		for i, e := range el {
+			if e.StartsAt(t) {
+				el[i].Start = e.Start.Add(rs.StepDuration)
+			}
+		}
+	}
+
+	rs.ExtentList = timeseries.ExtentList(el).Compress(rs.StepDuration)
+	rs.Sort()
+}
+
+// CropToRange reduces the Timeseries down to timestamps contained within the provided Extents (inclusive).
+func (rs *ResultSet) CropToRange(e timeseries.Extent) {
+
+	rs.isCounted = false
+	x := len(rs.ExtentList)
+	// The Series has no extents, or is entirely outside of the crop range, so return an empty set
+	if x < 1 || rs.ExtentList.OutsideOf(e) {
+		rs.Series = []*Series{}
+		rs.ExtentList = timeseries.ExtentList{}
+		return
+	}
+
+	rs.filterPoints(func(p Point) bool {
+		return !p.Timestamp.Before(e.Start) && !p.Timestamp.After(e.End)
+	})
+	rs.ExtentList = rs.ExtentList.Crop(e)
+}
+
+// filterPoints retains only the datapoints for which keep returns true, and removes
+// any Series that are left empty
+func (rs *ResultSet) filterPoints(keep func(Point) bool) {
+	series := rs.Series[:0]
+	for _, s := range rs.Series {
+		points := s.Points[:0]
+		for _, p := range s.Points {
+			if keep(p) {
+				points = append(points, p)
+			}
+		}
+		s.Points = points
+		if len(points) > 0 {
+			series = append(series, s)
+		}
+	}
+	rs.Series = series
+}
+
+// Sort sorts all datapoints in each Series chronologically by their timestamp, keeping the most
+// recently merged datapoint when a timestamp is duplicated
+func (rs *ResultSet) Sort() {
+
+	if rs.isSorted || len(rs.Series) == 0 {
+		return
+	}
+
+	tsm := make(map[time.Time]bool)
+	for _, s := range rs.Series {
+		sort.SliceStable(s.Points, func(i, j int) bool { return s.Points[i].Timestamp.Before(s.Points[j].Timestamp) })
+		points := s.Points[:0]
+		for _, p := range s.Points {
+			if n := len(points); n > 0 && points[n-1].Timestamp.Equal(p.Timestamp) {
+				points[n-1] = p
+				continue
+			}
+			points = append(points, p)
+			tsm[p.Timestamp] = true
+		}
+		s.Points = points
+	}
+
+	sort.Sort(rs.ExtentList)
+
+	rs.timestamps = tsm
+	rs.isCounted = true
+	rs.isSorted = true
+}
+
+// Size returns the approximate memory utilization in bytes of the timeseries
+func (rs *ResultSet) Size() int {
+	size := 0
+	for _, s := range rs.Series {
+		size += len(s.key)
+		for k, v := range s.Fields {
+			size += len(k) + len(v)
+		}
+		for _, p := range s.Points {
+			// Timestamp
+			size += 24 + len(p.Value)
+		}
+	}
+	// ExtentList + StepDuration + Timestamps + Millis + isCounted + isSorted
+	size += (len(rs.ExtentList) * 24) + 8 + (len(rs.timestamps) * 9) + 3
+	return size
+}
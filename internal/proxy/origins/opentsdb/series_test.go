@@ -0,0 +1,180 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package opentsdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+func TestSetStep(t *testing.T) {
+	rs := &ResultSet{}
+	const step = time.Duration(300) * time.Minute
+	rs.SetStep(step)
+	if rs.Step() != step {
+		t.Errorf(`expected "%s". got "%s"`, step, rs.Step())
+	}
+}
+
+func TestSetExtents(t *testing.T) {
+	rs := &ResultSet{}
+	ex := timeseries.ExtentList{{Start: time.Unix(0, 0), End: time.Unix(60, 0)}}
+	rs.SetExtents(ex)
+	if len(rs.Extents()) != 1 || !rs.Extents()[0].End.Equal(ex[0].End) {
+		t.Errorf("expected %v got %v", ex, rs.Extents())
+	}
+}
+
+func TestMerge(t *testing.T) {
+
+	rs := testResultSet(t, []string{"a"}, 600, 720)
+	rs2 := testResultSet(t, []string{"a", "b"}, 780, 900)
+	rs2.Millis = true
+
+	rs.Merge(true, rs2, nil)
+
+	if rs.SeriesCount() != 2 {
+		t.Errorf("expected %d got %d", 2, rs.SeriesCount())
+	}
+
+	if rs.ValueCount() != 9 {
+		t.Errorf("expected %d got %d", 9, rs.ValueCount())
+	}
+
+	if rs.TimestampCount() != 6 {
+		t.Errorf("expected %d got %d", 6, rs.TimestampCount())
+	}
+
+	if !rs.Millis {
+		t.Error("expected millisecond resolution")
+	}
+
+	if len(rs.ExtentList) != 1 || !rs.ExtentList[0].Start.Equal(time.Unix(600, 0)) ||
+		!rs.ExtentList[0].End.Equal(time.Unix(900, 0)) {
+		t.Errorf("unexpected extents %v", rs.ExtentList)
+	}
+}
+
+func TestSort(t *testing.T) {
+
+	rs := testResultSet(t, []string{"a"}, 600, 720)
+	rs2 := testResultSet(t, []string{"a"}, 660, 660)
+
+	// the later datapoint for a duplicate timestamp is kept
+	rs2.Series[0].Points[0].Value = []byte("99")
+	rs.Merge(true, rs2)
+
+	p := rs.Series[0].Points
+	if len(p) != 3 {
+		t.Errorf("expected %d got %d", 3, len(p))
+	}
+	if string(p[1].Value) != "99" {
+		t.Errorf("expected %s got %s", "99", p[1].Value)
+	}
+
+	// sorting is a no-op once sorted
+	rs.Sort()
+	if len(rs.Series[0].Points) != 3 {
+		t.Errorf("expected %d got %d", 3, len(rs.Series[0].Points))
+	}
+}
+
+func TestClone(t *testing.T) {
+
+	rs := testResultSet(t, []string{"a", "b"}, 600, 720)
+	rs.Millis = true
+
+	rs2 := rs.Clone().(*ResultSet)
+	if rs2.ValueCount() != rs.ValueCount() || rs2.Step() != rs.Step() || !rs2.Millis ||
+		len(rs2.ExtentList) != 1 || rs2.Series[0].key != rs.Series[0].key {
+		t.Errorf("clone mismatch")
+	}
+
+	// the clone is independent of the original
+	rs2.CropToRange(timeseries.Extent{Start: time.Unix(600, 0), End: time.Unix(600, 0)})
+	if rs.ValueCount() != 6 || rs2.ValueCount() != 2 {
+		t.Errorf("expected 6 and 2 values got %d and %d", rs.ValueCount(), rs2.ValueCount())
+	}
+}
+
+func TestCropToRange(t *testing.T) {
+
+	rs := testResultSet(t, []string{"a", "b"}, 600, 720)
+	rs.CropToRange(timeseries.Extent{Start: time.Unix(660, 0), End: time.Unix(900, 0)})
+	if rs.ValueCount() != 4 {
+		t.Errorf("expected %d got %d", 4, rs.ValueCount())
+	}
+	if len(rs.ExtentList) != 1 || !rs.ExtentList[0].Start.Equal(time.Unix(660, 0)) {
+		t.Errorf("unexpected extents %v", rs.ExtentList)
+	}
+
+	// series left without datapoints are removed
+	rs = testResultSet(t, []string{"a"}, 600, 720)
+	rs2 := testResultSet(t, []string{"b"}, 780, 840)
+	rs.Merge(true, rs2)
+	rs.CropToRange(timeseries.Extent{Start: time.Unix(780, 0), End: time.Unix(840, 0)})
+	if rs.SeriesCount() != 1 || rs.Series[0].key != rs2.Series[0].key {
+		t.Errorf("expected only series b got %d series", rs.SeriesCount())
+	}
+
+	// outside of the extents
+	rs.CropToRange(timeseries.Extent{Start: time.Unix(0, 0), End: time.Unix(60, 0)})
+	if rs.ValueCount() != 0 || len(rs.ExtentList) != 0 {
+		t.Errorf("expected empty result set got %d values", rs.ValueCount())
+	}
+}
+
+func TestCropToSize(t *testing.T) {
+
+	now := time.Now().Truncate(time.Minute)
+	start := now.Add(-10 * time.Minute)
+
+	rs := testResultSet(t, []string{"a", "b"}, start.Unix(), now.Unix())
+	rs.CropToSize(5, now, timeseries.Extent{Start: start, End: now})
+
+	if rs.TimestampCount() != 5 {
+		t.Errorf("expected %d got %d", 5, rs.TimestampCount())
+	}
+	if rs.ValueCount() != 10 {
+		t.Errorf("expected %d got %d", 10, rs.ValueCount())
+	}
+	if len(rs.ExtentList) != 1 || !rs.ExtentList[0].Start.Equal(now.Add(-4*time.Minute)) {
+		t.Errorf("unexpected extents %v", rs.ExtentList)
+	}
+
+	// backfill tolerance
+	rs = testResultSet(t, []string{"a"}, start.Unix(), now.Unix())
+	rs.CropToSize(100, now.Add(-time.Minute), timeseries.Extent{Start: start, End: now})
+	if rs.TimestampCount() != 10 {
+		t.Errorf("expected %d got %d", 10, rs.TimestampCount())
+	}
+
+	// no extents
+	rs = testResultSet(t, []string{"a"}, start.Unix(), now.Unix())
+	rs.ExtentList = nil
+	rs.CropToSize(5, now, timeseries.Extent{})
+	if rs.ValueCount() != 0 {
+		t.Errorf("expected %d got %d", 0, rs.ValueCount())
+	}
+}
+
+func TestSize(t *testing.T) {
+	rs := testResultSet(t, []string{"a"}, 600, 600)
+	const expected = 142
+	if rs.Size() != expected {
+		t.Errorf("expected %d got %d", expected, rs.Size())
+	}
+}
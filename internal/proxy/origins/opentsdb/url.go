@@ -0,0 +1,87 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package opentsdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Comcast/trickster/internal/proxy/headers"
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+// BaseURL returns a URL in the form of scheme://host/path based on the proxy configuration
+func (c *Client) BaseURL() *url.URL {
+	u := &url.URL{}
+	u.Scheme = c.config.Scheme
+	u.Host = c.config.Host
+	u.Path = c.config.PathPrefix
+	return u
+}
+
+// BuildUpstreamURL will merge the downstream request with the BaseURL to construct the full upstream URL
+func (c *Client) BuildUpstreamURL(r *http.Request) *url.URL {
+	u := c.BaseURL()
+
+	if strings.HasPrefix(r.URL.Path, "/"+c.name+"/") {
+		u.Path += strings.Replace(r.URL.Path, "/"+c.name+"/", "/", 1)
+	} else {
+		u.Path += r.URL.Path
+	}
+
+	u.RawQuery = r.URL.RawQuery
+	u.Fragment = r.URL.Fragment
+	u.User = r.URL.User
+	return u
+}
+
+// SetExtent will change the upstream request query or body to use the provided Extent. The
+// end is extended to the last millisecond of the final downsample interval, so that it is complete.
+func (c *Client) SetExtent(r *http.Request, trq *timeseries.TimeRangeQuery, extent *timeseries.Extent) {
+
+	if extent == nil || r == nil || trq == nil || trq.TemplateURL == nil {
+		return
+	}
+
+	start := epochMillis(extent.Start)
+	end := epochMillis(extent.End.Add(trq.Step)) - 1
+
+	if template := trq.TemplateURL.Query().Get(upQueryBody); template != "" {
+		body := make(map[string]json.RawMessage)
+		json.Unmarshal([]byte(template), &body)
+		body[fnStart] = json.RawMessage(strconv.FormatInt(start, 10))
+		body[fnEnd] = json.RawMessage(strconv.FormatInt(end, 10))
+		b, _ := json.Marshal(body)
+		r.Header.Set(headers.NameContentType, headers.ValueApplicationJSON)
+		r.Body = ioutil.NopCloser(bytes.NewReader(b))
+		r.ContentLength = int64(len(b))
+		r.Header.Set(headers.NameContentLength, strconv.Itoa(len(b)))
+		return
+	}
+
+	params := r.URL.Query()
+	params.Set(upStart, strconv.FormatInt(start, 10))
+	params.Set(upEnd, strconv.FormatInt(end, 10))
+	r.URL.RawQuery = params.Encode()
+}
+
+func epochMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
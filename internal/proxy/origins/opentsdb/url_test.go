@@ -0,0 +1,98 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package opentsdb
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/trickster/internal/config"
+	"github.com/Comcast/trickster/internal/proxy/headers"
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+func TestSetExtent(t *testing.T) {
+
+	client := &Client{}
+	e := &timeseries.Extent{Start: time.Unix(600, 0), End: time.Unix(720, 0)}
+
+	r := httptest.NewRequest(http.MethodGet, "http://0/api/query?m=sum:1m-avg:a&start=1h-ago", nil)
+	trq, err := parseQueryURL(r.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const expected = "end=779999&m=sum%3A1m-avg%3Aa&start=600000"
+	client.SetExtent(r, trq, e)
+	if r.URL.RawQuery != expected {
+		t.Errorf("\nexpected [%s]\ngot      [%s]", expected, r.URL.RawQuery)
+	}
+
+	client.SetExtent(r, trq, nil)
+	if r.URL.RawQuery != expected {
+		t.Errorf("\nexpected [%s]\ngot      [%s]", expected, r.URL.RawQuery)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "http://0/api/query",
+		strings.NewReader(`{"start":"1h-ago","queries":[{"aggregator":"sum","metric":"a","downsample":"1m-avg"}]}`))
+	trq, err = parseQueryBody(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client.SetExtent(r, trq, e)
+	b, _ := ioutil.ReadAll(r.Body)
+	const expectedBody = `{"end":779999,"queries":[{"aggregator":"sum","metric":"a","downsample":"1m-avg"}],"start":600000}`
+	if string(b) != expectedBody {
+		t.Errorf("\nexpected [%s]\ngot      [%s]", expectedBody, b)
+	}
+
+	if r.ContentLength != int64(len(b)) {
+		t.Errorf("expected %d got %d", len(b), r.ContentLength)
+	}
+
+	if ct := r.Header.Get(headers.NameContentType); ct != headers.ValueApplicationJSON {
+		t.Errorf("expected %s got %s", headers.ValueApplicationJSON, ct)
+	}
+}
+
+func TestBuildUpstreamURL(t *testing.T) {
+
+	cfg := config.NewConfig()
+	oc := cfg.Origins["default"]
+	oc.Scheme = "http"
+	oc.Host = "0"
+	oc.PathPrefix = ""
+
+	client := &Client{name: "default", config: oc}
+	r, err := http.NewRequest(http.MethodGet, "http://0/default/api/query?m=sum:a&start=1h-ago", nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	u := client.BuildUpstreamURL(r)
+	if u.Path != "/api/query" {
+		t.Errorf("expected %s got %s", "/api/query", u.Path)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "http://0/api/query", nil)
+	u = client.BuildUpstreamURL(r)
+	if u.Path != "/api/query" {
+		t.Errorf("expected %s got %s", "/api/query", u.Path)
+	}
+}
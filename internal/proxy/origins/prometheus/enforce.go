@@ -0,0 +1,337 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Comcast/trickster/internal/config"
+	"github.com/Comcast/trickster/internal/proxy/headers"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// This file handles the origin's label rules, which restrict the label values that the queries
+// and series selectors of a request can select by injecting label matchers into them.
+
+// enforcedLabel is a label whose selected values are restricted to those allowed for the request
+type enforcedLabel struct {
+	name   string
+	values []string
+}
+
+// forbiddenError indicates that a request is not allowed to select the data it asks for
+type forbiddenError string
+
+func (e forbiddenError) Error() string {
+	return string(e)
+}
+
+// errorEnvelope is the body of a Prometheus API error response
+type errorEnvelope struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+}
+
+// enforceLabels applies the origin's label rules to the selectors found in the provided parameter
+// of the request, in both its URL and any form-encoded body. If the request can't be restricted,
+// it responds with a Prometheus API error and returns false.
+func (c *Client) enforceLabels(w http.ResponseWriter, r *http.Request, param string) bool {
+	if c.config == nil || len(c.config.LabelRules) == 0 {
+		return true
+	}
+	if err := enforceRequest(r, c.config.LabelRules, param); err != nil {
+		writeEnforceError(w, err)
+		return false
+	}
+	return true
+}
+
+// rejectUnenforced responds with a Prometheus API error and returns true if the origin has label
+// rules, for requests to endpoints that the rules are not applied to, which could select any data
+func (c *Client) rejectUnenforced(w http.ResponseWriter, r *http.Request) bool {
+	if c.config == nil || len(c.config.LabelRules) == 0 {
+		return false
+	}
+	writeEnforceError(w, forbiddenError(fmt.Sprintf("%s is not available when label rules are enforced", r.URL.Path)))
+	return true
+}
+
+// writeEnforceError responds with the Prometheus API error for a request that can't be restricted
+func writeEnforceError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if _, ok := err.(forbiddenError); ok {
+		status = http.StatusForbidden
+	}
+	b, _ := json.Marshal(errorEnvelope{Status: "error", ErrorType: "bad_data", Error: err.Error()})
+	w.Header().Set(headers.NameContentType, headers.ValueApplicationJSON)
+	w.WriteHeader(status)
+	w.Write(b)
+}
+
+// enforceRequest rewrites the selectors of the request to apply the label rules. Series and label
+// metadata requests that provide no selectors are restricted with one that has only the rules' matchers.
+func enforceRequest(r *http.Request, rules map[string]*config.LabelRuleConfig, param string) error {
+
+	ls, err := enforcedLabels(r, rules)
+	if err != nil {
+		return err
+	}
+
+	params := r.URL.Query()
+	found, err := enforceValues(params, param, ls)
+	if err != nil {
+		return err
+	}
+
+	// Prometheus reads the parameters of other POST bodies, such as multipart forms,
+	// which aren't rewritten here, so the request can't be restricted
+	if r.Method == http.MethodPost && hasBody(r) && !isFormPost(r) {
+		return fmt.Errorf("POST bodies must be %s when label rules are enforced", headers.ValueXFormURLEncoded)
+	}
+
+	var form url.Values
+	if isFormPost(r) {
+		if form, err = formValues(r); err != nil {
+			return err
+		}
+		inForm, err := enforceValues(form, param, ls)
+		if err != nil {
+			return err
+		}
+		found = found || inForm
+	}
+
+	if !found && param == upMatch {
+		// the selector is added wherever the other parameters of the request are
+		ms, _ := enforceMatchers(nil, ls)
+		if form != nil {
+			form.Set(upMatch, selectorString(ms))
+		} else {
+			params.Set(upMatch, selectorString(ms))
+		}
+	}
+
+	if form != nil {
+		setFormBody(r, form)
+	}
+	r.URL.RawQuery = params.Encode()
+	return nil
+}
+
+// enforceReadRequest applies the label rules to the matchers of each query of the remote read request
+func enforceReadRequest(r *http.Request, rules map[string]*config.LabelRuleConfig, rr *readRequest) error {
+	ls, err := enforcedLabels(r, rules)
+	if err != nil {
+		return err
+	}
+	for _, q := range rr.queries {
+		if q.matchers, err = enforceMatchers(q.matchers, ls); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enforceValues applies the enforced labels to each value of the parameter, which are queries or,
+// for upMatch, series selectors. It returns true if the parameter was found.
+func enforceValues(params url.Values, param string, ls []enforcedLabel) (bool, error) {
+	vals, ok := params[param]
+	if !ok {
+		return false, nil
+	}
+	for i, v := range vals {
+		var err error
+		if param == upMatch {
+			vals[i], err = enforceSelector(v, ls)
+		} else {
+			vals[i], err = enforceQuery(v, ls)
+		}
+		if err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// enforceQuery applies the enforced labels to every selector in the PromQL query
+func enforceQuery(q string, ls []enforcedLabel) (string, error) {
+	expr, err := parser.ParseExpr(q)
+	if err != nil {
+		return "", err
+	}
+	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+		if vs, ok := node.(*parser.VectorSelector); ok && err == nil {
+			vs.LabelMatchers, err = enforceMatchers(vs.LabelMatchers, ls)
+		}
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return expr.String(), nil
+}
+
+// enforceSelector applies the enforced labels to the series selector
+func enforceSelector(s string, ls []enforcedLabel) (string, error) {
+	ms, err := parser.ParseMetricSelector(s)
+	if err != nil {
+		return "", err
+	}
+	if ms, err = enforceMatchers(ms, ls); err != nil {
+		return "", err
+	}
+	return selectorString(ms), nil
+}
+
+// enforceMatchers returns the matchers of a selector, restricted to the allowed values of the
+// enforced labels. Equality matchers on an enforced label that select a value outside of those
+// allowed are rejected, while other matchers remain and can only narrow the selection further.
+func enforceMatchers(ms []*labels.Matcher, ls []enforcedLabel) ([]*labels.Matcher, error) {
+	for _, l := range ls {
+		m := l.matcher()
+		restricted := false
+		for _, e := range ms {
+			if e.Name != l.name {
+				continue
+			}
+			if e.Type == labels.MatchEqual {
+				if !m.Matches(e.Value) {
+					return nil, forbiddenError(fmt.Sprintf("%s selects a value that is not allowed", e))
+				}
+				restricted = true
+			} else if e.Type == m.Type && e.Value == m.Value {
+				restricted = true
+			}
+		}
+		if !restricted {
+			ms = append(ms, m)
+		}
+	}
+	return ms, nil
+}
+
+// selectorString returns the series selector with the provided matchers
+func selectorString(ms []*labels.Matcher) string {
+	vs := &parser.VectorSelector{LabelMatchers: ms}
+	for _, m := range ms {
+		if m.Name == labels.MetricName && m.Type == labels.MatchEqual {
+			vs.Name = m.Value
+			break
+		}
+	}
+	return vs.String()
+}
+
+// matcher returns the label matcher that selects only the allowed values of the label
+func (l enforcedLabel) matcher() *labels.Matcher {
+	if len(l.values) == 1 {
+		m, _ := labels.NewMatcher(labels.MatchEqual, l.name, l.values[0])
+		return m
+	}
+	quoted := make([]string, len(l.values))
+	for i, v := range l.values {
+		quoted[i] = regexp.QuoteMeta(v)
+	}
+	// the values are quoted, so the expression always compiles
+	m, _ := labels.NewMatcher(labels.MatchRegexp, l.name, strings.Join(quoted, "|"))
+	return m
+}
+
+// enforcedLabels returns the enforced labels, sorted by name, with the values the request is
+// allowed to select according to the provided rules
+func enforcedLabels(r *http.Request, rules map[string]*config.LabelRuleConfig) ([]enforcedLabel, error) {
+
+	var claims map[string]interface{}
+
+	ls := make([]enforcedLabel, 0, len(rules))
+	for name, rule := range rules {
+
+		var values []string
+		if rule.Header != "" {
+			for _, h := range r.Header[rule.Header] {
+				values = append(values, strings.Split(h, ",")...)
+			}
+		} else {
+			if claims == nil {
+				var err error
+				if claims, err = bearerClaims(r); err != nil {
+					return nil, err
+				}
+			}
+			switch v := claims[rule.JWTClaim].(type) {
+			case string:
+				values = []string{v}
+			case []interface{}:
+				for _, e := range v {
+					if s, ok := e.(string); ok {
+						values = append(values, s)
+					}
+				}
+			}
+		}
+
+		allowed := make([]string, 0, len(values))
+		seen := make(map[string]bool, len(values))
+		for _, v := range values {
+			v = strings.TrimSpace(v)
+			if v == "" || seen[v] {
+				continue
+			}
+			seen[v] = true
+			allowed = append(allowed, v)
+		}
+		if len(allowed) == 0 {
+			return nil, forbiddenError(fmt.Sprintf("no allowed values were provided for label %q", name))
+		}
+		sort.Strings(allowed)
+
+		ls = append(ls, enforcedLabel{name: name, values: allowed})
+	}
+
+	sort.Slice(ls, func(i, j int) bool { return ls[i].name < ls[j].name })
+	return ls, nil
+}
+
+// bearerClaims returns the claims in the payload of the request's bearer token. The token's
+// signature is not verified, so it must be authenticated before the request reaches Trickster.
+func bearerClaims(r *http.Request) (map[string]interface{}, error) {
+	const prefix = "bearer "
+	h := r.Header.Get(headers.NameAuthorization)
+	if len(h) <= len(prefix) || !strings.EqualFold(h[:len(prefix)], prefix) {
+		return nil, forbiddenError("missing bearer token")
+	}
+	parts := strings.Split(strings.TrimSpace(h[len(prefix):]), ".")
+	if len(parts) != 3 {
+		return nil, forbiddenError("invalid bearer token")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, forbiddenError("invalid bearer token")
+	}
+	claims := make(map[string]interface{})
+	if err := json.Unmarshal(b, &claims); err != nil {
+		return nil, forbiddenError("invalid bearer token")
+	}
+	return claims, nil
+}
@@ -0,0 +1,324 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Comcast/trickster/internal/config"
+	"github.com/Comcast/trickster/internal/proxy/headers"
+	"github.com/Comcast/trickster/internal/proxy/request"
+	tu "github.com/Comcast/trickster/internal/util/testing"
+)
+
+var testLabelRules = map[string]*config.LabelRuleConfig{
+	"namespace": {Label: "namespace", Header: "X-Namespace"},
+}
+
+func TestEnforceQuery(t *testing.T) {
+
+	ls := []enforcedLabel{{name: "namespace", values: []string{"a"}}}
+	ls2 := []enforcedLabel{{name: "namespace", values: []string{"a", "b.c"}}}
+
+	tests := []struct {
+		query, expected string
+		ls              []enforcedLabel
+		forbidden       bool
+	}{
+		{`up`, `up{namespace="a"}`, ls, false},
+		{`sum(rate(http_requests_total{job="api"}[5m])) / sum(rate(http_requests_total[5m]))`,
+			`sum(rate(http_requests_total{job="api",namespace="a"}[5m])) / sum(rate(http_requests_total{namespace="a"}[5m]))`, ls, false},
+		{`up{namespace="a"}`, `up{namespace="a"}`, ls, false},
+		{`up{namespace="b"}`, ``, ls, true},
+		{`up{namespace=~".+"}`, `up{namespace="a",namespace=~".+"}`, ls, false},
+		{`up`, `up{namespace=~"a|b\\.c"}`, ls2, false},
+		{`up{namespace="b.c"}`, `up{namespace="b.c"}`, ls2, false},
+		{`up{namespace="b"}`, ``, ls2, true},
+	}
+
+	for i, test := range tests {
+		q, err := enforceQuery(test.query, test.ls)
+		if test.forbidden {
+			if _, ok := err.(forbiddenError); !ok {
+				t.Errorf("test %d: expected forbidden error got %v", i, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+		}
+		if q != test.expected {
+			t.Errorf("test %d: expected %s got %s", i, test.expected, q)
+		}
+	}
+
+	if _, err := enforceQuery(`up{`, ls); err == nil {
+		t.Errorf("expected parse error")
+	}
+
+}
+
+func TestEnforceSelector(t *testing.T) {
+
+	ls := []enforcedLabel{{name: "namespace", values: []string{"a"}}}
+
+	s, err := enforceSelector(`process_start_time_seconds{job="prometheus"}`, ls)
+	if err != nil {
+		t.Error(err)
+	}
+	expected := `process_start_time_seconds{job="prometheus",namespace="a"}`
+	if s != expected {
+		t.Errorf("expected %s got %s", expected, s)
+	}
+
+	if _, err := enforceSelector(`{namespace="b"}`, ls); err == nil {
+		t.Errorf("expected forbidden error")
+	}
+
+}
+
+func TestEnforcedLabels(t *testing.T) {
+
+	rules := map[string]*config.LabelRuleConfig{
+		"namespace": {Label: "namespace", Header: "X-Namespace"},
+		"team":      {Label: "team", JWTClaim: "teams"},
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"1","teams":["y","x"]}`))
+
+	r := httptest.NewRequest(http.MethodGet, "http://127.0.0.1/", nil)
+	r.Header.Set("X-Namespace", "b, a,b")
+	r.Header.Set(headers.NameAuthorization, "Bearer header."+payload+".signature")
+
+	ls, err := enforcedLabels(r, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ls) != 2 || ls[0].name != "namespace" || ls[1].name != "team" {
+		t.Fatalf("unexpected labels %v", ls)
+	}
+
+	if strings.Join(ls[0].values, ",") != "a,b" {
+		t.Errorf("expected %s got %v", "a,b", ls[0].values)
+	}
+
+	if strings.Join(ls[1].values, ",") != "x,y" {
+		t.Errorf("expected %s got %v", "x,y", ls[1].values)
+	}
+
+	r.Header.Del("X-Namespace")
+	if _, err := enforcedLabels(r, rules); err == nil {
+		t.Errorf("expected error for missing header")
+	}
+
+	r.Header.Set("X-Namespace", "a")
+	r.Header.Set(headers.NameAuthorization, "Bearer invalid")
+	if _, err := enforcedLabels(r, rules); err == nil {
+		t.Errorf("expected error for invalid token")
+	}
+
+}
+
+func TestEnforceRequest(t *testing.T) {
+
+	r := httptest.NewRequest(http.MethodGet, "http://127.0.0.1/api/v1/labels?start=0", nil)
+	r.Header.Set("X-Namespace", "a")
+
+	// metadata requests without selectors get one
+	if err := enforceRequest(r, testLabelRules, upMatch); err != nil {
+		t.Error(err)
+	}
+	if v := r.URL.Query().Get(upMatch); v != `{namespace="a"}` {
+		t.Errorf("expected %s got %s", `{namespace="a"}`, v)
+	}
+
+	form := url.Values{upQuery: {"up"}}
+	r = httptest.NewRequest(http.MethodPost, "http://127.0.0.1/api/v1/query", strings.NewReader(form.Encode()))
+	r.Header.Set(headers.NameContentType, headers.ValueXFormURLEncoded)
+	r.Header.Set("X-Namespace", "a")
+
+	if err := enforceRequest(r, testLabelRules, upQuery); err != nil {
+		t.Error(err)
+	}
+	form, err := formValues(r)
+	if err != nil {
+		t.Error(err)
+	}
+	if v := form.Get(upQuery); v != `up{namespace="a"}` {
+		t.Errorf("expected %s got %s", `up{namespace="a"}`, v)
+	}
+
+	// form POST metadata requests without selectors get one in the body
+	form = url.Values{upStart: {"0"}}
+	r = httptest.NewRequest(http.MethodPost, "http://127.0.0.1/api/v1/labels", strings.NewReader(form.Encode()))
+	r.Header.Set(headers.NameContentType, headers.ValueXFormURLEncoded)
+	r.Header.Set("X-Namespace", "a")
+
+	if err := enforceRequest(r, testLabelRules, upMatch); err != nil {
+		t.Error(err)
+	}
+	if form, err = formValues(r); err != nil {
+		t.Error(err)
+	}
+	if v := form.Get(upMatch); v != `{namespace="a"}` {
+		t.Errorf("expected %s got %s", `{namespace="a"}`, v)
+	}
+	if v := form.Get(upStart); v != "0" {
+		t.Errorf("expected %s got %s", "0", v)
+	}
+	if v := r.URL.Query().Get(upMatch); v != "" {
+		t.Errorf("expected no %s in the url got %s", upMatch, v)
+	}
+
+	// the media type of form POSTs is matched regardless of case
+	form = url.Values{upQuery: {"up"}}
+	r = httptest.NewRequest(http.MethodPost, "http://127.0.0.1/api/v1/query", strings.NewReader(form.Encode()))
+	r.Header.Set(headers.NameContentType, "Application/X-WWW-Form-Urlencoded; charset=UTF-8")
+	r.Header.Set("X-Namespace", "a")
+
+	if err := enforceRequest(r, testLabelRules, upQuery); err != nil {
+		t.Error(err)
+	}
+	if form, err = formValues(r); err != nil {
+		t.Error(err)
+	}
+	if v := form.Get(upQuery); v != `up{namespace="a"}` {
+		t.Errorf("expected %s got %s", `up{namespace="a"}`, v)
+	}
+
+}
+
+func TestEnforceRequestMultipart(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	mw := multipart.NewWriter(buf)
+	mw.WriteField(upQuery, `up{namespace="b"}`)
+	mw.Close()
+
+	r := httptest.NewRequest(http.MethodPost, "http://127.0.0.1/api/v1/query", buf)
+	r.Header.Set(headers.NameContentType, mw.FormDataContentType())
+	r.Header.Set("X-Namespace", "a")
+
+	// multipart bodies can't be rewritten, so they are rejected
+	err := enforceRequest(r, testLabelRules, upQuery)
+	if err == nil {
+		t.Fatal("expected error for multipart body")
+	}
+
+	w := httptest.NewRecorder()
+	writeEnforceError(w, err)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected %d got %d", http.StatusBadRequest, w.Code)
+	}
+
+}
+
+func TestQueryRangeHandlerLabelRules(t *testing.T) {
+
+	client := &Client{name: "test"}
+	ts, w, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, "{}", nil, "prometheus",
+		`/api/v1/query_range?query=up{namespace="b"}&start=0&end=60&step=15`, "debug")
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	client.config.LabelRules = testLabelRules
+
+	r.Header.Set("X-Namespace", "a")
+	client.QueryRangeHandler(w, r)
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected %d got %d", http.StatusForbidden, resp.StatusCode)
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := `{"status":"error","errorType":"bad_data","error":"namespace=\"b\" selects a value that is not allowed"}`
+	if string(b) != expected {
+		t.Errorf("expected %s got %s", expected, b)
+	}
+
+}
+
+func TestUnenforcedHandlersLabelRules(t *testing.T) {
+
+	client := &Client{name: "test"}
+	ts, _, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, "{}", nil, "prometheus", "/", "debug")
+	if err != nil {
+		t.Error(err)
+	}
+	defer ts.Close()
+	ctx := r.Context()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.config.HTTPClient = hc
+
+	tests := []struct {
+		path    string
+		handler http.HandlerFunc
+	}{
+		{`/federate?match[]={__name__=~".+"}`, client.ProxyHandler},
+		{`/api/v1/query_exemplars?query=up&start=0&end=60`, client.ProxyHandler},
+		{`/api/v1/targets`, client.ObjectProxyCacheHandler},
+	}
+
+	// without label rules, the requests are proxied
+	for i, test := range tests {
+		r = httptest.NewRequest(http.MethodGet, ts.URL+test.path, nil).WithContext(ctx)
+		w := httptest.NewRecorder()
+		test.handler(w, r)
+		if resp := w.Result(); resp.StatusCode != http.StatusOK {
+			t.Errorf("test %d expected %d got %d", i, http.StatusOK, resp.StatusCode)
+		}
+	}
+
+	// with label rules, endpoints the rules are not applied to are rejected
+	client.config.LabelRules = testLabelRules
+	for i, test := range tests {
+		r = httptest.NewRequest(http.MethodGet, ts.URL+test.path, nil).WithContext(ctx)
+		r.Header.Set("X-Namespace", "a")
+		w := httptest.NewRecorder()
+		test.handler(w, r)
+		resp := w.Result()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("test %d expected %d got %d", i, http.StatusForbidden, resp.StatusCode)
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		expected := `{"status":"error","errorType":"bad_data","error":"` + r.URL.Path +
+			` is not available when label rules are enforced"}`
+		if string(b) != expected {
+			t.Errorf("test %d expected %s got %s", i, expected, b)
+		}
+	}
+}
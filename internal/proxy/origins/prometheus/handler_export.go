@@ -0,0 +1,24 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"net/http"
+)
+
+// ExportHandler proxies requests for the VictoriaMetrics paths /export and /federate, which select
+// series with match[] parameters, to the origin by way of the object proxy cache
+func (c *Client) ExportHandler(w http.ResponseWriter, r *http.Request) {
+	c.metadataHandler(w, r)
+}
@@ -0,0 +1,82 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Comcast/trickster/internal/proxy/headers"
+	"github.com/Comcast/trickster/internal/proxy/request"
+	tu "github.com/Comcast/trickster/internal/util/testing"
+)
+
+func TestExportHandler(t *testing.T) {
+
+	client := &Client{name: "test"}
+	ts, _, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, "{}", nil, "victoriametrics", "/api/v1/export", "debug")
+	ctx := r.Context()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	defer ts.Close()
+	if err != nil {
+		t.Error(err)
+	}
+
+	tests := []struct {
+		method string
+		params url.Values
+		status string
+	}{
+		{http.MethodGet, url.Values{upMatch: {"up"}, upStart: {"3600"}, upEnd: {"7200"}}, "status=kmiss"},
+		{http.MethodGet, url.Values{upMatch: {"up"}, upStart: {"3600"}, upEnd: {"7200"}}, "status=hit"},
+		// the extra_label and extra_filters[] parameters are part of the cache key
+		{http.MethodGet, url.Values{upMatch: {"up"}, upStart: {"3600"}, upEnd: {"7200"}, upExtraLabel: {"env=prod"}}, "status=kmiss"},
+		{http.MethodGet, url.Values{upMatch: {"up"}, upStart: {"3600"}, upEnd: {"7200"}, upExtraFilters: {`{env="prod"}`}}, "status=kmiss"},
+		// as are the values in a form-encoded POST body
+		{http.MethodPost, url.Values{upMatch: {"up"}, upStart: {"3600"}, upEnd: {"7200"}, upExtraLabel: {"env=prod"}}, "status=kmiss"},
+		{http.MethodPost, url.Values{upMatch: {"up"}, upStart: {"3600"}, upEnd: {"7200"}, upExtraLabel: {"env=prod"}}, "status=hit"},
+		{http.MethodPost, url.Values{upMatch: {"up"}, upStart: {"3600"}, upEnd: {"7200"}, upExtraLabel: {"env=dev"}}, "status=kmiss"},
+		// nocache=1 bypasses the cache
+		{http.MethodGet, url.Values{upMatch: {"up"}, upStart: {"3600"}, upEnd: {"7200"}, upNoCache: {"1"}}, "status=proxy-only"},
+		{http.MethodPost, url.Values{upMatch: {"up"}, upStart: {"3600"}, upEnd: {"7200"}, upNoCache: {"1"}}, "status=proxy-only"},
+	}
+
+	for i, test := range tests {
+		if test.method == http.MethodPost {
+			r = httptest.NewRequest(http.MethodPost, ts.URL+"/api/v1/export", strings.NewReader(test.params.Encode()))
+			r.Header.Set(headers.NameContentType, headers.ValueXFormURLEncoded)
+		} else {
+			r = httptest.NewRequest(http.MethodGet, ts.URL+"/api/v1/export?"+test.params.Encode(), nil)
+		}
+		r = r.WithContext(ctx)
+		w := httptest.NewRecorder()
+
+		client.ExportHandler(w, r)
+		resp := w.Result()
+		if resp.StatusCode != 200 {
+			t.Errorf("test %d expected 200 got %d.", i, resp.StatusCode)
+		}
+
+		if h := resp.Header.Get("X-Trickster-Result"); !strings.Contains(h, test.status) {
+			t.Errorf("test %d expected %s got %s", i, test.status, h)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Comcast/trickster/internal/proxy/request"
+	tu "github.com/Comcast/trickster/internal/util/testing"
+)
+
+func TestLabelsHandler(t *testing.T) {
+
+	client := &Client{name: "test"}
+	ts, _, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, "{}", nil, "prometheus", "/api/v1/labels", "debug")
+	ctx := r.Context()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	defer ts.Close()
+	if err != nil {
+		t.Error(err)
+	}
+
+	if _, ok := client.config.Paths[APIPath+mnLabel+"/"]; !ok {
+		t.Errorf("could not find path config named %s", mnLabel)
+	}
+
+	tests := []struct {
+		path   string
+		status string
+	}{
+		{"/api/v1/labels?start=3600&end=7200", "status=kmiss"},
+		{"/api/v1/labels?start=3659&end=7259", "status=hit"},
+		{"/api/v1/labels?start=3660&end=7200", "status=kmiss"},
+		{"/api/v1/labels?start=3660&end=7200&match[]=up", "status=kmiss"},
+		{"/api/v1/label/job/values?start=3600.25&end=7200", "status=kmiss"},
+		{"/api/v1/label/job/values?start=1970-01-01T01:00:30Z&end=7200", "status=hit"},
+		{"/api/v1/label/instance/values?start=3600&end=7200", "status=kmiss"},
+	}
+
+	for i, test := range tests {
+		r = httptest.NewRequest(http.MethodGet, ts.URL+test.path, nil).WithContext(ctx)
+		w := httptest.NewRecorder()
+
+		client.LabelsHandler(w, r)
+		resp := w.Result()
+		if resp.StatusCode != 200 {
+			t.Errorf("test %d expected 200 got %d.", i, resp.StatusCode)
+		}
+
+		if h := resp.Header.Get("X-Trickster-Result"); !strings.Contains(h, test.status) {
+			t.Errorf("test %d expected %s got %s", i, test.status, h)
+		}
+	}
+}
@@ -21,6 +21,13 @@ import (
 
 // ObjectProxyCacheHandler handles calls to /query (for instantaneous values)
 func (c *Client) ObjectProxyCacheHandler(w http.ResponseWriter, r *http.Request) {
+	if c.rejectUnenforced(w, r) {
+		return
+	}
 	r.URL = c.BuildUpstreamURL(r)
+	if c.noCache(r) {
+		engines.DoProxy(w, r)
+		return
+	}
 	engines.ObjectProxyCacheRequest(w, r)
 }
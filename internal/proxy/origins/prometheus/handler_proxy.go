@@ -21,6 +21,9 @@ import (
 
 // ProxyHandler sends a request through the basic reverse proxy to the origin, and services non-cacheable Prometheus API calls.
 func (c *Client) ProxyHandler(w http.ResponseWriter, r *http.Request) {
+	if c.rejectUnenforced(w, r) {
+		return
+	}
 	r.URL = c.BuildUpstreamURL(r)
 	engines.DoProxy(w, r)
 }
@@ -14,28 +14,215 @@
 package prometheus
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/Comcast/trickster/internal/proxy/engines"
+	"github.com/Comcast/trickster/internal/proxy/errors"
+	"github.com/Comcast/trickster/internal/proxy/request"
+	"github.com/Comcast/trickster/internal/proxy/urls"
+	"github.com/Comcast/trickster/internal/timeseries"
+	"github.com/prometheus/prometheus/promql/parser"
 )
 
 // QueryHandler handles calls to /query (for instantaneous values)
 func (c *Client) QueryHandler(w http.ResponseWriter, r *http.Request) {
 
+	if !c.enforceLabels(w, r, upQuery) {
+		return
+	}
+
 	u := c.BuildUpstreamURL(r)
 	params := u.Query()
 
-	// Round time param down to the nearest 15 seconds if it exists
+	if c.noCache(r) {
+		r.URL = u
+		engines.DoProxy(w, r)
+		return
+	}
+
+	// the raw samples of a range vector selector are cached without a step by the Delta Proxy Cache,
+	// so that overlapping ranges of the same selector are only fetched from the origin once
+	if isRangeSelectorQuery(r) {
+		r.URL = u
+		engines.DeltaProxyCacheRequest(w, r)
+		return
+	}
+
+	var quantum time.Duration
+	rsc := request.GetResources(r)
+	if rsc != nil && rsc.PathConfig != nil {
+		quantum = rsc.PathConfig.TimeQuantum
+	}
+
+	if quantum <= 0 {
+		// Round time param down to the nearest 15 seconds if it exists
+		if p := params.Get(upTime); p != "" {
+			if i, err := strconv.ParseInt(p, 10, 64); err == nil {
+				params.Set(upTime, strconv.FormatInt(time.Unix(i, 0).Truncate(time.Second*time.Duration(15)).Unix(), 10))
+			}
+		}
+		r.URL = u
+		r.URL.RawQuery = params.Encode()
+		engines.ObjectProxyCacheRequest(w, r)
+		return
+	}
+
+	oc := rsc.OriginConfig
+	now := time.Now()
+
+	// the parameters of a form POST can be in its body, which take precedence over the URL,
+	// so the evaluation time is rounded in the body and served from the quantized cache key
+	if isFormPost(r) {
+		r.URL = u
+		form, err := formValues(r)
+		if err != nil {
+			engines.DoProxy(w, r)
+			return
+		}
+		t := now
+		p := form.Get(upTime)
+		if p == "" {
+			p = params.Get(upTime)
+		}
+		if p != "" {
+			if t, err = parseTime(p); err != nil {
+				engines.DoProxy(w, r)
+				return
+			}
+		}
+		params.Del(upTime)
+		r.URL.RawQuery = params.Encode()
+		form.Set(upTime, strconv.FormatInt(t.Truncate(quantum).Unix(), 10))
+		setFormBody(r, form)
+		rsc.AlternateCacheTTL = quantum
+		engines.ObjectProxyCacheRequest(w, r)
+		return
+	}
+
+	// Round the evaluation time (which defaults to now) down to the Path's time quantum
+	t := now
 	if p := params.Get(upTime); p != "" {
-		if i, err := strconv.ParseInt(p, 10, 64); err == nil {
-			params.Set(upTime, strconv.FormatInt(time.Unix(i, 0).Truncate(time.Second*time.Duration(15)).Unix(), 10))
+		var err error
+		if t, err = parseTime(p); err != nil {
+			// let the origin respond with the appropriate error
+			r.URL = u
+			engines.DoProxy(w, r)
+			return
 		}
 	}
+	t = t.Truncate(quantum)
+
+	if t.Before(now.Truncate(quantum)) || oc.FastForwardDisable ||
+		oc.FastForwardPath == nil || oc.FastForwardTTL > quantum {
+		params.Set(upTime, strconv.FormatInt(t.Unix(), 10))
+		r.URL = u
+		r.URL.RawQuery = params.Encode()
+		rsc.AlternateCacheTTL = quantum
+		engines.ObjectProxyCacheRequest(w, r)
+		return
+	}
 
+	// the evaluation time is within the current quantum, so the Fast Forward data already
+	// fetched for range queries is fresh enough to use. Requesting it without a time
+	// parameter, using the Fast Forward Path Config and TTL, results in the same cache key.
+	params.Del(upTime)
 	r.URL = u
 	r.URL.RawQuery = params.Encode()
+	rs := rsc.Clone()
+	rs.PathConfig = oc.FastForwardPath
+	rs.AlternateCacheTTL = oc.FastForwardTTL
+	engines.ObjectProxyCacheRequest(w, request.SetResources(r, rs))
+}
+
+// isRangeSelectorQuery returns true if the instant query is only a range vector selector, such as
+// up[5m], without the offset or @ modifiers
+func isRangeSelectorQuery(r *http.Request) bool {
+	qp, err := requestValues(r)
+	if err != nil || hasAtModifier(qp.Get(upQuery)) {
+		return false
+	}
+	expr, err := parser.ParseExpr(qp.Get(upQuery))
+	if err != nil {
+		return false
+	}
+	_, _, ok := rangeSelector(expr)
+	return ok
+}
+
+// parseRangeSelectorQuery parses an instant query for a range vector selector into a TimeRangeQuery
+// without a Step, whose Extent is the range ending at the evaluation time.
+func parseRangeSelectorQuery(r *http.Request) (*timeseries.TimeRangeQuery, error) {
 
-	engines.ObjectProxyCacheRequest(w, r)
+	qp, err := requestValues(r)
+	if err != nil {
+		return nil, err
+	}
+
+	q := qp.Get(upQuery)
+	if q == "" {
+		return nil, errors.MissingURLParam(upQuery)
+	}
+	if hasAtModifier(q) {
+		return nil, errors.ErrNotTimeRangeQuery
+	}
+
+	expr, err := parser.ParseExpr(q)
+	if err != nil {
+		return nil, err
+	}
+	vs, rng, ok := rangeSelector(expr)
+	if !ok {
+		return nil, errors.ErrNotTimeRangeQuery
+	}
+
+	t := time.Now()
+	if p := qp.Get(upTime); p != "" {
+		if t, err = parseTime(p); err != nil {
+			return nil, err
+		}
+	}
+
+	trq := &timeseries.TimeRangeQuery{
+		Statement: normalizeStatement(vs),
+		Extent:    timeseries.Extent{Start: t.Add(-rng), End: t},
+	}
+
+	// the TemplateURL holds the selector without its range, so that all ranges of the
+	// selector share a cache key
+	qp.Set(upQuery, trq.Statement)
+	qp.Del(upTime)
+	trq.TemplateURL = urls.Clone(r.URL)
+	trq.TemplateURL.RawQuery = qp.Encode()
+
+	return trq, nil
+}
+
+// setRangeSelectorExtent rewrites the range and evaluation time of a range vector selector query
+// to cover the provided Extent. The evaluation time has millisecond precision, while the range is
+// widened to whole seconds, which all versions of PromQL accept.
+func setRangeSelectorExtent(r *http.Request, trq *timeseries.TimeRangeQuery, extent *timeseries.Extent) {
+
+	if trq == nil || trq.TemplateURL == nil || extent == nil {
+		return
+	}
+
+	end := extent.End.UnixNano() / int64(time.Millisecond)
+	rng := (end - extent.Start.UnixNano()/int64(time.Millisecond) + 999) / 1000
+	if rng < 1 {
+		// a range selector must have a positive range
+		rng = 1
+	}
+
+	params := trq.TemplateURL.Query()
+	params.Set(upQuery, fmt.Sprintf("%s[%ds]", trq.Statement, rng))
+	params.Set(upTime, fmt.Sprintf("%d.%03d", end/1000, end%1000))
+
+	if isFormPost(r) {
+		setFormBody(r, params)
+		return
+	}
+	r.URL.RawQuery = params.Encode()
 }
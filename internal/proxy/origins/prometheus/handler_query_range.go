@@ -21,6 +21,13 @@ import (
 
 // QueryRangeHandler handles timeseries requests for Prometheus and processes them through the delta proxy cache
 func (c *Client) QueryRangeHandler(w http.ResponseWriter, r *http.Request) {
+	if !c.enforceLabels(w, r, upQuery) {
+		return
+	}
 	r.URL = c.BuildUpstreamURL(r)
+	if c.noCache(r) {
+		engines.DoProxy(w, r)
+		return
+	}
 	engines.DeltaProxyCacheRequest(w, r)
 }
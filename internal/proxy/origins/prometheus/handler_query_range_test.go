@@ -14,9 +14,17 @@
 package prometheus
 
 import (
+	"io"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/Comcast/trickster/internal/proxy/headers"
 	"github.com/Comcast/trickster/internal/proxy/request"
 	tu "github.com/Comcast/trickster/internal/util/testing"
 )
@@ -53,3 +61,90 @@ func TestQueryRangeHandler(t *testing.T) {
 		t.Errorf("expected '{}' got %s.", bodyBytes)
 	}
 }
+
+func TestQueryRangeHandlerFormPost(t *testing.T) {
+
+	client := &Client{name: "test"}
+	ts, w, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, "", nil, "promsim", "/api/v1/query_range", "debug")
+	ctx := r.Context()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	defer ts.Close()
+	if err != nil {
+		t.Error(err)
+	}
+
+	// the range ends before now, so that no fast forward datapoint is appended
+	end := time.Now().Add(-time.Hour).Truncate(time.Minute)
+	form := func(query string, start, end time.Time) io.Reader {
+		return strings.NewReader(url.Values{upQuery: {query}, upStep: {"60"},
+			upStart: {strconv.FormatInt(start.Unix(), 10)}, upEnd: {strconv.FormatInt(end.Unix(), 10)}}.Encode())
+	}
+
+	tests := []struct {
+		query      string
+		start, end time.Time
+		status     string
+	}{
+		{"up", end.Add(-time.Hour), end, "status=kmiss"},
+		// the form values are part of the cache key
+		{"down", end.Add(-time.Hour), end, "status=kmiss"},
+		// the gap is fetched by rewriting the time range in the form body
+		{"up", end.Add(-2 * time.Hour), end, "status=phit"},
+	}
+
+	for i, test := range tests {
+		r = httptest.NewRequest(http.MethodPost, ts.URL+"/api/v1/query_range", form(test.query, test.start, test.end))
+		r.Header.Set(headers.NameContentType, headers.ValueXFormURLEncoded)
+		r = r.WithContext(ctx)
+		w = httptest.NewRecorder()
+
+		client.QueryRangeHandler(w, r)
+		resp := w.Result()
+		if resp.StatusCode != 200 {
+			t.Errorf("test %d expected 200 got %d.", i, resp.StatusCode)
+		}
+
+		if h := resp.Header.Get("X-Trickster-Result"); !strings.Contains(h, "engine=DeltaProxyCache") || !strings.Contains(h, test.status) {
+			t.Errorf("test %d expected delta proxy cache %s got %s", i, test.status, h)
+		}
+
+		bodyBytes, _ := ioutil.ReadAll(resp.Body)
+		ts, err := client.UnmarshalTimeseries(bodyBytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c := ts.TimestampCount(); c != int(test.end.Sub(test.start)/time.Minute)+1 {
+			t.Errorf("test %d expected %d timestamps got %d", i, int(test.end.Sub(test.start)/time.Minute)+1, c)
+		}
+	}
+}
+
+func TestQueryRangeHandlerNoCache(t *testing.T) {
+
+	client := &Client{name: "test"}
+	ts, w, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, "{}", nil, "victoriametrics", "/api/v1/query_range?query=up&start=0&end=900&nocache=1", "debug")
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	defer ts.Close()
+	if err != nil {
+		t.Error(err)
+	}
+
+	client.QueryRangeHandler(w, r)
+
+	resp := w.Result()
+	if resp.StatusCode != 200 {
+		t.Errorf("expected 200 got %d.", resp.StatusCode)
+	}
+
+	if h := resp.Header.Get("X-Trickster-Result"); !strings.Contains(h, "status=proxy-only") {
+		t.Errorf("expected %s got %s", "status=proxy-only", h)
+	}
+}
@@ -14,11 +14,24 @@
 package prometheus
 
 import (
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/Comcast/trickster/internal/proxy/headers"
 	"github.com/Comcast/trickster/internal/proxy/request"
+	"github.com/Comcast/trickster/internal/timeseries"
 	tu "github.com/Comcast/trickster/internal/util/testing"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/promql/parser"
 )
 
 func TestQueryHandler(t *testing.T) {
@@ -58,3 +71,300 @@ func TestQueryHandler(t *testing.T) {
 		t.Errorf("expected '{}' got %s.", bodyBytes)
 	}
 }
+
+func TestQueryHandlerTimeQuantum(t *testing.T) {
+
+	client := &Client{name: "test"}
+	ts, _, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, "", nil, "promsim", "/api/v1/query", "debug")
+	ctx := r.Context()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	defer ts.Close()
+	if err != nil {
+		t.Error(err)
+	}
+
+	rsc.PathConfig.TimeQuantum = time.Minute
+	old := time.Now().Add(-time.Hour).Truncate(time.Minute)
+	now := time.Now()
+
+	get := func(path string, params url.Values) string {
+		r := httptest.NewRequest(http.MethodGet, ts.URL+path+"?"+params.Encode(), nil).WithContext(ctx)
+		// each request gets its own resources, as it would from the router
+		r = request.SetResources(r, rsc.Clone())
+		w := httptest.NewRecorder()
+		if path == APIPath+mnQueryRange {
+			client.QueryRangeHandler(w, r)
+		} else {
+			client.QueryHandler(w, r)
+		}
+		resp := w.Result()
+		if resp.StatusCode != 200 {
+			t.Errorf("expected 200 got %d", resp.StatusCode)
+		}
+		return resp.Header.Get("X-Trickster-Result")
+	}
+
+	tests := []struct {
+		path   string
+		params url.Values
+		status string
+	}{
+		{APIPath + mnQuery, url.Values{upQuery: {"up"}, upTime: {strconv.FormatInt(old.Unix()+10, 10)}}, "status=kmiss"},
+		// times within the same quantum share the cache key
+		{APIPath + mnQuery, url.Values{upQuery: {"up"}, upTime: {strconv.FormatFloat(float64(old.Unix())+20.5, 'f', 1, 64)}}, "status=hit"},
+		{APIPath + mnQuery, url.Values{upQuery: {"up"}, upTime: {old.Add(30 * time.Second).Format(time.RFC3339)}}, "status=hit"},
+		{APIPath + mnQuery, url.Values{upQuery: {"up"}, upTime: {strconv.FormatInt(old.Unix()+60, 10)}}, "status=kmiss"},
+		// a range query ending now fetches and caches the fast forward data
+		{APIPath + mnQueryRange, url.Values{upQuery: {"up"}, upStep: {"60"},
+			upStart: {strconv.FormatInt(now.Add(-time.Hour).Unix(), 10)}, upEnd: {strconv.FormatInt(now.Unix(), 10)}}, "ffstatus=miss"},
+		// which is reused by instant queries for recent times
+		{APIPath + mnQuery, url.Values{upQuery: {"up"}, upTime: {strconv.FormatInt(time.Now().Unix(), 10)}}, "status=hit"},
+		{APIPath + mnQuery, url.Values{upQuery: {"up"}}, "status=hit"},
+	}
+
+	for i, test := range tests {
+		if h := get(test.path, test.params); !strings.Contains(h, test.status) {
+			t.Errorf("test %d expected %s got %s", i, test.status, h)
+		}
+	}
+}
+
+func TestQueryHandlerTimeQuantumFormPost(t *testing.T) {
+
+	client := &Client{name: "test"}
+	ts, _, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, "", nil, "prometheus", "/api/v1/query", "debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+
+	// the origin records the evaluation time of each query it receives
+	var times []string
+	var mtx sync.Mutex
+	os := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		mtx.Lock()
+		times = append(times, r.Form.Get(upTime))
+		mtx.Unlock()
+		w.Write([]byte("{}"))
+	}))
+	defer os.Close()
+	u, _ := url.Parse(os.URL)
+	ctx := r.Context()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.config.Host = u.Host
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	rsc.PathConfig.TimeQuantum = time.Minute
+
+	old := time.Now().Add(-time.Hour).Truncate(time.Minute)
+	post := func(query string, body url.Values) string {
+		r := httptest.NewRequest(http.MethodPost, ts.URL+APIPath+mnQuery+query, strings.NewReader(body.Encode())).WithContext(ctx)
+		r.Header.Set(headers.NameContentType, headers.ValueXFormURLEncoded)
+		r = request.SetResources(r, rsc.Clone())
+		w := httptest.NewRecorder()
+		client.QueryHandler(w, r)
+		resp := w.Result()
+		if resp.StatusCode != 200 {
+			t.Errorf("expected 200 got %d", resp.StatusCode)
+		}
+		return resp.Header.Get("X-Trickster-Result")
+	}
+
+	tests := []struct {
+		query  string
+		body   url.Values
+		status string
+	}{
+		{"", url.Values{upQuery: {"up"}, upTime: {strconv.FormatInt(old.Unix()+10, 10)}}, "status=kmiss"},
+		// times within the same quantum share the cache key
+		{"", url.Values{upQuery: {"up"}, upTime: {old.Add(30 * time.Second).Format(time.RFC3339)}}, "status=hit"},
+		{"?" + upTime + "=" + strconv.FormatInt(old.Unix()+50, 10), url.Values{upQuery: {"up"}}, "status=hit"},
+		// the query in the body is part of the cache key
+		{"", url.Values{upQuery: {"down"}, upTime: {strconv.FormatInt(old.Unix()+10, 10)}}, "status=kmiss"},
+		{"", url.Values{upQuery: {"up"}, upTime: {strconv.FormatInt(old.Unix()+60, 10)}}, "status=kmiss"},
+	}
+
+	for i, test := range tests {
+		if h := post(test.query, test.body); !strings.Contains(h, test.status) {
+			t.Errorf("test %d expected %s got %s", i, test.status, h)
+		}
+	}
+
+	expected := []string{strconv.FormatInt(old.Unix(), 10), strconv.FormatInt(old.Unix(), 10),
+		strconv.FormatInt(old.Unix()+60, 10)}
+	if strings.Join(times, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v got %v", expected, times)
+	}
+}
+
+// rangeTestOrigin simulates instant queries for range vector selectors, with a sample
+// every 10s, and records the extent of each query it receives
+type rangeTestOrigin struct {
+	mtx     sync.Mutex
+	extents timeseries.ExtentList
+}
+
+func (o *rangeTestOrigin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	expr, err := parser.ParseExpr(r.URL.Query().Get(upQuery))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	end, _ := parseTime(r.URL.Query().Get(upTime))
+	e := timeseries.Extent{Start: end.Add(-expr.(*parser.MatrixSelector).Range), End: end}
+	o.mtx.Lock()
+	o.extents = append(o.extents, e)
+	o.mtx.Unlock()
+	s := &model.SampleStream{Metric: model.Metric{"__name__": "up"}}
+	for ts := e.Start.Truncate(10 * time.Second); !ts.After(e.End); ts = ts.Add(10 * time.Second) {
+		if !ts.Before(e.Start) {
+			s.Values = append(s.Values, model.SamplePair{Timestamp: model.TimeFromUnixNano(ts.UnixNano()), Value: 1})
+		}
+	}
+	b, _ := json.Marshal(s)
+	fmt.Fprintf(w, `{"status":"success","data":{"resultType":"matrix","result":[%s]}}`, b)
+}
+
+func (o *rangeTestOrigin) received() timeseries.ExtentList {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	el := o.extents
+	o.extents = nil
+	return el
+}
+
+func TestQueryHandlerRangeSelector(t *testing.T) {
+
+	client := &Client{name: "test"}
+	ts, _, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, "", nil, "prometheus", "/api/v1/query", "debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+	o := &rangeTestOrigin{}
+	os := httptest.NewServer(o)
+	defer os.Close()
+	u, _ := url.Parse(os.URL)
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.config.Host = u.Host
+	client.webClient = hc
+	client.config.HTTPClient = hc
+
+	base := time.Now().Add(-time.Hour).Truncate(time.Minute)
+
+	get := func(q string, at time.Time) *MatrixEnvelope {
+		params := url.Values{upQuery: {q}, upTime: {strconv.FormatInt(at.Unix(), 10)}}
+		req := httptest.NewRequest(http.MethodGet, "http://0"+APIPath+mnQuery+"?"+params.Encode(), nil).
+			WithContext(r.Context())
+		req = request.SetResources(req, rsc.Clone())
+		w := httptest.NewRecorder()
+		client.QueryHandler(w, req)
+		resp := w.Result()
+		if resp.StatusCode != 200 {
+			t.Fatalf("expected 200 got %d", resp.StatusCode)
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		me := &MatrixEnvelope{}
+		if err := json.Unmarshal(b, me); err != nil {
+			t.Fatal(err)
+		}
+		return me
+	}
+
+	me := get("up[5m]", base)
+	if len(me.Data.Result) != 1 || len(me.Data.Result[0].Values) != 31 {
+		t.Fatalf("unexpected response %v", me.Data.Result)
+	}
+	if el := o.received(); len(el) != 1 || !el[0].Start.Equal(base.Add(-5*time.Minute)) || !el[0].End.Equal(base) {
+		t.Errorf("unexpected upstream extents %s", el)
+	}
+
+	// an overlapping range of the same selector only fetches the samples that aren't cached
+	me = get("( up[5m] )", base.Add(2*time.Minute))
+	if len(me.Data.Result) != 1 || len(me.Data.Result[0].Values) != 31 ||
+		!me.Data.Result[0].Values[0].Timestamp.Time().Equal(base.Add(-3*time.Minute)) {
+		t.Errorf("unexpected response %v", me.Data.Result)
+	}
+	if el := o.received(); len(el) != 1 || !el[0].Start.Equal(base) || !el[0].End.Equal(base.Add(2*time.Minute)) {
+		t.Errorf("unexpected upstream extents %s", el)
+	}
+
+	// a fully cached range is not fetched
+	get("up[1m]", base)
+	if el := o.received(); len(el) != 0 {
+		t.Errorf("unexpected upstream extents %s", el)
+	}
+
+}
+
+func TestParseRangeSelectorQuery(t *testing.T) {
+
+	tests := []struct {
+		query string
+		ok    bool
+	}{
+		{`up{job="api"}[5m]`, true},
+		{`(up[5m])`, true},
+		{`up`, false},
+		{`rate(up[5m])`, false},
+		{`up[5m] offset 1h`, false},
+		{`up[5m] @ 1000`, false},
+		{`up[`, false},
+	}
+
+	for i, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "http://0"+APIPath+mnQuery+"?"+
+			url.Values{upQuery: {test.query}, upTime: {"1000"}}.Encode(), nil)
+		if ok := isRangeSelectorQuery(r); ok != test.ok {
+			t.Errorf("test %d: expected %t got %t", i, test.ok, ok)
+		}
+		trq, err := parseRangeSelectorQuery(r)
+		if (err == nil) != test.ok {
+			t.Errorf("test %d: unexpected error %v", i, err)
+		}
+		if !test.ok {
+			continue
+		}
+		if trq.Step != 0 || !trq.Extent.Start.Equal(time.Unix(700, 0)) || !trq.Extent.End.Equal(time.Unix(1000, 0)) {
+			t.Errorf("test %d: unexpected time range query %s", i, trq)
+		}
+		if trq.TemplateURL.Query().Get(upTime) != "" || strings.Contains(trq.TemplateURL.Query().Get(upQuery), "[") {
+			t.Errorf("test %d: unexpected template url %s", i, trq.TemplateURL)
+		}
+	}
+
+}
+
+func TestSetRangeSelectorExtent(t *testing.T) {
+
+	r := httptest.NewRequest(http.MethodGet, "http://0"+APIPath+mnQuery+"?query=up%5B5m%5D&time=1000&timeout=10s", nil)
+	trq, err := parseRangeSelectorQuery(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ranges are widened to whole seconds
+	e := &timeseries.Extent{Start: time.Unix(900, 0).Add(time.Nanosecond), End: time.Unix(1000, 5000000)}
+	setRangeSelectorExtent(r, trq, e)
+	qp := r.URL.Query()
+	if qp.Get(upQuery) != "up[101s]" || qp.Get(upTime) != "1000.005" || qp.Get("timeout") != "10s" {
+		t.Errorf("unexpected query %s", r.URL.RawQuery)
+	}
+
+	// ranges must be positive
+	e = &timeseries.Extent{Start: time.Unix(1000, 0), End: time.Unix(1000, 0)}
+	setRangeSelectorExtent(r, trq, e)
+	if q := r.URL.Query().Get(upQuery); q != "up[1s]" {
+		t.Errorf("expected %s got %s", "up[1s]", q)
+	}
+
+}
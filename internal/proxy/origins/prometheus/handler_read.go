@@ -0,0 +1,143 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/Comcast/trickster/internal/proxy/engines"
+	"github.com/Comcast/trickster/internal/proxy/headers"
+)
+
+// ReadHandler handles Prometheus remote read requests and processes the raw sample queries
+// they contain through the delta proxy cache. Requests with more than one query are split
+// into a request for each, so that each query is cached on its own, and their responses
+// are recombined in the order of the queries.
+func (c *Client) ReadHandler(w http.ResponseWriter, r *http.Request) {
+
+	hasRules := c.config != nil && len(c.config.LabelRules) > 0
+
+	rr, err := readRequestBody(r)
+	if err != nil {
+		// the label rules can't be applied to a request that can't be decoded
+		if hasRules {
+			writeEnforceError(w, err)
+			return
+		}
+		r.URL = c.BuildUpstreamURL(r)
+		engines.DoProxy(w, r)
+		return
+	}
+
+	if hasRules {
+		if err := enforceReadRequest(r, c.config.LabelRules, rr); err != nil {
+			writeEnforceError(w, err)
+			return
+		}
+		setReadRequestBody(r, rr)
+	}
+
+	r.URL = c.BuildUpstreamURL(r)
+
+	// clients that only accept streamed chunks are proxied, with any label rules already applied,
+	// so ProxyHandler, which rejects requests when the origin has label rules, isn't used
+	if !rr.acceptsSamples() || len(rr.queries) == 0 {
+		engines.DoProxy(w, r)
+		return
+	}
+
+	if len(rr.queries) == 1 {
+		engines.DeltaProxyCacheRequest(w, r)
+		return
+	}
+
+	bws := make([]*bufferedWriter, len(rr.queries))
+	wg := sync.WaitGroup{}
+	for i, q := range rr.queries {
+		sr := r.Clone(r.Context())
+		setReadRequestBody(sr, &readRequest{queries: []*readQuery{q}})
+		bws[i] = &bufferedWriter{header: make(http.Header)}
+		wg.Add(1)
+		go func(bw *bufferedWriter, sr *http.Request) {
+			engines.DeltaProxyCacheRequest(bw, sr)
+			wg.Done()
+		}(bws[i], sr)
+	}
+	wg.Wait()
+
+	resp := &ReadResponse{Results: make([]*ReadResult, 0, len(bws))}
+	for _, bw := range bws {
+		// any unsuccessful response is returned as the response to the whole request
+		if bw.status != http.StatusOK {
+			bw.writeTo(w)
+			return
+		}
+		rr2, err := unmarshalReadResponse(bw.buf.Bytes())
+		if err != nil || len(rr2.Results) != 1 {
+			bw.writeTo(w)
+			return
+		}
+		resp.Results = append(resp.Results, rr2.Results[0])
+	}
+
+	b := resp.marshal()
+	h := w.Header()
+	for k, v := range bws[0].header {
+		h[k] = v
+	}
+	h.Set(headers.NameContentLength, strconv.Itoa(len(b)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+// bufferedWriter captures the response to one query of a remote read request with several queries
+type bufferedWriter struct {
+	header http.Header
+	status int
+	buf    bytes.Buffer
+}
+
+// Header returns the header map of the captured response
+func (bw *bufferedWriter) Header() http.Header {
+	return bw.header
+}
+
+// WriteHeader records the status code of the captured response
+func (bw *bufferedWriter) WriteHeader(code int) {
+	bw.status = code
+}
+
+// Write buffers the body of the captured response
+func (bw *bufferedWriter) Write(b []byte) (int, error) {
+	if bw.status == 0 {
+		bw.status = http.StatusOK
+	}
+	return bw.buf.Write(b)
+}
+
+// writeTo writes the captured response to the provided ResponseWriter
+func (bw *bufferedWriter) writeTo(w http.ResponseWriter) {
+	h := w.Header()
+	for k, v := range bw.header {
+		h[k] = v
+	}
+	if bw.status == 0 {
+		bw.status = http.StatusOK
+	}
+	w.WriteHeader(bw.status)
+	w.Write(bw.buf.Bytes())
+}
@@ -0,0 +1,287 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Comcast/trickster/internal/config"
+	"github.com/Comcast/trickster/internal/proxy/headers"
+	"github.com/Comcast/trickster/internal/proxy/request"
+	tu "github.com/Comcast/trickster/internal/util/testing"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/pkg/labels"
+)
+
+// readTestOrigin simulates the remote read API of Prometheus, with a sample every 10s
+// for the series selected by each query, and records the queries it receives
+type readTestOrigin struct {
+	mtx     sync.Mutex
+	queries []*readQuery
+}
+
+func (o *readTestOrigin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rr, err := readRequestBody(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	resp := &ReadResponse{}
+	for _, q := range rr.queries {
+		o.mtx.Lock()
+		o.queries = append(o.queries, q)
+		o.mtx.Unlock()
+		ls := labels.Labels{}
+		for _, m := range q.matchers {
+			ls = append(ls, labels.Label{Name: m.Name, Value: m.Value})
+		}
+		s := &ReadSeries{Labels: labels.New(ls...)}
+		for ts := (q.start + 9999) / 10000 * 10000; ts <= q.end; ts += 10000 {
+			s.Samples = append(s.Samples, ReadSample{Timestamp: ts, Value: float64(ts / 1000)})
+		}
+		resp.Results = append(resp.Results, &ReadResult{Series: []*ReadSeries{s}})
+	}
+	w.Header().Set(headers.NameContentType, mediaTypeProtobuf)
+	w.Header().Set(headers.NameContentEncoding, encodingSnappy)
+	w.Write(resp.marshal())
+}
+
+func (o *readTestOrigin) received() []*readQuery {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	q := o.queries
+	o.queries = nil
+	return q
+}
+
+func newReadTestClient(t *testing.T) (*Client, *readTestOrigin, *http.Request, func()) {
+	client := &Client{name: "test"}
+	ts, _, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, "", nil, "prometheus", "/api/v1/read", "debug")
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := &readTestOrigin{}
+	os := httptest.NewServer(o)
+	u, _ := url.Parse(os.URL)
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.config.Host = u.Host
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	return client, o, r, func() { ts.Close(); os.Close() }
+}
+
+func serveReadRequest(client *Client, r *http.Request, rr *readRequest) (*http.Response, *ReadResponse, error) {
+	req := newReadRequest(rr).WithContext(r.Context())
+	w := httptest.NewRecorder()
+	client.ReadHandler(w, req)
+	resp := w.Result()
+	b, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil, nil
+	}
+	rr2, err := unmarshalReadResponse(b)
+	return resp, rr2, err
+}
+
+func TestReadHandler(t *testing.T) {
+
+	client, o, r, closer := newReadTestClient(t)
+	defer closer()
+
+	base := time.Now().Add(-time.Hour).Truncate(time.Minute)
+	ms := msTimestamp(base)
+
+	// the first request is a key miss
+	q := testReadQuery(ms, ms+300000, "__name__", "=", "up", "job", "=", "api")
+	resp, rr, err := serveReadRequest(client, r, &readRequest{queries: []*readQuery{q}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 got %d", resp.StatusCode)
+	}
+	if len(rr.Results) != 1 || len(rr.Results[0].Series) != 1 || len(rr.Results[0].Series[0].Samples) != 31 {
+		t.Fatalf("unexpected response %v", rr.Results)
+	}
+	if len(rr.ExtentList) != 0 {
+		t.Errorf("expected no extents in the client response")
+	}
+	if got := o.received(); len(got) != 1 {
+		t.Errorf("expected %d upstream queries got %d", 1, len(got))
+	}
+
+	// an overlapping request with reordered matchers only fetches the samples that aren't cached
+	q = testReadQuery(ms+120000, ms+480000, "job", "=", "api", "__name__", "=", "up")
+	_, rr, err = serveReadRequest(client, r, &readRequest{queries: []*readQuery{q}, responseTypes: []uint64{1, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := rr.Results[0].Series[0]
+	if len(s.Samples) != 37 || s.Samples[0].Timestamp != ms+120000 || s.Samples[36].Timestamp != ms+480000 {
+		t.Errorf("unexpected samples %v", testSeriesTimestamps(s))
+	}
+	got := o.received()
+	if len(got) != 1 {
+		t.Fatalf("expected %d upstream queries got %d", 1, len(got))
+	}
+	if got[0].start != ms+300000 || got[0].end != ms+480000 {
+		t.Errorf("expected %d-%d got %d-%d", ms+300000, ms+480000, got[0].start, got[0].end)
+	}
+
+	// a request within the cached range is a full hit
+	q = testReadQuery(ms+60000, ms+400000, "job", "=", "api", "__name__", "=", "up")
+	_, rr, err = serveReadRequest(client, r, &readRequest{queries: []*readQuery{q}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(rr.Results[0].Series[0].Samples); n != 35 {
+		t.Errorf("expected %d got %d", 35, n)
+	}
+	if got := o.received(); len(got) != 0 {
+		t.Errorf("expected %d upstream queries got %d", 0, len(got))
+	}
+}
+
+func TestReadHandlerMultipleQueries(t *testing.T) {
+
+	client, o, r, closer := newReadTestClient(t)
+	defer closer()
+
+	ms := msTimestamp(time.Now().Add(-time.Hour).Truncate(time.Minute))
+
+	q1 := testReadQuery(ms, ms+60000, "__name__", "=", "up", "job", "=", "a")
+	q2 := testReadQuery(ms, ms+120000, "__name__", "=", "up", "job", "=", "b")
+	_, rr, err := serveReadRequest(client, r, &readRequest{queries: []*readQuery{q1, q2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rr.Results) != 2 {
+		t.Fatalf("expected %d got %d", 2, len(rr.Results))
+	}
+	if s := rr.Results[0].Series[0]; s.Labels.Get("job") != "a" || len(s.Samples) != 7 {
+		t.Errorf("unexpected series %v", s)
+	}
+	if s := rr.Results[1].Series[0]; s.Labels.Get("job") != "b" || len(s.Samples) != 13 {
+		t.Errorf("unexpected series %v", s)
+	}
+	if got := o.received(); len(got) != 2 {
+		t.Errorf("expected %d upstream queries got %d", 2, len(got))
+	}
+
+	// each query is cached on its own
+	_, rr, err = serveReadRequest(client, r, &readRequest{queries: []*readQuery{q2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rr.Results) != 1 || len(rr.Results[0].Series[0].Samples) != 13 {
+		t.Errorf("unexpected response %v", rr.Results)
+	}
+	if got := o.received(); len(got) != 0 {
+		t.Errorf("expected %d upstream queries got %d", 0, len(got))
+	}
+}
+
+func TestReadHandlerProxy(t *testing.T) {
+
+	client, o, r, closer := newReadTestClient(t)
+	defer closer()
+
+	ms := msTimestamp(time.Now().Add(-time.Hour).Truncate(time.Minute))
+	q := testReadQuery(ms, ms+60000, "__name__", "=", "up")
+
+	// clients that only accept streamed chunks are proxied with their request unchanged
+	for i := 0; i < 2; i++ {
+		_, _, err := serveReadRequest(client, r, &readRequest{queries: []*readQuery{q}, responseTypes: []uint64{1}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := o.received(); len(got) != 2 {
+		t.Errorf("expected %d upstream queries got %d", 2, len(got))
+	}
+
+	// requests that can't be decoded are proxied
+	req := newRawReadRequest(snappy.Encode(nil, []byte{0x0a})).WithContext(r.Context())
+	w := httptest.NewRecorder()
+	client.ReadHandler(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected %d got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestReadHandlerLabelRules(t *testing.T) {
+
+	client, o, r, closer := newReadTestClient(t)
+	defer closer()
+	client.config.LabelRules = map[string]*config.LabelRuleConfig{
+		"namespace": {Label: "namespace", Header: "X-Namespace"},
+	}
+
+	ms := msTimestamp(time.Now().Add(-time.Hour).Truncate(time.Minute))
+	q := testReadQuery(ms, ms+60000, "__name__", "=", "up")
+
+	req := newReadRequest(&readRequest{queries: []*readQuery{q}}).WithContext(r.Context())
+	req.Header.Set("X-Namespace", "team-a")
+	w := httptest.NewRecorder()
+	client.ReadHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, w.Code)
+	}
+	got := o.received()
+	if len(got) != 1 || got[0].selector() != `up{namespace="team-a"}` {
+		t.Errorf("unexpected upstream queries %v", got)
+	}
+
+	// clients that only accept streamed chunks are proxied with the rules applied
+	req = newReadRequest(&readRequest{queries: []*readQuery{q}, responseTypes: []uint64{1}}).WithContext(r.Context())
+	req.Header.Set("X-Namespace", "team-a")
+	w = httptest.NewRecorder()
+	client.ReadHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d got %d", http.StatusOK, w.Code)
+	}
+	got = o.received()
+	if len(got) != 1 || got[0].selector() != `up{namespace="team-a"}` {
+		t.Errorf("unexpected upstream queries %v", got)
+	}
+
+	// requests without the header are forbidden
+	req = newReadRequest(&readRequest{queries: []*readQuery{q}}).WithContext(r.Context())
+	w = httptest.NewRecorder()
+	client.ReadHandler(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected %d got %d", http.StatusForbidden, w.Code)
+	}
+
+	// requests that can't be decoded can't be restricted
+	req = newRawReadRequest([]byte("invalid")).WithContext(r.Context())
+	req.Header.Set("X-Namespace", "team-a")
+	w = httptest.NewRecorder()
+	client.ReadHandler(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected %d got %d", http.StatusBadRequest, w.Code)
+	}
+	if got := o.received(); len(got) != 0 {
+		t.Errorf("expected %d upstream queries got %d", 0, len(got))
+	}
+}
@@ -15,33 +15,71 @@ package prometheus
 
 import (
 	"net/http"
+	"net/url"
 	"strconv"
 	"time"
 
 	"github.com/Comcast/trickster/internal/proxy/engines"
+	"github.com/Comcast/trickster/internal/proxy/request"
 )
 
 // SeriesHandler proxies requests for path /series to the origin by way of the object proxy cache
 func (c *Client) SeriesHandler(w http.ResponseWriter, r *http.Request) {
-	u := c.BuildUpstreamURL(r)
+	c.metadataHandler(w, r)
+}
 
-	params := u.Query()
+// metadataHandler proxies requests for series and label metadata to the origin by way of the
+// object proxy cache, after rounding their start and end times down to the Path's time quantum
+// for cacheability
+func (c *Client) metadataHandler(w http.ResponseWriter, r *http.Request) {
 
-	// Round Start and End times down to top of most recent minute for cacheability
-	if p := params.Get(upStart); p != "" {
-		if i, err := strconv.ParseInt(p, 10, 64); err == nil {
-			params.Set(upStart, strconv.FormatInt(time.Unix(i, 0).Truncate(time.Second*time.Duration(60)).Unix(), 10))
-		}
+	if !c.enforceLabels(w, r, upMatch) {
+		return
 	}
 
-	if p := params.Get(upEnd); p != "" {
-		if i, err := strconv.ParseInt(p, 10, 64); err == nil {
-			params.Set(upEnd, strconv.FormatInt(time.Unix(i, 0).Truncate(time.Second*time.Duration(60)).Unix(), 10))
-		}
+	u := c.BuildUpstreamURL(r)
+
+	if c.noCache(r) {
+		r.URL = u
+		engines.DoProxy(w, r)
+		return
+	}
+
+	var quantum time.Duration
+	if rsc := request.GetResources(r); rsc != nil && rsc.PathConfig != nil {
+		quantum = rsc.PathConfig.TimeQuantum
 	}
 
+	params := u.Query()
+	roundTimeParams(params, quantum)
 	r.URL = u
 	r.URL.RawQuery = params.Encode()
 
+	// parameters like match[] can be sent in the body to work around URL length limits
+	if isFormPost(r) {
+		form, err := formValues(r)
+		if err != nil {
+			engines.DoProxy(w, r)
+			return
+		}
+		roundTimeParams(form, quantum)
+		setFormBody(r, form)
+	}
+
 	engines.ObjectProxyCacheRequest(w, r)
 }
+
+// roundTimeParams rounds the start and end parameters, when present as unix or RFC3339
+// timestamps, down to the provided quantum
+func roundTimeParams(params url.Values, quantum time.Duration) {
+	if quantum <= 0 {
+		return
+	}
+	for _, k := range []string{upStart, upEnd} {
+		if p := params.Get(k); p != "" {
+			if t, err := parseTime(p); err == nil {
+				params.Set(k, strconv.FormatInt(t.Truncate(quantum).Unix(), 10))
+			}
+		}
+	}
+}
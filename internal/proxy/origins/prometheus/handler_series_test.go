@@ -15,8 +15,14 @@ package prometheus
 
 import (
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/Comcast/trickster/internal/proxy/headers"
 	"github.com/Comcast/trickster/internal/proxy/request"
 	tu "github.com/Comcast/trickster/internal/util/testing"
 )
@@ -58,3 +64,78 @@ func TestSeriesHandler(t *testing.T) {
 		t.Errorf("expected '{}' got %s.", bodyBytes)
 	}
 }
+
+func TestSeriesHandlerTimeQuantum(t *testing.T) {
+
+	client := &Client{name: "test"}
+	ts, _, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, "{}", nil, "prometheus", "/api/v1/series", "debug")
+	ctx := r.Context()
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	client.config.HTTPClient = hc
+	defer ts.Close()
+	if err != nil {
+		t.Error(err)
+	}
+
+	tests := []struct {
+		method string
+		params url.Values
+		status string
+	}{
+		{http.MethodGet, url.Values{upMatch: {"up", "down"}, upStart: {"3600"}, upEnd: {"7210"}}, "status=kmiss"},
+		// float and RFC3339 times are rounded to the same minute
+		{http.MethodGet, url.Values{upMatch: {"up", "down"}, upStart: {"3630.5"}, upEnd: {"1970-01-01T02:00:30Z"}}, "status=hit"},
+		// all match[] values are part of the cache key
+		{http.MethodGet, url.Values{upMatch: {"up", "sideways"}, upStart: {"3600"}, upEnd: {"7210"}}, "status=kmiss"},
+		// as are the values in a form-encoded POST body
+		{http.MethodPost, url.Values{upMatch: {"up", "down"}, upStart: {"3600"}, upEnd: {"7210"}}, "status=kmiss"},
+		{http.MethodPost, url.Values{upMatch: {"up", "down"}, upStart: {"3601"}, upEnd: {"7201.5"}}, "status=hit"},
+		{http.MethodPost, url.Values{upMatch: {"up", "sideways"}, upStart: {"3601"}, upEnd: {"7201.5"}}, "status=kmiss"},
+	}
+
+	for i, test := range tests {
+		if test.method == http.MethodPost {
+			r = httptest.NewRequest(http.MethodPost, ts.URL+"/api/v1/series", strings.NewReader(test.params.Encode()))
+			r.Header.Set(headers.NameContentType, headers.ValueXFormURLEncoded)
+		} else {
+			r = httptest.NewRequest(http.MethodGet, ts.URL+"/api/v1/series?"+test.params.Encode(), nil)
+		}
+		r = r.WithContext(ctx)
+		w := httptest.NewRecorder()
+
+		client.SeriesHandler(w, r)
+		resp := w.Result()
+		if resp.StatusCode != 200 {
+			t.Errorf("test %d expected 200 got %d.", i, resp.StatusCode)
+		}
+
+		if h := resp.Header.Get("X-Trickster-Result"); !strings.Contains(h, test.status) {
+			t.Errorf("test %d expected %s got %s", i, test.status, h)
+		}
+	}
+}
+
+func TestRoundTimeParams(t *testing.T) {
+
+	params := url.Values{upStart: {"2020-05-01T10:00:59.5Z"}, upEnd: {"1588327259.999"}, upMatch: {"up"}}
+	roundTimeParams(params, time.Minute)
+	if params.Get(upStart) != "1588327200" || params.Get(upEnd) != "1588327200" || params.Get(upMatch) != "up" {
+		t.Errorf("unexpected params %v", params)
+	}
+
+	// unparseable times are left for the origin to reject
+	params = url.Values{upStart: {"yesterday"}}
+	roundTimeParams(params, time.Minute)
+	if params.Get(upStart) != "yesterday" {
+		t.Errorf("expected %s got %s", "yesterday", params.Get(upStart))
+	}
+
+	params = url.Values{upStart: {"1588327259"}}
+	roundTimeParams(params, 0)
+	if params.Get(upStart) != "1588327259" {
+		t.Errorf("expected %s got %s", "1588327259", params.Get(upStart))
+	}
+}
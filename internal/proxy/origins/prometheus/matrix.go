@@ -148,6 +148,10 @@ func (me *MatrixEnvelope) CropToSize(sz int, t time.Time, lur timeseries.Extent)
 	}
 
 	rc := tc - sz // # of required timestamps we must delete to meet the rentention policy
+	if me.StepDuration <= 0 {
+		me.cropRawToSize(el, rc)
+		return
+	}
 	removals := make(map[time.Time]bool)
 	done := false
 	var ok bool
@@ -200,6 +204,22 @@ func (me *MatrixEnvelope) CropToSize(sz int, t time.Time, lur timeseries.Extent)
 	me.Sort()
 }
 
+// cropRawToSize removes rc timestamps from a Timeseries of raw samples, which has no Step to walk
+func (me *MatrixEnvelope) cropRawToSize(el timeseries.ExtentListLRU, rc int) {
+	removals, el2 := el.RemoveOldest(times.FromMap(me.timestamps), rc)
+	for _, s := range me.Data.Result {
+		tmp := s.Values[:0]
+		for _, p := range s.Values {
+			if !removals[p.Timestamp.Time()] {
+				tmp = append(tmp, p)
+			}
+		}
+		s.Values = tmp
+	}
+	me.ExtentList = el2
+	me.Sort()
+}
+
 // CropToRange reduces the Timeseries down to timestamps contained within the provided Extents (inclusive).
 // CropToRange assumes the base Timeseries is already sorted, and will corrupt an unsorted Timeseries
 func (me *MatrixEnvelope) CropToRange(e timeseries.Extent) {
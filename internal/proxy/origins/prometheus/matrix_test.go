@@ -502,6 +502,43 @@ func TestMerge(t *testing.T) {
 	}
 }
 
+func TestCropToSizeRaw(t *testing.T) {
+
+	// raw samples are not aligned to a step
+	me := &MatrixEnvelope{
+		Data: MatrixData{
+			ResultType: "matrix",
+			Result: model.Matrix{
+				&model.SampleStream{
+					Metric: model.Metric{"__name__": "a"},
+					Values: []model.SamplePair{
+						{Timestamp: 1444004600123, Value: 1.5},
+						{Timestamp: 1444004613456, Value: 1.5},
+						{Timestamp: 1444004621789, Value: 1.5},
+					},
+				},
+			},
+		},
+		ExtentList: timeseries.ExtentList{
+			timeseries.Extent{Start: time.Unix(1444004600, 0), End: time.Unix(1444004630, 0)},
+		},
+	}
+
+	me.CropToSize(2, time.Now(), timeseries.Extent{})
+
+	if len(me.Data.Result[0].Values) != 2 || me.Data.Result[0].Values[0].Timestamp != 1444004613456 {
+		t.Errorf("unexpected values %v", me.Data.Result[0].Values)
+	}
+
+	el := timeseries.ExtentList{timeseries.Extent{
+		Start: model.Time(1444004600123).Time().Add(time.Nanosecond), End: time.Unix(1444004630, 0)}}
+	if len(me.ExtentList) != 1 || !me.ExtentList[0].Start.Equal(el[0].Start) ||
+		!me.ExtentList[0].End.Equal(el[0].End) {
+		t.Errorf("expected %s got %s", el, me.ExtentList)
+	}
+
+}
+
 func TestCropToRange(t *testing.T) {
 	tests := []struct {
 		before, after *MatrixEnvelope
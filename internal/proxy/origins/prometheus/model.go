@@ -54,16 +54,25 @@ type MatrixData struct {
 	Result     model.Matrix `json:"result"`
 }
 
-// MarshalTimeseries converts a Timeseries into a JSON blob
+// MarshalTimeseries converts a Timeseries into a JSON blob, or a remote read response
 func (c *Client) MarshalTimeseries(ts timeseries.Timeseries) ([]byte, error) {
+	if rr, ok := ts.(*ReadResponse); ok {
+		return rr.marshal(), nil
+	}
 	// Marshal the Envelope back to a json object for Cache Storage
 	return json.Marshal(ts)
 }
 
-// UnmarshalTimeseries converts a JSON blob into a Timeseries
+// UnmarshalTimeseries converts a JSON blob, or a remote read response, into a Timeseries
 func (c *Client) UnmarshalTimeseries(data []byte) (timeseries.Timeseries, error) {
 	me := &MatrixEnvelope{}
 	err := json.Unmarshal(data, &me)
+	if err != nil {
+		// remote read responses are snappy-compressed protocol buffers rather than JSON
+		if rr, err2 := unmarshalReadResponse(data); err2 == nil {
+			return rr, nil
+		}
+	}
 	return me, err
 }
 
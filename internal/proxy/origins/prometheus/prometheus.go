@@ -28,7 +28,9 @@ import (
 	"github.com/Comcast/trickster/internal/proxy"
 	"github.com/Comcast/trickster/internal/proxy/errors"
 	tt "github.com/Comcast/trickster/internal/proxy/timeconv"
+	"github.com/Comcast/trickster/internal/proxy/urls"
 	"github.com/Comcast/trickster/internal/timeseries"
+	"github.com/prometheus/prometheus/promql/parser"
 )
 
 // Prometheus API
@@ -45,6 +47,7 @@ const (
 	mnAlerts        = "alerts"
 	mnAlertManagers = "alertmanagers"
 	mnStatus        = "status"
+	mnRead          = "read"
 )
 
 // Common URL Parameter Names
@@ -130,8 +133,19 @@ func parseDuration(input string) (time.Duration, error) {
 // ParseTimeRangeQuery parses the key parts of a TimeRangeQuery from the inbound HTTP Request
 func (c *Client) ParseTimeRangeQuery(r *http.Request) (*timeseries.TimeRangeQuery, error) {
 
+	if isRemoteRead(r) {
+		return parseReadRequest(r)
+	}
+
+	if strings.HasSuffix(r.URL.Path, "/"+mnQuery) {
+		return parseRangeSelectorQuery(r)
+	}
+
 	trq := &timeseries.TimeRangeQuery{Extent: timeseries.Extent{}}
-	qp := r.URL.Query()
+	qp, err := requestValues(r)
+	if err != nil {
+		return nil, err
+	}
 
 	trq.Statement = qp.Get(upQuery)
 	if trq.Statement == "" {
@@ -164,14 +178,48 @@ func (c *Client) ParseTimeRangeQuery(r *http.Request) (*timeseries.TimeRangeQuer
 			return nil, err
 		}
 		trq.Step = step
+	} else if isVictoriaMetrics(c.config) {
+		// VictoriaMetrics evaluates range queries without a step at its default step, which is
+		// included in the cache key so that these requests share results with those providing it
+		trq.Step = vmDefaultStep
+		qp.Set(upStep, strconv.FormatInt(int64(vmDefaultStep/time.Second), 10))
 	} else {
 		return nil, errors.MissingURLParam(upStep)
 	}
 
-	if strings.Contains(trq.Statement, " offset ") {
-		trq.IsOffset = true
+	// the @ modifier can pin evaluation to the start or end of the requested range,
+	// so the results for different ranges can't be merged
+	if hasAtModifier(trq.Statement) {
+		return nil, errors.ErrNotTimeRangeQuery
+	}
+
+	if expr, err := parser.ParseExpr(trq.Statement); err == nil {
+		trq.IsOffset = hasOffset(expr)
+		trq.Lookback = lookback(expr)
+		trq.Statement = normalizeStatement(expr)
+	} else {
+		// queries that can't be parsed, such as those using extensions to PromQL, are keyed on the raw statement
+		trq.IsOffset = strings.Contains(trq.Statement, " offset ")
+	}
+
+	// each step is evaluated only from the samples within the lookback before it, so aligning
+	// the start to the step moves the evaluation timestamps without changing their results
+	if trq.Lookback > 0 && trq.Step > 0 {
+		trq.Extent.Start = trq.Extent.Start.Truncate(trq.Step)
+	}
+
+	if trq.IsOffset {
 		trq.FastForwardDisable = true
 	}
 
+	// the TemplateURL holds the normalized statement and any parameters of a form-encoded POST body,
+	// other than the time range, so that the request is cache-keyed on them and the body of a
+	// POST can be rebuilt for each extent
+	qp.Set(upQuery, trq.Statement)
+	qp.Del(upStart)
+	qp.Del(upEnd)
+	trq.TemplateURL = urls.Clone(r.URL)
+	trq.TemplateURL.RawQuery = qp.Encode()
+
 	return trq, nil
 }
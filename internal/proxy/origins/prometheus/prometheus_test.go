@@ -15,16 +15,20 @@ package prometheus
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	cr "github.com/Comcast/trickster/internal/cache/registration"
 	"github.com/Comcast/trickster/internal/config"
 	"github.com/Comcast/trickster/internal/proxy/errors"
+	"github.com/Comcast/trickster/internal/proxy/headers"
 	"github.com/Comcast/trickster/internal/proxy/origins"
+	"github.com/Comcast/trickster/internal/timeseries"
 )
 
 func TestPrometheusClientInterfacing(t *testing.T) {
@@ -187,6 +191,67 @@ func TestParseTimeRangeQuery(t *testing.T) {
 	}
 }
 
+func TestParseTimeRangeQueryFormPost(t *testing.T) {
+
+	body := "query=up&start=0&end=900&step=15&timeout=10s"
+	req, _ := http.NewRequest(http.MethodPost, "http://blah.com/api/v1/query_range?step=30&dedup=true", strings.NewReader(body))
+	req.Header.Set(headers.NameContentType, headers.ValueXFormURLEncoded)
+
+	client := &Client{}
+	res, err := client.ParseTimeRangeQuery(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// body parameters take precedence over url parameters
+	if res.Step != 15*time.Second || res.Extent.End.Unix() != 900 {
+		t.Errorf("unexpected step %s or extent %v", res.Step, res.Extent)
+	}
+
+	const expected = "dedup=true&query=up&step=15&timeout=10s"
+	if res.TemplateURL == nil || res.TemplateURL.RawQuery != expected {
+		t.Errorf("expected %s got %v", expected, res.TemplateURL)
+	}
+
+	// the body is restored for proxying
+	if b, _ := ioutil.ReadAll(req.Body); string(b) != body {
+		t.Errorf("expected %s got %s", body, b)
+	}
+
+	req, _ = http.NewRequest(http.MethodPost, "http://blah.com/api/v1/query_range", strings.NewReader("query=%zz"))
+	req.Header.Set(headers.NameContentType, headers.ValueXFormURLEncoded)
+	if _, err = client.ParseTimeRangeQuery(req); err == nil {
+		t.Error("expected error for invalid form body")
+	}
+}
+
+func TestParseTimeRangeQueryStartAlignment(t *testing.T) {
+
+	client := &Client{}
+
+	tests := []struct {
+		query         string
+		expectedStart int64
+	}{
+		{`rate(up[5m])`, 900},
+		{`up +`, 907},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "http://blah.com/api/v1/query_range?"+url.Values{
+				"query": {test.query}, "start": {"907"}, "end": {"1800"}, "step": {"15"}}.Encode(), nil)
+			res, err := client.ParseTimeRangeQuery(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Extent.Start.Unix() != test.expectedStart {
+				t.Errorf("expected %d got %d", test.expectedStart, res.Extent.Start.Unix())
+			}
+		})
+	}
+}
+
 func TestParseTimeRangeQueryMissingQuery(t *testing.T) {
 	expected := errors.MissingURLParam(upQuery).Error()
 	req := &http.Request{URL: &url.URL{
@@ -356,6 +421,43 @@ func TestParseTimeRangeQueryNoStep(t *testing.T) {
 	}
 }
 
+func TestParseTimeRangeQueryVictoriaMetricsNoStep(t *testing.T) {
+	req := &http.Request{URL: &url.URL{
+		Scheme: "https",
+		Host:   "blah.com",
+		Path:   "/",
+		RawQuery: url.Values(map[string][]string{
+			"query":       {`rollup_candlestick(price_usd{ticker="ABC"}[1h])`},
+			"start":       {strconv.Itoa(int(time.Now().Add(time.Duration(-6) * time.Hour).Unix()))},
+			"end":         {strconv.Itoa(int(time.Now().Unix()))},
+			"extra_label": {"env=prod"},
+		}).Encode(),
+	}}
+	client := &Client{config: &config.OriginConfig{OriginType: "victoriametrics"}}
+	res, err := client.ParseTimeRangeQuery(req)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if res.Step != 5*time.Minute {
+		t.Errorf("expected %s got %s", 5*time.Minute, res.Step)
+	}
+
+	// MetricsQL functions are keyed on the raw statement
+	if res.Statement != `rollup_candlestick(price_usd{ticker="ABC"}[1h])` {
+		t.Errorf("unexpected statement %s", res.Statement)
+	}
+
+	params := res.TemplateURL.Query()
+	if params.Get(upStep) != "300" {
+		t.Errorf("expected %s got %s", "300", params.Get(upStep))
+	}
+	if params.Get(upExtraLabel) != "env=prod" {
+		t.Errorf("expected %s got %s", "env=prod", params.Get(upExtraLabel))
+	}
+}
+
 func TestParseTimeRangeQueryWithOffset(t *testing.T) {
 	req := &http.Request{URL: &url.URL{
 		Scheme: "https",
@@ -381,6 +483,70 @@ func TestParseTimeRangeQueryWithOffset(t *testing.T) {
 
 }
 
+func TestParseTimeRangeQueryNormalized(t *testing.T) {
+
+	client := &Client{}
+	parse := func(query string) (*timeseries.TimeRangeQuery, error) {
+		req := &http.Request{URL: &url.URL{
+			Scheme: "https",
+			Host:   "blah.com",
+			Path:   "/",
+			RawQuery: url.Values(map[string][]string{
+				"query": {query},
+				"start": {"0"},
+				"end":   {"900"},
+				"step":  {"15"},
+			}).Encode(),
+		}}
+		return client.ParseTimeRangeQuery(req)
+	}
+
+	// equivalent queries share the same statement and cache key parameters
+	res, err := parse(`sum(rate(http_requests_total{job="a",env="b"}[5m])) by (code)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res2, err := parse(`sum by (code) (rate( ( http_requests_total{env="b", job="a"}[5m] ) ))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const expected = `sum by(code) (rate(http_requests_total{env="b",job="a"}[5m]))`
+	if res.Statement != expected || res2.Statement != expected {
+		t.Errorf("expected %s got %s and %s", expected, res.Statement, res2.Statement)
+	}
+
+	if res.TemplateURL.Query().Get(upQuery) != expected || res.TemplateURL.Query().Get(upStart) != "" {
+		t.Errorf("unexpected template url %s", res.TemplateURL)
+	}
+
+	if res.IsOffset || res.FastForwardDisable || res.Lookback != 5*time.Minute {
+		t.Errorf("unexpected offset %t or lookback %s", res.IsOffset, res.Lookback)
+	}
+
+	// an offset within a label value is not an offset modifier
+	res, err = parse(`up{job=" offset "}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.IsOffset {
+		t.Error("expected no offset")
+	}
+
+	res, err = parse(`rate(up[1m] offset 1h)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.IsOffset || !res.FastForwardDisable {
+		t.Error("expected offset")
+	}
+
+	// queries using the @ modifier are not time range queries
+	if _, err = parse(`up @ start()`); err != errors.ErrNotTimeRangeQuery {
+		t.Errorf("expected %v got %v", errors.ErrNotTimeRangeQuery, err)
+	}
+}
+
 func TestSetCache(t *testing.T) {
 	c, err := NewClient("test", config.NewOriginConfig(), nil)
 	if err != nil {
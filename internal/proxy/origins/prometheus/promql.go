@@ -0,0 +1,180 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// This file handles PromQL parsing, to normalize queries for cache key hashing, and to detect
+// the parts of a query that affect how its results can be cached.
+
+// defaultLookbackDelta is how far Prometheus looks back for the latest sample of an instant vector selector
+const defaultLookbackDelta = 5 * time.Minute
+
+// normalizeStatement returns the canonical form of a parsed PromQL expression, so that equivalent
+// queries share a cache key regardless of their whitespace, label matcher order or redundant parentheses
+func normalizeStatement(expr parser.Expr) string {
+	printed := expr.String()
+	s := normalizeExpr(expr).String()
+	// never key on a canonical form that would not parse to the same expression
+	if expr2, err := parser.ParseExpr(s); err != nil || normalizeExpr(expr2).String() != s {
+		return printed
+	}
+	return s
+}
+
+// normalizeExpr removes redundant parentheses from the expression and sorts its grouping and
+// vector matching labels. Label matchers are sorted when the expression is printed.
+func normalizeExpr(e parser.Expr) parser.Expr {
+	switch n := e.(type) {
+	case *parser.ParenExpr:
+		return normalizeExpr(n.Expr)
+	case *parser.AggregateExpr:
+		n.Expr = normalizeExpr(n.Expr)
+		if n.Param != nil {
+			n.Param = normalizeExpr(n.Param)
+		}
+		sort.Strings(n.Grouping)
+	case *parser.Call:
+		for i := range n.Args {
+			n.Args[i] = normalizeExpr(n.Args[i])
+		}
+	case *parser.BinaryExpr:
+		n.LHS = normalizeOperand(n.LHS, false)
+		n.RHS = normalizeOperand(n.RHS, false)
+		if vm := n.VectorMatching; vm != nil {
+			sort.Strings(vm.MatchingLabels)
+			sort.Strings(vm.Include)
+		}
+	case *parser.UnaryExpr:
+		n.Expr = normalizeOperand(n.Expr, false)
+	case *parser.SubqueryExpr:
+		n.Expr = normalizeOperand(n.Expr, true)
+	}
+	return e
+}
+
+// normalizeOperand normalizes the operand of an operator or subquery, which is parenthesized
+// unless it is a selector, function call, aggregation or literal that can't be ambiguous
+func normalizeOperand(e parser.Expr, subquery bool) parser.Expr {
+	e = normalizeExpr(e)
+	switch n := e.(type) {
+	case *parser.MatrixSelector, *parser.Call, *parser.AggregateExpr, *parser.StringLiteral:
+		return e
+	case *parser.VectorSelector:
+		if !subquery || n.Offset == 0 {
+			return e
+		}
+	case *parser.NumberLiteral:
+		if n.Val >= 0 && !math.IsInf(n.Val, 1) {
+			return e
+		}
+	}
+	return &parser.ParenExpr{Expr: e}
+}
+
+// hasOffset returns true if any selector or subquery in the expression uses the offset modifier
+func hasOffset(expr parser.Expr) bool {
+	found := false
+	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+		switch n := node.(type) {
+		case *parser.VectorSelector:
+			found = found || n.Offset != 0
+		case *parser.SubqueryExpr:
+			found = found || n.Offset != 0
+		}
+		return nil
+	})
+	return found
+}
+
+// lookback returns the furthest before each evaluation timestamp that the expression reads samples
+func lookback(node parser.Node) time.Duration {
+	switch n := node.(type) {
+	case *parser.VectorSelector:
+		return defaultLookbackDelta + n.Offset
+	case *parser.MatrixSelector:
+		var offset time.Duration
+		if vs, ok := n.VectorSelector.(*parser.VectorSelector); ok {
+			offset = vs.Offset
+		}
+		return n.Range + offset
+	case *parser.SubqueryExpr:
+		return n.Range + n.Offset + lookback(n.Expr)
+	}
+	var max time.Duration
+	for _, c := range parser.Children(node) {
+		if d := lookback(c); d > max {
+			max = d
+		}
+	}
+	return max
+}
+
+// rangeSelector returns the selector and range of an expression that is only a range vector
+// selector, such as up[5m], which evaluates to the raw samples in the range. Selectors with
+// an offset are not included.
+func rangeSelector(expr parser.Expr) (*parser.VectorSelector, time.Duration, bool) {
+	for {
+		p, ok := expr.(*parser.ParenExpr)
+		if !ok {
+			break
+		}
+		expr = p.Expr
+	}
+	ms, ok := expr.(*parser.MatrixSelector)
+	if !ok {
+		return nil, 0, false
+	}
+	vs, ok := ms.VectorSelector.(*parser.VectorSelector)
+	if !ok || vs.Offset != 0 {
+		return nil, 0, false
+	}
+	return vs, ms.Range, true
+}
+
+// hasAtModifier returns true if the query uses the @ modifier, which is found anywhere
+// outside of a string literal or comment. The query string is scanned, rather than the
+// parsed expression, because the vendored PromQL parser predates the @ modifier and
+// fails to parse queries that use it.
+func hasAtModifier(q string) bool {
+	var quote rune
+	var escaped, comment bool
+	for _, c := range q {
+		switch {
+		case comment:
+			comment = c != '\n'
+		case quote != 0:
+			if escaped {
+				escaped = false
+			} else if c == '\\' && quote != '`' {
+				escaped = true
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case c == '#':
+			comment = true
+		case c == '@':
+			return true
+		}
+	}
+	return false
+}
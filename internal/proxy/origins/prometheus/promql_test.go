@@ -0,0 +1,189 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+func TestNormalizeStatement(t *testing.T) {
+
+	tests := []struct {
+		queries  []string
+		expected string
+	}{
+		{
+			[]string{`up{job="a",instance="b"}`, `up{ instance = "b", job = "a" }`, `((up{instance="b",job="a"}))`},
+			`up{instance="b",job="a"}`,
+		},
+		{
+			[]string{`sum by (job, env) (rate(http_requests_total[5m]))`, `sum(rate((http_requests_total[5m]))) by (env,job)`},
+			`sum by(env, job) (rate(http_requests_total[5m]))`,
+		},
+		{
+			[]string{`a + b * c`, `a + (b * c)`, `(a) + ((b) * c)`},
+			`a + (b * c)`,
+		},
+		{
+			[]string{`a / on(y, x) group_left(z, w) b`, `a / on (x,y) group_left (w,z) b`},
+			`a / on(x, y) group_left(w, z) b`,
+		},
+		{
+			[]string{`(-1) ^ 2`},
+			`(-1) ^ 2`,
+		},
+		{
+			[]string{`-(a + b)`},
+			`-(a + b)`,
+		},
+		{
+			[]string{`(a offset 5m)[30m:1m]`, `max_over_time((rate(b[1m]))[30m:])`},
+			``,
+		},
+		{
+			[]string{`topk(5, (x))`},
+			`topk(5, x)`,
+		},
+	}
+
+	for _, test := range tests {
+		for _, q := range test.queries {
+			expr, err := parser.ParseExpr(q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := normalizeStatement(expr)
+			// every normalized statement must parse to the same expression
+			expr2, err := parser.ParseExpr(s)
+			if err != nil {
+				t.Errorf("unable to parse normalized statement %s: %s", s, err)
+				continue
+			}
+			if s2 := normalizeStatement(expr2); s2 != s {
+				t.Errorf("expected %s got %s", s, s2)
+			}
+			if test.expected != "" && s != test.expected {
+				t.Errorf("expected %s got %s for %s", test.expected, s, q)
+			}
+		}
+	}
+}
+
+func TestHasOffset(t *testing.T) {
+
+	tests := []struct {
+		query    string
+		expected bool
+	}{
+		{`up`, false},
+		{`up offset 5m`, true},
+		{`rate(up[5m] offset 1h)`, true},
+		{`max_over_time(up[1h:1m] offset 1d)`, true},
+		{`up{job=" offset "}`, false},
+	}
+
+	for _, test := range tests {
+		expr, err := parser.ParseExpr(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hasOffset(expr) != test.expected {
+			t.Errorf("expected %t for %s", test.expected, test.query)
+		}
+	}
+}
+
+func TestLookback(t *testing.T) {
+
+	tests := []struct {
+		query    string
+		expected time.Duration
+	}{
+		{`1`, 0},
+		{`up`, defaultLookbackDelta},
+		{`up offset 1h`, time.Hour + defaultLookbackDelta},
+		{`rate(up[10m]) + rate(up[1m] offset 1h)`, time.Hour + time.Minute},
+		{`max_over_time(rate(up[5m])[1h:1m])`, time.Hour + 5*time.Minute},
+		{`max_over_time(up[1h:1m] offset 1d)`, 25*time.Hour + defaultLookbackDelta},
+	}
+
+	for _, test := range tests {
+		expr, err := parser.ParseExpr(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := lookback(expr); d != test.expected {
+			t.Errorf("expected %s got %s for %s", test.expected, d, test.query)
+		}
+	}
+}
+
+func TestHasAtModifier(t *testing.T) {
+
+	tests := []struct {
+		query    string
+		expected bool
+	}{
+		{`up`, false},
+		{`up @ 1609746000`, true},
+		{`rate(up[5m] @ end())`, true},
+		{`up{email="a@b.com"}`, false},
+		{`up{email='a\'@'}`, false},
+		{"up{email=`a\\`} @ start()", true},
+		{"up # @ in a comment\n", false},
+		{"up # comment\n @ start()", true},
+	}
+
+	for _, test := range tests {
+		if hasAtModifier(test.query) != test.expected {
+			t.Errorf("expected %t for %s", test.expected, test.query)
+		}
+	}
+}
+
+func TestRangeSelector(t *testing.T) {
+
+	tests := []struct {
+		query    string
+		selector string
+		rng      time.Duration
+		ok       bool
+	}{
+		{`up[5m]`, `up`, 5 * time.Minute, true},
+		{`((up{job="api"}[1h]))`, `up{job="api"}`, time.Hour, true},
+		{`up`, "", 0, false},
+		{`up[5m] offset 1m`, "", 0, false},
+		{`max_over_time(up[5m])`, "", 0, false},
+		{`up[5m:1m]`, "", 0, false},
+	}
+
+	for i, test := range tests {
+		expr, err := parser.ParseExpr(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		vs, rng, ok := rangeSelector(expr)
+		if ok != test.ok {
+			t.Errorf("test %d: expected %t got %t", i, test.ok, ok)
+			continue
+		}
+		if ok && (vs.String() != test.selector || rng != test.rng) {
+			t.Errorf("test %d: expected %s[%s] got %s[%s]", i, test.selector, test.rng, vs, rng)
+		}
+	}
+
+}
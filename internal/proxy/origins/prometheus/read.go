@@ -0,0 +1,639 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Comcast/trickster/internal/proxy/errors"
+	"github.com/Comcast/trickster/internal/proxy/headers"
+	"github.com/Comcast/trickster/internal/proxy/urls"
+	"github.com/Comcast/trickster/internal/timeseries"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// This file decodes and encodes the snappy-compressed protocol buffer messages of the
+// Prometheus remote read API. Only the fields that Trickster uses are decoded, and the
+// rest are skipped, so the few messages involved are handled without generated code.
+
+const (
+	mediaTypeProtobuf = "application/x-protobuf"
+	encodingSnappy    = "snappy"
+
+	// upHints is the TemplateURL parameter holding the encoded read hints of a remote read
+	// query, other than their time range, so that requests are cache-keyed on them
+	upHints = "hints"
+
+	// fieldExtents is the field number of the ReadResponse in which the extents of a cached
+	// response are stored. Clients skip it as an unknown field, but it's only ever written
+	// for the cache, since the DeltaProxyCache clears the extents of client responses.
+	fieldExtents = 1000
+)
+
+// wire types of the protocol buffer encoding
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// response types of a remote read request
+const (
+	responseTypeSamples = 0
+)
+
+var errInvalidMessage = fmt.Errorf("invalid remote read message")
+
+// readRequest is a Prometheus remote read request
+type readRequest struct {
+	queries       []*readQuery
+	responseTypes []uint64
+}
+
+// readQuery is a query of a remote read request, for the raw samples of the series
+// selected by its matchers, with timestamps in epoch milliseconds
+type readQuery struct {
+	start    int64
+	end      int64
+	matchers []*labels.Matcher
+	hints    *readHints
+}
+
+// readHints describe the PromQL expression that a remote read query is made for
+type readHints struct {
+	stepMs   int64
+	function string
+	startMs  int64
+	endMs    int64
+	grouping []string
+	by       bool
+	rangeMs  int64
+}
+
+// isRemoteRead returns true if the request is a remote read request
+func isRemoteRead(r *http.Request) bool {
+	return r.Method == http.MethodPost && r.Body != nil &&
+		strings.HasPrefix(r.Header.Get(headers.NameContentType), mediaTypeProtobuf) &&
+		r.Header.Get(headers.NameContentEncoding) == encodingSnappy
+}
+
+// readRequestBody decodes the remote read request in the body of the request. The body
+// is restored so that it can be read again.
+func readRequestBody(r *http.Request) (*readRequest, error) {
+	b, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		return nil, errors.ParseRequestBody(err)
+	}
+	b, err = snappy.Decode(nil, b)
+	if err != nil {
+		return nil, errors.ParseRequestBody(err)
+	}
+	rr, err := decodeReadRequest(b)
+	if err != nil {
+		return nil, errors.ParseRequestBody(err)
+	}
+	return rr, nil
+}
+
+// setReadRequestBody replaces the body of the request with the provided remote read request
+func setReadRequestBody(r *http.Request, rr *readRequest) {
+	b := snappy.Encode(nil, rr.marshal())
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	r.ContentLength = int64(len(b))
+	r.Header.Set(headers.NameContentLength, strconv.Itoa(len(b)))
+}
+
+// acceptsSamples returns true if the client of the remote read request accepts a response
+// of raw samples, rather than only streamed chunks
+func (rr *readRequest) acceptsSamples() bool {
+	if len(rr.responseTypes) == 0 {
+		return true
+	}
+	for _, t := range rr.responseTypes {
+		if t == responseTypeSamples {
+			return true
+		}
+	}
+	return false
+}
+
+// parseReadRequest parses the TimeRangeQuery of a remote read request. The raw samples of
+// its query have no step, and are cached by the canonical series selector of its matchers.
+func parseReadRequest(r *http.Request) (*timeseries.TimeRangeQuery, error) {
+
+	rr, err := readRequestBody(r)
+	if err != nil {
+		return nil, err
+	}
+
+	// requests with several queries are split by the ReadHandler, so each is cached on its own
+	if len(rr.queries) != 1 || !rr.acceptsSamples() || len(rr.queries[0].matchers) == 0 {
+		return nil, errors.ErrNotTimeRangeQuery
+	}
+
+	q := rr.queries[0]
+	trq := &timeseries.TimeRangeQuery{
+		Statement: q.selector(),
+		Extent:    timeseries.Extent{Start: msTime(q.start), End: msTime(q.end)},
+	}
+
+	// the selector is parsed again for each upstream request, so those that don't
+	// survive the round trip are proxied
+	if _, err := parser.ParseMetricSelector(trq.Statement); err != nil {
+		return nil, errors.ErrNotTimeRangeQuery
+	}
+
+	params := url.Values{}
+	params.Set(upMatch, trq.Statement)
+	if q.hints != nil {
+		h := *q.hints
+		h.startMs, h.endMs = 0, 0
+		params.Set(upHints, base64.RawURLEncoding.EncodeToString(h.marshal()))
+	}
+	trq.TemplateURL = urls.Clone(r.URL)
+	trq.TemplateURL.RawQuery = params.Encode()
+
+	return trq, nil
+}
+
+// setReadExtent replaces the body of a remote read request with one that queries the
+// TimeRangeQuery's series for the raw samples in the provided extent
+func setReadExtent(r *http.Request, trq *timeseries.TimeRangeQuery, extent *timeseries.Extent) {
+
+	if trq == nil || trq.TemplateURL == nil || extent == nil {
+		return
+	}
+
+	params := trq.TemplateURL.Query()
+	ms, err := parser.ParseMetricSelector(params.Get(upMatch))
+	if err != nil {
+		return
+	}
+
+	q := &readQuery{start: msTimestamp(extent.Start), end: msTimestamp(extent.End), matchers: ms}
+	if v := params.Get(upHints); v != "" {
+		if b, err := base64.RawURLEncoding.DecodeString(v); err == nil {
+			if h, err := decodeReadHints(b); err == nil {
+				h.startMs, h.endMs = q.start, q.end
+				q.hints = h
+			}
+		}
+	}
+
+	setReadRequestBody(r, &readRequest{queries: []*readQuery{q}})
+}
+
+// selector returns the series selector of the query's matchers, in a canonical order
+func (q *readQuery) selector() string {
+	ms := make([]*labels.Matcher, len(q.matchers))
+	copy(ms, q.matchers)
+	sort.Slice(ms, func(i, j int) bool {
+		if ms[i].Name != ms[j].Name {
+			return ms[i].Name < ms[j].Name
+		}
+		if ms[i].Type != ms[j].Type {
+			return ms[i].Type < ms[j].Type
+		}
+		return ms[i].Value < ms[j].Value
+	})
+	return selectorString(ms)
+}
+
+// msTime returns the time of the epoch millisecond timestamp
+func msTime(ms int64) time.Time {
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
+
+// msTimestamp returns the epoch millisecond timestamp of the time
+func msTimestamp(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+// pbReader reads the fields of an encoded protocol buffer message
+type pbReader struct {
+	b   []byte
+	err error
+}
+
+// next reads the field number and wire type of the next field, and returns false
+// at the end of the message or when the message is invalid
+func (p *pbReader) next() (int, int, bool) {
+	if p.err != nil || len(p.b) == 0 {
+		return 0, 0, false
+	}
+	t := p.varint()
+	if p.err != nil {
+		return 0, 0, false
+	}
+	return int(t >> 3), int(t & 7), true
+}
+
+func (p *pbReader) varint() uint64 {
+	v, n := binary.Uvarint(p.b)
+	if n <= 0 {
+		p.fail()
+		return 0
+	}
+	p.b = p.b[n:]
+	return v
+}
+
+func (p *pbReader) fixed64() uint64 {
+	if len(p.b) < 8 {
+		p.fail()
+		return 0
+	}
+	v := binary.LittleEndian.Uint64(p.b)
+	p.b = p.b[8:]
+	return v
+}
+
+func (p *pbReader) bytes() []byte {
+	l := p.varint()
+	if p.err != nil || uint64(len(p.b)) < l {
+		p.fail()
+		return nil
+	}
+	v := p.b[:l]
+	p.b = p.b[l:]
+	return v
+}
+
+// skip reads past the value of a field that isn't decoded
+func (p *pbReader) skip(wireType int) {
+	switch wireType {
+	case wireVarint:
+		p.varint()
+	case wireFixed64:
+		p.fixed64()
+	case wireBytes:
+		p.bytes()
+	case wireFixed32:
+		if len(p.b) < 4 {
+			p.fail()
+			return
+		}
+		p.b = p.b[4:]
+	default:
+		p.fail()
+	}
+}
+
+func (p *pbReader) fail() {
+	p.err = errInvalidMessage
+	p.b = nil
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+func appendTag(b []byte, field, wireType int) []byte {
+	return appendVarint(b, uint64(field<<3|wireType))
+}
+
+func appendVarintField(b []byte, field int, v uint64) []byte {
+	return appendVarint(appendTag(b, field, wireVarint), v)
+}
+
+func appendFixed64Field(b []byte, field int, v uint64) []byte {
+	b = appendTag(b, field, wireFixed64)
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendBytesField(b []byte, field int, v []byte) []byte {
+	b = appendVarint(appendTag(b, field, wireBytes), uint64(len(v)))
+	return append(b, v...)
+}
+
+func appendStringField(b []byte, field int, v string) []byte {
+	b = appendVarint(appendTag(b, field, wireBytes), uint64(len(v)))
+	return append(b, v...)
+}
+
+func decodeReadRequest(b []byte) (*readRequest, error) {
+	rr := &readRequest{}
+	p := &pbReader{b: b}
+	for f, wt, ok := p.next(); ok; f, wt, ok = p.next() {
+		switch {
+		case f == 1 && wt == wireBytes:
+			q, err := decodeReadQuery(p.bytes())
+			if err != nil {
+				return nil, err
+			}
+			rr.queries = append(rr.queries, q)
+		case f == 2 && wt == wireVarint:
+			rr.responseTypes = append(rr.responseTypes, p.varint())
+		case f == 2 && wt == wireBytes:
+			// the response types are usually packed
+			pp := &pbReader{b: p.bytes()}
+			for len(pp.b) > 0 && pp.err == nil {
+				rr.responseTypes = append(rr.responseTypes, pp.varint())
+			}
+			if pp.err != nil {
+				return nil, pp.err
+			}
+		default:
+			p.skip(wt)
+		}
+	}
+	return rr, p.err
+}
+
+func (rr *readRequest) marshal() []byte {
+	var b []byte
+	for _, q := range rr.queries {
+		b = appendBytesField(b, 1, q.marshal())
+	}
+	for _, t := range rr.responseTypes {
+		b = appendVarintField(b, 2, t)
+	}
+	return b
+}
+
+func decodeReadQuery(b []byte) (*readQuery, error) {
+	q := &readQuery{}
+	p := &pbReader{b: b}
+	for f, wt, ok := p.next(); ok; f, wt, ok = p.next() {
+		switch {
+		case f == 1 && wt == wireVarint:
+			q.start = int64(p.varint())
+		case f == 2 && wt == wireVarint:
+			q.end = int64(p.varint())
+		case f == 3 && wt == wireBytes:
+			m, err := decodeLabelMatcher(p.bytes())
+			if err != nil {
+				return nil, err
+			}
+			q.matchers = append(q.matchers, m)
+		case f == 4 && wt == wireBytes:
+			h, err := decodeReadHints(p.bytes())
+			if err != nil {
+				return nil, err
+			}
+			q.hints = h
+		default:
+			p.skip(wt)
+		}
+	}
+	return q, p.err
+}
+
+func (q *readQuery) marshal() []byte {
+	b := appendVarintField(nil, 1, uint64(q.start))
+	b = appendVarintField(b, 2, uint64(q.end))
+	for _, m := range q.matchers {
+		mb := appendVarintField(nil, 1, uint64(m.Type))
+		mb = appendStringField(mb, 2, m.Name)
+		mb = appendStringField(mb, 3, m.Value)
+		b = appendBytesField(b, 3, mb)
+	}
+	if q.hints != nil {
+		b = appendBytesField(b, 4, q.hints.marshal())
+	}
+	return b
+}
+
+func decodeLabelMatcher(b []byte) (*labels.Matcher, error) {
+	var t uint64
+	var name, value string
+	p := &pbReader{b: b}
+	for f, wt, ok := p.next(); ok; f, wt, ok = p.next() {
+		switch {
+		case f == 1 && wt == wireVarint:
+			t = p.varint()
+		case f == 2 && wt == wireBytes:
+			name = string(p.bytes())
+		case f == 3 && wt == wireBytes:
+			value = string(p.bytes())
+		default:
+			p.skip(wt)
+		}
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	if t > uint64(labels.MatchNotRegexp) {
+		return nil, errInvalidMessage
+	}
+	return labels.NewMatcher(labels.MatchType(t), name, value)
+}
+
+func decodeReadHints(b []byte) (*readHints, error) {
+	h := &readHints{}
+	p := &pbReader{b: b}
+	for f, wt, ok := p.next(); ok; f, wt, ok = p.next() {
+		switch {
+		case f == 1 && wt == wireVarint:
+			h.stepMs = int64(p.varint())
+		case f == 2 && wt == wireBytes:
+			h.function = string(p.bytes())
+		case f == 3 && wt == wireVarint:
+			h.startMs = int64(p.varint())
+		case f == 4 && wt == wireVarint:
+			h.endMs = int64(p.varint())
+		case f == 5 && wt == wireBytes:
+			h.grouping = append(h.grouping, string(p.bytes()))
+		case f == 6 && wt == wireVarint:
+			h.by = p.varint() != 0
+		case f == 7 && wt == wireVarint:
+			h.rangeMs = int64(p.varint())
+		default:
+			p.skip(wt)
+		}
+	}
+	return h, p.err
+}
+
+func (h *readHints) marshal() []byte {
+	b := appendVarintField(nil, 1, uint64(h.stepMs))
+	b = appendStringField(b, 2, h.function)
+	b = appendVarintField(b, 3, uint64(h.startMs))
+	b = appendVarintField(b, 4, uint64(h.endMs))
+	for _, g := range h.grouping {
+		b = appendStringField(b, 5, g)
+	}
+	if h.by {
+		b = appendVarintField(b, 6, 1)
+	}
+	return appendVarintField(b, 7, uint64(h.rangeMs))
+}
+
+// unmarshalReadResponse decodes a snappy-compressed remote read response
+func unmarshalReadResponse(data []byte) (*ReadResponse, error) {
+	b, err := snappy.Decode(nil, data)
+	if err != nil {
+		return nil, err
+	}
+	rr := &ReadResponse{Results: []*ReadResult{}}
+	p := &pbReader{b: b}
+	for f, wt, ok := p.next(); ok; f, wt, ok = p.next() {
+		switch {
+		case f == 1 && wt == wireBytes:
+			res, err := decodeReadResult(p.bytes())
+			if err != nil {
+				return nil, err
+			}
+			rr.Results = append(rr.Results, res)
+		case f == fieldExtents && wt == wireBytes:
+			e, err := decodeExtent(p.bytes())
+			if err != nil {
+				return nil, err
+			}
+			rr.ExtentList = append(rr.ExtentList, e)
+		default:
+			p.skip(wt)
+		}
+	}
+	return rr, p.err
+}
+
+// marshal returns the snappy-compressed encoding of the remote read response
+func (rr *ReadResponse) marshal() []byte {
+	var b []byte
+	for _, res := range rr.Results {
+		var rb []byte
+		for _, s := range res.Series {
+			rb = appendBytesField(rb, 1, s.marshal())
+		}
+		b = appendBytesField(b, 1, rb)
+	}
+	for _, e := range rr.ExtentList {
+		eb := appendVarintField(nil, 1, uint64(e.Start.UnixNano()))
+		eb = appendVarintField(eb, 2, uint64(e.End.UnixNano()))
+		if !e.LastUsed.IsZero() {
+			eb = appendVarintField(eb, 3, uint64(e.LastUsed.UnixNano()))
+		}
+		b = appendBytesField(b, fieldExtents, eb)
+	}
+	return snappy.Encode(nil, b)
+}
+
+func decodeReadResult(b []byte) (*ReadResult, error) {
+	res := &ReadResult{Series: []*ReadSeries{}}
+	p := &pbReader{b: b}
+	for f, wt, ok := p.next(); ok; f, wt, ok = p.next() {
+		if f == 1 && wt == wireBytes {
+			s, err := decodeReadSeries(p.bytes())
+			if err != nil {
+				return nil, err
+			}
+			res.Series = append(res.Series, s)
+			continue
+		}
+		p.skip(wt)
+	}
+	return res, p.err
+}
+
+func decodeReadSeries(b []byte) (*ReadSeries, error) {
+	s := &ReadSeries{}
+	p := &pbReader{b: b}
+	for f, wt, ok := p.next(); ok; f, wt, ok = p.next() {
+		switch {
+		case f == 1 && wt == wireBytes:
+			var l labels.Label
+			lp := &pbReader{b: p.bytes()}
+			for lf, lwt, ok := lp.next(); ok; lf, lwt, ok = lp.next() {
+				switch {
+				case lf == 1 && lwt == wireBytes:
+					l.Name = string(lp.bytes())
+				case lf == 2 && lwt == wireBytes:
+					l.Value = string(lp.bytes())
+				default:
+					lp.skip(lwt)
+				}
+			}
+			if lp.err != nil {
+				return nil, lp.err
+			}
+			s.Labels = append(s.Labels, l)
+		case f == 2 && wt == wireBytes:
+			var v ReadSample
+			sp := &pbReader{b: p.bytes()}
+			for sf, swt, ok := sp.next(); ok; sf, swt, ok = sp.next() {
+				switch {
+				case sf == 1 && swt == wireFixed64:
+					v.Value = math.Float64frombits(sp.fixed64())
+				case sf == 2 && swt == wireVarint:
+					v.Timestamp = int64(sp.varint())
+				default:
+					sp.skip(swt)
+				}
+			}
+			if sp.err != nil {
+				return nil, sp.err
+			}
+			s.Samples = append(s.Samples, v)
+		default:
+			p.skip(wt)
+		}
+	}
+	sort.Sort(s.Labels)
+	return s, p.err
+}
+
+func (s *ReadSeries) marshal() []byte {
+	var b []byte
+	for _, l := range s.Labels {
+		lb := appendStringField(nil, 1, l.Name)
+		lb = appendStringField(lb, 2, l.Value)
+		b = appendBytesField(b, 1, lb)
+	}
+	for _, v := range s.Samples {
+		sb := appendFixed64Field(nil, 1, math.Float64bits(v.Value))
+		sb = appendVarintField(sb, 2, uint64(v.Timestamp))
+		b = appendBytesField(b, 2, sb)
+	}
+	return b
+}
+
+func decodeExtent(b []byte) (timeseries.Extent, error) {
+	var e timeseries.Extent
+	p := &pbReader{b: b}
+	for f, wt, ok := p.next(); ok; f, wt, ok = p.next() {
+		switch {
+		case f == 1 && wt == wireVarint:
+			e.Start = time.Unix(0, int64(p.varint()))
+		case f == 2 && wt == wireVarint:
+			e.End = time.Unix(0, int64(p.varint()))
+		case f == 3 && wt == wireVarint:
+			e.LastUsed = time.Unix(0, int64(p.varint()))
+		default:
+			p.skip(wt)
+		}
+	}
+	return e, p.err
+}
@@ -0,0 +1,295 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Comcast/trickster/internal/timeseries"
+	"github.com/Comcast/trickster/pkg/sort/times"
+	"github.com/prometheus/prometheus/pkg/labels"
+)
+
+// ReadResponse represents a response to a Prometheus remote read request. Its samples are
+// raw, rather than evaluated at a step, so they are merged and cropped by their timestamps.
+type ReadResponse struct {
+	Results    []*ReadResult
+	ExtentList timeseries.ExtentList
+}
+
+// ReadResult holds the series selected by one query of a remote read request
+type ReadResult struct {
+	Series []*ReadSeries
+}
+
+// ReadSeries is a series of raw samples and its labels
+type ReadSeries struct {
+	Labels  labels.Labels
+	Samples []ReadSample
+}
+
+// ReadSample is a raw sample, with its timestamp in epoch milliseconds
+type ReadSample struct {
+	Timestamp int64
+	Value     float64
+}
+
+// Step returns the step for the Timeseries, which is always 0 since its samples are raw
+func (rr *ReadResponse) Step() time.Duration {
+	return 0
+}
+
+// SetStep is a no-op, since the samples of a ReadResponse are raw
+func (rr *ReadResponse) SetStep(step time.Duration) {}
+
+// Merge merges the provided Timeseries list into the base Timeseries (in the order provided) and
+// optionally sorts the merged Timeseries. Series are merged with those of the same query result.
+func (rr *ReadResponse) Merge(sort bool, collection ...timeseries.Timeseries) {
+	for _, ts := range collection {
+		rr2, ok := ts.(*ReadResponse)
+		if !ok || rr2 == nil {
+			continue
+		}
+		for i, res := range rr2.Results {
+			for len(rr.Results) <= i {
+				rr.Results = append(rr.Results, &ReadResult{Series: []*ReadSeries{}})
+			}
+			rr.Results[i].merge(res)
+		}
+		rr.ExtentList = append(rr.ExtentList, rr2.ExtentList...)
+	}
+	rr.ExtentList = rr.ExtentList.Compress(0)
+	if sort {
+		rr.Sort()
+	}
+}
+
+// merge appends the samples of the provided result's series to those of the same labels
+func (res *ReadResult) merge(res2 *ReadResult) {
+	series := make(map[string]*ReadSeries, len(res.Series))
+	for _, s := range res.Series {
+		series[s.Labels.String()] = s
+	}
+	for _, s := range res2.Series {
+		k := s.Labels.String()
+		if s1, ok := series[k]; ok {
+			s1.Samples = append(s1.Samples, s.Samples...)
+			continue
+		}
+		s1 := s.clone()
+		series[k] = s1
+		res.Series = append(res.Series, s1)
+	}
+}
+
+// Clone returns a perfect copy of the base Timeseries
+func (rr *ReadResponse) Clone() timeseries.Timeseries {
+	c := &ReadResponse{
+		Results:    make([]*ReadResult, len(rr.Results)),
+		ExtentList: rr.ExtentList.Clone(),
+	}
+	for i, res := range rr.Results {
+		c.Results[i] = &ReadResult{Series: make([]*ReadSeries, len(res.Series))}
+		for j, s := range res.Series {
+			c.Results[i].Series[j] = s.clone()
+		}
+	}
+	return c
+}
+
+func (s *ReadSeries) clone() *ReadSeries {
+	s2 := &ReadSeries{Labels: s.Labels.Copy(), Samples: make([]ReadSample, len(s.Samples))}
+	copy(s2.Samples, s.Samples)
+	return s2
+}
+
+// CropToSize reduces the number of elements in the Timeseries to the provided count, by evicting elements
+// using a least-recently-used methodology. Any timestamps newer than the provided time are removed before
+// sizing, in order to support backfill tolerance. The provided extent will be marked as used during crop.
+func (rr *ReadResponse) CropToSize(sz int, t time.Time, lur timeseries.Extent) {
+	x := len(rr.ExtentList)
+	// The Series has no extents, so no need to do anything
+	if x < 1 {
+		rr.clearSeries()
+		rr.ExtentList = timeseries.ExtentList{}
+		return
+	}
+
+	// Crop to the Backfill Tolerance Value if needed
+	if rr.ExtentList[x-1].End.After(t) {
+		rr.CropToRange(timeseries.Extent{Start: rr.ExtentList[0].Start, End: t})
+	}
+
+	el := timeseries.ExtentListLRU(rr.ExtentList).UpdateLastUsed(lur, 0)
+	sort.Sort(el)
+	rr.ExtentList = timeseries.ExtentList(el)
+
+	tl := rr.timestamps()
+	if len(tl) <= sz {
+		return
+	}
+
+	st := make(times.Times, len(tl))
+	for i, ts := range tl {
+		st[i] = msTime(ts)
+	}
+	removals, el2 := el.RemoveOldest(st, len(tl)-sz)
+
+	for _, res := range rr.Results {
+		tmp := res.Series[:0]
+		for _, s := range res.Series {
+			samples := s.Samples[:0]
+			for _, v := range s.Samples {
+				if !removals[msTime(v.Timestamp)] {
+					samples = append(samples, v)
+				}
+			}
+			s.Samples = samples
+			if len(s.Samples) > 0 {
+				tmp = append(tmp, s)
+			}
+		}
+		res.Series = tmp
+	}
+
+	rr.ExtentList = el2
+	rr.Sort()
+}
+
+// CropToRange reduces the Timeseries down to timestamps contained within the provided Extents (inclusive).
+// Series left without samples are removed.
+func (rr *ReadResponse) CropToRange(e timeseries.Extent) {
+	// if the extent of the series is entirely outside the extent of the crop range, return empty set and bail
+	if rr.ExtentList.OutsideOf(e) {
+		rr.clearSeries()
+		rr.ExtentList = timeseries.ExtentList{}
+		return
+	}
+
+	for _, res := range rr.Results {
+		tmp := res.Series[:0]
+		for _, s := range res.Series {
+			samples := s.Samples[:0]
+			for _, v := range s.Samples {
+				if st := msTime(v.Timestamp); !st.Before(e.Start) && !st.After(e.End) {
+					samples = append(samples, v)
+				}
+			}
+			s.Samples = samples
+			if len(s.Samples) > 0 {
+				tmp = append(tmp, s)
+			}
+		}
+		res.Series = tmp
+	}
+	rr.ExtentList = rr.ExtentList.Crop(e)
+}
+
+// clearSeries removes all series, while keeping a result for each query
+func (rr *ReadResponse) clearSeries() {
+	for _, res := range rr.Results {
+		res.Series = []*ReadSeries{}
+	}
+}
+
+// Sort sorts the series of each result by their labels, and their samples chronologically by their
+// timestamp. When a series has more than one sample with the same timestamp, the last one is kept.
+func (rr *ReadResponse) Sort() {
+	for _, res := range rr.Results {
+		series := res.Series
+		sort.SliceStable(series, func(i, j int) bool {
+			return labels.Compare(series[i].Labels, series[j].Labels) < 0
+		})
+		for _, s := range series {
+			samples := s.Samples
+			sort.SliceStable(samples, func(i, j int) bool {
+				return samples[i].Timestamp < samples[j].Timestamp
+			})
+			tmp := samples[:0]
+			for i, v := range samples {
+				if i+1 < len(samples) && samples[i+1].Timestamp == v.Timestamp {
+					continue
+				}
+				tmp = append(tmp, v)
+			}
+			s.Samples = tmp
+		}
+	}
+	sort.Sort(rr.ExtentList)
+}
+
+// timestamps returns the unique timestamps across the timeseries, in chronological order
+func (rr *ReadResponse) timestamps() []int64 {
+	m := make(map[int64]bool)
+	for _, res := range rr.Results {
+		for _, s := range res.Series {
+			for _, v := range s.Samples {
+				m[v.Timestamp] = true
+			}
+		}
+	}
+	tl := make([]int64, 0, len(m))
+	for ts := range m {
+		tl = append(tl, ts)
+	}
+	sort.Slice(tl, func(i, j int) bool { return tl[i] < tl[j] })
+	return tl
+}
+
+// SetExtents overwrites a Timeseries's known extents with the provided extent list
+func (rr *ReadResponse) SetExtents(extents timeseries.ExtentList) {
+	rr.ExtentList = extents
+}
+
+// Extents returns the Timeseries's ExentList
+func (rr *ReadResponse) Extents() timeseries.ExtentList {
+	return rr.ExtentList
+}
+
+// TimestampCount returns the number of unique timestamps across the timeseries
+func (rr *ReadResponse) TimestampCount() int {
+	return len(rr.timestamps())
+}
+
+// SeriesCount returns the number of individual Series in the Timeseries object
+func (rr *ReadResponse) SeriesCount() int {
+	c := 0
+	for _, res := range rr.Results {
+		c += len(res.Series)
+	}
+	return c
+}
+
+// ValueCount returns the count of all values across all Series in the Timeseries object
+func (rr *ReadResponse) ValueCount() int {
+	c := 0
+	for _, res := range rr.Results {
+		for _, s := range res.Series {
+			c += len(s.Samples)
+		}
+	}
+	return c
+}
+
+// Size returns the approximate memory utilization in bytes of the timeseries
+func (rr *ReadResponse) Size() int {
+	c := 0
+	for _, res := range rr.Results {
+		for _, s := range res.Series {
+			c += (len(s.Samples) * 16) + len(s.Labels.String())
+		}
+	}
+	return c
+}
@@ -0,0 +1,213 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Comcast/trickster/internal/timeseries"
+	"github.com/prometheus/prometheus/pkg/labels"
+)
+
+// testReadResponse returns a response with a result of two series, and an empty result
+func testReadResponse() *ReadResponse {
+	return &ReadResponse{
+		Results: []*ReadResult{
+			{Series: []*ReadSeries{
+				{
+					Labels:  labels.FromStrings("__name__", "up", "job", "a"),
+					Samples: []ReadSample{{Timestamp: 10000, Value: 1}, {Timestamp: 20000, Value: 2}},
+				},
+				{
+					Labels:  labels.FromStrings("__name__", "up", "job", "b"),
+					Samples: []ReadSample{{Timestamp: 15000, Value: 1}, {Timestamp: 20000, Value: -1.5}},
+				},
+			}},
+			{Series: []*ReadSeries{}},
+		},
+	}
+}
+
+func testSeriesTimestamps(s *ReadSeries) []int64 {
+	ts := make([]int64, len(s.Samples))
+	for i, v := range s.Samples {
+		ts[i] = v.Timestamp
+	}
+	return ts
+}
+
+func testEqualTimestamps(t *testing.T, expected []int64, s *ReadSeries) {
+	t.Helper()
+	got := testSeriesTimestamps(s)
+	if len(got) != len(expected) {
+		t.Errorf("expected %v got %v", expected, got)
+		return
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("expected %v got %v", expected, got)
+			return
+		}
+	}
+}
+
+func TestReadResponseStep(t *testing.T) {
+	rr := testReadResponse()
+	rr.SetStep(time.Minute)
+	if rr.Step() != 0 {
+		t.Errorf("expected 0 got %s", rr.Step())
+	}
+}
+
+func TestReadResponseMerge(t *testing.T) {
+
+	rr := testReadResponse()
+	rr.SetExtents(timeseries.ExtentList{{Start: time.Unix(10, 0), End: time.Unix(20, 0)}})
+
+	rr2 := &ReadResponse{
+		Results: []*ReadResult{
+			{Series: []*ReadSeries{
+				{
+					Labels:  labels.FromStrings("__name__", "up", "job", "a"),
+					Samples: []ReadSample{{Timestamp: 20000, Value: 5}, {Timestamp: 25000, Value: 3}},
+				},
+				{
+					Labels:  labels.FromStrings("__name__", "up", "job", "0"),
+					Samples: []ReadSample{{Timestamp: 22500, Value: 1}},
+				},
+			}},
+			{Series: []*ReadSeries{}},
+			{Series: []*ReadSeries{}},
+		},
+		ExtentList: timeseries.ExtentList{{Start: time.Unix(20, 0), End: time.Unix(30, 0)}},
+	}
+
+	rr.Merge(true, rr2, nil)
+
+	if len(rr.Results) != 3 {
+		t.Fatalf("expected %d got %d", 3, len(rr.Results))
+	}
+	series := rr.Results[0].Series
+	if len(series) != 3 || series[0].Labels.Get("job") != "0" || series[2].Labels.Get("job") != "b" {
+		t.Fatalf("unexpected series %v", series)
+	}
+	testEqualTimestamps(t, []int64{10000, 20000, 25000}, series[1])
+	// the value merged last wins
+	if series[1].Samples[1].Value != 5 {
+		t.Errorf("expected %d got %f", 5, series[1].Samples[1].Value)
+	}
+
+	// the merged series is a copy
+	rr2.Results[0].Series[1].Samples[0].Value = 10
+	if series[0].Samples[0].Value != 1 {
+		t.Errorf("expected merged series to be copied")
+	}
+
+	el := rr.Extents()
+	if len(el) != 1 || !el[0].Start.Equal(time.Unix(10, 0)) || !el[0].End.Equal(time.Unix(30, 0)) {
+		t.Errorf("unexpected extents %s", el)
+	}
+
+	if rr.SeriesCount() != 3 || rr.ValueCount() != 6 || rr.TimestampCount() != 5 {
+		t.Errorf("unexpected counts %d %d %d", rr.SeriesCount(), rr.ValueCount(), rr.TimestampCount())
+	}
+}
+
+func TestReadResponseClone(t *testing.T) {
+	rr := testReadResponse()
+	rr.SetExtents(timeseries.ExtentList{{Start: time.Unix(10, 0), End: time.Unix(20, 0)}})
+	rr2 := rr.Clone().(*ReadResponse)
+	if rr2.Size() != rr.Size() || rr.Size() == 0 {
+		t.Errorf("unexpected size %d", rr2.Size())
+	}
+	rr2.Results[0].Series[0].Samples[0].Value = 10
+	rr2.Results[0].Series[0].Labels[0].Value = "down"
+	rr2.ExtentList[0].Start = time.Unix(0, 0)
+	if rr.Results[0].Series[0].Samples[0].Value != 1 || rr.Results[0].Series[0].Labels[0].Value != "up" ||
+		!rr.ExtentList[0].Start.Equal(time.Unix(10, 0)) {
+		t.Errorf("expected a deep copy")
+	}
+}
+
+func TestReadResponseCropToRange(t *testing.T) {
+
+	rr := testReadResponse()
+	rr.SetExtents(timeseries.ExtentList{{Start: time.Unix(10, 0), End: time.Unix(20, 0)}})
+	rr.CropToRange(timeseries.Extent{Start: time.Unix(12, 0), End: time.Unix(15, 0)})
+
+	series := rr.Results[0].Series
+	if len(series) != 1 || series[0].Labels.Get("job") != "b" {
+		t.Fatalf("unexpected series %v", series)
+	}
+	testEqualTimestamps(t, []int64{15000}, series[0])
+	if len(rr.Results) != 2 {
+		t.Errorf("expected %d got %d", 2, len(rr.Results))
+	}
+	el := rr.Extents()
+	if len(el) != 1 || !el[0].Start.Equal(time.Unix(12, 0)) || !el[0].End.Equal(time.Unix(15, 0)) {
+		t.Errorf("unexpected extents %s", el)
+	}
+
+	rr.CropToRange(timeseries.Extent{Start: time.Unix(30, 0), End: time.Unix(40, 0)})
+	if rr.SeriesCount() != 0 || len(rr.Extents()) != 0 || len(rr.Results) != 2 {
+		t.Errorf("expected empty response")
+	}
+}
+
+func TestReadResponseCropToSize(t *testing.T) {
+
+	now := time.Now().Truncate(time.Second)
+
+	rr := testReadResponse()
+	rr.SetExtents(timeseries.ExtentList{{Start: time.Unix(10, 0), End: time.Unix(20, 0), LastUsed: now}})
+
+	// the backfill tolerance crops the newest timestamps
+	rr.CropToSize(10, time.Unix(19, 0), timeseries.Extent{Start: time.Unix(10, 0), End: time.Unix(20, 0)})
+	if rr.TimestampCount() != 2 {
+		t.Errorf("expected %d got %d", 2, rr.TimestampCount())
+	}
+	if el := rr.Extents(); len(el) != 1 || !el[0].End.Equal(time.Unix(19, 0)) {
+		t.Errorf("unexpected extents %s", el)
+	}
+
+	// the oldest timestamps of the least recently used extents are removed
+	rr = testReadResponse()
+	rr.Results[0].Series[0].Samples = append(rr.Results[0].Series[0].Samples, ReadSample{Timestamp: 30000, Value: 3})
+	rr.SetExtents(timeseries.ExtentList{
+		{Start: time.Unix(10, 0), End: time.Unix(20, 0), LastUsed: now.Add(-30 * time.Minute)},
+		{Start: time.Unix(20, 0), End: time.Unix(30, 0), LastUsed: now.Add(-time.Hour)},
+	})
+	rr.CropToSize(2, time.Unix(40, 0), timeseries.Extent{Start: time.Unix(10, 0), End: time.Unix(15, 0)})
+
+	series := rr.Results[0].Series
+	if len(series) != 2 {
+		t.Fatalf("unexpected series %v", series)
+	}
+	testEqualTimestamps(t, []int64{10000}, series[0])
+	testEqualTimestamps(t, []int64{15000}, series[1])
+	// the removed timestamp shared by the extents is trimmed from both
+	el := rr.Extents()
+	if len(el) != 2 || !el[0].Start.Equal(time.Unix(10, 0)) || !el[0].End.Equal(time.Unix(15, 0)) ||
+		!el[1].End.Equal(time.Unix(20, 0).Add(-time.Nanosecond)) {
+		t.Errorf("unexpected extents %v", el)
+	}
+
+	// a response without extents is emptied
+	rr = testReadResponse()
+	rr.CropToSize(10, now, timeseries.Extent{})
+	if rr.SeriesCount() != 0 {
+		t.Errorf("expected empty response")
+	}
+}
@@ -0,0 +1,328 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Comcast/trickster/internal/proxy/headers"
+	"github.com/Comcast/trickster/internal/timeseries"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/pkg/labels"
+)
+
+func testReadQuery(start, end int64, ms ...string) *readQuery {
+	q := &readQuery{start: start, end: end}
+	for i := 0; i+2 < len(ms); i += 3 {
+		var t labels.MatchType
+		switch ms[i+1] {
+		case "!=":
+			t = labels.MatchNotEqual
+		case "=~":
+			t = labels.MatchRegexp
+		case "!~":
+			t = labels.MatchNotRegexp
+		}
+		q.matchers = append(q.matchers, labels.MustNewMatcher(t, ms[i], ms[i+2]))
+	}
+	return q
+}
+
+func newReadRequest(rr *readRequest) *http.Request {
+	return newRawReadRequest(snappy.Encode(nil, rr.marshal()))
+}
+
+func newRawReadRequest(b []byte) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "http://0/api/v1/read", bytes.NewReader(b))
+	r.Header.Set(headers.NameContentType, mediaTypeProtobuf)
+	r.Header.Set(headers.NameContentEncoding, encodingSnappy)
+	return r
+}
+
+func TestReadRequestRoundTrip(t *testing.T) {
+
+	q := testReadQuery(1000, 2000, "__name__", "=", "up", "job", "=~", "api.*", "env", "!=", "dev", "dc", "!~", "x|y")
+	q.hints = &readHints{stepMs: 15000, function: "rate", startMs: 700, endMs: 2000,
+		grouping: []string{"job", "dc"}, by: true, rangeMs: 300000}
+	rr := &readRequest{queries: []*readQuery{q, testReadQuery(-5, 0, "a", "=", "")},
+		responseTypes: []uint64{1, 0}}
+
+	rr2, err := decodeReadRequest(rr.marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rr2.queries) != 2 {
+		t.Fatalf("expected %d got %d", 2, len(rr2.queries))
+	}
+	if len(rr2.responseTypes) != 2 || rr2.responseTypes[0] != 1 || rr2.responseTypes[1] != 0 {
+		t.Errorf("unexpected response types %v", rr2.responseTypes)
+	}
+
+	q2 := rr2.queries[0]
+	if q2.start != 1000 || q2.end != 2000 {
+		t.Errorf("expected 1000-2000 got %d-%d", q2.start, q2.end)
+	}
+	if len(q2.matchers) != 4 {
+		t.Fatalf("expected %d got %d", 4, len(q2.matchers))
+	}
+	for i, m := range q.matchers {
+		if q2.matchers[i].String() != m.String() {
+			t.Errorf("expected %s got %s", m, q2.matchers[i])
+		}
+	}
+	if h := q2.hints; h == nil || h.stepMs != 15000 || h.function != "rate" || h.startMs != 700 ||
+		h.endMs != 2000 || len(h.grouping) != 2 || h.grouping[1] != "dc" || !h.by || h.rangeMs != 300000 {
+		t.Errorf("unexpected hints %v", h)
+	}
+
+	if q3 := rr2.queries[1]; q3.start != -5 || q3.hints != nil {
+		t.Errorf("unexpected query %v", q3)
+	}
+
+	// packed response types
+	b := appendBytesField(nil, 2, appendVarint(appendVarint(nil, 1), 0))
+	rr2, err = decodeReadRequest(b)
+	if err != nil {
+		t.Error(err)
+	} else if len(rr2.responseTypes) != 2 || !rr2.acceptsSamples() {
+		t.Errorf("unexpected response types %v", rr2.responseTypes)
+	}
+}
+
+func TestDecodeReadRequestInvalid(t *testing.T) {
+
+	tests := [][]byte{
+		{0x0a},             // truncated length
+		{0x0a, 0x05, 0x08}, // length beyond the message
+		{0x0b},             // unsupported wire type
+		appendBytesField(nil, 1, appendBytesField(nil, 3, appendVarintField(nil, 1, 7))),                            // unknown matcher type
+		appendBytesField(nil, 1, appendBytesField(nil, 3, appendStringField(appendVarintField(nil, 1, 2), 3, "("))), // invalid regex
+	}
+
+	for i, b := range tests {
+		if _, err := decodeReadRequest(b); err == nil {
+			t.Errorf("test %d: expected error", i)
+		}
+	}
+
+	// unknown fields are skipped
+	b := appendFixed64Field(nil, 9, 1)
+	b = append(appendTag(b, 10, wireFixed32), 1, 2, 3, 4)
+	b = appendVarintField(b, 11, 1)
+	if _, err := decodeReadRequest(b); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAcceptsSamples(t *testing.T) {
+	tests := []struct {
+		types    []uint64
+		expected bool
+	}{
+		{nil, true},
+		{[]uint64{0}, true},
+		{[]uint64{1, 0}, true},
+		{[]uint64{1}, false},
+	}
+	for i, test := range tests {
+		rr := &readRequest{responseTypes: test.types}
+		if rr.acceptsSamples() != test.expected {
+			t.Errorf("test %d: expected %t", i, test.expected)
+		}
+	}
+}
+
+func TestIsRemoteRead(t *testing.T) {
+	r := newReadRequest(&readRequest{})
+	if !isRemoteRead(r) {
+		t.Error("expected remote read request")
+	}
+	r.Header.Del(headers.NameContentEncoding)
+	if isRemoteRead(r) {
+		t.Error("expected non-remote read request")
+	}
+	r, _ = http.NewRequest(http.MethodGet, "http://0/api/v1/read", nil)
+	if isRemoteRead(r) {
+		t.Error("expected non-remote read request")
+	}
+}
+
+func TestParseReadRequest(t *testing.T) {
+
+	q := testReadQuery(60000, 120000, "job", "=", "api", "__name__", "=", "up")
+	q.hints = &readHints{stepMs: 15000, startMs: 60000, endMs: 120000}
+	r := newReadRequest(&readRequest{queries: []*readQuery{q}})
+
+	c := &Client{}
+	trq, err := c.ParseTimeRangeQuery(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trq.Statement != `up{job="api"}` {
+		t.Errorf("expected %s got %s", `up{job="api"}`, trq.Statement)
+	}
+	if trq.Step != 0 {
+		t.Errorf("expected 0 got %s", trq.Step)
+	}
+	if !trq.Extent.Start.Equal(time.Unix(60, 0)) || !trq.Extent.End.Equal(time.Unix(120, 0)) {
+		t.Errorf("unexpected extent %s", trq.Extent)
+	}
+
+	// the matchers are canonical, and the hints' time range is not part of the cache key
+	q2 := testReadQuery(0, 180000, "__name__", "=", "up", "job", "=", "api")
+	q2.hints = &readHints{stepMs: 15000, startMs: 0, endMs: 180000}
+	trq2, err := c.ParseTimeRangeQuery(newReadRequest(&readRequest{queries: []*readQuery{q2}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trq2.TemplateURL.RawQuery != trq.TemplateURL.RawQuery {
+		t.Errorf("expected %s got %s", trq.TemplateURL.RawQuery, trq2.TemplateURL.RawQuery)
+	}
+
+	// the body can be read again
+	if _, err := readRequestBody(r); err != nil {
+		t.Error(err)
+	}
+
+	tests := []*readRequest{
+		{queries: []*readQuery{q, q2}},
+		{queries: []*readQuery{q}, responseTypes: []uint64{1}},
+		{queries: []*readQuery{testReadQuery(0, 1000)}},
+	}
+	for i, rr := range tests {
+		if _, err := parseReadRequest(newReadRequest(rr)); err == nil {
+			t.Errorf("test %d: expected error", i)
+		}
+	}
+
+	r = newRawReadRequest([]byte("invalid"))
+	if _, err := parseReadRequest(r); err == nil {
+		t.Error("expected error for invalid body")
+	}
+}
+
+func TestSetReadExtent(t *testing.T) {
+
+	q := testReadQuery(60000, 120000, "__name__", "=", "up")
+	q.hints = &readHints{function: "rate", startMs: 60000, endMs: 120000}
+	r := newReadRequest(&readRequest{queries: []*readQuery{q}, responseTypes: []uint64{1, 0}})
+
+	c := &Client{}
+	trq, err := c.ParseTimeRangeQuery(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := &timeseries.Extent{Start: time.Unix(90, 0), End: time.Unix(150, 500)}
+	c.SetExtent(r, trq, e)
+
+	rr, err := readRequestBody(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ContentLength <= 0 {
+		t.Errorf("expected content length")
+	}
+	if len(rr.responseTypes) != 0 {
+		t.Errorf("expected samples response type, got %v", rr.responseTypes)
+	}
+	q2 := rr.queries[0]
+	if q2.start != 90000 || q2.end != 150000 {
+		t.Errorf("expected 90000-150000 got %d-%d", q2.start, q2.end)
+	}
+	if q2.hints == nil || q2.hints.function != "rate" || q2.hints.startMs != 90000 || q2.hints.endMs != 150000 {
+		t.Errorf("unexpected hints %v", q2.hints)
+	}
+	if q2.selector() != "up" {
+		t.Errorf("expected %s got %s", "up", q2.selector())
+	}
+
+	// invalid hints are dropped
+	trq.TemplateURL.RawQuery = "match%5B%5D=up&hints=" + base64.RawURLEncoding.EncodeToString([]byte{0x0b})
+	setReadExtent(r, trq, e)
+	if rr, _ = readRequestBody(r); rr.queries[0].hints != nil {
+		t.Errorf("expected no hints")
+	}
+
+	// nothing is changed without a selector
+	trq.TemplateURL.RawQuery = ""
+	setReadExtent(r, trq, &timeseries.Extent{Start: time.Unix(1, 0), End: time.Unix(2, 0)})
+	if rr, _ = readRequestBody(r); rr.queries[0].start != 90000 {
+		t.Errorf("expected %d got %d", 90000, rr.queries[0].start)
+	}
+	setReadExtent(r, nil, e)
+}
+
+func TestReadResponseRoundTrip(t *testing.T) {
+
+	rr := testReadResponse()
+	rr.ExtentList = timeseries.ExtentList{
+		{Start: time.Unix(0, 1), End: time.Unix(10, 5), LastUsed: time.Unix(20, 0)},
+		{Start: time.Unix(30, 0), End: time.Unix(40, 0)},
+	}
+
+	c := &Client{}
+	b, err := c.MarshalTimeseries(rr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts, err := c.UnmarshalTimeseries(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr2, ok := ts.(*ReadResponse)
+	if !ok {
+		t.Fatalf("expected *ReadResponse got %T", ts)
+	}
+
+	if len(rr2.Results) != 2 || len(rr2.Results[0].Series) != 2 || len(rr2.Results[1].Series) != 0 {
+		t.Fatalf("unexpected results %v", rr2.Results)
+	}
+	s := rr2.Results[0].Series[1]
+	if s.Labels.String() != `{__name__="up", job="b"}` {
+		t.Errorf("unexpected labels %s", s.Labels)
+	}
+	if len(s.Samples) != 2 || s.Samples[1].Timestamp != 20000 || s.Samples[1].Value != -1.5 {
+		t.Errorf("unexpected samples %v", s.Samples)
+	}
+	if len(rr2.ExtentList) != 2 || !rr2.ExtentList[0].End.Equal(time.Unix(10, 5)) ||
+		!rr2.ExtentList[0].LastUsed.Equal(time.Unix(20, 0)) || !rr2.ExtentList[1].LastUsed.IsZero() {
+		t.Errorf("unexpected extents %v", rr2.ExtentList)
+	}
+
+	// a response without extents has no extents field
+	rr.ExtentList = nil
+	b2, _ := c.MarshalTimeseries(rr)
+	if len(b2) >= len(b) {
+		t.Errorf("expected extents to be omitted")
+	}
+
+	if _, err := unmarshalReadResponse(snappy.Encode(nil, []byte{0x0a, 0x05})); err == nil {
+		t.Error("expected error for invalid response")
+	}
+	if _, err := unmarshalReadResponse([]byte("invalid")); err == nil {
+		t.Error("expected error for invalid response")
+	}
+
+	// JSON is still unmarshaled as a matrix
+	if _, err := c.UnmarshalTimeseries([]byte("{")); err == nil {
+		t.Error("expected error for invalid json")
+	}
+}
@@ -16,6 +16,7 @@ package prometheus
 import (
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/Comcast/trickster/internal/config"
 	"github.com/Comcast/trickster/internal/proxy/headers"
@@ -30,6 +31,9 @@ func (c *Client) registerHandlers() {
 	c.handlers["query_range"] = http.HandlerFunc(c.QueryRangeHandler)
 	c.handlers["query"] = http.HandlerFunc(c.QueryHandler)
 	c.handlers["series"] = http.HandlerFunc(c.SeriesHandler)
+	c.handlers["labels"] = http.HandlerFunc(c.LabelsHandler)
+	c.handlers[mnExport] = http.HandlerFunc(c.ExportHandler)
+	c.handlers[mnRead] = http.HandlerFunc(c.ReadHandler)
 	c.handlers["proxycache"] = http.HandlerFunc(c.ObjectProxyCacheHandler)
 	c.handlers["proxy"] = http.HandlerFunc(c.ProxyHandler)
 }
@@ -54,6 +58,10 @@ func populateHeathCheckRequestValues(oc *config.OriginConfig) {
 	}
 }
 
+// defaultMetadataTimeQuantumSecs is the default granularity to which the start and end
+// times of series and label metadata requests are rounded
+const defaultMetadataTimeQuantumSecs = 60
+
 // DefaultPathConfigs returns the default PathConfigs for the given OriginType
 func (c *Client) DefaultPathConfigs(oc *config.OriginConfig) map[string]*config.PathConfig {
 
@@ -80,51 +88,72 @@ func (c *Client) DefaultPathConfigs(oc *config.OriginConfig) map[string]*config.
 		},
 
 		APIPath + mnQuery: {
-			Path:            APIPath + mnQuery,
-			HandlerName:     mnQuery,
-			Methods:         []string{http.MethodGet, http.MethodPost},
-			CacheKeyParams:  []string{upQuery, upTime},
-			CacheKeyHeaders: []string{},
-			ResponseHeaders: rhinst,
-			OriginConfig:    oc,
-			MatchTypeName:   "exact",
-			MatchType:       config.PathMatchTypeExact,
+			Path:               APIPath + mnQuery,
+			HandlerName:        mnQuery,
+			Methods:            []string{http.MethodGet, http.MethodPost},
+			CacheKeyParams:     []string{upQuery, upTime},
+			CacheKeyHeaders:    []string{},
+			CacheKeyFormFields: []string{upQuery, upTime},
+			ResponseHeaders:    rhinst,
+			OriginConfig:       oc,
+			MatchTypeName:      "exact",
+			MatchType:          config.PathMatchTypeExact,
 		},
 
 		APIPath + mnSeries: {
-			Path:            APIPath + mnSeries,
-			HandlerName:     mnSeries,
-			Methods:         []string{http.MethodGet, http.MethodPost},
-			CacheKeyParams:  []string{upMatch, upStart, upEnd},
-			CacheKeyHeaders: []string{},
-			ResponseHeaders: rhinst,
-			OriginConfig:    oc,
-			MatchTypeName:   "exact",
-			MatchType:       config.PathMatchTypeExact,
+			Path:               APIPath + mnSeries,
+			HandlerName:        mnSeries,
+			Methods:            []string{http.MethodGet, http.MethodPost},
+			CacheKeyParams:     []string{upMatch, upStart, upEnd},
+			CacheKeyHeaders:    []string{},
+			CacheKeyFormFields: []string{upMatch, upStart, upEnd},
+			ResponseHeaders:    rhinst,
+			OriginConfig:       oc,
+			MatchTypeName:      "exact",
+			MatchType:          config.PathMatchTypeExact,
+			TimeQuantumSecs:    defaultMetadataTimeQuantumSecs,
+			TimeQuantum:        defaultMetadataTimeQuantumSecs * time.Second,
 		},
 
 		APIPath + mnLabels: {
-			Path:            APIPath + mnLabels,
-			HandlerName:     "proxycache",
-			Methods:         []string{http.MethodGet, http.MethodPost},
-			CacheKeyParams:  []string{},
-			CacheKeyHeaders: []string{},
-			ResponseHeaders: rhinst,
-			OriginConfig:    oc,
-			MatchTypeName:   "exact",
-			MatchType:       config.PathMatchTypeExact,
+			Path:               APIPath + mnLabels,
+			HandlerName:        mnLabels,
+			Methods:            []string{http.MethodGet, http.MethodPost},
+			CacheKeyParams:     []string{upMatch, upStart, upEnd},
+			CacheKeyHeaders:    []string{},
+			CacheKeyFormFields: []string{upMatch, upStart, upEnd},
+			ResponseHeaders:    rhinst,
+			OriginConfig:       oc,
+			MatchTypeName:      "exact",
+			MatchType:          config.PathMatchTypeExact,
+			TimeQuantumSecs:    defaultMetadataTimeQuantumSecs,
+			TimeQuantum:        defaultMetadataTimeQuantumSecs * time.Second,
 		},
 
 		APIPath + mnLabel + "/": {
 			Path:            APIPath + mnLabel + "/",
-			HandlerName:     "proxycache",
+			HandlerName:     mnLabels,
 			Methods:         []string{http.MethodGet},
-			CacheKeyParams:  []string{},
+			CacheKeyParams:  []string{upMatch, upStart, upEnd},
 			CacheKeyHeaders: []string{},
 			MatchTypeName:   "prefix",
 			MatchType:       config.PathMatchTypePrefix,
 			ResponseHeaders: rhinst,
 			OriginConfig:    oc,
+			TimeQuantumSecs: defaultMetadataTimeQuantumSecs,
+			TimeQuantum:     defaultMetadataTimeQuantumSecs * time.Second,
+		},
+
+		APIPath + mnRead: {
+			Path:            APIPath + mnRead,
+			HandlerName:     mnRead,
+			Methods:         []string{http.MethodPost},
+			CacheKeyParams:  []string{upMatch, upHints},
+			CacheKeyHeaders: []string{},
+			ResponseHeaders: rhts,
+			OriginConfig:    oc,
+			MatchTypeName:   "exact",
+			MatchType:       config.PathMatchTypeExact,
 		},
 
 		APIPath + mnTargets: {
@@ -218,8 +247,56 @@ func (c *Client) DefaultPathConfigs(oc *config.OriginConfig) map[string]*config.
 		},
 	}
 
+	if isVictoriaMetrics(oc) {
+		addVictoriaMetricsPathConfigs(paths, oc, rhinst)
+	}
+
 	oc.FastForwardPath = paths[APIPath+mnQuery].Clone()
 
 	return paths
 
 }
+
+// addVictoriaMetricsPathConfigs adds the VictoriaMetrics extra_label and extra_filters[] parameters,
+// which further filter the selected series, to the cache keys of the querying paths, and adds the
+// paths for its /export and /federate extensions to the Prometheus API
+func addVictoriaMetricsPathConfigs(paths map[string]*config.PathConfig, oc *config.OriginConfig,
+	rhinst map[string]string) {
+
+	vmParams := []string{upExtraLabel, upExtraFilters}
+	for _, k := range []string{APIPath + mnQueryRange, APIPath + mnQuery, APIPath + mnSeries,
+		APIPath + mnLabels, APIPath + mnLabel + "/"} {
+		p := paths[k]
+		p.CacheKeyParams = append(p.CacheKeyParams, vmParams...)
+		if len(p.CacheKeyFormFields) > 0 {
+			p.CacheKeyFormFields = append(p.CacheKeyFormFields, vmParams...)
+		}
+	}
+
+	exportParams := []string{upMatch, upStart, upEnd, "max_rows_per_line", "reduce_mem_usage"}
+	paths[APIPath+mnExport] = &config.PathConfig{
+		Path:               APIPath + mnExport,
+		HandlerName:        mnExport,
+		Methods:            []string{http.MethodGet, http.MethodPost},
+		CacheKeyParams:     append(exportParams, vmParams...),
+		CacheKeyHeaders:    []string{},
+		CacheKeyFormFields: append(exportParams, vmParams...),
+		ResponseHeaders:    rhinst,
+		OriginConfig:       oc,
+		MatchTypeName:      "exact",
+		MatchType:          config.PathMatchTypeExact,
+	}
+
+	paths[mnFederate] = &config.PathConfig{
+		Path:            mnFederate,
+		HandlerName:     mnExport,
+		Methods:         []string{http.MethodGet},
+		CacheKeyParams:  append([]string{upMatch, "max_lookback"}, vmParams...),
+		CacheKeyHeaders: []string{},
+		ResponseHeaders: rhinst,
+		OriginConfig:    oc,
+		MatchTypeName:   "exact",
+		MatchType:       config.PathMatchTypeExact,
+	}
+
+}
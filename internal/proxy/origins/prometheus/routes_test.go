@@ -14,6 +14,7 @@
 package prometheus
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/Comcast/trickster/internal/proxy/request"
@@ -55,7 +56,51 @@ func TestDefaultPathConfigs(t *testing.T) {
 		t.Errorf("expected to find path named: %s", "/")
 	}
 
-	const expectedLen = 13
+	if _, ok := dpc[APIPath+mnRead]; !ok {
+		t.Errorf("expected to find path named: %s", APIPath+mnRead)
+	}
+
+	const expectedLen = 14
+	if len(dpc) != expectedLen {
+		t.Errorf("expected ordered length to be: %d got %d", expectedLen, len(dpc))
+	}
+
+}
+
+func TestDefaultPathConfigsVictoriaMetrics(t *testing.T) {
+
+	client := &Client{name: "test"}
+	ts, _, r, hc, err := tu.NewTestInstance("", client.DefaultPathConfigs, 200, "{}", nil, "victoriametrics", "/health", "debug")
+	rsc := request.GetResources(r)
+	rsc.OriginClient = client
+	client.config = rsc.OriginConfig
+	client.webClient = hc
+	defer ts.Close()
+	if err != nil {
+		t.Error(err)
+	}
+
+	dpc := client.DefaultPathConfigs(client.config)
+
+	for _, k := range []string{APIPath + mnExport, mnFederate} {
+		if p, ok := dpc[k]; !ok {
+			t.Errorf("expected to find path named: %s", k)
+		} else if p.HandlerName != mnExport {
+			t.Errorf("expected handler %s got %s", mnExport, p.HandlerName)
+		}
+	}
+
+	p := dpc[APIPath+mnQueryRange]
+	if strings.Join(p.CacheKeyParams, ",") != "query,step,extra_label,extra_filters[]" {
+		t.Errorf("unexpected cache key params %v", p.CacheKeyParams)
+	}
+
+	p = dpc[APIPath+mnSeries]
+	if strings.Join(p.CacheKeyFormFields, ",") != "match[],start,end,extra_label,extra_filters[]" {
+		t.Errorf("unexpected cache key form fields %v", p.CacheKeyFormFields)
+	}
+
+	const expectedLen = 16
 	if len(dpc) != expectedLen {
 		t.Errorf("expected ordered length to be: %d got %d", expectedLen, len(dpc))
 	}
@@ -14,11 +14,16 @@
 package prometheus
 
 import (
+	"bytes"
+	"io/ioutil"
+	"mime"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
 
+	"github.com/Comcast/trickster/internal/proxy/errors"
+	"github.com/Comcast/trickster/internal/proxy/headers"
 	"github.com/Comcast/trickster/internal/proxy/urls"
 	"github.com/Comcast/trickster/internal/timeseries"
 )
@@ -50,6 +55,26 @@ func (c *Client) BuildUpstreamURL(r *http.Request) *url.URL {
 
 // SetExtent will change the upstream request query to use the provided Extent
 func (c *Client) SetExtent(r *http.Request, trq *timeseries.TimeRangeQuery, extent *timeseries.Extent) {
+
+	if isRemoteRead(r) {
+		setReadExtent(r, trq, extent)
+		return
+	}
+
+	if strings.HasSuffix(r.URL.Path, "/"+mnQuery) {
+		setRangeSelectorExtent(r, trq, extent)
+		return
+	}
+
+	// the parameters of form-encoded POST requests are rewritten in the body
+	if trq != nil && trq.TemplateURL != nil && isFormPost(r) {
+		params := trq.TemplateURL.Query()
+		params.Set(upStart, strconv.FormatInt(extent.Start.Unix(), 10))
+		params.Set(upEnd, strconv.FormatInt(extent.End.Unix(), 10))
+		setFormBody(r, params)
+		return
+	}
+
 	params := r.URL.Query()
 	params.Set(upStart, strconv.FormatInt(extent.Start.Unix(), 10))
 	params.Set(upEnd, strconv.FormatInt(extent.End.Unix(), 10))
@@ -65,7 +90,12 @@ func (c *Client) FastForwardURL(r *http.Request) (*url.URL, error) {
 		u.Path = u.Path[0 : len(u.Path)-6]
 	}
 
-	p := u.Query()
+	// the parameters of form-encoded POST requests are included in the URL, so
+	// that the fast forward request is cache-keyed on the query
+	p, err := requestValues(r)
+	if err != nil {
+		return nil, err
+	}
 	p.Del(upStart)
 	p.Del(upEnd)
 	p.Del(upStep)
@@ -73,3 +103,66 @@ func (c *Client) FastForwardURL(r *http.Request) (*url.URL, error) {
 
 	return u, nil
 }
+
+// isFormPost returns true if the request is a POST with form-encoded parameters in its body
+func isFormPost(r *http.Request) bool {
+	if r.Method != http.MethodPost || r.Body == nil {
+		return false
+	}
+	// media types are case-insensitive and may have parameters, such as a charset
+	mt, _, err := mime.ParseMediaType(r.Header.Get(headers.NameContentType))
+	return err == nil && mt == headers.ValueXFormURLEncoded
+}
+
+// hasBody returns true if the request has a body, which may be empty when its length is unknown
+func hasBody(r *http.Request) bool {
+	return r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0
+}
+
+// formValues returns the parameters of a form-encoded POST body. The body is restored
+// so that it can be read again.
+func formValues(r *http.Request) (url.Values, error) {
+
+	b, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		return nil, errors.ParseRequestBody(err)
+	}
+
+	form, err := url.ParseQuery(string(b))
+	if err != nil {
+		return nil, errors.ParseRequestBody(err)
+	}
+
+	return form, nil
+}
+
+// setFormBody replaces the body of a form-encoded POST request with the provided parameters
+func setFormBody(r *http.Request, params url.Values) {
+	b := []byte(params.Encode())
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	r.ContentLength = int64(len(b))
+	r.Header.Set(headers.NameContentLength, strconv.Itoa(len(b)))
+}
+
+// requestValues returns the URL query parameters of the request, along with the parameters
+// of a form-encoded POST body, which take precedence as they do in Prometheus. The body
+// is restored so that it can be read again.
+func requestValues(r *http.Request) (url.Values, error) {
+
+	params := r.URL.Query()
+	if !isFormPost(r) {
+		return params, nil
+	}
+
+	form, err := formValues(r)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range form {
+		params[k] = v
+	}
+
+	return params, nil
+}
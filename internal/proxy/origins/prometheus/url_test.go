@@ -15,12 +15,15 @@ package prometheus
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/Comcast/trickster/internal/config"
+	"github.com/Comcast/trickster/internal/proxy/headers"
 	"github.com/Comcast/trickster/internal/timeseries"
 )
 
@@ -52,6 +55,29 @@ func TestSetExtent(t *testing.T) {
 	}
 }
 
+func TestSetExtentFormPost(t *testing.T) {
+
+	client := &Client{}
+	r, _ := http.NewRequest(http.MethodPost, "http://0/api/v1/query_range", strings.NewReader("query=up&start=0&end=900&step=15"))
+	r.Header.Set(headers.NameContentType, headers.ValueXFormURLEncoded)
+	trq, err := client.ParseTimeRangeQuery(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client.SetExtent(r, trq, &timeseries.Extent{Start: time.Unix(300, 0), End: time.Unix(600, 0)})
+
+	const expected = "end=600&query=up&start=300&step=15"
+	b, _ := ioutil.ReadAll(r.Body)
+	if string(b) != expected {
+		t.Errorf("\nexpected [%s]\ngot [%s]", expected, b)
+	}
+
+	if r.ContentLength != int64(len(expected)) || r.URL.RawQuery != "" {
+		t.Errorf("unexpected content length %d or query %s", r.ContentLength, r.URL.RawQuery)
+	}
+}
+
 func TestFastForwardURL(t *testing.T) {
 
 	expected := "q=up"
@@ -78,6 +104,28 @@ func TestFastForwardURL(t *testing.T) {
 
 }
 
+func TestFastForwardURLFormPost(t *testing.T) {
+
+	client := &Client{}
+	r, _ := http.NewRequest(http.MethodPost, "http://0/api/v1/query_range", strings.NewReader("query=up&start=1&end=1&step=1"))
+	r.Header.Set(headers.NameContentType, headers.ValueXFormURLEncoded)
+
+	u, err := client.FastForwardURL(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u.Path != "/api/v1/query" || u.RawQuery != "query=up" {
+		t.Errorf("expected %s got %s", "/api/v1/query?query=up", u)
+	}
+
+	r, _ = http.NewRequest(http.MethodPost, "http://0/api/v1/query_range", strings.NewReader("query=%zz"))
+	r.Header.Set(headers.NameContentType, headers.ValueXFormURLEncoded)
+	if _, err = client.FastForwardURL(r); err == nil {
+		t.Error("expected error for invalid form body")
+	}
+}
+
 func TestBuildUpstreamURL(t *testing.T) {
 
 	cfg := config.NewConfig()
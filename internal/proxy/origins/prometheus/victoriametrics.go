@@ -0,0 +1,53 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Comcast/trickster/internal/config"
+)
+
+// VictoriaMetrics extends the Prometheus API with these paths and URL parameters, which the
+// Client supports when the origin is configured with the 'victoriametrics' Origin Type
+const (
+	originTypeVictoriaMetrics = "victoriametrics"
+
+	mnExport   = "export"
+	mnFederate = "/federate"
+
+	upExtraLabel   = "extra_label"
+	upExtraFilters = "extra_filters[]"
+	upNoCache      = "nocache"
+)
+
+// vmDefaultStep is the step VictoriaMetrics uses for range queries that don't provide one
+const vmDefaultStep = 5 * time.Minute
+
+// isVictoriaMetrics returns true if the origin is configured as VictoriaMetrics
+func isVictoriaMetrics(oc *config.OriginConfig) bool {
+	return oc != nil && strings.ToLower(oc.OriginType) == originTypeVictoriaMetrics
+}
+
+// noCache returns true if the request to a VictoriaMetrics origin provides nocache=1, which asks
+// that its results be computed by the origin instead of being served from any cache
+func (c *Client) noCache(r *http.Request) bool {
+	if !isVictoriaMetrics(c.config) {
+		return false
+	}
+	qp, err := requestValues(r)
+	return err == nil && qp.Get(upNoCache) == "1"
+}
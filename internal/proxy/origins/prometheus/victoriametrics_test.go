@@ -0,0 +1,78 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package prometheus
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Comcast/trickster/internal/config"
+	"github.com/Comcast/trickster/internal/proxy/headers"
+)
+
+func TestIsVictoriaMetrics(t *testing.T) {
+
+	tests := []struct {
+		oc       *config.OriginConfig
+		expected bool
+	}{
+		{nil, false},
+		{&config.OriginConfig{OriginType: "prometheus"}, false},
+		{&config.OriginConfig{OriginType: "victoriametrics"}, true},
+		{&config.OriginConfig{OriginType: "VictoriaMetrics"}, true},
+	}
+
+	for i, test := range tests {
+		if res := isVictoriaMetrics(test.oc); res != test.expected {
+			t.Errorf("test %d expected %t got %t", i, test.expected, res)
+		}
+	}
+
+}
+
+func TestNoCache(t *testing.T) {
+
+	vm := &Client{config: &config.OriginConfig{OriginType: "victoriametrics"}}
+	prom := &Client{config: &config.OriginConfig{OriginType: "prometheus"}}
+
+	tests := []struct {
+		client   *Client
+		method   string
+		params   url.Values
+		expected bool
+	}{
+		{vm, http.MethodGet, url.Values{upQuery: {"up"}}, false},
+		{vm, http.MethodGet, url.Values{upQuery: {"up"}, upNoCache: {"1"}}, true},
+		{vm, http.MethodGet, url.Values{upQuery: {"up"}, upNoCache: {"0"}}, false},
+		{vm, http.MethodPost, url.Values{upQuery: {"up"}, upNoCache: {"1"}}, true},
+		{prom, http.MethodGet, url.Values{upQuery: {"up"}, upNoCache: {"1"}}, false},
+	}
+
+	for i, test := range tests {
+		var r *http.Request
+		if test.method == http.MethodPost {
+			r = httptest.NewRequest(http.MethodPost, "http://0/api/v1/query", strings.NewReader(test.params.Encode()))
+			r.Header.Set(headers.NameContentType, headers.ValueXFormURLEncoded)
+		} else {
+			r = httptest.NewRequest(http.MethodGet, "http://0/api/v1/query?"+test.params.Encode(), nil)
+		}
+		if res := test.client.noCache(r); res != test.expected {
+			t.Errorf("test %d expected %t got %t", i, test.expected, res)
+		}
+	}
+
+}
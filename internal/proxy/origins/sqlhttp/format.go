@@ -0,0 +1,95 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package sqlhttp
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Time formats supported for time filters and timestamp columns. Any other format is a Go time layout.
+const (
+	tfRFC3339 = "rfc3339"
+	tfEpochS  = "epoch_s"
+	tfEpochMS = "epoch_ms"
+	tfEpochUS = "epoch_us"
+	tfEpochNS = "epoch_ns"
+)
+
+// epochUnits maps the epoch time formats to their units
+var epochUnits = map[string]time.Duration{
+	tfEpochS:  time.Second,
+	tfEpochMS: time.Millisecond,
+	tfEpochUS: time.Microsecond,
+	tfEpochNS: time.Nanosecond,
+}
+
+// parseTime parses a time in the provided format. Times without a zone are in UTC.
+func parseTime(s, format string) (time.Time, error) {
+
+	s = strings.TrimSpace(s)
+
+	if unit, ok := epochUnits[format]; ok {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return time.Unix(0, n*int64(unit)).UTC(), nil
+		}
+		// fractional epochs, as are rendered by some JSON encoders. The integer and fractional
+		// parts are parsed separately when possible, so that precision is not lost.
+		if i := strings.IndexByte(s, '.'); i > 0 && !strings.ContainsAny(s, "eE") {
+			n, err := strconv.ParseInt(s[:i], 10, 64)
+			if err != nil {
+				return time.Time{}, err
+			}
+			f, err := strconv.ParseFloat("0"+s[i:], 64)
+			if err != nil {
+				return time.Time{}, err
+			}
+			if n < 0 {
+				f = -f
+			}
+			return time.Unix(0, n*int64(unit)+int64(math.Round(f*float64(unit)))).UTC(), nil
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(0, int64(f*float64(unit))).UTC(), nil
+	}
+
+	layout := format
+	if format == tfRFC3339 {
+		layout = time.RFC3339Nano
+	}
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.UTC(), nil
+}
+
+// formatTime renders a time in the provided format
+func formatTime(t time.Time, format string) string {
+
+	if unit, ok := epochUnits[format]; ok {
+		return strconv.FormatInt(t.UnixNano()/int64(unit), 10)
+	}
+
+	layout := format
+	if format == tfRFC3339 {
+		layout = time.RFC3339Nano
+	}
+	return t.UTC().Format(layout)
+}
@@ -0,0 +1,84 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package sqlhttp
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+
+	expected := time.Unix(1577836800, 0).UTC()
+	expectedMS := expected.Add(250 * time.Millisecond)
+
+	tests := []struct {
+		value, format string
+		expected      time.Time
+		err           bool
+	}{
+		{"2020-01-01T00:00:00Z", tfRFC3339, expected, false},
+		{"2020-01-01T00:00:00.250000Z", tfRFC3339, expectedMS, false},
+		{"2020-01-01T02:00:00+02:00", tfRFC3339, expected, false},
+		{"2020-01-01 00:00:00", tfRFC3339, time.Time{}, true},
+		{"2020-01-01 00:00:00", "2006-01-02 15:04:05", expected, false},
+		{"1577836800", tfEpochS, expected, false},
+		{" 1577836800 ", tfEpochS, expected, false},
+		{"1577836800.25", tfEpochS, expectedMS, false},
+		{"1577836800250", tfEpochMS, expectedMS, false},
+		{"1.5778368e12", tfEpochMS, expected, false},
+		{"1577836800250000", tfEpochUS, expectedMS, false},
+		{"1577836800250000000", tfEpochNS, expectedMS, false},
+		{"now()", tfEpochS, time.Time{}, true},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			tm, err := parseTime(test.value, test.format)
+			if (err != nil) != test.err {
+				t.Errorf("unexpected error %v", err)
+			}
+			if !tm.Equal(test.expected) {
+				t.Errorf("expected %s got %s", test.expected, tm)
+			}
+		})
+	}
+
+}
+
+func TestFormatTime(t *testing.T) {
+
+	tm := time.Unix(1577836800, int64(250*time.Millisecond)).In(time.FixedZone("test", 3600))
+
+	tests := []struct {
+		format, expected string
+	}{
+		{tfRFC3339, "2020-01-01T00:00:00.25Z"},
+		{"2006-01-02 15:04:05.000", "2020-01-01 00:00:00.250"},
+		{tfEpochS, "1577836800"},
+		{tfEpochMS, "1577836800250"},
+		{tfEpochUS, "1577836800250000"},
+		{tfEpochNS, "1577836800250000000"},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			if s := formatTime(tm, test.format); s != test.expected {
+				t.Errorf("expected %s got %s", test.expected, s)
+			}
+		})
+	}
+
+}
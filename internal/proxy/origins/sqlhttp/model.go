@@ -0,0 +1,316 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package sqlhttp
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Comcast/trickster/internal/config"
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+// Response formats
+const (
+	rfJSON = "json"
+	rfCSV  = "csv"
+)
+
+// envelopePrefix identifies a ResultSet that was marshaled for cache storage
+var envelopePrefix = []byte(`{"sqlhttp_body":`)
+
+// ResultSet represents the results of a SQL statement. Each row holds the results for a
+// timestamp (and, for statements that group by other columns, a set of their values).
+type ResultSet struct {
+	Rows         []Row
+	StepDuration time.Duration
+	ExtentList   timeseries.ExtentList
+
+	format     string                     // the response format of the results
+	header     []byte                     // the header row of CSV results
+	rowsField  string                     // the field of JSON results that holds the rows
+	fields     map[string]json.RawMessage // the other fields of JSON results, such as the columns
+	timestamps map[time.Time]int          // tracks the count of rows for each timestamp in the result set
+	isSorted   bool                       // tracks if the result set is currently sorted
+	isCounted  bool                       // tracks if timestamps map is up-to-date
+}
+
+// Row represents a single result row. The row is carried as-is, as a JSON value or an
+// encoded CSV record, since its layout depends upon the statement.
+type Row struct {
+	Timestamp time.Time
+	Data      []byte
+}
+
+// resultSetEnvelope is the cached representation of a ResultSet, which carries the step and extents
+type resultSetEnvelope struct {
+	Body         string                `json:"sqlhttp_body"`
+	StepDuration time.Duration         `json:"step,omitempty"`
+	ExtentList   timeseries.ExtentList `json:"extents,omitempty"`
+}
+
+// MarshalTimeseries converts a Timeseries into a response body
+func (c *Client) MarshalTimeseries(ts timeseries.Timeseries) ([]byte, error) {
+	rs, ok := ts.(*ResultSet)
+	if !ok {
+		return nil, fmt.Errorf("unsupported timeseries type: %T", ts)
+	}
+	return rs.marshal()
+}
+
+// UnmarshalTimeseries converts a response body into a Timeseries
+func (c *Client) UnmarshalTimeseries(data []byte) (timeseries.Timeseries, error) {
+	return unmarshalResultSet(data, c.config.SQLHTTP)
+}
+
+// unmarshalResultSet converts a cached ResultSet or a query response body into a ResultSet
+func unmarshalResultSet(data []byte, sc *config.SQLHTTPConfig) (*ResultSet, error) {
+
+	if bytes.HasPrefix(data, envelopePrefix) {
+		re := &resultSetEnvelope{}
+		if err := json.Unmarshal(data, re); err != nil {
+			return nil, err
+		}
+		rs, err := parseResults([]byte(re.Body), sc)
+		if err != nil {
+			return nil, err
+		}
+		rs.StepDuration = re.StepDuration
+		rs.ExtentList = re.ExtentList
+		return rs, nil
+	}
+
+	return parseResults(data, sc)
+}
+
+// parseResults parses a query response body in the configured response format
+func parseResults(data []byte, sc *config.SQLHTTPConfig) (*ResultSet, error) {
+	if sc.ResponseFormat == rfCSV {
+		return parseCSV(data, sc)
+	}
+	return parseJSON(data, sc)
+}
+
+// parseJSON parses JSON results, whose rows are arrays of values (named by the columns field) or objects
+func parseJSON(data []byte, sc *config.SQLHTTPConfig) (*ResultSet, error) {
+
+	rs := &ResultSet{format: rfJSON, rowsField: sc.RowsField}
+
+	var list []json.RawMessage
+	var columns []string
+
+	if sc.RowsField == "" {
+		if err := json.Unmarshal(data, &list); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := json.Unmarshal(data, &rs.fields); err != nil {
+			return nil, err
+		}
+		raw, ok := rs.fields[sc.RowsField]
+		if !ok {
+			return nil, fmt.Errorf("missing result field: %s", sc.RowsField)
+		}
+		delete(rs.fields, sc.RowsField)
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return nil, err
+		}
+		if raw, ok := rs.fields[sc.ColumnsField]; ok && sc.ColumnsField != "" {
+			var err error
+			if columns, err = parseColumns(raw); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	idx := -1
+	for i, name := range columns {
+		if name == sc.TimestampColumn {
+			idx = i
+			break
+		}
+	}
+
+	rs.Rows = make([]Row, len(list))
+	for i, raw := range list {
+		v, err := rowTimestamp(raw, idx, sc.TimestampColumn)
+		if err != nil {
+			return nil, err
+		}
+		t, err := parseTime(v, sc.TimestampFormat)
+		if err != nil {
+			return nil, err
+		}
+		rs.Rows[i] = Row{Timestamp: t, Data: raw}
+	}
+
+	return rs, nil
+}
+
+// parseColumns returns the names of the columns, which are strings or objects with a name
+func parseColumns(data []byte) ([]string, error) {
+	var list []interface{}
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	names := make([]string, len(list))
+	for i, v := range list {
+		switch t := v.(type) {
+		case string:
+			names[i] = t
+		case map[string]interface{}:
+			names[i], _ = t["name"].(string)
+		}
+	}
+	return names, nil
+}
+
+// rowTimestamp returns the value of the timestamp column of a JSON row. Rows that are arrays
+// of values are indexed by the position of the timestamp column.
+func rowTimestamp(data []byte, idx int, column string) (string, error) {
+
+	var row interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	if err := d.Decode(&row); err != nil {
+		return "", err
+	}
+
+	var v interface{}
+	switch t := row.(type) {
+	case []interface{}:
+		if idx < 0 || idx >= len(t) {
+			return "", fmt.Errorf("missing timestamp column: %s", column)
+		}
+		v = t[idx]
+	case map[string]interface{}:
+		var ok bool
+		if v, ok = t[column]; !ok {
+			return "", fmt.Errorf("missing timestamp column: %s", column)
+		}
+	}
+
+	switch t := v.(type) {
+	case string:
+		return t, nil
+	case json.Number:
+		return t.String(), nil
+	}
+	return "", fmt.Errorf("invalid timestamp: %v", v)
+}
+
+// parseCSV parses CSV results, whose first record is the header row
+func parseCSV(data []byte, sc *config.SQLHTTPConfig) (*ResultSet, error) {
+
+	rs := &ResultSet{format: rfCSV}
+
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		rs.Rows = []Row{}
+		return rs, nil
+	}
+
+	idx := -1
+	for i, name := range records[0] {
+		if name == sc.TimestampColumn {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		return nil, fmt.Errorf("missing timestamp column: %s", sc.TimestampColumn)
+	}
+
+	if rs.header, err = encodeCSV(records[0]); err != nil {
+		return nil, err
+	}
+
+	rs.Rows = make([]Row, len(records)-1)
+	for i, record := range records[1:] {
+		t, err := parseTime(record[idx], sc.TimestampFormat)
+		if err != nil {
+			return nil, err
+		}
+		b, err := encodeCSV(record)
+		if err != nil {
+			return nil, err
+		}
+		rs.Rows[i] = Row{Timestamp: t, Data: b}
+	}
+
+	return rs, nil
+}
+
+// encodeCSV returns the CSV encoding of the record, including the line ending
+func encodeCSV(record []string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w := csv.NewWriter(buf)
+	w.Write(record)
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// Body returns the ResultSet as a query response body in its response format
+func (rs *ResultSet) Body() ([]byte, error) {
+
+	buf := &bytes.Buffer{}
+
+	if rs.format == rfCSV {
+		buf.Write(rs.header)
+		for _, row := range rs.Rows {
+			buf.Write(row.Data)
+		}
+		return buf.Bytes(), nil
+	}
+
+	buf.WriteByte('[')
+	for i, row := range rs.Rows {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(row.Data)
+	}
+	buf.WriteByte(']')
+
+	if rs.rowsField == "" {
+		return buf.Bytes(), nil
+	}
+
+	doc := make(map[string]json.RawMessage, len(rs.fields)+1)
+	for k, v := range rs.fields {
+		doc[k] = v
+	}
+	doc[rs.rowsField] = buf.Bytes()
+	return marshalJSON(doc)
+}
+
+// marshal returns the ResultSet as a query response body for client responses,
+// or as a JSON envelope that carries the step and extents for cache storage
+func (rs *ResultSet) marshal() ([]byte, error) {
+	b, err := rs.Body()
+	if err != nil {
+		return nil, err
+	}
+	if len(rs.ExtentList) == 0 && rs.StepDuration == 0 {
+		return b, nil
+	}
+	return json.Marshal(&resultSetEnvelope{Body: string(b),
+		StepDuration: rs.StepDuration, ExtentList: rs.ExtentList})
+}
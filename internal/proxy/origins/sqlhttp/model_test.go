@@ -0,0 +1,195 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package sqlhttp
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/trickster/internal/config"
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+// testResponse returns the results of a one minute step query from start to end (inclusive, in epoch
+// seconds), in the provided response format. When hosts are provided, the results are grouped by host.
+func testResponse(format string, hosts []string, start, end int64) string {
+	if len(hosts) == 0 {
+		hosts = []string{""}
+	}
+	rows := make([]string, 0, 8)
+	for ts := start; ts <= end; ts += 60 {
+		t := time.Unix(ts, 0).UTC().Format("2006-01-02T15:04:05.000000Z")
+		for _, h := range hosts {
+			switch {
+			case format == rfCSV && h == "":
+				rows = append(rows, fmt.Sprintf("%s,%d\n", t, ts/60))
+			case format == rfCSV:
+				rows = append(rows, fmt.Sprintf("%s,%s,%d\n", t, h, ts/60))
+			case h == "":
+				rows = append(rows, fmt.Sprintf(`["%s",%d]`, t, ts/60))
+			default:
+				rows = append(rows, fmt.Sprintf(`["%s","%s",%d]`, t, h, ts/60))
+			}
+		}
+	}
+	if format == rfCSV {
+		if hosts[0] == "" {
+			return "ts,count\n" + strings.Join(rows, "")
+		}
+		return "ts,host,count\n" + strings.Join(rows, "")
+	}
+	columns := `[{"name":"ts","type":"TIMESTAMP"},{"name":"count","type":"LONG"}]`
+	if hosts[0] != "" {
+		columns = `[{"name":"ts","type":"TIMESTAMP"},{"name":"host","type":"SYMBOL"},{"name":"count","type":"LONG"}]`
+	}
+	return fmt.Sprintf(`{"columns":%s,"count":%d,"dataset":[%s]}`, columns, len(rows), strings.Join(rows, ","))
+}
+
+func testResultSet(t *testing.T, format string, hosts []string, start, end int64) *ResultSet {
+	rs, err := unmarshalResultSet([]byte(testResponse(format, hosts, start, end)), testConfig(format))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs.StepDuration = time.Minute
+	rs.ExtentList = timeseries.ExtentList{{Start: time.Unix(start, 0), End: time.Unix(end, 0)}}
+	return rs
+}
+
+func TestMarshalTimeseries(t *testing.T) {
+
+	for _, format := range []string{rfJSON, rfCSV} {
+		t.Run(format, func(t *testing.T) {
+
+			client := &Client{config: &config.OriginConfig{SQLHTTP: testConfig(format)}}
+			expected := testResponse(format, []string{"a", "b"}, 600, 720)
+
+			ts, err := client.UnmarshalTimeseries([]byte(expected))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// client responses are marshaled as-is
+			b, err := client.MarshalTimeseries(ts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != expected {
+				t.Errorf("\nexpected [%s]\ngot      [%s]", expected, b)
+			}
+
+			// cached result sets carry the step and extents
+			rs := ts.(*ResultSet)
+			rs.SetStep(time.Minute)
+			rs.SetExtents(timeseries.ExtentList{{Start: time.Unix(600, 0), End: time.Unix(720, 0)}})
+			b, err = client.MarshalTimeseries(rs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(string(b), string(envelopePrefix)) {
+				t.Errorf("expected envelope got %s", b)
+			}
+
+			ts, err = client.UnmarshalTimeseries(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rs2 := ts.(*ResultSet)
+			if rs2.Step() != time.Minute || len(rs2.Extents()) != 1 || rs2.ValueCount() != 6 {
+				t.Errorf("unexpected result set %v", rs2)
+			}
+			if b, _ = rs2.Body(); string(b) != expected {
+				t.Errorf("\nexpected [%s]\ngot      [%s]", expected, b)
+			}
+
+			if _, err = client.MarshalTimeseries(nil); err == nil {
+				t.Error("expected error for unsupported timeseries type")
+			}
+		})
+	}
+}
+
+func TestUnmarshalObjectRows(t *testing.T) {
+
+	// the result is an array of objects with epoch timestamps
+	sc := testConfig(rfJSON)
+	sc.RowsField = ""
+	sc.TimestampFormat = tfEpochMS
+
+	const expected = `[{"ts":600000,"count":10},{"ts":"660000","count":11}]`
+	rs, err := unmarshalResultSet([]byte(expected), sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs.ValueCount() != 2 || rs.Rows[1].Timestamp.Unix() != 660 {
+		t.Errorf("unexpected rows %v", rs.Rows)
+	}
+	if b, _ := rs.Body(); string(b) != expected {
+		t.Errorf("\nexpected [%s]\ngot      [%s]", expected, b)
+	}
+
+	// columns named by strings
+	sc = testConfig(rfJSON)
+	sc.RowsField = "rows"
+	sc.ColumnsField = "cols"
+	rs, err = unmarshalResultSet([]byte(`{"cols":["count","ts"],"rows":[[10,"1970-01-01T00:10:00Z"]],"rowcount":1}`), sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rs.ValueCount() != 1 || rs.Rows[0].Timestamp.Unix() != 600 {
+		t.Errorf("unexpected rows %v", rs.Rows)
+	}
+}
+
+func TestUnmarshalTimeseriesErrors(t *testing.T) {
+
+	client := &Client{config: &config.OriginConfig{SQLHTTP: testConfig(rfJSON)}}
+	csvClient := &Client{config: &config.OriginConfig{SQLHTTP: testConfig(rfCSV)}}
+
+	tests := []struct {
+		client *Client
+		body   string
+	}{
+		{client, `{"sqlhttp_body":`},
+		{client, `{"sqlhttp_body":"{}","step":60}`},
+		{client, `{"error":"table does not exist"}`},
+		{client, `{"dataset":{}}`},
+		{client, `{"dataset":[["1970-01-01T00:10:00Z",10]]}`},
+		{client, `{"columns":{},"dataset":[]}`},
+		{client, `{"columns":["ts","count"],"dataset":[[null,10]]}`},
+		{client, `{"columns":["ts","count"],"dataset":[["yesterday",10]]}`},
+		{client, `{"columns":["ts","count"],"dataset":[{"count":10}]}`},
+		{client, `[]`},
+		{csvClient, "count\n10\n"},
+		{csvClient, "ts,count\n1970-01-01T00:10:00Z\n"},
+		{csvClient, "ts,count\nyesterday,10\n"},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			if _, err := test.client.UnmarshalTimeseries([]byte(test.body)); err == nil {
+				t.Errorf("expected error for %s", test.body)
+			}
+		})
+	}
+
+	// an empty CSV result has no rows
+	ts, err := csvClient.UnmarshalTimeseries([]byte{})
+	if err != nil {
+		t.Error(err)
+	} else if ts.ValueCount() != 0 {
+		t.Errorf("expected %d got %d", 0, ts.ValueCount())
+	}
+}
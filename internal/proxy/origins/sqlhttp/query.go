@@ -0,0 +1,266 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package sqlhttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Comcast/trickster/internal/config"
+	"github.com/Comcast/trickster/internal/proxy/errors"
+	"github.com/Comcast/trickster/internal/proxy/timeconv"
+	"github.com/Comcast/trickster/internal/proxy/urls"
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+// This file handles parsing and tokenization of the time range filters and steps of SQL
+// statements, as described by the origin config, for cache key hashing and delta proxy caching.
+
+// Tokens for String Interpolation of the time range bounds in a tokenized statement
+const (
+	tkStart = "<$START_TOKEN$>"
+	tkEnd   = "<$END_TOKEN$>"
+)
+
+// Named groups of the time filter and step patterns
+const (
+	gnStart = "start"
+	gnEnd   = "end"
+	gnStep  = "step"
+)
+
+var reSelect, reLimit *regexp.Regexp
+
+func init() {
+	// Regexp for statements that are read-only queries
+	reSelect = regexp.MustCompile(`(?is)^\s*(select|with)\s`)
+	// Regexp for statements with a limit, whose results for one time range can't be merged with another's
+	reLimit = regexp.MustCompile(`(?i)\blimit\s+\d`)
+}
+
+// parseQueryRequest parses the key parts of a TimeRangeQuery from an inbound request, whose statement is in
+// the configured JSON body field, or in the configured query parameter of the URL or form-encoded body
+func parseQueryRequest(r *http.Request, sc *config.SQLHTTPConfig) (*timeseries.TimeRangeQuery, error) {
+
+	if sc == nil || sc.TimeFilter == nil || sc.Step == nil {
+		return nil, errors.ErrNotTimeRangeQuery
+	}
+
+	trq := &timeseries.TimeRangeQuery{TimestampFieldName: sc.TimestampColumn, FastForwardDisable: true}
+	trq.TemplateURL = urls.Clone(r.URL)
+
+	if sc.QueryBodyField != "" && isJSONPost(r) {
+
+		b, err := readBody(r)
+		if err != nil {
+			return nil, err
+		}
+
+		doc := map[string]interface{}{}
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.UseNumber()
+		if err := d.Decode(&doc); err != nil {
+			return nil, errors.ParseRequestBody(err)
+		}
+
+		stmt, ok := doc[sc.QueryBodyField].(string)
+		if !ok {
+			return nil, errors.MissingRequestParam(sc.QueryBodyField)
+		}
+		if err := parseStatement(trq, stmt, sc); err != nil {
+			return nil, err
+		}
+
+		// Swap in the Tokenized Body in the Url Params
+		doc[sc.QueryBodyField] = trq.Statement
+		template, err := marshalJSON(doc)
+		if err != nil {
+			return nil, err
+		}
+		qi := trq.TemplateURL.Query()
+		qi.Set(upQueryBody, string(template))
+		trq.TemplateURL.RawQuery = qi.Encode()
+		return trq, nil
+	}
+
+	params, err := requestValues(r)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := params.Get(sc.QueryParam)
+	if stmt == "" {
+		return nil, errors.MissingURLParam(sc.QueryParam)
+	}
+	if err := parseStatement(trq, stmt, sc); err != nil {
+		return nil, err
+	}
+
+	// Swap in the Tokenized Query in the Url Params
+	params.Set(sc.QueryParam, trq.Statement)
+	trq.TemplateURL.RawQuery = params.Encode()
+
+	return trq, nil
+}
+
+// parseStatement sets the tokenized statement, extent and step of the TimeRangeQuery from the SQL statement
+func parseStatement(trq *timeseries.TimeRangeQuery, stmt string, sc *config.SQLHTTPConfig) error {
+
+	if !reSelect.MatchString(stmt) {
+		return errors.ErrNotSelectStatement
+	}
+
+	if reLimit.MatchString(stmt) {
+		return errors.ErrNotTimeRangeQuery
+	}
+
+	m := sc.Step.FindStringSubmatch(stmt)
+	if m == nil {
+		return errors.ErrNotTimeRangeQuery
+	}
+	step, err := parseStep(m[subexpIndex(sc.Step, gnStep)])
+	if err != nil {
+		return err
+	}
+
+	template, start, end, err := tokenizeTimeFilter(stmt, sc)
+	if err != nil {
+		return err
+	}
+
+	if sc.TimeFilterEndExclusive {
+		// the last bucket is the one before the end
+		end = end.Add(-time.Nanosecond)
+	}
+	if end.Before(start) {
+		return errors.ErrNotTimeRangeQuery
+	}
+
+	trq.Statement = template
+	trq.Step = step
+	trq.Extent = timeseries.Extent{Start: start, End: end}
+	return nil
+}
+
+// tokenizeTimeFilter replaces the bounds captured by each match of the time filter pattern with tokens,
+// and returns the tokenized statement and the bounds, which must be the same for every match
+func tokenizeTimeFilter(stmt string, sc *config.SQLHTTPConfig) (string, time.Time, time.Time, error) {
+
+	var start, end time.Time
+
+	matches := sc.TimeFilter.FindAllStringSubmatchIndex(stmt, -1)
+	if len(matches) == 0 {
+		return "", start, end, errors.ErrNotTimeRangeQuery
+	}
+
+	si := subexpIndex(sc.TimeFilter, gnStart)
+	ei := subexpIndex(sc.TimeFilter, gnEnd)
+
+	type span struct {
+		from, to int
+		token    string
+	}
+	spans := make([]span, 0, len(matches)*2)
+
+	for i, m := range matches {
+		if m[2*si] < 0 || m[2*ei] < 0 {
+			return "", start, end, errors.ErrNotTimeRangeQuery
+		}
+		s, err := parseTime(stmt[m[2*si]:m[2*si+1]], sc.TimeFilterFormat)
+		if err != nil {
+			return "", start, end, errors.ErrNotTimeRangeQuery
+		}
+		e, err := parseTime(stmt[m[2*ei]:m[2*ei+1]], sc.TimeFilterFormat)
+		if err != nil {
+			return "", start, end, errors.ErrNotTimeRangeQuery
+		}
+		if i == 0 {
+			start, end = s, e
+		} else if !s.Equal(start) || !e.Equal(end) {
+			return "", start, end, errors.ErrNotTimeRangeQuery
+		}
+		spans = append(spans, span{m[2*si], m[2*si+1], tkStart}, span{m[2*ei], m[2*ei+1], tkEnd})
+	}
+
+	sort.Slice(spans, func(i, j int) bool { return spans[i].from < spans[j].from })
+
+	var sb strings.Builder
+	cursor := 0
+	for _, s := range spans {
+		if s.from < cursor {
+			// the start and end groups overlap
+			return "", start, end, errors.ErrNotTimeRangeQuery
+		}
+		sb.WriteString(stmt[cursor:s.from])
+		sb.WriteString(s.token)
+		cursor = s.to
+	}
+	sb.WriteString(stmt[cursor:])
+
+	return sb.String(), start, end, nil
+}
+
+// parseStep parses a step that is a duration, such as 30s or 5m, or a number of seconds
+func parseStep(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	var d time.Duration
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		d = time.Duration(n) * time.Second
+	} else if d, err = timeconv.ParseDuration(s); err != nil {
+		return 0, errors.ErrStepParse
+	}
+	if d <= 0 {
+		return 0, errors.ErrStepParse
+	}
+	return d, nil
+}
+
+// subexpIndex returns the index of the named group in the regular expression, or -1 if it has none
+func subexpIndex(re *regexp.Regexp, name string) int {
+	for i, n := range re.SubexpNames() {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// interpolateStatement replaces the time range tokens in a tokenized statement or body with the provided
+// extent. When the time filter's end is exclusive, it is extended to the end of the last bucket.
+func interpolateStatement(template string, extent *timeseries.Extent, step time.Duration,
+	sc *config.SQLHTTPConfig) string {
+	end := extent.End
+	if sc.TimeFilterEndExclusive {
+		end = end.Add(step)
+	}
+	return strings.NewReplacer(tkStart, formatTime(extent.Start, sc.TimeFilterFormat),
+		tkEnd, formatTime(end, sc.TimeFilterFormat)).Replace(template)
+}
+
+// marshalJSON marshals the value without escaping HTML characters, so that tokens remain intact
+func marshalJSON(v interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
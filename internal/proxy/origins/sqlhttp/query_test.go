@@ -0,0 +1,187 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package sqlhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/trickster/internal/proxy/errors"
+	"github.com/Comcast/trickster/internal/proxy/headers"
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+const testTimeFilter = "ts >= '1970-01-01T00:10:00Z' AND ts < '1970-01-01T00:13:00Z'"
+const testTokenizedFilter = "ts >= '" + tkStart + "' AND ts < '" + tkEnd + "'"
+
+func TestParseQueryRequest(t *testing.T) {
+
+	stmt := "SELECT ts, avg(value) FROM metrics WHERE " + testTimeFilter + " SAMPLE BY 1m"
+	tokenized := "SELECT ts, avg(value) FROM metrics WHERE " + testTokenizedFilter + " SAMPLE BY 1m"
+
+	sc := testConfig(rfJSON)
+	sc.QueryBodyField = "stmt"
+
+	get := httptest.NewRequest(http.MethodGet, "http://0/exec?fmt=json&query="+url.QueryEscape(stmt), nil)
+	form := httptest.NewRequest(http.MethodPost, "http://0/exec?fmt=json",
+		strings.NewReader(url.Values{"query": {stmt}}.Encode()))
+	form.Header.Set(headers.NameContentType, headers.ValueXFormURLEncoded)
+
+	trq, err := parseQueryRequest(get, sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trq.Statement != tokenized {
+		t.Errorf("expected %s got %s", tokenized, trq.Statement)
+	}
+	if trq.Extent.Start.Unix() != 600 || trq.Extent.End.Unix() != 779 || trq.Step != time.Minute {
+		t.Errorf("unexpected extent %s or step %s", trq.Extent, trq.Step)
+	}
+	if trq.TemplateURL.Query().Get("query") != tokenized || trq.TemplateURL.Query().Get("fmt") != "json" {
+		t.Errorf("unexpected template url %s", trq.TemplateURL)
+	}
+	if trq.TimestampFieldName != "ts" || !trq.FastForwardDisable {
+		t.Errorf("unexpected timestamp field %s or fast forward %t", trq.TimestampFieldName, trq.FastForwardDisable)
+	}
+
+	// form-encoded statements are included in the template url
+	trq, err = parseQueryRequest(form, sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trq.TemplateURL.Query().Get("query") != tokenized || trq.TemplateURL.Query().Get("fmt") != "json" {
+		t.Errorf("unexpected template url %s", trq.TemplateURL)
+	}
+
+	// JSON-encoded statements are tokenized within the body
+	body := `{"args":[1],"stmt":"` + strings.Replace(stmt, "'", `'`, -1) + `"}`
+	r := httptest.NewRequest(http.MethodPost, "http://0/_sql", strings.NewReader(body))
+	r.Header.Set(headers.NameContentType, headers.ValueApplicationJSON)
+	trq, err = parseQueryRequest(r, sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"args":[1],"stmt":"` + tokenized + `"}`
+	if b := trq.TemplateURL.Query().Get(upQueryBody); b != expected {
+		t.Errorf("expected %s got %s", expected, b)
+	}
+
+	tests := []struct {
+		stmt     string
+		expected error
+	}{
+		{"DELETE FROM metrics WHERE " + testTimeFilter + " SAMPLE BY 1m", errors.ErrNotSelectStatement},
+		{"SELECT * FROM metrics WHERE " + testTimeFilter, errors.ErrNotTimeRangeQuery},
+		{"SELECT * FROM metrics WHERE ts > 0 SAMPLE BY 1m", errors.ErrNotTimeRangeQuery},
+		{"SELECT * FROM metrics WHERE " + testTimeFilter + " SAMPLE BY 1m LIMIT 10", errors.ErrNotTimeRangeQuery},
+		{"SELECT * FROM metrics WHERE " + testTimeFilter + " SAMPLE BY 1x", errors.ErrStepParse},
+		{"SELECT * FROM metrics WHERE ts >= 'a' AND ts < 'b' SAMPLE BY 1m", errors.ErrNotTimeRangeQuery},
+		{"SELECT * FROM metrics WHERE ts >= '1970-01-01T00:13:00Z' AND ts < '1970-01-01T00:10:00Z' SAMPLE BY 1m",
+			errors.ErrNotTimeRangeQuery},
+		{"WITH a AS (SELECT * FROM metrics WHERE " + testTimeFilter + "), b AS (SELECT * FROM other WHERE " +
+			"ts >= '1970-01-01T00:00:00Z' AND ts < '1970-01-01T00:13:00Z') SELECT * FROM a SAMPLE BY 1m", errors.ErrNotTimeRangeQuery},
+		{"WITH a AS (SELECT * FROM metrics WHERE " + testTimeFilter + "), b AS (SELECT * FROM other WHERE " +
+			testTimeFilter + ") SELECT * FROM a SAMPLE BY 1m", nil},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://0/exec?query="+url.QueryEscape(test.stmt), nil)
+			trq, err := parseQueryRequest(r, sc)
+			if err != test.expected {
+				t.Errorf("expected %v got %v", test.expected, err)
+			}
+			if err == nil && (strings.Count(trq.Statement, tkStart) != 2 || strings.Count(trq.Statement, tkEnd) != 2) {
+				t.Errorf("expected every time filter to be tokenized in %s", trq.Statement)
+			}
+		})
+	}
+
+	// missing statement
+	r = httptest.NewRequest(http.MethodGet, "http://0/exec", nil)
+	if _, err = parseQueryRequest(r, sc); err == nil {
+		t.Errorf("expected error for missing statement")
+	}
+
+	// unconfigured origins aren't cached
+	if _, err = parseQueryRequest(get, nil); err != errors.ErrNotTimeRangeQuery {
+		t.Errorf("expected %v got %v", errors.ErrNotTimeRangeQuery, err)
+	}
+
+}
+
+func TestParseStep(t *testing.T) {
+
+	tests := []struct {
+		step     string
+		expected time.Duration
+		err      bool
+	}{
+		{"60", time.Minute, false},
+		{"5m", 5 * time.Minute, false},
+		{"1h", time.Hour, false},
+		{"1d", 24 * time.Hour, false},
+		{"0", 0, true},
+		{"0s", 0, true},
+		{"5 minutes", 0, true},
+	}
+
+	for i, test := range tests {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			d, err := parseStep(test.step)
+			if (err != nil) != test.err {
+				t.Errorf("unexpected error %v", err)
+			}
+			if d != test.expected {
+				t.Errorf("expected %s got %s", test.expected, d)
+			}
+		})
+	}
+
+}
+
+func TestInterpolateStatement(t *testing.T) {
+
+	sc := testConfig(rfJSON)
+	e := &timeseries.Extent{Start: time.Unix(600, 0), End: time.Unix(720, 0)}
+
+	// the end of an exclusive time filter is extended to the end of the last bucket
+	expected := "ts >= '1970-01-01T00:10:00Z' AND ts < '1970-01-01T00:13:00Z'"
+	if s := interpolateStatement(testTokenizedFilter, e, time.Minute, sc); s != expected {
+		t.Errorf("expected %s got %s", expected, s)
+	}
+
+	sc.TimeFilterEndExclusive = false
+	sc.TimeFilterFormat = tfEpochMS
+	expected = "ts >= '600000' AND ts < '720000'"
+	if s := interpolateStatement(testTokenizedFilter, e, time.Minute, sc); s != expected {
+		t.Errorf("expected %s got %s", expected, s)
+	}
+
+}
+
+func TestSubexpIndex(t *testing.T) {
+	sc := testConfig(rfJSON)
+	if i := subexpIndex(sc.TimeFilter, gnEnd); i != 2 {
+		t.Errorf("expected %d got %d", 2, i)
+	}
+	if i := subexpIndex(sc.TimeFilter, gnStep); i != -1 {
+		t.Errorf("expected %d got %d", -1, i)
+	}
+}
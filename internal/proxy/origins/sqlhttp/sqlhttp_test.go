@@ -0,0 +1,123 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package sqlhttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	cr "github.com/Comcast/trickster/internal/cache/registration"
+	"github.com/Comcast/trickster/internal/config"
+	"github.com/Comcast/trickster/internal/proxy/origins"
+	"github.com/Comcast/trickster/internal/util/metrics"
+)
+
+func init() {
+	metrics.Init()
+}
+
+// testConfigFile configures a default sqlhttp origin for test instances
+const testConfigFile = "../../../../testdata/test.sqlhttp.conf"
+
+// testConfig returns a QuestDB-style SQLHTTPConfig for the provided response format
+func testConfig(format string) *config.SQLHTTPConfig {
+	sc := config.NewSQLHTTPConfig()
+	sc.TimeFilterPattern = `ts >= '(?P<start>[^']+)' AND ts < '(?P<end>[^']+)'`
+	sc.TimeFilter = regexp.MustCompile(sc.TimeFilterPattern)
+	sc.TimeFilterEndExclusive = true
+	sc.StepPattern = `SAMPLE BY (?P<step>\w+)`
+	sc.Step = regexp.MustCompile(sc.StepPattern)
+	sc.TimestampColumn = "ts"
+	sc.ResponseFormat = format
+	sc.RowsField = "dataset"
+	return sc
+}
+
+func TestSQLHTTPClientInterfacing(t *testing.T) {
+
+	// this test ensures the client will properly conform to the
+	// Client and TimeseriesClient interfaces
+
+	c := &Client{name: "test"}
+	var oc origins.Client = c
+	var tc origins.TimeseriesClient = c
+
+	if oc.Name() != "test" {
+		t.Errorf("expected %s got %s", "test", oc.Name())
+	}
+
+	if tc.Name() != "test" {
+		t.Errorf("expected %s got %s", "test", tc.Name())
+	}
+}
+
+func TestNewClient(t *testing.T) {
+
+	err := config.Load("trickster", "test", []string{"-config", "../../../../testdata/test.sqlhttp.conf"})
+	if err != nil {
+		t.Errorf("Could not load configuration: %s", err.Error())
+	}
+
+	cr.LoadCachesFromConfig()
+	cache, err := cr.GetCache("default")
+	if err != nil {
+		t.Error(err)
+	}
+
+	oc := &config.OriginConfig{OriginType: "TEST_CLIENT"}
+	c, err := NewClient("default", oc, cache)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if c.Name() != "default" {
+		t.Errorf("expected %s got %s", "default", c.Name())
+	}
+
+	if c.Cache().Configuration().CacheType != "memory" {
+		t.Errorf("expected %s got %s", "memory", c.Cache().Configuration().CacheType)
+	}
+
+	if c.Configuration().OriginType != "TEST_CLIENT" {
+		t.Errorf("expected %s got %s", "TEST_CLIENT", c.Configuration().OriginType)
+	}
+
+	if c.HTTPClient() == nil {
+		t.Error("expected non-nil http client")
+	}
+
+	c.SetCache(nil)
+	if c.Cache() != nil {
+		t.Error("expected nil cache")
+	}
+}
+
+func TestParseTimeRangeQuery(t *testing.T) {
+
+	client := &Client{config: &config.OriginConfig{SQLHTTP: testConfig(rfJSON)}}
+	stmt := "SELECT ts, avg(value) FROM metrics WHERE ts >= '1970-01-01T00:10:00Z' AND ts < '1970-01-01T00:13:00Z' SAMPLE BY 1m"
+	r := httptest.NewRequest(http.MethodGet, "http://0/exec?query="+url.QueryEscape(stmt), nil)
+	trq, err := client.ParseTimeRangeQuery(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if trq.Extent.Start.Unix() != 600 || trq.Step != time.Minute || trq.TemplateURL.Query().Get("query") != trq.Statement {
+		t.Errorf("unexpected extent %v, step %s or template %s", trq.Extent, trq.Step, trq.TemplateURL)
+	}
+}
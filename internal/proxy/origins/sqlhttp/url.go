@@ -0,0 +1,151 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package sqlhttp
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Comcast/trickster/internal/proxy/errors"
+	"github.com/Comcast/trickster/internal/proxy/headers"
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+// BaseURL returns a URL in the form of scheme://host/path based on the proxy configuration
+func (c *Client) BaseURL() *url.URL {
+	u := &url.URL{}
+	u.Scheme = c.config.Scheme
+	u.Host = c.config.Host
+	u.Path = c.config.PathPrefix
+	return u
+}
+
+// BuildUpstreamURL will merge the downstream request with the BaseURL to construct the full upstream URL
+func (c *Client) BuildUpstreamURL(r *http.Request) *url.URL {
+	u := c.BaseURL()
+
+	if strings.HasPrefix(r.URL.Path, "/"+c.name+"/") {
+		u.Path += strings.Replace(r.URL.Path, "/"+c.name+"/", "/", 1)
+	} else {
+		u.Path += r.URL.Path
+	}
+
+	u.RawQuery = r.URL.RawQuery
+	u.Fragment = r.URL.Fragment
+	u.User = r.URL.User
+	return u
+}
+
+// SetExtent will change the upstream request statement to query the provided Extent, wherever the
+// statement was provided: in the JSON body, the form-encoded body or the URL query parameters
+func (c *Client) SetExtent(r *http.Request, trq *timeseries.TimeRangeQuery, extent *timeseries.Extent) {
+
+	if extent == nil || r == nil || trq == nil || trq.TemplateURL == nil {
+		return
+	}
+
+	sc := c.config.SQLHTTP
+	t := trq.TemplateURL.Query()
+
+	if body := t.Get(upQueryBody); body != "" {
+		setBody(r, []byte(interpolateStatement(body, extent, trq.Step, sc)))
+		return
+	}
+
+	q := t.Get(sc.QueryParam)
+	if q == "" {
+		return
+	}
+	stmt := interpolateStatement(q, extent, trq.Step, sc)
+
+	if isFormPost(r) {
+		form, err := formValues(r)
+		if err == nil && form.Get(sc.QueryParam) != "" {
+			form.Set(sc.QueryParam, stmt)
+			setBody(r, []byte(form.Encode()))
+			return
+		}
+	}
+
+	p := r.URL.Query()
+	p.Set(sc.QueryParam, stmt)
+	r.URL.RawQuery = p.Encode()
+}
+
+// isJSONPost returns true if the request is a POST with a JSON body
+func isJSONPost(r *http.Request) bool {
+	return r.Method == http.MethodPost && r.Body != nil &&
+		strings.HasPrefix(r.Header.Get(headers.NameContentType), headers.ValueApplicationJSON)
+}
+
+// isFormPost returns true if the request is a POST with a form-encoded body
+func isFormPost(r *http.Request) bool {
+	return r.Method == http.MethodPost && r.Body != nil &&
+		strings.HasPrefix(r.Header.Get(headers.NameContentType), headers.ValueXFormURLEncoded)
+}
+
+// formValues returns the parameters of a form-encoded POST body
+func formValues(r *http.Request) (url.Values, error) {
+	b, err := readBody(r)
+	if err != nil {
+		return nil, err
+	}
+	form, err := url.ParseQuery(string(b))
+	if err != nil {
+		return nil, errors.ParseRequestBody(err)
+	}
+	return form, nil
+}
+
+// requestValues returns the URL query parameters of the request, along with the parameters
+// of a form-encoded POST body, which take precedence
+func requestValues(r *http.Request) (url.Values, error) {
+
+	params := r.URL.Query()
+	if !isFormPost(r) {
+		return params, nil
+	}
+
+	form, err := formValues(r)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range form {
+		params[k] = v
+	}
+
+	return params, nil
+}
+
+// readBody returns the body of the request. The body is restored so that it can be read again.
+func readBody(r *http.Request) ([]byte, error) {
+	b, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		return nil, errors.ParseRequestBody(err)
+	}
+	return b, nil
+}
+
+// setBody replaces the body of the request with the provided bytes
+func setBody(r *http.Request, b []byte) {
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	r.ContentLength = int64(len(b))
+	r.Header.Set(headers.NameContentLength, strconv.Itoa(len(b)))
+}
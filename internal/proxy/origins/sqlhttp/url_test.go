@@ -0,0 +1,112 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package sqlhttp
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Comcast/trickster/internal/config"
+	"github.com/Comcast/trickster/internal/proxy/headers"
+	"github.com/Comcast/trickster/internal/timeseries"
+)
+
+func TestSetExtent(t *testing.T) {
+
+	sc := testConfig(rfJSON)
+	sc.QueryBodyField = "stmt"
+	client := &Client{config: &config.OriginConfig{SQLHTTP: sc}}
+	e := &timeseries.Extent{Start: time.Unix(600, 0), End: time.Unix(720, 0)}
+
+	stmt := "SELECT ts, avg(value) FROM metrics WHERE ts >= '1970-01-01T00:00:00Z' AND ts < '1970-01-01T01:00:00Z' SAMPLE BY 1m"
+	expected := "SELECT ts, avg(value) FROM metrics WHERE " + testTimeFilter + " SAMPLE BY 1m"
+
+	// statements in the url
+	r := httptest.NewRequest(http.MethodGet, "http://0/exec?fmt=json&query="+url.QueryEscape(stmt), nil)
+	trq, err := parseQueryRequest(r, sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetExtent(r, trq, e)
+	if q := r.URL.Query(); q.Get("query") != expected || q.Get("fmt") != "json" {
+		t.Errorf("unexpected url %s", r.URL)
+	}
+
+	// statements in a form-encoded body
+	r = httptest.NewRequest(http.MethodPost, "http://0/exec", strings.NewReader(url.Values{"query": {stmt}}.Encode()))
+	r.Header.Set(headers.NameContentType, headers.ValueXFormURLEncoded)
+	trq, err = parseQueryRequest(r, sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetExtent(r, trq, e)
+	b, _ := ioutil.ReadAll(r.Body)
+	if form, _ := url.ParseQuery(string(b)); form.Get("query") != expected {
+		t.Errorf("unexpected body %s", b)
+	}
+	if r.ContentLength != int64(len(b)) {
+		t.Errorf("expected %d got %d", len(b), r.ContentLength)
+	}
+
+	// statements in a JSON body
+	r = httptest.NewRequest(http.MethodPost, "http://0/_sql", strings.NewReader(`{"stmt":"`+stmt+`"}`))
+	r.Header.Set(headers.NameContentType, headers.ValueApplicationJSON)
+	trq, err = parseQueryRequest(r, sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.SetExtent(r, trq, e)
+	b, _ = ioutil.ReadAll(r.Body)
+	if string(b) != `{"stmt":"`+expected+`"}` {
+		t.Errorf("unexpected body %s", b)
+	}
+
+	// a nil extent leaves the request unchanged
+	r.Body = ioutil.NopCloser(strings.NewReader("test"))
+	client.SetExtent(r, trq, nil)
+	if b, _ = ioutil.ReadAll(r.Body); string(b) != "test" {
+		t.Errorf("expected %s got %s", "test", b)
+	}
+}
+
+func TestBuildUpstreamURL(t *testing.T) {
+
+	cfg := config.NewConfig()
+	oc := cfg.Origins["default"]
+	oc.Scheme = "http"
+	oc.Host = "0"
+	oc.PathPrefix = ""
+
+	client := &Client{name: "default", config: oc}
+	r, err := http.NewRequest(http.MethodGet, "http://0/default/exec?query=SELECT+1", nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	u := client.BuildUpstreamURL(r)
+	if u.Path != "/exec" || u.RawQuery != "query=SELECT+1" {
+		t.Errorf("expected %s got %s", "/exec?query=SELECT+1", u)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "http://0/exec", nil)
+	u = client.BuildUpstreamURL(r)
+	if u.Path != "/exec" {
+		t.Errorf("expected %s got %s", "/exec", u.Path)
+	}
+}
@@ -16,9 +16,7 @@ package proxy
 
 import (
 	"crypto/tls"
-	"crypto/x509"
 	"fmt"
-	"io/ioutil"
 	"net"
 	"net/http"
 	"time"
@@ -41,40 +39,10 @@ func NewHTTPClient(oc *config.OriginConfig) (*http.Client, error) {
 	var TLSConfig *tls.Config
 
 	if oc.TLS != nil {
-		TLSConfig = &tls.Config{InsecureSkipVerify: oc.TLS.InsecureSkipVerify}
-
-		if oc.TLS.ClientCertPath != "" && oc.TLS.ClientKeyPath != "" {
-			// load client cert
-			cert, err := tls.LoadX509KeyPair(oc.TLS.ClientCertPath, oc.TLS.ClientKeyPath)
-			if err != nil {
-				return nil, err
-			}
-			TLSConfig.Certificates = []tls.Certificate{cert}
-		}
-
-		if oc.TLS.CertificateAuthorityPaths != nil && len(oc.TLS.CertificateAuthorityPaths) > 0 {
-
-			// credit snippet to https://forfuncsake.github.io/post/2017/08/trust-extra-ca-cert-in-go-app/
-			// Get the SystemCertPool, continue with an empty pool on error
-			rootCAs, _ := x509.SystemCertPool()
-			if rootCAs == nil {
-				rootCAs = x509.NewCertPool()
-			}
-
-			for _, path := range oc.TLS.CertificateAuthorityPaths {
-				// Read in the cert file
-				certs, err := ioutil.ReadFile(path)
-				if err != nil {
-					return nil, err
-				}
-				// Append our cert to the system pool
-				if ok := rootCAs.AppendCertsFromPEM(certs); !ok {
-					return nil, fmt.Errorf("unable to append to CA Certs from file %s", path)
-				}
-			}
-
-			// Trust the augmented cert pool in our client
-			TLSConfig.RootCAs = rootCAs
+		var err error
+		TLSConfig, err = oc.TLS.ClientTLSConfig()
+		if err != nil {
+			return nil, err
 		}
 	}
 
@@ -26,10 +26,16 @@ import (
 	"github.com/Comcast/trickster/internal/proxy/methods"
 	"github.com/Comcast/trickster/internal/proxy/origins"
 	"github.com/Comcast/trickster/internal/proxy/origins/clickhouse"
+	"github.com/Comcast/trickster/internal/proxy/origins/druid"
+	"github.com/Comcast/trickster/internal/proxy/origins/elasticsearch"
+	"github.com/Comcast/trickster/internal/proxy/origins/graphite"
 	"github.com/Comcast/trickster/internal/proxy/origins/influxdb"
 	"github.com/Comcast/trickster/internal/proxy/origins/irondb"
+	"github.com/Comcast/trickster/internal/proxy/origins/loki"
+	"github.com/Comcast/trickster/internal/proxy/origins/opentsdb"
 	"github.com/Comcast/trickster/internal/proxy/origins/prometheus"
 	"github.com/Comcast/trickster/internal/proxy/origins/reverseproxycache"
+	"github.com/Comcast/trickster/internal/proxy/origins/sqlhttp"
 	"github.com/Comcast/trickster/internal/routing"
 	"github.com/Comcast/trickster/internal/util/log"
 	"github.com/Comcast/trickster/internal/util/middleware"
@@ -109,7 +115,7 @@ func registerOriginRoutes(k string, o *config.OriginConfig) error {
 	log.Info("registering route paths", log.Pairs{"originName": k, "originType": o.OriginType, "upstreamHost": o.Host})
 
 	switch strings.ToLower(o.OriginType) {
-	case "prometheus", "":
+	case "prometheus", "victoriametrics", "":
 		client, err = prometheus.NewClient(k, o, c)
 	case "influxdb":
 		client, err = influxdb.NewClient(k, o, c)
@@ -117,6 +123,18 @@ func registerOriginRoutes(k string, o *config.OriginConfig) error {
 		client, err = irondb.NewClient(k, o, c)
 	case "clickhouse":
 		client, err = clickhouse.NewClient(k, o, c)
+	case "graphite":
+		client, err = graphite.NewClient(k, o, c)
+	case "loki":
+		client, err = loki.NewClient(k, o, c)
+	case "elasticsearch":
+		client, err = elasticsearch.NewClient(k, o, c)
+	case "opentsdb":
+		client, err = opentsdb.NewClient(k, o, c)
+	case "druid":
+		client, err = druid.NewClient(k, o, c)
+	case "sqlhttp":
+		client, err = sqlhttp.NewClient(k, o, c)
 	case "rpc", "reverseproxycache":
 		client, err = reverseproxycache.NewClient(k, o, c)
 	}
@@ -140,6 +158,10 @@ func registerPathRoutes(handlers map[string]http.Handler, client origins.Client,
 	decorate := func(p *config.PathConfig) http.Handler {
 		// add Origin, Cache, and Path Configs to the HTTP Request's context
 		h := middleware.WithResourcesContext(client, o, c, p, p.Handler)
+		// reject requests that don't identify their tenant, when the origin requires it
+		if o.TenantHeaderRequired && o.TenantHeader != "" {
+			h = middleware.RequireTenant(o.TenantHeader, h)
+		}
 		// decorate frontend prometheus metrics
 		if !p.NoMetrics {
 			h = middleware.Decorate(o.Name, o.OriginType, p.Path, h)
@@ -18,6 +18,8 @@ import (
 	"sort"
 	"strings"
 	"time"
+
+	"github.com/Comcast/trickster/pkg/sort/times"
 )
 
 // ExtentList is a type of []Extent used for sorting the slice
@@ -263,3 +265,57 @@ func (el ExtentListLRU) UpdateLastUsed(lur Extent, step time.Duration) ExtentLis
 	}
 	return ExtentListLRU(el2.Compress(step))
 }
+
+// RemoveOldest selects up to n of the provided timestamps for removal from a Timeseries that has
+// no Step (raw samples), visiting the least-recently-used extents first, and the oldest timestamps
+// within each extent first. The provided timestamps must be sorted chronologically. Since raw
+// samples do not fall on step boundaries, each extent is then trimmed just past any removed
+// timestamps at its edges, and extents whose timestamps were all removed are dropped.
+func (el ExtentListLRU) RemoveOldest(tl times.Times, n int) (map[time.Time]bool, ExtentList) {
+
+	el = el.Clone()
+	sort.Sort(el)
+	removals := make(map[time.Time]bool)
+
+	// within returns the timestamps in tl that fall inside the extent (inclusive)
+	within := func(e Extent) times.Times {
+		i := sort.Search(len(tl), func(i int) bool { return !tl[i].Before(e.Start) })
+		j := sort.Search(len(tl), func(i int) bool { return tl[i].After(e.End) })
+		if j < i {
+			j = i
+		}
+		return tl[i:j]
+	}
+
+	for _, x := range el {
+		if len(removals) >= n {
+			break
+		}
+		for _, ts := range within(x) {
+			removals[ts] = true
+			if len(removals) >= n {
+				break
+			}
+		}
+	}
+
+	el2 := make(ExtentList, 0, len(el))
+	for _, x := range el {
+		w := within(x)
+		i, j := 0, len(w)
+		for i < j && removals[w[i]] {
+			x.Start = w[i].Add(time.Nanosecond)
+			i++
+		}
+		if len(w) > 0 && i == j {
+			continue
+		}
+		for j > i && removals[w[j-1]] {
+			x.End = w[j-1].Add(-time.Nanosecond)
+			j--
+		}
+		el2 = append(el2, x)
+	}
+
+	return removals, el2.Compress(0)
+}
@@ -20,6 +20,8 @@ import (
 	"strconv"
 	"testing"
 	"time"
+
+	"github.com/Comcast/trickster/pkg/sort/times"
 )
 
 var t98 = time.Unix(98, 0)
@@ -526,6 +528,54 @@ func TestExtentListLRUCopy(t *testing.T) {
 
 }
 
+func TestExtentListLRURemoveOldest(t *testing.T) {
+
+	ts := func(secs ...int64) times.Times {
+		tl := make(times.Times, len(secs))
+		for i, s := range secs {
+			tl[i] = time.Unix(s, 0)
+		}
+		return tl
+	}
+
+	// the second extent is least-recently used, so its oldest samples go first,
+	// and the sample it shares with the first extent is trimmed from both
+	el := ExtentListLRU{
+		Extent{Start: time.Unix(100, 0), End: time.Unix(205, 0), LastUsed: time.Unix(2, 0)},
+		Extent{Start: time.Unix(205, 0), End: time.Unix(300, 0), LastUsed: time.Unix(1, 0)},
+	}
+	tl := ts(102, 150, 205, 250, 290)
+
+	removals, el2 := el.RemoveOldest(tl, 2)
+	if len(removals) != 2 || !removals[time.Unix(205, 0)] || !removals[time.Unix(250, 0)] {
+		t.Errorf("unexpected removals %v", removals)
+	}
+	expected := ExtentList{
+		Extent{Start: time.Unix(100, 0), End: time.Unix(205, 0).Add(-time.Nanosecond), LastUsed: time.Unix(2, 0)},
+		Extent{Start: time.Unix(250, 0).Add(time.Nanosecond), End: time.Unix(300, 0), LastUsed: time.Unix(1, 0)},
+	}
+	if !reflect.DeepEqual(el2, expected) {
+		t.Errorf("expected %v got %v", expected, el2)
+	}
+
+	// an extent whose samples are all removed is dropped
+	removals, el2 = el.RemoveOldest(tl, 3)
+	if len(removals) != 3 {
+		t.Errorf("expected %d got %d", 3, len(removals))
+	}
+	if len(el2) != 1 || !el2[0].Start.Equal(time.Unix(100, 0)) {
+		t.Errorf("unexpected extents %v", el2)
+	}
+
+	// extents without any samples are retained
+	el = ExtentListLRU{Extent{Start: time.Unix(400, 0), End: time.Unix(500, 0)}}
+	removals, el2 = el.RemoveOldest(tl, 1)
+	if len(removals) != 0 || len(el2) != 1 {
+		t.Errorf("unexpected removals %v or extents %v", removals, el2)
+	}
+
+}
+
 func TestCompress(t *testing.T) {
 
 	tests := []struct {
@@ -16,6 +16,7 @@ package timeseries
 import (
 	"fmt"
 	"net/url"
+	"sort"
 	"strings"
 	"time"
 
@@ -38,6 +39,8 @@ type TimeRangeQuery struct {
 	FastForwardDisable bool
 	// TemplateURL is used by some Origin Types for templatization of url parameters containing timestamps
 	TemplateURL *url.URL
+	// Lookback is how far before each timestamp the query reads data, when known by the Origin Type
+	Lookback time.Duration
 }
 
 // Clone returns an exact copy of a TimeRangeQuery
@@ -49,6 +52,7 @@ func (trq *TimeRangeQuery) Clone() *TimeRangeQuery {
 		IsOffset:           trq.IsOffset,
 		TimestampFieldName: trq.TimestampFieldName,
 		FastForwardDisable: trq.FastForwardDisable,
+		Lookback:           trq.Lookback,
 	}
 
 	if trq.TemplateURL != nil {
@@ -59,11 +63,12 @@ func (trq *TimeRangeQuery) Clone() *TimeRangeQuery {
 }
 
 // NormalizeExtent adjusts the Start and End of a TimeRangeQuery's Extent to align against normalized boundaries.
+// Queries without a Step (raw samples) are not aligned, but their End is still capped at the current time.
 func (trq *TimeRangeQuery) NormalizeExtent() {
+	if !trq.IsOffset && trq.Extent.End.After(time.Now()) {
+		trq.Extent.End = time.Now()
+	}
 	if trq.Step.Seconds() > 0 {
-		if !trq.IsOffset && trq.Extent.End.After(time.Now()) {
-			trq.Extent.End = time.Now()
-		}
 		trq.Extent.Start = trq.Extent.Start.Truncate(trq.Step)
 		trq.Extent.End = trq.Extent.End.Truncate(trq.Step)
 	}
@@ -74,6 +79,9 @@ func (trq *TimeRangeQuery) CalculateDeltas(have ExtentList) ExtentList {
 	if len(have) == 0 {
 		return ExtentList{trq.Extent}
 	}
+	if trq.Step <= 0 {
+		return trq.calculateContinuousDeltas(have)
+	}
 	misCap := trq.Extent.End.Sub(trq.Extent.Start) / trq.Step
 	if misCap < 0 {
 		misCap = 0
@@ -111,6 +119,38 @@ func (trq *TimeRangeQuery) CalculateDeltas(have ExtentList) ExtentList {
 	return ins
 }
 
+// calculateContinuousDeltas provides the missing extents for a query that has no Step, such as a
+// raw sample query. Since samples can fall at any time, the gaps between cached extents are
+// returned as-is, inclusive of the cached boundaries on either side.
+func (trq *TimeRangeQuery) calculateContinuousDeltas(have ExtentList) ExtentList {
+	have = have.Clone()
+	sort.Sort(have)
+	ins := ExtentList{}
+	cursor := trq.Extent.Start
+	for _, e := range have {
+		if !cursor.Before(trq.Extent.End) {
+			break
+		}
+		if e.End.Before(cursor) {
+			continue
+		}
+		if e.Start.After(cursor) {
+			end := e.Start
+			if end.After(trq.Extent.End) {
+				end = trq.Extent.End
+			}
+			ins = append(ins, Extent{Start: cursor, End: end})
+		}
+		if e.End.After(cursor) {
+			cursor = e.End
+		}
+	}
+	if cursor.Before(trq.Extent.End) {
+		ins = append(ins, Extent{Start: cursor, End: trq.Extent.End})
+	}
+	return ins
+}
+
 func (trq *TimeRangeQuery) String() string {
 	return fmt.Sprintf(`{ "statement": "%s", "step": "%s", "extent": "%s" }`,
 		strings.Replace(trq.Statement, `"`, `\"`, -1), trq.Step.String(), trq.Extent.String())
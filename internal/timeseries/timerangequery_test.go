@@ -52,6 +52,28 @@ func TestCalculateDeltas(t *testing.T) {
 			[]Extent{{Start: time.Unix(101, 0), End: time.Unix(101, 0)}},
 			1, 101, 1,
 		},
+		// queries without a step return the gaps between cached extents
+		{
+			[]Extent{{Start: time.Unix(50, 0), End: time.Unix(100, 0)}},
+			[]Extent{{Start: time.Unix(1, 0), End: time.Unix(50, 0)}},
+			1, 100, 0,
+		},
+		{
+			[]Extent{{Start: time.Unix(50, 0), End: time.Unix(60, 0)}, {Start: time.Unix(20, 0), End: time.Unix(30, 0)}},
+			[]Extent{{Start: time.Unix(1, 0), End: time.Unix(20, 0)}, {Start: time.Unix(30, 0), End: time.Unix(50, 0)},
+				{Start: time.Unix(60, 0), End: time.Unix(100, 0)}},
+			1, 100, 0,
+		},
+		{
+			[]Extent{{Start: time.Unix(0, 0), End: time.Unix(200, 0)}},
+			[]Extent{},
+			1, 100, 0,
+		},
+		{
+			[]Extent{{Start: time.Unix(150, 0), End: time.Unix(200, 0)}},
+			[]Extent{{Start: time.Unix(1, 0), End: time.Unix(100, 0)}},
+			1, 100, 0,
+		},
 	}
 
 	for i, test := range tests {
@@ -106,6 +128,12 @@ func TestNormalizeExtent(t *testing.T) {
 			0, expected,
 			false,
 		},
+		// Ensure that queries without a step are not aligned
+		{
+			1, 103, 0, 1,
+			1, 103,
+			false,
+		},
 	}
 
 	for i, test := range tests {
@@ -127,7 +155,7 @@ func TestNormalizeExtent(t *testing.T) {
 
 func TestClone(t *testing.T) {
 	u, _ := url.Parse("http://127.0.0.1/")
-	trq := &TimeRangeQuery{Statement: "1234", Extent: Extent{Start: time.Unix(5, 0), End: time.Unix(10, 0)}, Step: time.Duration(5) * time.Second, TemplateURL: u}
+	trq := &TimeRangeQuery{Statement: "1234", Extent: Extent{Start: time.Unix(5, 0), End: time.Unix(10, 0)}, Step: time.Duration(5) * time.Second, TemplateURL: u, Lookback: time.Minute}
 	c := trq.Clone()
 	if !reflect.DeepEqual(trq, c) {
 		t.Errorf("expected %s got %s", trq.String(), c.String())
@@ -57,6 +57,10 @@ var ProxyRequestElements *prometheus.CounterVec
 // ProxyRequestDuration is a Histogram of time required in seconds to proxy a given Prometheus query
 var ProxyRequestDuration *prometheus.HistogramVec
 
+// ProxyTenantRequestStatus is a Counter of downstream client requests handled by Trickster for
+// origins with a tenant header, by tenant
+var ProxyTenantRequestStatus *prometheus.CounterVec
+
 // CacheObjectOperations is a Counter of operations (in # of objects) performed on a Trickster cache
 var CacheObjectOperations *prometheus.CounterVec
 
@@ -78,6 +82,21 @@ var CacheMaxObjects *prometheus.GaugeVec
 // CacheMaxBytes is a Gauge representing the Trickster cache's Max Object Threshold for triggering an eviction exercise
 var CacheMaxBytes *prometheus.GaugeVec
 
+// CacheOriginObjects is a Gauge representing the number of objects an origin has in a Trickster cache
+var CacheOriginObjects *prometheus.GaugeVec
+
+// CacheOriginBytes is a Gauge representing the number of bytes an origin has in a Trickster cache
+var CacheOriginBytes *prometheus.GaugeVec
+
+// CacheUp is a Gauge representing the result of the most recent health check of a Trickster cache (1 is up, 0 is down)
+var CacheUp *prometheus.GaugeVec
+
+// CacheCircuitBreakerState is a Gauge representing the state of a Trickster cache's circuit breaker (0 closed, 1 half-open, 2 open)
+var CacheCircuitBreakerState *prometheus.GaugeVec
+
+// CacheCircuitBreakerTransitions is a Counter of state changes of a Trickster cache's circuit breaker
+var CacheCircuitBreakerTransitions *prometheus.CounterVec
+
 // ProxyMaxConnections is a Gauge representing the max number of active concurrent connections in the server
 var ProxyMaxConnections prometheus.Gauge
 
@@ -166,6 +185,16 @@ func initialize() {
 		[]string{"origin_name", "origin_type", "method", "status", "http_status", "path"},
 	)
 
+	ProxyTenantRequestStatus = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: proxySubsystem,
+			Name:      "tenant_requests_total",
+			Help:      "Count of downstream client requests handled by Trickster for each tenant of an origin",
+		},
+		[]string{"origin_name", "origin_type", "tenant", "cache_status", "http_status"},
+	)
+
 	ProxyMaxConnections = prometheus.NewGauge(
 		prometheus.GaugeOpts{
 			Namespace: metricNamespace,
@@ -289,6 +318,56 @@ func initialize() {
 		[]string{"cache_name", "cache_type"},
 	)
 
+	CacheOriginObjects = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: cacheSubsystem,
+			Name:      "origin_usage_objects",
+			Help:      "Number of objects an origin has in a Trickster cache.",
+		},
+		[]string{"cache_name", "cache_type", "origin_name"},
+	)
+
+	CacheOriginBytes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: cacheSubsystem,
+			Name:      "origin_usage_bytes",
+			Help:      "Number of bytes an origin has in a Trickster cache.",
+		},
+		[]string{"cache_name", "cache_type", "origin_name"},
+	)
+
+	CacheUp = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: cacheSubsystem,
+			Name:      "up",
+			Help:      "Result of the most recent health check of a Trickster cache (1 is up, 0 is down).",
+		},
+		[]string{"cache_name", "cache_type"},
+	)
+
+	CacheCircuitBreakerState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricNamespace,
+			Subsystem: cacheSubsystem,
+			Name:      "circuit_breaker_state",
+			Help:      "State of a Trickster cache's circuit breaker (0 is closed, 1 is half-open, 2 is open).",
+		},
+		[]string{"cache_name", "cache_type"},
+	)
+
+	CacheCircuitBreakerTransitions = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricNamespace,
+			Subsystem: cacheSubsystem,
+			Name:      "circuit_breaker_transitions_total",
+			Help:      "Count of state changes of a Trickster cache's circuit breaker.",
+		},
+		[]string{"cache_name", "cache_type", "state"},
+	)
+
 	// Register Metrics
 	prometheus.MustRegister(FrontendRequestStatus)
 	prometheus.MustRegister(FrontendRequestDuration)
@@ -296,6 +375,7 @@ func initialize() {
 	prometheus.MustRegister(ProxyRequestStatus)
 	prometheus.MustRegister(ProxyRequestElements)
 	prometheus.MustRegister(ProxyRequestDuration)
+	prometheus.MustRegister(ProxyTenantRequestStatus)
 	prometheus.MustRegister(ProxyMaxConnections)
 	prometheus.MustRegister(ProxyActiveConnections)
 	prometheus.MustRegister(ProxyConnectionRequested)
@@ -309,6 +389,11 @@ func initialize() {
 	prometheus.MustRegister(CacheBytes)
 	prometheus.MustRegister(CacheMaxObjects)
 	prometheus.MustRegister(CacheMaxBytes)
+	prometheus.MustRegister(CacheOriginObjects)
+	prometheus.MustRegister(CacheOriginBytes)
+	prometheus.MustRegister(CacheUp)
+	prometheus.MustRegister(CacheCircuitBreakerState)
+	prometheus.MustRegister(CacheCircuitBreakerTransitions)
 
 	// Turn up the Metrics HTTP Server
 	if config.Metrics != nil && config.Metrics.ListenPort > 0 {
@@ -29,6 +29,7 @@ import (
 	"github.com/Comcast/trickster/internal/proxy/request"
 	"github.com/Comcast/trickster/internal/util/metrics"
 	tr "github.com/Comcast/trickster/internal/util/tracing/registration"
+	"github.com/Comcast/trickster/pkg/opentsdbsim"
 	"github.com/Comcast/trickster/pkg/promsim"
 	"github.com/Comcast/trickster/pkg/rangesim"
 )
@@ -75,6 +76,9 @@ func NewTestInstance(
 	if originType == "promsim" {
 		ts = promsim.NewTestServer()
 		originType = "prometheus"
+	} else if originType == "opentsdbsim" {
+		ts = opentsdbsim.NewTestServer()
+		originType = "opentsdb"
 	} else if originType == "rangesim" {
 		ts = rangesim.NewTestServer()
 		originType = "rpc"
@@ -101,4 +101,9 @@ func TestNewTestInstance(t *testing.T) {
 		t.Error(err)
 	}
 
+	_, _, _, _, err = NewTestInstance("", nil, 200, "", nil, "opentsdbsim", "test", "debug")
+	if err != nil {
+		t.Error(err)
+	}
+
 }
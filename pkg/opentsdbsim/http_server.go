@@ -0,0 +1,199 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package opentsdbsim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NewTestServer launches a Test OpenTSDB Server (for unit testing)
+func NewTestServer() *httptest.Server {
+	return httptest.NewServer(MuxWithRoutes())
+}
+
+// MuxWithRoutes returns a ServeMux that includes the OpenTSDBSim handlers already registered
+func MuxWithRoutes() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/query", queryHandler)
+	mux.HandleFunc("/api/version", versionHandler)
+	return mux
+}
+
+// queryRequest is the JSON request body format of a POST to /api/query
+type queryRequest struct {
+	Start        interface{} `json:"start"`
+	End          interface{} `json:"end"`
+	MSResolution bool        `json:"msResolution"`
+	Queries      []struct {
+		Aggregator string            `json:"aggregator"`
+		Metric     string            `json:"metric"`
+		Downsample string            `json:"downsample"`
+		Tags       map[string]string `json:"tags"`
+	} `json:"queries"`
+}
+
+func queryHandler(w http.ResponseWriter, r *http.Request) {
+
+	var s, e string
+	var ms bool
+	var queries []SubQuery
+
+	switch r.Method {
+	case http.MethodGet:
+		params := r.URL.Query()
+		s, e = params.Get("start"), params.Get("end")
+		_, ms = params["ms"]
+		for _, m := range params["m"] {
+			queries = append(queries, ParseSubQuery(m))
+		}
+	case http.MethodPost:
+		qr := &queryRequest{}
+		dec := json.NewDecoder(r.Body)
+		dec.UseNumber()
+		if err := dec.Decode(qr); err != nil {
+			writeError(http.StatusBadRequest, []byte("unable to parse request body"), w)
+			return
+		}
+		s, e = fmt.Sprint(qr.Start), fmt.Sprint(qr.End)
+		if qr.End == nil {
+			e = ""
+		}
+		ms = qr.MSResolution
+		for _, q := range qr.Queries {
+			m := q.Aggregator + ":"
+			if q.Downsample != "" {
+				m += q.Downsample + ":"
+			}
+			queries = append(queries, ParseSubQuery(m+q.Metric+"{"+joinTags(q.Tags)+"}"))
+		}
+	default:
+		writeError(http.StatusMethodNotAllowed, []byte{}, w)
+		return
+	}
+
+	if s == "" || len(queries) == 0 {
+		writeError(http.StatusBadRequest, []byte("missing required parameter"), w)
+		return
+	}
+
+	now := time.Now()
+	start, err := parseTime(s, now)
+	if err != nil {
+		writeError(http.StatusBadRequest, []byte("unable to parse start time parameter"), w)
+		return
+	}
+
+	end := now
+	if e != "" {
+		end, err = parseTime(e, now)
+		if err != nil {
+			writeError(http.StatusBadRequest, []byte("unable to parse end time parameter"), w)
+			return
+		}
+	}
+
+	json, code, _ := GetQueryData(queries, start, end, ms)
+
+	if code == http.StatusOK {
+		w.Header().Set("Content-Type", "application/json")
+	}
+
+	w.WriteHeader(code)
+
+	if code == http.StatusOK {
+		w.Write([]byte(json))
+	} else {
+		w.Write([]byte{})
+	}
+}
+
+func versionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"version":"opentsdbsim"}`))
+}
+
+func writeError(code int, body []byte, w http.ResponseWriter) {
+	w.WriteHeader(code)
+	w.Write(body)
+}
+
+func joinTags(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// parseTime converts a start or end parameter to time.Time. Supported values are Unix
+// epochs in seconds (with up to 10 digits) or milliseconds, and relative times like 1h-ago
+func parseTime(s string, now time.Time) (time.Time, error) {
+	if strings.HasSuffix(s, "-ago") {
+		d, err := ParseDuration(strings.TrimSuffix(s, "-ago"))
+		if err != nil {
+			return time.Time{}, err
+		}
+		return now.Add(-d), nil
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if len(s) <= 10 {
+			return time.Unix(i, 0), nil
+		}
+		return time.Unix(0, i*int64(time.Millisecond)), nil
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q to a valid timestamp", s)
+}
+
+// ParseDuration parses an OpenTSDB duration, such as 30s or 1h
+func ParseDuration(input string) (time.Duration, error) {
+	for i := range input {
+		if input[i] >= '0' && input[i] <= '9' {
+			continue
+		}
+		units, ok := UnitMap[input[i:]]
+		if !ok || i == 0 {
+			return 0, durationError(input)
+		}
+		v, err := strconv.ParseInt(input[0:i], 10, 64)
+		if err != nil {
+			return 0, durationError(input)
+		}
+		return time.Duration(v * units), nil
+	}
+	return 0, durationError(input)
+}
+
+func durationError(input string) error {
+	return fmt.Errorf("cannot parse %q to a valid duration", input)
+}
+
+// UnitMap provides a map of OpenTSDB time unit indicators to nanoseconds of duration per unit
+var UnitMap = map[string]int64{
+	"ms": int64(time.Millisecond),
+	"s":  int64(time.Second),
+	"m":  int64(time.Minute),
+	"h":  int64(time.Hour),
+	"d":  int64(24 * time.Hour),
+	"w":  int64(7 * 24 * time.Hour),
+	"n":  int64(30 * 24 * time.Hour),
+	"y":  int64(365 * 24 * time.Hour),
+}
@@ -0,0 +1,158 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package opentsdbsim
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewTestServer(t *testing.T) {
+	if NewTestServer() == nil {
+		t.Errorf("failed to get test server object")
+	}
+}
+
+const expectedQuery = `[{"metric":"up","tags":{"series_id":"0"},"aggregateTags":[],"dps":{"0":29,"15":81,"30":23}}]`
+
+func TestQueryHandler(t *testing.T) {
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "http://0/api/query?m=sum:15s-avg:up&start=0&end=30", nil)
+	queryHandler(w, r)
+
+	resp := w.Result()
+
+	// it should return 200 OK
+	if resp.StatusCode != 200 {
+		t.Errorf("expected 200 got %d", resp.StatusCode)
+	}
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if string(bodyBytes) != expectedQuery {
+		t.Errorf("expected %s got %s", expectedQuery, bodyBytes)
+	}
+
+	// millisecond start and end
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("GET", "http://0/api/query?m=sum:15s-avg:up&start=0000000000000&end=0000000030000", nil)
+	queryHandler(w, r)
+	if w.Result().StatusCode != 200 {
+		t.Errorf("expected 200 got %d", w.Result().StatusCode)
+	}
+}
+
+func TestQueryHandlerPost(t *testing.T) {
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "http://0/api/query",
+		strings.NewReader(`{"start":0,"end":30,"queries":[{"aggregator":"sum","metric":"up","downsample":"15s-avg"}]}`))
+	queryHandler(w, r)
+
+	resp := w.Result()
+	if resp.StatusCode != 200 {
+		t.Errorf("expected 200 got %d", resp.StatusCode)
+	}
+
+	bodyBytes, _ := ioutil.ReadAll(resp.Body)
+	if string(bodyBytes) != expectedQuery {
+		t.Errorf("expected %s got %s", expectedQuery, bodyBytes)
+	}
+
+	// relative start with no end
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("POST", "http://0/api/query",
+		strings.NewReader(`{"start":"1h-ago","queries":[{"aggregator":"sum","metric":"up","tags":{"host":"a"}}]}`))
+	queryHandler(w, r)
+
+	resp = w.Result()
+	if resp.StatusCode != 200 {
+		t.Errorf("expected 200 got %d", resp.StatusCode)
+	}
+
+	bodyBytes, _ = ioutil.ReadAll(resp.Body)
+	if c := strings.Count(string(bodyBytes), ":"); c < 60 {
+		t.Errorf("expected about an hour of datapoints got %s", bodyBytes)
+	}
+}
+
+func TestQueryHandlerErrors(t *testing.T) {
+
+	tests := []struct {
+		method, url, body string
+		code              int
+	}{
+		{"GET", "http://0/api/query?start=0", "", http.StatusBadRequest},
+		{"GET", "http://0/api/query?m=sum:up&start=foo", "", http.StatusBadRequest},
+		{"GET", "http://0/api/query?m=sum:up&start=0&end=foo", "", http.StatusBadRequest},
+		{"GET", "http://0/api/query?m=sum:up&start=1x-ago", "", http.StatusBadRequest},
+		{"GET", "http://0/api/query?m=sum:up{status_code=500}&start=0&end=60", "", http.StatusInternalServerError},
+		{"POST", "http://0/api/query", "{", http.StatusBadRequest},
+		{"PUT", "http://0/api/query", "", http.StatusMethodNotAllowed},
+	}
+
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(test.method, test.url, strings.NewReader(test.body))
+		queryHandler(w, r)
+		if w.Result().StatusCode != test.code {
+			t.Errorf("expected %d got %d for %s", test.code, w.Result().StatusCode, test.url)
+		}
+	}
+}
+
+func TestVersionHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "http://0/api/version", nil)
+	versionHandler(w, r)
+	if w.Result().StatusCode != 200 {
+		t.Errorf("expected 200 got %d", w.Result().StatusCode)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected time.Duration
+		err      bool
+	}{
+		{"500ms", 500 * time.Millisecond, false},
+		{"1h", time.Hour, false},
+		{"2w", 14 * 24 * time.Hour, false},
+		{"1n", 30 * 24 * time.Hour, false},
+		{"h", 0, true},
+		{"1x", 0, true},
+		{"10", 0, true},
+		{"99999999999999999999s", 0, true},
+	}
+
+	for _, test := range tests {
+		d, err := ParseDuration(test.input)
+		if test.err != (err != nil) {
+			t.Errorf("unexpected error state for %s: %v", test.input, err)
+		}
+		if d != test.expected {
+			t.Errorf("expected %s got %s", test.expected, d)
+		}
+	}
+}
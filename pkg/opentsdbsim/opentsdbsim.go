@@ -0,0 +1,211 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+// Package opentsdbsim is a rudimentary OpenTSDB /api/query output simulator,
+// intended for use with unit testing that would normally require a running OpenTSDB server.
+// OpenTSDBSim outputs repeatable, OpenTSDB-formatted data, synthetically generated from sub query and timestamp.
+// It does not validate queries and does not produce output that accurately depicts data shapes expected of the query.
+// OpenTSDBSim currently only supports the default (map) datapoint format
+package opentsdbsim
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	mdSeriesCount = "series_count"
+	mdLatency     = "latency_ms"
+	mdMaxVal      = "max_value"
+	mdMinVal      = "min_value"
+	mdSeriesID    = "series_id"
+	mdStatusCode  = "status_code"
+	mdInvalidBody = "invalid_response_body"
+)
+
+// defaultInterval is the interval between datapoints of sub queries that aren't downsampled
+const defaultInterval = time.Minute
+
+// Modifiers represents a collection of modifiers for the simulator's behavior provided by the user
+// as tags of a sub query, such as sum:1m-avg:sys.cpu.user{series_count=2,max_value=10}
+type Modifiers struct {
+	// SeriesCount defines how many series to return
+	SeriesCount int
+	// Latency introduces a static delay in responding to each request
+	Latency time.Duration
+	// MaxValue limits the maximum value of any data in the query result
+	MaxValue int
+	// MinValue limits the minimum value of any data in the query result
+	MinValue int
+	// StatusCode indicates the desired return status code, to simulate errors
+	StatusCode int
+	// InvalidResponseBody when > 0 causes the server to respond with a payload that cannot be unmarshaled
+	// useful for causing and testing unmarshling failure cases
+	InvalidResponseBody int
+
+	tags map[string]string
+}
+
+// SubQuery is a parsed sub query of an /api/query request
+type SubQuery struct {
+	Metric   string
+	Interval time.Duration
+	Tags     map[string]string
+}
+
+// ParseSubQuery parses a sub query in the format of the m URL parameter, such as
+// sum:1m-avg:rate:sys.cpu.user{host=a}
+func ParseSubQuery(m string) SubQuery {
+
+	sq := SubQuery{Interval: defaultInterval, Tags: make(map[string]string)}
+
+	if i := strings.Index(m, "{"); i >= 0 {
+		tags := strings.TrimSuffix(m[i+1:], "}")
+		m = m[:i]
+		for _, tag := range strings.Split(tags, ",") {
+			parts := strings.SplitN(tag, "=", 2)
+			if len(parts) == 2 {
+				sq.Tags[parts[0]] = parts[1]
+			}
+		}
+	}
+
+	parts := strings.Split(m, ":")
+	sq.Metric = parts[len(parts)-1]
+	for _, p := range parts[1 : len(parts)-1] {
+		if i := strings.Index(p, "-"); i > 0 {
+			if d, err := ParseDuration(p[:i]); err == nil && d > 0 {
+				sq.Interval = d
+			}
+		}
+	}
+
+	return sq
+}
+
+// GetQueryData returns a simulated /api/query response with repeatable results. Datapoints
+// are aligned to the interval of each sub query and keyed in seconds, or in milliseconds when ms is true.
+func GetQueryData(queries []SubQuery, start time.Time, end time.Time, ms bool) (string, int, error) {
+
+	code := 200
+	series := make([]string, 0, len(queries))
+
+	for _, q := range queries {
+
+		d := getModifiers(q.Tags)
+		if d.Latency > 0 {
+			time.Sleep(d.Latency)
+		}
+
+		if d.InvalidResponseBody > 0 {
+			return "foo", d.StatusCode, nil
+		}
+
+		if d.StatusCode != 200 {
+			code = d.StatusCode
+		}
+
+		queryVal := getQueryVal(q.Metric)
+		first := start.Truncate(q.Interval)
+
+		for i := 0; d.SeriesCount > i; i++ {
+			tags := make(map[string]string, len(d.tags)+1)
+			for k, v := range d.tags {
+				tags[k] = v
+			}
+			tags[mdSeriesID] = strconv.Itoa(i)
+
+			dps := make([]string, 0, int(end.Sub(first)/q.Interval)+1)
+			for t := first; !t.After(end); t = t.Add(q.Interval) {
+				ts := t.Unix()
+				if ms {
+					ts = t.UnixNano() / int64(time.Millisecond)
+				}
+				dps = append(dps, fmt.Sprintf(`"%d":%d`, ts, repeatableRandomVal(d, i, queryVal, t)))
+			}
+
+			series = append(series, fmt.Sprintf(`{"metric":"%s","tags":{%s},"aggregateTags":[],"dps":{%s}}`,
+				q.Metric, formatTags(tags), strings.Join(dps, ",")))
+		}
+	}
+
+	return "[" + strings.Join(series, ",") + "]", code, nil
+}
+
+func getModifiers(tags map[string]string) *Modifiers {
+
+	d := &Modifiers{
+		SeriesCount: 1,
+		MaxValue:    100,
+		MinValue:    0,
+		StatusCode:  200,
+		tags:        make(map[string]string, len(tags)),
+	}
+
+	for k, v := range tags {
+		d.tags[k] = v
+		i, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			continue
+		}
+		switch k {
+		case mdSeriesCount:
+			d.SeriesCount = int(i)
+		case mdLatency:
+			d.Latency = time.Duration(i) * time.Millisecond
+		case mdMaxVal:
+			d.MaxValue = int(i)
+		case mdMinVal:
+			d.MinValue = int(i)
+		case mdStatusCode:
+			d.StatusCode = int(i)
+		case mdInvalidBody:
+			d.InvalidResponseBody = int(i)
+		}
+	}
+
+	return d
+}
+
+func formatTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = fmt.Sprintf(`"%s":"%s"`, k, tags[k])
+	}
+	return strings.Join(pairs, ",")
+}
+
+func repeatableRandomVal(d *Modifiers, seriesIndex int, querySeed int64, t time.Time) int {
+	rand.Seed(querySeed + int64(seriesIndex) + t.Unix())
+	return d.MinValue + rand.Intn(d.MaxValue-d.MinValue)
+}
+
+// Calculates a number for the Query Value
+func getQueryVal(query string) int64 {
+	l := len(query)
+	var v int64
+	for i := 0; i < l; i++ {
+		v += int64(query[i])
+	}
+	v = v * v * v
+	return v
+}
@@ -0,0 +1,93 @@
+/**
+* Copyright 2018 Comcast Cable Communications Management, LLC
+* Licensed under the Apache License, Version 2.0 (the "License");
+* you may not use this file except in compliance with the License.
+* You may obtain a copy of the License at
+* http://www.apache.org/licenses/LICENSE-2.0
+* Unless required by applicable law or agreed to in writing, software
+* distributed under the License is distributed on an "AS IS" BASIS,
+* WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+* See the License for the specific language governing permissions and
+* limitations under the License.
+ */
+
+package opentsdbsim
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSubQuery(t *testing.T) {
+
+	sq := ParseSubQuery("sum:5m-avg-zero:rate:sys.cpu.user{host=a,series_count=2}")
+	if sq.Metric != "sys.cpu.user" {
+		t.Errorf("expected %s got %s", "sys.cpu.user", sq.Metric)
+	}
+	if sq.Interval != 5*time.Minute {
+		t.Errorf("expected %s got %s", 5*time.Minute, sq.Interval)
+	}
+	if len(sq.Tags) != 2 || sq.Tags["host"] != "a" {
+		t.Errorf("unexpected tags %v", sq.Tags)
+	}
+
+	sq = ParseSubQuery("sum:sys.cpu.user")
+	if sq.Interval != defaultInterval || len(sq.Tags) != 0 {
+		t.Errorf("unexpected sub query %v", sq)
+	}
+}
+
+func TestGetQueryData(t *testing.T) {
+
+	sq := ParseSubQuery("sum:15s-avg:up")
+	out, code, err := GetQueryData([]SubQuery{sq}, time.Unix(5, 0), time.Unix(30, 0), false)
+	if err != nil {
+		t.Error(err)
+	}
+	if code != 200 {
+		t.Errorf("expected %d got %d", 200, code)
+	}
+
+	const expected = `[{"metric":"up","tags":{"series_id":"0"},"aggregateTags":[],"dps":{"0":29,"15":81,"30":23}}]`
+	if out != expected {
+		t.Errorf("expected %s got %s", expected, out)
+	}
+
+	// millisecond timestamps
+	out, _, _ = GetQueryData([]SubQuery{sq}, time.Unix(0, 0), time.Unix(15, 0), true)
+	const expectedMS = `[{"metric":"up","tags":{"series_id":"0"},"aggregateTags":[],"dps":{"0":29,"15000":81}}]`
+	if out != expectedMS {
+		t.Errorf("expected %s got %s", expectedMS, out)
+	}
+
+	// multiple series
+	sq = ParseSubQuery("sum:15s-avg:up{series_count=2,host=a}")
+	out, _, _ = GetQueryData([]SubQuery{sq}, time.Unix(0, 0), time.Unix(0, 0), false)
+	const expectedMulti = `[{"metric":"up","tags":{"host":"a","series_count":"2","series_id":"0"},"aggregateTags":[],"dps":{"0":29}},` +
+		`{"metric":"up","tags":{"host":"a","series_count":"2","series_id":"1"},"aggregateTags":[],"dps":{"0":75}}]`
+	if out != expectedMulti {
+		t.Errorf("expected %s got %s", expectedMulti, out)
+	}
+}
+
+func TestGetQueryDataModifiers(t *testing.T) {
+
+	sq := ParseSubQuery("sum:up{status_code=500}")
+	_, code, _ := GetQueryData([]SubQuery{sq}, time.Unix(0, 0), time.Unix(60, 0), false)
+	if code != 500 {
+		t.Errorf("expected %d got %d", 500, code)
+	}
+
+	sq = ParseSubQuery("sum:up{invalid_response_body=1,latency_ms=1}")
+	out, _, _ := GetQueryData([]SubQuery{sq}, time.Unix(0, 0), time.Unix(60, 0), false)
+	if out != "foo" {
+		t.Errorf("expected %s got %s", "foo", out)
+	}
+
+	sq = ParseSubQuery("sum:up{min_value=10,max_value=11}")
+	out, _, _ = GetQueryData([]SubQuery{sq}, time.Unix(0, 0), time.Unix(0, 0), false)
+	const expected = `[{"metric":"up","tags":{"max_value":"11","min_value":"10","series_id":"0"},"aggregateTags":[],"dps":{"0":10}}]`
+	if out != expected {
+		t.Errorf("expected %s got %s", expected, out)
+	}
+}
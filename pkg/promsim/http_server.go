@@ -37,7 +37,9 @@ func MuxWithRoutes() *http.ServeMux {
 
 func queryRangeHandler(w http.ResponseWriter, r *http.Request) {
 
-	params := r.URL.Query()
+	// parameters may be in the url or, for POST requests, in a form-encoded body
+	r.ParseForm()
+	params := r.Form
 	q := params.Get("query")
 	s := params.Get("start")
 	e := params.Get("end")
@@ -92,7 +94,8 @@ func queryHandler(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 
-	params := r.URL.Query()
+	r.ParseForm()
+	params := r.Form
 	q := params.Get("query")
 	t := params.Get("time")
 
@@ -17,6 +17,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -73,6 +74,32 @@ func TestQueryRangeHandler(t *testing.T) {
 
 }
 
+func TestQueryRangeHandlerFormPost(t *testing.T) {
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "http://0/query_range", strings.NewReader("query=up&start=0&end=30&step=15"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	queryRangeHandler(w, r)
+
+	resp := w.Result()
+
+	// it should return 200 OK
+	if resp.StatusCode != 200 {
+		t.Errorf("expected 200 got %d", resp.StatusCode)
+	}
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Error(err)
+	}
+
+	const expected = `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"series_id":"0"},"values":[[0,"29"],[15,"81"],[30,"23"]]}]}}`
+
+	if string(bodyBytes) != expected {
+		t.Errorf("expected %s got %s", expected, bodyBytes)
+	}
+}
+
 func TestQueryRangeHandlerFloatTime(t *testing.T) {
 
 	w := httptest.NewRecorder()